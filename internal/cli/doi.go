@@ -28,7 +28,6 @@ import (
 
 	"github.com/scttfrdmn/cicada/internal/config"
 	"github.com/scttfrdmn/cicada/internal/doi"
-	"github.com/scttfrdmn/cicada/internal/metadata"
 )
 
 // NewDOICmd creates the DOI command
@@ -96,8 +95,7 @@ Examples:
 			}
 
 			// Extract metadata
-			registry := metadata.NewExtractorRegistry()
-			registry.RegisterDefaults()
+			registry := newExtractorRegistry(cmd.ErrOrStderr())
 
 			extractedMeta, err := registry.Extract(path)
 			if err != nil {
@@ -126,6 +124,7 @@ Examples:
 				MinQualityScore:    60.0,
 				RequireRealAuthors: true,
 				RequireDescription: true,
+				Ontologies:         loadOntologyIndex(cmd.ErrOrStderr()),
 			}
 
 			// For now, use a disabled provider registry
@@ -167,6 +166,9 @@ Examples:
 				}
 
 				if outputFile != "" {
+					if result.ContainsPHI {
+						return errContainsPHI(path)
+					}
 					return os.WriteFile(outputFile, data, 0644)
 				}
 				fmt.Println(string(data))
@@ -234,6 +236,9 @@ Examples:
 				}
 			}
 
+			if result.ContainsPHI {
+				return errContainsPHI(path)
+			}
 			return nil
 		},
 	}
@@ -248,6 +253,12 @@ Examples:
 	return cmd
 }
 
+// errContainsPHI is returned when a file carries protected health
+// information and must not be published.
+func errContainsPHI(path string) error {
+	return fmt.Errorf("refusing to prepare %s for publication: it contains protected health information", filepath.Base(path))
+}
+
 // newDOIValidateCmd creates the doi validate subcommand
 func newDOIValidateCmd() *cobra.Command {
 	var (
@@ -282,8 +293,7 @@ Examples:
 			}
 
 			// Extract metadata
-			registry := metadata.NewExtractorRegistry()
-			registry.RegisterDefaults()
+			registry := newExtractorRegistry(cmd.ErrOrStderr())
 
 			extractedMeta, err := registry.Extract(path)
 			if err != nil {
@@ -687,8 +697,7 @@ func runDOIMint(filePath, providerName, publisher, license, enrichmentFile, pres
 
 	// Step 3: Extract metadata
 	fmt.Println("\n→ Extracting metadata...")
-	registry := metadata.NewExtractorRegistry()
-	registry.RegisterDefaults()
+	registry := newExtractorRegistry(os.Stderr)
 	
 	extractedMeta, err := registry.Extract(filePath)
 	if err != nil {
@@ -15,16 +15,21 @@
 package cli
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
 	"github.com/scttfrdmn/cicada/internal/metadata"
+	"github.com/scttfrdmn/cicada/internal/sync"
 )
 
 // NewMetadataCmd creates the metadata command.
@@ -41,9 +46,14 @@ for microscopy, sequencing, mass spec, and other scientific data formats.`,
 	// Add subcommands
 	cmd.AddCommand(newMetadataExtractCmd())
 	cmd.AddCommand(newMetadataShowCmd())
+	cmd.AddCommand(newMetadataHistoryCmd())
+	cmd.AddCommand(newMetadataEditCmd())
+	cmd.AddCommand(newMetadataEnrichCmd())
 	cmd.AddCommand(newMetadataValidateCmd())
 	cmd.AddCommand(newMetadataListCmd())
 	cmd.AddCommand(newMetadataPresetCmd())
+	cmd.AddCommand(newMetadataSchemaCmd())
+	cmd.AddCommand(newMetadataOntologyCmd())
 
 	return cmd
 }
@@ -54,15 +64,30 @@ func newMetadataExtractCmd() *cobra.Command {
 		outputFormat string
 		outputFile   string
 		extractorName string
+		explain      bool
+		save         bool
+		sampleReads  int
+		batch        batchExtractOptions
 	)
 
 	cmd := &cobra.Command{
-		Use:   "extract <path>",
-		Short: "Extract metadata from a file",
-		Long: `Extract metadata from a scientific instrument file.
+		Use:   "extract <path>...",
+		Short: "Extract metadata from files",
+		Long: `Extract metadata from scientific instrument files.
 
 Automatically detects the file format and uses the appropriate extractor.
-Outputs metadata in JSON, YAML, or table format.
+A single file is output in JSON, YAML, or table format.
+
+Directories, glob patterns and multiple paths are processed as a batch.
+Directories are walked recursively, skipping hidden entries. Files are
+extracted in parallel, and per-file errors are reported at the end instead
+of stopping the run. Batch output is JSON Lines (one object per file), a CSV
+summary, or a <file>.extract.json sidecar next to each file. Files whose
+checksum matches ~/.cicada/cache/extract.json are served from the cache.
+
+With --save, the extracted metadata is also recorded in the metadata store:
+.cicada/<file>.metadata.json next to a local file, or <key>.metadata.json
+for an S3 store. Use 'metadata show' and 'metadata history' to read it.
 
 Examples:
   # Extract metadata and display as JSON
@@ -75,24 +100,71 @@ Examples:
   cicada metadata extract data/image.czi --format yaml
 
   # Force a specific extractor
-  cicada metadata extract data/image.czi --extractor zeiss_czi`,
-		Args: cobra.ExactArgs(1),
+  cicada metadata extract data/image.czi --extractor zeiss_czi
+
+  # Show how each extractor scored the file (printed to stderr)
+  cicada metadata extract data/unnamed_file --explain
+
+  # Read a Zarr store on S3 in place (only metadata documents are fetched)
+  cicada metadata extract s3://bucket/plate.ome.zarr
+
+  # Extract a whole run directory to JSON Lines with 8 workers
+  cicada metadata extract data/run42 --workers 8 --output run42.jsonl
+
+  # Only CZI files, skipping the scratch directory, as a CSV summary
+  cicada metadata extract data --include '*.czi' --exclude 'scratch/**' --format csv
+
+  # Write a sidecar next to every FASTQ file matched by a glob
+  cicada metadata extract 'data/*/*.fastq.gz' --format sidecar
+
+  # Record the metadata of a run directory in the metadata store
+  cicada metadata extract data/run42 --save
+
+  # Estimate mapping, duplicate and pairing rates from 100000 alignments
+  cicada metadata extract data/sample.bam --sample-reads 100000`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			path := args[0]
 
+			// Create registry and register default extractors
+			registry := newExtractorRegistry(cmd.ErrOrStderr())
+			if sampleReads < 0 {
+				return fmt.Errorf("--sample-reads must not be negative")
+			}
+			registry.SetSampleReads(sampleReads)
+
+			// Directories, globs and multiple paths are extracted as a batch
+			if len(args) > 1 || isBatchInput(registry, path) {
+				if extractorName != "" || explain {
+					return fmt.Errorf("--extractor and --explain apply to a single file")
+				}
+				batch.format = outputFormat
+				if !cmd.Flags().Changed("format") {
+					batch.format = "jsonl"
+				}
+				batch.output = outputFile
+				batch.save = save
+				// Cached results may have been extracted without sampling
+				batch.noCache = batch.noCache || sampleReads > 0
+				return runBatchExtract(cmd, registry, args, batch)
+			}
+
 			// Check if file exists
-			if _, err := os.Stat(path); os.IsNotExist(err) {
+			if _, err := os.Stat(path); os.IsNotExist(err) && !strings.HasPrefix(path, "s3://") {
 				return fmt.Errorf("file not found: %s", path)
 			}
 
-			// Create registry and register default extractors
-			registry := metadata.NewExtractorRegistry()
-			registry.RegisterDefaults()
-
 			var result map[string]interface{}
 			var err error
 
-			if extractorName != "" {
+			if explain && !strings.HasPrefix(path, "s3://") {
+				explainDetection(cmd.ErrOrStderr(), path, registry.Detect(path))
+			}
+
+			if strings.HasPrefix(path, "s3://") {
+				// Remote stores are read through the sync backend
+				result, err = extractS3Zarr(context.Background(), path)
+			} else if extractorName != "" {
 				// Use specific extractor
 				extractor := registry.FindExtractor(filepath.Base(path))
 				if extractor == nil || extractor.Name() != extractorName {
@@ -108,6 +180,20 @@ Examples:
 				return fmt.Errorf("extraction failed: %w", err)
 			}
 
+			if save {
+				ctx := context.Background()
+				store, key, closeStore, err := openMetadataStore(ctx, path)
+				if err != nil {
+					return err
+				}
+				record, err := storeMetadata(ctx, store, key, result, nil, "", nil)
+				closeStore()
+				if err != nil {
+					return fmt.Errorf("save metadata: %w", err)
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Metadata saved to %s (version %d)\n", store.RecordPath(key), record.Version())
+			}
+
 			// Format output
 			var output []byte
 			switch strings.ToLower(outputFormat) {
@@ -139,22 +225,263 @@ Examples:
 		},
 	}
 
-	cmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format (json, yaml, table)")
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", "json", "Output format (json, yaml, table; batch: jsonl, csv, sidecar)")
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
 	cmd.Flags().StringVar(&extractorName, "extractor", "", "Force specific extractor")
+	cmd.Flags().BoolVar(&explain, "explain", false, "Explain which extractor was chosen and why")
+	cmd.Flags().IntVarP(&batch.workers, "workers", "j", 0, "Files to extract in parallel (default: number of CPUs)")
+	cmd.Flags().StringSliceVar(&batch.include, "include", nil, "Only extract files matching these patterns")
+	cmd.Flags().StringSliceVar(&batch.exclude, "exclude", nil, "Skip files and directories matching these patterns")
+	cmd.Flags().StringVar(&batch.cachePath, "cache", "", "Extract cache file (default: ~/.cicada/cache/extract.json)")
+	cmd.Flags().BoolVar(&batch.noCache, "no-cache", false, "Extract every file, ignoring the cache")
+	cmd.Flags().BoolVar(&save, "save", false, "Record the metadata in the metadata store")
+	cmd.Flags().IntVar(&sampleReads, "sample-reads", 0, "Sample up to this many alignment records of SAM, BAM and CRAM files (default: header only)")
 
 	return cmd
 }
 
+// explainDetection prints the confidence and reason for every extractor
+// that claimed the file, best first.
+func explainDetection(w io.Writer, path string, detections []metadata.Detection) {
+	fmt.Fprintf(w, "Extractor selection for %s:\n", path)
+	for _, d := range detections {
+		fmt.Fprintf(w, "  %s\n", d)
+	}
+	if len(detections) > 0 {
+		fmt.Fprintf(w, "Selected: %s\n", detections[0].Name)
+	}
+}
+
+// newExtractorRegistry returns a registry with the built-in extractors and
+// any plugins in ~/.cicada/extractors. Plugin problems, such as plugins
+// that fail to load, are reported to w.
+func newExtractorRegistry(w io.Writer) *metadata.ExtractorRegistry {
+	registry := metadata.NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	dir, err := metadata.DefaultPluginDir()
+	if err != nil {
+		return registry
+	}
+	if err := registry.RegisterPlugins(dir, metadata.DefaultPluginLimits()); err != nil {
+		fmt.Fprintf(w, "Warning: extractor plugins: %v\n", err)
+	}
+	return registry
+}
+
+// batchExtractOptions holds the batch flags of metadata extract.
+type batchExtractOptions struct {
+	format    string
+	output    string
+	workers   int
+	include   []string
+	exclude   []string
+	cachePath string
+	noCache   bool
+	save      bool
+}
+
+// isBatchInput reports whether a single extract argument names more than
+// one file: a glob pattern, or a directory no extractor handles as a whole.
+func isBatchInput(registry *metadata.ExtractorRegistry, path string) bool {
+	if strings.HasPrefix(path, "s3://") {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return strings.ContainsAny(path, "*?[")
+	}
+	return info.IsDir() && !registry.HandlesDirectory(path)
+}
+
+// runBatchExtract extracts every file under the inputs and writes the
+// results in the requested batch format.
+func runBatchExtract(cmd *cobra.Command, registry *metadata.ExtractorRegistry, inputs []string, opts batchExtractOptions) error {
+	format := strings.ToLower(opts.format)
+	switch format {
+	case "jsonl", "csv":
+	case "sidecar":
+		if opts.output != "" {
+			return fmt.Errorf("--output cannot be used with sidecar output")
+		}
+	default:
+		return fmt.Errorf("unsupported batch format: %s (use jsonl, csv, or sidecar)", opts.format)
+	}
+
+	paths, err := registry.BatchInputs(inputs, opts.include, opts.exclude)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no files matched %s", strings.Join(inputs, " "))
+	}
+
+	var cache *metadata.ExtractCache
+	if !opts.noCache {
+		cachePath := opts.cachePath
+		if cachePath == "" {
+			if cachePath, err = metadata.DefaultExtractCachePath(); err != nil {
+				return err
+			}
+		}
+		if cache, err = metadata.LoadExtractCache(cachePath); err != nil {
+			return err
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			return fmt.Errorf("create output file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+
+	var csvOut *csv.Writer
+	if format == "csv" {
+		csvOut = csv.NewWriter(out)
+		if err := csvOut.Write(batchCSVHeader); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	encoder := json.NewEncoder(out)
+	store := metadata.NewLocalMetadataStore()
+
+	var failures []metadata.BatchResult
+	var writeErr error
+	summary := registry.ExtractBatch(context.Background(), paths, metadata.BatchOptions{
+		Workers: opts.workers,
+		Cache:   cache,
+		ResultFunc: func(result metadata.BatchResult) {
+			if result.Error != "" {
+				failures = append(failures, result)
+			}
+			var err error
+			switch format {
+			case "jsonl":
+				err = encoder.Encode(result)
+			case "csv":
+				err = csvOut.Write(batchCSVRow(result))
+			case "sidecar":
+				if result.Error == "" {
+					err = writeSidecar(result)
+				}
+			}
+			if err == nil && opts.save && result.Error == "" {
+				_, err = storeMetadata(context.Background(), store, result.Path, result.Metadata, nil, "", nil)
+			}
+			if err != nil && writeErr == nil {
+				writeErr = fmt.Errorf("write output: %w", err)
+			}
+		},
+	})
+	if csvOut != nil {
+		csvOut.Flush()
+		if err := csvOut.Error(); err != nil && writeErr == nil {
+			writeErr = fmt.Errorf("write output: %w", err)
+		}
+	}
+
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+		}
+	}
+
+	stderr := cmd.ErrOrStderr()
+	fmt.Fprintf(stderr, "Processed %d files: %d extracted, %d from cache, %d failed\n",
+		summary.Total, summary.Extracted, summary.Cached, summary.Failed)
+	for _, failure := range failures {
+		fmt.Fprintf(stderr, "  %s: %s\n", failure.Path, failure.Error)
+	}
+
+	if writeErr != nil {
+		return writeErr
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d files failed", summary.Failed, summary.Total)
+	}
+	return nil
+}
+
+// batchCSVHeader is the header row of the CSV batch summary.
+var batchCSVHeader = []string{"path", "status", "format", "instrument_type", "file_size", "fields", "error"}
+
+// batchCSVRow summarises one batch result as a CSV row.
+func batchCSVRow(result metadata.BatchResult) []string {
+	status := "extracted"
+	switch {
+	case result.Error != "":
+		status = "failed"
+	case result.Cached:
+		status = "cached"
+	}
+	field := func(key string) string {
+		switch v := result.Metadata[key].(type) {
+		case nil:
+			return ""
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Sprintf("%v", v)
+		}
+	}
+	fields := ""
+	if result.Metadata != nil {
+		fields = strconv.Itoa(len(result.Metadata))
+	}
+	return []string{result.Path, status, field("format"), field("instrument_type"), field("file_size"), fields, result.Error}
+}
+
+// writeSidecar writes a result's metadata to <path>.extract.json.
+func writeSidecar(result metadata.BatchResult) error {
+	data, err := json.MarshalIndent(result.Metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(strings.TrimRight(result.Path, "/")+metadata.SidecarSuffix, append(data, '\n'), 0644)
+}
+
+// extractS3Zarr extracts metadata from a Zarr store on S3 without
+// downloading its chunks.
+func extractS3Zarr(ctx context.Context, uri string) (map[string]interface{}, error) {
+	bucket, key, err := sync.ParseS3URI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	extractor := &metadata.ZarrExtractor{}
+	if !extractor.CanHandle(key) {
+		return nil, fmt.Errorf("only Zarr stores can be extracted directly from S3: %s", uri)
+	}
+
+	backend, err := sync.NewS3Backend(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("create S3 backend: %w", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	return extractor.ExtractFromStore(ctx, backend, key, uri)
+}
+
 // newMetadataShowCmd creates the metadata show subcommand.
 func newMetadataShowCmd() *cobra.Command {
-	var format string
+	var (
+		format  string
+		extract bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "show <path>",
 		Short: "Show metadata in human-readable format",
 		Long: `Display metadata from a file in a human-readable format.
 
+Metadata recorded in the metadata store (see 'metadata history') is shown
+with its enrichment applied. Files with no stored metadata, or any file with
+--extract, are extracted instead.
+
 Similar to 'extract' but optimized for readability with colored output
 and formatted tables.
 
@@ -163,29 +490,65 @@ Examples:
   cicada metadata show data/image.czi
 
   # Show as JSON
-  cicada metadata show data/image.czi --format json`,
+  cicada metadata show data/image.czi --format json
+
+  # Show the stored metadata of an S3 object
+  cicada metadata show s3://bucket/run42/image.czi
+
+  # Ignore the store and extract from the file
+  cicada metadata show data/image.czi --extract`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			path := args[0]
+			ctx := context.Background()
 
-			// Check if file exists
-			if _, err := os.Stat(path); os.IsNotExist(err) {
-				return fmt.Errorf("file not found: %s", path)
+			var result map[string]interface{}
+			var record *metadata.MetadataRecord
+			if !extract {
+				store, key, closeStore, err := openMetadataStore(ctx, path)
+				if err != nil {
+					return err
+				}
+				record, err = store.Load(ctx, key)
+				closeStore()
+				if err != nil {
+					return err
+				}
+				if record.Version() > 0 {
+					result = record.Metadata.Fields
+				}
 			}
 
-			// Create registry and extract metadata
-			registry := metadata.NewExtractorRegistry()
-			registry.RegisterDefaults()
+			if result == nil {
+				var err error
+				if strings.HasPrefix(path, "s3://") {
+					result, err = extractS3Zarr(ctx, path)
+				} else {
+					// Check if file exists
+					if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+						return fmt.Errorf("file not found: %s", path)
+					}
 
-			result, err := registry.Extract(path)
-			if err != nil {
-				return fmt.Errorf("extraction failed: %w", err)
+					// Create registry and extract metadata
+					registry := newExtractorRegistry(cmd.ErrOrStderr())
+					result, err = registry.Extract(path)
+				}
+				if err != nil {
+					return fmt.Errorf("extraction failed: %w", err)
+				}
+				record = nil
 			}
 
 			// Display based on format
 			switch strings.ToLower(format) {
 			case "table":
+				if record != nil && record.Version() > 0 {
+					fmt.Printf("Stored metadata, version %d (updated %s)\n\n", record.Version(), record.Metadata.UpdatedAt.Format("2006-01-02 15:04:05"))
+				}
 				fmt.Println(formatAsTable(result))
+				if record != nil && record.Validation != nil {
+					fmt.Printf("\nValidation: %s\n", record.Validation)
+				}
 			case "json":
 				output, _ := json.MarshalIndent(result, "", "  ")
 				fmt.Println(string(output))
@@ -201,13 +564,17 @@ Examples:
 	}
 
 	cmd.Flags().StringVarP(&format, "format", "f", "table", "Output format (table, json, yaml)")
+	cmd.Flags().BoolVar(&extract, "extract", false, "Extract from the file instead of reading the metadata store")
 
 	return cmd
 }
 
 // newMetadataValidateCmd creates the metadata validate subcommand.
 func newMetadataValidateCmd() *cobra.Command {
-	var presetID string
+	var (
+		presetID string
+		save     bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "validate <path>",
@@ -225,6 +592,10 @@ With --preset flag:
   - Checks required and optional fields
   - Provides quality score (0-100)
 
+Enrichment recorded in the metadata store is applied before validating.
+With --save, the extracted metadata and the validation result are recorded
+in the store (see 'metadata history').
+
 Examples:
   # Validate a single file
   cicada metadata validate data/image.czi
@@ -236,11 +607,13 @@ Examples:
   cicada metadata validate data/image.czi --preset zeiss-lsm-880
 
   # Validate Illumina FASTQ
-  cicada metadata validate data/sample_R1.fastq.gz --preset illumina-novaseq`,
+  cicada metadata validate data/sample_R1.fastq.gz --preset illumina-novaseq
+
+  # Validate and record the result
+  cicada metadata validate data/*.czi --preset zeiss-lsm-880 --save`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			extractorRegistry := metadata.NewExtractorRegistry()
-			extractorRegistry.RegisterDefaults()
+			extractorRegistry := newExtractorRegistry(cmd.ErrOrStderr())
 
 			var presetRegistry *metadata.PresetRegistry
 			var preset *metadata.InstrumentPreset
@@ -265,16 +638,23 @@ Examples:
 				}
 
 				// Try to extract metadata
-				result, err := extractorRegistry.Extract(path)
+				extracted, err := extractorRegistry.Extract(path)
 				if err != nil {
 					fmt.Printf("❌ %s: %v\n", path, err)
 					hasErrors = true
 					continue
 				}
+				result := withEnrichment(extracted, storedEnrichment(path))
 
 				// Validate against preset if specified
 				if preset != nil {
 					validation := preset.Validate(result)
+					if save {
+						summary := metadata.SummarizePresetValidation(preset.ID, validation)
+						if _, err := storeMetadata(context.Background(), metadata.NewLocalMetadataStore(), path, extracted, nil, "", &summary); err != nil {
+							return fmt.Errorf("save metadata: %w", err)
+						}
+					}
 					if !validation.IsValid {
 						fmt.Printf("❌ %s: validation failed\n", path)
 						for _, err := range validation.Errors {
@@ -312,6 +692,11 @@ Examples:
 					} else {
 						fmt.Printf("✓ %s: valid (%s)\n", path, result["format"])
 					}
+					if save {
+						if _, err := storeMetadata(context.Background(), metadata.NewLocalMetadataStore(), path, extracted, nil, "", nil); err != nil {
+							return fmt.Errorf("save metadata: %w", err)
+						}
+					}
 				}
 			}
 
@@ -324,6 +709,7 @@ Examples:
 	}
 
 	cmd.Flags().StringVarP(&presetID, "preset", "p", "", "Validate against instrument preset")
+	cmd.Flags().BoolVar(&save, "save", false, "Record the metadata and validation result in the metadata store")
 
 	return cmd
 }
@@ -336,12 +722,12 @@ func newMetadataListCmd() *cobra.Command {
 		Long: `List all registered metadata extractors and supported formats.
 
 Shows which file formats are supported and which extractor handles each format.
+Plugin extractors installed in ~/.cicada/extractors are listed first.
 
 Example:
   cicada metadata list`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			registry := metadata.NewExtractorRegistry()
-			registry.RegisterDefaults()
+			registry := newExtractorRegistry(cmd.ErrOrStderr())
 
 			extractors := registry.ListExtractors()
 
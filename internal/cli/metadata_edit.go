@@ -0,0 +1,673 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scttfrdmn/cicada/internal/doi"
+	"github.com/scttfrdmn/cicada/internal/metadata"
+)
+
+// dataCiteFields are the enrichment fields 'doi prepare --enrich' maps to
+// the DataCite record.
+var dataCiteFields = []metadata.FieldRequirement{
+	{Name: "title", Type: "string", Description: "Dataset title"},
+	{Name: "description", Type: "string", Description: "Dataset description"},
+	{Name: "authors", Type: "array", Description: "Authors separated by ';', each with an optional ORCID: Jane Doe (0000-0002-1825-0097)"},
+	{Name: "keywords", Type: "array", Description: "Keywords separated by ','"},
+	{Name: "publisher", Type: "string", Description: "Publisher"},
+	{Name: "license", Type: "string", Description: "License, such as CC-BY-4.0"},
+	{Name: "url", Type: "string", Description: "Landing page URL", Pattern: `^https?://\S+$`},
+}
+
+// orcidPattern matches a bare ORCID iD.
+var orcidPattern = regexp.MustCompile(`^\d{4}-\d{4}-\d{4}-\d{3}[\dX]$`)
+
+// fieldNamePattern matches the name of a custom enrichment field.
+var fieldNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+const editHelp = `Enter a field's number to edit it; an empty value keeps the current one
+and "-" clears the enrichment, restoring any extracted value. Set any field,
+including new ones, with <name>=<value>. "s" saves the enrichment file, "q"
+quits without saving.`
+
+// newMetadataEditCmd creates the metadata edit subcommand.
+func newMetadataEditCmd() *cobra.Command {
+	var (
+		presetID string
+		output   string
+		save     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "edit <file>",
+		Short: "Review and enrich a file's metadata interactively",
+		Long: `Review a file's metadata and edit its enrichment interactively.
+
+The editor lists the extracted metadata, which is read-only, and the
+enrichment fields: the preset's required (*) and optional fields and the
+DataCite fields (title, description, authors, keywords, publisher, license,
+url). Values are checked against the preset's allowed values, patterns and
+ranges as they are entered. After every change the preset validation and
+the DOI readiness check run again, and their quality scores are shown.
+
+Without --preset, the generic preset for the file's instrument type is
+used, if there is one.
+
+Saving writes the enrichment to <file>.enrichment.yaml, or the --output
+file, which 'cicada doi prepare --enrich' reads. An existing enrichment
+file is loaded for editing. With --save, the enrichment and the preset
+validation result are also recorded in the metadata store (see 'metadata
+history').
+
+Examples:
+  # Edit a file's enrichment with the generic sequencing preset
+  cicada metadata edit data/sample_R1.fastq
+
+  # Edit against an instrument preset and record the result
+  cicada metadata edit data/image.czi --preset zeiss-lsm-880 --save
+
+  # Prepare the DOI with the saved enrichment
+  cicada doi prepare data/image.czi --enrich data/image.czi.enrichment.yaml`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				return fmt.Errorf("file not found: %s", path)
+			}
+
+			extracted, err := newExtractorRegistry(cmd.ErrOrStderr()).Extract(path)
+			if err != nil {
+				return fmt.Errorf("extraction failed: %w", err)
+			}
+			fields := metadata.NormalizeFields(extracted)
+
+			preset, err := editPreset(presetID, fields)
+			if err != nil {
+				return err
+			}
+
+			if output == "" {
+				output = path + metadata.EnrichmentSuffix
+			}
+			enrichment, err := loadEnrichmentFile(output)
+			if os.IsNotExist(err) {
+				enrichment = storedEnrichment(path)
+			} else if err != nil {
+				return err
+			}
+
+			workflow := doi.NewDOIWorkflow(&doi.WorkflowConfig{
+				License:            "CC-BY-4.0",
+				MinQualityScore:    60.0,
+				RequireRealAuthors: true,
+				RequireDescription: true,
+				Ontologies:         loadOntologyIndex(cmd.ErrOrStderr()),
+			}, doi.NewProviderRegistry())
+
+			editor := newMetadataEditor(path, output, fields, enrichment, preset, workflow)
+			out := cmd.OutOrStdout()
+			saved, err := editor.run(cmd.InOrStdin(), out, isTerminal(out))
+			if err != nil {
+				return fmt.Errorf("read input: %w", err)
+			}
+			if !saved {
+				return nil
+			}
+
+			if err := writeEnrichmentFile(output, editor.enrichment); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "✓ Enrichment saved to %s\n", output)
+			fmt.Fprintf(out, "  Prepare the DOI with: cicada doi prepare %s --enrich %s\n", path, output)
+
+			if save {
+				var summary *metadata.ValidationSummary
+				if preset != nil {
+					s := metadata.SummarizePresetValidation(preset.ID, editor.presetResult)
+					summary = &s
+				}
+				changes := enrichmentChanges(storedEnrichment(path), editor.enrichment)
+				record, err := storeMetadata(context.Background(), metadata.NewLocalMetadataStore(), path, extracted, changes, "metadata edit", summary)
+				if err != nil {
+					return fmt.Errorf("save metadata: %w", err)
+				}
+				fmt.Fprintf(out, "✓ Metadata saved to %s (version %d)\n", metadata.NewLocalMetadataStore().RecordPath(path), record.Version())
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&presetID, "preset", "p", "", "Instrument preset whose fields to edit")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Enrichment file (default <file>.enrichment.yaml)")
+	cmd.Flags().BoolVar(&save, "save", false, "Also record the enrichment and validation result in the metadata store")
+
+	return cmd
+}
+
+// editPreset returns the preset named id or, without an id, the generic
+// preset for the file's instrument type. It returns nil if there is none.
+func editPreset(id string, fields map[string]interface{}) (*metadata.InstrumentPreset, error) {
+	registry := metadata.NewPresetRegistry()
+	registry.RegisterDefaults()
+	if id != "" {
+		preset, err := registry.GetPreset(id)
+		if err != nil {
+			return nil, fmt.Errorf("preset not found: %s", id)
+		}
+		return preset, nil
+	}
+	if instrumentType, ok := fields["instrument_type"].(string); ok {
+		if preset, err := registry.GetPreset("generic-" + instrumentType); err == nil {
+			return preset, nil
+		}
+	}
+	return nil, nil
+}
+
+// editField is a field the editor can change.
+type editField struct {
+	metadata.FieldRequirement
+	required bool
+	group    string
+}
+
+// metadataEditor holds the state of an interactive editing session.
+type metadataEditor struct {
+	path       string
+	output     string
+	extracted  map[string]interface{}
+	enrichment map[string]interface{}
+	preset     *metadata.InstrumentPreset
+	workflow   *doi.DOIWorkflow
+	fields     []editField
+
+	presetResult   *metadata.PresetValidationResult
+	readiness      *doi.ReadinessResult
+	presetScore    float64 // Scores before the last change
+	readinessScore float64
+	dirty          bool
+	status         string
+}
+
+// newMetadataEditor creates an editor for the enrichment of path, saved to
+// output, and evaluates it.
+func newMetadataEditor(path, output string, extracted, enrichment map[string]interface{}, preset *metadata.InstrumentPreset, workflow *doi.DOIWorkflow) *metadataEditor {
+	e := &metadataEditor{
+		path:       path,
+		output:     output,
+		extracted:  extracted,
+		enrichment: make(map[string]interface{}, len(enrichment)),
+		preset:     preset,
+		workflow:   workflow,
+	}
+	for key, value := range enrichment {
+		e.enrichment[key] = value
+	}
+	e.updateFields()
+	e.evaluate()
+	e.presetScore, e.readinessScore = e.scores()
+	return e
+}
+
+// updateFields lists the preset fields, the DataCite fields and any other
+// enrichment fields, in that order.
+func (e *metadataEditor) updateFields() {
+	e.fields = nil
+	seen := make(map[string]bool)
+	add := func(req metadata.FieldRequirement, required bool, group string) {
+		if !seen[req.Name] {
+			seen[req.Name] = true
+			e.fields = append(e.fields, editField{FieldRequirement: req, required: required, group: group})
+		}
+	}
+	if e.preset != nil {
+		for _, req := range e.preset.RequiredFields {
+			add(req, true, e.preset.Name)
+		}
+		for _, req := range e.preset.OptionalFields {
+			add(req, false, e.preset.Name)
+		}
+	}
+	for _, req := range dataCiteFields {
+		add(req, false, "DataCite")
+	}
+	for _, name := range sortedKeys(e.enrichment) {
+		add(metadata.FieldRequirement{Name: name, Type: "string"}, false, "Other")
+	}
+}
+
+// evaluate re-runs the preset validation and the DOI readiness check.
+func (e *metadataEditor) evaluate() {
+	if e.preset != nil {
+		e.presetResult = e.preset.Validate(withEnrichment(e.extracted, e.enrichment))
+	}
+	result, err := e.workflow.Prepare(&doi.PrepareRequest{
+		FilePath:   e.path,
+		Metadata:   e.extracted,
+		Enrichment: e.enrichment,
+	})
+	if err != nil {
+		e.readiness = nil
+		e.status = fmt.Sprintf("❌ DOI readiness check failed: %v", err)
+		return
+	}
+	e.readiness = result.Validation
+}
+
+// scores returns the current preset and DOI readiness scores.
+func (e *metadataEditor) scores() (float64, float64) {
+	var presetScore, readinessScore float64
+	if e.presetResult != nil {
+		presetScore = e.presetResult.QualityScore()
+	}
+	if e.readiness != nil {
+		readinessScore = e.readiness.Score
+	}
+	return presetScore, readinessScore
+}
+
+// field returns the editable field called name.
+func (e *metadataEditor) field(name string) (editField, bool) {
+	for _, field := range e.fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return editField{}, false
+}
+
+// set parses input as a value of the named field and sets its enrichment;
+// "-" clears it. Names not among the fields add a string field.
+func (e *metadataEditor) set(name, input string) error {
+	input = strings.TrimSpace(input)
+	if !fieldNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid field name %q", name)
+	}
+	if input == "-" {
+		if _, ok := e.enrichment[name]; !ok {
+			return fmt.Errorf("%s has no enrichment to clear", name)
+		}
+		delete(e.enrichment, name)
+		e.changed(fmt.Sprintf("Cleared %s", name))
+		return nil
+	}
+
+	field, ok := e.field(name)
+	if !ok {
+		field = editField{FieldRequirement: metadata.FieldRequirement{Name: name, Type: "string"}}
+	}
+	var value interface{}
+	var err error
+	if name == "authors" {
+		value, err = parseAuthors(input)
+	} else {
+		value, err = field.ParseValue(input)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	e.enrichment[name] = value
+	e.changed(fmt.Sprintf("Set %s = %s", name, formatEditValue(value)))
+	return nil
+}
+
+// changed re-evaluates the metadata after a change and reports it, with
+// the change in the scores.
+func (e *metadataEditor) changed(message string) {
+	e.dirty = true
+	e.presetScore, e.readinessScore = e.scores()
+	e.status = "✓ " + message
+	e.updateFields()
+	e.evaluate()
+}
+
+// render draws the editor screen.
+func (e *metadataEditor) render(w io.Writer) {
+	fmt.Fprintf(w, "Metadata editor: %s\n", filepath.Base(e.path))
+	if e.preset != nil {
+		fmt.Fprintf(w, "Preset: %s (%s)\n", e.preset.ID, e.preset.Name)
+	} else {
+		fmt.Fprintf(w, "Preset: none\n")
+	}
+	fmt.Fprintf(w, "Enrichment file: %s\n", e.output)
+
+	fmt.Fprintf(w, "\nExtracted metadata (read-only):\n")
+	for _, key := range sortedKeys(e.extracted) {
+		fmt.Fprintf(w, "  %-24s %s\n", key, truncateEditValue(formatEditValue(e.extracted[key])))
+	}
+
+	fmt.Fprintf(w, "\nEnrichment fields (* required):\n")
+	group := ""
+	for i, field := range e.fields {
+		if field.group != group {
+			group = field.group
+			fmt.Fprintf(w, "  %s\n", group)
+		}
+		marker := " "
+		if field.required {
+			marker = "*"
+		}
+		value := "-"
+		if v, ok := e.enrichment[field.Name]; ok {
+			value = formatEditValue(v)
+		} else if v, ok := e.extracted[field.Name]; ok {
+			value = formatEditValue(v) + " (extracted)"
+		}
+		fmt.Fprintf(w, "  %3d %s %-22s %s\n", i+1, marker, field.Name, truncateEditValue(value))
+	}
+
+	fmt.Fprintf(w, "\nQuality:\n")
+	presetScore, readinessScore := e.scores()
+	var errs, warnings []string
+	if e.presetResult != nil {
+		status := "valid"
+		if !e.presetResult.IsValid {
+			status = "invalid"
+		}
+		fmt.Fprintf(w, "  Preset %s: %.1f/100%s, %s\n", e.preset.ID, presetScore, scoreDelta(presetScore, e.presetScore), status)
+		errs = append(errs, e.presetResult.Errors...)
+		warnings = append(warnings, e.presetResult.Warnings...)
+	}
+	if e.readiness != nil {
+		status := "ready"
+		if !e.readiness.IsReady {
+			status = "not ready"
+		}
+		fmt.Fprintf(w, "  DOI readiness: %.1f/100%s (%s), %s\n", readinessScore, scoreDelta(readinessScore, e.readinessScore), doi.GetQualityLevel(readinessScore), status)
+		errs = append(errs, e.readiness.Errors...)
+		warnings = append(warnings, e.readiness.Warnings...)
+	}
+	for _, msg := range errs {
+		fmt.Fprintf(w, "  ❌ %s\n", msg)
+	}
+	for _, msg := range warnings {
+		fmt.Fprintf(w, "  Warning: %s\n", msg)
+	}
+
+	if e.status != "" {
+		fmt.Fprintf(w, "\n%s\n", e.status)
+	}
+	fmt.Fprintf(w, "\nCommands: <number> edit, <name>=<value> set, s save, q quit, ? help\n")
+}
+
+// describe prints the requirements of a field before it is edited.
+func (e *metadataEditor) describe(w io.Writer, field editField) {
+	fmt.Fprintf(w, "\n%s", field.Name)
+	if field.Description != "" {
+		fmt.Fprintf(w, ": %s", field.Description)
+	}
+	fmt.Fprintln(w)
+	if field.Type != "" && field.Type != "string" && field.Name != "authors" {
+		fmt.Fprintf(w, "  Type: %s\n", field.Type)
+	}
+	if len(field.Enum) > 0 {
+		fmt.Fprintf(w, "  One of: %s\n", strings.Join(field.Enum, ", "))
+	}
+	if field.Pattern != "" {
+		fmt.Fprintf(w, "  Pattern: %s\n", field.Pattern)
+	}
+	if field.MinValue != nil || field.MaxValue != nil {
+		bound := func(b *float64) string {
+			if b == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*b, 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "  Range: %s..%s\n", bound(field.MinValue), bound(field.MaxValue))
+	}
+	if field.Example != nil {
+		fmt.Fprintf(w, "  Example: %v\n", field.Example)
+	}
+	if v, ok := e.enrichment[field.Name]; ok {
+		fmt.Fprintf(w, "  Current: %s\n", formatEditValue(v))
+	} else if v, ok := e.extracted[field.Name]; ok {
+		fmt.Fprintf(w, "  Extracted: %s\n", formatEditValue(v))
+	}
+}
+
+// run reads commands from in until the user saves or quits, and reports
+// whether to save. Closing the input quits without saving.
+func (e *metadataEditor) run(in io.Reader, out io.Writer, clearScreen bool) (bool, error) {
+	scanner := bufio.NewScanner(in)
+	prompt := func(text string) (string, bool) {
+		fmt.Fprint(out, text)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return "", false
+		}
+		return strings.TrimSpace(scanner.Text()), true
+	}
+	closed := func() (bool, error) {
+		if e.dirty {
+			fmt.Fprintln(out, "Input closed, changes not saved")
+		}
+		return false, scanner.Err()
+	}
+
+	for {
+		if clearScreen {
+			fmt.Fprint(out, "\033[H\033[2J")
+		}
+		e.render(out)
+		line, ok := prompt("> ")
+		if !ok {
+			return closed()
+		}
+		e.status = ""
+
+		switch {
+		case line == "":
+
+		case line == "s" || line == "save":
+			if e.presetResult != nil && !e.presetResult.IsValid {
+				answer, ok := prompt("The metadata does not pass preset validation. Save anyway? [y/N] ")
+				if !ok {
+					return closed()
+				}
+				if !isYes(answer) {
+					e.status = "Not saved"
+					continue
+				}
+			}
+			return true, nil
+
+		case line == "q" || line == "quit":
+			if !e.dirty {
+				return false, nil
+			}
+			answer, ok := prompt("Discard unsaved changes? [y/N] ")
+			if !ok || isYes(answer) {
+				return false, scanner.Err()
+			}
+
+		case line == "?" || line == "help":
+			e.status = editHelp
+
+		case strings.Contains(line, "="):
+			name, value, _ := strings.Cut(line, "=")
+			if err := e.set(strings.TrimSpace(name), value); err != nil {
+				e.status = fmt.Sprintf("❌ %v", err)
+			}
+
+		default:
+			n, err := strconv.Atoi(line)
+			if err != nil || n < 1 || n > len(e.fields) {
+				e.status = fmt.Sprintf("❌ Unknown command: %s (? for help)", line)
+				continue
+			}
+			field := e.fields[n-1]
+			e.describe(out, field)
+			value, ok := prompt("New value (empty keeps, - clears): ")
+			if !ok {
+				return closed()
+			}
+			if value == "" {
+				continue
+			}
+			if err := e.set(field.Name, value); err != nil {
+				e.status = fmt.Sprintf("❌ %v", err)
+			}
+		}
+	}
+}
+
+// parseAuthors parses "Name (ORCID); Name" into the author list
+// 'doi prepare --enrich' reads.
+func parseAuthors(text string) (interface{}, error) {
+	var authors []interface{}
+	for _, entry := range strings.Split(text, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		author := map[string]interface{}{"name": entry}
+		if open := strings.LastIndex(entry, "("); open > 0 && strings.HasSuffix(entry, ")") {
+			orcid := strings.TrimSpace(entry[open+1 : len(entry)-1])
+			orcid = strings.TrimPrefix(orcid, "https://orcid.org/")
+			if !orcidPattern.MatchString(orcid) {
+				return nil, fmt.Errorf("invalid ORCID %q", orcid)
+			}
+			author["name"] = strings.TrimSpace(entry[:open])
+			author["orcid"] = orcid
+		}
+		authors = append(authors, author)
+	}
+	if len(authors) == 0 {
+		return nil, fmt.Errorf("expected at least one author")
+	}
+	return authors, nil
+}
+
+// formatEditValue formats a field value on one line.
+func formatEditValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		parts := make([]string, len(v))
+		sep := ", "
+		for i, item := range v {
+			if author, ok := item.(map[string]interface{}); ok {
+				sep = "; "
+				parts[i] = fmt.Sprint(author["name"])
+				if orcid, ok := author["orcid"]; ok {
+					parts[i] += fmt.Sprintf(" (%v)", orcid)
+				}
+				continue
+			}
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	case []string:
+		return strings.Join(v, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// truncateEditValue shortens a value to fit a line of the editor.
+func truncateEditValue(s string) string {
+	const max = 50
+	if r := []rune(s); len(r) > max {
+		return string(r[:max-3]) + "..."
+	}
+	return s
+}
+
+// scoreDelta formats the change from a previous score, if any.
+func scoreDelta(score, previous float64) string {
+	if score == previous {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.1f)", score-previous)
+}
+
+// isYes reports whether a prompt answer is yes.
+func isYes(answer string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// isTerminal reports whether w is a terminal, where the editor redraws
+// the screen.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// sortedKeys returns the keys of fields in order.
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadEnrichmentFile reads an enrichment file, YAML or JSON.
+func loadEnrichmentFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var enrichment map[string]interface{}
+	if err := yaml.Unmarshal(data, &enrichment); err != nil {
+		if err := json.Unmarshal(data, &enrichment); err != nil {
+			return nil, fmt.Errorf("parse enrichment file %s: %w", path, err)
+		}
+	}
+	return enrichment, nil
+}
+
+// writeEnrichmentFile writes enrichment as YAML.
+func writeEnrichmentFile(path string, enrichment map[string]interface{}) error {
+	data, err := yaml.Marshal(enrichment)
+	if err != nil {
+		return fmt.Errorf("marshal YAML: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write enrichment file: %w", err)
+	}
+	return nil
+}
+
+// enrichmentChanges returns the changes that turn the enrichment before
+// into after, with nil for removed fields.
+func enrichmentChanges(before, after map[string]interface{}) map[string]interface{} {
+	changes := changedFields(before, after)
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changes[key] = nil
+		}
+	}
+	return changes
+}
@@ -0,0 +1,213 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/scttfrdmn/cicada/internal/metadata"
+)
+
+// newMetadataEnrichCmd creates the metadata enrich subcommand.
+func newMetadataEnrichCmd() *cobra.Command {
+	var (
+		sheetPath string
+		matchSpec string
+		presetID  string
+		dryRun    bool
+		save      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "enrich <files or directories...>",
+		Short: "Enrich files from a sample sheet",
+		Long: `Add sample-level metadata, such as organism, treatment, sample ID or
+operator, from a CSV or TSV sample sheet or an Illumina SampleSheet to the
+files the rows describe.
+
+Each file is joined to a row with --match:
+  column={regex}  The regular expression's first group (or whole match)
+                  taken from the file name equals the column's value.
+  column          The column holds the file's name or path.
+
+Illumina SampleSheets (v1 and v2) are read natively and need no --match:
+FASTQ files named <sample>_S<n>_L<lane>_R<n>_001.fastq.gz are joined to
+Sample_ID, or Sample_Name, and to the row for their lane. The run's
+investigator, experiment name and date are added to every sample.
+
+Column names become snake_case field names (Sample_ID is sample_id). With
+--preset, values of the preset's fields are converted to the field's type
+and checked against its allowed values, patterns and ranges; files whose
+row has invalid values are not enriched.
+
+The fields are written to each file's enrichment file,
+<file>.enrichment.yaml, which 'cicada doi prepare --enrich' reads and
+'cicada metadata edit' opens; existing fields not in the sheet are kept.
+With --save, they are also recorded in the metadata store.
+
+Examples:
+  # Enrich FASTQ files from an Illumina SampleSheet
+  cicada metadata enrich run42/fastq --sheet run42/SampleSheet.csv
+
+  # Join on a sample ID taken from the file name
+  cicada metadata enrich data/*.czi --sheet samples.csv --match 'sample_id={^([A-Z]+\d+)_}'
+
+  # Join on a column holding file names, checking rows against a preset
+  cicada metadata enrich data --sheet samples.tsv --match file --preset zeiss-lsm-880
+
+  # Show what would be written
+  cicada metadata enrich data --sheet samples.csv --match file --dry-run`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sheet, err := metadata.ReadSampleSheet(sheetPath)
+			if err != nil {
+				return err
+			}
+
+			var match *metadata.SampleMatch
+			if matchSpec != "" {
+				match, err = metadata.ParseSampleMatch(matchSpec)
+				if err != nil {
+					return err
+				}
+			} else if match, _ = sheet.DefaultSampleMatch(); match == nil {
+				return fmt.Errorf("--match is required for %s sample sheets", sheet.Format)
+			}
+			if err := sheet.Check(match); err != nil {
+				return err
+			}
+
+			var preset *metadata.InstrumentPreset
+			if presetID != "" {
+				registry := metadata.NewPresetRegistry()
+				registry.RegisterDefaults()
+				preset, err = registry.GetPreset(presetID)
+				if err != nil {
+					return fmt.Errorf("preset not found: %s", presetID)
+				}
+			}
+
+			registry := newExtractorRegistry(cmd.ErrOrStderr())
+			paths, err := registry.BatchInputs(args, nil, nil)
+			if err != nil {
+				return err
+			}
+
+			sheetAbs, _ := filepath.Abs(sheetPath)
+			used := make(map[int]bool)
+			var enriched, unmatched, failed int
+			for _, path := range paths {
+				if abs, _ := filepath.Abs(path); abs == sheetAbs {
+					continue
+				}
+
+				row, ok, err := sheet.Match(path, match)
+				if err != nil {
+					fmt.Printf("❌ %v\n", err)
+					failed++
+					continue
+				}
+				if !ok {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: no sample matches %s\n", path)
+					unmatched++
+					continue
+				}
+				used[row.Line] = true
+
+				fields, errs := sheet.Enrichment(row, preset)
+				if len(errs) > 0 {
+					fmt.Printf("❌ %s: invalid sample\n", path)
+					for _, err := range errs {
+						fmt.Printf("     Error: %v\n", err)
+					}
+					failed++
+					continue
+				}
+
+				if !dryRun {
+					if err := writeSampleEnrichment(registry, path, fields, save); err != nil {
+						return err
+					}
+				}
+				verb := "Enriched"
+				if dryRun {
+					verb = "Would enrich"
+				}
+				fmt.Printf("✓ %s %s from line %d: %s\n", verb, path, row.Line, strings.Join(sortedKeys(fields), ", "))
+				enriched++
+			}
+
+			var unused int
+			for _, row := range sheet.Rows {
+				if !used[row.Line] {
+					unused++
+				}
+			}
+			fmt.Printf("\n%d files enriched, %d without a sample, %d failed; %d of %d samples matched no file\n",
+				enriched, unmatched, failed, unused, len(sheet.Rows))
+
+			if failed > 0 {
+				return fmt.Errorf("%d files could not be enriched", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&sheetPath, "sheet", "s", "", "Sample sheet (CSV, TSV or Illumina SampleSheet)")
+	cmd.Flags().StringVarP(&matchSpec, "match", "m", "", "Join files to rows: column={regex} on the file name, or a column of file names")
+	cmd.Flags().StringVarP(&presetID, "preset", "p", "", "Check rows against instrument preset")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be enriched without writing")
+	cmd.Flags().BoolVar(&save, "save", false, "Also record the enrichment in the metadata store")
+	_ = cmd.MarkFlagRequired("sheet")
+
+	return cmd
+}
+
+// writeSampleEnrichment merges fields into the enrichment file of path and,
+// with save, records them in the metadata store along with the file's
+// extracted metadata.
+func writeSampleEnrichment(registry *metadata.ExtractorRegistry, path string, fields map[string]interface{}, save bool) error {
+	file := path + metadata.EnrichmentSuffix
+	enrichment, err := loadEnrichmentFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if enrichment == nil {
+		enrichment = make(map[string]interface{}, len(fields))
+	}
+	for key, value := range fields {
+		enrichment[key] = value
+	}
+	if err := writeEnrichmentFile(file, enrichment); err != nil {
+		return err
+	}
+
+	if save {
+		extracted, err := registry.Extract(path)
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", path, err)
+		}
+		if _, err := storeMetadata(context.Background(), metadata.NewLocalMetadataStore(), path, extracted, fields, "sample sheet", nil); err != nil {
+			return fmt.Errorf("save metadata: %w", err)
+		}
+	}
+	return nil
+}
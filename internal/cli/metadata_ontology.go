@@ -0,0 +1,253 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scttfrdmn/cicada/internal/metadata"
+)
+
+// newMetadataOntologyCmd creates the metadata ontology command.
+func newMetadataOntologyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ontology",
+		Short: "Manage offline ontologies",
+		Long: `Manage the ontologies used to resolve organisms, tissues, cell types,
+instruments and imaging methods to ontology term IDs.
+
+Ontologies are read from OBO (.obo) and OWL RDF/XML (.owl, .rdf) files in:
+  1. .cicada/ontologies in the current directory (project)
+  2. ~/.cicada/ontologies (user)
+Their terms are added to small built-in subsets of NCBITaxon, UBERON, CL,
+EFO, FBbi and PSI-MS. Nothing is fetched from the network.
+
+Examples:
+  # List available ontologies
+  cicada metadata ontology list
+
+  # Import a subset exported from the OBO Foundry
+  cicada metadata ontology import ncbitaxon-subset.obo
+
+  # Look up a term
+  cicada metadata ontology lookup mouse`,
+	}
+
+	cmd.AddCommand(newMetadataOntologyListCmd())
+	cmd.AddCommand(newMetadataOntologyImportCmd())
+	cmd.AddCommand(newMetadataOntologyLookupCmd())
+
+	return cmd
+}
+
+// newMetadataOntologyListCmd creates the ontology list subcommand.
+func newMetadataOntologyListCmd() *cobra.Command {
+	var outputFormat string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List available ontologies",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			infos := loadOntologyIndex(cmd.ErrOrStderr()).Ontologies()
+
+			switch strings.ToLower(outputFormat) {
+			case "json":
+				output, err := json.MarshalIndent(infos, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal JSON: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "yaml":
+				output, err := yaml.Marshal(infos)
+				if err != nil {
+					return fmt.Errorf("marshal YAML: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "table", "":
+				fmt.Println("Available Ontologies:")
+				fmt.Println()
+
+				for _, info := range infos {
+					fmt.Printf("  %s (%d terms)\n", info.Prefix, info.Terms)
+					if info.Version != "" {
+						fmt.Printf("    Version: %s\n", info.Version)
+					}
+					fmt.Printf("    Source: %s\n", strings.Join(info.Sources, ", "))
+					fmt.Println()
+				}
+
+				fmt.Printf("Total: %d ontologies\n", len(infos))
+
+			default:
+				return fmt.Errorf("unsupported format: %s (use json, yaml, or table)", outputFormat)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", "table", "Output format (json, yaml, table)")
+
+	return cmd
+}
+
+// newMetadataOntologyImportCmd creates the ontology import subcommand.
+func newMetadataOntologyImportCmd() *cobra.Command {
+	var project bool
+
+	cmd := &cobra.Command{
+		Use:   "import <file...>",
+		Short: "Import or update ontology files",
+		Long: `Check ontology files and copy them into the user ontology directory
+(~/.cicada/ontologies), or the project directory with --project.
+
+Each file is stored under its ontology prefix, such as ncbitaxon.obo, so
+importing a newer release of an ontology replaces the previous one.
+
+Examples:
+  # Import an OBO subset
+  cicada metadata ontology import uberon-subset.obo
+
+  # Import an OWL file for this project only
+  cicada metadata ontology import cl-basic.owl --project`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dirs := metadata.DefaultOntologyDirs()
+			dir := dirs[len(dirs)-1]
+			if project {
+				dir = dirs[0]
+			}
+
+			var failed bool
+			for _, file := range args {
+				o, dest, err := metadata.ImportOntology(file, dir)
+				if err != nil {
+					fmt.Printf("❌ %s: %v\n", file, err)
+					failed = true
+					continue
+				}
+				version := ""
+				if o.Version != "" {
+					version = ", version " + o.Version
+				}
+				fmt.Printf("✓ Imported %s (%d terms%s) to %s\n", o.Prefix, len(o.Terms), version, dest)
+			}
+
+			if failed {
+				return fmt.Errorf("import failed for one or more files")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&project, "project", false, "Import into .cicada/ontologies in the current directory")
+
+	return cmd
+}
+
+// newMetadataOntologyLookupCmd creates the ontology lookup subcommand.
+func newMetadataOntologyLookupCmd() *cobra.Command {
+	var (
+		ontologies   []string
+		outputFormat string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "lookup <term>",
+		Short: "Resolve a label, synonym or CURIE to an ontology term",
+		Long: `Resolve a label, exact synonym, CURIE or OBO PURL to an ontology term.
+
+Examples:
+  # Resolve a common name
+  cicada metadata ontology lookup mouse
+
+  # Resolve within one ontology
+  cicada metadata ontology lookup "T cell" --ontology CL
+
+  # Show a term by CURIE as JSON
+  cicada metadata ontology lookup NCBITaxon:10090 --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index := loadOntologyIndex(cmd.ErrOrStderr())
+
+			term, err := index.Resolve(args[0], ontologies...)
+			if err != nil {
+				suggestions := index.Suggest(args[0], ontologies...)
+				if len(suggestions) > 0 {
+					labels := make([]string, len(suggestions))
+					for i, t := range suggestions {
+						labels[i] = fmt.Sprintf("%s (%s)", t.Label, t.ID)
+					}
+					return fmt.Errorf("%w; did you mean: %s", err, strings.Join(labels, ", "))
+				}
+				return err
+			}
+
+			switch strings.ToLower(outputFormat) {
+			case "json":
+				output, err := json.MarshalIndent(term, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal JSON: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "table", "":
+				fmt.Printf("%s  %s\n", term.ID, term.Label)
+				fmt.Printf("  URI: %s\n", term.URI())
+				if len(term.Synonyms) > 0 {
+					fmt.Printf("  Synonyms: %s\n", strings.Join(term.Synonyms, ", "))
+				}
+				if len(term.Parents) > 0 {
+					fmt.Printf("  Parents: %s\n", strings.Join(term.Parents, ", "))
+				}
+				if term.Obsolete {
+					fmt.Printf("  Obsolete")
+					if term.ReplacedBy != "" {
+						fmt.Printf(", replaced by %s", term.ReplacedBy)
+					}
+					fmt.Println()
+				}
+
+			default:
+				return fmt.Errorf("unsupported format: %s (use json or table)", outputFormat)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&ontologies, "ontology", "o", nil, "Only search these ontologies (e.g. NCBITaxon,UBERON)")
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", "table", "Output format (json, table)")
+
+	return cmd
+}
+
+// loadOntologyIndex loads the built-in and installed ontologies. Files that
+// fail to load are reported to w and skipped.
+func loadOntologyIndex(w io.Writer) *metadata.OntologyIndex {
+	index, err := metadata.LoadOntologies(metadata.DefaultOntologyDirs()...)
+	if err != nil {
+		fmt.Fprintf(w, "Warning: %v\n", err)
+	}
+	return index
+}
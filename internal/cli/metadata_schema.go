@@ -0,0 +1,630 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scttfrdmn/cicada/internal/metadata"
+)
+
+// newMetadataSchemaCmd creates the metadata schema command.
+func newMetadataSchemaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Manage metadata schemas",
+		Long: `Manage domain metadata schemas.
+
+Schemas describe the fields expected for a kind of data, with types, units,
+ranges and controlled vocabularies. They are searched in this order:
+  1. .cicada/schemas in the current directory (project)
+  2. ~/.cicada/schemas (user)
+  3. Built-in schemas
+
+A schema in an earlier location hides a built-in schema with the same name.
+JSON Schema documents (.json) in these directories are converted on load.
+
+Examples:
+  # List available schemas
+  cicada metadata schema list
+
+  # Show a schema with its inherited fields
+  cicada metadata schema show fluorescence-microscopy
+
+  # Validate files against a schema
+  cicada metadata schema validate microscopy data/*.czi
+
+  # Export a schema as JSON Schema
+  cicada metadata schema export sequencing > sequencing.schema.json`,
+	}
+
+	cmd.AddCommand(newMetadataSchemaListCmd())
+	cmd.AddCommand(newMetadataSchemaShowCmd())
+	cmd.AddCommand(newMetadataSchemaValidateCmd())
+	cmd.AddCommand(newMetadataSchemaExportCmd())
+	cmd.AddCommand(newMetadataSchemaImportCmd())
+
+	return cmd
+}
+
+// newMetadataSchemaListCmd creates the schema list subcommand.
+func newMetadataSchemaListCmd() *cobra.Command {
+	var (
+		outputFormat string
+		query        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List available metadata schemas",
+		Long: `List the metadata schemas in the project, user and built-in locations.
+
+Examples:
+  # List all schemas
+  cicada metadata schema list
+
+  # Find schemas that mention pixel sizes
+  cicada metadata schema list --search pixel
+
+  # List as JSON
+  cicada metadata schema list --format json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loader := metadata.NewFileSchemaLoader(metadata.DefaultSchemaDirs()...)
+
+			var schemas []metadata.SchemaInfo
+			var err error
+			if query != "" {
+				schemas, err = loader.Search(query)
+			} else {
+				schemas, err = loader.List()
+			}
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+			}
+
+			switch strings.ToLower(outputFormat) {
+			case "json":
+				output, err := json.MarshalIndent(schemas, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal JSON: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "yaml":
+				output, err := yaml.Marshal(schemas)
+				if err != nil {
+					return fmt.Errorf("marshal YAML: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "table", "":
+				fmt.Println("Available Metadata Schemas:")
+				fmt.Println()
+
+				for _, schema := range schemas {
+					fmt.Printf("  %s (v%s)\n", schema.Name, schema.Version)
+					fmt.Printf("    Domain: %s\n", schema.Domain)
+					fmt.Printf("    Description: %s\n", schema.Description)
+					fmt.Printf("    Source: %s\n", schema.Source)
+					fmt.Println()
+				}
+
+				fmt.Printf("Total: %d schemas\n", len(schemas))
+
+			default:
+				return fmt.Errorf("unsupported format: %s (use json, yaml, or table)", outputFormat)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", "table", "Output format (json, yaml, table)")
+	cmd.Flags().StringVarP(&query, "search", "s", "", "Only list schemas matching this text")
+
+	return cmd
+}
+
+// newMetadataSchemaShowCmd creates the schema show subcommand.
+func newMetadataSchemaShowCmd() *cobra.Command {
+	var (
+		outputFormat string
+		raw          bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "show <schema>",
+		Short: "Show a metadata schema",
+		Long: `Show a metadata schema by name, or from a schema file.
+
+Fields and required fields inherited through "extends" are included unless
+--raw is given.
+
+Examples:
+  # Show a built-in schema
+  cicada metadata schema show microscopy
+
+  # Show only what the schema itself defines
+  cicada metadata schema show fluorescence-microscopy --raw
+
+  # Show a schema file as JSON
+  cicada metadata schema show ./lab-schema.yaml --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loader := metadata.NewFileSchemaLoader(metadata.DefaultSchemaDirs()...)
+
+			var schema *metadata.Schema
+			var err error
+			if raw {
+				schema, err = loadRawSchema(loader, args[0])
+			} else {
+				schema, err = loadSchema(loader, args[0])
+			}
+			if err != nil {
+				return err
+			}
+			warnSchemaIssues(cmd.ErrOrStderr(), schema)
+
+			switch strings.ToLower(outputFormat) {
+			case "json":
+				output, err := json.MarshalIndent(schema, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal JSON: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "yaml":
+				output, err := yaml.Marshal(schema)
+				if err != nil {
+					return fmt.Errorf("marshal YAML: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "table", "":
+				fmt.Print(formatSchema(schema))
+
+			default:
+				return fmt.Errorf("unsupported format: %s (use json, yaml, or table)", outputFormat)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", "table", "Output format (json, yaml, table)")
+	cmd.Flags().BoolVar(&raw, "raw", false, "Do not merge fields from extended schemas")
+
+	return cmd
+}
+
+// newMetadataSchemaValidateCmd creates the schema validate subcommand.
+func newMetadataSchemaValidateCmd() *cobra.Command {
+	var (
+		fix  bool
+		save bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "validate <schema> [path...]",
+		Short: "Check a schema, or validate files against it",
+		Long: `Check a schema definition and validate files against it.
+
+The schema is a name or a schema file. With no paths, only the schema
+itself is checked: field types, patterns and its extends chain. Given paths,
+metadata is extracted from each file and validated against the schema.
+
+Enrichment recorded in the metadata store is applied before validating.
+With --save, the extracted metadata and the validation result are recorded
+in the store (see 'metadata history').
+
+Values close to a controlled vocabulary term get suggestions. Fields that
+name an ontology are checked against the offline ontologies (see "cicada
+metadata ontology"). With --fix, values that differ from a vocabulary term
+only in case, spacing or separators, that are a known synonym, or that are
+a clear misspelling are replaced by the term, and ontology fields get their
+term's label and CURIE (<field>_ontology_term_id). The fixes are recorded
+as enrichment in the metadata store, along with the extraction and the
+validation result.
+
+Examples:
+  # Check a schema file before installing it
+  cicada metadata schema validate ./lab-schema.yaml
+
+  # Validate microscopy files
+  cicada metadata schema validate microscopy data/*.czi
+
+  # Validate FASTQ files against a project schema
+  cicada metadata schema validate rnaseq data/*.fastq.gz
+
+  # Replace variants such as "mouse" with "Mus musculus"
+  cicada metadata schema validate sequencing data/*.fastq.gz --fix`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loader := metadata.NewFileSchemaLoader(metadata.DefaultSchemaDirs()...)
+			manager := metadata.NewSchemaManager(loader)
+
+			schema, err := loadSchemaInto(manager, loader, args[0])
+			if err != nil {
+				return err
+			}
+			warnSchemaIssues(cmd.ErrOrStderr(), schema)
+			if len(args) == 1 {
+				fmt.Printf("✓ schema %s is valid (%d fields)\n", schema.Name, len(schema.Fields))
+				return nil
+			}
+
+			manager.SetOntologies(loadOntologyIndex(cmd.ErrOrStderr()))
+			registry := newExtractorRegistry(cmd.ErrOrStderr())
+			var hasErrors bool
+			for _, path := range args[1:] {
+				extracted, err := registry.Extract(path)
+				if err != nil {
+					fmt.Printf("❌ %s: %v\n", path, err)
+					hasErrors = true
+					continue
+				}
+
+				fields := withEnrichment(metadata.NormalizeFields(extracted), storedEnrichment(path))
+				meta := &metadata.Metadata{
+					SchemaName:    schema.Name,
+					SchemaVersion: schema.Version,
+					Fields:        make(map[string]interface{}, len(fields)),
+					FileInfo:      metadata.FileInfo{Filename: filepath.Base(path), Path: path},
+				}
+				for key, value := range fields {
+					meta.Fields[key] = value
+				}
+				var fixes []metadata.VocabularyFix
+				var resolved []metadata.OntologyResolution
+				if fix {
+					fixes, err = manager.FixVocabulary(meta)
+					if err != nil {
+						return err
+					}
+					resolved, err = manager.ResolveOntologyTerms(meta)
+					if err != nil {
+						return err
+					}
+				}
+
+				result := manager.ValidateMetadata(meta)
+				if !printSchemaValidation(path, schema, result) {
+					hasErrors = true
+				}
+				if fix || save {
+					summary := metadata.SummarizeValidation(schema.Name, result)
+					enrichment := changedFields(fields, meta.Fields)
+					if _, err := storeMetadata(context.Background(), metadata.NewLocalMetadataStore(), path, extracted, enrichment, "schema validate --fix", &summary); err != nil {
+						return fmt.Errorf("save metadata: %w", err)
+					}
+				}
+				for _, f := range fixes {
+					fmt.Printf("     Fixed: %s\n", f)
+				}
+				for _, r := range resolved {
+					fmt.Printf("     Resolved: %s\n", r)
+				}
+			}
+
+			if hasErrors {
+				return fmt.Errorf("validation failed for one or more files")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&fix, "fix", false, "Replace variants of controlled terms, resolve ontology terms and store the fixes")
+	cmd.Flags().BoolVar(&save, "save", false, "Record the metadata and validation result in the metadata store")
+
+	return cmd
+}
+
+// newMetadataSchemaExportCmd creates the schema export subcommand.
+func newMetadataSchemaExportCmd() *cobra.Command {
+	var (
+		output string
+		id     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export <schema>",
+		Short: "Export a schema as JSON Schema",
+		Long: `Export a metadata schema, with its inherited fields, as JSON Schema
+draft 2020-12.
+
+Vocabularies become enum, numeric ranges minimum and maximum, and date,
+datetime and duration fields strings with a format. Units, synonyms,
+ontologies, required_if and validation rules are kept in an "x-cicada"
+annotation, which other validators ignore. Constraints that JSON Schema
+validators will not enforce are listed as warnings.
+
+Examples:
+  # Print a schema as JSON Schema
+  cicada metadata schema export microscopy
+
+  # Write it to a file with an $id for the data portal
+  cicada metadata schema export sequencing -o sequencing.schema.json \
+    --id https://data.example.org/schemas/sequencing.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loader := metadata.NewFileSchemaLoader(metadata.DefaultSchemaDirs()...)
+			schema, err := loadSchema(loader, args[0])
+			if err != nil {
+				return err
+			}
+
+			doc, issues := metadata.ExportJSONSchema(schema)
+			doc.ID = id
+			data, err := json.MarshalIndent(doc, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshal JSON: %w", err)
+			}
+			for _, issue := range issues {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", issue)
+			}
+
+			if output == "" {
+				fmt.Println(string(data))
+				return nil
+			}
+			if err := os.WriteFile(output, append(data, '\n'), 0644); err != nil {
+				return fmt.Errorf("write JSON Schema: %w", err)
+			}
+			fmt.Printf("✓ Exported %s (%d fields) to %s\n", schema.Name, len(schema.Fields), output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to this file instead of stdout")
+	cmd.Flags().StringVar(&id, "id", "", "$id of the exported document")
+
+	return cmd
+}
+
+// newMetadataSchemaImportCmd creates the schema import subcommand.
+func newMetadataSchemaImportCmd() *cobra.Command {
+	var (
+		name    string
+		project bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import a JSON Schema as a metadata schema",
+		Long: `Convert a JSON Schema document into a metadata schema and save it as
+YAML in the user schema directory (~/.cicada/schemas), or the project
+directory with --project.
+
+Each property becomes a field; enum becomes a vocabulary, minimum and
+maximum a range, and nested objects and array items nested fields. Local
+references ($defs) are followed. Keywords with no equivalent, such as
+allOf or minLength, are ignored and listed as warnings.
+
+Examples:
+  # Import a schema published by the LIMS
+  cicada metadata schema import lims-sample.schema.json --name lims-sample
+
+  # Import for this project only
+  cicada metadata schema import portal.json --project`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("read JSON Schema: %w", err)
+			}
+			schema, issues, err := metadata.ImportJSONSchema(data)
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				schema.Name = name
+			}
+			if schema.Name == "" {
+				base := filepath.Base(args[0])
+				schema.Name = strings.TrimSuffix(strings.TrimSuffix(base, filepath.Ext(base)), ".schema")
+			}
+
+			dirs := metadata.DefaultSchemaDirs()
+			dir := dirs[len(dirs)-1]
+			if project {
+				dir = dirs[0]
+			}
+			dest := filepath.Join(dir, schema.Name+".yaml")
+			output, err := yaml.Marshal(schema)
+			if err != nil {
+				return fmt.Errorf("marshal YAML: %w", err)
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("create schema directory: %w", err)
+			}
+			if err := os.WriteFile(dest, output, 0644); err != nil {
+				return fmt.Errorf("write schema: %w", err)
+			}
+
+			fmt.Printf("✓ Imported %s (%d fields) to %s\n", schema.Name, len(schema.Fields), dest)
+			for _, issue := range issues {
+				fmt.Printf("     Warning: %s\n", issue)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Schema name (default: from the document or file name)")
+	cmd.Flags().BoolVar(&project, "project", false, "Import into .cicada/schemas in the current directory")
+
+	return cmd
+}
+
+// warnSchemaIssues reports what was lost converting a schema from JSON
+// Schema.
+func warnSchemaIssues(w io.Writer, schema *metadata.Schema) {
+	for _, issue := range schema.ConversionIssues {
+		fmt.Fprintf(w, "Warning: %s: %s\n", schema.Name, issue)
+	}
+}
+
+// changedFields returns the fields of after that are new or differ from
+// before.
+func changedFields(before, after map[string]interface{}) map[string]interface{} {
+	changed := make(map[string]interface{})
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || !reflect.DeepEqual(previous, value) {
+			changed[key] = value
+		}
+	}
+	return changed
+}
+
+// loadSchema loads a schema by name or file and resolves its extends.
+func loadSchema(loader *metadata.FileSchemaLoader, nameOrFile string) (*metadata.Schema, error) {
+	return loadSchemaInto(metadata.NewSchemaManager(loader), loader, nameOrFile)
+}
+
+// loadSchemaInto loads a schema by name or file into manager.
+func loadSchemaInto(manager *metadata.SchemaManager, loader *metadata.FileSchemaLoader, nameOrFile string) (*metadata.Schema, error) {
+	if !isSchemaPath(nameOrFile) {
+		return manager.LoadSchema(nameOrFile)
+	}
+	schema, err := loader.LoadFromFile(nameOrFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := manager.AddSchema(schema); err != nil {
+		return nil, fmt.Errorf("schema %s: %w", schema.Name, err)
+	}
+	return schema, nil
+}
+
+// loadRawSchema loads a schema by name or file without resolving extends.
+func loadRawSchema(loader *metadata.FileSchemaLoader, nameOrFile string) (*metadata.Schema, error) {
+	if isSchemaPath(nameOrFile) {
+		return loader.LoadFromFile(nameOrFile)
+	}
+	return loader.Load(nameOrFile)
+}
+
+// isSchemaPath reports whether arg names an existing schema file rather
+// than a schema.
+func isSchemaPath(arg string) bool {
+	switch strings.ToLower(filepath.Ext(arg)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return false
+	}
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
+}
+
+// printSchemaValidation prints one file's result and reports whether it
+// is valid. Fields the schema does not define are counted, not listed.
+func printSchemaValidation(path string, schema *metadata.Schema, result metadata.ValidationResult) bool {
+	if result.Valid {
+		fmt.Printf("✓ %s: valid against %s\n", path, schema.Name)
+	} else {
+		fmt.Printf("❌ %s: %d errors against %s\n", path, len(result.Errors), schema.Name)
+	}
+	for _, e := range result.Errors {
+		fmt.Printf("     Error: %s: %s\n", e.Field, e.Message)
+	}
+
+	undefined := 0
+	for _, w := range result.Warnings {
+		if _, defined := schema.Fields[w.Field]; !defined {
+			undefined++
+			continue
+		}
+		fmt.Printf("     Warning: %s: %s\n", w.Field, w.Message)
+		if len(w.Suggestions) > 0 {
+			fmt.Printf("       Did you mean: %s\n", strings.Join(w.Suggestions, ", "))
+		}
+	}
+	if undefined > 0 {
+		fmt.Printf("     %d fields not defined in the schema\n", undefined)
+	}
+	if result.Score != nil {
+		fmt.Printf("     Quality Score: %d/100\n", result.Score.Overall)
+		for _, detail := range result.Score.Details {
+			fmt.Printf("       %s\n", detail)
+		}
+	}
+	return result.Valid
+}
+
+// formatSchema renders a schema for the table format.
+func formatSchema(schema *metadata.Schema) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Schema: %s\n\n", schema.Name)
+	fmt.Fprintf(&b, "Version:      %s\n", schema.Version)
+	fmt.Fprintf(&b, "Domain:       %s\n", schema.Domain)
+	fmt.Fprintf(&b, "Description:  %s\n", schema.Description)
+	if len(schema.Extends) > 0 {
+		fmt.Fprintf(&b, "Extends:      %s\n", strings.Join(schema.Extends, ", "))
+	}
+	if len(schema.RequiredFields) > 0 {
+		fmt.Fprintf(&b, "Required:     %s\n", strings.Join(schema.RequiredFields, ", "))
+	}
+
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(&b, "\nFields (%d):\n", len(names))
+	for _, name := range names {
+		field := schema.Fields[name]
+		line := fmt.Sprintf("  %-28s %s", name, field.Type)
+		if field.Units != "" {
+			line += fmt.Sprintf(" [%s]", field.Units)
+		}
+		if field.Range != nil {
+			line += fmt.Sprintf(" range %v..%v", rangeBound(field.Range.Min), rangeBound(field.Range.Max))
+		}
+		b.WriteString(line + "\n")
+		if field.Description != "" {
+			fmt.Fprintf(&b, "      %s\n", field.Description)
+		}
+		if len(field.Vocabulary) > 0 {
+			fmt.Fprintf(&b, "      Allowed: %s\n", strings.Join(field.Vocabulary, ", "))
+		}
+	}
+
+	if len(schema.ValidationRules) > 0 {
+		fmt.Fprintf(&b, "\nValidation Rules:\n")
+		for _, rule := range schema.ValidationRules {
+			fmt.Fprintf(&b, "  %s\n      %s\n", rule.Rule, rule.Message)
+		}
+	}
+	return b.String()
+}
+
+// rangeBound formats an optional range bound.
+func rangeBound(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
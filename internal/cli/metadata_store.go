@@ -0,0 +1,192 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/scttfrdmn/cicada/internal/metadata"
+	"github.com/scttfrdmn/cicada/internal/sync"
+)
+
+// newMetadataHistoryCmd creates the metadata history subcommand.
+func newMetadataHistoryCmd() *cobra.Command {
+	var outputFormat string
+
+	cmd := &cobra.Command{
+		Use:   "history <path>",
+		Short: "Show the stored metadata history of a file",
+		Long: `Show every version of a file's stored metadata: extractions, enrichment
+and validation results, with the fields each version added (+), changed (~)
+or removed (-).
+
+Metadata is stored with 'extract --save', 'validate --save', 'schema
+validate --save' or '--fix'. Local files keep their records in a .cicada
+directory next to them; S3 objects in a <key>.metadata.json object.
+
+Examples:
+  # Show the history of a file
+  cicada metadata history data/sample_R1.fastq.gz
+
+  # Show the history of an S3 object as JSON
+  cicada metadata history s3://bucket/run42/image.czi --format json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			store, key, closeStore, err := openMetadataStore(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+
+			record, err := store.Load(ctx, key)
+			if err != nil {
+				return err
+			}
+			if record.Version() == 0 {
+				return fmt.Errorf("no stored metadata for %s", args[0])
+			}
+
+			switch strings.ToLower(outputFormat) {
+			case "json":
+				output, err := json.MarshalIndent(record.History, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal JSON: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "yaml":
+				output, err := yaml.Marshal(record.History)
+				if err != nil {
+					return fmt.Errorf("marshal YAML: %w", err)
+				}
+				fmt.Println(string(output))
+
+			case "table", "":
+				fmt.Printf("%s: %d versions\n", args[0], record.Version())
+				for _, version := range record.History {
+					fmt.Println()
+					line := fmt.Sprintf("Version %d  %s  %s", version.Version, version.Timestamp.Format("2006-01-02 15:04:05"), version.Kind)
+					if version.Source != "" {
+						line += fmt.Sprintf(" (%s)", version.Source)
+					}
+					fmt.Println(line)
+					for _, change := range version.Changes {
+						fmt.Printf("  %s\n", change)
+					}
+					if version.Validation != nil {
+						fmt.Printf("  %s\n", version.Validation)
+						for _, e := range version.Validation.Errors {
+							fmt.Printf("    Error: %s\n", e)
+						}
+					}
+				}
+
+			default:
+				return fmt.Errorf("unsupported format: %s (use json, yaml, or table)", outputFormat)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFormat, "format", "f", "table", "Output format (json, yaml, table)")
+
+	return cmd
+}
+
+// openMetadataStore returns the store holding the record of path, which is
+// a local file or an s3:// URI, and the key to load it by.
+func openMetadataStore(ctx context.Context, path string) (*metadata.MetadataStore, string, func(), error) {
+	if !strings.HasPrefix(path, "s3://") {
+		return metadata.NewLocalMetadataStore(), path, func() {}, nil
+	}
+
+	bucket, key, err := sync.ParseS3URI(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	backend, err := sync.NewS3Backend(ctx, bucket)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("create S3 backend: %w", err)
+	}
+	return metadata.NewMetadataStore(backend), key, func() { _ = backend.Close() }, nil
+}
+
+// storeMetadata records new extracted fields, enrichment and a validation
+// result in the record of key, saving it if anything changed. Nil
+// arguments are skipped.
+func storeMetadata(ctx context.Context, store *metadata.MetadataStore, key string, extracted, enrichment map[string]interface{}, enrichmentSource string, validation *metadata.ValidationSummary) (*metadata.MetadataRecord, error) {
+	record, err := store.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	before := record.Version()
+	if extracted != nil {
+		record.SetExtracted(extractionSource(extracted), metadata.NormalizeFields(extracted))
+	}
+	if len(enrichment) > 0 {
+		record.Enrich(enrichmentSource, enrichment)
+	}
+	if validation != nil {
+		record.AddValidation(*validation)
+	}
+	if record.Version() == before {
+		return record, nil
+	}
+	if err := store.Save(ctx, key, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// storedEnrichment returns the enrichment stored for a local file, or nil.
+func storedEnrichment(path string) map[string]interface{} {
+	record, err := metadata.NewLocalMetadataStore().Load(context.Background(), path)
+	if err != nil {
+		return nil
+	}
+	return record.Enrichment
+}
+
+// withEnrichment returns fields with enrichment applied over them.
+func withEnrichment(fields, enrichment map[string]interface{}) map[string]interface{} {
+	if len(enrichment) == 0 {
+		return fields
+	}
+	merged := make(map[string]interface{}, len(fields)+len(enrichment))
+	for key, value := range fields {
+		merged[key] = value
+	}
+	for key, value := range enrichment {
+		merged[key] = value
+	}
+	return merged
+}
+
+// extractionSource names the source of extracted fields in the history.
+func extractionSource(fields map[string]interface{}) string {
+	if format, ok := fields["format"].(string); ok {
+		return format
+	}
+	return ""
+}
@@ -15,9 +15,17 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/scttfrdmn/cicada/internal/doi"
+	"github.com/scttfrdmn/cicada/internal/metadata"
 )
 
 // TestMetadataExtractCmd tests the metadata extract command.
@@ -37,6 +45,7 @@ func TestMetadataExtractCmd(t *testing.T) {
 		{"JSON format", []string{"extract", testFile, "--format", "json"}},
 		{"YAML format", []string{"extract", testFile, "--format", "yaml"}},
 		{"Table format", []string{"extract", testFile, "--format", "table"}},
+		{"Explain", []string{"extract", testFile, "--explain"}},
 	}
 
 	for _, tt := range tests {
@@ -85,6 +94,122 @@ func TestMetadataExtractCmd(t *testing.T) {
 	})
 }
 
+// TestMetadataExtractSampleReads tests the sampled pass over alignments.
+func TestMetadataExtractSampleReads(t *testing.T) {
+	tmpDir := t.TempDir()
+	sam := filepath.Join(tmpDir, "sample.sam")
+	content := "@HD\tVN:1.6\n@SQ\tSN:chr1\tLN:1000\n" +
+		"r1\t3\tchr1\t100\t60\t4M\t=\t200\t100\tACGT\tIIII\n" +
+		"r2\t5\t*\t0\t0\t*\t*\t0\t0\tACGT\tIIII\n"
+	if err := os.WriteFile(sam, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extract := func(args ...string) map[string]interface{} {
+		t.Helper()
+		output := filepath.Join(tmpDir, "metadata.json")
+		cmd := NewMetadataCmd()
+		cmd.SetArgs(append([]string{"extract", sam, "--output", output}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("extract %v failed: %v", args, err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := extract(); result["sampled_reads"] != nil {
+		t.Errorf("sampled_reads = %v without --sample-reads, want header only", result["sampled_reads"])
+	}
+	result := extract("--sample-reads", "10")
+	if result["sampled_reads"] != 2.0 || result["mapping_rate_percent"] != 50.0 {
+		t.Errorf("sampled_reads = %v, mapping_rate_percent = %v, want 2 and 50", result["sampled_reads"], result["mapping_rate_percent"])
+	}
+
+	cmd := NewMetadataCmd()
+	cmd.SetArgs([]string{"extract", sam, "--sample-reads", "-1"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("Expected error for negative --sample-reads")
+	}
+}
+
+// TestMetadataExtractBatch tests directory and multi-file extraction.
+func TestMetadataExtractBatch(t *testing.T) {
+	dataDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", filepath.Join("sub", "c.txt")} {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("content of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cacheFile := filepath.Join(t.TempDir(), "extract.json")
+
+	t.Run("JSONL output", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "out.jsonl")
+		cmd := NewMetadataCmd()
+		cmd.SetArgs([]string{"extract", dataDir, "--cache", cacheFile, "--workers", "2", "--output", outputFile})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Command failed: %v", err)
+		}
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(string(data), "\n"); lines != 3 {
+			t.Errorf("JSONL output has %d lines, want 3:\n%s", lines, data)
+		}
+		if _, err := os.Stat(cacheFile); err != nil {
+			t.Errorf("cache was not written: %v", err)
+		}
+	})
+
+	t.Run("CSV summary with filters", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "out.csv")
+		cmd := NewMetadataCmd()
+		cmd.SetArgs([]string{"extract", dataDir, "--cache", cacheFile, "--format", "csv",
+			"--exclude", "sub/**", "--output", outputFile})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Command failed: %v", err)
+		}
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "path,status,") || strings.Count(string(data), ",cached,") != 2 {
+			t.Errorf("CSV output = %s, want header and 2 cached rows", data)
+		}
+	})
+
+	t.Run("Sidecar output", func(t *testing.T) {
+		cmd := NewMetadataCmd()
+		cmd.SetArgs([]string{"extract", filepath.Join(dataDir, "a.txt"), filepath.Join(dataDir, "b.txt"),
+			"--no-cache", "--format", "sidecar"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Command failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dataDir, "a.txt"+metadata.SidecarSuffix)); err != nil {
+			t.Errorf("sidecar not written: %v", err)
+		}
+	})
+
+	t.Run("Missing input", func(t *testing.T) {
+		cmd := NewMetadataCmd()
+		cmd.SetArgs([]string{"extract", filepath.Join(dataDir, "a.txt"), filepath.Join(dataDir, "missing.txt"), "--no-cache"})
+		if err := cmd.Execute(); err == nil {
+			t.Error("Expected error for missing input")
+		}
+	})
+}
+
 // TestMetadataShowCmd tests the metadata show command.
 func TestMetadataShowCmd(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -231,3 +356,506 @@ func TestFormatAsTable(t *testing.T) {
 		}
 	})
 }
+
+// TestMetadataSchemaCmd tests the metadata schema commands.
+func TestMetadataSchemaCmd(t *testing.T) {
+	tmpDir := t.TempDir()
+	fastq := filepath.Join(tmpDir, "reads.fastq")
+	if err := os.WriteFile(fastq, []byte("@r1\nACGT\n+\nIIII\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	schemaFile := filepath.Join(tmpDir, "lab.yaml")
+	if err := os.WriteFile(schemaFile, []byte("name: lab\nextends: [sequencing]\nlab_id:\n  type: string\n"), 0644); err != nil {
+		t.Fatalf("Failed to create schema file: %v", err)
+	}
+	badSchema := filepath.Join(tmpDir, "bad.yaml")
+	if err := os.WriteFile(badSchema, []byte("name: bad\nsize:\n  type: size\n"), 0644); err != nil {
+		t.Fatalf("Failed to create schema file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"List schemas", []string{"schema", "list"}, false},
+		{"Search schemas", []string{"schema", "list", "--search", "pixel", "--format", "json"}, false},
+		{"Show schema", []string{"schema", "show", "fluorescence-microscopy"}, false},
+		{"Show raw schema as YAML", []string{"schema", "show", "microscopy", "--raw", "--format", "yaml"}, false},
+		{"Show schema file", []string{"schema", "show", schemaFile, "--format", "json"}, false},
+		{"Show unknown schema", []string{"schema", "show", "no-such-schema"}, true},
+		{"Validate schema", []string{"schema", "validate", schemaFile}, false},
+		{"Validate bad schema", []string{"schema", "validate", badSchema}, true},
+		{"Validate file", []string{"schema", "validate", "sequencing", fastq}, false},
+		{"Validate file against wrong schema", []string{"schema", "validate", "microscopy", fastq}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewMetadataCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetadataSchemaValidateFix(t *testing.T) {
+	tmpDir := t.TempDir()
+	fastq := filepath.Join(tmpDir, "reads.fastq")
+	if err := os.WriteFile(fastq, []byte("@r1\nACGT\n+\nIIII\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	schemaFile := filepath.Join(tmpDir, "lab.yaml")
+	if err := os.WriteFile(schemaFile, []byte("name: lab\nformat:\n  type: string\n  vocabulary: [fastq, bam]\n"), 0644); err != nil {
+		t.Fatalf("Failed to create schema file: %v", err)
+	}
+
+	cmd := NewMetadataCmd()
+	cmd.SetArgs([]string{"schema", "validate", schemaFile, fastq, "--fix"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	record, err := metadata.NewLocalMetadataStore().Load(context.Background(), fastq)
+	if err != nil {
+		t.Fatalf("Failed to load stored metadata: %v", err)
+	}
+	if record.Metadata.Fields["format"] != "fastq" {
+		t.Errorf("format = %v, want fastq", record.Metadata.Fields["format"])
+	}
+	if record.Extracted["format"] != "FASTQ" || record.Enrichment["format"] != "fastq" {
+		t.Errorf("Extracted format = %v, Enrichment format = %v, want FASTQ and fastq", record.Extracted["format"], record.Enrichment["format"])
+	}
+	var kinds []string
+	for _, version := range record.History {
+		kinds = append(kinds, version.Kind)
+	}
+	if want := []string{"extraction", "enrichment", "validation"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("History kinds = %v, want %v", kinds, want)
+	}
+	if record.Validation == nil || !record.Validation.Valid {
+		t.Errorf("Validation = %+v, want valid", record.Validation)
+	}
+}
+
+// TestMetadataStoreCmds tests saving metadata to the store and reading it
+// back with show and history.
+func TestMetadataStoreCmds(t *testing.T) {
+	tmpDir := t.TempDir()
+	fastq := filepath.Join(tmpDir, "reads.fastq")
+	if err := os.WriteFile(fastq, []byte("@r1\nACGT\n+\nIIII\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	unsaved := filepath.Join(tmpDir, "other.fastq")
+	if err := os.WriteFile(unsaved, []byte("@r1\nACGT\n+\nIIII\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"History before saving", []string{"history", fastq}, true},
+		{"Extract and save", []string{"extract", fastq, "--save"}, false},
+		{"Extract and save again", []string{"extract", fastq, "--save"}, false},
+		{"Validate against preset and save", []string{"validate", fastq, "--preset", "illumina-novaseq", "--save"}, false},
+		{"Show stored", []string{"show", fastq}, false},
+		{"Show stored as JSON", []string{"show", fastq, "--format", "json"}, false},
+		{"Show re-extracted", []string{"show", fastq, "--extract"}, false},
+		{"Show unsaved", []string{"show", unsaved}, false},
+		{"History", []string{"history", fastq}, false},
+		{"History as JSON", []string{"history", fastq, "--format", "json"}, false},
+		{"History with invalid format", []string{"history", fastq, "--format", "csv"}, true},
+		{"History of unsaved file", []string{"history", unsaved}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewMetadataCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	record, err := metadata.NewLocalMetadataStore().Load(context.Background(), fastq)
+	if err != nil {
+		t.Fatalf("Failed to load stored metadata: %v", err)
+	}
+	var kinds []string
+	for _, version := range record.History {
+		kinds = append(kinds, version.Kind)
+	}
+	if want := []string{"extraction", "validation"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("History kinds = %v, want %v", kinds, want)
+	}
+	if record.Validation == nil || record.Validation.Against != "illumina-novaseq" || record.Validation.Score == nil {
+		t.Errorf("Validation = %+v, want scored illumina-novaseq result", record.Validation)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, metadata.StoreDir, "other.fastq"+metadata.RecordSuffix)); !os.IsNotExist(err) {
+		t.Errorf("show stored metadata for an unsaved file: %v", err)
+	}
+}
+
+func TestMetadataOntologyCmd(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tmpDir := t.TempDir()
+	obo := filepath.Join(tmpDir, "strains.obo")
+	if err := os.WriteFile(obo, []byte("format-version: 1.2\n\n[Term]\nid: MGI:3028467\nname: C57BL/6J\nsynonym: \"B6\" EXACT []\n"), 0644); err != nil {
+		t.Fatalf("Failed to create ontology file: %v", err)
+	}
+	notOntology := filepath.Join(tmpDir, "notes.txt")
+	if err := os.WriteFile(notOntology, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"List ontologies", []string{"ontology", "list"}, false},
+		{"List ontologies as JSON", []string{"ontology", "list", "--format", "json"}, false},
+		{"Look up synonym", []string{"ontology", "lookup", "mouse"}, false},
+		{"Look up CURIE as JSON", []string{"ontology", "lookup", "UBERON:0002107", "--format", "json"}, false},
+		{"Look up in wrong ontology", []string{"ontology", "lookup", "mouse", "--ontology", "CL"}, true},
+		{"Look up unknown term", []string{"ontology", "lookup", "no such thing"}, true},
+		{"Import ontology", []string{"ontology", "import", obo}, false},
+		{"Look up imported term", []string{"ontology", "lookup", "B6", "-o", "MGI"}, false},
+		{"Import unsupported file", []string{"ontology", "import", notOntology}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewMetadataCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(home, ".cicada", "ontologies", "mgi.obo")); err != nil {
+		t.Errorf("imported ontology not stored: %v", err)
+	}
+}
+
+func TestMetadataSchemaJSONSchema(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tmpDir := t.TempDir()
+	exported := filepath.Join(tmpDir, "sequencing.schema.json")
+	lims := filepath.Join(tmpDir, "lims.json")
+	if err := os.WriteFile(lims, []byte(`{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object",
+  "required": ["sample_id"], "properties": {"sample_id": {"type": "string", "minLength": 3}}}`), 0644); err != nil {
+		t.Fatalf("Failed to create JSON Schema: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"Export schema", []string{"schema", "export", "sequencing", "-o", exported, "--id", "https://example.org/sequencing.json"}, false},
+		{"Export unknown schema", []string{"schema", "export", "no-such-schema"}, true},
+		{"Show JSON Schema file", []string{"schema", "show", exported}, false},
+		{"Validate JSON Schema file", []string{"schema", "validate", lims}, false},
+		{"Import JSON Schema", []string{"schema", "import", lims, "--name", "lims-sample"}, false},
+		{"Show imported schema", []string{"schema", "show", "lims-sample"}, false},
+		{"Import missing file", []string{"schema", "import", filepath.Join(tmpDir, "missing.json")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewMetadataCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	data, err := os.ReadFile(exported)
+	if err != nil {
+		t.Fatalf("Failed to read exported schema: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to parse exported schema: %v", err)
+	}
+	if doc["$schema"] != metadata.JSONSchemaDialect || doc["$id"] != "https://example.org/sequencing.json" {
+		t.Errorf("exported header = %v, %v", doc["$schema"], doc["$id"])
+	}
+	if _, err := os.Stat(filepath.Join(home, ".cicada", "schemas", "lims-sample.yaml")); err != nil {
+		t.Errorf("imported schema not stored: %v", err)
+	}
+}
+
+// TestMetadataEditor tests editing enrichment with preset checks.
+func TestMetadataEditor(t *testing.T) {
+	registry := metadata.NewPresetRegistry()
+	registry.RegisterDefaults()
+	preset, err := registry.GetPreset("illumina-novaseq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extracted := map[string]interface{}{"format": "FASTQ", "instrument_type": "sequencing", "total_reads": 1000}
+	workflow := doi.NewDOIWorkflow(nil, doi.NewProviderRegistry())
+	editor := newMetadataEditor("reads.fastq", "reads.fastq.enrichment.yaml", extracted, nil, preset, workflow)
+
+	if editor.presetResult.IsValid {
+		t.Fatal("preset validation passed without mean_read_length")
+	}
+	presetScore, readinessScore := editor.scores()
+
+	tests := []struct {
+		name    string
+		field   string
+		input   string
+		want    interface{}
+		wantErr bool
+	}{
+		{"Number", "mean_read_length", "150", 150.0, false},
+		{"Number below minimum", "mean_read_length", "0", nil, true},
+		{"Enum ignores case", "read_pair", "r1", "R1", false},
+		{"Value outside enum", "read_pair", "R3", nil, true},
+		{"Authors with ORCID", "authors", "Jane Doe (0000-0002-1825-0097); Bob", []interface{}{
+			map[string]interface{}{"name": "Jane Doe", "orcid": "0000-0002-1825-0097"},
+			map[string]interface{}{"name": "Bob"},
+		}, false},
+		{"Invalid ORCID", "authors", "Jane Doe (1234)", nil, true},
+		{"Keywords", "keywords", "mouse, liver", []interface{}{"mouse", "liver"}, false},
+		{"Invalid URL", "url", "example.org", nil, true},
+		{"Custom field", "operator", "jdoe", "jdoe", false},
+		{"Invalid field name", "bad name", "x", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := editor.enrichment[tt.field]
+			err := editor.set(tt.field, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !reflect.DeepEqual(editor.enrichment[tt.field], before) {
+					t.Errorf("rejected value changed %s to %v", tt.field, editor.enrichment[tt.field])
+				}
+				return
+			}
+			if !reflect.DeepEqual(editor.enrichment[tt.field], tt.want) {
+				t.Errorf("%s = %#v, want %#v", tt.field, editor.enrichment[tt.field], tt.want)
+			}
+		})
+	}
+
+	if !editor.presetResult.IsValid {
+		t.Errorf("preset validation failed after edits: %v", editor.presetResult.Errors)
+	}
+	newPresetScore, newReadinessScore := editor.scores()
+	if newPresetScore <= presetScore || newReadinessScore <= readinessScore {
+		t.Errorf("scores went from %.1f/%.1f to %.1f/%.1f, want both higher", presetScore, readinessScore, newPresetScore, newReadinessScore)
+	}
+	if _, ok := editor.field("operator"); !ok {
+		t.Error("custom field not listed")
+	}
+
+	if err := editor.set("operator", "-"); err != nil {
+		t.Fatalf("set() clear error = %v", err)
+	}
+	if _, ok := editor.enrichment["operator"]; ok {
+		t.Error("operator not cleared")
+	}
+	if err := editor.set("operator", "-"); err == nil {
+		t.Error("clearing a field without enrichment succeeded")
+	}
+}
+
+// TestMetadataEditorRun tests the editor's command loop.
+func TestMetadataEditorRun(t *testing.T) {
+	workflow := doi.NewDOIWorkflow(nil, doi.NewProviderRegistry())
+	extracted := map[string]interface{}{"format": "FASTQ"}
+
+	tests := []struct {
+		name      string
+		input     string
+		wantSaved bool
+		wantTitle interface{}
+	}{
+		{"Edit by number and save", "1\nMy dataset\ns\n", true, "My dataset"},
+		{"Set by name and save", "title=My dataset\nsave\n", true, "My dataset"},
+		{"Empty value keeps field", "1\n\ns\n", true, nil},
+		{"Quit discarding changes", "title=My dataset\nq\ny\n", false, "My dataset"},
+		{"Quit cancelled", "title=My dataset\nq\nn\ns\n", true, "My dataset"},
+		{"Input closed", "title=My dataset\n", false, "My dataset"},
+		{"Unknown command", "99\nfoo\n?\ns\n", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			editor := newMetadataEditor("reads.fastq", "out.yaml", extracted, nil, nil, workflow)
+			var out strings.Builder
+			saved, err := editor.run(strings.NewReader(tt.input), &out, false)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if saved != tt.wantSaved {
+				t.Errorf("run() saved = %v, want %v", saved, tt.wantSaved)
+			}
+			if editor.enrichment["title"] != tt.wantTitle {
+				t.Errorf("title = %v, want %v", editor.enrichment["title"], tt.wantTitle)
+			}
+			if !strings.Contains(out.String(), "DOI readiness:") {
+				t.Errorf("output has no DOI readiness score:\n%s", out.String())
+			}
+		})
+	}
+}
+
+// TestMetadataEditCmd tests saving the enrichment file doi prepare reads.
+func TestMetadataEditCmd(t *testing.T) {
+	tmpDir := t.TempDir()
+	fastq := filepath.Join(tmpDir, "reads.fastq")
+	if err := os.WriteFile(fastq, []byte("@r1\nACGT\n+\nIIII\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	enrichmentFile := fastq + metadata.EnrichmentSuffix
+
+	cmd := NewMetadataCmd()
+	cmd.SetIn(strings.NewReader("title=Liver RNA-seq\nkeywords=mouse, liver\ns\n"))
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"edit", fastq, "--save"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	enrichment, err := loadEnrichmentFile(enrichmentFile)
+	if err != nil {
+		t.Fatalf("Failed to read enrichment file: %v", err)
+	}
+	if enrichment["title"] != "Liver RNA-seq" || !reflect.DeepEqual(enrichment["keywords"], []interface{}{"mouse", "liver"}) {
+		t.Errorf("enrichment = %v", enrichment)
+	}
+	record, err := metadata.NewLocalMetadataStore().Load(context.Background(), fastq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Enrichment["title"] != "Liver RNA-seq" || record.Validation == nil || record.Validation.Against != "generic-sequencing" {
+		t.Errorf("stored record = enrichment %v, validation %+v", record.Enrichment, record.Validation)
+	}
+
+	// The saved file is loaded for the next edit; clearing a field removes
+	// it from the file and the store
+	cmd = NewMetadataCmd()
+	cmd.SetIn(strings.NewReader("title=-\ns\n"))
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"edit", fastq, "--save"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	enrichment, err = loadEnrichmentFile(enrichmentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := enrichment["title"]; ok || enrichment["keywords"] == nil {
+		t.Errorf("enrichment after clearing title = %v", enrichment)
+	}
+	record, err = metadata.NewLocalMetadataStore().Load(context.Background(), fastq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := record.Enrichment["title"]; ok {
+		t.Errorf("stored enrichment after clearing title = %v", record.Enrichment)
+	}
+
+	cmd = NewMetadataCmd()
+	cmd.SetArgs([]string{"edit", fastq, "--preset", "no-such-preset"})
+	cmd.SetIn(strings.NewReader(""))
+	if err := cmd.Execute(); err == nil {
+		t.Error("Execute() with unknown preset succeeded")
+	}
+}
+
+// TestMetadataEnrichCmd tests enriching files from sample sheets.
+func TestMetadataEnrichCmd(t *testing.T) {
+	tmpDir := t.TempDir()
+	fastqDir := filepath.Join(tmpDir, "fastq")
+	if err := os.MkdirAll(fastqDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"S1_S1_L001_R1_001.fastq", "S2_S2_L001_R1_001.fastq", "A1_reads.fastq", "B2_reads.fastq"} {
+		if err := os.WriteFile(filepath.Join(fastqDir, name), []byte("@r1\nACGT\n+\nIIII\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	illumina := filepath.Join(tmpDir, "SampleSheet.csv")
+	if err := os.WriteFile(illumina, []byte("[Header]\nFileFormatVersion,2\nRunName,Run42\n\n[BCLConvert_Data]\nLane,Sample_ID,Index\n1,S1,ACGT\n1,S2,TTTT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	samples := filepath.Join(tmpDir, "samples.tsv")
+	if err := os.WriteFile(samples, []byte("sample_id\torganism\tread_pair\nA1\tmouse\tr1\nB2\tmouse\tR9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"Dry run", []string{"enrich", fastqDir, "--sheet", illumina, "--dry-run"}, false},
+		{"Illumina SampleSheet", []string{"enrich", fastqDir, "--sheet", illumina}, false},
+		{"Regex match with preset", []string{"enrich", fastqDir, "--sheet", samples, "--match", `sample_id={^([A-Z]\d)_}`, "--preset", "illumina-novaseq", "--save"}, true},
+		{"Missing match", []string{"enrich", fastqDir, "--sheet", samples}, true},
+		{"Unknown column", []string{"enrich", fastqDir, "--sheet", samples, "--match", "barcode"}, true},
+		{"Unknown preset", []string{"enrich", fastqDir, "--sheet", illumina, "--preset", "no-such-preset"}, true},
+		{"Missing sheet", []string{"enrich", fastqDir, "--sheet", filepath.Join(tmpDir, "missing.csv")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewMetadataCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	enrichment, err := loadEnrichmentFile(filepath.Join(fastqDir, "S2_S2_L001_R1_001.fastq"+metadata.EnrichmentSuffix))
+	if err != nil {
+		t.Fatalf("Failed to read enrichment file: %v", err)
+	}
+	if enrichment["sample_id"] != "S2" || enrichment["experiment_name"] != "Run42" || enrichment["index"] != "TTTT" {
+		t.Errorf("Illumina enrichment = %v", enrichment)
+	}
+
+	enrichment, err = loadEnrichmentFile(filepath.Join(fastqDir, "A1_reads.fastq"+metadata.EnrichmentSuffix))
+	if err != nil {
+		t.Fatalf("Failed to read enrichment file: %v", err)
+	}
+	if enrichment["organism"] != "mouse" || enrichment["read_pair"] != "R1" {
+		t.Errorf("enrichment = %v, want organism mouse and read_pair R1", enrichment)
+	}
+	record, err := metadata.NewLocalMetadataStore().Load(context.Background(), filepath.Join(fastqDir, "A1_reads.fastq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Enrichment["organism"] != "mouse" || record.Extracted["format"] != "FASTQ" {
+		t.Errorf("stored record = extracted %v, enrichment %v", record.Extracted, record.Enrichment)
+	}
+
+	// An invalid row writes nothing
+	if _, err := os.Stat(filepath.Join(fastqDir, "B2_reads.fastq"+metadata.EnrichmentSuffix)); !os.IsNotExist(err) {
+		t.Errorf("enrichment written for an invalid sample: %v", err)
+	}
+}
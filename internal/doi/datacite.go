@@ -120,9 +120,22 @@ type ResourceType struct {
 }
 
 type Subject struct {
-	Value         string `xml:",chardata"`
-	SubjectScheme string `xml:"subjectScheme,attr,omitempty"`
-	SchemeURI     string `xml:"schemeURI,attr,omitempty"`
+	Value              string `xml:",chardata"`
+	SubjectScheme      string `xml:"subjectScheme,attr,omitempty"`
+	SchemeURI          string `xml:"schemeURI,attr,omitempty"`
+	ValueURI           string `xml:"valueURI,attr,omitempty"`
+	ClassificationCode string `xml:"classificationCode,attr,omitempty"`
+}
+
+// subjectFromTerm converts an ontology subject to a DataCite subject
+func subjectFromTerm(term SubjectTerm) Subject {
+	return Subject{
+		Value:              term.Term,
+		SubjectScheme:      term.Scheme,
+		SchemeURI:          term.SchemeURI,
+		ValueURI:           term.ValueURI,
+		ClassificationCode: term.Code,
+	}
 }
 
 type DataCiteContributor struct {
@@ -464,6 +477,9 @@ func (g *StandardMetadataGenerator) Generate(dataset *Dataset) *DataCiteMetadata
 			Value: keyword,
 		})
 	}
+	for _, subject := range dataset.Subjects {
+		metadata.Subjects = append(metadata.Subjects, subjectFromTerm(subject))
+	}
 
 	// Descriptions
 	if dataset.Description != "" {
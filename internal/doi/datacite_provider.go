@@ -614,6 +614,9 @@ func generateDataCiteMetadata(dataset *Dataset) *DataCiteMetadata {
 			Value: keyword,
 		})
 	}
+	for _, subject := range dataset.Subjects {
+		metadata.Subjects = append(metadata.Subjects, subjectFromTerm(subject))
+	}
 
 	// Descriptions
 	if dataset.Description != "" {
@@ -17,8 +17,11 @@ package doi
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/scttfrdmn/cicada/internal/metadata"
 )
 
 // MetadataMapper maps Cicada metadata to DOI Dataset structure
@@ -27,6 +30,10 @@ type MetadataMapper struct {
 	DefaultPublisher string
 	DefaultLicense   string
 	DefaultURL       string
+
+	// Ontologies, if set, resolve organism, tissue and other terms so they
+	// become subjects with ontology identifiers
+	Ontologies *metadata.OntologyIndex
 }
 
 // NewMetadataMapper creates a new metadata mapper with defaults
@@ -45,20 +52,68 @@ func NewMetadataMapper(publisher, license, url string) *MetadataMapper {
 }
 
 // MapToDataset converts Cicada extractor metadata to DOI Dataset
-func (m *MetadataMapper) MapToDataset(metadata map[string]interface{}, filename string) (*Dataset, error) {
-	format, _ := metadata["format"].(string)
+func (m *MetadataMapper) MapToDataset(fields map[string]interface{}, filename string) (*Dataset, error) {
+	// Resolve extractor-specific aliases so the mappers below can rely on
+	// the canonical field names
+	normalized := metadata.NormalizeFields(fields)
+	format, _ := normalized["format"].(string)
 
 	// Route to format-specific mapper
+	var dataset *Dataset
+	var err error
 	switch format {
 	case "CZI":
-		return m.mapCZI(metadata, filename)
+		dataset, err = m.mapCZI(normalized, filename)
 	case "FASTQ":
-		return m.mapFASTQ(metadata, filename)
+		dataset, err = m.mapFASTQ(normalized, filename)
 	case "OME-TIFF":
-		return m.mapOMETIFF(metadata, filename)
+		dataset, err = m.mapOMETIFF(normalized, filename)
 	default:
-		return m.mapGeneric(metadata, filename)
+		dataset, err = m.mapGeneric(normalized, filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dataset.Subjects = append(dataset.Subjects, m.mapOntologySubjects(normalized)...)
+	return dataset, nil
+}
+
+// mapOntologySubjects returns a subject for every field with an ontology
+// term, resolving terms first when the mapper has ontologies
+func (m *MetadataMapper) mapOntologySubjects(fields map[string]interface{}) []SubjectTerm {
+	if m.Ontologies != nil {
+		metadata.ResolveOntologyFields(fields, metadata.DefaultOntologyFields, m.Ontologies)
+	}
+
+	var names []string
+	for key := range fields {
+		if strings.HasSuffix(key, metadata.OntologyTermIDSuffix) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+
+	var subjects []SubjectTerm
+	for _, key := range names {
+		id, ok := fields[key].(string)
+		prefix, _, found := strings.Cut(id, ":")
+		if !ok || !found {
+			continue
+		}
+		label, _ := fields[strings.TrimSuffix(key, metadata.OntologyTermIDSuffix)].(string)
+		if label == "" {
+			label = id
+		}
+		subjects = append(subjects, SubjectTerm{
+			Term:      label,
+			Scheme:    prefix,
+			SchemeURI: metadata.OntologySchemeURI(prefix),
+			ValueURI:  metadata.OntologyTermURI(id),
+			Code:      id,
+		})
 	}
+	return subjects
 }
 
 // mapCZI maps CZI microscopy metadata to Dataset
@@ -106,13 +161,13 @@ func (m *MetadataMapper) mapCZI(metadata map[string]interface{}, filename string
 			desc = append(desc, fmt.Sprintf("Dimensions: %d x %d pixels", width, height))
 		}
 	}
-	if channels, ok := metadata["channel_count"].(int); ok && channels > 0 {
+	if channels, ok := metadata["num_channels"].(int); ok && channels > 0 {
 		desc = append(desc, fmt.Sprintf("Channels: %d", channels))
 	}
-	if zplanes, ok := metadata["z_planes"].(int); ok && zplanes > 1 {
+	if zplanes, ok := metadata["image_depth"].(int); ok && zplanes > 1 {
 		desc = append(desc, fmt.Sprintf("Z-planes: %d", zplanes))
 	}
-	if timepoints, ok := metadata["timepoints"].(int); ok && timepoints > 1 {
+	if timepoints, ok := metadata["num_timepoints"].(int); ok && timepoints > 1 {
 		desc = append(desc, fmt.Sprintf("Timepoints: %d", timepoints))
 	}
 	dataset.Description = strings.Join(desc, "; ")
@@ -254,24 +309,74 @@ func (m *MetadataMapper) mapOMETIFF(metadata map[string]interface{}, filename st
 		Custom:          make(map[string]interface{}),
 	}
 
-	// Title from filename (OME-TIFF extraction not yet complete)
-	dataset.Title = fmt.Sprintf("OME-TIFF Image: %s", filepath.Base(filename))
+	// Title from image name or filename
+	if imageName, ok := metadata["image_name"].(string); ok && imageName != "" {
+		dataset.Title = imageName
+	} else {
+		dataset.Title = fmt.Sprintf("OME-TIFF Image: %s", filepath.Base(filename))
+	}
 
-	// Authors - require user configuration
-	dataset.Authors = []Author{{Name: "Unknown Creator"}}
+	// Authors from OME Experimenter
+	if operator, ok := metadata["operator"].(string); ok && operator != "" {
+		author := Author{Name: operator}
+		if institution, ok := metadata["institution"].(string); ok {
+			author.Affiliation = institution
+		}
+		dataset.Authors = []Author{author}
+	} else {
+		dataset.Authors = []Author{{Name: "Unknown Creator"}}
+	}
 
-	// Description
-	dataset.Description = "OME-TIFF microscopy image. Full metadata extraction requires TIFF library support."
+	// Description from technical details
+	desc := []string{}
+	if manufacturer, ok := metadata["manufacturer"].(string); ok {
+		desc = append(desc, fmt.Sprintf("Manufacturer: %s", manufacturer))
+	}
+	if model, ok := metadata["instrument_model"].(string); ok {
+		desc = append(desc, fmt.Sprintf("Instrument: %s", model))
+	}
+	if width, ok := metadata["image_width"].(int); ok {
+		if height, ok2 := metadata["image_height"].(int); ok2 {
+			desc = append(desc, fmt.Sprintf("Dimensions: %d x %d pixels", width, height))
+		}
+	}
+	if channels, ok := metadata["num_channels"].(int); ok && channels > 0 {
+		desc = append(desc, fmt.Sprintf("Channels: %d", channels))
+	}
+	if images, ok := metadata["image_count"].(int); ok && images > 1 {
+		desc = append(desc, fmt.Sprintf("Images: %d", images))
+	}
+	if len(desc) > 0 {
+		dataset.Description = "OME-TIFF microscopy image. " + strings.Join(desc, "; ")
+	} else {
+		dataset.Description = "OME-TIFF microscopy image."
+	}
 
 	// Keywords
 	dataset.Keywords = []string{"microscopy", "biological imaging", "OME-TIFF"}
+	if manufacturer, ok := metadata["manufacturer"].(string); ok {
+		dataset.Keywords = append(dataset.Keywords, strings.ToLower(manufacturer))
+	}
+
+	// Dates
+	if acqDate, ok := metadata["acquisition_date"].(string); ok && acqDate != "" {
+		dataset.Dates = append(dataset.Dates, DateInfo{
+			Date: acqDate,
+			Type: "Collected",
+		})
+	}
 
-	// Note about implementation status
-	if note, ok := metadata["extraction_note"].(string); ok {
-		dataset.Custom["extraction_note"] = note
+	// Custom metadata for preservation
+	preserveFields := []string{
+		"objective_magnification", "objective_na", "objective_immersion",
+		"pixel_size_x_um", "pixel_size_y_um", "pixel_size_z_um",
+		"channels", "image_count", "ome_schema",
+		"extraction_note", "implementation_status",
 	}
-	if status, ok := metadata["implementation_status"].(string); ok {
-		dataset.Custom["implementation_status"] = status
+	for _, field := range preserveFields {
+		if val, ok := metadata[field]; ok {
+			dataset.Custom[field] = val
+		}
 	}
 
 	return dataset, nil
@@ -376,8 +481,16 @@ func (m *MetadataMapper) EnrichDataset(dataset *Dataset, enrichment map[string]i
 	if url, ok := enrichment["url"].(string); ok && url != "" {
 		dataset.URL = url
 	}
-	if keywords, ok := enrichment["keywords"].([]string); ok {
+	switch keywords := enrichment["keywords"].(type) {
+	case []string:
 		dataset.Keywords = append(dataset.Keywords, keywords...)
+	case []interface{}:
+		// As read from an enrichment YAML or JSON file
+		for _, keyword := range keywords {
+			if keyword, ok := keyword.(string); ok && keyword != "" {
+				dataset.Keywords = append(dataset.Keywords, keyword)
+			}
+		}
 	}
 
 	// Add funding references
@@ -400,6 +513,27 @@ func (m *MetadataMapper) EnrichDataset(dataset *Dataset, enrichment map[string]i
 			}
 		}
 	}
+
+	// Add ontology subjects such as the organism
+	fields := make(map[string]interface{}, len(enrichment))
+	for key, value := range enrichment {
+		fields[key] = value
+	}
+	for _, subject := range m.mapOntologySubjects(fields) {
+		if !hasSubject(dataset.Subjects, subject.Code) {
+			dataset.Subjects = append(dataset.Subjects, subject)
+		}
+	}
+}
+
+// hasSubject reports whether subjects include the given term
+func hasSubject(subjects []SubjectTerm, code string) bool {
+	for _, s := range subjects {
+		if s.Code == code {
+			return true
+		}
+	}
+	return false
 }
 
 // GenerateTitle generates a descriptive title from metadata
@@ -15,8 +15,11 @@
 package doi
 
 import (
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/scttfrdmn/cicada/internal/metadata"
 )
 
 func TestMetadataMapper_MapCZI(t *testing.T) {
@@ -216,6 +219,45 @@ func TestMetadataMapper_MapOMETIFF(t *testing.T) {
 	}
 }
 
+func TestMetadataMapper_MapOMETIFF_Extracted(t *testing.T) {
+	mapper := NewMetadataMapper("Imaging Center", "CC-BY-4.0", "")
+
+	metadata := map[string]interface{}{
+		"format":           "OME-TIFF",
+		"image_name":       "Well A1",
+		"operator":         "Jane Doe",
+		"institution":      "Example University",
+		"manufacturer":     "Nikon",
+		"instrument_model": "Ti2-E",
+		"image_width":      2048,
+		"image_height":     2048,
+		"num_channels":     2,
+		"acquisition_date": "2025-11-23T10:30:00",
+		"objective_na":     1.4,
+	}
+
+	dataset, err := mapper.MapToDataset(metadata, "/data/plate.ome.tif")
+	if err != nil {
+		t.Fatalf("MapToDataset() error = %v", err)
+	}
+
+	if dataset.Title != "Well A1" {
+		t.Errorf("Title = %v, want Well A1", dataset.Title)
+	}
+	if len(dataset.Authors) != 1 || dataset.Authors[0].Name != "Jane Doe" || dataset.Authors[0].Affiliation != "Example University" {
+		t.Errorf("Authors = %+v", dataset.Authors)
+	}
+	if !strings.Contains(dataset.Description, "Ti2-E") || !strings.Contains(dataset.Description, "Channels: 2") {
+		t.Errorf("Description = %v", dataset.Description)
+	}
+	if len(dataset.Dates) != 1 || dataset.Dates[0].Type != "Collected" {
+		t.Errorf("Dates = %+v", dataset.Dates)
+	}
+	if dataset.Custom["objective_na"] != 1.4 {
+		t.Errorf("Custom objective_na = %v", dataset.Custom["objective_na"])
+	}
+}
+
 func TestMetadataMapper_MapGeneric(t *testing.T) {
 	mapper := NewMetadataMapper("Research Lab", "MIT", "")
 
@@ -420,3 +462,64 @@ func TestExtractKeywords(t *testing.T) {
 		})
 	}
 }
+
+func TestMetadataMapper_OntologySubjects(t *testing.T) {
+	mapper := NewMetadataMapper("", "", "")
+	mapper.Ontologies = metadata.BuiltinOntologies()
+
+	dataset, err := mapper.MapToDataset(map[string]interface{}{
+		"format":   "FASTQ",
+		"organism": "mouse",
+		"tissue":   "liver",
+	}, "reads.fastq")
+	if err != nil {
+		t.Fatalf("MapToDataset() error = %v", err)
+	}
+
+	if len(dataset.Subjects) != 2 {
+		t.Fatalf("Subjects = %+v, want 2", dataset.Subjects)
+	}
+	organism := dataset.Subjects[0]
+	if organism.Term != "Mus musculus" || organism.Code != "NCBITaxon:10090" || organism.Scheme != "NCBITaxon" {
+		t.Errorf("Subjects[0] = %+v, want Mus musculus NCBITaxon:10090", organism)
+	}
+	if organism.ValueURI != "http://purl.obolibrary.org/obo/NCBITaxon_10090" {
+		t.Errorf("Subjects[0].ValueURI = %s", organism.ValueURI)
+	}
+
+	generated := (&StandardMetadataGenerator{}).Generate(dataset)
+	var found bool
+	for _, subject := range generated.Subjects {
+		if subject.ClassificationCode == "UBERON:0002107" {
+			found = subject.Value == "liver" && subject.ValueURI == "http://purl.obolibrary.org/obo/UBERON_0002107"
+		}
+	}
+	if !found {
+		t.Errorf("DataCite subjects = %+v, want liver with its term URI", generated.Subjects)
+	}
+
+	// Enrichment may name terms the extracted metadata lacks
+	mapper.EnrichDataset(dataset, map[string]interface{}{"cell_type": "hepatocyte"})
+	if len(dataset.Subjects) != 3 || dataset.Subjects[2].Code != "CL:0000182" {
+		t.Errorf("Subjects after enrichment = %+v, want hepatocyte added", dataset.Subjects)
+	}
+	mapper.EnrichDataset(dataset, map[string]interface{}{"cell_type": "hepatocyte"})
+	if len(dataset.Subjects) != 3 {
+		t.Errorf("Subjects after second enrichment = %d, want no duplicates", len(dataset.Subjects))
+	}
+}
+
+func TestMetadataMapper_EnrichDatasetFileKeywords(t *testing.T) {
+	mapper := NewMetadataMapper("Test Publisher", "CC-BY-4.0", "")
+	dataset := &Dataset{Keywords: []string{"sequencing"}}
+
+	// Keywords read from an enrichment YAML file are []interface{}
+	mapper.EnrichDataset(dataset, map[string]interface{}{
+		"keywords": []interface{}{"mouse", "liver", 3},
+	})
+
+	want := []string{"sequencing", "mouse", "liver"}
+	if !reflect.DeepEqual(dataset.Keywords, want) {
+		t.Errorf("Keywords = %v, want %v", dataset.Keywords, want)
+	}
+}
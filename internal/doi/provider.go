@@ -63,6 +63,7 @@ type Dataset struct {
 	Version         string            `json:"version,omitempty"`
 	Language        string            `json:"language,omitempty"`        // ISO 639-1 code
 	Keywords        []string          `json:"keywords,omitempty"`
+	Subjects        []SubjectTerm     `json:"subjects,omitempty"`        // Ontology terms, e.g. the organism
 	RelatedIdentifiers []RelatedID    `json:"related_identifiers,omitempty"`
 	Contributors    []Contributor     `json:"contributors,omitempty"`
 	FundingReferences []FundingRef    `json:"funding_references,omitempty"`
@@ -91,6 +92,15 @@ type RelatedID struct {
 	ResourceType string `json:"resource_type,omitempty"`
 }
 
+// SubjectTerm represents a subject identified by an ontology term
+type SubjectTerm struct {
+	Term      string `json:"term"`                 // Label, e.g. "Mus musculus"
+	Scheme    string `json:"scheme,omitempty"`     // Ontology prefix, e.g. "NCBITaxon"
+	SchemeURI string `json:"scheme_uri,omitempty"` // Ontology IRI
+	ValueURI  string `json:"value_uri,omitempty"`  // Term IRI
+	Code      string `json:"code,omitempty"`       // CURIE, e.g. "NCBITaxon:10090"
+}
+
 // Contributor represents a dataset contributor (not author)
 type Contributor struct {
 	Name         string   `json:"name"`
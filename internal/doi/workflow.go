@@ -17,6 +17,7 @@ package doi
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/scttfrdmn/cicada/internal/metadata"
 )
@@ -30,6 +31,7 @@ type WorkflowConfig struct {
 	RequireRealAuthors bool // Reject "Unknown Creator"
 	RequireDescription bool // Require non-empty description
 	AutoEnrich      bool    // Automatically enrich from presets
+	Ontologies      *metadata.OntologyIndex // Resolves organism, tissue, etc. to ontology subjects
 }
 
 // DOIWorkflow orchestrates the DOI assignment process
@@ -54,6 +56,7 @@ func NewDOIWorkflow(config *WorkflowConfig, registry *ProviderRegistry) *DOIWork
 	}
 
 	mapper := NewMetadataMapper(config.Publisher, config.License, config.LandingPageURL)
+	mapper.Ontologies = config.Ontologies
 
 	validator := NewDOIReadinessValidator()
 	validator.MinQualityScore = config.MinQualityScore
@@ -78,9 +81,10 @@ type PrepareRequest struct {
 
 // PrepareResult represents the result of DOI preparation
 type PrepareResult struct {
-	Dataset    *Dataset         // Mapped dataset
-	Validation *ReadinessResult // Validation result
-	Warnings   []string         // Workflow warnings
+	Dataset     *Dataset         // Mapped dataset
+	Validation  *ReadinessResult // Validation result
+	Warnings    []string         // Workflow warnings
+	ContainsPHI bool             // Source file carries protected health information
 }
 
 // Prepare prepares metadata for DOI minting
@@ -123,7 +127,10 @@ func (w *DOIWorkflow) Prepare(req *PrepareRequest) (*PrepareResult, error) {
 	result.Dataset = dataset
 	result.Validation = validation
 
-	// 5. Add workflow-specific warnings
+	// 5. Refuse identifiable patient data (e.g. DICOM PHI tags)
+	result.ContainsPHI = rejectIdentifiable(validation, req.Metadata)
+
+	// 6. Add workflow-specific warnings
 	if !validation.IsReady {
 		result.Warnings = append(result.Warnings,
 			"Dataset is not ready for DOI minting. See validation errors.")
@@ -132,6 +139,39 @@ func (w *DOIWorkflow) Prepare(req *PrepareRequest) (*PrepareResult, error) {
 	return result, nil
 }
 
+// rejectIdentifiable marks validation as not ready when the extracted
+// metadata reports protected health information. It returns true if so.
+func rejectIdentifiable(validation *ReadinessResult, md map[string]interface{}) bool {
+	phiTags := identifiableTags(md)
+	if len(phiTags) == 0 {
+		return false
+	}
+	validation.IsReady = false
+	validation.Errors = append(validation.Errors,
+		fmt.Sprintf("file contains protected health information (%s); de-identify it before publishing",
+			strings.Join(phiTags, ", ")))
+	return true
+}
+
+// identifiableTags returns the PHI tags reported by an extractor, such as
+// the DICOM extractor's "phi_tags".
+func identifiableTags(md map[string]interface{}) []string {
+	switch tags := md["phi_tags"].(type) {
+	case []string:
+		return tags
+	case []interface{}:
+		names := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			names = append(names, fmt.Sprint(tag))
+		}
+		return names
+	}
+	if contains, _ := md["contains_phi"].(bool); contains {
+		return []string{"unspecified"}
+	}
+	return nil
+}
+
 // MintRequest represents a DOI minting request
 type MintRequest struct {
 	Dataset     *Dataset // Prepared dataset
@@ -253,11 +293,13 @@ func (w *DOIWorkflow) ValidateMetadata(metadata map[string]interface{}, filename
 	}
 
 	validation := w.validator.Validate(dataset)
+	containsPHI := rejectIdentifiable(validation, metadata)
 
 	return &PrepareResult{
-		Dataset:    dataset,
-		Validation: validation,
-		Warnings:   []string{},
+		Dataset:     dataset,
+		Validation:  validation,
+		Warnings:    []string{},
+		ContainsPHI: containsPHI,
 	}, nil
 }
 
@@ -0,0 +1,79 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doi
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDOIWorkflow_PrepareRefusesPHI(t *testing.T) {
+	workflow := NewDOIWorkflow(nil, NewProviderRegistry())
+
+	md := map[string]interface{}{
+		"format":       "DICOM",
+		"modality":     "MR",
+		"manufacturer": "SIEMENS",
+		"contains_phi": true,
+		"phi_tags":     []string{"PatientName", "PatientID"},
+	}
+	enrichment := map[string]interface{}{
+		"title":       "Brain MRI",
+		"description": "Structural MRI of a phantom acquired for scanner calibration and protocol testing.",
+		"authors":     []map[string]interface{}{{"name": "Jane Smith"}},
+	}
+
+	result, err := workflow.Prepare(&PrepareRequest{FilePath: "brain.dcm", Metadata: md, Enrichment: enrichment})
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if !result.ContainsPHI || result.Validation.IsReady {
+		t.Errorf("ContainsPHI = %v, IsReady = %v; want true, false", result.ContainsPHI, result.Validation.IsReady)
+	}
+	found := false
+	for _, e := range result.Validation.Errors {
+		if strings.Contains(e, "PatientName, PatientID") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Errors = %v, want PHI error", result.Validation.Errors)
+	}
+
+	if _, err := workflow.PrepareAndMint(context.Background(),
+		&PrepareRequest{FilePath: "brain.dcm", Metadata: md, Enrichment: enrichment},
+		&MintRequest{DryRun: true}); err == nil {
+		t.Error("PrepareAndMint() should refuse files with PHI")
+	}
+
+	validated, err := workflow.ValidateMetadata(md, "brain.dcm")
+	if err != nil {
+		t.Fatalf("ValidateMetadata() error = %v", err)
+	}
+	if !validated.ContainsPHI || validated.Validation.IsReady {
+		t.Errorf("ValidateMetadata ContainsPHI = %v, IsReady = %v", validated.ContainsPHI, validated.Validation.IsReady)
+	}
+
+	delete(md, "contains_phi")
+	delete(md, "phi_tags")
+	clean, err := workflow.Prepare(&PrepareRequest{FilePath: "brain.dcm", Metadata: md, Enrichment: enrichment})
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if clean.ContainsPHI {
+		t.Error("de-identified metadata should not be flagged")
+	}
+}
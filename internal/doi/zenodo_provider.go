@@ -65,6 +65,7 @@ type ZenodoMetadata struct {
 	Creators           []ZenodoCreator       `json:"creators"`
 	PublicationDate    string                `json:"publication_date,omitempty"`
 	Keywords           []string              `json:"keywords,omitempty"`
+	Subjects           []ZenodoSubject       `json:"subjects,omitempty"`
 	License            string                `json:"license,omitempty"`
 	AccessRight        string                `json:"access_right"` // open, embargoed, restricted, closed
 	Version            string                `json:"version,omitempty"`
@@ -82,6 +83,13 @@ type ZenodoCreator struct {
 	ORCID       string `json:"orcid,omitempty"`
 }
 
+// ZenodoSubject represents a subject from a controlled vocabulary
+type ZenodoSubject struct {
+	Term       string `json:"term"`
+	Identifier string `json:"identifier"`
+	Scheme     string `json:"scheme"` // url
+}
+
 // ZenodoRelatedID represents a related identifier
 type ZenodoRelatedID struct {
 	Identifier string `json:"identifier"`
@@ -529,6 +537,17 @@ func (p *ZenodoProvider) datasetToZenodoMetadata(dataset *Dataset) ZenodoMetadat
 	// Keywords
 	metadata.Keywords = dataset.Keywords
 
+	// Subjects identified by ontology terms
+	for _, subject := range dataset.Subjects {
+		if subject.ValueURI != "" {
+			metadata.Subjects = append(metadata.Subjects, ZenodoSubject{
+				Term:       subject.Term,
+				Identifier: subject.ValueURI,
+				Scheme:     "url",
+			})
+		}
+	}
+
 	// License
 	if dataset.License != "" {
 		metadata.License = mapLicenseToZenodo(dataset.License)
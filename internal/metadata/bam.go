@@ -0,0 +1,1036 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # SAM/BAM/CRAM Format
+//
+// This file implements metadata extraction for aligned sequencing reads stored
+// as SAM (text), BAM (BGZF-compressed binary) or CRAM (reference-based
+// columnar) files. Everything is pure Go; no htslib or cgo is required.
+//
+// ## Header Records
+//
+// All three formats share the SAM text header:
+//   - @HD: format version (VN), sort order (SO), group order (GO)
+//   - @SQ: reference sequences (SN name, LN length, AS assembly, UR URI)
+//   - @RG: read groups (ID, SM sample, LB library, PL platform, PU unit)
+//   - @PG: programs (ID, PN name, VN version, CL command line, PP previous)
+//   - @CO: free-text comments
+//
+// PacBio read groups (PL:PACBIO) carry the movie name in PU and run details
+// in DS as semicolon-separated KEY=VALUE pairs (READTYPE, BINDINGKIT,
+// SEQUENCINGKIT, BASECALLERVERSION, FRAMERATEHZ). These are mapped into
+// SequencingMetadata with platform "PacBio"; the instrument serial number and
+// movie start time are decoded from the movie name (m64011_190830_220126).
+//
+// ## Container Layouts
+//
+// BAM is a series of BGZF blocks, which are ordinary gzip members, so the
+// standard library gzip reader decompresses it in multistream mode. The
+// decompressed stream starts with "BAM\1", the header text and the binary
+// reference list, followed by alignment records.
+//
+// CRAM starts with a 26-byte file definition ("CRAM", major, minor, file ID)
+// followed by containers. The first container holds the SAM header in a
+// single block that is raw, gzip or bzip2 compressed. Container headers use
+// ITF8/LTF8 variable-length integers and carry a record count, which lets the
+// sampled pass count CRAM records without decoding them.
+//
+// ## Sampled Pass
+//
+// When SampleReads is non-zero (set with 'cicada metadata extract
+// --sample-reads'), up to that many primary alignment records are
+// read after the header to estimate mapping, duplicate and pairing rates. The
+// total read count is exact when the whole file fits in the sample and is
+// otherwise extrapolated from the fraction of the file consumed.
+//
+// ## References and Sources
+//
+// Sequence Alignment/Map Format Specification:
+// https://samtools.github.io/hts-specs/SAMv1.pdf
+//
+// CRAM Format Specification (version 3.1):
+// https://samtools.github.io/hts-specs/CRAMv3.pdf
+//
+// ## Limitations
+//
+//   - CRAM 4 and CRAM 1 headers are not decoded
+//   - CRAM header blocks compressed with LZMA or rANS are not decoded
+//   - CRAM sampling reports record counts only; mapping and duplicate rates
+//     would require decoding the record data series
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SAM/BAM/CRAM format constants
+const (
+	bamMagic            = "BAM\x01"
+	cramMagic           = "CRAM"
+	bamMaxHeaderText    = 256 << 20
+	bamMaxReferences    = 1 << 24
+	bamMaxRecordSize    = 64 << 20
+	bamMaxListedRefs    = 100
+	cramFileDefinition  = 26
+	cramMaxHeaderBlock  = 256 << 20
+	samFlagPaired       = 0x1
+	samFlagUnmapped     = 0x4
+	samFlagSecondary    = 0x100
+	samFlagDuplicate    = 0x400
+	samFlagSupplemental = 0x800
+)
+
+// knownAssemblies identifies common reference builds by the length of their
+// first chromosome when @SQ AS/UR tags are missing.
+var knownAssemblies = map[int64]string{
+	248956422: "GRCh38",
+	249250621: "GRCh37",
+	195154279: "GRCm39",
+	195471971: "GRCm38",
+}
+
+// pacbioModels maps @RG PM values to PacBio instrument names.
+var pacbioModels = map[string]string{
+	"RS":        "PacBio RS II",
+	"SEQUEL":    "Sequel",
+	"SEQUELII":  "Sequel II",
+	"SEQUELIIE": "Sequel IIe",
+	"REVIO":     "Revio",
+	"VEGA":      "Vega",
+}
+
+// pacbioMovieName matches PacBio movie names: instrument serial, then the
+// movie start date and time (yymmdd_hhmmss), then an optional SMRT Cell
+// position on Revio.
+var pacbioMovieName = regexp.MustCompile(`^m(\d+[a-zA-Z]?)_(\d{6}_\d{6})`)
+
+// BAMExtractor extracts header metadata from SAM, BAM and CRAM files.
+type BAMExtractor struct {
+	// SampleReads enables a sampled pass over up to this many primary
+	// alignment records. Zero reads the header only.
+	SampleReads int
+}
+
+// samHeader holds the parsed SAM header records.
+type samHeader struct {
+	fields     map[string]string
+	references []samReference
+	readGroups []map[string]string
+	programs   []map[string]string
+	comments   int
+}
+
+type samReference struct {
+	name   string
+	length int64
+	tags   map[string]string
+}
+
+// alignmentStats accumulates flag counts over sampled primary alignments.
+type alignmentStats struct {
+	sampled    int
+	mapped     int
+	duplicates int
+	paired     int
+	exhausted  bool
+	// countsOnly is set when records were counted without decoding flags
+	countsOnly bool
+}
+
+// countingReader counts the bytes read from the underlying reader so sampled
+// passes can estimate how much of the file they covered. Readers buffered on
+// top of it subtract what they hold to get the bytes consumed.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// SetSampleReads sets SampleReads, making the extractor a ReadSampler.
+func (e *BAMExtractor) SetSampleReads(n int) {
+	e.SampleReads = n
+}
+
+// Name returns the extractor name.
+func (e *BAMExtractor) Name() string {
+	return "BAM"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *BAMExtractor) SupportedFormats() []string {
+	return []string{".bam", ".sam", ".cram"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *BAMExtractor) CanHandle(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, format := range e.SupportedFormats() {
+		if ext == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Sniff recognises CRAM magic, BGZF blocks holding BAM magic and SAM
+// header lines.
+func (e *BAMExtractor) Sniff(in *SniffInput) (int, string) {
+	switch {
+	case in.hasPrefix(cramMagic):
+		return 100, "CRAM magic"
+	case len(in.Header) >= 16 && in.at(0, "\x1f\x8b\x08\x04") && in.at(12, "BC"):
+		if bytes.HasPrefix(in.gunzipHeader(4), []byte(bamMagic)) {
+			return 100, "BGZF block with BAM magic"
+		}
+	case in.hasPrefix("@HD\t"), in.hasPrefix("@SQ\t"), in.hasPrefix("@RG\t"), in.hasPrefix("@PG\t"):
+		return 90, "SAM header line"
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from a SAM, BAM or CRAM file.
+func (e *BAMExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	metadata, err := e.extractFromReader(f, filepath, size)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		metadata["file_size"] = size
+	}
+	return metadata, nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *BAMExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	return e.extractFromReader(r, filename, 0)
+}
+
+// extractFromReader detects the container format and extracts the header.
+// size is the total file size, or zero when unknown.
+func (e *BAMExtractor) extractFromReader(r io.Reader, filename string, size int64) (map[string]interface{}, error) {
+	cr := &countingReader{r: r}
+	br := bufio.NewReader(cr)
+
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file header: %w", err)
+	}
+
+	var metadata map[string]interface{}
+	switch {
+	case string(magic) == cramMagic:
+		metadata, err = e.extractCRAM(br, cr, size)
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		metadata, err = e.extractBAM(br, cr, size)
+	case magic[0] == '@' || strings.EqualFold(filepath.Ext(filename), ".sam"):
+		metadata, err = e.extractSAM(br, cr, size)
+	default:
+		return nil, fmt.Errorf("not a valid SAM/BAM/CRAM file: unrecognized header")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata["file_name"] = filename
+	metadata["instrument_type"] = "sequencing"
+	metadata["data_type"] = "aligned_reads"
+	return metadata, nil
+}
+
+// extractBAM reads the BGZF-compressed BAM header and optional records.
+func (e *BAMExtractor) extractBAM(r *bufio.Reader, cr *countingReader, size int64) (map[string]interface{}, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open BGZF stream: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+	br := bufio.NewReader(zr)
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != bamMagic {
+		return nil, fmt.Errorf("not a valid BAM file: invalid magic bytes")
+	}
+
+	text, err := readBAMString(br, bamMaxHeaderText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read BAM header text: %w", err)
+	}
+	header := parseSAMHeader(text)
+
+	var nRef int32
+	if err := binary.Read(br, binary.LittleEndian, &nRef); err != nil {
+		return nil, fmt.Errorf("failed to read BAM reference count: %w", err)
+	}
+	if nRef < 0 || nRef > bamMaxReferences {
+		return nil, fmt.Errorf("invalid BAM reference count %d", nRef)
+	}
+	refs := make([]samReference, 0, nRef)
+	for i := int32(0); i < nRef; i++ {
+		name, err := readBAMString(br, 1<<20)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read BAM reference %d: %w", i, err)
+		}
+		var length int32
+		if err := binary.Read(br, binary.LittleEndian, &length); err != nil {
+			return nil, fmt.Errorf("failed to read BAM reference %d: %w", i, err)
+		}
+		refs = append(refs, samReference{name: strings.TrimRight(name, "\x00"), length: int64(length)})
+	}
+	// The binary reference list is authoritative; @SQ lines may be absent
+	if len(header.references) == 0 {
+		header.references = refs
+	}
+
+	metadata := map[string]interface{}{
+		"format":         "BAM",
+		"extractor_name": "bam",
+		"schema_name":    "bam_v1",
+		"compression":    "bgzf",
+	}
+	header.addTo(metadata)
+
+	if e.SampleReads > 0 {
+		// Compressed bytes consumed, less what r has read ahead. The
+		// decompressed read-ahead in br is similar at both ends and cancels.
+		start := cr.n - int64(r.Buffered())
+		stats, err := sampleBAMRecords(br, e.SampleReads)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample BAM records: %w", err)
+		}
+		stats.addTo(metadata, cr.n-int64(r.Buffered())-start, size-start)
+	}
+
+	return metadata, nil
+}
+
+// readBAMString reads an int32 length-prefixed string.
+func readBAMString(r io.Reader, limit int32) (string, error) {
+	var length int32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	if length < 0 || length > limit {
+		return "", fmt.Errorf("invalid string length %d", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// sampleBAMRecords reads up to limit primary alignment records.
+func sampleBAMRecords(br *bufio.Reader, limit int) (*alignmentStats, error) {
+	stats := &alignmentStats{}
+	fixed := make([]byte, 16)
+	for stats.sampled < limit {
+		var blockSize int32
+		if err := binary.Read(br, binary.LittleEndian, &blockSize); err != nil {
+			if err == io.EOF {
+				stats.exhausted = true
+				return stats, nil
+			}
+			return nil, err
+		}
+		if blockSize < int32(len(fixed)) || blockSize > bamMaxRecordSize {
+			return nil, fmt.Errorf("invalid alignment record size %d", blockSize)
+		}
+		if _, err := io.ReadFull(br, fixed); err != nil {
+			return nil, err
+		}
+		if _, err := br.Discard(int(blockSize) - len(fixed)); err != nil {
+			return nil, err
+		}
+		stats.add(binary.LittleEndian.Uint16(fixed[14:16]))
+	}
+
+	// A full sample that ends exactly at EOF still covers the whole file
+	if _, err := br.Peek(1); err == io.EOF {
+		stats.exhausted = true
+	}
+	return stats, nil
+}
+
+// extractSAM reads the plain-text SAM header and optional records.
+func (e *BAMExtractor) extractSAM(br *bufio.Reader, cr *countingReader, size int64) (map[string]interface{}, error) {
+	var headerText strings.Builder
+	for {
+		peek, err := br.Peek(1)
+		if err != nil || peek[0] != '@' {
+			break
+		}
+		line, err := br.ReadString('\n')
+		headerText.WriteString(line)
+		if err != nil {
+			break
+		}
+		if headerText.Len() > bamMaxHeaderText {
+			return nil, fmt.Errorf("SAM header exceeds %d bytes", bamMaxHeaderText)
+		}
+	}
+
+	metadata := map[string]interface{}{
+		"format":         "SAM",
+		"extractor_name": "sam",
+		"schema_name":    "sam_v1",
+		"compression":    "none",
+	}
+	parseSAMHeader(headerText.String()).addTo(metadata)
+
+	if e.SampleReads > 0 {
+		start := cr.n - int64(br.Buffered())
+		stats := &alignmentStats{}
+		for stats.sampled < e.SampleReads {
+			line, err := br.ReadString('\n')
+			if fields := strings.SplitN(line, "\t", 3); len(fields) >= 2 {
+				if flag, perr := strconv.ParseUint(fields[1], 10, 16); perr == nil {
+					stats.add(uint16(flag))
+				}
+			}
+			if err == io.EOF {
+				stats.exhausted = true
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to sample SAM records: %w", err)
+			}
+		}
+		if _, err := br.Peek(1); err == io.EOF {
+			stats.exhausted = true
+		}
+		stats.addTo(metadata, cr.n-int64(br.Buffered())-start, size-start)
+	}
+
+	return metadata, nil
+}
+
+// extractCRAM reads the CRAM file definition and the header container.
+func (e *BAMExtractor) extractCRAM(br *bufio.Reader, cr *countingReader, size int64) (map[string]interface{}, error) {
+	def := make([]byte, cramFileDefinition)
+	if _, err := io.ReadFull(br, def); err != nil {
+		return nil, fmt.Errorf("not a valid CRAM file: %w", err)
+	}
+	major, minor := int(def[4]), int(def[5])
+
+	metadata := map[string]interface{}{
+		"format":         "CRAM",
+		"extractor_name": "cram",
+		"schema_name":    "cram_v1",
+		"cram_version":   fmt.Sprintf("%d.%d", major, minor),
+	}
+	if fileID := strings.TrimRight(string(def[6:]), "\x00"); fileID != "" {
+		metadata["cram_file_id"] = fileID
+	}
+
+	if major < 2 || major > 3 {
+		metadata["extraction_note"] = fmt.Sprintf("CRAM %d.%d headers are not decoded", major, minor)
+		return metadata, nil
+	}
+
+	container, err := readCRAMContainerHeader(br, major)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRAM header container: %w", err)
+	}
+	if container.length < 0 || container.length > cramMaxHeaderBlock {
+		return nil, fmt.Errorf("invalid CRAM header container length %d", container.length)
+	}
+	payload := make([]byte, container.length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, fmt.Errorf("failed to read CRAM header container: %w", err)
+	}
+
+	text, err := decodeCRAMHeaderBlock(payload)
+	if err != nil {
+		return nil, err
+	}
+	parseSAMHeader(text).addTo(metadata)
+
+	if e.SampleReads > 0 {
+		start := cr.n - int64(br.Buffered())
+		stats := &alignmentStats{countsOnly: true}
+		for stats.sampled < e.SampleReads {
+			c, err := readCRAMContainerHeader(br, major)
+			if err == io.EOF {
+				stats.exhausted = true
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CRAM container: %w", err)
+			}
+			if _, err := br.Discard(int(c.length)); err != nil {
+				return nil, fmt.Errorf("failed to skip CRAM container: %w", err)
+			}
+			stats.sampled += int(c.records)
+		}
+		if _, err := br.Peek(1); err == io.EOF {
+			stats.exhausted = true
+		}
+		metadata["cram_sampling_note"] = "CRAM record counts come from container headers; mapping and duplicate rates are not decoded"
+		stats.addTo(metadata, cr.n-int64(br.Buffered())-start, size-start)
+	}
+
+	return metadata, nil
+}
+
+// cramContainer is the subset of a CRAM container header we use.
+type cramContainer struct {
+	length  int32
+	records int32
+}
+
+// readCRAMContainerHeader reads a CRAM 2.x/3.x container header, leaving the
+// reader positioned at the first block.
+func readCRAMContainerHeader(br *bufio.Reader, major int) (*cramContainer, error) {
+	var length int32
+	if err := binary.Read(br, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	c := &cramContainer{length: length}
+
+	// reference sequence id, start, span, number of records
+	for i := 0; i < 4; i++ {
+		v, err := readITF8(br)
+		if err != nil {
+			return nil, err
+		}
+		if i == 3 {
+			c.records = v
+		}
+	}
+	// record counter and base count; CRAM 2.x stores the counter as ITF8
+	if major >= 3 {
+		if _, err := readLTF8(br); err != nil {
+			return nil, err
+		}
+	} else if _, err := readITF8(br); err != nil {
+		return nil, err
+	}
+	if _, err := readLTF8(br); err != nil {
+		return nil, err
+	}
+	if _, err := readITF8(br); err != nil { // number of blocks
+		return nil, err
+	}
+	landmarks, err := readITF8(br)
+	if err != nil {
+		return nil, err
+	}
+	for i := int32(0); i < landmarks; i++ {
+		if _, err := readITF8(br); err != nil {
+			return nil, err
+		}
+	}
+	if major >= 3 {
+		if _, err := br.Discard(4); err != nil { // CRC32
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// decodeCRAMHeaderBlock decodes the first block of the header container.
+func decodeCRAMHeaderBlock(payload []byte) (string, error) {
+	br := bytes.NewReader(payload)
+	method, err := br.ReadByte()
+	if err != nil {
+		return "", fmt.Errorf("failed to read CRAM header block: %w", err)
+	}
+	if _, err := br.ReadByte(); err != nil { // content type
+		return "", fmt.Errorf("failed to read CRAM header block: %w", err)
+	}
+	if _, err := readITF8(br); err != nil { // content id
+		return "", fmt.Errorf("failed to read CRAM header block: %w", err)
+	}
+	compressed, err := readITF8(br)
+	if err != nil {
+		return "", fmt.Errorf("failed to read CRAM header block: %w", err)
+	}
+	if _, err := readITF8(br); err != nil { // raw size
+		return "", fmt.Errorf("failed to read CRAM header block: %w", err)
+	}
+	if compressed < 0 || int(compressed) > br.Len() {
+		return "", fmt.Errorf("invalid CRAM header block size %d", compressed)
+	}
+	data := make([]byte, compressed)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return "", fmt.Errorf("failed to read CRAM header block: %w", err)
+	}
+
+	var raw io.Reader
+	switch method {
+	case 0:
+		raw = bytes.NewReader(data)
+	case 1:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress CRAM header: %w", err)
+		}
+		defer func() { _ = zr.Close() }()
+		raw = zr
+	case 2:
+		raw = bzip2.NewReader(bytes.NewReader(data))
+	default:
+		return "", fmt.Errorf("unsupported CRAM header compression method %d", method)
+	}
+
+	text, err := readBAMString(raw, bamMaxHeaderText)
+	if err != nil {
+		return "", fmt.Errorf("failed to read CRAM header text: %w", err)
+	}
+	return text, nil
+}
+
+// readITF8 reads a CRAM ITF8 variable-length 32-bit integer.
+func readITF8(br io.ByteReader) (int32, error) {
+	b0, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var extra int
+	var value uint32
+	switch {
+	case b0&0x80 == 0:
+		return int32(b0), nil
+	case b0&0x40 == 0:
+		extra, value = 1, uint32(b0&0x3f)
+	case b0&0x20 == 0:
+		extra, value = 2, uint32(b0&0x1f)
+	case b0&0x10 == 0:
+		extra, value = 3, uint32(b0&0x0f)
+	default:
+		// Five-byte form: only the low nibble of the last byte is used
+		value = uint32(b0 & 0x0f)
+		for i := 0; i < 3; i++ {
+			b, err := br.ReadByte()
+			if err != nil {
+				return 0, unexpectedEOF(err)
+			}
+			value = value<<8 | uint32(b)
+		}
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		return int32(value<<4 | uint32(b&0x0f)), nil
+	}
+
+	for i := 0; i < extra; i++ {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		value = value<<8 | uint32(b)
+	}
+	return int32(value), nil
+}
+
+// readLTF8 reads a CRAM LTF8 variable-length 64-bit integer.
+func readLTF8(br io.ByteReader) (int64, error) {
+	b0, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	// The number of leading one bits gives the number of extra bytes
+	extra := 0
+	for extra < 8 && b0&(0x80>>extra) != 0 {
+		extra++
+	}
+	value := uint64(b0 & (0x7f >> extra))
+	for i := 0; i < extra; i++ {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		value = value<<8 | uint64(b)
+	}
+	return int64(value), nil
+}
+
+// unexpectedEOF converts a mid-value EOF into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// parseSAMHeader parses SAM header text into its record types.
+func parseSAMHeader(text string) *samHeader {
+	header := &samHeader{fields: map[string]string{}}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r\x00")
+		if len(line) < 3 || line[0] != '@' {
+			continue
+		}
+		if line[1:3] == "CO" {
+			header.comments++
+			continue
+		}
+
+		tags := map[string]string{}
+		for _, field := range strings.Split(line, "\t")[1:] {
+			if key, value, ok := strings.Cut(field, ":"); ok && len(key) == 2 {
+				tags[key] = value
+			}
+		}
+
+		switch line[1:3] {
+		case "HD":
+			header.fields = tags
+		case "SQ":
+			length, _ := strconv.ParseInt(tags["LN"], 10, 64)
+			header.references = append(header.references, samReference{name: tags["SN"], length: length, tags: tags})
+		case "RG":
+			header.readGroups = append(header.readGroups, tags)
+		case "PG":
+			header.programs = append(header.programs, tags)
+		}
+	}
+	return header
+}
+
+// addTo adds the parsed header fields to metadata.
+func (h *samHeader) addTo(metadata map[string]interface{}) {
+	for tag, key := range map[string]string{"VN": "sam_version", "SO": "sort_order", "GO": "group_order", "SS": "sub_sort_order"} {
+		if value := h.fields[tag]; value != "" {
+			metadata[key] = value
+		}
+	}
+
+	h.addReferences(metadata)
+	h.addReadGroups(metadata)
+	h.addPacBio(metadata)
+	h.addPrograms(metadata)
+
+	if h.comments > 0 {
+		metadata["comment_count"] = h.comments
+	}
+}
+
+// addReferences reports the reference sequences and infers the genome build.
+func (h *samHeader) addReferences(metadata map[string]interface{}) {
+	if len(h.references) == 0 {
+		return
+	}
+
+	var total int64
+	names := make([]string, 0, bamMaxListedRefs)
+	for i, ref := range h.references {
+		total += ref.length
+		if i < bamMaxListedRefs {
+			names = append(names, ref.name)
+		}
+	}
+	metadata["reference_count"] = len(h.references)
+	metadata["reference_length_total"] = total
+	metadata["reference_names"] = names
+	if len(h.references) > bamMaxListedRefs {
+		metadata["reference_names_truncated"] = true
+	}
+
+	first := h.references[0]
+	if species := first.tags["SP"]; species != "" {
+		metadata["species"] = species
+	}
+	if uri := first.tags["UR"]; uri != "" {
+		metadata["reference_uri"] = uri
+	}
+	switch {
+	case first.tags["AS"] != "":
+		metadata["reference_genome"] = first.tags["AS"]
+	case knownAssemblies[first.length] != "":
+		metadata["reference_genome"] = knownAssemblies[first.length]
+	case first.tags["UR"] != "":
+		base := first.tags["UR"][strings.LastIndexAny(first.tags["UR"], "/:")+1:]
+		for _, ext := range []string{".gz", ".fasta", ".fa", ".fna"} {
+			base = strings.TrimSuffix(base, ext)
+		}
+		metadata["reference_genome"] = base
+	}
+}
+
+// addReadGroups reports read groups and the distinct samples, libraries and
+// platforms they name.
+func (h *samHeader) addReadGroups(metadata map[string]interface{}) {
+	if len(h.readGroups) == 0 {
+		return
+	}
+
+	rgFields := map[string]string{
+		"ID": "id", "SM": "sample", "LB": "library", "PL": "platform",
+		"PU": "platform_unit", "PM": "platform_model", "CN": "center",
+		"DS": "description", "DT": "run_date", "PI": "insert_size", "BC": "barcode",
+	}
+
+	var samples, libraries, platforms []string
+	groups := make([]map[string]interface{}, 0, len(h.readGroups))
+	for _, tags := range h.readGroups {
+		group := map[string]interface{}{}
+		for tag, key := range rgFields {
+			if value := tags[tag]; value != "" {
+				group[key] = value
+			}
+		}
+		groups = append(groups, group)
+		samples = appendUnique(samples, tags["SM"])
+		libraries = appendUnique(libraries, tags["LB"])
+		platforms = appendUnique(platforms, strings.ToUpper(tags["PL"]))
+	}
+
+	metadata["read_groups"] = groups
+	metadata["read_group_count"] = len(groups)
+	if len(samples) > 0 {
+		metadata["samples"] = samples
+	}
+	if len(samples) == 1 {
+		metadata["sample_name"] = samples[0]
+	}
+	if len(libraries) > 0 {
+		metadata["libraries"] = libraries
+	}
+	if len(platforms) == 1 {
+		metadata["sequencing_platform"] = platforms[0]
+	} else if len(platforms) > 1 {
+		metadata["sequencing_platforms"] = platforms
+	}
+}
+
+// addPacBio decodes PacBio read group descriptions and movie names. Kits
+// and movies are listed when the file merges several SMRT Cells; the first
+// PacBio read group fills SequencingMetadata.
+func (h *samHeader) addPacBio(metadata map[string]interface{}) {
+	var first map[string]string
+	var movies, readTypes, bindingKits, sequencingKits []string
+	for _, tags := range h.readGroups {
+		if !strings.EqualFold(tags["PL"], "PACBIO") {
+			continue
+		}
+		if first == nil {
+			first = tags
+		}
+		ds := parsePacBioDescription(tags["DS"])
+		movies = appendUnique(movies, tags["PU"])
+		readTypes = appendUnique(readTypes, strings.ToUpper(ds["READTYPE"]))
+		bindingKits = appendUnique(bindingKits, ds["BINDINGKIT"])
+		sequencingKits = appendUnique(sequencingKits, ds["SEQUENCINGKIT"])
+	}
+	if first == nil {
+		return
+	}
+
+	ds := parsePacBioDescription(first["DS"])
+	model := pacbioModels[strings.ToUpper(first["PM"])]
+	if model == "" {
+		model = first["PM"]
+	}
+	sm := &SequencingMetadata{
+		Platform: "PacBio",
+		Model:    model,
+		RunID:    first["PU"],
+	}
+	if match := pacbioMovieName.FindStringSubmatch(first["PU"]); match != nil {
+		sm.SerialNumber = match[1]
+		// Movie names use the instrument's local time.
+		if t, err := time.Parse("060102_150405", match[2]); err == nil {
+			metadata["movie_start_time"] = t.Format("2006-01-02T15:04:05")
+		}
+	}
+	sequencingFields(sm, metadata)
+	metadata["manufacturer"] = "Pacific Biosciences"
+
+	listOrSingle := func(single, plural string, values []string) {
+		switch {
+		case len(values) == 1:
+			metadata[single] = values[0]
+		case len(values) > 1:
+			metadata[single] = values[0]
+			metadata[plural] = values
+		}
+	}
+	listOrSingle("movie_name", "movie_names", movies)
+	listOrSingle("pacbio_read_type", "pacbio_read_types", readTypes)
+	listOrSingle("binding_kit", "binding_kits", bindingKits)
+	listOrSingle("sequencing_chemistry", "sequencing_chemistries", sequencingKits)
+	if len(readTypes) > 0 {
+		metadata["hifi"] = readTypes[0] == "CCS"
+	}
+	if version := ds["BASECALLERVERSION"]; version != "" {
+		metadata["basecaller_version"] = version
+	}
+	if rate, err := strconv.ParseFloat(ds["FRAMERATEHZ"], 64); err == nil {
+		metadata["frame_rate_hz"] = rate
+	}
+}
+
+// parsePacBioDescription splits a PacBio @RG DS value into its KEY=VALUE
+// pairs. Codec entries such as "Ipd:CodecV1=ip" are kept under their full key.
+func parsePacBioDescription(ds string) map[string]string {
+	fields := map[string]string{}
+	for _, part := range strings.Split(ds, ";") {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			fields[strings.ToUpper(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// addPrograms reports @PG records and the program chain that produced the
+// file, from the original aligner to the most recent tool.
+func (h *samHeader) addPrograms(metadata map[string]interface{}) {
+	if len(h.programs) == 0 {
+		return
+	}
+
+	pgFields := map[string]string{
+		"ID": "id", "PN": "name", "VN": "version", "CL": "command_line",
+		"PP": "previous_id", "DS": "description",
+	}
+	programs := make([]map[string]interface{}, 0, len(h.programs))
+	byID := make(map[string]map[string]string, len(h.programs))
+	referenced := map[string]bool{}
+	for _, tags := range h.programs {
+		program := map[string]interface{}{}
+		for tag, key := range pgFields {
+			if value := tags[tag]; value != "" {
+				program[key] = value
+			}
+		}
+		programs = append(programs, program)
+		byID[tags["ID"]] = tags
+		if tags["PP"] != "" {
+			referenced[tags["PP"]] = true
+		}
+	}
+	metadata["programs"] = programs
+
+	// Walk back from the first program nobody else lists as PP
+	leaf := h.programs[0]
+	for _, tags := range h.programs {
+		if !referenced[tags["ID"]] {
+			leaf = tags
+			break
+		}
+	}
+	var chain []map[string]string
+	seen := map[string]bool{}
+	for tags := leaf; tags != nil && !seen[tags["ID"]]; tags = byID[tags["PP"]] {
+		seen[tags["ID"]] = true
+		chain = append([]map[string]string{tags}, chain...)
+	}
+
+	names := make([]string, 0, len(chain))
+	for _, tags := range chain {
+		name := tags["PN"]
+		if name == "" {
+			name = tags["ID"]
+		}
+		if tags["VN"] != "" {
+			name += " " + tags["VN"]
+		}
+		names = append(names, name)
+	}
+	metadata["program_chain"] = names
+
+	root := chain[0]
+	if name := root["PN"]; name != "" {
+		metadata["aligner"] = name
+	} else {
+		metadata["aligner"] = root["ID"]
+	}
+	if root["VN"] != "" {
+		metadata["aligner_version"] = root["VN"]
+	}
+}
+
+// add records one alignment's flags, ignoring secondary and supplementary
+// alignments so each read is counted once.
+func (s *alignmentStats) add(flag uint16) {
+	if flag&(samFlagSecondary|samFlagSupplemental) != 0 {
+		return
+	}
+	s.sampled++
+	if flag&samFlagUnmapped == 0 {
+		s.mapped++
+	}
+	if flag&samFlagDuplicate != 0 {
+		s.duplicates++
+	}
+	if flag&samFlagPaired != 0 {
+		s.paired++
+	}
+}
+
+// addTo adds sampled statistics to metadata. consumed is the number of
+// record bytes read and remaining the number of record bytes in the file.
+func (s *alignmentStats) addTo(metadata map[string]interface{}, consumed, remaining int64) {
+	metadata["sampled_reads"] = s.sampled
+	switch {
+	case s.exhausted:
+		metadata["read_count"] = s.sampled
+	case consumed > 0 && remaining > consumed:
+		metadata["estimated_read_count"] = int64(float64(s.sampled) * float64(remaining) / float64(consumed))
+	}
+
+	if s.sampled == 0 || s.countsOnly {
+		return
+	}
+	metadata["mapping_rate_percent"] = float64(s.mapped) / float64(s.sampled) * 100
+	metadata["duplicate_rate_percent"] = float64(s.duplicates) / float64(s.sampled) * 100
+	metadata["paired_rate_percent"] = float64(s.paired) / float64(s.sampled) * 100
+}
+
+// appendUnique appends value to list if it is non-empty and not present.
+func appendUnique(list []string, value string) []string {
+	if value == "" {
+		return list
+	}
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
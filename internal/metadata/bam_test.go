@@ -0,0 +1,390 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSAMHeader = "@HD\tVN:1.6\tSO:coordinate\n" +
+	"@SQ\tSN:chr1\tLN:248956422\n" +
+	"@SQ\tSN:chr2\tLN:242193529\n" +
+	"@RG\tID:run1.L001\tSM:NA12878\tLB:lib1\tPL:illumina\tPU:HXXXXXXXX.1\n" +
+	"@RG\tID:run1.L002\tSM:NA12878\tLB:lib1\tPL:ILLUMINA\tPU:HXXXXXXXX.2\n" +
+	"@PG\tID:bwa\tPN:bwa\tVN:0.7.17-r1188\tCL:bwa mem ref.fa r1.fq r2.fq\n" +
+	"@PG\tID:samtools\tPN:samtools\tPP:bwa\tVN:1.17\tCL:samtools sort\n" +
+	"@PG\tID:MarkDuplicates\tPN:MarkDuplicates\tPP:samtools\tVN:3.0.0\n" +
+	"@CO\tsequenced at the example core\n"
+
+// testSAMFlags are the flags of the records written by the test builders:
+// mapped pair, mapped duplicate, unmapped, secondary (ignored).
+var testSAMFlags = []uint16{0x1 | 0x2, 0x1 | 0x400, 0x1 | 0x4, 0x100}
+
+func buildTestSAM() string {
+	var b strings.Builder
+	b.WriteString(testSAMHeader)
+	for i, flag := range testSAMFlags {
+		fmt.Fprintf(&b, "read%d\t%d\tchr1\t100\t60\t4M\t=\t200\t100\tACGT\tIIII\n", i, flag)
+	}
+	return b.String()
+}
+
+func buildTestBAM(t *testing.T) []byte {
+	t.Helper()
+	var records [][]byte
+	for _, flag := range testSAMFlags {
+		record := make([]byte, 32)
+		binary.LittleEndian.PutUint16(record[14:16], flag)
+		records = append(records, record)
+	}
+	return buildTestBAMRecords(t, records)
+}
+
+// buildTestBAMRecords writes a BAM file with the test header and records.
+func buildTestBAMRecords(t *testing.T, records [][]byte) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+	var raw bytes.Buffer
+	raw.WriteString(bamMagic)
+	_ = binary.Write(&raw, le, int32(len(testSAMHeader)))
+	raw.WriteString(testSAMHeader)
+	_ = binary.Write(&raw, le, int32(2))
+	for _, ref := range []struct {
+		name   string
+		length int32
+	}{{"chr1", 248956422}, {"chr2", 242193529}} {
+		_ = binary.Write(&raw, le, int32(len(ref.name)+1))
+		raw.WriteString(ref.name + "\x00")
+		_ = binary.Write(&raw, le, ref.length)
+	}
+
+	// Like BGZF, the stream is a series of gzip members: one for the header
+	// and one per record
+	var buf bytes.Buffer
+	member := func(data []byte) {
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+	}
+	member(raw.Bytes())
+	for _, record := range records {
+		raw.Reset()
+		_ = binary.Write(&raw, le, int32(len(record)))
+		raw.Write(record)
+		member(raw.Bytes())
+	}
+	return buf.Bytes()
+}
+
+func buildTestCRAM(t *testing.T, records int) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+
+	// Header block: raw method, FILE_HEADER content type, id 0
+	var text bytes.Buffer
+	_ = binary.Write(&text, le, int32(len(testSAMHeader)))
+	text.WriteString(testSAMHeader)
+	var block bytes.Buffer
+	block.Write([]byte{0, 0, 0})
+	block.Write(encodeITF8(int32(text.Len())))
+	block.Write(encodeITF8(int32(text.Len())))
+	block.Write(text.Bytes())
+	block.Write([]byte{0, 0, 0, 0}) // CRC32
+
+	var buf bytes.Buffer
+	buf.WriteString(cramMagic)
+	buf.Write([]byte{3, 0})
+	buf.Write(append([]byte("test.cram"), make([]byte, 11)...))
+
+	writeContainer := func(nRecords int32, payload []byte) {
+		_ = binary.Write(&buf, le, int32(len(payload)))
+		buf.Write(encodeITF8(0))        // ref seq id
+		buf.Write(encodeITF8(0))        // start
+		buf.Write(encodeITF8(0))        // span
+		buf.Write(encodeITF8(nRecords)) // records
+		buf.Write([]byte{0, 0})         // record counter, bases (LTF8)
+		buf.Write(encodeITF8(1))        // blocks
+		buf.Write(encodeITF8(0))        // landmarks
+		buf.Write([]byte{0, 0, 0, 0})   // CRC32
+		buf.Write(payload)
+	}
+	writeContainer(0, block.Bytes())
+	if records > 0 {
+		writeContainer(int32(records), make([]byte, 64))
+		writeContainer(int32(records), make([]byte, 64))
+	}
+	return buf.Bytes()
+}
+
+func encodeITF8(v int32) []byte {
+	u := uint32(v)
+	switch {
+	case u < 0x80:
+		return []byte{byte(u)}
+	case u < 0x4000:
+		return []byte{byte(u>>8) | 0x80, byte(u)}
+	case u < 0x200000:
+		return []byte{byte(u>>16) | 0xc0, byte(u >> 8), byte(u)}
+	case u < 0x10000000:
+		return []byte{byte(u>>24) | 0xe0, byte(u >> 16), byte(u >> 8), byte(u)}
+	default:
+		return []byte{byte(u>>28) | 0xf0, byte(u >> 20), byte(u >> 12), byte(u >> 4), byte(u & 0x0f)}
+	}
+}
+
+func checkSAMHeaderFields(t *testing.T, metadata map[string]interface{}) {
+	t.Helper()
+	checks := map[string]interface{}{
+		"sam_version":         "1.6",
+		"sort_order":          "coordinate",
+		"reference_count":     2,
+		"reference_genome":    "GRCh38",
+		"sample_name":         "NA12878",
+		"sequencing_platform": "ILLUMINA",
+		"read_group_count":    2,
+		"aligner":             "bwa",
+		"aligner_version":     "0.7.17-r1188",
+		"comment_count":       1,
+		"instrument_type":     "sequencing",
+	}
+	for key, want := range checks {
+		if metadata[key] != want {
+			t.Errorf("%s = %v, want %v", key, metadata[key], want)
+		}
+	}
+
+	chain := metadata["program_chain"].([]string)
+	want := []string{"bwa 0.7.17-r1188", "samtools 1.17", "MarkDuplicates 3.0.0"}
+	if strings.Join(chain, "|") != strings.Join(want, "|") {
+		t.Errorf("program_chain = %v, want %v", chain, want)
+	}
+
+	groups := metadata["read_groups"].([]map[string]interface{})
+	if groups[1]["platform_unit"] != "HXXXXXXXX.2" || groups[0]["library"] != "lib1" {
+		t.Errorf("read_groups = %v", groups)
+	}
+}
+
+func TestBAMExtractor_CanHandle(t *testing.T) {
+	extractor := &BAMExtractor{}
+	for _, name := range []string{"sample.bam", "sample.SAM", "sample.cram"} {
+		if !extractor.CanHandle(name) {
+			t.Errorf("CanHandle(%q) = false, want true", name)
+		}
+	}
+	if extractor.CanHandle("sample.bam.bai") {
+		t.Error("CanHandle() should reject index files")
+	}
+}
+
+func TestBAMExtractor_BAM(t *testing.T) {
+	data := buildTestBAM(t)
+
+	t.Run("header only", func(t *testing.T) {
+		extractor := &BAMExtractor{}
+		metadata, err := extractor.ExtractFromReader(bytes.NewReader(data), "sample.bam")
+		if err != nil {
+			t.Fatalf("ExtractFromReader() error = %v", err)
+		}
+		if metadata["format"] != "BAM" || metadata["compression"] != "bgzf" {
+			t.Errorf("format = %v, compression = %v", metadata["format"], metadata["compression"])
+		}
+		checkSAMHeaderFields(t, metadata)
+		if _, ok := metadata["sampled_reads"]; ok {
+			t.Error("sampled_reads should not be set without SampleReads")
+		}
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		extractor := &BAMExtractor{SampleReads: 100}
+		metadata, err := extractor.ExtractFromReader(bytes.NewReader(data), "sample.bam")
+		if err != nil {
+			t.Fatalf("ExtractFromReader() error = %v", err)
+		}
+		if metadata["read_count"] != 3 {
+			t.Errorf("read_count = %v, want 3", metadata["read_count"])
+		}
+		rate := metadata["mapping_rate_percent"].(float64)
+		if rate < 66.6 || rate > 66.7 {
+			t.Errorf("mapping_rate_percent = %v, want 66.67", rate)
+		}
+		dup := metadata["duplicate_rate_percent"].(float64)
+		if dup < 33.3 || dup > 33.4 {
+			t.Errorf("duplicate_rate_percent = %v, want 33.33", dup)
+		}
+	})
+}
+
+func TestBAMExtractor_BAMEstimatedReadCount(t *testing.T) {
+	// Incompressible records, so the sample covers a small part of the file
+	rng := rand.New(rand.NewSource(1))
+	records := make([][]byte, 2000)
+	for i := range records {
+		records[i] = make([]byte, 64)
+		rng.Read(records[i])
+		binary.LittleEndian.PutUint16(records[i][14:16], 0x1|0x2)
+	}
+	path := filepath.Join(t.TempDir(), "sample.bam")
+	if err := os.WriteFile(path, buildTestBAMRecords(t, records), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := (&BAMExtractor{SampleReads: 100}).Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	estimate, _ := metadata["estimated_read_count"].(int64)
+	if estimate < 1960 || estimate > 2040 {
+		t.Errorf("estimated_read_count = %v, want about 2000", metadata["estimated_read_count"])
+	}
+}
+
+func TestExtractorRegistry_SetSampleReads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample.sam")
+	if err := os.WriteFile(path, []byte(buildTestSAM()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	registry.SetSampleReads(2)
+	metadata, err := registry.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if metadata["sampled_reads"] != 2 {
+		t.Errorf("sampled_reads = %v, want 2", metadata["sampled_reads"])
+	}
+
+	registry.SetSampleReads(0)
+	if metadata, _ := registry.Extract(path); metadata["sampled_reads"] != nil {
+		t.Errorf("sampled_reads = %v after SetSampleReads(0), want header only", metadata["sampled_reads"])
+	}
+}
+
+func TestBAMExtractor_SAM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample.sam")
+	if err := os.WriteFile(path, []byte(buildTestSAM()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extractor := &BAMExtractor{SampleReads: 2}
+	metadata, err := extractor.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if metadata["format"] != "SAM" {
+		t.Errorf("format = %v, want SAM", metadata["format"])
+	}
+	checkSAMHeaderFields(t, metadata)
+
+	// Two of three primary reads sampled, so the count is extrapolated
+	if metadata["sampled_reads"] != 2 {
+		t.Errorf("sampled_reads = %v, want 2", metadata["sampled_reads"])
+	}
+	if _, ok := metadata["estimated_read_count"]; !ok {
+		t.Error("estimated_read_count should be set for a partial sample")
+	}
+}
+
+func TestBAMExtractor_CRAM(t *testing.T) {
+	extractor := &BAMExtractor{SampleReads: 1000}
+	metadata, err := extractor.ExtractFromReader(bytes.NewReader(buildTestCRAM(t, 250)), "sample.cram")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	if metadata["format"] != "CRAM" || metadata["cram_version"] != "3.0" || metadata["cram_file_id"] != "test.cram" {
+		t.Errorf("format = %v, cram_version = %v, cram_file_id = %v",
+			metadata["format"], metadata["cram_version"], metadata["cram_file_id"])
+	}
+	checkSAMHeaderFields(t, metadata)
+	if metadata["read_count"] != 500 {
+		t.Errorf("read_count = %v, want 500", metadata["read_count"])
+	}
+	if _, ok := metadata["mapping_rate_percent"]; ok {
+		t.Error("mapping_rate_percent should not be reported for CRAM")
+	}
+}
+
+func TestBAMExtractor_PacBio(t *testing.T) {
+	sam := "@HD\tVN:1.6\tSO:unknown\tpb:5.0.0\n" +
+		"@RG\tID:e3a5a4c2/0--0\tPL:PACBIO\tDS:READTYPE=CCS;Ipd:CodecV1=ip;PulseWidth:CodecV1=pw;" +
+		"BINDINGKIT=101-894-200;SEQUENCINGKIT=101-826-100;BASECALLERVERSION=5.0.0;FRAMERATEHZ=100.000000\t" +
+		"LB:lib_hifi\tPU:m64011_190830_220126\tSM:HG002\tPM:SEQUELII\tCM:S/P4-C2/5.0-8M\n" +
+		"@PG\tID:ccs\tPN:ccs\tVN:6.4.0\n"
+
+	metadata, err := (&BAMExtractor{}).ExtractFromReader(strings.NewReader(sam), "movie.hifi_reads.bam")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"platform":             "PacBio",
+		"manufacturer":         "Pacific Biosciences",
+		"instrument_model":     "Sequel II",
+		"serial_number":        "64011",
+		"run_id":               "m64011_190830_220126",
+		"movie_name":           "m64011_190830_220126",
+		"movie_start_time":     "2019-08-30T22:01:26",
+		"pacbio_read_type":     "CCS",
+		"hifi":                 true,
+		"binding_kit":          "101-894-200",
+		"sequencing_chemistry": "101-826-100",
+		"basecaller_version":   "5.0.0",
+		"frame_rate_hz":        100.0,
+		"sample_name":          "HG002",
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+
+	if result := pacbioSequelPreset().Validate(metadata); !result.IsValid || len(result.Warnings) > 0 {
+		t.Errorf("pacbio-sequel validation: errors %v, warnings %v", result.Errors, result.Warnings)
+	}
+}
+
+func TestBAMExtractor_Invalid(t *testing.T) {
+	extractor := &BAMExtractor{}
+	if _, err := extractor.ExtractFromReader(strings.NewReader("\x00\x01\x02\x03"), "sample.bam"); err == nil {
+		t.Error("ExtractFromReader() should return error for unrecognized data")
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte("not a bam"))
+	_ = zw.Close()
+	if _, err := extractor.ExtractFromReader(&buf, "sample.bam"); err == nil {
+		t.Error("ExtractFromReader() should return error for gzip data without BAM magic")
+	}
+}
+
+func TestReadITF8(t *testing.T) {
+	for _, v := range []int32{0, 127, 128, 16383, 16384, 2097151, 2097152, 268435455, 268435456, -1} {
+		got, err := readITF8(bytes.NewReader(encodeITF8(v)))
+		if err != nil || got != v {
+			t.Errorf("readITF8(encode(%d)) = %d, %v", v, got, err)
+		}
+	}
+}
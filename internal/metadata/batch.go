@@ -0,0 +1,465 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file implements batch extraction: expanding directories and globs
+// into a file list, extracting it with a worker pool, and skipping files
+// whose checksum matches the extract cache.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SidecarSuffix is appended to a file's path to name the metadata sidecar
+// batch extraction writes. It differs from RecordSuffix so that sync never
+// confuses a sidecar with a metadata store record. Files with either suffix
+// are never picked up as batch inputs.
+const SidecarSuffix = ".extract.json"
+
+// EnrichmentSuffix is appended to a file's path to name the enrichment
+// YAML that 'doi prepare --enrich' reads. Like sidecars, these files are
+// never batch inputs.
+const EnrichmentSuffix = ".enrichment.yaml"
+
+// BatchOptions configures ExtractBatch.
+type BatchOptions struct {
+	// Workers is the number of files extracted in parallel. Zero means one
+	// per CPU.
+	Workers int
+
+	// Cache, if set, supplies metadata for unchanged files and records
+	// the results of new extractions.
+	Cache *ExtractCache
+
+	// ResultFunc is called once per path, in input order.
+	ResultFunc func(BatchResult)
+}
+
+// BatchResult is the outcome for one path in a batch.
+type BatchResult struct {
+	Path     string                 `json:"path"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Cached   bool                   `json:"cached,omitempty"`
+}
+
+// BatchSummary counts the outcomes of a batch.
+type BatchSummary struct {
+	Total     int `json:"total"`
+	Extracted int `json:"extracted"`
+	Cached    int `json:"cached"`
+	Failed    int `json:"failed"`
+}
+
+// BatchInputs expands files, directories and glob patterns into the list
+// of paths to extract. Directories are walked recursively, except that a
+// directory claimed by an extractor (such as a Zarr store) is one input.
+// Hidden files and directories and metadata sidecars are skipped.
+//
+// Include and exclude patterns are matched against both the base name and
+// the slash-separated path relative to the input; "dir/**" matches
+// everything under dir. They apply to walked and globbed files; paths named
+// explicitly are always included.
+func (r *ExtractorRegistry) BatchInputs(inputs, include, exclude []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, input := range inputs {
+		if _, err := os.Stat(input); err != nil && strings.ContainsAny(input, "*?[") {
+			matches, err := filepath.Glob(input)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", input, err)
+			}
+			for _, match := range matches {
+				if globMatchesHidden(input, match) {
+					continue
+				}
+				if err := r.walkBatchInput(match, include, exclude, true, add); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := r.walkBatchInput(input, include, exclude, false, add); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// walkBatchInput adds input, or the files under it if it is a directory.
+func (r *ExtractorRegistry) walkBatchInput(input string, include, exclude []string, filter bool, add func(string)) error {
+	info, err := os.Stat(input)
+	if err != nil {
+		return fmt.Errorf("file not found: %s", input)
+	}
+	if !info.IsDir() || r.HandlesDirectory(input) {
+		if !filter || batchPathSelected(filepath.Base(input), include, exclude) {
+			add(input)
+		}
+		return nil
+	}
+
+	return filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == input {
+			return nil
+		}
+		rel, err := filepath.Rel(input, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if strings.HasPrefix(d.Name(), ".") || strings.HasSuffix(d.Name(), SidecarSuffix) || strings.HasSuffix(d.Name(), RecordSuffix) ||
+			strings.HasSuffix(d.Name(), EnrichmentSuffix) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if matchBatchPattern(exclude, rel) {
+				return filepath.SkipDir
+			}
+			if r.HandlesDirectory(path) {
+				if batchPathSelected(rel, include, exclude) {
+					add(path)
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && batchPathSelected(rel, include, exclude) {
+			add(path)
+		}
+		return nil
+	})
+}
+
+// HandlesDirectory reports whether an extractor other than the generic
+// fallback handles the directory as a whole.
+func (r *ExtractorRegistry) HandlesDirectory(path string) bool {
+	detections := r.Detect(path)
+	return len(detections) > 0 && detections[0].Confidence > confidenceFallback
+}
+
+// globMatchesHidden reports whether a wildcard in pattern matched a hidden
+// name in match. Unlike a shell, filepath.Glob lets "*" match a leading dot.
+func globMatchesHidden(pattern, match string) bool {
+	patternParts := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	matchParts := strings.Split(filepath.ToSlash(filepath.Clean(match)), "/")
+	for i, part := range matchParts {
+		if i < len(patternParts) && strings.HasPrefix(part, ".") && !strings.HasPrefix(patternParts[i], ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// batchPathSelected applies include and exclude patterns to a relative path.
+func batchPathSelected(rel string, include, exclude []string) bool {
+	if matchBatchPattern(exclude, rel) {
+		return false
+	}
+	return len(include) == 0 || matchBatchPattern(include, rel)
+}
+
+// matchBatchPattern reports whether any pattern matches the relative path
+// or its base name.
+func matchBatchPattern(patterns []string, rel string) bool {
+	base := filepath.Base(rel)
+	for _, pattern := range patterns {
+		if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if rel == dir || strings.HasPrefix(rel, dir+"/") || strings.Contains(rel, "/"+dir+"/") {
+				return true
+			}
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, base); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractBatch extracts every path with a pool of workers. Failures are
+// recorded per path rather than stopping the batch. Results are passed to
+// ResultFunc in input order as soon as all earlier paths are done. Paths
+// not started before ctx is cancelled are reported with its error.
+func (r *ExtractorRegistry) ExtractBatch(ctx context.Context, paths []string, options BatchOptions) BatchSummary {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]BatchResult, len(paths))
+	jobs := make(chan int)
+	done := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = r.extractBatchPath(paths[i], options.Cache)
+				done <- i
+			}
+		}()
+	}
+	go func() {
+	feed:
+		for i := range paths {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(done)
+	}()
+
+	summary := BatchSummary{Total: len(paths)}
+	emit := func(result BatchResult) {
+		switch {
+		case result.Error != "":
+			summary.Failed++
+		case result.Cached:
+			summary.Cached++
+		default:
+			summary.Extracted++
+		}
+		if options.ResultFunc != nil {
+			options.ResultFunc(result)
+		}
+	}
+
+	ready := make([]bool, len(paths))
+	next := 0
+	for i := range done {
+		ready[i] = true
+		for next < len(paths) && ready[next] {
+			emit(results[next])
+			next++
+		}
+	}
+	for ; next < len(paths); next++ {
+		if !ready[next] {
+			results[next] = BatchResult{Path: paths[next], Error: ctx.Err().Error()}
+		}
+		emit(results[next])
+	}
+	return summary
+}
+
+// extractBatchPath extracts one path, consulting the cache first. A panic
+// in an extractor is reported as the path's error so the batch carries on.
+func (r *ExtractorRegistry) extractBatchPath(path string, cache *ExtractCache) (result BatchResult) {
+	result = BatchResult{Path: path}
+	defer func() {
+		if p := recover(); p != nil {
+			result = BatchResult{Path: path, Error: fmt.Sprintf("extractor panicked: %v", p)}
+		}
+	}()
+
+	var checksum string
+	if cache != nil {
+		metadata, sum, err := cache.lookup(path)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		if metadata != nil {
+			result.Metadata = metadata
+			result.Cached = true
+			return result
+		}
+		checksum = sum
+	}
+
+	metadata, err := r.Extract(path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Metadata = metadata
+	if cache != nil && checksum != "" {
+		cache.store(path, checksum, metadata)
+	}
+	return result
+}
+
+// ExtractCache maps files to the checksum and metadata of their last
+// extraction. A file whose size and modification time are unchanged is a
+// hit without reading it; otherwise its SHA-256 is compared, so touched but
+// unchanged files are still skipped. Directories are never cached.
+type ExtractCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]*extractCacheEntry
+}
+
+// extractCacheEntry is one file's cached extraction.
+type extractCacheEntry struct {
+	Size     int64                  `json:"size"`
+	ModTime  time.Time              `json:"mod_time"`
+	SHA256   string                 `json:"sha256"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// DefaultExtractCachePath returns ~/.cicada/cache/extract.json.
+func DefaultExtractCachePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home directory: %w", err)
+	}
+	return filepath.Join(home, ".cicada", "cache", "extract.json"), nil
+}
+
+// LoadExtractCache reads the cache at path. A missing file gives an empty
+// cache.
+func LoadExtractCache(path string) (*ExtractCache, error) {
+	cache := &ExtractCache{path: path, entries: make(map[string]*extractCacheEntry)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read extract cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, fmt.Errorf("parse extract cache %s: %w", path, err)
+	}
+	return cache, nil
+}
+
+// Save writes the cache back to its file.
+func (c *ExtractCache) Save() error {
+	c.mu.Lock()
+	data, err := json.Marshal(c.entries)
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshal extract cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("create cache directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write extract cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("write extract cache: %w", err)
+	}
+	return nil
+}
+
+// lookup returns the cached metadata for an unchanged file. On a miss it
+// returns the file's checksum for store; directories return neither.
+func (c *ExtractCache) lookup(path string) (map[string]interface{}, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("file not found: %s", path)
+	}
+	if info.IsDir() {
+		return nil, "", nil
+	}
+	key := cacheKey(path)
+
+	c.mu.Lock()
+	entry := c.entries[key]
+	c.mu.Unlock()
+	if entry != nil && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return entry.Metadata, "", nil
+	}
+
+	checksum, err := fileSHA256(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if entry != nil && entry.Size == info.Size() && entry.SHA256 == checksum {
+		c.mu.Lock()
+		entry.ModTime = info.ModTime()
+		c.mu.Unlock()
+		return entry.Metadata, "", nil
+	}
+	return nil, checksum, nil
+}
+
+// store records a fresh extraction.
+func (c *ExtractCache) store(path, checksum string, metadata map[string]interface{}) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey(path)] = &extractCacheEntry{
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		SHA256:   checksum,
+		Metadata: metadata,
+	}
+}
+
+// cacheKey is the absolute form of path.
+func cacheKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// fileSHA256 returns the hex SHA-256 of a file's contents.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("checksum %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,254 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBatchFASTQ = "@r1\nACGT\n+\nIIII\n"
+
+// writeBatchTree writes files (relative path to content) under root.
+func writeBatchTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExtractorRegistry_BatchInputs(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	root := t.TempDir()
+	writeBatchTree(t, root, map[string]string{
+		"a.fastq":                    testBatchFASTQ,
+		"a.fastq" + SidecarSuffix:    "{}",
+		"a.fastq" + RecordSuffix:     "{}",
+		"notes.txt":                  "notes",
+		".hidden/x.fastq":            testBatchFASTQ,
+		"scratch/c.fastq":            testBatchFASTQ,
+		"sub/b.fastq":                testBatchFASTQ,
+		"plate.zarr/.zgroup":         `{"zarr_format": 2}`,
+		"plate.zarr/0/.zarray":       `{"zarr_format": 2}`,
+		"plate.zarr/0/0.0":           "chunk",
+		"other/sample.fastq":         testBatchFASTQ,
+		"other/sample.fastq.gz.part": "partial",
+	})
+	rel := func(paths []string) []string {
+		out := make([]string, len(paths))
+		for i, p := range paths {
+			r, _ := filepath.Rel(root, p)
+			out[i] = filepath.ToSlash(r)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		inputs  []string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "directory",
+			inputs:  []string{root},
+			exclude: []string{"scratch/**", "*.part"},
+			want:    []string{"a.fastq", "notes.txt", "other/sample.fastq", "plate.zarr", "sub/b.fastq"},
+		},
+		{
+			name:    "include",
+			inputs:  []string{root},
+			include: []string{"*.fastq"},
+			exclude: []string{"other/**"},
+			want:    []string{"a.fastq", "scratch/c.fastq", "sub/b.fastq"},
+		},
+		{
+			name:   "glob",
+			inputs: []string{filepath.Join(root, "*", "*.fastq")},
+			want:   []string{"other/sample.fastq", "scratch/c.fastq", "sub/b.fastq"},
+		},
+		{
+			name:    "explicit file ignores filters",
+			inputs:  []string{filepath.Join(root, "notes.txt"), filepath.Join(root, "notes.txt")},
+			include: []string{"*.fastq"},
+			want:    []string{"notes.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := registry.BatchInputs(tt.inputs, tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("BatchInputs() error = %v", err)
+			}
+			if got := rel(paths); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BatchInputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := registry.BatchInputs([]string{filepath.Join(root, "missing")}, nil, nil); err == nil {
+		t.Error("BatchInputs(missing) error = nil, want error")
+	}
+}
+
+func TestExtractorRegistry_ExtractBatch(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	root := t.TempDir()
+	var paths []string
+	for _, name := range []string{"1.fastq", "2.fastq", "3.fastq", "4.fastq", "5.fastq"} {
+		writeBatchTree(t, root, map[string]string{name: testBatchFASTQ})
+		paths = append(paths, filepath.Join(root, name))
+	}
+	// A file that disappears after listing fails without stopping the batch
+	paths = append(paths[:2], append([]string{filepath.Join(root, "gone.fastq")}, paths[2:]...)...)
+
+	var got []BatchResult
+	summary := registry.ExtractBatch(context.Background(), paths, BatchOptions{
+		Workers:    3,
+		ResultFunc: func(r BatchResult) { got = append(got, r) },
+	})
+
+	if want := (BatchSummary{Total: 6, Extracted: 5, Failed: 1}); summary != want {
+		t.Errorf("ExtractBatch() summary = %+v, want %+v", summary, want)
+	}
+	if len(got) != len(paths) {
+		t.Fatalf("ResultFunc called %d times, want %d", len(got), len(paths))
+	}
+	for i, result := range got {
+		if result.Path != paths[i] {
+			t.Errorf("result %d path = %s, want %s (input order)", i, result.Path, paths[i])
+		}
+		if failed := result.Error != ""; failed != (i == 2) {
+			t.Errorf("result %d error = %q", i, result.Error)
+		}
+	}
+	if got[0].Metadata["format"] != "FASTQ" {
+		t.Errorf("result 0 metadata = %v", got[0].Metadata)
+	}
+}
+
+// panickingExtractor panics on every extraction, like an extractor hitting
+// a bug on a corrupt file.
+type panickingExtractor struct{}
+
+func (panickingExtractor) CanHandle(filename string) bool { return filepath.Ext(filename) == ".boom" }
+func (panickingExtractor) Extract(path string) (map[string]interface{}, error) {
+	panic("index out of range")
+}
+func (panickingExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	panic("index out of range")
+}
+func (panickingExtractor) Name() string               { return "Boom" }
+func (panickingExtractor) SupportedFormats() []string { return []string{".boom"} }
+
+func TestExtractorRegistry_ExtractBatchPanic(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.Register(panickingExtractor{})
+	registry.RegisterDefaults()
+
+	root := t.TempDir()
+	writeBatchTree(t, root, map[string]string{"a.fastq": testBatchFASTQ, "b.boom": "x", "c.fastq": testBatchFASTQ})
+	paths := []string{filepath.Join(root, "a.fastq"), filepath.Join(root, "b.boom"), filepath.Join(root, "c.fastq")}
+
+	var got []BatchResult
+	summary := registry.ExtractBatch(context.Background(), paths, BatchOptions{
+		Workers:    2,
+		ResultFunc: func(r BatchResult) { got = append(got, r) },
+	})
+
+	if want := (BatchSummary{Total: 3, Extracted: 2, Failed: 1}); summary != want {
+		t.Errorf("ExtractBatch() summary = %+v, want %+v", summary, want)
+	}
+	if len(got) != 3 || !strings.Contains(got[1].Error, "panicked") || got[1].Metadata != nil {
+		t.Fatalf("results = %+v, want the panic reported for b.boom", got)
+	}
+	if got[2].Metadata["format"] != "FASTQ" {
+		t.Errorf("result after panic = %+v, want c.fastq extracted", got[2])
+	}
+}
+
+func TestExtractorRegistry_ExtractBatchCancelled(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	root := t.TempDir()
+	writeBatchTree(t, root, map[string]string{"a.fastq": testBatchFASTQ, "b.fastq": testBatchFASTQ})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	summary := registry.ExtractBatch(ctx, []string{filepath.Join(root, "a.fastq"), filepath.Join(root, "b.fastq")}, BatchOptions{Workers: 1})
+	if summary.Total != 2 || summary.Extracted+summary.Failed != 2 {
+		t.Errorf("ExtractBatch() summary = %+v, want every path accounted for", summary)
+	}
+}
+
+func TestExtractCache(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	root := t.TempDir()
+	writeBatchTree(t, root, map[string]string{"a.fastq": testBatchFASTQ, "b.fastq": testBatchFASTQ})
+	paths := []string{filepath.Join(root, "a.fastq"), filepath.Join(root, "b.fastq")}
+	cachePath := filepath.Join(t.TempDir(), "cache", "extract.json")
+
+	run := func() BatchSummary {
+		t.Helper()
+		cache, err := LoadExtractCache(cachePath)
+		if err != nil {
+			t.Fatalf("LoadExtractCache() error = %v", err)
+		}
+		summary := registry.ExtractBatch(context.Background(), paths, BatchOptions{Cache: cache})
+		if err := cache.Save(); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		return summary
+	}
+
+	if got := run(); got.Extracted != 2 || got.Cached != 0 {
+		t.Errorf("first run = %+v, want 2 extracted", got)
+	}
+	if got := run(); got.Extracted != 0 || got.Cached != 2 {
+		t.Errorf("second run = %+v, want 2 cached", got)
+	}
+
+	// Touched but identical content is still a hit; changed content is not
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(paths[0], later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(paths[1], []byte(testBatchFASTQ+testBatchFASTQ), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(); got.Extracted != 1 || got.Cached != 1 {
+		t.Errorf("third run = %+v, want 1 extracted and 1 cached", got)
+	}
+}
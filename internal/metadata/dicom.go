@@ -0,0 +1,749 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # DICOM Format
+//
+// This file implements metadata extraction for DICOM Part 10 files from CT,
+// MR, X-ray, ultrasound, PET and other medical and preclinical scanners.
+//
+// ## File Format Overview
+//
+// A Part 10 file starts with a 128-byte preamble and the "DICM" prefix,
+// followed by the file meta information group (0002,xxxx), which is always
+// explicit VR little endian and names the transfer syntax of the dataset:
+//   - 1.2.840.10008.1.2: implicit VR little endian (VRs from the dictionary)
+//   - 1.2.840.10008.1.2.1: explicit VR little endian
+//   - 1.2.840.10008.1.2.2: explicit VR big endian (retired)
+//   - 1.2.840.10008.1.2.1.99: deflated explicit VR little endian
+//   - JPEG, JPEG 2000, RLE and other compressed syntaxes are explicit VR
+//     little endian up to the encapsulated pixel data
+//
+// Elements are (group, element) tags with a VR and length; sequences (SQ)
+// hold items that are nested datasets, with defined or undefined lengths.
+// Parsing stops at Pixel Data (7FE0,0010), so image data is never read.
+//
+// ## Protected Health Information
+//
+// Patient, physician, operator and institution identifiers, and study,
+// series, acquisition and content dates and times, are never copied to the
+// output. When any of them holds a value, anywhere in the dataset including
+// nested sequences, its keyword is listed in "phi_tags" and a warning is
+// added to "warnings". The DOI workflow refuses to prepare files that carry
+// PHI. The tag list follows the DICOM PS3.15 Basic Application Level
+// Confidentiality Profile.
+//
+// Private tags (odd groups) are never copied either. The profile removes
+// them because vendors may store identifiers there, so their groups are
+// listed in "private_groups" with a warning.
+//
+// ## References and Sources
+//
+// DICOM PS3.5 Data Structures and Encoding:
+// https://dicom.nema.org/medical/dicom/current/output/html/part05.html
+//
+// DICOM PS3.6 Data Dictionary:
+// https://dicom.nema.org/medical/dicom/current/output/html/part06.html
+//
+// DICOM PS3.15 Annex E, Attribute Confidentiality Profiles:
+// https://dicom.nema.org/medical/dicom/current/output/html/part15.html#chapter_E
+package metadata
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DICOM encoding constants
+const (
+	dicomPrefix          = "DICM"
+	dicomPreambleSize    = 128
+	dicomUndefinedLength = 0xFFFFFFFF
+	dicomMaxValueBytes   = 64 << 10
+	dicomMaxDepth        = 16
+
+	dicomItem              = 0xFFFEE000
+	dicomItemDelimiter     = 0xFFFEE00D
+	dicomSequenceDelimiter = 0xFFFEE0DD
+	dicomPixelData         = 0x7FE00010
+	dicomFloatPixelData    = 0x7FE00008
+	dicomDoublePixelData   = 0x7FE00009
+
+	dicomImplicitLittle = "1.2.840.10008.1.2"
+	dicomExplicitLittle = "1.2.840.10008.1.2.1"
+	dicomExplicitBig    = "1.2.840.10008.1.2.2"
+	dicomDeflated       = "1.2.840.10008.1.2.1.99"
+)
+
+// errDICOMPixelData stops parsing at the pixel data element.
+var errDICOMPixelData = errors.New("pixel data reached")
+
+// dicomTag is a data dictionary entry.
+type dicomTag struct {
+	keyword string
+	vr      string
+	phi     bool
+}
+
+// dicomDictionary holds the tags the extractor maps or screens for PHI.
+var dicomDictionary = map[uint32]dicomTag{
+	// File meta information
+	0x00020002: {"MediaStorageSOPClassUID", "UI", false},
+	0x00020003: {"MediaStorageSOPInstanceUID", "UI", false},
+	0x00020010: {"TransferSyntaxUID", "UI", false},
+	0x00020012: {"ImplementationClassUID", "UI", false},
+	0x00020013: {"ImplementationVersionName", "SH", false},
+	0x00020016: {"SourceApplicationEntityTitle", "AE", false},
+
+	// General study, series, equipment and image
+	0x00080008: {"ImageType", "CS", false},
+	0x00080016: {"SOPClassUID", "UI", false},
+	0x00080018: {"SOPInstanceUID", "UI", false},
+	0x00080012: {"InstanceCreationDate", "DA", true},
+	0x00080013: {"InstanceCreationTime", "TM", true},
+	0x00080020: {"StudyDate", "DA", true},
+	0x00080021: {"SeriesDate", "DA", true},
+	0x00080022: {"AcquisitionDate", "DA", true},
+	0x00080023: {"ContentDate", "DA", true},
+	0x0008002A: {"AcquisitionDateTime", "DT", true},
+	0x00080030: {"StudyTime", "TM", true},
+	0x00080031: {"SeriesTime", "TM", true},
+	0x00080032: {"AcquisitionTime", "TM", true},
+	0x00080033: {"ContentTime", "TM", true},
+	0x00080050: {"AccessionNumber", "SH", true},
+	0x00080060: {"Modality", "CS", false},
+	0x00080070: {"Manufacturer", "LO", false},
+	0x00080080: {"InstitutionName", "LO", true},
+	0x00080081: {"InstitutionAddress", "ST", true},
+	0x00080090: {"ReferringPhysicianName", "PN", true},
+	0x00080092: {"ReferringPhysicianAddress", "ST", true},
+	0x00080094: {"ReferringPhysicianTelephoneNumbers", "SH", true},
+	0x00081010: {"StationName", "SH", true},
+	0x00081030: {"StudyDescription", "LO", false},
+	0x0008103E: {"SeriesDescription", "LO", false},
+	0x00081040: {"InstitutionalDepartmentName", "LO", true},
+	0x00081048: {"PhysiciansOfRecord", "PN", true},
+	0x00081050: {"PerformingPhysicianName", "PN", true},
+	0x00081060: {"NameOfPhysiciansReadingStudy", "PN", true},
+	0x00081070: {"OperatorsName", "PN", true},
+	0x00081090: {"ManufacturerModelName", "LO", false},
+
+	// Patient
+	0x00100010: {"PatientName", "PN", true},
+	0x00100020: {"PatientID", "LO", true},
+	0x00100021: {"IssuerOfPatientID", "LO", true},
+	0x00100030: {"PatientBirthDate", "DA", true},
+	0x00100032: {"PatientBirthTime", "TM", true},
+	0x00101000: {"OtherPatientIDs", "LO", true},
+	0x00101001: {"OtherPatientNames", "PN", true},
+	0x00101002: {"OtherPatientIDsSequence", "SQ", true},
+	0x00101005: {"PatientBirthName", "PN", true},
+	0x00101040: {"PatientAddress", "LO", true},
+	0x00101060: {"PatientMotherBirthName", "PN", true},
+	0x00101090: {"MedicalRecordLocator", "LO", true},
+	0x00102154: {"PatientTelephoneNumbers", "SH", true},
+	0x00104000: {"PatientComments", "LT", true},
+	0x00120062: {"PatientIdentityRemoved", "CS", false},
+	0x00120063: {"DeidentificationMethod", "LO", false},
+
+	// Acquisition
+	0x00180015: {"BodyPartExamined", "CS", false},
+	0x00180020: {"ScanningSequence", "CS", false},
+	0x00180023: {"MRAcquisitionType", "CS", false},
+	0x00180050: {"SliceThickness", "DS", false},
+	0x00180060: {"KVP", "DS", false},
+	0x00180080: {"RepetitionTime", "DS", false},
+	0x00180081: {"EchoTime", "DS", false},
+	0x00180082: {"InversionTime", "DS", false},
+	0x00180087: {"MagneticFieldStrength", "DS", false},
+	0x00180088: {"SpacingBetweenSlices", "DS", false},
+	0x00180091: {"EchoTrainLength", "IS", false},
+	0x00181000: {"DeviceSerialNumber", "LO", false},
+	0x00181012: {"DateOfSecondaryCapture", "DA", true},
+	0x00181014: {"TimeOfSecondaryCapture", "TM", true},
+	0x00181020: {"SoftwareVersions", "LO", false},
+	0x00181030: {"ProtocolName", "LO", false},
+	0x00181150: {"ExposureTime", "IS", false},
+	0x00181151: {"XRayTubeCurrent", "IS", false},
+	0x00181164: {"ImagerPixelSpacing", "DS", false},
+	0x00181314: {"FlipAngle", "DS", false},
+	0x00185100: {"PatientPosition", "CS", false},
+
+	// Relationship and image plane
+	0x0020000D: {"StudyInstanceUID", "UI", false},
+	0x0020000E: {"SeriesInstanceUID", "UI", false},
+	0x00200010: {"StudyID", "SH", false},
+	0x00200011: {"SeriesNumber", "IS", false},
+	0x00200013: {"InstanceNumber", "IS", false},
+	0x00200052: {"FrameOfReferenceUID", "UI", false},
+
+	// Image pixel
+	0x00280002: {"SamplesPerPixel", "US", false},
+	0x00280004: {"PhotometricInterpretation", "CS", false},
+	0x00280008: {"NumberOfFrames", "IS", false},
+	0x00280010: {"Rows", "US", false},
+	0x00280011: {"Columns", "US", false},
+	0x00280030: {"PixelSpacing", "DS", false},
+	0x00280100: {"BitsAllocated", "US", false},
+	0x00280101: {"BitsStored", "US", false},
+
+	// Procedure step
+	0x00400244: {"PerformedProcedureStepStartDate", "DA", true},
+	0x00400245: {"PerformedProcedureStepStartTime", "TM", true},
+	0x00400250: {"PerformedProcedureStepEndDate", "DA", true},
+	0x00400251: {"PerformedProcedureStepEndTime", "TM", true},
+
+	// Sequence and item delimitation
+	dicomItem:              {"Item", "", false},
+	dicomItemDelimiter:     {"ItemDelimitationItem", "", false},
+	dicomSequenceDelimiter: {"SequenceDelimitationItem", "", false},
+}
+
+// dicomTransferSyntaxes names common transfer syntaxes.
+var dicomTransferSyntaxes = map[string]string{
+	dicomImplicitLittle:      "Implicit VR Little Endian",
+	dicomExplicitLittle:      "Explicit VR Little Endian",
+	dicomExplicitBig:         "Explicit VR Big Endian",
+	dicomDeflated:            "Deflated Explicit VR Little Endian",
+	"1.2.840.10008.1.2.4.50": "JPEG Baseline",
+	"1.2.840.10008.1.2.4.57": "JPEG Lossless",
+	"1.2.840.10008.1.2.4.70": "JPEG Lossless, First-Order Prediction",
+	"1.2.840.10008.1.2.4.80": "JPEG-LS Lossless",
+	"1.2.840.10008.1.2.4.90": "JPEG 2000 Lossless",
+	"1.2.840.10008.1.2.4.91": "JPEG 2000",
+	"1.2.840.10008.1.2.5":    "RLE Lossless",
+}
+
+// dicomSOPClasses names common storage SOP classes.
+var dicomSOPClasses = map[string]string{
+	"1.2.840.10008.5.1.4.1.1.2":      "CT Image Storage",
+	"1.2.840.10008.5.1.4.1.1.2.1":    "Enhanced CT Image Storage",
+	"1.2.840.10008.5.1.4.1.1.4":      "MR Image Storage",
+	"1.2.840.10008.5.1.4.1.1.4.1":    "Enhanced MR Image Storage",
+	"1.2.840.10008.5.1.4.1.1.1":      "Computed Radiography Image Storage",
+	"1.2.840.10008.5.1.4.1.1.1.1":    "Digital X-Ray Image Storage - For Presentation",
+	"1.2.840.10008.5.1.4.1.1.6.1":    "Ultrasound Image Storage",
+	"1.2.840.10008.5.1.4.1.1.7":      "Secondary Capture Image Storage",
+	"1.2.840.10008.5.1.4.1.1.12.1":   "X-Ray Angiographic Image Storage",
+	"1.2.840.10008.5.1.4.1.1.20":     "Nuclear Medicine Image Storage",
+	"1.2.840.10008.5.1.4.1.1.128":    "Positron Emission Tomography Image Storage",
+	"1.2.840.10008.5.1.4.1.1.77.1.6": "VL Whole Slide Microscopy Image Storage",
+}
+
+// dicomLongLengthVRs use a 2-byte reserved field and 4-byte length in
+// explicit VR encoding.
+var dicomLongLengthVRs = map[string]bool{
+	"OB": true, "OD": true, "OF": true, "OL": true, "OV": true, "OW": true,
+	"SQ": true, "SV": true, "UC": true, "UN": true, "UR": true, "UT": true, "UV": true,
+}
+
+// DICOMExtractor extracts metadata from DICOM Part 10 files.
+type DICOMExtractor struct{}
+
+// Name returns the extractor name.
+func (e *DICOMExtractor) Name() string {
+	return "DICOM"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *DICOMExtractor) SupportedFormats() []string {
+	return []string{".dcm", ".dicom"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *DICOMExtractor) CanHandle(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".dcm" || ext == ".dicom"
+}
+
+// Sniff recognises the DICM prefix after the 128-byte preamble.
+func (e *DICOMExtractor) Sniff(in *SniffInput) (int, string) {
+	if in.at(dicomPreambleSize, dicomPrefix) {
+		return 100, "DICM prefix at offset 128"
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from a DICOM file.
+func (e *DICOMExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	metadata, err := e.extractFromReader(f, filepath)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil {
+		metadata["file_size"] = info.Size()
+	}
+	return metadata, nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *DICOMExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	return e.extractFromReader(r, filename)
+}
+
+// extractFromReader parses the file meta group and the dataset up to the
+// pixel data.
+func (e *DICOMExtractor) extractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	p := &dicomParser{
+		r:        &countingReader{r: br},
+		order:    binary.LittleEndian,
+		explicit: true,
+		values:   map[uint32]string{},
+		phi:      map[uint32]bool{},
+		private:  map[uint16]bool{},
+	}
+
+	head, err := br.Peek(dicomPreambleSize + len(dicomPrefix))
+	hasPrefix := err == nil && string(head[dicomPreambleSize:]) == dicomPrefix
+	transferSyntax := dicomExplicitLittle
+
+	switch {
+	case hasPrefix:
+		if _, err := br.Discard(len(head)); err != nil {
+			return nil, fmt.Errorf("failed to read DICOM preamble: %w", err)
+		}
+		if err := p.parseMeta(br); err != nil {
+			return nil, err
+		}
+		if ts := p.values[0x00020010]; ts != "" {
+			transferSyntax = ts
+		}
+	case len(head) >= 6 && (head[0] == 0x08 || head[0] == 0x02) && head[1] == 0x00:
+		// Bare dataset without preamble, as written by some older tools
+		transferSyntax = dicomImplicitLittle
+		if head[0] == 0x02 || isDICOMVR(string(head[4:6])) {
+			transferSyntax = dicomExplicitLittle
+		}
+	default:
+		return nil, fmt.Errorf("not a valid DICOM file: missing DICM prefix")
+	}
+
+	switch transferSyntax {
+	case dicomImplicitLittle:
+		p.explicit = false
+	case dicomExplicitBig:
+		p.order = binary.BigEndian
+	case dicomDeflated:
+		p.r = &countingReader{r: flate.NewReader(br)}
+	}
+
+	if err := p.parseDataset(-1, 0); err != nil && !errors.Is(err, errDICOMPixelData) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse DICOM dataset: %w", err)
+	}
+	if len(p.values) == 0 {
+		return nil, fmt.Errorf("not a valid DICOM file: no data elements found")
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "DICOM",
+		"file_name":       filepath.Base(filename),
+		"extractor_name":  "dicom",
+		"schema_name":     "dicom_v1",
+		"instrument_type": "medical_imaging",
+		"data_type":       "image",
+		"transfer_syntax": transferSyntax,
+	}
+	if name, ok := dicomTransferSyntaxes[transferSyntax]; ok {
+		metadata["transfer_syntax_name"] = name
+	}
+	p.mapFields(metadata)
+	p.addPHIWarnings(metadata)
+	return metadata, nil
+}
+
+// isDICOMVR reports whether s is a known value representation.
+func isDICOMVR(s string) bool {
+	switch s {
+	case "AE", "AS", "AT", "CS", "DA", "DS", "DT", "FL", "FD", "IS", "LO", "LT",
+		"OB", "OD", "OF", "OL", "OV", "OW", "PN", "SH", "SL", "SQ", "SS", "ST",
+		"SV", "TM", "UC", "UI", "UL", "UN", "UR", "US", "UT", "UV":
+		return true
+	}
+	return false
+}
+
+// dicomParser reads data elements. Only top-level values of dictionary
+// tags are kept; nested datasets are walked to screen for PHI.
+type dicomParser struct {
+	r        *countingReader
+	order    binary.ByteOrder
+	explicit bool
+	values   map[uint32]string
+	phi      map[uint32]bool
+	private  map[uint16]bool
+}
+
+// parseMeta reads the file meta group, which is always explicit VR little
+// endian, stopping at the first non-0002 tag.
+func (p *dicomParser) parseMeta(br *bufio.Reader) error {
+	for {
+		next, err := br.Peek(2)
+		if err != nil || binary.LittleEndian.Uint16(next) != 0x0002 {
+			return nil
+		}
+		tag, vr, length, err := p.readHeader()
+		if err != nil {
+			return fmt.Errorf("not a valid DICOM file: truncated file meta information: %w", err)
+		}
+		if err := p.readValue(tag, vr, length, 0); err != nil {
+			return fmt.Errorf("not a valid DICOM file: %w", err)
+		}
+	}
+}
+
+// readTag reads a (group, element) tag.
+func (p *dicomParser) readTag() (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(p.r, b[:]); err != nil {
+		return 0, err
+	}
+	return uint32(p.order.Uint16(b[0:2]))<<16 | uint32(p.order.Uint16(b[2:4])), nil
+}
+
+// readHeader reads an element header: tag, VR and value length.
+func (p *dicomParser) readHeader() (uint32, string, uint32, error) {
+	tag, err := p.readTag()
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	// Items and delimiters always have an implicit 4-byte length
+	if tag>>16 == 0xFFFE {
+		var b [4]byte
+		if _, err := io.ReadFull(p.r, b[:]); err != nil {
+			return 0, "", 0, unexpectedEOF(err)
+		}
+		return tag, "", p.order.Uint32(b[:]), nil
+	}
+
+	if !p.explicit {
+		var b [4]byte
+		if _, err := io.ReadFull(p.r, b[:]); err != nil {
+			return 0, "", 0, unexpectedEOF(err)
+		}
+		vr := dicomDictionary[tag].vr
+		if vr == "" {
+			vr = "UN"
+		}
+		return tag, vr, p.order.Uint32(b[:]), nil
+	}
+
+	var b [4]byte
+	if _, err := io.ReadFull(p.r, b[:]); err != nil {
+		return 0, "", 0, unexpectedEOF(err)
+	}
+	vr := string(b[0:2])
+	if dicomLongLengthVRs[vr] {
+		if _, err := io.ReadFull(p.r, b[:]); err != nil {
+			return 0, "", 0, unexpectedEOF(err)
+		}
+		return tag, vr, p.order.Uint32(b[:]), nil
+	}
+	return tag, vr, uint32(p.order.Uint16(b[2:4])), nil
+}
+
+// parseDataset reads elements until end (a byte offset), an item
+// delimiter when end is -1, or the pixel data.
+func (p *dicomParser) parseDataset(end int64, depth int) error {
+	for end < 0 || p.r.n < end {
+		tag, vr, length, err := p.readHeader()
+		if err != nil {
+			if depth == 0 && errors.Is(err, io.EOF) {
+				return nil
+			}
+			return unexpectedEOF(err)
+		}
+
+		switch {
+		case tag == dicomItemDelimiter:
+			return nil
+		case depth == 0 && (tag == dicomPixelData || tag == dicomFloatPixelData || tag == dicomDoublePixelData):
+			return errDICOMPixelData
+		}
+		if err := p.readValue(tag, vr, length, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readValue reads or skips one element value, descending into sequences.
+func (p *dicomParser) readValue(tag uint32, vr string, length uint32, depth int) error {
+	entry := dicomDictionary[tag]
+	if group := uint16(tag >> 16); group%2 == 1 {
+		p.private[group] = true
+	}
+
+	if vr == "SQ" || (length == dicomUndefinedLength && vr == "UN") {
+		if entry.phi {
+			p.phi[tag] = true
+		}
+		return p.parseSequence(length, depth+1)
+	}
+	if length == dicomUndefinedLength {
+		// Only encapsulated pixel data may have undefined length here
+		return errDICOMPixelData
+	}
+	if entry.keyword == "" || length > dicomMaxValueBytes {
+		_, err := io.CopyN(io.Discard, p.r, int64(length))
+		return unexpectedEOF(err)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(p.r, buf); err != nil {
+		return unexpectedEOF(err)
+	}
+	value := p.decodeValue(vr, buf)
+	if entry.phi && strings.Trim(value, " ^\x00") != "" {
+		p.phi[tag] = true
+	}
+	if depth == 0 && !entry.phi {
+		p.values[tag] = value
+	}
+	return nil
+}
+
+// parseSequence reads the items of a sequence.
+func (p *dicomParser) parseSequence(length uint32, depth int) error {
+	if depth > dicomMaxDepth {
+		return fmt.Errorf("sequences nested too deeply")
+	}
+	end := int64(-1)
+	if length != dicomUndefinedLength {
+		end = p.r.n + int64(length)
+	}
+
+	// Sequences of unknown VR in implicit files are implicit little endian
+	for end < 0 || p.r.n < end {
+		tag, _, itemLength, err := p.readHeader()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		switch tag {
+		case dicomSequenceDelimiter:
+			return nil
+		case dicomItem:
+			itemEnd := int64(-1)
+			if itemLength != dicomUndefinedLength {
+				itemEnd = p.r.n + int64(itemLength)
+			}
+			if err := p.parseDataset(itemEnd, depth); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected tag (%04X,%04X) in sequence", tag>>16, tag&0xFFFF)
+		}
+	}
+	return nil
+}
+
+// decodeValue converts a value to a string; multiple values are joined
+// with a backslash as in DICOM string VRs.
+func (p *dicomParser) decodeValue(vr string, b []byte) string {
+	switch vr {
+	case "US", "SS":
+		var parts []string
+		for i := 0; i+2 <= len(b); i += 2 {
+			v := p.order.Uint16(b[i:])
+			if vr == "SS" {
+				parts = append(parts, strconv.Itoa(int(int16(v))))
+			} else {
+				parts = append(parts, strconv.Itoa(int(v)))
+			}
+		}
+		return strings.Join(parts, `\`)
+	case "UL", "SL":
+		var parts []string
+		for i := 0; i+4 <= len(b); i += 4 {
+			v := p.order.Uint32(b[i:])
+			if vr == "SL" {
+				parts = append(parts, strconv.Itoa(int(int32(v))))
+			} else {
+				parts = append(parts, strconv.FormatUint(uint64(v), 10))
+			}
+		}
+		return strings.Join(parts, `\`)
+	case "OB", "OW", "OF", "OD", "UN":
+		return ""
+	}
+	return strings.TrimRight(string(b), " \x00")
+}
+
+// str returns a top-level string value.
+func (p *dicomParser) str(tag uint32) string {
+	return strings.TrimSpace(p.values[tag])
+}
+
+// floats parses a backslash-separated numeric value.
+func (p *dicomParser) floats(tag uint32) []float64 {
+	var out []float64
+	for _, part := range strings.Split(p.values[tag], `\`) {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(part), 64); err == nil {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// mapFields maps top-level values to output fields.
+func (p *dicomParser) mapFields(metadata map[string]interface{}) {
+	for _, field := range []struct {
+		tag uint32
+		key string
+	}{
+		{0x00080060, "modality"},
+		{0x00080070, "manufacturer"},
+		{0x00081090, "instrument_model"},
+		{0x00181000, "serial_number"},
+		{0x00181020, "software_version"},
+		{0x0020000D, "study_instance_uid"},
+		{0x0020000E, "series_instance_uid"},
+		{0x00080018, "sop_instance_uid"},
+		{0x00080016, "sop_class_uid"},
+		{0x00200052, "frame_of_reference_uid"},
+		{0x00081030, "study_description"},
+		{0x0008103E, "series_description"},
+		{0x00181030, "protocol_name"},
+		{0x00180015, "body_part_examined"},
+		{0x00185100, "patient_position"},
+		{0x00080008, "image_type"},
+		{0x00280004, "photometric_interpretation"},
+		{0x00180020, "scanning_sequence"},
+		{0x00180023, "mr_acquisition_type"},
+		{0x00120063, "deidentification_method"},
+	} {
+		if v := p.str(field.tag); v != "" {
+			metadata[field.key] = v
+		}
+	}
+	if name, ok := dicomSOPClasses[p.str(0x00080016)]; ok {
+		metadata["sop_class_name"] = name
+	}
+	if strings.EqualFold(p.str(0x00120062), "YES") {
+		metadata["patient_identity_removed"] = true
+	}
+
+	for _, field := range []struct {
+		tag uint32
+		key string
+	}{
+		{0x00280011, "image_width"},
+		{0x00280010, "image_height"},
+		{0x00280008, "num_frames"},
+		{0x00280100, "bits_allocated"},
+		{0x00280101, "bit_depth"},
+		{0x00280002, "samples_per_pixel"},
+		{0x00200011, "series_number"},
+		{0x00200013, "instance_number"},
+		{0x00180091, "echo_train_length"},
+	} {
+		if n, err := strconv.Atoi(strings.Split(p.str(field.tag), `\`)[0]); err == nil {
+			metadata[field.key] = n
+		}
+	}
+
+	for _, field := range []struct {
+		tag uint32
+		key string
+	}{
+		{0x00180050, "slice_thickness_mm"},
+		{0x00180088, "spacing_between_slices_mm"},
+		{0x00180060, "kvp"},
+		{0x00181150, "exposure_time_ms"},
+		{0x00181151, "tube_current_ma"},
+		{0x00180080, "repetition_time_ms"},
+		{0x00180081, "echo_time_ms"},
+		{0x00180082, "inversion_time_ms"},
+		{0x00181314, "flip_angle_deg"},
+		{0x00180087, "magnetic_field_strength_t"},
+	} {
+		if values := p.floats(field.tag); len(values) > 0 {
+			metadata[field.key] = values[0]
+		}
+	}
+
+	// Pixel Spacing is row spacing (y) then column spacing (x), in mm
+	spacing := p.floats(0x00280030)
+	if len(spacing) < 2 {
+		spacing = p.floats(0x00181164)
+	}
+	if len(spacing) >= 2 {
+		metadata["pixel_spacing_mm"] = spacing[:2]
+		metadata["pixel_size_y_um"] = spacing[0] * 1000
+		metadata["pixel_size_x_um"] = spacing[1] * 1000
+	}
+}
+
+// addPHIWarnings lists the PHI tags that held values and the private groups
+// present. Their values are never included in the output.
+func (p *dicomParser) addPHIWarnings(metadata map[string]interface{}) {
+	var warnings []string
+	if len(p.phi) > 0 {
+		warnings = p.phiWarnings(metadata)
+	}
+	if len(p.private) > 0 {
+		groups := make([]uint16, 0, len(p.private))
+		for group := range p.private {
+			groups = append(groups, group)
+		}
+		sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+		names := make([]string, 0, len(groups))
+		for _, group := range groups {
+			names = append(names, fmt.Sprintf("%04X", group))
+			warnings = append(warnings, fmt.Sprintf("private tags in group %04X are present; they may hold PHI, remove them before sharing", group))
+		}
+		metadata["private_groups"] = names
+	}
+	if len(warnings) > 0 {
+		metadata["warnings"] = warnings
+	}
+}
+
+// phiWarnings sets contains_phi and phi_tags and returns a warning per tag.
+func (p *dicomParser) phiWarnings(metadata map[string]interface{}) []string {
+	tags := make([]uint32, 0, len(p.phi))
+	for tag := range p.phi {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	keywords := make([]string, 0, len(tags))
+	warnings := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keyword := dicomDictionary[tag].keyword
+		keywords = append(keywords, keyword)
+		warnings = append(warnings, fmt.Sprintf("PHI tag %s (%04X,%04X) is present; de-identify before sharing",
+			keyword, tag>>16, tag&0xFFFF))
+	}
+	metadata["contains_phi"] = true
+	metadata["phi_tags"] = keywords
+	return warnings
+}
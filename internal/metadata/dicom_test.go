@@ -0,0 +1,301 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDICOMWriter encodes data elements in a given transfer syntax.
+type testDICOMWriter struct {
+	order    binary.ByteOrder
+	explicit bool
+	buf      bytes.Buffer
+}
+
+func (w *testDICOMWriter) tag(tag uint32) {
+	_ = binary.Write(&w.buf, w.order, uint16(tag>>16))
+	_ = binary.Write(&w.buf, w.order, uint16(tag))
+}
+
+// elem writes one element, padding string values to an even length.
+func (w *testDICOMWriter) elem(tag uint32, vr string, value []byte) {
+	if len(value)%2 == 1 {
+		value = append(value, ' ')
+	}
+	w.header(tag, vr, uint32(len(value)))
+	w.buf.Write(value)
+}
+
+func (w *testDICOMWriter) header(tag uint32, vr string, length uint32) {
+	w.tag(tag)
+	switch {
+	case !w.explicit:
+		_ = binary.Write(&w.buf, w.order, length)
+	case dicomLongLengthVRs[vr]:
+		w.buf.WriteString(vr)
+		w.buf.Write([]byte{0, 0})
+		_ = binary.Write(&w.buf, w.order, length)
+	default:
+		w.buf.WriteString(vr)
+		_ = binary.Write(&w.buf, w.order, uint16(length))
+	}
+}
+
+func (w *testDICOMWriter) str(tag uint32, value string) {
+	w.elem(tag, dicomDictionary[tag].vr, []byte(value))
+}
+
+func (w *testDICOMWriter) us(tag uint32, value uint16) {
+	b := make([]byte, 2)
+	w.order.PutUint16(b, value)
+	w.elem(tag, "US", b)
+}
+
+// sequence writes an undefined-length sequence of undefined-length items.
+func (w *testDICOMWriter) sequence(tag uint32, items ...func(*testDICOMWriter)) {
+	w.header(tag, "SQ", dicomUndefinedLength)
+	for _, item := range items {
+		w.tag(dicomItem)
+		_ = binary.Write(&w.buf, w.order, uint32(dicomUndefinedLength))
+		item(w)
+		w.tag(dicomItemDelimiter)
+		_ = binary.Write(&w.buf, w.order, uint32(0))
+	}
+	w.tag(dicomSequenceDelimiter)
+	_ = binary.Write(&w.buf, w.order, uint32(0))
+}
+
+// pixelData writes a pixel data element followed by bytes that would fail
+// to parse if the extractor read past it.
+func (w *testDICOMWriter) pixelData() {
+	w.elem(dicomPixelData, "OW", bytes.Repeat([]byte{0xFF}, 64))
+	w.buf.Write([]byte("trailing garbage"))
+}
+
+// buildTestDICOM wraps a dataset in a preamble and file meta group.
+func buildTestDICOM(transferSyntax string, dataset []byte) []byte {
+	meta := &testDICOMWriter{order: binary.LittleEndian, explicit: true}
+	meta.elem(0x00020001, "OB", []byte{0, 1})
+	meta.str(0x00020002, "1.2.840.10008.5.1.4.1.1.4\x00")
+	meta.str(0x00020003, "1.2.3.4.5.6.7\x00")
+	meta.str(0x00020010, transferSyntax+"\x00")
+
+	var out bytes.Buffer
+	out.Write(make([]byte, dicomPreambleSize))
+	out.WriteString(dicomPrefix)
+	group := &testDICOMWriter{order: binary.LittleEndian, explicit: true}
+	group.elem(0x00020000, "UL", binary.LittleEndian.AppendUint32(nil, uint32(meta.buf.Len())))
+	out.Write(group.buf.Bytes())
+	out.Write(meta.buf.Bytes())
+	out.Write(dataset)
+	return out.Bytes()
+}
+
+// writeTestMRDataset writes an identifiable MR dataset.
+func writeTestMRDataset(w *testDICOMWriter) {
+	w.str(0x00080008, `ORIGINAL\PRIMARY\M\ND`)
+	w.str(0x00080016, "1.2.840.10008.5.1.4.1.1.4")
+	w.str(0x00080018, "1.2.3.4.5.6.7")
+	w.str(0x00080020, "20240311")
+	w.str(0x00080022, "20240311")
+	w.str(0x00080032, "142530.250000")
+	w.str(0x00080060, "MR")
+	w.str(0x00080070, "SIEMENS")
+	w.str(0x00080080, "General Hospital")
+	w.str(0x00081090, "Prisma")
+	w.elem(0x00091010, "LO", []byte("private creator value"))
+	w.str(0x00100010, "DOE^JANE")
+	w.str(0x00100020, "MRN123456")
+	w.str(0x00100030, "19700101")
+	w.sequence(0x00400275, func(w *testDICOMWriter) {
+		w.str(0x00100020, "OTHER-ID-9")
+		w.elem(0x00400007, "LO", []byte("Brain"))
+	})
+	w.str(0x00180080, "2300")
+	w.str(0x00180081, "2.98")
+	w.str(0x00180087, "3")
+	w.str(0x00181314, "9")
+	w.str(0x00180050, "1.0")
+	w.str(0x00181000, "66012")
+	w.str(0x00181020, "syngo MR XA30")
+	w.str(0x0020000D, "1.2.3.4.1")
+	w.str(0x0020000E, "1.2.3.4.2")
+	w.str(0x00200013, "42")
+	w.us(0x00280010, 256)
+	w.us(0x00280011, 240)
+	w.str(0x00280030, `0.9375\0.9765625`)
+	w.us(0x00280100, 16)
+	w.us(0x00280101, 12)
+	w.pixelData()
+}
+
+func TestDICOMExtractorExplicitLittle(t *testing.T) {
+	w := &testDICOMWriter{order: binary.LittleEndian, explicit: true}
+	writeTestMRDataset(w)
+	path := filepath.Join(t.TempDir(), "brain.dcm")
+	if err := os.WriteFile(path, buildTestDICOM(dicomExplicitLittle, w.buf.Bytes()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := (&DICOMExtractor{}).Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":              "DICOM",
+		"file_name":           "brain.dcm",
+		"schema_name":         "dicom_v1",
+		"modality":            "MR",
+		"manufacturer":        "SIEMENS",
+		"instrument_model":    "Prisma",
+		"serial_number":       "66012",
+		"software_version":    "syngo MR XA30",
+		"sop_class_name":      "MR Image Storage",
+		"study_instance_uid":  "1.2.3.4.1",
+		"series_instance_uid": "1.2.3.4.2",
+		"sop_instance_uid":    "1.2.3.4.5.6.7",
+		"transfer_syntax":     dicomExplicitLittle,
+		"image_width":         240,
+		"image_height":        256,
+		"bit_depth":           12,
+		"instance_number":     42,
+		"repetition_time_ms":  2300.0,
+		"echo_time_ms":        2.98,
+		"flip_angle_deg":      9.0,
+		"contains_phi":        true,
+		"phi_tags":            []string{"StudyDate", "AcquisitionDate", "AcquisitionTime", "InstitutionName", "PatientName", "PatientID", "PatientBirthDate"},
+		"private_groups":      []string{"0009"},
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(metadata[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+	if got := metadata["magnetic_field_strength_t"]; got != 3.0 {
+		t.Errorf("magnetic_field_strength_t = %v, want 3", got)
+	}
+	if got, _ := metadata["pixel_size_x_um"].(float64); math.Abs(got-976.5625) > 1e-9 {
+		t.Errorf("pixel_size_x_um = %v, want 976.5625", metadata["pixel_size_x_um"])
+	}
+	if got, _ := metadata["pixel_size_y_um"].(float64); math.Abs(got-937.5) > 1e-9 {
+		t.Errorf("pixel_size_y_um = %v, want 937.5", metadata["pixel_size_y_um"])
+	}
+
+	warnings, _ := metadata["warnings"].([]string)
+	if len(warnings) != 8 || !strings.Contains(warnings[4], "PatientName (0010,0010)") || !strings.Contains(warnings[7], "group 0009") {
+		t.Errorf("warnings = %v", warnings)
+	}
+
+	dump := fmt.Sprint(metadata)
+	for _, value := range []string{"DOE", "MRN123456", "OTHER-ID-9", "19700101", "20240311", "General Hospital", "private creator value"} {
+		if strings.Contains(dump, value) {
+			t.Errorf("PHI value %q leaked into metadata", value)
+		}
+	}
+}
+
+func TestDICOMExtractorImplicitDeidentified(t *testing.T) {
+	w := &testDICOMWriter{order: binary.LittleEndian, explicit: false}
+	w.str(0x00080060, "CT")
+	w.str(0x00080070, "GE MEDICAL SYSTEMS")
+	w.str(0x00100010, "")
+	w.str(0x00100020, "")
+	w.str(0x00120062, "YES")
+	w.str(0x00120063, "DICOM PS3.15 Basic Profile")
+	w.str(0x00180060, "120")
+	w.str(0x00181150, "500")
+	w.us(0x00280010, 512)
+	w.us(0x00280011, 512)
+	w.pixelData()
+
+	data := buildTestDICOM(dicomImplicitLittle, w.buf.Bytes())
+	metadata, err := (&DICOMExtractor{}).ExtractFromReader(bytes.NewReader(data), "ct.dcm")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	if metadata["modality"] != "CT" || metadata["kvp"] != 120.0 || metadata["exposure_time_ms"] != 500.0 || metadata["image_width"] != 512 {
+		t.Errorf("metadata = %v", metadata)
+	}
+	if metadata["patient_identity_removed"] != true {
+		t.Errorf("patient_identity_removed = %v, want true", metadata["patient_identity_removed"])
+	}
+	if _, ok := metadata["contains_phi"]; ok {
+		t.Errorf("empty PHI tags should not be reported: %v", metadata["phi_tags"])
+	}
+	if _, ok := metadata["warnings"]; ok {
+		t.Errorf("warnings = %v, want none", metadata["warnings"])
+	}
+}
+
+func TestDICOMExtractorBigEndianAndDeflated(t *testing.T) {
+	big := &testDICOMWriter{order: binary.BigEndian, explicit: true}
+	writeTestMRDataset(big)
+
+	little := &testDICOMWriter{order: binary.LittleEndian, explicit: true}
+	writeTestMRDataset(little)
+	var deflated bytes.Buffer
+	fw, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	_, _ = fw.Write(little.buf.Bytes())
+	_ = fw.Close()
+
+	for name, data := range map[string][]byte{
+		"big endian": buildTestDICOM(dicomExplicitBig, big.buf.Bytes()),
+		"deflated":   buildTestDICOM(dicomDeflated, deflated.Bytes()),
+	} {
+		metadata, err := (&DICOMExtractor{}).ExtractFromReader(bytes.NewReader(data), "mr.dcm")
+		if err != nil {
+			t.Fatalf("%s: ExtractFromReader() error = %v", name, err)
+		}
+		if metadata["image_width"] != 240 || metadata["image_height"] != 256 || metadata["echo_time_ms"] != 2.98 {
+			t.Errorf("%s: metadata = %v", name, metadata)
+		}
+		if !reflect.DeepEqual(metadata["phi_tags"], []string{"StudyDate", "AcquisitionDate", "AcquisitionTime", "InstitutionName", "PatientName", "PatientID", "PatientBirthDate"}) {
+			t.Errorf("%s: phi_tags = %v", name, metadata["phi_tags"])
+		}
+	}
+}
+
+func TestDICOMExtractorInvalid(t *testing.T) {
+	extractor := &DICOMExtractor{}
+	if _, err := extractor.ExtractFromReader(strings.NewReader(strings.Repeat("x", 200)), "bad.dcm"); err == nil {
+		t.Error("expected error for file without DICM prefix")
+	}
+
+	w := &testDICOMWriter{order: binary.LittleEndian, explicit: true}
+	w.str(0x00080060, "MR")
+	w.header(0x00100010, "PN", 200)
+	w.buf.WriteString("DOE")
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(buildTestDICOM(dicomExplicitLittle, w.buf.Bytes())), "short.dcm"); err == nil {
+		t.Error("expected error for truncated element")
+	}
+
+	for _, data := range []string{"\x08\x00", "\x08\x00\x05\x00", "\x08\x00\x05\x00C"} {
+		// Too short to tell a bare dataset's VR, so not read as one
+		if _, err := extractor.ExtractFromReader(strings.NewReader(data), "truncated.dcm"); err == nil || !strings.Contains(err.Error(), "not a valid DICOM file") {
+			t.Errorf("truncated %d-byte file error = %v, want not a valid DICOM file", len(data), err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+const testEERXML = `<metadata>
+  <item name="acquisitionID">EER_20240502_101112</item>
+  <item name="commercialName">Falcon 4i</item>
+  <item name="exposureTime" unit="s">4.0</item>
+  <item name="meanDoseRate" unit="e/pixel/s">2.5</item>
+  <item name="numberOfFrames">3</item>
+  <item name="sensorPixelSize.width" unit="m">1.4e-5</item>
+  <item name="serialNumber">F4I-1234</item>
+  <item name="timestamp">2024-05-02T10:11:12Z</item>
+</metadata>`
+
+// buildTestEER writes a little-endian TIFF with one IFD per frame. Only the
+// first IFD carries the acquisition XML.
+func buildTestEER(frames int, xmlData string) []byte {
+	le := binary.LittleEndian
+	var buf bytes.Buffer
+	buf.WriteString("II")
+	_ = binary.Write(&buf, le, uint16(42))
+	_ = binary.Write(&buf, le, uint32(8))
+
+	for i := 0; i < frames; i++ {
+		type entry struct {
+			tag, typ    uint16
+			count, data uint32
+		}
+		entries := []entry{
+			{tiffTagImageWidth, 3, 1, 4096},
+			{tiffTagImageLength, 3, 1, 4096},
+			{tiffTagCompression, 3, 1, 65001},
+		}
+		if i == 0 {
+			entries = append(entries, entry{eerTagMetadata, 7, uint32(len(xmlData)), 0})
+		}
+
+		start := uint32(buf.Len())
+		ifdSize := uint32(2 + 12*len(entries) + 4)
+		valueOffset := start + ifdSize
+		next := valueOffset
+		if i == 0 {
+			next += uint32(len(xmlData))
+		}
+		if i == frames-1 {
+			next = 0
+		}
+
+		_ = binary.Write(&buf, le, uint16(len(entries)))
+		for _, e := range entries {
+			_ = binary.Write(&buf, le, e.tag)
+			_ = binary.Write(&buf, le, e.typ)
+			_ = binary.Write(&buf, le, e.count)
+			if e.tag == eerTagMetadata {
+				_ = binary.Write(&buf, le, valueOffset)
+			} else {
+				_ = binary.Write(&buf, le, uint16(e.data))
+				_ = binary.Write(&buf, le, uint16(0))
+			}
+		}
+		_ = binary.Write(&buf, le, next)
+		if i == 0 {
+			buf.WriteString(xmlData)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestEERExtractor(t *testing.T) {
+	extractor := &EERExtractor{}
+	if !extractor.CanHandle("movie.EER") {
+		t.Error("CanHandle(movie.EER) = false")
+	}
+
+	metadata, err := extractor.ExtractFromReader(bytes.NewReader(buildTestEER(3, testEERXML)), "movie.eer")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":                 "EER",
+		"num_frames":             3,
+		"frames_per_movie":       3,
+		"image_width":            4096,
+		"image_height":           4096,
+		"compression":            "EER 7-bit RLE",
+		"manufacturer":           "Thermo Fisher Scientific",
+		"detector_model":         "Falcon 4i",
+		"detector_mode":          "counting",
+		"detector_serial_number": "F4I-1234",
+		"acquisition_id":         "EER_20240502_101112",
+		"acquisition_date":       "2024-05-02T10:11:12Z",
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+	assertFloat(t, metadata, "exposure_time", 4)
+	assertFloat(t, metadata, "total_dose_per_pixel", 10)
+	assertFloat(t, metadata, "sensor_pixel_size_um", 14)
+}
+
+func TestEERExtractorInvalid(t *testing.T) {
+	if _, err := (&EERExtractor{}).ExtractFromReader(bytes.NewReader([]byte("not a tiff")), "bad.eer"); err == nil {
+		t.Error("expected error for non-TIFF input")
+	}
+}
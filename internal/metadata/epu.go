@@ -0,0 +1,470 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # EPU/Tomo XML Format
+//
+// This file implements metadata extraction for the XML sidecars that Thermo
+// Fisher EPU and Tomo write next to every micrograph, movie and tilt series,
+// and the CryoEMMetadata mapping shared by the MRC and EER extractors.
+//
+// ## File Format Overview
+//
+// The sidecars are .NET DataContract serializations with a MicroscopeImage
+// root. Fixed microscope state lives in nested elements:
+//   - microscopeData/gun/AccelerationVoltage (V)
+//   - microscopeData/optics/Defocus (m) and SphericalAberration
+//   - microscopeData/optics/TemMagnification/NominalMagnification
+//   - microscopeData/instrument/InstrumentModel, InstrumentID, Manufacturer
+//   - microscopeData/camera/Name, ExposureTime (s)
+//   - SpatialScale/pixelSize/x/numericValue (m)
+//
+// Per-acquisition values such as Dose (e⁻/m²), DetectorCommercialName and
+// ElectronCountingEnabled are stored as Key/Value pairs in CustomData.
+//
+// ## Sidecar Lookup
+//
+// EPU names movies after their micrograph, e.g. "FoilHole_1_Data_2_3_
+// 20240101_101010_fractions.tiff" or "..._EER.eer" next to "FoilHole_1_
+// Data_2_3_20240101_101010.xml". The MRC and EER extractors look for that
+// XML file and use it to fill fields the binary header lacks.
+//
+// ## References and Sources
+//
+// Thermo Fisher EPU and Tomo user guides; field names follow the XML written
+// by EPU 2.x and 3.x.
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// epuSniffBytes is how much of an .xml file CanHandle reads to recognise EPU.
+const epuSniffBytes = 4096
+
+// epuMarkers identify EPU/Tomo DataContract XML.
+var epuMarkers = []string{"Fei.SharedObjects", "<MicroscopeImage", "<microscopeData"}
+
+// epuNamePrefixes are file name prefixes written by EPU and Tomo.
+var epuNamePrefixes = []string{"FoilHole_", "GridSquare_", "Atlas_", "TargetLocation_"}
+
+// EPUExtractor extracts metadata from EPU and Tomo XML sidecars.
+type EPUExtractor struct{}
+
+// Name returns the extractor name.
+func (e *EPUExtractor) Name() string {
+	return "EPU"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *EPUExtractor) SupportedFormats() []string {
+	return []string{".xml"}
+}
+
+// CanHandle returns true for .xml files named or shaped like EPU sidecars.
+func (e *EPUExtractor) CanHandle(filename string) bool {
+	if strings.ToLower(filepath.Ext(filename)) != ".xml" {
+		return false
+	}
+	base := filepath.Base(filename)
+	for _, prefix := range epuNamePrefixes {
+		if strings.HasPrefix(base, prefix) {
+			return true
+		}
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	head := make([]byte, epuSniffBytes)
+	n, _ := io.ReadFull(f, head)
+	for _, marker := range epuMarkers {
+		if bytes.Contains(head[:n], []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sniff recognises EPU/Tomo DataContract XML.
+func (e *EPUExtractor) Sniff(in *SniffInput) (int, string) {
+	for _, marker := range epuMarkers {
+		if in.contains(marker) {
+			return 90, "EPU XML marker " + marker
+		}
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from an EPU XML file.
+func (e *EPUExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	return e.ExtractFromReader(f, filepath)
+}
+
+// ExtractTyped extracts an EPU XML file as CryoEMMetadata.
+func (e *EPUExtractor) ExtractTyped(filepath string) (*InstrumentMetadata, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	metadata, cm, err := e.extract(f, filepath)
+	if err != nil {
+		return nil, err
+	}
+	return typedMetadata(metadata, cm), nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *EPUExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	metadata, _, err := e.extract(r, filename)
+	return metadata, err
+}
+
+// extract parses the document and maps it to CryoEMMetadata.
+func (e *EPUExtractor) extract(r io.Reader, filename string) (map[string]interface{}, *CryoEMMetadata, error) {
+	doc, err := parseEPUXML(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "EPU XML",
+		"file_name":       filepath.Base(filename),
+		"extractor_name":  "epu",
+		"schema_name":     "cryoem_v1",
+		"instrument_type": "cryo_em",
+	}
+	cm := doc.cryoEM()
+	cryoEMFields(cm, metadata)
+	doc.addSoftware(metadata)
+	return metadata, cm, nil
+}
+
+// epuDocument holds element values by slash-separated path, in document
+// order, and CustomData key/value pairs.
+type epuDocument struct {
+	paths  []string
+	values []string
+	custom map[string]string
+}
+
+// parseEPUXML flattens an EPU XML document. Namespaces are ignored.
+func parseEPUXML(r io.Reader) (*epuDocument, error) {
+	doc := &epuDocument{custom: map[string]string{}}
+	decoder := xml.NewDecoder(r)
+	var stack []string
+	var pendingKey string
+	root := ""
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a valid EPU XML file: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if root == "" {
+				root = t.Name.Local
+			}
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" || len(stack) == 0 {
+				continue
+			}
+			switch stack[len(stack)-1] {
+			case "Key":
+				pendingKey = text
+				continue
+			case "Value":
+				if pendingKey != "" {
+					if _, seen := doc.custom[pendingKey]; !seen {
+						doc.custom[pendingKey] = text
+					}
+					pendingKey = ""
+					continue
+				}
+			}
+			doc.paths = append(doc.paths, strings.Join(stack, "/"))
+			doc.values = append(doc.values, text)
+		}
+	}
+
+	if root == "" {
+		return nil, fmt.Errorf("not a valid EPU XML file: empty document")
+	}
+	if len(doc.values) == 0 && len(doc.custom) == 0 {
+		return nil, fmt.Errorf("not a valid EPU XML file: no values in <%s>", root)
+	}
+	return doc, nil
+}
+
+// get returns the first value whose path ends with one of the suffixes.
+func (d *epuDocument) get(suffixes ...string) string {
+	for _, suffix := range suffixes {
+		for i, path := range d.paths {
+			if path == suffix || strings.HasSuffix(path, "/"+suffix) {
+				return d.values[i]
+			}
+		}
+	}
+	return ""
+}
+
+// float returns a numeric element or CustomData value.
+func (d *epuDocument) float(value string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return f, err == nil
+}
+
+// cryoEM maps the document to CryoEMMetadata.
+func (d *epuDocument) cryoEM() *CryoEMMetadata {
+	cm := &CryoEMMetadata{
+		Manufacturer:  d.get("instrument/Manufacturer"),
+		Model:         d.get("instrument/InstrumentModel"),
+		SerialNumber:  d.get("instrument/InstrumentID"),
+		DetectorModel: d.custom["DetectorCommercialName"],
+	}
+	if cm.DetectorModel == "" {
+		cm.DetectorModel = d.get("camera/Name")
+	}
+
+	if v, ok := d.float(d.get("gun/AccelerationVoltage")); ok && v > 0 {
+		cm.Voltage = int(v/1000 + 0.5)
+	}
+	if v, ok := d.float(d.get("SphericalAberration")); ok && v > 0 {
+		cm.SphericalAberration = cryoEMCsMillimeters(v)
+	} else if v, ok := d.float(d.custom["SphericalAberration"]); ok && v > 0 {
+		cm.SphericalAberration = cryoEMCsMillimeters(v)
+	}
+	if v, ok := d.float(d.get("TemMagnification/NominalMagnification")); ok {
+		cm.Magnification = v
+	}
+	if v, ok := d.float(d.get("optics/Defocus")); ok {
+		cm.Defocus = v * 1e6
+	} else if v, ok := d.float(d.custom["AppliedDefocus"]); ok {
+		cm.Defocus = v * 1e6
+	}
+	if v, ok := d.float(d.get("pixelSize/x/numericValue")); ok && v > 0 {
+		cm.PixelSize = v * 1e10
+	}
+	if v, ok := d.float(d.get("camera/ExposureTime")); ok {
+		cm.ExposureTime = v
+	}
+	if v, ok := d.float(d.custom["Dose"]); ok && v > 0 {
+		cm.TotalDose = v * 1e-20
+	}
+	for _, key := range []string{"NumberOffractions", "NumberOfFractions", "FrameCount"} {
+		if v, err := strconv.Atoi(d.custom[key]); err == nil && v > 0 {
+			cm.FramesPerMovie = v
+			break
+		}
+	}
+	if n, err := strconv.Atoi(d.get("NumberOffractions", "NumberOfFractions")); err == nil && cm.FramesPerMovie == 0 {
+		cm.FramesPerMovie = n
+	}
+
+	switch {
+	case cryoEMFactor(d.custom["SuperResolutionFactor"]) > 1:
+		cm.DetectorMode = "super-resolution"
+	case strings.EqualFold(d.custom["ElectronCountingEnabled"], "true"):
+		cm.DetectorMode = "counting"
+	case strings.EqualFold(d.custom["ElectronCountingEnabled"], "false"):
+		cm.DetectorMode = "linear"
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, d.get("acquisitionDateTime")); err == nil {
+		cm.AcquisitionDate = t.UTC()
+	}
+	return cm
+}
+
+// addSoftware records the acquisition application.
+func (d *epuDocument) addSoftware(metadata map[string]interface{}) {
+	if name := d.get("core/ApplicationSoftware"); name != "" {
+		metadata["software_name"] = name
+	}
+	if version := d.get("core/ApplicationSoftwareVersion"); version != "" {
+		metadata["software_version"] = version
+	}
+}
+
+// cryoEMFactor parses a numeric factor, returning 0 when absent.
+func cryoEMFactor(value string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return f
+}
+
+// cryoEMCsMillimeters converts Cs to mm; values below 0.1 are in meters.
+func cryoEMCsMillimeters(cs float64) float64 {
+	if cs < 0.1 {
+		return cs * 1000
+	}
+	return cs
+}
+
+// epuSidecarPath returns the EPU XML sidecar for a movie or micrograph, or
+// "" if there is none.
+func epuSidecarPath(path string) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	candidates := []string{stem + ".xml"}
+	for _, suffix := range []string{"_fractions", "_Fractions", "_EER"} {
+		if strings.HasSuffix(stem, suffix) {
+			candidates = append(candidates, strings.TrimSuffix(stem, suffix)+".xml")
+		}
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// mergeEPUSidecar fills fields missing from cm with values from the EPU
+// sidecar next to path, returning the sidecar path used.
+func mergeEPUSidecar(path string, cm *CryoEMMetadata) string {
+	sidecar := epuSidecarPath(path)
+	if sidecar == "" {
+		return ""
+	}
+	f, err := os.Open(sidecar)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+	doc, err := parseEPUXML(f)
+	if err != nil {
+		return ""
+	}
+	mergeCryoEM(cm, doc.cryoEM())
+	return sidecar
+}
+
+// mergeCryoEM copies non-zero fields of src into zero fields of dst.
+func mergeCryoEM(dst, src *CryoEMMetadata) {
+	setString := func(d *string, s string) {
+		if *d == "" {
+			*d = s
+		}
+	}
+	setFloat := func(d *float64, s float64) {
+		if *d == 0 {
+			*d = s
+		}
+	}
+	setInt := func(d *int, s int) {
+		if *d == 0 {
+			*d = s
+		}
+	}
+	setString(&dst.Manufacturer, src.Manufacturer)
+	setString(&dst.Model, src.Model)
+	setString(&dst.SerialNumber, src.SerialNumber)
+	setString(&dst.DetectorModel, src.DetectorModel)
+	setString(&dst.DetectorMode, src.DetectorMode)
+	setString(&dst.SampleID, src.SampleID)
+	setString(&dst.Protein, src.Protein)
+	setString(&dst.Organism, src.Organism)
+	setString(&dst.GridType, src.GridType)
+	setString(&dst.FreezingMethod, src.FreezingMethod)
+	setString(&dst.Operator, src.Operator)
+	setInt(&dst.Voltage, src.Voltage)
+	setInt(&dst.FramesPerMovie, src.FramesPerMovie)
+	setInt(&dst.TotalMovies, src.TotalMovies)
+	setFloat(&dst.SphericalAberration, src.SphericalAberration)
+	setFloat(&dst.PixelSize, src.PixelSize)
+	setFloat(&dst.Magnification, src.Magnification)
+	setFloat(&dst.Defocus, src.Defocus)
+	setFloat(&dst.ExposureTime, src.ExposureTime)
+	setFloat(&dst.TotalDose, src.TotalDose)
+	if dst.AcquisitionDate.IsZero() {
+		dst.AcquisitionDate = src.AcquisitionDate
+	}
+}
+
+// cryoEMFields writes CryoEMMetadata into the output map under its JSON
+// field names, skipping empty values.
+func cryoEMFields(cm *CryoEMMetadata, metadata map[string]interface{}) {
+	for key, value := range map[string]string{
+		"manufacturer":    cm.Manufacturer,
+		"model":           cm.Model,
+		"serial_number":   cm.SerialNumber,
+		"detector_model":  cm.DetectorModel,
+		"detector_mode":   cm.DetectorMode,
+		"sample_id":       cm.SampleID,
+		"protein":         cm.Protein,
+		"organism":        cm.Organism,
+		"grid_type":       cm.GridType,
+		"freezing_method": cm.FreezingMethod,
+		"operator":        cm.Operator,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	if cm.Model != "" {
+		metadata["instrument_model"] = cm.Model
+	}
+	for key, value := range map[string]int{
+		"voltage":          cm.Voltage,
+		"frames_per_movie": cm.FramesPerMovie,
+		"total_movies":     cm.TotalMovies,
+	} {
+		if value != 0 {
+			metadata[key] = value
+		}
+	}
+	for key, value := range map[string]float64{
+		"spherical_aberration": cm.SphericalAberration,
+		"pixel_size":           cm.PixelSize,
+		"magnification":        cm.Magnification,
+		"defocus":              cm.Defocus,
+		"exposure_time":        cm.ExposureTime,
+		"total_dose":           cm.TotalDose,
+	} {
+		if value != 0 {
+			metadata[key] = value
+		}
+	}
+	if !cm.AcquisitionDate.IsZero() {
+		metadata["acquisition_date"] = cm.AcquisitionDate.Format(time.RFC3339)
+	}
+}
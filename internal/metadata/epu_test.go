@@ -0,0 +1,127 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testEPUXML is a trimmed EPU micrograph sidecar.
+const testEPUXML = `<?xml version="1.0" encoding="utf-8"?>
+<MicroscopeImage xmlns="http://schemas.datacontract.org/2004/07/Fei.SharedObjects" xmlns:i="http://www.w3.org/2001/XMLSchema-instance">
+  <CustomData xmlns:a="http://schemas.microsoft.com/2003/10/Serialization/Arrays">
+    <a:KeyValueOfstringanyType>
+      <a:Key>Dose</a:Key>
+      <a:Value xmlns:b="http://www.w3.org/2001/XMLSchema" i:type="b:double">5.0E+21</a:Value>
+    </a:KeyValueOfstringanyType>
+    <a:KeyValueOfstringanyType>
+      <a:Key>DetectorCommercialName</a:Key>
+      <a:Value xmlns:b="http://www.w3.org/2001/XMLSchema" i:type="b:string">Falcon 4i</a:Value>
+    </a:KeyValueOfstringanyType>
+    <a:KeyValueOfstringanyType>
+      <a:Key>ElectronCountingEnabled</a:Key>
+      <a:Value xmlns:b="http://www.w3.org/2001/XMLSchema" i:type="b:boolean">true</a:Value>
+    </a:KeyValueOfstringanyType>
+  </CustomData>
+  <microscopeData>
+    <acquisition><acquisitionDateTime>2024-05-02T10:11:12.5+02:00</acquisitionDateTime></acquisition>
+    <camera><ExposureTime>2.5</ExposureTime><Name>BM-Falcon</Name></camera>
+    <core><ApplicationSoftware>Epu</ApplicationSoftware><ApplicationSoftwareVersion>3.6.0</ApplicationSoftwareVersion></core>
+    <gun><AccelerationVoltage>300000</AccelerationVoltage></gun>
+    <instrument><InstrumentID>3593</InstrumentID><InstrumentModel>TITAN52336320</InstrumentModel><Manufacturer>FEI Company</Manufacturer></instrument>
+    <optics>
+      <Defocus>-1.5E-06</Defocus>
+      <SphericalAberration>0.0027</SphericalAberration>
+      <TemMagnification><NominalMagnification>165000</NominalMagnification></TemMagnification>
+    </optics>
+  </microscopeData>
+  <SpatialScale><pixelSize><x><numericValue>7.3E-11</numericValue></x><y><numericValue>7.3E-11</numericValue></y></pixelSize></SpatialScale>
+</MicroscopeImage>`
+
+func assertFloat(t *testing.T, metadata map[string]interface{}, key string, want float64) {
+	t.Helper()
+	got, ok := metadata[key].(float64)
+	if !ok || math.Abs(got-want) > 1e-6*math.Max(1, math.Abs(want)) {
+		t.Errorf("%s = %v, want %v", key, metadata[key], want)
+	}
+}
+
+func TestEPUExtractor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "FoilHole_1_Data_2_3_20240502_101112.xml")
+	if err := os.WriteFile(path, []byte(testEPUXML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+	if extractor := registry.FindExtractor(path); extractor == nil || extractor.Name() != "EPU" {
+		t.Fatalf("FindExtractor(%s) = %v, want EPU", path, extractor)
+	}
+	metadata, err := registry.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":           "EPU XML",
+		"manufacturer":     "FEI Company",
+		"model":            "TITAN52336320",
+		"serial_number":    "3593",
+		"detector_model":   "Falcon 4i",
+		"detector_mode":    "counting",
+		"voltage":          300,
+		"software_name":    "Epu",
+		"software_version": "3.6.0",
+		"acquisition_date": "2024-05-02T08:11:12Z",
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+	assertFloat(t, metadata, "spherical_aberration", 2.7)
+	assertFloat(t, metadata, "magnification", 165000)
+	assertFloat(t, metadata, "defocus", -1.5)
+	assertFloat(t, metadata, "pixel_size", 0.73)
+	assertFloat(t, metadata, "exposure_time", 2.5)
+	assertFloat(t, metadata, "total_dose", 50)
+}
+
+func TestEPUExtractorCanHandle(t *testing.T) {
+	dir := t.TempDir()
+	sniffed := filepath.Join(dir, "tilt_01.xml")
+	other := filepath.Join(dir, "settings.xml")
+	if err := os.WriteFile(sniffed, []byte(testEPUXML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other, []byte(`<settings><a>1</a></settings>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extractor := &EPUExtractor{}
+	if !extractor.CanHandle(sniffed) {
+		t.Error("CanHandle should recognise EPU content")
+	}
+	if extractor.CanHandle(other) {
+		t.Error("CanHandle should reject unrelated XML")
+	}
+	if _, err := extractor.ExtractFromReader(strings.NewReader("<broken"), "x.xml"); err == nil {
+		t.Error("expected error for malformed XML")
+	}
+}
@@ -0,0 +1,1245 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file implements the expression language used by
+// Schema.ValidationRules and FieldSchema.RequiredIf.
+//
+// Expressions read metadata fields and cannot call out of the evaluator:
+// there are no loops, assignments or user functions, and regular
+// expressions use RE2, so evaluation time is bounded by the expression size.
+//
+// # Syntax
+//
+//	pixel_size_x_um > 0 && pixel_size_x_um < 10
+//	num_channels == len(channels)
+//	if modality == 'confocal' then pinhole_size_um required
+//	if format in ['CZI', 'LIF'] then objective_na >= 0.1 else true
+//	channels[0].dye_name != '' and not lower(format) == 'tiff'
+//	matches(flowcell_id, '^[A-Z0-9]{9}$')
+//
+// Operators, from lowest to highest precedence:
+//
+//	if c then a [else b]      a rule with no else holds when c is false
+//	|| or
+//	&& and
+//	not                       negates a comparison
+//	== != < <= > >= in
+//	+ -                       + also joins strings
+//	* / %
+//	! -                       unary
+//	x required                x is present and not empty
+//
+// Literals are numbers, 'single' or "double" quoted strings, true, false,
+// null and lists such as [1, 2]. A field is named directly; nested values are
+// reached with a.b and a[0]. Built-in functions are len, exists, lower, upper,
+// matches, abs, min and max.
+//
+// # Types
+//
+// Values are null, numbers, strings, booleans, lists and objects. Every
+// numeric type reads as a number and times read as RFC 3339 strings.
+// Comparing unlike types, or using a non-boolean as a condition, is an error
+// rather than false, so a mistyped rule is reported instead of silently
+// passing.
+//
+// A field that is absent reads as null. Ordering, arithmetic or a condition
+// on an absent field stops evaluation with an ExprError whose Missing is set;
+// validation treats such a rule as not applicable. Use exists or required to
+// test for presence.
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxExprLength caps the source length of an expression.
+	maxExprLength = 4096
+
+	// maxExprDepth caps the nesting depth of an expression.
+	maxExprDepth = 64
+)
+
+// ExprError describes a syntax or evaluation error in an expression.
+type ExprError struct {
+	// Expr is the expression source.
+	Expr string
+
+	// Pos is the 1-based column of the error, or 0 if unknown.
+	Pos int
+
+	// Field is the field involved, if any.
+	Field string
+
+	// Msg describes the error.
+	Msg string
+
+	// Missing is set when evaluation stopped on an absent field.
+	Missing bool
+}
+
+// Error implements error.
+func (e *ExprError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rule %q", e.Expr)
+	if e.Pos > 0 {
+		fmt.Fprintf(&b, ": column %d", e.Pos)
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&b, ": field %s", e.Field)
+	}
+	b.WriteString(": " + e.Msg)
+	return b.String()
+}
+
+// Expr is a compiled expression.
+type Expr struct {
+	src    string
+	root   exprNode
+	fields []string
+}
+
+// CompileExpr parses an expression.
+func CompileExpr(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, &ExprError{Expr: src, Msg: "empty expression"}
+	}
+	if len(src) > maxExprLength {
+		return nil, &ExprError{Expr: src, Msg: fmt.Sprintf("expression longer than %d characters", maxExprLength)}
+	}
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{src: src, tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	return &Expr{src: src, root: root, fields: p.fields}, nil
+}
+
+// String returns the expression source.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Fields returns the top-level fields the expression reads, in order of
+// first use.
+func (e *Expr) Fields() []string {
+	return e.fields
+}
+
+// Eval evaluates the expression against fields.
+func (e *Expr) Eval(fields map[string]interface{}) (interface{}, error) {
+	return e.root.eval(&exprEnv{src: e.src, fields: fields})
+}
+
+// EvalBool evaluates an expression that must yield a boolean.
+func (e *Expr) EvalBool(fields map[string]interface{}) (bool, error) {
+	env := &exprEnv{src: e.src, fields: fields}
+	v, err := e.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	return env.toBool(e.root, v)
+}
+
+// Subject returns the field a failed rule is best reported against: the
+// field a required check names, otherwise the first field read.
+func (e *Expr) Subject() string {
+	node := e.root
+	if n, ok := node.(*ifNode); ok && n.els == nil {
+		node = n.then
+	}
+	if n, ok := node.(*requiredNode); ok {
+		return n.path.text
+	}
+	if len(e.fields) > 0 {
+		return e.fields[0]
+	}
+	return ""
+}
+
+// EvalRule compiles and evaluates a boolean expression against fields.
+func EvalRule(src string, fields map[string]interface{}) (bool, error) {
+	expr, err := CompileExpr(src)
+	if err != nil {
+		return false, err
+	}
+	return expr.EvalBool(fields)
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	num  float64
+}
+
+// String describes the token for error messages.
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// exprOps are the operator tokens, longest first.
+var exprOps = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",", "."}
+
+// lexExpr splits src into tokens.
+func lexExpr(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r >= '0' && r <= '9' || r == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					for i = j; i < len(src) && isDigit(src[i]); i++ {
+					}
+				}
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, &ExprError{Expr: src, Pos: start + 1, Msg: fmt.Sprintf("invalid number %q", src[start:i])}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], pos: start + 1, num: num})
+
+		case r == '\'' || r == '"':
+			start := i
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(src) {
+				c := src[i]
+				if c == byte(r) {
+					closed = true
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(src) {
+					switch next := src[i+1]; next {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(next)
+					}
+					i += 2
+					continue
+				}
+				b.WriteByte(c)
+				i++
+			}
+			if !closed {
+				return nil, &ExprError{Expr: src, Pos: start + 1, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: start + 1})
+
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start + 1})
+
+		default:
+			matched := false
+			for _, op := range exprOps {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokOp, text: op, pos: i + 1})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &ExprError{Expr: src, Pos: i + 1, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src) + 1}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Parser
+
+// exprKeywords cannot be used as field names.
+var exprKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "if": true, "then": true,
+	"else": true, "true": true, "false": true, "null": true, "required": true,
+}
+
+// exprFuncs are the built-in functions and their argument counts; -1 means
+// one or more.
+var exprFuncs = map[string]int{
+	"len": 1, "exists": 1, "lower": 1, "upper": 1, "matches": 2, "abs": 1, "min": -1, "max": -1,
+}
+
+type exprParser struct {
+	src    string
+	tokens []token
+	next   int
+	depth  int
+	fields []string
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *exprParser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokEOF {
+		p.next++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the operator or keyword text.
+func (p *exprParser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.kind == tokOp || tok.kind == tokIdent) && tok.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.accept(text) {
+		tok := p.peek()
+		return p.errorf(tok, "expected %q, found %s", text, tok)
+	}
+	return nil
+}
+
+func (p *exprParser) errorf(tok token, format string, args ...interface{}) error {
+	return &ExprError{Expr: p.src, Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// enter guards against deeply nested input.
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > maxExprDepth {
+		return p.errorf(p.peek(), "expression nested deeper than %d levels", maxExprDepth)
+	}
+	return nil
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	tok := p.peek()
+	if !p.accept("if") {
+		return p.parseOr()
+	}
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	node := &ifNode{at: tok.pos, cond: cond, then: then}
+	if p.accept("else") {
+		if node.els, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.accept("||") && !p.accept("or") {
+			return x, nil
+		}
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{at: tok.pos, op: "||", x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.accept("&&") && !p.accept("and") {
+			return x, nil
+		}
+		y, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{at: tok.pos, op: "&&", x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	tok := p.peek()
+	if !p.accept("not") {
+		return p.parseCompare()
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return &unaryNode{at: tok.pos, op: "!", x: x}, nil
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	x, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	op := ""
+	switch {
+	case tok.kind == tokOp && (tok.text == "==" || tok.text == "!=" || tok.text == "<" || tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+		op = tok.text
+	case tok.kind == tokIdent && tok.text == "in":
+		op = "in"
+	case tok.kind == tokIdent && tok.text == "not" && p.tokens[p.next+1].text == "in":
+		p.next++
+		op = "not in"
+	default:
+		return x, nil
+	}
+	p.next++
+	y, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{at: tok.pos, op: op, x: x, y: y}, nil
+}
+
+func (p *exprParser) parseAdd() (exprNode, error) {
+	x, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.accept("+") && !p.accept("-") {
+			return x, nil
+		}
+		y, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{at: tok.pos, op: tok.text, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseMul() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.accept("*") && !p.accept("/") && !p.accept("%") {
+			return x, nil
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{at: tok.pos, op: tok.text, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	tok := p.peek()
+	if !p.accept("!") && !p.accept("-") {
+		return p.parsePostfix()
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &unaryNode{at: tok.pos, op: tok.text, x: x}, nil
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if !p.accept("required") {
+		return x, nil
+	}
+	path, ok := x.(*pathNode)
+	if !ok {
+		return nil, p.errorf(tok, "required must follow a field name")
+	}
+	return &requiredNode{at: tok.pos, path: path}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokNumber:
+		return &literalNode{at: tok.pos, value: tok.num}, nil
+	case tokString:
+		return &literalNode{at: tok.pos, value: tok.text}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{at: tok.pos, items: items}, nil
+		}
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{at: tok.pos, value: true}, nil
+		case "false":
+			return &literalNode{at: tok.pos, value: false}, nil
+		case "null":
+			return &literalNode{at: tok.pos, value: nil}, nil
+		}
+		if exprKeywords[tok.text] {
+			break
+		}
+		if p.accept("(") {
+			return p.parseCall(tok)
+		}
+		return p.parsePath(tok)
+	}
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+// parseList parses comma-separated expressions up to the closing token.
+func (p *exprParser) parseList(closing string) ([]exprNode, error) {
+	var items []exprNode
+	if p.accept(closing) {
+		return items, nil
+	}
+	for {
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.accept(closing) {
+			return items, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	arity, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %s", name.text)
+	}
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+	if arity >= 0 && len(args) != arity || arity < 0 && len(args) == 0 {
+		return nil, p.errorf(name, "wrong number of arguments to %s", name.text)
+	}
+	return &callNode{at: name.pos, name: name.text, args: args}, nil
+}
+
+func (p *exprParser) parsePath(name token) (exprNode, error) {
+	node := &pathNode{at: name.pos, name: name.text}
+	end := name.pos - 1 + len(name.text)
+	for {
+		switch {
+		case p.accept("."):
+			key := p.advance()
+			if key.kind != tokIdent {
+				return nil, p.errorf(key, "expected field name after \".\", found %s", key)
+			}
+			node.parts = append(node.parts, pathPart{key: key.text})
+			end = key.pos - 1 + len(key.text)
+		case p.accept("["):
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			closing := p.peek()
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node.parts = append(node.parts, pathPart{index: index})
+			end = closing.pos
+		default:
+			node.text = p.src[name.pos-1 : end]
+			if !contains(p.fields, name.text) {
+				p.fields = append(p.fields, name.text)
+			}
+			return node, nil
+		}
+	}
+}
+
+// Evaluation
+
+type exprNode interface {
+	pos() int
+	eval(env *exprEnv) (interface{}, error)
+}
+
+type exprEnv struct {
+	src    string
+	fields map[string]interface{}
+}
+
+// errorf reports an error at node, naming the field if node is one.
+func (env *exprEnv) errorf(node exprNode, format string, args ...interface{}) *ExprError {
+	err := &ExprError{Expr: env.src, Pos: node.pos(), Msg: fmt.Sprintf(format, args...)}
+	if path, ok := node.(*pathNode); ok {
+		err.Field = path.text
+	}
+	return err
+}
+
+// missing reports that evaluation reached an absent value at node. A null
+// literal is a type error instead.
+func (env *exprEnv) missing(node exprNode, want string) error {
+	path, ok := node.(*pathNode)
+	if !ok {
+		return env.errorf(node, "expected %s, got null", want)
+	}
+	return &ExprError{Expr: env.src, Pos: node.pos(), Field: path.text, Msg: "field is missing", Missing: true}
+}
+
+func (env *exprEnv) toBool(node exprNode, v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case nil:
+		return false, env.missing(node, "boolean")
+	}
+	return false, env.errorf(node, "expected boolean, got %s", exprTypeName(v))
+}
+
+func (env *exprEnv) toNumber(node exprNode, v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case nil:
+		return 0, env.missing(node, "number")
+	}
+	return 0, env.errorf(node, "expected number, got %s", exprTypeName(v))
+}
+
+func (env *exprEnv) toString(node exprNode, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", env.missing(node, "string")
+	}
+	return "", env.errorf(node, "expected string, got %s", exprTypeName(v))
+}
+
+// exprValue converts a field value to an expression value: nil, float64,
+// string, bool, []interface{} or map[string]interface{}.
+func exprValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, float64, string, bool, []interface{}, map[string]interface{}:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = iter.Value().Interface()
+		}
+		return m
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return exprValue(rv.Elem().Interface())
+	}
+	return fmt.Sprint(v)
+}
+
+func exprTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+type literalNode struct {
+	at    int
+	value interface{}
+}
+
+func (n *literalNode) pos() int { return n.at }
+
+func (n *literalNode) eval(env *exprEnv) (interface{}, error) {
+	return n.value, nil
+}
+
+type listNode struct {
+	at    int
+	items []exprNode
+}
+
+func (n *listNode) pos() int { return n.at }
+
+func (n *listNode) eval(env *exprEnv) (interface{}, error) {
+	items := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+type pathPart struct {
+	key   string
+	index exprNode
+}
+
+type pathNode struct {
+	at    int
+	name  string
+	parts []pathPart
+	text  string
+}
+
+func (n *pathNode) pos() int { return n.at }
+
+// eval returns the value at the path, or nil if any step is absent.
+func (n *pathNode) eval(env *exprEnv) (interface{}, error) {
+	v := exprValue(env.fields[n.name])
+	for _, part := range n.parts {
+		if v == nil {
+			return nil, nil
+		}
+		if part.index == nil {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, env.errorf(n, "cannot read .%s of %s", part.key, exprTypeName(v))
+			}
+			v = exprValue(m[part.key])
+			continue
+		}
+		index, err := part.index.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		switch container := v.(type) {
+		case []interface{}:
+			i, err := env.toNumber(part.index, index)
+			if err != nil {
+				return nil, err
+			}
+			if i != math.Trunc(i) || i < 0 {
+				return nil, env.errorf(part.index, "invalid list index %v", i)
+			}
+			if int(i) >= len(container) {
+				return nil, nil
+			}
+			v = exprValue(container[int(i)])
+		case map[string]interface{}:
+			key, err := env.toString(part.index, index)
+			if err != nil {
+				return nil, err
+			}
+			v = exprValue(container[key])
+		default:
+			return nil, env.errorf(n, "cannot index %s", exprTypeName(v))
+		}
+	}
+	return v, nil
+}
+
+type requiredNode struct {
+	at   int
+	path *pathNode
+}
+
+func (n *requiredNode) pos() int { return n.at }
+
+// eval reports whether the field is present and not an empty string, list
+// or object.
+func (n *requiredNode) eval(env *exprEnv) (interface{}, error) {
+	v, err := n.path.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case nil:
+		return false, nil
+	case string:
+		return strings.TrimSpace(v) != "", nil
+	case []interface{}:
+		return len(v) > 0, nil
+	case map[string]interface{}:
+		return len(v) > 0, nil
+	}
+	return true, nil
+}
+
+type ifNode struct {
+	at              int
+	cond, then, els exprNode
+}
+
+func (n *ifNode) pos() int { return n.at }
+
+func (n *ifNode) eval(env *exprEnv) (interface{}, error) {
+	v, err := n.cond.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := env.toBool(n.cond, v)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case cond:
+		return n.then.eval(env)
+	case n.els != nil:
+		return n.els.eval(env)
+	}
+	return true, nil
+}
+
+type unaryNode struct {
+	at int
+	op string
+	x  exprNode
+}
+
+func (n *unaryNode) pos() int { return n.at }
+
+func (n *unaryNode) eval(env *exprEnv) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "-" {
+		f, err := env.toNumber(n.x, v)
+		return -f, err
+	}
+	b, err := env.toBool(n.x, v)
+	return !b, err
+}
+
+type binaryNode struct {
+	at   int
+	op   string
+	x, y exprNode
+}
+
+func (n *binaryNode) pos() int { return n.at }
+
+func (n *binaryNode) eval(env *exprEnv) (interface{}, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit
+	if n.op == "&&" || n.op == "||" {
+		b, err := env.toBool(n.x, x)
+		if err != nil || b == (n.op == "||") {
+			return b, err
+		}
+		y, err := n.y.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		return env.toBool(n.y, y)
+	}
+
+	y, err := n.y.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==", "!=":
+		eq, ok := exprEqual(x, y)
+		if !ok {
+			return nil, env.compareError(n, x, y)
+		}
+		return eq == (n.op == "=="), nil
+
+	case "<", "<=", ">", ">=":
+		cmp, err := env.order(n, x, y)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+		return cmp >= 0, nil
+
+	case "in", "not in":
+		in, err := env.in(n, x, y)
+		return in == (n.op == "in"), err
+
+	case "+":
+		if xs, ok := x.(string); ok {
+			ys, err := env.toString(n.y, y)
+			return xs + ys, err
+		}
+	}
+
+	xf, err := env.toNumber(n.x, x)
+	if err != nil {
+		return nil, err
+	}
+	yf, err := env.toNumber(n.y, y)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "+":
+		return xf + yf, nil
+	case "-":
+		return xf - yf, nil
+	case "*":
+		return xf * yf, nil
+	}
+	if yf == 0 {
+		return nil, env.errorf(n.y, "division by zero")
+	}
+	if n.op == "%" {
+		return math.Mod(xf, yf), nil
+	}
+	return xf / yf, nil
+}
+
+// compareError reports unlike operands, naming a field operand if there is
+// one.
+func (env *exprEnv) compareError(n *binaryNode, x, y interface{}) error {
+	node := n.x
+	if _, ok := node.(*pathNode); !ok {
+		node = n.y
+	}
+	err := env.errorf(node, "cannot compare %s with %s", exprTypeName(x), exprTypeName(y))
+	err.Pos = n.at
+	return err
+}
+
+// order compares two numbers or two strings.
+func (env *exprEnv) order(n *binaryNode, x, y interface{}) (int, error) {
+	if x == nil {
+		return 0, env.missing(n.x, "value")
+	}
+	if y == nil {
+		return 0, env.missing(n.y, "value")
+	}
+	switch xv := x.(type) {
+	case float64:
+		if yv, ok := y.(float64); ok {
+			switch {
+			case xv < yv:
+				return -1, nil
+			case xv > yv:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case string:
+		if yv, ok := y.(string); ok {
+			return strings.Compare(xv, yv), nil
+		}
+	}
+	return 0, env.compareError(n, x, y)
+}
+
+// in reports whether x is an item of a list, a substring of a string or a
+// key of an object.
+func (env *exprEnv) in(n *binaryNode, x, y interface{}) (bool, error) {
+	switch container := y.(type) {
+	case nil:
+		return false, env.missing(n.y, "list")
+	case []interface{}:
+		for _, item := range container {
+			if eq, _ := exprEqual(x, exprValue(item)); eq {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		s, err := env.toString(n.x, x)
+		return strings.Contains(container, s), err
+	case map[string]interface{}:
+		key, err := env.toString(n.x, x)
+		_, ok := container[key]
+		return ok, err
+	}
+	return false, env.errorf(n.y, "expected list, string or object, got %s", exprTypeName(y))
+}
+
+// exprEqual compares two values. ok is false if they cannot be compared.
+func exprEqual(x, y interface{}) (eq, ok bool) {
+	if x == nil || y == nil {
+		return x == nil && y == nil, true
+	}
+	switch xv := x.(type) {
+	case float64:
+		yv, ok := y.(float64)
+		return ok && xv == yv, ok
+	case string:
+		yv, ok := y.(string)
+		return ok && xv == yv, ok
+	case bool:
+		yv, ok := y.(bool)
+		return ok && xv == yv, ok
+	case []interface{}:
+		yv, ok := y.([]interface{})
+		if !ok {
+			return false, false
+		}
+		if len(xv) != len(yv) {
+			return false, true
+		}
+		for i := range xv {
+			if eq, _ := exprEqual(exprValue(xv[i]), exprValue(yv[i])); !eq {
+				return false, true
+			}
+		}
+		return true, true
+	case map[string]interface{}:
+		yv, ok := y.(map[string]interface{})
+		if !ok {
+			return false, false
+		}
+		if len(xv) != len(yv) {
+			return false, true
+		}
+		for k, v := range xv {
+			if eq, _ := exprEqual(exprValue(v), exprValue(yv[k])); !eq {
+				return false, true
+			}
+		}
+		return true, true
+	}
+	return false, false
+}
+
+type callNode struct {
+	at   int
+	name string
+	args []exprNode
+}
+
+func (n *callNode) pos() int { return n.at }
+
+func (n *callNode) eval(env *exprEnv) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch n.name {
+	case "exists":
+		return args[0] != nil, nil
+
+	case "len":
+		switch v := args[0].(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		case nil:
+			return nil, env.missing(n.args[0], "list")
+		}
+		return nil, env.errorf(n.args[0], "len of %s", exprTypeName(args[0]))
+
+	case "lower", "upper":
+		s, err := env.toString(n.args[0], args[0])
+		if err != nil {
+			return nil, err
+		}
+		if n.name == "lower" {
+			return strings.ToLower(s), nil
+		}
+		return strings.ToUpper(s), nil
+
+	case "matches":
+		s, err := env.toString(n.args[0], args[0])
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := env.toString(n.args[1], args[1])
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, env.errorf(n.args[1], "invalid pattern: %v", err)
+		}
+		return re.MatchString(s), nil
+
+	case "abs":
+		f, err := env.toNumber(n.args[0], args[0])
+		return math.Abs(f), err
+	}
+
+	// min and max
+	var result float64
+	for i, arg := range args {
+		f, err := env.toNumber(n.args[i], arg)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 || n.name == "min" && f < result || n.name == "max" && f > result {
+			result = f
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testExprFields resembles normalized extractor output, with the sized
+// types extractors return.
+func testExprFields() map[string]interface{} {
+	return map[string]interface{}{
+		"format":          "CZI",
+		"modality":        "confocal",
+		"num_channels":    int32(2),
+		"pixel_size_x_um": float32(0.5),
+		"image_width":     uint16(2048),
+		"objective_na":    1.4,
+		"channels": []map[string]interface{}{
+			{"dye_name": "DAPI", "emission_wavelength_nm": 461},
+			{"dye_name": "GFP", "emission_wavelength_nm": 509},
+		},
+		"tags":             []string{"nucleus", "live"},
+		"acquisition_date": time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		"empty":            "  ",
+	}
+}
+
+func TestEvalRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"pixel_size_x_um > 0 && pixel_size_x_um < 10", true},
+		{"pixel_size_x_um > 0 and pixel_size_x_um < 0.1", false},
+		{"num_channels == len(channels)", true},
+		{"num_channels * 1024 == image_width", true},
+		{"image_width / num_channels % 1000 == 24", true},
+		{"-objective_na < -1", true},
+		{"if modality == 'confocal' then pinhole_size_um required", false},
+		{"if modality == 'widefield' then pinhole_size_um required", true},
+		{"if modality == 'widefield' then false else format required", true},
+		{"empty required", false},
+		{"channels required && tags required", true},
+		{"channels[1].dye_name == \"GFP\"", true},
+		{"channels[1]['emission_wavelength_nm'] > 500", true},
+		{"channels[5].dye_name == null", true},
+		{"format in ['CZI', 'LIF'] && 'live' in tags", true},
+		{"format not in ['CZI', 'LIF']", false},
+		{"'ZI' in format", true},
+		{"not modality == 'widefield'", true},
+		{"!(format == 'CZI') || objective_na >= 1.4", true},
+		{"lower(format) == 'czi' && upper('x') == 'X'", true},
+		{"matches(format, '^C[A-Z]+$')", true},
+		{"acquisition_date >= '2025-01-01' && acquisition_date < '2026'", true},
+		{"format + '-' + modality == 'CZI-confocal'", true},
+		{"abs(-2) == 2 && min(3, num_channels, 4) == 2 && max(1, 5) == 5", true},
+		{"exists(format) && !exists(missing)", true},
+		{"missing == null && missing != 1", true},
+		{"[1, 2] == [1, 2] && len('µm') == 2", true},
+		{"1.5e3 == 1500 && .5 == 0.5", true},
+		{"missing > 0 || true", false}, // stops on the missing field
+	}
+	fields := testExprFields()
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := EvalRule(tt.rule, fields)
+			var exprErr *ExprError
+			if errors.As(err, &exprErr) && exprErr.Missing {
+				if tt.want {
+					t.Errorf("EvalRule() stopped on missing field %s", exprErr.Field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalRule() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EvalRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalRule_Missing(t *testing.T) {
+	_, err := EvalRule("pixel_size_y_um > 0 && pixel_size_y_um < 10", testExprFields())
+	var exprErr *ExprError
+	if !errors.As(err, &exprErr) || !exprErr.Missing || exprErr.Field != "pixel_size_y_um" {
+		t.Fatalf("EvalRule() error = %v, want missing pixel_size_y_um", err)
+	}
+}
+
+func TestEvalRule_Errors(t *testing.T) {
+	tests := []struct {
+		rule  string
+		field string
+		want  string
+	}{
+		{"format > 1", "format", "cannot compare string with number"},
+		{"num_channels == 'two'", "num_channels", "cannot compare number with string"},
+		{"modality && true", "modality", "expected boolean, got string"},
+		{"num_channels + 1", "", "expected boolean, got number"},
+		{"image_width / 0 > 1", "", "division by zero"},
+		{"channels[-1].dye_name == 'x'", "", "invalid list index"},
+		{"format.name == 'x'", "format.name", "cannot read .name of string"},
+		{"len(num_channels) > 0", "num_channels", "len of number"},
+		{"matches(format, '[')", "", "invalid pattern"},
+		{"null > 1", "", "expected value, got null"},
+	}
+	fields := testExprFields()
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := EvalRule(tt.rule, fields)
+			var exprErr *ExprError
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("EvalRule() error = %v, want ExprError", err)
+			}
+			if exprErr.Missing || exprErr.Field != tt.field || !strings.Contains(exprErr.Msg, tt.want) {
+				t.Errorf("EvalRule() error = %+v, want field %q and %q", exprErr, tt.field, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.rule) {
+				t.Errorf("Error() = %q, want the rule quoted", err.Error())
+			}
+		})
+	}
+}
+
+func TestCompileExpr_Errors(t *testing.T) {
+	tests := []struct {
+		rule string
+		pos  int
+		want string
+	}{
+		{"", 0, "empty expression"},
+		{"a >", 4, "unexpected end of expression"},
+		{"a > 1 b", 7, `unexpected "b"`},
+		{"(a > 1", 7, `expected ")"`},
+		{"a == 'x", 6, "unterminated string"},
+		{"a # b", 3, "unexpected character"},
+		{"size(a) > 1", 1, "unknown function size"},
+		{"matches(a)", 1, "wrong number of arguments"},
+		{"1 required", 3, "required must follow a field name"},
+		{"if a then", 10, "unexpected end of expression"},
+		{"a. == 2", 4, "expected field name"},
+		{strings.Repeat("(", 100) + "a" + strings.Repeat(")", 100), 0, "nested deeper"},
+		{strings.Repeat("a", maxExprLength+1), 0, "longer than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			_, err := CompileExpr(tt.rule)
+			var exprErr *ExprError
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("CompileExpr() error = %v, want ExprError", err)
+			}
+			if !strings.Contains(exprErr.Msg, tt.want) || tt.pos != 0 && exprErr.Pos != tt.pos {
+				t.Errorf("CompileExpr() error = %v (column %d), want %q at column %d", err, exprErr.Pos, tt.want, tt.pos)
+			}
+		})
+	}
+}
+
+func TestExpr_FieldsAndSubject(t *testing.T) {
+	tests := []struct {
+		rule    string
+		fields  []string
+		subject string
+	}{
+		{"num_channels == len(channels)", []string{"num_channels", "channels"}, "num_channels"},
+		{"if modality == 'confocal' then pinhole_size_um required", []string{"modality", "pinhole_size_um"}, "pinhole_size_um"},
+		{"channels[0].dye_name != '' && channels[1].dye_name != ''", []string{"channels"}, "channels"},
+		{"true", nil, ""},
+	}
+	for _, tt := range tests {
+		expr, err := CompileExpr(tt.rule)
+		if err != nil {
+			t.Fatalf("CompileExpr(%q) error = %v", tt.rule, err)
+		}
+		if strings.Join(expr.Fields(), ",") != strings.Join(tt.fields, ",") {
+			t.Errorf("Fields(%q) = %v, want %v", tt.rule, expr.Fields(), tt.fields)
+		}
+		if expr.Subject() != tt.subject {
+			t.Errorf("Subject(%q) = %q, want %q", tt.rule, expr.Subject(), tt.subject)
+		}
+	}
+}
+
+func TestSchemaManager_ValidationRules(t *testing.T) {
+	manager := NewSchemaManager(NewFileSchemaLoader())
+	if err := manager.AddSchema(&Schema{
+		Name: "rules",
+		Fields: map[string]FieldSchema{
+			"modality":        {Type: "string"},
+			"num_channels":    {Type: "integer"},
+			"channels":        {Type: "array"},
+			"pinhole_size_um": {Type: "number", RequiredIf: "modality == 'confocal'"},
+			"pixel_size_x_um": {Type: "number"},
+		},
+		ValidationRules: []ValidationRule{
+			{Rule: "num_channels == len(channels)", Message: "Channel count does not match"},
+			{Rule: "pixel_size_x_um > 0 && pixel_size_x_um < 10"},
+			{Rule: "modality > 1"},
+		},
+	}); err != nil {
+		t.Fatalf("AddSchema() error = %v", err)
+	}
+
+	result := manager.ValidateMetadata(&Metadata{
+		SchemaName: "rules",
+		Fields: map[string]interface{}{
+			"modality":     "confocal",
+			"num_channels": 3,
+			"channels":     []interface{}{"DAPI", "GFP"},
+		},
+	})
+	if result.Valid {
+		t.Fatal("ValidateMetadata() valid, want rule failures")
+	}
+
+	got := make(map[string]ValidationError)
+	for _, e := range result.Errors {
+		got[e.Type+":"+e.Field] = e
+	}
+	if e, ok := got["missing_field:pinhole_size_um"]; !ok || !strings.Contains(e.Message, "required if modality == 'confocal'") {
+		t.Errorf("missing pinhole_size_um error = %+v", e)
+	}
+	if e, ok := got["rule_failed:num_channels"]; !ok || !strings.Contains(e.Message, "Channel count does not match (rule: num_channels == len(channels))") {
+		t.Errorf("channel rule error = %+v", e)
+	}
+	if e, ok := got["invalid_rule:modality"]; !ok || !strings.Contains(e.Message, "cannot compare string with number") {
+		t.Errorf("type error = %+v", e)
+	}
+	// The pixel size rule does not apply without a pixel size
+	if len(result.Errors) != 3 {
+		t.Errorf("ValidateMetadata() errors = %+v, want 3", result.Errors)
+	}
+
+	result = manager.ValidateMetadata(&Metadata{
+		SchemaName: "rules",
+		Fields: map[string]interface{}{
+			"modality":        "widefield",
+			"num_channels":    2,
+			"channels":        []interface{}{"DAPI", "GFP"},
+			"pixel_size_x_um": 12.5,
+		},
+	})
+	if len(result.Errors) != 2 || result.Errors[0].Type != "rule_failed" || result.Errors[0].Message != "Rule failed (rule: pixel_size_x_um > 0 && pixel_size_x_um < 10)" {
+		t.Errorf("ValidateMetadata() errors = %+v", result.Errors)
+	}
+}
+
+func TestFileSchemaLoader_InvalidRule(t *testing.T) {
+	dir := t.TempDir()
+	path := writeSchemaFile(t, dir, "bad.yaml", `
+name: bad
+size:
+  type: number
+  required_if: "mode =="
+validation:
+  - rule: "size > "
+`)
+	_, err := NewFileSchemaLoader().LoadFromFile(path)
+	if err == nil {
+		t.Fatal("LoadFromFile() error = nil, want rule errors")
+	}
+	for _, want := range []string{"field size: required_if", "validation rule 1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadFromFile() error = %v, want %q", err, want)
+		}
+	}
+}
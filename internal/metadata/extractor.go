@@ -28,6 +28,16 @@ type Extractor interface {
 	SupportedFormats() []string
 }
 
+// TypedExtractor is an optional interface for extractors that build one of
+// the instrument structs in types.go themselves. The registry falls back to
+// NormalizeMetadata for extractors that only return a map.
+type TypedExtractor interface {
+	Extractor
+
+	// ExtractTyped extracts metadata from a file in typed form
+	ExtractTyped(filepath string) (*InstrumentMetadata, error)
+}
+
 // ExtractorRegistry manages metadata extractors
 type ExtractorRegistry struct {
 	extractors []Extractor
@@ -47,31 +57,52 @@ func (r *ExtractorRegistry) Register(extractor Extractor) {
 
 // RegisterDefaults registers default extractors
 func (r *ExtractorRegistry) RegisterDefaults() {
-	// Image formats
-	r.Register(&TIFFExtractor{})
+	// Image formats (OME-TIFF before TIFF so .ome.tif files reach it)
 	r.Register(&OMETIFFExtractor{})
+	r.Register(&TIFFExtractor{})
 	r.Register(&ZeissExtractor{}) // .czi
 	r.Register(&NikonExtractor{}) // .nd2
 	r.Register(&LeicaExtractor{}) // .lif
 
 	// Sequencing formats
 	r.Register(&FASTQExtractor{})
-	r.Register(&BAMExtractor{})
+	r.Register(&BAMExtractor{})   // .bam, .sam, .cram
+	r.Register(&POD5Extractor{})  // .pod5 nanopore signal
+	r.Register(&FAST5Extractor{}) // .fast5 nanopore signal
 
 	// Mass spec formats
-	r.Register(&MzMLExtractor{})
+	r.Register(&MzMLExtractor{}) // .mzml, .mzxml
 	r.Register(&MGFExtractor{})
 
 	// Other formats
-	r.Register(&HDF5Extractor{})
-	r.Register(&ZarrExtractor{})
-	r.Register(&DICOMExtractor{})
-	r.Register(&FCSExtractor{}) // Flow cytometry
+	r.Register(&HDF5Extractor{})  // .h5, .hdf5, .nxs, .ims
+	r.Register(&ZarrExtractor{})  // .zarr stores (directories)
+	r.Register(&DICOMExtractor{}) // .dcm, .dicom
+	r.Register(&FCSExtractor{})   // .fcs flow cytometry
+	r.Register(&MRCExtractor{})   // .mrc, .mrcs, .map, .st, .rec
+	r.Register(&EERExtractor{})   // .eer
+	r.Register(&EPUExtractor{})   // EPU/Tomo .xml sidecars
 
 	// Generic fallback
 	r.Register(&GenericExtractor{})
 }
 
+// ReadSampler is an optional interface for extractors that can sample
+// records after the header, such as the SAM, BAM and CRAM extractor.
+type ReadSampler interface {
+	SetSampleReads(n int)
+}
+
+// SetSampleReads sets how many records every ReadSampler samples after the
+// header. Zero reads the header only.
+func (r *ExtractorRegistry) SetSampleReads(n int) {
+	for _, extractor := range r.extractors {
+		if sampler, ok := extractor.(ReadSampler); ok {
+			sampler.SetSampleReads(n)
+		}
+	}
+}
+
 // FindExtractor finds an extractor for the given filename
 func (r *ExtractorRegistry) FindExtractor(filename string) Extractor {
 	for _, extractor := range r.extractors {
@@ -82,17 +113,35 @@ func (r *ExtractorRegistry) FindExtractor(filename string) Extractor {
 	return nil
 }
 
-// Extract extracts metadata using the appropriate extractor
+// Extract extracts metadata using the extractor that best matches the
+// file's content and name (see Detect)
 func (r *ExtractorRegistry) Extract(filepath string) (map[string]interface{}, error) {
-	filename := filepath
-	extractor := r.FindExtractor(filename)
+	extractor := r.DetectExtractor(filepath)
 	if extractor == nil {
-		return nil, fmt.Errorf("no extractor found for file: %s", filename)
+		return nil, fmt.Errorf("no extractor found for file: %s", filepath)
 	}
 
 	return extractor.Extract(filepath)
 }
 
+// ExtractTyped extracts metadata in typed form, using the extractor's own
+// TypedExtractor implementation when it has one
+func (r *ExtractorRegistry) ExtractTyped(filepath string) (*InstrumentMetadata, error) {
+	extractor := r.DetectExtractor(filepath)
+	if extractor == nil {
+		return nil, fmt.Errorf("no extractor found for file: %s", filepath)
+	}
+
+	if typed, ok := extractor.(TypedExtractor); ok {
+		return typed.ExtractTyped(filepath)
+	}
+	fields, err := extractor.Extract(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return NormalizeMetadata(fields), nil
+}
+
 // ListExtractors returns all registered extractors
 func (r *ExtractorRegistry) ListExtractors() []ExtractorInfo {
 	var info []ExtractorInfo
@@ -133,6 +182,19 @@ func (e *TIFFExtractor) CanHandle(filename string) bool {
 	return false
 }
 
+// Sniff recognises TIFF and BigTIFF headers and ImageJ descriptions.
+func (e *TIFFExtractor) Sniff(in *SniffInput) (int, string) {
+	switch {
+	case !isTIFFHeader(in.Header):
+		return 0, ""
+	case strings.HasPrefix(in.imageDescription(), "ImageJ="):
+		return 80, "TIFF header with ImageJ description"
+	case in.at(2, "+") || in.at(3, "+"):
+		return 60, "BigTIFF header"
+	}
+	return 60, "TIFF header"
+}
+
 func (e *TIFFExtractor) Extract(filepath string) (map[string]interface{}, error) {
 	// TODO: Implement TIFF metadata extraction using tiff package
 	// Extract:
@@ -166,278 +228,27 @@ func (e *TIFFExtractor) ExtractFromReader(r io.Reader, filename string) (map[str
 type ZeissExtractor = ZeissCZIExtractor
 
 // --- Nikon ND2 Extractor ---
+// The full implementation is in nikon_nd2.go
 
-type NikonExtractor struct{}
-
-func (e *NikonExtractor) Name() string {
-	return "Nikon ND2"
-}
-
-func (e *NikonExtractor) SupportedFormats() []string {
-	return []string{".nd2"}
-}
-
-func (e *NikonExtractor) CanHandle(filename string) bool {
-	return strings.ToLower(filepath.Ext(filename)) == ".nd2"
-}
-
-func (e *NikonExtractor) Extract(filepath string) (map[string]interface{}, error) {
-	// TODO: Implement ND2 metadata extraction
-	metadata := map[string]interface{}{
-		"format":                  "ND2",
-		"microscope_manufacturer": "Nikon",
-	}
-	return metadata, nil
-}
-
-func (e *NikonExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
+type NikonExtractor = NikonND2Extractor
 
 // --- Leica LIF Extractor ---
+// The full implementation is in leica_lif.go
 
-type LeicaExtractor struct{}
-
-func (e *LeicaExtractor) Name() string {
-	return "Leica LIF"
-}
-
-func (e *LeicaExtractor) SupportedFormats() []string {
-	return []string{".lif"}
-}
-
-func (e *LeicaExtractor) CanHandle(filename string) bool {
-	return strings.ToLower(filepath.Ext(filename)) == ".lif"
-}
-
-func (e *LeicaExtractor) Extract(filepath string) (map[string]interface{}, error) {
-	// TODO: Implement LIF metadata extraction
-	metadata := map[string]interface{}{
-		"format":                  "LIF",
-		"microscope_manufacturer": "Leica",
-	}
-	return metadata, nil
-}
-
-func (e *LeicaExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
+type LeicaExtractor = LeicaLIFExtractor
 
 // --- FASTQ Extractor ---
 // The full implementation is in fastq.go
 // This comment kept for reference in extractor sequence
 
-// --- BAM Extractor ---
-
-type BAMExtractor struct{}
-
-func (e *BAMExtractor) Name() string {
-	return "BAM"
-}
-
-func (e *BAMExtractor) SupportedFormats() []string {
-	return []string{".bam"}
-}
-
-func (e *BAMExtractor) CanHandle(filename string) bool {
-	return strings.ToLower(filepath.Ext(filename)) == ".bam"
-}
-
-func (e *BAMExtractor) Extract(filepath string) (map[string]interface{}, error) {
-	// TODO: Implement BAM metadata extraction using htslib bindings
-	// Extract header information:
-	// - Reference genome
-	// - Aligner used
-	// - Total reads
-	// - Mapped reads
-
-	metadata := map[string]interface{}{
-		"format": "BAM",
-	}
-
-	return metadata, nil
-}
-
-func (e *BAMExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-// --- mzML Extractor (Mass Spec) ---
-
-type MzMLExtractor struct{}
-
-func (e *MzMLExtractor) Name() string {
-	return "mzML"
-}
-
-func (e *MzMLExtractor) SupportedFormats() []string {
-	return []string{".mzml"}
-}
-
-func (e *MzMLExtractor) CanHandle(filename string) bool {
-	return strings.ToLower(filepath.Ext(filename)) == ".mzml"
-}
-
-func (e *MzMLExtractor) Extract(filepath string) (map[string]interface{}, error) {
-	// TODO: Parse mzML XML
-	// Extract:
-	// - Instrument info
-	// - Acquisition parameters
-	// - Number of spectra
-
-	metadata := map[string]interface{}{
-		"format": "mzML",
-	}
-
-	return metadata, nil
-}
-
-func (e *MzMLExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-// --- MGF Extractor (Mass Spec) ---
-
-type MGFExtractor struct{}
-
-func (e *MGFExtractor) Name() string {
-	return "MGF"
-}
-
-func (e *MGFExtractor) SupportedFormats() []string {
-	return []string{".mgf"}
-}
-
-func (e *MGFExtractor) CanHandle(filename string) bool {
-	return strings.ToLower(filepath.Ext(filename)) == ".mgf"
-}
-
-func (e *MGFExtractor) Extract(filepath string) (map[string]interface{}, error) {
-	metadata := map[string]interface{}{
-		"format": "MGF",
-	}
-	return metadata, nil
-}
-
-func (e *MGFExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-// --- HDF5 Extractor ---
-
-type HDF5Extractor struct{}
-
-func (e *HDF5Extractor) Name() string {
-	return "HDF5"
-}
-
-func (e *HDF5Extractor) SupportedFormats() []string {
-	return []string{".h5", ".hdf5"}
-}
-
-func (e *HDF5Extractor) CanHandle(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	return ext == ".h5" || ext == ".hdf5"
-}
-
-func (e *HDF5Extractor) Extract(filepath string) (map[string]interface{}, error) {
-	// TODO: Use HDF5 Go library to extract embedded attributes
-	metadata := map[string]interface{}{
-		"format": "HDF5",
-	}
-	return metadata, nil
-}
-
-func (e *HDF5Extractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-// --- Zarr Extractor ---
-
-type ZarrExtractor struct{}
-
-func (e *ZarrExtractor) Name() string {
-	return "Zarr"
-}
-
-func (e *ZarrExtractor) SupportedFormats() []string {
-	return []string{".zarr"}
-}
-
-func (e *ZarrExtractor) CanHandle(filename string) bool {
-	return strings.HasSuffix(strings.ToLower(filename), ".zarr")
-}
-
-func (e *ZarrExtractor) Extract(filepath string) (map[string]interface{}, error) {
-	// TODO: Read .zattrs JSON file
-	metadata := map[string]interface{}{
-		"format": "Zarr",
-	}
-	return metadata, nil
-}
-
-func (e *ZarrExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
+// --- POD5/FAST5 Extractors (Oxford Nanopore) ---
+// The full implementations are in nanopore.go
 
 // --- DICOM Extractor ---
-
-type DICOMExtractor struct{}
-
-func (e *DICOMExtractor) Name() string {
-	return "DICOM"
-}
-
-func (e *DICOMExtractor) SupportedFormats() []string {
-	return []string{".dcm", ".dicom"}
-}
-
-func (e *DICOMExtractor) CanHandle(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	return ext == ".dcm" || ext == ".dicom"
-}
-
-func (e *DICOMExtractor) Extract(filepath string) (map[string]interface{}, error) {
-	// TODO: Parse DICOM tags
-	// IMPORTANT: Redact PHI/PII before storing
-	metadata := map[string]interface{}{
-		"format": "DICOM",
-	}
-	return metadata, nil
-}
-
-func (e *DICOMExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
+// The full implementation is in dicom.go
 
 // --- FCS Extractor (Flow Cytometry) ---
-
-type FCSExtractor struct{}
-
-func (e *FCSExtractor) Name() string {
-	return "FCS"
-}
-
-func (e *FCSExtractor) SupportedFormats() []string {
-	return []string{".fcs"}
-}
-
-func (e *FCSExtractor) CanHandle(filename string) bool {
-	return strings.ToLower(filepath.Ext(filename)) == ".fcs"
-}
-
-func (e *FCSExtractor) Extract(filepath string) (map[string]interface{}, error) {
-	// TODO: Parse FCS header and TEXT segment
-	// Extract instrument, channels, events, etc.
-	metadata := map[string]interface{}{
-		"format": "FCS",
-	}
-	return metadata, nil
-}
-
-func (e *FCSExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("not implemented")
-}
+// The full implementation is in fcs.go
 
 // --- Generic Extractor (Fallback) ---
 
@@ -455,6 +266,11 @@ func (e *GenericExtractor) CanHandle(filename string) bool {
 	return true // Always matches as fallback
 }
 
+// Sniff ranks the generic extractor below every other match.
+func (e *GenericExtractor) Sniff(in *SniffInput) (int, string) {
+	return confidenceFallback, "fallback for unrecognised files"
+}
+
 func (e *GenericExtractor) Extract(filepath string) (map[string]interface{}, error) {
 	// Extract basic file info only
 	info, err := getFileInfo(filepath)
@@ -151,6 +151,7 @@ package metadata
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -197,6 +198,27 @@ func (e *FASTQExtractor) CanHandle(filename string) bool {
 	return false
 }
 
+// Sniff recognises a FASTQ record, plain or gzip-compressed.
+func (e *FASTQExtractor) Sniff(in *SniffInput) (int, string) {
+	if isFASTQRecord(in.Header) {
+		return 80, "FASTQ record (@ header and + separator)"
+	}
+	if isFASTQRecord(in.gunzipHeader(sniffHeaderSize)) {
+		return 75, "gzip-compressed FASTQ record"
+	}
+	return 0, ""
+}
+
+// isFASTQRecord reports whether data starts with an @ header line followed
+// by a sequence line and a + separator line.
+func isFASTQRecord(data []byte) bool {
+	if len(data) == 0 || data[0] != '@' {
+		return false
+	}
+	lines := bytes.SplitN(data, []byte("\n"), 4)
+	return len(lines) == 4 && len(lines[1]) > 0 && bytes.HasPrefix(lines[2], []byte("+"))
+}
+
 // Extract extracts metadata from a FASTQ file.
 func (e *FASTQExtractor) Extract(filepath string) (map[string]interface{}, error) {
 	f, err := os.Open(filepath)
@@ -0,0 +1,674 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # FCS Format
+//
+// This file implements metadata extraction for Flow Cytometry Standard files
+// (FCS 2.0, 3.0 and 3.1) written by BD, Beckman Coulter, Cytek, Thermo Fisher,
+// Sony, Miltenyi and other cytometers.
+//
+// ## File Format Overview
+//
+// An FCS file has four segments:
+//   - HEADER: "FCSx.y", four spaces, then six 8-byte ASCII offsets giving the
+//     first and last byte of the TEXT, DATA and ANALYSIS segments
+//   - TEXT: keyword/value pairs separated by a delimiter character, which is
+//     the first byte of the segment
+//   - DATA: the event matrix (never read by this extractor)
+//   - ANALYSIS: optional gating results
+//
+// A delimiter that appears inside a keyword or value is escaped by doubling
+// it. Keywords are case-insensitive; standard keywords start with "$".
+// FCS 3.0 moved large offsets into $BEGINDATA/$ENDDATA and allows a
+// supplemental TEXT segment ($BEGINSTEXT/$ENDSTEXT).
+//
+// ## Keyword Mapping
+//
+// Keywords are mapped into FlowCytometryMetadata, and the output uses its
+// JSON field names:
+//   - $CYT, $CYTSN, CREATOR: model, serial number and acquisition software
+//   - $TOT, $ABRT, $PAR: event, aborted event and parameter counts
+//   - $PnN, $PnS, $PnR, $PnB, $PnE, $PnV, $PnG, $PnF: per-parameter name,
+//     stain, range, bits, amplification, voltage, gain and filter
+//   - $DATE, $BTIM, $ETIM: acquisition date and duration
+//   - $SPILLOVER, SPILL, $COMP: compensation matrix
+//   - $OP, $SMNO, $CELLS, TUBE NAME, EXPERIMENT NAME, $PROJ: sample and
+//     operator information
+//
+// ## References and Sources
+//
+// Data File Standard for Flow Cytometry, Version FCS 3.1 (ISAC):
+// https://isac-net.org/page/Data-Standards
+//
+// Spidlen et al. (2010), "Data File Standard for Flow Cytometry, version
+// FCS 3.1", Cytometry Part A 77A:97-100.
+//
+// ## Limitations
+//
+// Only the first dataset is read; additional datasets linked by $NEXTDATA
+// are counted but not described.
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FCS layout constants
+const (
+	fcsHeaderSize      = 58
+	fcsMaxTextBytes    = 16 << 20
+	fcsMaxDatasets     = 64
+	fcsMaxKeywords     = 200
+	fcsMaxKeywordValue = 256
+	fcsMaxParameters   = 10000
+)
+
+// fcsChannelCode matches detector channel codes such as FL1, B2 or YG10,
+// which name a detector rather than a fluorochrome.
+var fcsChannelCode = regexp.MustCompile(`(?i)^(FL\d+|[A-Z]{1,2}\d{1,2})$`)
+
+// fcsManufacturers maps $CYT prefixes to instrument manufacturers.
+var fcsManufacturers = []struct {
+	prefix       string
+	manufacturer string
+}{
+	{"bd ", "BD Biosciences"},
+	{"facs", "BD Biosciences"},
+	{"lsr", "BD Biosciences"},
+	{"accuri", "BD Biosciences"},
+	{"influx", "BD Biosciences"},
+	{"cytoflex", "Beckman Coulter"},
+	{"gallios", "Beckman Coulter"},
+	{"navios", "Beckman Coulter"},
+	{"cyan", "Beckman Coulter"},
+	{"moflo", "Beckman Coulter"},
+	{"aurora", "Cytek Biosciences"},
+	{"northern lights", "Cytek Biosciences"},
+	{"attune", "Thermo Fisher Scientific"},
+	{"macsquant", "Miltenyi Biotec"},
+	{"novocyte", "Agilent"},
+	{"ze5", "Bio-Rad"},
+	{"guava", "Luminex"},
+	{"sony", "Sony Biotechnology"},
+	{"id7000", "Sony Biotechnology"},
+	{"sa3800", "Sony Biotechnology"},
+}
+
+// FCSExtractor extracts metadata from Flow Cytometry Standard files.
+type FCSExtractor struct{}
+
+// Name returns the extractor name.
+func (e *FCSExtractor) Name() string {
+	return "FCS"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *FCSExtractor) SupportedFormats() []string {
+	return []string{".fcs"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *FCSExtractor) CanHandle(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".fcs"
+}
+
+// Sniff recognises the FCSx.y version in the HEADER segment.
+func (e *FCSExtractor) Sniff(in *SniffInput) (int, string) {
+	for _, version := range []string{"FCS2.0", "FCS3.0", "FCS3.1", "FCS3.2"} {
+		if in.hasPrefix(version) {
+			return 100, version + " header"
+		}
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from an FCS file.
+func (e *FCSExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	metadata, _, err := e.extractFile(filepath)
+	return metadata, err
+}
+
+// ExtractTyped extracts an FCS file as FlowCytometryMetadata.
+func (e *FCSExtractor) ExtractTyped(filepath string) (*InstrumentMetadata, error) {
+	metadata, typed, err := e.extractFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return typedMetadata(metadata, typed), nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *FCSExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+	metadata, _, err := e.extract(bytes.NewReader(data), int64(len(data)), filename)
+	return metadata, err
+}
+
+// extractFile opens filepath and extracts it, adding the file size.
+func (e *FCSExtractor) extractFile(filepath string) (map[string]interface{}, *FlowCytometryMetadata, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	metadata, typed, err := e.extract(f, info.Size(), filepath)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata["file_size"] = info.Size()
+	return metadata, typed, nil
+}
+
+// extract parses the HEADER and TEXT segments and maps the keywords.
+func (e *FCSExtractor) extract(r io.ReaderAt, size int64, filename string) (map[string]interface{}, *FlowCytometryMetadata, error) {
+	file, err := readFCS(r, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "FCS",
+		"file_name":       filepath.Base(filename),
+		"extractor_name":  "fcs",
+		"schema_name":     "fcs_v1",
+		"instrument_type": "flow_cytometry",
+		"fcs_version":     file.version,
+	}
+	fc, err := file.flowCytometry()
+	if err != nil {
+		return nil, nil, err
+	}
+	flowCytometryFields(fc, metadata)
+	file.addExtras(metadata)
+	return metadata, fc, nil
+}
+
+// fcsFile is a parsed FCS HEADER and TEXT segment.
+type fcsFile struct {
+	version  string
+	keywords map[string]string
+	datasets int
+}
+
+// readFCS reads the header and the primary and supplemental TEXT segments,
+// following $NEXTDATA only to count datasets.
+func readFCS(r io.ReaderAt, size int64) (*fcsFile, error) {
+	file := &fcsFile{}
+	offset := int64(0)
+	for file.datasets < fcsMaxDatasets {
+		version, keywords, err := readFCSDataset(r, size, offset)
+		if err != nil {
+			if file.datasets > 0 {
+				break
+			}
+			return nil, err
+		}
+		if file.datasets == 0 {
+			file.version = version
+			file.keywords = keywords
+		}
+		file.datasets++
+
+		next, _ := strconv.ParseInt(strings.TrimSpace(keywords["$NEXTDATA"]), 10, 64)
+		if next <= 0 || offset+next >= size {
+			break
+		}
+		offset += next
+	}
+	return file, nil
+}
+
+// readFCSDataset reads one dataset's HEADER and TEXT keywords.
+func readFCSDataset(r io.ReaderAt, size, base int64) (string, map[string]string, error) {
+	header := make([]byte, fcsHeaderSize)
+	if _, err := r.ReadAt(header, base); err != nil {
+		return "", nil, fmt.Errorf("not a valid FCS file: header too short")
+	}
+	version := string(header[0:6])
+	if !strings.HasPrefix(version, "FCS") || version[4] != '.' {
+		return "", nil, fmt.Errorf("not a valid FCS file: bad signature %q", version)
+	}
+	version = version[3:]
+
+	var offsets [6]int64
+	for i := range offsets {
+		field := strings.TrimSpace(string(header[10+8*i : 18+8*i]))
+		if field == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("not a valid FCS file: bad segment offset %q", field)
+		}
+		offsets[i] = v
+	}
+
+	keywords, err := readFCSText(r, size, base, offsets[0], offsets[1])
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Supplemental TEXT (FCS 3.0+) adds keywords but does not override
+	start, _ := strconv.ParseInt(strings.TrimSpace(keywords["$BEGINSTEXT"]), 10, 64)
+	end, _ := strconv.ParseInt(strings.TrimSpace(keywords["$ENDSTEXT"]), 10, 64)
+	if start > 0 && end > start && start != offsets[0] {
+		if extra, err := readFCSText(r, size, base, start, end); err == nil {
+			for key, value := range extra {
+				if _, ok := keywords[key]; !ok {
+					keywords[key] = value
+				}
+			}
+		}
+	}
+	return version, keywords, nil
+}
+
+// readFCSText reads and parses a TEXT segment spanning [start, end].
+func readFCSText(r io.ReaderAt, size, base, start, end int64) (map[string]string, error) {
+	if start < fcsHeaderSize || end <= start || base+end >= size {
+		return nil, fmt.Errorf("not a valid FCS file: TEXT segment [%d, %d] out of range", start, end)
+	}
+	if end-start+1 > fcsMaxTextBytes {
+		return nil, fmt.Errorf("not a valid FCS file: TEXT segment too large (%d bytes)", end-start+1)
+	}
+	text := make([]byte, end-start+1)
+	if _, err := r.ReadAt(text, base+start); err != nil {
+		return nil, fmt.Errorf("failed to read TEXT segment: %w", err)
+	}
+	return parseFCSText(text)
+}
+
+// parseFCSText splits a TEXT segment into keyword/value pairs. The first
+// byte is the delimiter; a doubled delimiter is a literal delimiter.
+func parseFCSText(text []byte) (map[string]string, error) {
+	if len(text) < 2 {
+		return nil, fmt.Errorf("not a valid FCS file: empty TEXT segment")
+	}
+	delim := text[0]
+
+	var tokens []string
+	var current []byte
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		if c != delim {
+			current = append(current, c)
+			continue
+		}
+		if i+1 < len(text) && text[i+1] == delim {
+			current = append(current, delim)
+			i++
+			continue
+		}
+		tokens = append(tokens, string(current))
+		current = nil
+	}
+	if len(bytes.TrimSpace(current)) > 0 {
+		// Some writers omit the final delimiter
+		tokens = append(tokens, string(current))
+	}
+
+	keywords := make(map[string]string, len(tokens)/2)
+	for i := 0; i+1 < len(tokens); i += 2 {
+		key := strings.ToUpper(strings.TrimSpace(tokens[i]))
+		if key != "" {
+			keywords[key] = strings.TrimSpace(tokens[i+1])
+		}
+	}
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("not a valid FCS file: no keywords in TEXT segment")
+	}
+	return keywords, nil
+}
+
+// get returns the first non-empty keyword value.
+func (f *fcsFile) get(keys ...string) string {
+	for _, key := range keys {
+		if v := f.keywords[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// getInt parses an integer keyword; FCS 3.1 allows floats for $PnR.
+func (f *fcsFile) getInt(keys ...string) int {
+	v := f.get(keys...)
+	if n, err := strconv.Atoi(v); err == nil {
+		return n
+	}
+	if x, err := strconv.ParseFloat(v, 64); err == nil {
+		return int(x)
+	}
+	return 0
+}
+
+// getFloat parses a floating-point keyword.
+func (f *fcsFile) getFloat(keys ...string) float64 {
+	x, _ := strconv.ParseFloat(f.get(keys...), 64)
+	return x
+}
+
+// flowCytometry maps the TEXT keywords to FlowCytometryMetadata.
+func (f *fcsFile) flowCytometry() (*FlowCytometryMetadata, error) {
+	fc := &FlowCytometryMetadata{
+		Model:           f.get("$CYT"),
+		SerialNumber:    f.get("$CYTSN"),
+		SoftwareVersion: f.get("CREATOR", "APPLICATION", "$SOFTWARE"),
+		TotalEvents:     f.getInt("$TOT"),
+		AbortedEvents:   f.getInt("$ABRT"),
+		SampleID:        f.get("$SMNO", "SAMPLE ID", "SAMPLE NAME"),
+		TubeID:          f.get("TUBE NAME", "TUBE"),
+		CellType:        f.get("$CELLS"),
+		Operator:        f.get("$OP", "EXPORT USER NAME"),
+		ExperimentName:  f.get("EXPERIMENT NAME", "$PROJ"),
+	}
+	fc.Manufacturer = fcsManufacturer(fc.Model)
+
+	// Each parameter needs at least $PnN and $PnB keywords
+	numParams := f.getInt("$PAR")
+	if numParams < 0 || numParams > fcsMaxParameters || numParams > len(f.keywords)/2 {
+		return nil, fmt.Errorf("invalid FCS file: $PAR %d does not match the TEXT segment", numParams)
+	}
+	for n := 1; n <= numParams; n++ {
+		key := func(suffix string) string { return fmt.Sprintf("$P%d%s", n, suffix) }
+		param := FlowCytometryParameter{
+			Name:          f.get(key("N")),
+			Description:   f.get(key("S")),
+			Range:         f.getInt(key("R")),
+			Bits:          f.getInt(key("B")),
+			Gain:          f.getFloat(key("G")),
+			Voltage:       f.getFloat(key("V")),
+			Filter:        f.get(key("F")),
+			Amplification: f.get(key("E")),
+		}
+		param.Fluorochrome = fcsFluorochrome(param.Name)
+		fc.Parameters = append(fc.Parameters, param)
+	}
+
+	fc.CompensationParameters, fc.CompensationMatrix = parseFCSSpillover(f.get("$SPILLOVER", "SPILL", "SPILLOVER", "$COMP"))
+
+	date, hasDate := fcsDate(f.get("$DATE"))
+	begin, hasBegin := fcsTime(f.get("$BTIM"))
+	if end, hasEnd := fcsTime(f.get("$ETIM")); hasBegin && hasEnd {
+		duration := end - begin
+		if duration < 0 {
+			duration += 24 * 60 * 60
+		}
+		fc.AcquisitionTime = duration
+		if duration > 0 && fc.TotalEvents > 0 {
+			fc.EventRate = float64(fc.TotalEvents) / duration
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, f.get("$BEGINDATETIME")); err == nil {
+		fc.AcquisitionDate = t
+	} else if hasDate {
+		fc.AcquisitionDate = date.Add(time.Duration(begin * float64(time.Second)))
+	}
+	return fc, nil
+}
+
+// fcsManufacturer infers the manufacturer from the $CYT model string.
+func fcsManufacturer(model string) string {
+	lower := strings.ToLower(model)
+	for _, m := range fcsManufacturers {
+		if strings.HasPrefix(lower, m.prefix) {
+			return m.manufacturer
+		}
+	}
+	return ""
+}
+
+// fcsFluorochrome derives a fluorochrome from a parameter name such as
+// "PE-Cy7-A", skipping scatter, time and detector channel codes.
+func fcsFluorochrome(name string) string {
+	base := name
+	for _, suffix := range []string{"-A", "-H", "-W"} {
+		base = strings.TrimSuffix(base, suffix)
+	}
+	upper := strings.ToUpper(base)
+	switch {
+	case base == "",
+		strings.HasPrefix(upper, "FSC"), strings.HasPrefix(upper, "SSC"),
+		strings.HasPrefix(upper, "TIME"), strings.HasPrefix(upper, "EVENT"),
+		fcsChannelCode.MatchString(base):
+		return ""
+	}
+	return base
+}
+
+// parseFCSSpillover parses "n,P1,...,Pn,v11,...,vnn". FCS 2.0/3.0 $COMP
+// omits the parameter names.
+func parseFCSSpillover(value string) ([]string, [][]float64) {
+	parts := strings.Split(value, ",")
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n <= 0 || n > fcsMaxParameters || n >= len(parts) {
+		return nil, nil
+	}
+
+	// n is bounded, so n*n cannot overflow
+	var names []string
+	values := parts[1:]
+	switch len(values) {
+	case n * n:
+	case n + n*n:
+		for _, name := range values[:n] {
+			names = append(names, strings.TrimSpace(name))
+		}
+		values = values[n:]
+	default:
+		return nil, nil
+	}
+
+	matrix := make([][]float64, n)
+	for i := range matrix {
+		matrix[i] = make([]float64, n)
+		for j := range matrix[i] {
+			v, err := strconv.ParseFloat(strings.TrimSpace(values[i*n+j]), 64)
+			if err != nil {
+				return nil, nil
+			}
+			matrix[i][j] = v
+		}
+	}
+	return names, matrix
+}
+
+// fcsDate parses $DATE, normally dd-mmm-yyyy.
+func fcsDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) >= 6 {
+		// Month abbreviations are written in any case ("05-MAR-2024")
+		parts := strings.Split(value, "-")
+		if len(parts) == 3 && len(parts[1]) == 3 {
+			parts[1] = strings.ToUpper(parts[1][:1]) + strings.ToLower(parts[1][1:])
+			value = strings.Join(parts, "-")
+		}
+	}
+	for _, layout := range []string{"02-Jan-2006", "2-Jan-2006", "02-Jan-06", "2006-01-02", "01/02/2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// fcsTime parses $BTIM/$ETIM as seconds since midnight. FCS 2.0/3.0 use
+// hh:mm:ss[:tt] with tt in 1/60 s; FCS 3.1 uses hh:mm:ss[.cc].
+func fcsTime(value string) (float64, bool) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	s, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+	seconds := float64(h*3600+m*60) + s
+	if len(parts) == 4 {
+		if ticks, err := strconv.Atoi(parts[3]); err == nil {
+			seconds += float64(ticks) / 60
+		}
+	}
+	return seconds, true
+}
+
+// flowCytometryFields writes FlowCytometryMetadata into the output map
+// under its JSON field names, skipping empty values.
+func flowCytometryFields(fc *FlowCytometryMetadata, metadata map[string]interface{}) {
+	for key, value := range map[string]string{
+		"manufacturer":     fc.Manufacturer,
+		"model":            fc.Model,
+		"serial_number":    fc.SerialNumber,
+		"software_version": fc.SoftwareVersion,
+		"sample_id":        fc.SampleID,
+		"tube_id":          fc.TubeID,
+		"organism":         fc.Organism,
+		"cell_type":        fc.CellType,
+		"treatment":        fc.Treatment,
+		"operator":         fc.Operator,
+		"experiment_name":  fc.ExperimentName,
+		"gating_strategy":  fc.GatingStrategy,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	if fc.Model != "" {
+		metadata["instrument_model"] = fc.Model
+	}
+
+	metadata["total_events"] = fc.TotalEvents
+	if fc.AbortedEvents > 0 {
+		metadata["aborted_events"] = fc.AbortedEvents
+	}
+	if fc.AcquisitionTime > 0 {
+		metadata["acquisition_time"] = fc.AcquisitionTime
+	}
+	if fc.EventRate > 0 {
+		metadata["event_rate"] = fc.EventRate
+	}
+	if !fc.AcquisitionDate.IsZero() {
+		metadata["acquisition_date"] = fc.AcquisitionDate.Format(time.RFC3339)
+	}
+
+	if len(fc.Parameters) > 0 {
+		params := make([]map[string]interface{}, 0, len(fc.Parameters))
+		for _, p := range fc.Parameters {
+			param := map[string]interface{}{"name": p.Name}
+			for key, value := range map[string]string{
+				"description":   p.Description,
+				"filter":        p.Filter,
+				"fluorochrome":  p.Fluorochrome,
+				"amplification": p.Amplification,
+			} {
+				if value != "" {
+					param[key] = value
+				}
+			}
+			if p.Range > 0 {
+				param["range"] = p.Range
+			}
+			if p.Bits > 0 {
+				param["bits"] = p.Bits
+			}
+			if p.Gain > 0 {
+				param["gain"] = p.Gain
+			}
+			if p.Voltage > 0 {
+				param["voltage"] = p.Voltage
+			}
+			params = append(params, param)
+		}
+		metadata["parameters"] = params
+		metadata["num_parameters"] = len(params)
+	}
+	if len(fc.CompensationMatrix) > 0 {
+		metadata["compensation_matrix"] = fc.CompensationMatrix
+		if len(fc.CompensationParameters) > 0 {
+			metadata["compensation_parameters"] = fc.CompensationParameters
+		}
+	}
+	if len(fc.Populations) > 0 {
+		metadata["populations"] = fc.Populations
+	}
+}
+
+// addExtras adds data layout, provenance and non-standard keywords that
+// FlowCytometryMetadata does not model.
+func (f *fcsFile) addExtras(metadata map[string]interface{}) {
+	for key, keyword := range map[string]string{
+		"data_type":          "$DATATYPE",
+		"byte_order":         "$BYTEORD",
+		"data_mode":          "$MODE",
+		"institution":        "$INST",
+		"comment":            "$COM",
+		"original_file_name": "$FIL",
+	} {
+		if v := f.get(keyword); v != "" {
+			metadata[key] = v
+		}
+	}
+	if ts := f.getFloat("$TIMESTEP"); ts > 0 {
+		metadata["timestep"] = ts
+	}
+	if f.datasets > 1 {
+		metadata["dataset_count"] = f.datasets
+	}
+
+	// Vendor keywords (no "$" prefix), excluding per-parameter and
+	// spillover entries already mapped above
+	var names []string
+	for key := range f.keywords {
+		if !strings.HasPrefix(key, "$") && !strings.HasPrefix(key, "SPILL") {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > fcsMaxKeywords {
+		names = names[:fcsMaxKeywords]
+	}
+	if len(names) > 0 {
+		keywords := make(map[string]string, len(names))
+		for _, name := range names {
+			value := f.keywords[name]
+			if len(value) > fcsMaxKeywordValue {
+				value = value[:fcsMaxKeywordValue]
+			}
+			keywords[name] = value
+		}
+		metadata["keywords"] = keywords
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildTestFCS writes a HEADER, a TEXT segment with the given keywords
+// (escaping the delimiter) and a DATA segment.
+func buildTestFCS(version string, delim byte, keywords [][2]string, data []byte) []byte {
+	escape := func(s string) string {
+		return strings.ReplaceAll(s, string(delim), string([]byte{delim, delim}))
+	}
+	var text bytes.Buffer
+	text.WriteByte(delim)
+	for _, kv := range keywords {
+		text.WriteString(escape(kv[0]))
+		text.WriteByte(delim)
+		text.WriteString(escape(kv[1]))
+		text.WriteByte(delim)
+	}
+
+	textStart := 256
+	textEnd := textStart + text.Len() - 1
+	dataStart := textEnd + 1
+	dataEnd := dataStart + len(data) - 1
+
+	header := fmt.Sprintf("FCS%s    %8d%8d%8d%8d%8d%8d", version, textStart, textEnd, dataStart, dataEnd, 0, 0)
+	out := make([]byte, textStart)
+	copy(out, header)
+	for i := len(header); i < textStart; i++ {
+		out[i] = ' '
+	}
+	out = append(out, text.Bytes()...)
+	return append(out, data...)
+}
+
+func TestFCSExtractorFCS31(t *testing.T) {
+	keywords := [][2]string{
+		{"$BEGINANALYSIS", "0"}, {"$ENDANALYSIS", "0"},
+		{"$BEGINSTEXT", "0"}, {"$ENDSTEXT", "0"},
+		{"$BYTEORD", "1,2,3,4"}, {"$DATATYPE", "F"}, {"$MODE", "L"},
+		{"$NEXTDATA", "0"}, {"$TOT", "10000"}, {"$PAR", "4"},
+		{"$CYT", "FACSCanto II"}, {"$CYTSN", "V96300078"},
+		{"CREATOR", "BD FACSDiva Software Version 8.0.1"},
+		{"$DATE", "05-MAR-2024"}, {"$BTIM", "14:02:10.00"}, {"$ETIM", "14:02:30.00"},
+		{"$OP", "jsmith"}, {"$SMNO", "Donor 7"}, {"TUBE NAME", "CD3/CD4 stain"},
+		{"EXPERIMENT NAME", "T cell panel"}, {"$ABRT", "12"},
+		{"$P1N", "FSC-A"}, {"$P1R", "262144"}, {"$P1B", "32"}, {"$P1E", "0,0"}, {"$P1V", "350"},
+		{"$P2N", "SSC-A"}, {"$P2R", "262144"}, {"$P2B", "32"}, {"$P2E", "0,0"}, {"$P2V", "420"},
+		{"$P3N", "FITC-A"}, {"$P3S", "CD3"}, {"$P3R", "262144"}, {"$P3B", "32"}, {"$P3E", "0,0"}, {"$P3V", "500"}, {"$P3F", "530/30"},
+		{"$P4N", "PE-Cy7-A"}, {"$P4S", "CD4"}, {"$P4R", "262144"}, {"$P4B", "32"}, {"$P4E", "0,0"}, {"$P4V", "610"}, {"$P4G", "1.5"},
+		{"$SPILLOVER", "2,FITC-A,PE-Cy7-A,1,0.12,0.003,1"},
+		{"$TIMESTEP", "0.01"},
+	}
+	data := buildTestFCS("3.1", '/', keywords, make([]byte, 64))
+	path := filepath.Join(t.TempDir(), "tube1.fcs")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+	metadata, err := registry.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":                  "FCS",
+		"fcs_version":             "3.1",
+		"schema_name":             "fcs_v1",
+		"model":                   "FACSCanto II",
+		"manufacturer":            "BD Biosciences",
+		"serial_number":           "V96300078",
+		"software_version":        "BD FACSDiva Software Version 8.0.1",
+		"total_events":            10000,
+		"aborted_events":          12,
+		"num_parameters":          4,
+		"operator":                "jsmith",
+		"sample_id":               "Donor 7",
+		"tube_id":                 "CD3/CD4 stain",
+		"experiment_name":         "T cell panel",
+		"acquisition_date":        "2024-03-05T14:02:10Z",
+		"acquisition_time":        20.0,
+		"event_rate":              500.0,
+		"data_type":               "F",
+		"compensation_parameters": []string{"FITC-A", "PE-Cy7-A"},
+		"compensation_matrix":     [][]float64{{1, 0.12}, {0.003, 1}},
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(metadata[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+
+	params := metadata["parameters"].([]map[string]interface{})
+	if params[0]["name"] != "FSC-A" || params[0]["range"] != 262144 || params[0]["voltage"] != 350.0 || params[0]["fluorochrome"] != nil {
+		t.Errorf("parameter 1 = %v", params[0])
+	}
+	if params[2]["description"] != "CD3" || params[2]["fluorochrome"] != "FITC" || params[2]["filter"] != "530/30" || params[2]["amplification"] != "0,0" {
+		t.Errorf("parameter 3 = %v", params[2])
+	}
+	if params[3]["fluorochrome"] != "PE-Cy7" || params[3]["gain"] != 1.5 {
+		t.Errorf("parameter 4 = %v", params[3])
+	}
+
+	if kw := metadata["keywords"].(map[string]string); kw["TUBE NAME"] != "CD3/CD4 stain" {
+		t.Errorf("keywords = %v", kw)
+	}
+}
+
+func TestFCSExtractorFCS20(t *testing.T) {
+	keywords := [][2]string{
+		{"$BYTEORD", "4,3,2,1"}, {"$DATATYPE", "I"}, {"$MODE", "L"},
+		{"$TOT", "5000"}, {"$PAR", "2"}, {"$CYT", "CyAn ADP"},
+		{"$DATE", "17-Nov-1999"}, {"$BTIM", "09:30:00:30"}, {"$ETIM", "09:30:10"},
+		{"$P1N", "FL1"}, {"$P1R", "1024"}, {"$P1B", "10"}, {"$P1E", "4,1"},
+		{"$P2N", "FL2"}, {"$P2R", "1024"}, {"$P2B", "10"}, {"$P2E", "4,1"},
+		{"$COMP", "2,1,0.2,0.05,1"},
+	}
+	data := buildTestFCS("2.0", '\\', keywords, make([]byte, 16))
+
+	metadata, err := (&FCSExtractor{}).ExtractFromReader(bytes.NewReader(data), "old.fcs")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	if metadata["fcs_version"] != "2.0" || metadata["manufacturer"] != "Beckman Coulter" || metadata["total_events"] != 5000 {
+		t.Errorf("metadata = %v", metadata)
+	}
+	if got, _ := metadata["acquisition_time"].(float64); math.Abs(got-9.5) > 1e-9 {
+		t.Errorf("acquisition_time = %v, want 9.5", metadata["acquisition_time"])
+	}
+	if metadata["acquisition_date"] != "1999-11-17T09:30:00Z" {
+		t.Errorf("acquisition_date = %v", metadata["acquisition_date"])
+	}
+	if !reflect.DeepEqual(metadata["compensation_matrix"], [][]float64{{1, 0.2}, {0.05, 1}}) || metadata["compensation_parameters"] != nil {
+		t.Errorf("compensation = %v / %v", metadata["compensation_matrix"], metadata["compensation_parameters"])
+	}
+	params := metadata["parameters"].([]map[string]interface{})
+	if params[0]["fluorochrome"] != nil || params[0]["amplification"] != "4,1" || params[0]["bits"] != 10 {
+		t.Errorf("parameter 1 = %v", params[0])
+	}
+}
+
+func TestParseFCSText(t *testing.T) {
+	keywords, err := parseFCSText([]byte("|$CYT|Aurora||Cytek|$COM|a || b|$TOT|42|"))
+	if err != nil {
+		t.Fatalf("parseFCSText() error = %v", err)
+	}
+	want := map[string]string{"$CYT": "Aurora|Cytek", "$COM": "a | b", "$TOT": "42"}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Errorf("keywords = %v, want %v", keywords, want)
+	}
+
+	// Missing final delimiter and lower-case keywords
+	keywords, err = parseFCSText([]byte("/$par/3/$tot/7"))
+	if err != nil || keywords["$PAR"] != "3" || keywords["$TOT"] != "7" {
+		t.Errorf("keywords = %v, err = %v", keywords, err)
+	}
+}
+
+func TestFCSExtractorInvalid(t *testing.T) {
+	extractor := &FCSExtractor{}
+	if _, err := extractor.ExtractFromReader(strings.NewReader("not an fcs file at all, just some text padding it out"), "x.fcs"); err == nil {
+		t.Error("expected error for short file")
+	}
+
+	data := buildTestFCS("3.0", '/', [][2]string{{"$TOT", "1"}}, nil)
+	copy(data[10:18], []byte("   99999"))
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(data), "bad.fcs"); err == nil {
+		t.Error("expected error for TEXT offset beyond end of file")
+	}
+
+	data = buildTestFCS("3.1", '/', [][2]string{{"$TOT", "1"}, {"$PAR", "300000000"}}, nil)
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(data), "bad.fcs"); err == nil {
+		t.Error("expected error for $PAR beyond the TEXT keywords")
+	}
+}
+
+func TestParseFCSSpilloverInvalid(t *testing.T) {
+	for _, value := range []string{
+		"4294967296",
+		"4294967296,FITC-A",
+		"3,FITC-A,PE-A,1,0,0,1",
+		"-1,1",
+		"x,1",
+	} {
+		if names, matrix := parseFCSSpillover(value); names != nil || matrix != nil {
+			t.Errorf("parseFCSSpillover(%q) = %v, %v, want nil", value, names, matrix)
+		}
+	}
+}
@@ -0,0 +1,821 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # HDF5 Format
+//
+// This file implements metadata extraction for HDF5 files, including the
+// NeXus files written at synchrotron and neutron beamlines and Imaris (.ims)
+// microscopy files. The low-level reader lives in hdf5_format.go.
+//
+// ## File Format Overview
+//
+// An HDF5 file starts with a superblock (at offset 0 or after a 512-byte
+// aligned user block) pointing at the root group's object header:
+//   - Superblock versions 0 and 1 (HDF5 1.6 and earlier defaults) hold the
+//     root symbol table entry; groups are B-trees of symbol table nodes with
+//     names in a local heap
+//   - Superblock versions 2 and 3 (libver="latest", h5py track_order, SWMR)
+//     hold the root object header address; groups store link messages
+//     directly or, when large, in a fractal heap indexed by a v2 B-tree
+//
+// Object headers carry typed messages: dataspace (shape), datatype, data
+// layout (contiguous, compact or chunked with chunk dimensions), filter
+// pipeline (compression) and attributes.
+//
+// ## Layouts
+//
+// NeXus files are recognised by groups whose NX_class attribute is NXentry.
+// Fields from NXinstrument, NXsource, NXmonochromator/NXbeam, NXdetector,
+// NXsample and NXuser groups are mapped to XRayMetadata field names
+// (facility, beamline, energy in keV, wavelength in Å, distance in mm).
+//
+// Imaris files are recognised by the ImarisDataSet root attribute. Image
+// dimensions, extents, channels and time points come from the string
+// attributes of the DataSetInfo groups and are mapped to MicroscopyMetadata
+// field names.
+//
+// ## References and Sources
+//
+// HDF5 File Format Specification 3.0:
+// https://docs.hdfgroup.org/hdf5/develop/_f_m_t3.html
+//
+// NeXus base classes:
+// https://manual.nexusformat.org/classes/base_classes/
+//
+// Imaris 5.5 file format:
+// https://imaris.oxinst.com/support/imaris-file-format
+//
+// ## Limitations
+//
+//   - Checksums are not verified
+//   - Dataset values are read only for small unchunked datasets, so NeXus
+//     fields stored in chunked or compressed datasets are not mapped
+//   - Huge fractal heap objects, shared messages and external links are skipped
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HDF5 extraction limits
+const (
+	hdf5MaxObjects        = 10000
+	hdf5MaxDepth          = 32
+	hdf5MaxListedDatasets = 200
+	hdf5MaxGroupAttrs     = 200
+	hdf5MaxValueBytes     = 64 << 10
+)
+
+// hcKeV is Planck's constant times the speed of light in keV·Å, used to
+// convert between X-ray energy and wavelength.
+const hcKeV = 12.398419843320026
+
+// HDF5Extractor extracts metadata from HDF5, NeXus and Imaris files.
+type HDF5Extractor struct{}
+
+// Name returns the extractor name.
+func (e *HDF5Extractor) Name() string {
+	return "HDF5"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *HDF5Extractor) SupportedFormats() []string {
+	return []string{".h5", ".hdf5", ".he5", ".nxs", ".nx5", ".ims"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *HDF5Extractor) CanHandle(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, format := range e.SupportedFormats() {
+		if ext == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Sniff recognises the HDF5 superblock signature at offset 0. More specific
+// HDF5-based formats such as FAST5 score higher.
+func (e *HDF5Extractor) Sniff(in *SniffInput) (int, string) {
+	if in.hasPrefix(hdf5Signature) {
+		return 70, "HDF5 superblock signature"
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from an HDF5 file.
+func (e *HDF5Extractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	metadata, err := e.extract(f, info.Size(), filepath)
+	if err != nil {
+		return nil, err
+	}
+	metadata["file_size"] = info.Size()
+	return metadata, nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *HDF5Extractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return e.extract(bytes.NewReader(data), int64(len(data)), filename)
+}
+
+// extract walks the file and builds the metadata map.
+func (e *HDF5Extractor) extract(r io.ReaderAt, size int64, filename string) (map[string]interface{}, error) {
+	f, err := openHDF5(r, size)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := walkHDF5(f)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"format":             "HDF5",
+		"file_name":          filepath.Base(filename),
+		"extractor_name":     "hdf5",
+		"schema_name":        "hdf5_v1",
+		"superblock_version": f.superblock,
+	}
+	tree.addStructure(metadata)
+
+	switch {
+	case tree.isImaris():
+		tree.mapImaris(metadata)
+	case tree.findClass("", "NXentry") != nil:
+		tree.mapNeXus(metadata)
+	}
+
+	return metadata, nil
+}
+
+// h5Object is one group or dataset reached while walking the file.
+type h5Object struct {
+	path  string
+	group bool
+	msgs  []h5Message
+	attrs map[string]interface{}
+}
+
+// h5Tree holds every object reached from the root group, keyed by path.
+type h5Tree struct {
+	f         *hdf5File
+	objects   map[string]*h5Object
+	paths     []string
+	softLinks map[string]string
+	truncated bool
+}
+
+// walkHDF5 visits the group hierarchy depth-first in name order. Objects
+// reachable through several hard links are reported at their first path.
+func walkHDF5(f *hdf5File) (*h5Tree, error) {
+	t := &h5Tree{f: f, objects: map[string]*h5Object{}, softLinks: map[string]string{}}
+	visited := map[uint64]bool{}
+
+	var visit func(addr uint64, path string, depth int) error
+	visit = func(addr uint64, path string, depth int) error {
+		if visited[addr] || depth > hdf5MaxDepth {
+			return nil
+		}
+		if len(t.paths) >= hdf5MaxObjects {
+			t.truncated = true
+			return nil
+		}
+		visited[addr] = true
+
+		msgs, err := f.readObjectHeader(addr)
+		if err != nil {
+			if path == "/" {
+				return fmt.Errorf("failed to read root group: %w", err)
+			}
+			return nil
+		}
+		obj := &h5Object{path: path, group: isGroup(msgs) || path == "/", msgs: msgs, attrs: f.attributes(msgs)}
+		t.objects[path] = obj
+		t.paths = append(t.paths, path)
+		if !obj.group {
+			return nil
+		}
+
+		links, err := f.groupLinks(msgs)
+		if err != nil {
+			if path == "/" {
+				return fmt.Errorf("failed to read root group: %w", err)
+			}
+			return nil
+		}
+		for _, link := range links {
+			child := strings.TrimSuffix(path, "/") + "/" + link.name
+			switch {
+			case link.soft != "":
+				t.softLinks[child] = link.soft
+			case !link.external:
+				if err := visit(link.addr, child, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := visit(f.rootAddr, "/", 0); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// addStructure reports group and dataset counts, dataset descriptions and
+// root- and group-level attributes.
+func (t *h5Tree) addStructure(metadata map[string]interface{}) {
+	var datasets []map[string]interface{}
+	groupAttrs := map[string]interface{}{}
+	groups, datasetCount := 0, 0
+
+	for _, path := range t.paths {
+		obj := t.objects[path]
+		if obj.group {
+			if path != "/" {
+				groups++
+				if len(obj.attrs) > 0 && len(groupAttrs) < hdf5MaxGroupAttrs {
+					groupAttrs[path] = obj.attrs
+				}
+			}
+			continue
+		}
+		datasetCount++
+		if len(datasets) < hdf5MaxListedDatasets {
+			datasets = append(datasets, t.describeDataset(obj))
+		}
+	}
+
+	metadata["group_count"] = groups
+	metadata["dataset_count"] = datasetCount
+	if len(datasets) > 0 {
+		metadata["datasets"] = datasets
+	}
+	if datasetCount > len(datasets) {
+		metadata["datasets_truncated"] = true
+	}
+	if root := t.objects["/"]; len(root.attrs) > 0 {
+		metadata["root_attributes"] = root.attrs
+	}
+	if len(groupAttrs) > 0 {
+		metadata["group_attributes"] = groupAttrs
+	}
+	if len(t.softLinks) > 0 {
+		metadata["soft_links"] = t.softLinks
+	}
+	if t.truncated {
+		metadata["extraction_note"] = fmt.Sprintf("walk stopped after %d objects", hdf5MaxObjects)
+	}
+}
+
+// describeDataset returns the path, shape, dtype, layout, chunking and
+// filters of a dataset.
+func (t *h5Tree) describeDataset(obj *h5Object) map[string]interface{} {
+	info := map[string]interface{}{"path": obj.path}
+
+	if m := findMessage(obj.msgs, h5MsgDataspace); m != nil {
+		if space, err := parseDataspace(m.data, t.f.lengthSize); err == nil {
+			info["shape"] = h5Dims(space.dims)
+			if len(space.maxDims) > 0 && !equalDims(space.dims, space.maxDims) {
+				info["max_shape"] = h5Dims(space.maxDims)
+			}
+		}
+	}
+	if m := findMessage(obj.msgs, h5MsgDatatype); m != nil {
+		if dt, _, err := parseDatatype(m.data); err == nil {
+			info["dtype"] = dt.String()
+		}
+	}
+	if m := findMessage(obj.msgs, h5MsgLayout); m != nil {
+		if layout, err := t.f.parseLayout(m.data); err == nil {
+			info["layout"] = layout.class
+			if len(layout.chunks) > 0 {
+				info["chunks"] = h5Dims(layout.chunks)
+			}
+		}
+	}
+	if m := findMessage(obj.msgs, h5MsgFilterPipeline); m != nil {
+		if filters := parseFilters(m.data); len(filters) > 0 {
+			info["filters"] = filters
+		}
+	}
+	if len(obj.attrs) > 0 {
+		info["attributes"] = obj.attrs
+	}
+	return info
+}
+
+// h5Dims converts dimensions to int64, reporting unlimited as -1.
+func h5Dims(dims []uint64) []int64 {
+	out := make([]int64, len(dims))
+	for i, d := range dims {
+		if d == math.MaxUint64 {
+			out[i] = -1
+		} else {
+			out[i] = int64(d)
+		}
+	}
+	return out
+}
+
+func equalDims(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// shape returns the dimensions of a dataset.
+func (t *h5Tree) shape(path string) []uint64 {
+	obj := t.objects[path]
+	if obj == nil || obj.group {
+		return nil
+	}
+	if m := findMessage(obj.msgs, h5MsgDataspace); m != nil {
+		if space, err := parseDataspace(m.data, t.f.lengthSize); err == nil {
+			return space.dims
+		}
+	}
+	return nil
+}
+
+// value reads the value of a small compact or contiguous dataset.
+func (t *h5Tree) value(path string) interface{} {
+	obj := t.objects[path]
+	if obj == nil || obj.group {
+		return nil
+	}
+	typeMsg := findMessage(obj.msgs, h5MsgDatatype)
+	spaceMsg := findMessage(obj.msgs, h5MsgDataspace)
+	layoutMsg := findMessage(obj.msgs, h5MsgLayout)
+	if typeMsg == nil || spaceMsg == nil || layoutMsg == nil || findMessage(obj.msgs, h5MsgFilterPipeline) != nil {
+		return nil
+	}
+	dt, _, err := parseDatatype(typeMsg.data)
+	if err != nil {
+		return nil
+	}
+	space, err := parseDataspace(spaceMsg.data, t.f.lengthSize)
+	if err != nil {
+		return nil
+	}
+	layout, err := t.f.parseLayout(layoutMsg.data)
+	if err != nil {
+		return nil
+	}
+
+	size := space.elements() * uint64(t.f.storageSize(dt))
+	if size == 0 || size > hdf5MaxValueBytes {
+		return nil
+	}
+	data := layout.compact
+	if layout.class == "contiguous" {
+		if data, err = t.f.read(layout.addr, int(size)); err != nil {
+			return nil
+		}
+	}
+	if data == nil {
+		return nil
+	}
+	v, err := t.f.decodeValue(dt, space, data)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// findClass returns the first group under prefix whose NX_class attribute
+// equals class.
+func (t *h5Tree) findClass(prefix, class string) *h5Object {
+	for _, path := range t.paths {
+		obj := t.objects[path]
+		if !obj.group || !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+		if h5String(obj.attrs["NX_class"]) == class {
+			return obj
+		}
+	}
+	return nil
+}
+
+// field returns the value and units attribute of a dataset in a group.
+func (t *h5Tree) field(group *h5Object, name string) (interface{}, string) {
+	if group == nil {
+		return nil, ""
+	}
+	path := strings.TrimSuffix(group.path, "/") + "/" + name
+	obj := t.objects[path]
+	if obj == nil {
+		return nil, ""
+	}
+	return t.value(path), h5String(obj.attrs["units"])
+}
+
+// stringField returns a dataset value as a string.
+func (t *h5Tree) stringField(group *h5Object, name string) string {
+	v, _ := t.field(group, name)
+	return strings.TrimSpace(h5String(v))
+}
+
+// h5String converts a decoded value to a string. Single-element arrays
+// unwrap to their element.
+func h5String(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case []interface{}:
+		if len(value) == 1 {
+			return h5String(value[0])
+		}
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	return ""
+}
+
+// h5Float converts a decoded number, numeric string or the first element
+// of an array to a float.
+func h5Float(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case int64:
+		return float64(value), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return f, err == nil
+	case []interface{}:
+		if len(value) > 0 {
+			return h5Float(value[0])
+		}
+	}
+	return 0, false
+}
+
+// mapNeXus maps NeXus base classes to XRayMetadata field names.
+func (t *h5Tree) mapNeXus(metadata map[string]interface{}) {
+	entry := t.findClass("", "NXentry")
+	metadata["format"] = "NeXus"
+	metadata["schema_name"] = "nexus_v1"
+	metadata["nexus_entry"] = entry.path
+
+	if definition := t.stringField(entry, "definition"); definition != "" {
+		metadata["nexus_definition"] = definition
+	}
+	if title := t.stringField(entry, "title"); title != "" {
+		metadata["title"] = title
+	}
+	if id := t.stringField(entry, "experiment_identifier"); id != "" {
+		metadata["experiment_identifier"] = id
+	}
+	if start := t.stringField(entry, "start_time"); start != "" {
+		metadata["acquisition_date"] = start
+	}
+	if end := t.stringField(entry, "end_time"); end != "" {
+		metadata["end_time"] = end
+	}
+
+	instrumentType := "xray"
+	if source := t.findClass(entry.path, "NXsource"); source != nil {
+		if name := t.stringField(source, "name"); name != "" {
+			metadata["facility"] = name
+		}
+		if sourceType := t.stringField(source, "type"); sourceType != "" {
+			metadata["source_type"] = sourceType
+		}
+		if probe := t.stringField(source, "probe"); probe != "" {
+			metadata["probe"] = probe
+			if strings.Contains(strings.ToLower(probe), "neutron") {
+				instrumentType = "neutron"
+			}
+		}
+	}
+	metadata["instrument_type"] = instrumentType
+
+	if instrument := t.findClass(entry.path, "NXinstrument"); instrument != nil {
+		if name := t.stringField(instrument, "name"); name != "" {
+			metadata["beamline"] = name
+		}
+	}
+
+	for _, class := range []string{"NXmonochromator", "NXbeam"} {
+		group := t.findClass(entry.path, class)
+		for _, name := range []string{"energy", "incident_energy"} {
+			if _, ok := metadata["energy"]; ok {
+				break
+			}
+			v, units := t.field(group, name)
+			if keV, ok := nexusEnergyKeV(v, units); ok {
+				metadata["energy"] = keV
+			}
+		}
+		for _, name := range []string{"wavelength", "incident_wavelength"} {
+			if _, ok := metadata["wavelength"]; ok {
+				break
+			}
+			v, units := t.field(group, name)
+			if f, ok := h5Float(v); ok {
+				if angstrom, ok := omeLengthIn(f, nexusLengthUnit(units, "Å"), "Å"); ok {
+					metadata["wavelength"] = angstrom
+				}
+			}
+		}
+	}
+	energy, hasEnergy := metadata["energy"].(float64)
+	wavelength, hasWavelength := metadata["wavelength"].(float64)
+	switch {
+	case hasEnergy && !hasWavelength && energy > 0:
+		metadata["wavelength"] = hcKeV / energy
+	case hasWavelength && !hasEnergy && wavelength > 0:
+		metadata["energy"] = hcKeV / wavelength
+	}
+
+	if detector := t.findClass(entry.path, "NXdetector"); detector != nil {
+		for _, name := range []string{"description", "local_name", "type"} {
+			if model := t.stringField(detector, name); model != "" {
+				metadata["detector_model"] = model
+				break
+			}
+		}
+		if serial := t.stringField(detector, "serial_number"); serial != "" {
+			metadata["detector_serial"] = serial
+		}
+		if v, units := t.field(detector, "distance"); v != nil {
+			if f, ok := h5Float(v); ok {
+				if mm, ok := omeLengthIn(f, nexusLengthUnit(units, "mm"), "mm"); ok {
+					metadata["distance"] = mm
+				}
+			}
+		}
+		if v, units := t.field(detector, "count_time"); v != nil {
+			if f, ok := h5Float(v); ok {
+				if seconds, ok := omeTimeInSeconds(f, nexusTimeUnit(units)); ok {
+					metadata["exposure_time"] = seconds
+				}
+			}
+		}
+	}
+
+	if sample := t.findClass(entry.path, "NXsample"); sample != nil {
+		if name := t.stringField(sample, "name"); name != "" {
+			metadata["sample_id"] = name
+		}
+		if formula := t.stringField(sample, "chemical_formula"); formula != "" {
+			metadata["chemical_formula"] = formula
+		}
+	}
+
+	if user := t.findClass(entry.path, "NXuser"); user != nil {
+		if name := t.stringField(user, "name"); name != "" {
+			metadata["operator"] = name
+		}
+	}
+
+	// Frame count from the first image stack in an NXdata group
+	if data := t.findClass(entry.path, "NXdata"); data != nil {
+		signal := h5String(data.attrs["signal"])
+		if signal == "" {
+			signal = "data"
+		}
+		if dims := t.shape(data.path + "/" + signal); len(dims) >= 3 {
+			metadata["total_frames"] = int64(dims[0])
+		}
+	}
+}
+
+// nexusEnergyKeV converts an energy field to keV. NeXus energies without
+// units are assumed to be keV.
+func nexusEnergyKeV(v interface{}, units string) (float64, bool) {
+	f, ok := h5Float(v)
+	if !ok {
+		return 0, false
+	}
+	switch strings.ToLower(strings.TrimSpace(units)) {
+	case "", "kev":
+		return f, true
+	case "ev":
+		return f / 1e3, true
+	case "mev":
+		return f * 1e3, true
+	case "j":
+		return f / 1.602176634e-16, true
+	}
+	return 0, false
+}
+
+// nexusLengthUnit normalises NeXus length units to the OME unit symbols
+// understood by omeLengthIn.
+func nexusLengthUnit(units, fallback string) string {
+	switch strings.ToLower(strings.TrimSpace(units)) {
+	case "":
+		return fallback
+	case "angstrom", "angstroms", "a", "å":
+		return "Å"
+	case "micron", "microns", "um", "µm", "μm", "micrometer", "micrometre":
+		return "µm"
+	case "nm", "nanometer", "nanometre":
+		return "nm"
+	case "mm", "millimeter", "millimetre":
+		return "mm"
+	case "cm":
+		return "cm"
+	case "m", "meter", "metre":
+		return "m"
+	}
+	return units
+}
+
+// nexusTimeUnit normalises NeXus time units for omeTimeInSeconds.
+func nexusTimeUnit(units string) string {
+	switch strings.ToLower(strings.TrimSpace(units)) {
+	case "second", "seconds", "sec":
+		return "s"
+	case "millisecond", "milliseconds", "msec":
+		return "ms"
+	case "microsecond", "microseconds", "usec":
+		return "us"
+	}
+	return strings.TrimSpace(units)
+}
+
+// isImaris reports whether the file follows the Imaris 5.5 layout.
+func (t *h5Tree) isImaris() bool {
+	if _, ok := t.objects["/"].attrs["ImarisDataSet"]; ok {
+		return true
+	}
+	_, ok := t.objects["/DataSetInfo/Image"]
+	return ok
+}
+
+// imarisAttr returns a DataSetInfo attribute as a trimmed string.
+func (t *h5Tree) imarisAttr(group, name string) string {
+	obj := t.objects["/DataSetInfo/"+group]
+	if obj == nil {
+		return ""
+	}
+	return strings.TrimSpace(h5String(obj.attrs[name]))
+}
+
+// mapImaris maps the Imaris DataSetInfo groups to MicroscopyMetadata field
+// names.
+func (t *h5Tree) mapImaris(metadata map[string]interface{}) {
+	metadata["format"] = "Imaris"
+	metadata["schema_name"] = "imaris_v1"
+	metadata["instrument_type"] = "microscopy"
+	metadata["software_name"] = "Imaris"
+	if version := h5String(t.objects["/"].attrs["ImarisVersion"]); version != "" {
+		metadata["imaris_file_version"] = version
+	}
+	if version := t.imarisAttr("Imaris", "Version"); version != "" {
+		metadata["software_version"] = version
+	}
+
+	sizes := map[string]int{}
+	for _, axis := range []struct{ attr, field string }{
+		{"X", "image_width"}, {"Y", "image_height"}, {"Z", "image_depth"},
+	} {
+		if n, err := strconv.Atoi(t.imarisAttr("Image", axis.attr)); err == nil && n > 0 {
+			sizes[axis.attr] = n
+			metadata[axis.field] = n
+		}
+	}
+
+	unit := t.imarisAttr("Image", "Unit")
+	for i, axis := range []string{"X", "Y", "Z"} {
+		lo, errLo := strconv.ParseFloat(t.imarisAttr("Image", fmt.Sprintf("ExtMin%d", i)), 64)
+		hi, errHi := strconv.ParseFloat(t.imarisAttr("Image", fmt.Sprintf("ExtMax%d", i)), 64)
+		if errLo != nil || errHi != nil || sizes[axis] == 0 {
+			continue
+		}
+		if um, ok := omeLengthIn((hi-lo)/float64(sizes[axis]), nexusLengthUnit(unit, "µm"), "µm"); ok && um > 0 {
+			metadata["pixel_size_"+strings.ToLower(axis)+"_um"] = um
+		}
+	}
+
+	if name := t.imarisAttr("Image", "Name"); name != "" {
+		metadata["image_name"] = name
+	}
+	if description := t.imarisAttr("Image", "Description"); description != "" {
+		metadata["description"] = description
+	}
+	if recorded := t.imarisAttr("Image", "RecordingDate"); recorded != "" {
+		if ts, err := time.Parse("2006-01-02 15:04:05.000", recorded); err == nil {
+			metadata["acquisition_date"] = ts.Format(time.RFC3339)
+		} else {
+			metadata["acquisition_date"] = recorded
+		}
+	}
+
+	var channels []map[string]interface{}
+	for i := 0; ; i++ {
+		group := fmt.Sprintf("Channel %d", i)
+		if t.objects["/DataSetInfo/"+group] == nil {
+			break
+		}
+		channel := map[string]interface{}{"index": i}
+		if name := t.imarisAttr(group, "Name"); name != "" {
+			channel["name"] = name
+		}
+		if color := imarisColorHex(t.imarisAttr(group, "Color")); color != "" {
+			channel["color"] = color
+		}
+		for _, wl := range []struct{ attr, field string }{
+			{"LSMEmissionWavelength", "emission_wavelength_nm"},
+			{"LSMExcitationWavelength", "excitation_wavelength_nm"},
+		} {
+			if v, err := strconv.ParseFloat(t.imarisAttr(group, wl.attr), 64); err == nil && v > 0 {
+				channel[wl.field] = v
+			}
+		}
+		channels = append(channels, channel)
+	}
+	if len(channels) > 0 {
+		metadata["channels"] = channels
+		metadata["num_channels"] = len(channels)
+	}
+
+	for _, attr := range []string{"DatasetTimePoints", "FileTimePoints"} {
+		if n, err := strconv.Atoi(t.imarisAttr("TimeInfo", attr)); err == nil && n > 0 {
+			metadata["num_timepoints"] = n
+			break
+		}
+	}
+
+	levels := 0
+	for t.objects[fmt.Sprintf("/DataSet/ResolutionLevel %d", levels)] != nil {
+		levels++
+	}
+	if levels > 0 {
+		metadata["resolution_levels"] = levels
+	}
+	if obj := t.objects["/DataSet/ResolutionLevel 0/TimePoint 0/Channel 0/Data"]; obj != nil {
+		if m := findMessage(obj.msgs, h5MsgDatatype); m != nil {
+			if dt, _, err := parseDatatype(m.data); err == nil && dt.class == h5ClassFixedPoint {
+				metadata["bit_depth"] = dt.size * 8
+			}
+		}
+	}
+}
+
+// imarisColorHex converts an Imaris "r g b" color with components in [0, 1]
+// to #RRGGBB.
+func imarisColorHex(color string) string {
+	parts := strings.Fields(color)
+	if len(parts) != 3 {
+		return ""
+	}
+	var rgb [3]int
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return ""
+		}
+		rgb[i] = int(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	return fmt.Sprintf("#%02X%02X%02X", rgb[0], rgb[1], rgb[2])
+}
@@ -0,0 +1,540 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testH5Writer builds small HDF5 files with 8-byte offsets and lengths.
+// Objects are appended bottom-up so parents can reference their children.
+type testH5Writer struct {
+	buf []byte
+}
+
+type testH5Msg struct {
+	typ  uint16
+	data []byte
+}
+
+type testH5Child struct {
+	name string
+	addr uint64
+}
+
+const testH5Undefined = math.MaxUint64
+
+func le16(v uint16) []byte { return binary.LittleEndian.AppendUint16(nil, v) }
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+func le64(v uint64) []byte { return binary.LittleEndian.AppendUint64(nil, v) }
+
+func join(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+func pad8(b []byte) []byte {
+	return append(b, make([]byte, (8-len(b)%8)%8)...)
+}
+
+// append writes data at an 8-byte aligned address and returns it.
+func (w *testH5Writer) append(data []byte) uint64 {
+	w.buf = pad8(w.buf)
+	addr := uint64(len(w.buf))
+	w.buf = append(w.buf, data...)
+	return addr
+}
+
+// superblock0 writes a version 0 superblock; the root address is patched in
+// by finish.
+func (w *testH5Writer) superblock0() {
+	w.buf = join([]byte(hdf5Signature),
+		[]byte{0, 0, 0, 0, 0, 8, 8, 0}, le16(4), le16(16), le32(0),
+		le64(0), le64(testH5Undefined), le64(0), le64(testH5Undefined),
+		le64(0), le64(0), le32(1), le32(0), make([]byte, 16))
+}
+
+// superblock2 writes a version 2 superblock.
+func (w *testH5Writer) superblock2() {
+	w.buf = join([]byte(hdf5Signature), []byte{2, 8, 8, 0},
+		le64(0), le64(testH5Undefined), le64(0), le64(0), le32(0))
+}
+
+// finish patches the root object header address into the superblock.
+func (w *testH5Writer) finish(root uint64) []byte {
+	if w.buf[8] == 0 {
+		binary.LittleEndian.PutUint64(w.buf[64:], root)
+	} else {
+		binary.LittleEndian.PutUint64(w.buf[36:], root)
+	}
+	return w.buf
+}
+
+// objectV1 writes a version 1 object header.
+func (w *testH5Writer) objectV1(msgs ...testH5Msg) uint64 {
+	var body []byte
+	for _, m := range msgs {
+		data := pad8(append([]byte(nil), m.data...))
+		body = append(body, join(le16(m.typ), le16(uint16(len(data))), []byte{0, 0, 0, 0}, data)...)
+	}
+	return w.append(join([]byte{1, 0}, le16(uint16(len(msgs))), le32(1), le32(uint32(len(body))), make([]byte, 4), body))
+}
+
+// objectV2 writes a version 2 object header with a 4-byte chunk size.
+func (w *testH5Writer) objectV2(msgs ...testH5Msg) uint64 {
+	var body []byte
+	for _, m := range msgs {
+		body = append(body, join([]byte{byte(m.typ)}, le16(uint16(len(m.data))), []byte{0}, m.data)...)
+	}
+	return w.append(join([]byte("OHDR"), []byte{2, 0x02}, le32(uint32(len(body))), body, le32(0)))
+}
+
+// symbolTableGroup writes an old-style group: local heap, one symbol
+// table node, a one-entry B-tree and the group object header.
+func (w *testH5Writer) symbolTableGroup(children []testH5Child, attrs ...testH5Msg) uint64 {
+	heap := pad8([]byte{0})
+	var entries []byte
+	var lastName uint64
+	for _, child := range children {
+		lastName = uint64(len(heap))
+		heap = pad8(append(heap, append([]byte(child.name), 0)...))
+		entries = append(entries, join(le64(lastName), le64(child.addr), le32(0), le32(0), make([]byte, 16))...)
+	}
+	heapData := w.append(heap)
+	heapAddr := w.append(join([]byte("HEAP"), []byte{0, 0, 0, 0}, le64(uint64(len(heap))), le64(testH5Undefined), le64(heapData)))
+	node := w.append(join([]byte("SNOD"), []byte{1, 0}, le16(uint16(len(children))), entries))
+	btree := w.append(join([]byte("TREE"), []byte{0, 0}, le16(1), le64(testH5Undefined), le64(testH5Undefined),
+		le64(0), le64(node), le64(lastName)))
+	msgs := append([]testH5Msg{{h5MsgSymbolTable, join(le64(btree), le64(heapAddr))}}, attrs...)
+	return w.objectV1(msgs...)
+}
+
+// linkGroup writes a new-style group holding compact link messages.
+func (w *testH5Writer) linkGroup(children []testH5Child, attrs ...testH5Msg) uint64 {
+	msgs := []testH5Msg{{h5MsgLinkInfo, join([]byte{0, 0}, le64(testH5Undefined), le64(testH5Undefined))}}
+	for _, child := range children {
+		msgs = append(msgs, testH5Msg{h5MsgLink, testH5Link(child)})
+	}
+	return w.objectV2(append(msgs, attrs...)...)
+}
+
+// denseGroup writes a new-style group whose links live in a fractal heap
+// with a single direct block, indexed by a one-leaf v2 B-tree.
+func (w *testH5Writer) denseGroup(children []testH5Child, attrs ...testH5Msg) uint64 {
+	// Heap IDs are 7 bytes: type byte, 4-byte offset, 2-byte length
+	const blockHeader = 4 + 1 + 8 + 4
+	block := []byte{}
+	var records []byte
+	for i, child := range children {
+		link := testH5Link(child)
+		offset := blockHeader + len(block)
+		block = append(block, link...)
+		records = append(records, join(le32(uint32(i)), []byte{0}, le32(uint32(offset)), le16(uint16(len(link))))...)
+	}
+
+	heapAddr := uint64(len(pad8(w.buf)))
+	header := join([]byte("FRHP"), []byte{0}, le16(7), le16(0), []byte{0}, le32(4096),
+		le64(0), le64(testH5Undefined), le64(0), le64(testH5Undefined),
+		le64(0), le64(0), le64(0), le64(uint64(len(children))),
+		le64(0), le64(0), le64(0), le64(0),
+		le16(4), le64(512), le64(65536), le16(32), le16(0))
+	rootOffset := len(header)
+	header = join(header, le64(0), le16(0), le32(0))
+	w.append(header)
+
+	direct := join([]byte("FHDB"), []byte{0}, le64(heapAddr), le32(0), block)
+	direct = append(direct, make([]byte, 512-len(direct))...)
+	directAddr := w.append(direct)
+	binary.LittleEndian.PutUint64(w.buf[heapAddr+uint64(rootOffset):], directAddr)
+
+	leaf := w.append(append(join([]byte("BTLF"), []byte{0, 5}, records), make([]byte, 4)...))
+	btree := w.append(join([]byte("BTHD"), []byte{0, 5}, le32(512), le16(11), le16(0), []byte{100, 40},
+		le64(leaf), le16(uint16(len(children))), le64(uint64(len(children))), le32(0)))
+
+	msgs := []testH5Msg{{h5MsgLinkInfo, join([]byte{0, 0}, le64(heapAddr), le64(btree))}}
+	return w.objectV2(append(msgs, attrs...)...)
+}
+
+// contiguousDataset writes a dataset with contiguous storage.
+func (w *testH5Writer) contiguousDataset(dtype, space, data []byte, attrs ...testH5Msg) uint64 {
+	addr := w.append(data)
+	msgs := []testH5Msg{
+		{h5MsgDataspace, space},
+		{h5MsgDatatype, dtype},
+		{h5MsgLayout, join([]byte{3, 1}, le64(addr), le64(uint64(len(data))))},
+	}
+	return w.objectV1(append(msgs, attrs...)...)
+}
+
+// chunkedDataset writes a deflate-compressed chunked dataset header
+// without any chunk data.
+func (w *testH5Writer) chunkedDataset(dtype, space []byte, chunks []uint32, v2 bool) uint64 {
+	layout := join([]byte{3, 2, byte(len(chunks) + 1)}, le64(testH5Undefined))
+	for _, c := range chunks {
+		layout = append(layout, le32(c)...)
+	}
+	layout = append(layout, le32(binary.LittleEndian.Uint32(dtype[4:8]))...)
+	msgs := []testH5Msg{
+		{h5MsgDataspace, space},
+		{h5MsgDatatype, dtype},
+		{h5MsgLayout, layout},
+		{h5MsgFilterPipeline, join([]byte{2, 1}, le16(1), le16(0), le16(1), le32(4))},
+	}
+	if v2 {
+		return w.objectV2(msgs...)
+	}
+	return w.objectV1(msgs...)
+}
+
+// globalHeap writes a global heap collection holding one object.
+func (w *testH5Writer) globalHeap(obj []byte) uint64 {
+	body := join(le16(1), le16(1), le32(0), le64(uint64(len(obj))), pad8(append([]byte(nil), obj...)))
+	size := 16 + len(body) + 16
+	return w.append(join([]byte("GCOL"), []byte{1, 0, 0, 0}, le64(uint64(size)), body, make([]byte, 16)))
+}
+
+func testH5Link(child testH5Child) []byte {
+	return join([]byte{1, 0, byte(len(child.name))}, []byte(child.name), le64(child.addr))
+}
+
+func testH5Fixed(size int, signed bool) []byte {
+	bits := byte(0)
+	if signed {
+		bits = 0x08
+	}
+	return join([]byte{0x10, bits, 0, 0}, le32(uint32(size)), le16(0), le16(uint16(size*8)))
+}
+
+func testH5Float64() []byte {
+	return join([]byte{0x11, 0x20, 63, 0}, le32(8), le16(0), le16(64), []byte{52, 11, 0, 52}, le32(1023))
+}
+
+func testH5String(size int) []byte {
+	return join([]byte{0x13, 0, 0, 0}, le32(uint32(size)))
+}
+
+func testH5VlenString() []byte {
+	return join([]byte{0x19, 0x01, 0, 0}, le32(16), testH5Fixed(1, false))
+}
+
+func testH5Scalar() []byte {
+	return []byte{1, 0, 0, 0, 0, 0, 0, 0}
+}
+
+func testH5Simple(dims ...uint64) []byte {
+	b := []byte{1, byte(len(dims)), 0, 0, 0, 0, 0, 0}
+	for _, d := range dims {
+		b = append(b, le64(d)...)
+	}
+	return b
+}
+
+// testH5Attr encodes an attribute message (version 1, or 3 when v3).
+func testH5Attr(name string, dtype, space, data []byte, v3 bool) testH5Msg {
+	nameBytes := append([]byte(name), 0)
+	if v3 {
+		return testH5Msg{h5MsgAttribute, join([]byte{3, 0}, le16(uint16(len(nameBytes))),
+			le16(uint16(len(dtype))), le16(uint16(len(space))), []byte{0}, nameBytes, dtype, space, data)}
+	}
+	return testH5Msg{h5MsgAttribute, join([]byte{1, 0}, le16(uint16(len(nameBytes))),
+		le16(uint16(len(dtype))), le16(uint16(len(space))), pad8(nameBytes), pad8(dtype), pad8(space), data)}
+}
+
+// testH5StrAttr is a scalar fixed-length string attribute.
+func testH5StrAttr(name, value string, v3 bool) testH5Msg {
+	return testH5Attr(name, testH5String(len(value)+1), testH5Scalar(), append([]byte(value), 0), v3)
+}
+
+// testH5CharsAttr is an Imaris-style attribute: an array of single characters.
+func testH5CharsAttr(name, value string) testH5Msg {
+	return testH5Attr(name, testH5String(1), testH5Simple(uint64(len(value))), []byte(value), true)
+}
+
+// buildTestNeXus builds a superblock v0 NeXus file with old-style groups.
+func buildTestNeXus() []byte {
+	w := &testH5Writer{}
+	w.superblock0()
+
+	strDataset := func(value string, attrs ...testH5Msg) uint64 {
+		return w.contiguousDataset(testH5String(len(value)+1), testH5Scalar(), append([]byte(value), 0), attrs...)
+	}
+	floatDataset := func(value float64, units string) uint64 {
+		return w.contiguousDataset(testH5Float64(), testH5Scalar(), le64(math.Float64bits(value)),
+			testH5StrAttr("units", units, false))
+	}
+	nxClass := func(class string) testH5Msg { return testH5StrAttr("NX_class", class, false) }
+
+	source := w.symbolTableGroup([]testH5Child{
+		{"name", strDataset("Diamond Light Source")},
+		{"probe", strDataset("x-ray")},
+		{"type", strDataset("Synchrotron X-ray Source")},
+	}, nxClass("NXsource"))
+	mono := w.symbolTableGroup([]testH5Child{
+		{"energy", floatDataset(12658, "eV")},
+	}, nxClass("NXmonochromator"))
+	detector := w.symbolTableGroup([]testH5Child{
+		{"count_time", floatDataset(10, "ms")},
+		{"description", strDataset("Eiger2 XE 16M")},
+		{"distance", floatDataset(0.25, "m")},
+	}, nxClass("NXdetector"))
+	instrument := w.symbolTableGroup([]testH5Child{
+		{"detector", detector},
+		{"monochromator", mono},
+		{"name", strDataset("I04")},
+		{"source", source},
+	}, nxClass("NXinstrument"))
+	sample := w.symbolTableGroup([]testH5Child{
+		{"name", strDataset("lysozyme_01")},
+	}, nxClass("NXsample"))
+	frames := w.chunkedDataset(testH5Fixed(2, false), testH5Simple(100, 64, 64), []uint32{1, 64, 64}, false)
+	data := w.symbolTableGroup([]testH5Child{{"data", frames}},
+		nxClass("NXdata"), testH5StrAttr("signal", "data", false))
+	entry := w.symbolTableGroup([]testH5Child{
+		{"data", data},
+		{"definition", strDataset("NXmx")},
+		{"instrument", instrument},
+		{"sample", sample},
+		{"start_time", strDataset("2024-05-01T10:00:00Z")},
+		{"title", strDataset("Lysozyme native")},
+	}, nxClass("NXentry"))
+
+	heapAddr := w.globalHeap([]byte("collection.nxs"))
+	root := w.symbolTableGroup([]testH5Child{{"entry", entry}},
+		testH5StrAttr("NX_class", "NXroot", false),
+		testH5Attr("file_name", testH5VlenString(), testH5Scalar(),
+			join(le32(14), le64(heapAddr), le32(1)), false),
+		testH5Attr("file_version", testH5Fixed(4, true), testH5Simple(3),
+			join(le32(1), le32(2), le32(0xFFFFFFFF)), false))
+	return w.finish(root)
+}
+
+// buildTestImaris builds a superblock v2 Imaris file with new-style groups;
+// DataSetInfo uses dense link storage.
+func buildTestImaris() []byte {
+	w := &testH5Writer{}
+	w.superblock2()
+
+	image := w.linkGroup(nil,
+		testH5CharsAttr("X", "512"), testH5CharsAttr("Y", "256"), testH5CharsAttr("Z", "10"),
+		testH5CharsAttr("ExtMin0", "0"), testH5CharsAttr("ExtMax0", "102.4"),
+		testH5CharsAttr("ExtMin1", "0"), testH5CharsAttr("ExtMax1", "51.2"),
+		testH5CharsAttr("ExtMin2", "-10"), testH5CharsAttr("ExtMax2", "10"),
+		testH5CharsAttr("Unit", "um"), testH5CharsAttr("RecordingDate", "2021-03-04 10:20:30.000"))
+	ch0 := w.linkGroup(nil, testH5CharsAttr("Name", "DAPI"), testH5CharsAttr("Color", "0.000 0.000 1.000"),
+		testH5CharsAttr("LSMEmissionWavelength", "461"))
+	ch1 := w.linkGroup(nil, testH5CharsAttr("Name", "GFP"), testH5CharsAttr("Color", "0 1 0"))
+	timeInfo := w.linkGroup(nil, testH5CharsAttr("DatasetTimePoints", "3"))
+	app := w.linkGroup(nil, testH5CharsAttr("Version", "9.9"))
+	info := w.denseGroup([]testH5Child{
+		{"Channel 0", ch0}, {"Channel 1", ch1}, {"Image", image}, {"Imaris", app}, {"TimeInfo", timeInfo},
+	})
+
+	stack := w.chunkedDataset(testH5Fixed(2, false), testH5Simple(10, 256, 512), []uint32{8, 128, 128}, true)
+	channel := w.linkGroup([]testH5Child{{"Data", stack}})
+	timepoint := w.linkGroup([]testH5Child{{"Channel 0", channel}})
+	level := w.linkGroup([]testH5Child{{"TimePoint 0", timepoint}})
+	dataset := w.linkGroup([]testH5Child{{"ResolutionLevel 0", level}})
+
+	root := w.linkGroup([]testH5Child{{"DataSet", dataset}, {"DataSetInfo", info}},
+		testH5CharsAttr("ImarisDataSet", "ImarisDataSet"), testH5CharsAttr("ImarisVersion", "5.5.0"),
+		testH5Attr("NumberOfDataSets", testH5Fixed(4, false), testH5Simple(1), le32(1), true))
+	return w.finish(root)
+}
+
+func TestHDF5ExtractorNeXus(t *testing.T) {
+	extractor := &HDF5Extractor{}
+	metadata, err := extractor.ExtractFromReader(bytes.NewReader(buildTestNeXus()), "scan_0001.nxs")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":             "NeXus",
+		"superblock_version": 0,
+		"nexus_entry":        "/entry",
+		"nexus_definition":   "NXmx",
+		"title":              "Lysozyme native",
+		"acquisition_date":   "2024-05-01T10:00:00Z",
+		"facility":           "Diamond Light Source",
+		"beamline":           "I04",
+		"probe":              "x-ray",
+		"instrument_type":    "xray",
+		"detector_model":     "Eiger2 XE 16M",
+		"sample_id":          "lysozyme_01",
+		"total_frames":       int64(100),
+		"group_count":        7,
+		"dataset_count":      13,
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(metadata[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+
+	for key, value := range map[string]float64{
+		"energy":        12.658,
+		"wavelength":    hcKeV / 12.658,
+		"distance":      250,
+		"exposure_time": 0.01,
+	} {
+		got, ok := metadata[key].(float64)
+		if !ok || math.Abs(got-value) > 1e-9 {
+			t.Errorf("%s = %v, want %v", key, metadata[key], value)
+		}
+	}
+
+	rootAttrs, ok := metadata["root_attributes"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("root_attributes missing: %v", metadata["root_attributes"])
+	}
+	if rootAttrs["file_name"] != "collection.nxs" {
+		t.Errorf("vlen string attribute = %v, want collection.nxs", rootAttrs["file_name"])
+	}
+	if !reflect.DeepEqual(rootAttrs["file_version"], []interface{}{int64(1), int64(2), int64(-1)}) {
+		t.Errorf("int array attribute = %v", rootAttrs["file_version"])
+	}
+
+	groupAttrs := metadata["group_attributes"].(map[string]interface{})
+	if attrs := groupAttrs["/entry/data"].(map[string]interface{}); attrs["signal"] != "data" {
+		t.Errorf("group attribute signal = %v, want data", attrs["signal"])
+	}
+
+	var frames map[string]interface{}
+	for _, ds := range metadata["datasets"].([]map[string]interface{}) {
+		if ds["path"] == "/entry/data/data" {
+			frames = ds
+		}
+	}
+	if frames == nil {
+		t.Fatal("dataset /entry/data/data not listed")
+	}
+	if !reflect.DeepEqual(frames["shape"], []int64{100, 64, 64}) {
+		t.Errorf("shape = %v", frames["shape"])
+	}
+	if !reflect.DeepEqual(frames["chunks"], []int64{1, 64, 64}) {
+		t.Errorf("chunks = %v", frames["chunks"])
+	}
+	if frames["dtype"] != "uint16" || frames["layout"] != "chunked" {
+		t.Errorf("dtype/layout = %v/%v, want uint16/chunked", frames["dtype"], frames["layout"])
+	}
+	if !reflect.DeepEqual(frames["filters"], []string{"deflate"}) {
+		t.Errorf("filters = %v, want [deflate]", frames["filters"])
+	}
+}
+
+func TestHDF5ExtractorImaris(t *testing.T) {
+	extractor := &HDF5Extractor{}
+	metadata, err := extractor.ExtractFromReader(bytes.NewReader(buildTestImaris()), "cells.ims")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":              "Imaris",
+		"superblock_version":  2,
+		"instrument_type":     "microscopy",
+		"software_name":       "Imaris",
+		"software_version":    "9.9",
+		"imaris_file_version": "5.5.0",
+		"image_width":         512,
+		"image_height":        256,
+		"image_depth":         10,
+		"num_channels":        2,
+		"num_timepoints":      3,
+		"resolution_levels":   1,
+		"bit_depth":           16,
+		"acquisition_date":    "2021-03-04T10:20:30Z",
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(metadata[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+
+	for key, value := range map[string]float64{
+		"pixel_size_x_um": 0.2,
+		"pixel_size_y_um": 0.2,
+		"pixel_size_z_um": 2,
+	} {
+		got, ok := metadata[key].(float64)
+		if !ok || math.Abs(got-value) > 1e-9 {
+			t.Errorf("%s = %v, want %v", key, metadata[key], value)
+		}
+	}
+
+	channels := metadata["channels"].([]map[string]interface{})
+	if channels[0]["name"] != "DAPI" || channels[0]["color"] != "#0000FF" || channels[0]["emission_wavelength_nm"] != 461.0 {
+		t.Errorf("channel 0 = %v", channels[0])
+	}
+	if channels[1]["name"] != "GFP" || channels[1]["color"] != "#00FF00" {
+		t.Errorf("channel 1 = %v", channels[1])
+	}
+
+	datasets := metadata["datasets"].([]map[string]interface{})
+	if len(datasets) != 1 || datasets[0]["path"] != "/DataSet/ResolutionLevel 0/TimePoint 0/Channel 0/Data" {
+		t.Fatalf("datasets = %v", datasets)
+	}
+	if !reflect.DeepEqual(datasets[0]["chunks"], []int64{8, 128, 128}) {
+		t.Errorf("chunks = %v", datasets[0]["chunks"])
+	}
+}
+
+func TestHDF5ExtractorUserBlock(t *testing.T) {
+	file := buildTestImaris()
+	// A 512-byte user block shifts the superblock; addresses stay relative
+	withUserBlock := append(make([]byte, 512), file...)
+
+	metadata, err := (&HDF5Extractor{}).ExtractFromReader(bytes.NewReader(withUserBlock), "cells.h5")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	if metadata["image_width"] != 512 {
+		t.Errorf("image_width = %v, want 512", metadata["image_width"])
+	}
+}
+
+func TestHDF5ExtractorInvalid(t *testing.T) {
+	extractor := &HDF5Extractor{}
+	if _, err := extractor.ExtractFromReader(bytes.NewReader([]byte("not an hdf5 file at all")), "bad.h5"); err == nil {
+		t.Error("expected error for invalid file")
+	}
+
+	for _, name := range []string{"a.h5", "b.HDF5", "scan.nxs", "cells.ims"} {
+		if !extractor.CanHandle(name) {
+			t.Errorf("CanHandle(%q) = false, want true", name)
+		}
+	}
+	if extractor.CanHandle("image.tif") {
+		t.Error("CanHandle(image.tif) = true, want false")
+	}
+}
+
+func TestHDF5ExtractorCorrupt(t *testing.T) {
+	// Sizes and counts read from a corrupt file must not drive allocations
+	// or slicing; every mutation should fail or succeed, never panic
+	extractor := &HDF5Extractor{}
+	for name, file := range map[string][]byte{"scan.nxs": buildTestNeXus(), "cells.ims": buildTestImaris()} {
+		for _, fill := range []byte{0xFF, 0x7F} {
+			for off := 8; off < len(file); off += 3 {
+				mutated := bytes.Clone(file)
+				for i := off; i < off+8 && i < len(mutated); i++ {
+					mutated[i] = fill
+				}
+				_, _ = extractor.ExtractFromReader(bytes.NewReader(mutated), name)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iso8601Layouts are the ISO 8601 calendar date and time forms accepted by
+// ParseISO8601Time, in extended and basic format.
+var iso8601Layouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999Z07",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"20060102T150405.999999999Z0700",
+	"20060102T150405.999999999Z07",
+	"20060102T150405.999999999",
+	"20060102",
+}
+
+// ParseISO8601Time parses an ISO 8601 date or date and time. A space may
+// stand in for the "T" separator. Times without a zone are read as UTC.
+func ParseISO8601Time(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 10 && s[10] == ' ' {
+		s = s[:10] + "T" + s[11:]
+	}
+	for _, layout := range iso8601Layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("not an ISO 8601 date: %q", s)
+}
+
+// ParseISO8601Duration parses an ISO 8601 duration such as "PT1H30M" or
+// "P1DT12H". Years count as 365 days and months as 30 days. Only the last
+// component may have a fraction, and a leading "-" negates the duration.
+func ParseISO8601Duration(s string) (time.Duration, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	sign := 1.0
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	}
+	if len(s) < 3 || (s[0] != 'P' && s[0] != 'p') {
+		return 0, fmt.Errorf("not an ISO 8601 duration: %q", orig)
+	}
+	s = strings.ToUpper(s[1:])
+
+	const day = 24 * float64(time.Hour)
+	dateUnits := map[byte]float64{'Y': 365 * day, 'M': 30 * day, 'W': 7 * day, 'D': day}
+	timeUnits := map[byte]float64{'H': float64(time.Hour), 'M': float64(time.Minute), 'S': float64(time.Second)}
+
+	var total float64
+	inTime := false
+	components := 0
+	fraction := false
+	for len(s) > 0 {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, fmt.Errorf("not an ISO 8601 duration: %q", orig)
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && (isDigit(s[i]) || s[i] == '.' || s[i] == ',') {
+			i++
+		}
+		if i == 0 || i == len(s) || fraction {
+			return 0, fmt.Errorf("not an ISO 8601 duration: %q", orig)
+		}
+		number := strings.Replace(s[:i], ",", ".", 1)
+		n, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, fmt.Errorf("not an ISO 8601 duration: %q", orig)
+		}
+		fraction = strings.Contains(number, ".")
+
+		units := dateUnits
+		if inTime {
+			units = timeUnits
+		}
+		unit, ok := units[s[i]]
+		if !ok {
+			return 0, fmt.Errorf("not an ISO 8601 duration: %q", orig)
+		}
+		total += n * unit
+		components++
+		s = s[i+1:]
+	}
+	if components == 0 {
+		return 0, fmt.Errorf("not an ISO 8601 duration: %q", orig)
+	}
+	if total > math.MaxInt64 {
+		return 0, fmt.Errorf("duration out of range: %q", orig)
+	}
+	return time.Duration(sign * total), nil
+}
+
+// toDateTime converts a time or an ISO 8601 string to a time.
+func toDateTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := ParseISO8601Time(v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// toDuration converts a time.Duration, an ISO 8601 or Go duration string,
+// or a number in the given time units (seconds if none) to a duration.
+func toDuration(v interface{}, units string) (time.Duration, bool) {
+	switch v := v.(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		if d, err := ParseISO8601Duration(v); err == nil {
+			return d, true
+		}
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			return d, true
+		}
+		// A quantity such as "90 min"
+		value, unit, ok := quantity(v)
+		if !ok {
+			return 0, false
+		}
+		seconds, err := ConvertUnit(value, unit, "s")
+		return time.Duration(seconds * float64(time.Second)), err == nil
+	}
+	if f, ok := numericValue(v); ok {
+		if units == "" {
+			units = "s"
+		}
+		seconds, err := ConvertUnit(f, units, "s")
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
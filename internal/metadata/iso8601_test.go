@@ -0,0 +1,114 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseISO8601Time(t *testing.T) {
+	utc := func(y int, m time.Month, d, h, min, s int) time.Time {
+		return time.Date(y, m, d, h, min, s, 0, time.UTC)
+	}
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2025-03-01", utc(2025, 3, 1, 0, 0, 0)},
+		{"2025-03", utc(2025, 3, 1, 0, 0, 0)},
+		{"2025-03-01T12:30:15Z", utc(2025, 3, 1, 12, 30, 15)},
+		{"2025-03-01T12:30:15", utc(2025, 3, 1, 12, 30, 15)},
+		{"2025-03-01 12:30:15", utc(2025, 3, 1, 12, 30, 15)},
+		{"2025-03-01T12:30", utc(2025, 3, 1, 12, 30, 0)},
+		{"2025-03-01T13:30:15+01:00", utc(2025, 3, 1, 12, 30, 15)},
+		{"2025-03-01T13:30:15+0100", utc(2025, 3, 1, 12, 30, 15)},
+		{"20250301T123015Z", utc(2025, 3, 1, 12, 30, 15)},
+		{"20250301", utc(2025, 3, 1, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		got, err := ParseISO8601Time(tt.in)
+		if err != nil {
+			t.Errorf("ParseISO8601Time(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseISO8601Time(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "yesterday", "05-MAY-2023", "2025-13-01", "2025-02-30", "01/03/2025"} {
+		if _, err := ParseISO8601Time(in); err == nil {
+			t.Errorf("ParseISO8601Time(%q) error = nil, want error", in)
+		}
+	}
+}
+
+func TestParseISO8601Duration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"PT1H30M", 90 * time.Minute},
+		{"PT0.5S", 500 * time.Millisecond},
+		{"PT1,5S", 1500 * time.Millisecond},
+		{"P1DT12H", 36 * time.Hour},
+		{"P2W", 14 * 24 * time.Hour},
+		{"P1M", 30 * 24 * time.Hour},
+		{"P1Y", 365 * 24 * time.Hour},
+		{"-PT10M", -10 * time.Minute},
+		{"pt45s", 45 * time.Second},
+	}
+	for _, tt := range tests {
+		got, err := ParseISO8601Duration(tt.in)
+		if err != nil {
+			t.Errorf("ParseISO8601Duration(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseISO8601Duration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "P", "PT", "1H", "PT1.5H30M", "P1H", "PT1D", "P1DT", "P10000000Y"} {
+		if _, err := ParseISO8601Duration(in); err == nil {
+			t.Errorf("ParseISO8601Duration(%q) error = nil, want error", in)
+		}
+	}
+}
+
+func TestToDuration(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		units string
+		want  time.Duration
+	}{
+		{90 * time.Second, "", 90 * time.Second},
+		{"PT2M", "", 2 * time.Minute},
+		{"1h15m", "", 75 * time.Minute},
+		{"90 min", "", 90 * time.Minute},
+		{int64(30), "", 30 * time.Second},
+		{1.5, "min", 90 * time.Second},
+	}
+	for _, tt := range tests {
+		got, ok := toDuration(tt.value, tt.units)
+		if !ok || got != tt.want {
+			t.Errorf("toDuration(%v, %q) = %v, %v, want %v", tt.value, tt.units, got, ok, tt.want)
+		}
+	}
+	if _, ok := toDuration("soon", ""); ok {
+		t.Error(`toDuration("soon") ok = true, want false`)
+	}
+}
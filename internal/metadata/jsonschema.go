@@ -0,0 +1,681 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file converts schemas to and from JSON Schema draft 2020-12.
+//
+// Types, vocabularies (enum), numeric ranges (minimum/maximum), patterns,
+// array items and nested object fields map onto JSON Schema keywords.
+// Properties JSON Schema has no keyword for, such as units, synonyms,
+// required_if and validation rules, are carried in an "x-cicada" annotation
+// so a schema survives a round trip; other JSON Schema validators ignore
+// them. Anything that is ignored or not enforced on either side is
+// reported as a SchemaIssue.
+//
+// JSON Schema treats enum as a hard constraint, where Cicada only warns
+// about values outside a vocabulary.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaDialect is the $schema of exported JSON Schema documents.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchemaExtension is the annotation keyword holding Cicada properties.
+const jsonSchemaExtension = "x-cicada"
+
+// SchemaIssue is a part of a schema that is lost or not enforced when the
+// schema is converted to or from JSON Schema.
+type SchemaIssue struct {
+	Path    string `json:"path"` // Field path, such as channels[].name; empty for the schema itself
+	Message string `json:"message"`
+}
+
+// String formats the issue as "path: message".
+func (i SchemaIssue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return i.Path + ": " + i.Message
+}
+
+// JSONSchema is a JSON Schema document, or a subschema of one, limited to
+// the keywords a Schema converts to.
+type JSONSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	ID          string                 `json:"$id,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+	Pattern     string                 `json:"pattern,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	Maximum     *float64               `json:"maximum,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"`
+	MinItems    int                    `json:"minItems,omitempty"`
+	MaxItems    int                    `json:"maxItems,omitempty"`
+	Default     interface{}            `json:"default,omitempty"`
+	Examples    []interface{}          `json:"examples,omitempty"`
+	Extension   interface{}            `json:"x-cicada,omitempty"`
+}
+
+// schemaExtension holds the schema properties JSON Schema has no keyword
+// for.
+type schemaExtension struct {
+	Name             string            `json:"name,omitempty"`
+	Version          string            `json:"schema_version,omitempty"`
+	Domain           string            `json:"domain,omitempty"`
+	OntologyBase     string            `json:"ontology_base,omitempty"`
+	ValidationRules  []ValidationRule  `json:"validation,omitempty"`
+	OntologyMappings map[string]string `json:"ontology_mappings,omitempty"`
+	FileFormats      *FileFormats      `json:"file_formats,omitempty"`
+	Facets           []FacetConfig     `json:"facets,omitempty"`
+	QualityWeights   *QualityWeights   `json:"quality_weights,omitempty"`
+}
+
+// fieldExtension holds the field properties JSON Schema has no keyword for.
+type fieldExtension struct {
+	RequiredIf string              `json:"required_if,omitempty"`
+	Vocabulary []string            `json:"vocabulary,omitempty"` // Only when not exported as enum
+	Synonyms   map[string][]string `json:"synonyms,omitempty"`
+	Units      string              `json:"units,omitempty"`
+	Range      *Range              `json:"range,omitempty"` // Only when not exported as minimum/maximum
+	Auto       string              `json:"auto,omitempty"`
+	Ontology   string              `json:"ontology,omitempty"`
+}
+
+// jsonSchemaFormats maps Cicada types to string formats.
+var jsonSchemaFormats = map[string]string{
+	"date":     "date",
+	"datetime": "date-time",
+	"duration": "duration",
+}
+
+// ExportJSONSchema converts a schema to JSON Schema. Fields inherited
+// through extends are exported only if the schema has been resolved, as
+// SchemaManager.LoadSchema does. The issues list the constraints JSON
+// Schema validators will not enforce.
+func ExportJSONSchema(schema *Schema) (*JSONSchema, []SchemaIssue) {
+	doc := &JSONSchema{
+		Schema:      JSONSchemaDialect,
+		Title:       schema.Name,
+		Description: schema.Description,
+		Type:        "object",
+	}
+
+	var issues []SchemaIssue
+	doc.Properties, doc.Required, issues = exportFields(schema.Fields, "")
+	for _, name := range schema.RequiredFields {
+		if !contains(doc.Required, name) {
+			doc.Required = append(doc.Required, name)
+		}
+	}
+	sort.Strings(doc.Required)
+
+	ext := schemaExtension{
+		Name:             schema.Name,
+		Version:          schema.Version,
+		Domain:           schema.Domain,
+		OntologyBase:     schema.OntologyBase,
+		ValidationRules:  schema.ValidationRules,
+		OntologyMappings: schema.OntologyMappings,
+		Facets:           schema.Facets,
+		QualityWeights:   schema.QualityWeights,
+	}
+	formats := schema.FileFormats
+	if len(formats.Primary)+len(formats.Processed)+len(formats.Raw)+len(formats.Metadata) > 0 {
+		ext.FileFormats = &formats
+	}
+	doc.Extension = ext
+
+	for _, rule := range schema.ValidationRules {
+		issues = append(issues, SchemaIssue{
+			Message: fmt.Sprintf("validation rule %q is not enforced by JSON Schema", rule.Rule),
+		})
+	}
+	return doc, issues
+}
+
+// exportFields converts a set of fields to JSON Schema properties and the
+// names of the required ones.
+func exportFields(fields map[string]FieldSchema, parent string) (map[string]*JSONSchema, []string, []SchemaIssue) {
+	if len(fields) == 0 {
+		return nil, nil, nil
+	}
+	properties := make(map[string]*JSONSchema, len(fields))
+	var required []string
+	var issues []SchemaIssue
+	for _, name := range sortedFieldNames(fields) {
+		field := fields[name]
+		path := name
+		if parent != "" {
+			path = parent + "." + name
+		}
+		var fieldIssues []SchemaIssue
+		properties[name], fieldIssues = exportField(field, path)
+		issues = append(issues, fieldIssues...)
+		if field.Required {
+			required = append(required, name)
+		}
+	}
+	return properties, required, issues
+}
+
+// exportField converts one field.
+func exportField(field FieldSchema, path string) (*JSONSchema, []SchemaIssue) {
+	prop := &JSONSchema{
+		Type:        field.Type,
+		Description: field.Description,
+		Pattern:     field.Pattern,
+		Default:     field.Default,
+		Examples:    field.Examples,
+		MinItems:    field.MinItems,
+		MaxItems:    field.MaxItems,
+	}
+	ext := fieldExtension{
+		RequiredIf: field.RequiredIf,
+		Synonyms:   field.Synonyms,
+		Units:      field.Units,
+		Auto:       field.Auto,
+		Ontology:   field.Ontology,
+	}
+	var issues []SchemaIssue
+
+	if format, ok := jsonSchemaFormats[field.Type]; ok {
+		prop.Type = "string"
+		prop.Format = format
+	}
+
+	if len(field.Vocabulary) > 0 {
+		if prop.Type == "string" {
+			prop.Enum = field.Vocabulary
+		} else {
+			ext.Vocabulary = field.Vocabulary
+			issues = append(issues, SchemaIssue{Path: path, Message: fmt.Sprintf("vocabulary on a %s field is not enforced by JSON Schema", field.Type)})
+		}
+	}
+
+	if field.Range != nil {
+		if field.Type == "number" || field.Type == "integer" {
+			// Bounds written with units are converted to the field's units
+			if key, ok := rangeKey(field.Range.Min, field); ok {
+				prop.Minimum = &key
+			}
+			if key, ok := rangeKey(field.Range.Max, field); ok {
+				prop.Maximum = &key
+			}
+		} else {
+			ext.Range = field.Range
+			issues = append(issues, SchemaIssue{Path: path, Message: fmt.Sprintf("range on a %s field is not enforced by JSON Schema", field.Type)})
+		}
+	}
+
+	if field.RequiredIf != "" {
+		issues = append(issues, SchemaIssue{Path: path, Message: fmt.Sprintf("required_if %q is not enforced by JSON Schema", field.RequiredIf)})
+	}
+
+	var childIssues []SchemaIssue
+	prop.Properties, prop.Required, childIssues = exportFields(field.Fields, path)
+	issues = append(issues, childIssues...)
+	if field.Items != nil {
+		prop.Items, childIssues = exportField(*field.Items, path+"[]")
+		issues = append(issues, childIssues...)
+	}
+
+	if ext.RequiredIf != "" || len(ext.Vocabulary) > 0 || len(ext.Synonyms) > 0 || ext.Units != "" ||
+		ext.Range != nil || ext.Auto != "" || ext.Ontology != "" {
+		prop.Extension = ext
+	}
+	return prop, issues
+}
+
+// ImportJSONSchema converts a JSON Schema document to a schema. The
+// document must describe an object; each property becomes a field.
+// Local references (#/$defs/... and #/definitions/...) are followed.
+// Keywords with no Cicada equivalent are ignored and reported as issues.
+// An unnamed document leaves Name empty.
+func ImportJSONSchema(data []byte) (*Schema, []SchemaIssue, error) {
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("parse JSON Schema: %w", err)
+	}
+
+	im := &jsonSchemaImporter{defs: make(map[string]json.RawMessage)}
+	for _, key := range []string{"$defs", "definitions"} {
+		var defs map[string]json.RawMessage
+		if raw, ok := root[key]; ok {
+			if err := json.Unmarshal(raw, &defs); err != nil {
+				return nil, nil, fmt.Errorf("parse JSON Schema %s: %w", key, err)
+			}
+		}
+		for name, def := range defs {
+			im.defs["#/"+key+"/"+name] = def
+		}
+	}
+
+	node, err := im.resolve("", root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("JSON Schema: %w", err)
+	}
+	if t := im.typeOf("", node); t != "object" {
+		return nil, nil, fmt.Errorf("JSON Schema describes %s values, not an object", t)
+	}
+
+	schema := &Schema{Fields: make(map[string]FieldSchema)}
+	var ext schemaExtension
+	im.decode("", node, jsonSchemaExtension, &ext)
+	im.decode("", node, "description", &schema.Description)
+	var title string
+	if im.decode("", node, "title", &title) && schema.Description == "" && title != ext.Name {
+		schema.Description = title
+	}
+	schema.Name = ext.Name
+	schema.Version = ext.Version
+	schema.Domain = ext.Domain
+	schema.OntologyBase = ext.OntologyBase
+	schema.ValidationRules = ext.ValidationRules
+	schema.OntologyMappings = ext.OntologyMappings
+	schema.Facets = ext.Facets
+	schema.QualityWeights = ext.QualityWeights
+	if ext.FileFormats != nil {
+		schema.FileFormats = *ext.FileFormats
+	}
+
+	schema.Fields = im.properties("", node)
+	im.decode("", node, "required", &schema.RequiredFields)
+	im.unsupported("", node, schemaKeywords)
+
+	sort.SliceStable(im.issues, func(i, j int) bool {
+		return im.issues[i].Path < im.issues[j].Path
+	})
+	if err := checkSchema(schema); err != nil {
+		return nil, im.issues, fmt.Errorf("converted schema is invalid: %w", err)
+	}
+	return schema, im.issues, nil
+}
+
+// jsonSchemaImporter converts JSON Schema nodes to fields and collects the
+// issues found on the way.
+type jsonSchemaImporter struct {
+	defs      map[string]json.RawMessage
+	resolving []string
+	issues    []SchemaIssue
+}
+
+// Keywords understood at the document level and on fields. Annotations
+// with no effect on validation are accepted silently.
+var (
+	schemaKeywords = keywordSet("$schema", "$id", "$defs", "definitions", "$comment", "title",
+		"description", "type", "properties", "required", "additionalProperties", jsonSchemaExtension)
+	fieldKeywords = keywordSet("$comment", "title", "description", "type", "format", "enum", "const",
+		"pattern", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "properties", "required",
+		"additionalProperties", "items", "minItems", "maxItems", "default", "examples",
+		"deprecated", "readOnly", "writeOnly", jsonSchemaExtension)
+)
+
+// keywordSet builds a set of keywords.
+func keywordSet(keywords ...string) map[string]bool {
+	set := make(map[string]bool, len(keywords))
+	for _, k := range keywords {
+		set[k] = true
+	}
+	return set
+}
+
+// importFormats maps string formats to Cicada types.
+var importFormats = map[string]string{
+	"date":      "date",
+	"date-time": "datetime",
+	"duration":  "duration",
+}
+
+// issue records a problem at path.
+func (im *jsonSchemaImporter) issue(path, format string, args ...interface{}) {
+	im.issues = append(im.issues, SchemaIssue{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// decode unmarshals a keyword's value into v, reporting values of the wrong
+// shape. It reports whether the keyword was present and valid.
+func (im *jsonSchemaImporter) decode(path string, node map[string]json.RawMessage, keyword string, v interface{}) bool {
+	raw, ok := node[keyword]
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		im.issue(path, "invalid %s ignored: %v", keyword, err)
+		return false
+	}
+	return true
+}
+
+// unsupported reports the keywords of node that are not in known.
+func (im *jsonSchemaImporter) unsupported(path string, node map[string]json.RawMessage, known map[string]bool) {
+	var keys []string
+	for key := range node {
+		if !known[key] && key != "$ref" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		im.issue(path, "keyword %s is not supported and was ignored", key)
+	}
+}
+
+// resolve follows a node's local $ref. Keywords next to $ref take
+// precedence over those of the referenced schema. The references followed
+// stay on the resolving stack, so a reference met again while the caller
+// converts the node's properties and items is reported as recursive; the
+// caller truncates the stack when it is done with the node.
+func (im *jsonSchemaImporter) resolve(path string, node map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	var ref string
+	if !im.decode(path, node, "$ref", &ref) {
+		return node, nil
+	}
+	raw, ok := im.defs[ref]
+	if !ok {
+		im.issue(path, "reference %s cannot be resolved (only #/$defs/ and #/definitions/ are)", ref)
+		return node, nil
+	}
+	for _, r := range im.resolving {
+		if r == ref {
+			return nil, fmt.Errorf("recursive reference %s cannot be converted", ref)
+		}
+	}
+
+	var target map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &target); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ref, err)
+	}
+	im.resolving = append(im.resolving, ref)
+	target, err := im.resolve(path, target)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]json.RawMessage, len(target)+len(node))
+	for key, value := range target {
+		merged[key] = value
+	}
+	for key, value := range node {
+		if key != "$ref" {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// typeOf returns a node's Cicada type. A nullable type ("type": ["string",
+// "null"]) becomes its other type, and a missing type is inferred from the
+// other keywords.
+func (im *jsonSchemaImporter) typeOf(path string, node map[string]json.RawMessage) string {
+	var types []string
+	if raw, ok := node["type"]; ok {
+		var single string
+		if err := json.Unmarshal(raw, &single); err == nil {
+			types = []string{single}
+		} else if err := json.Unmarshal(raw, &types); err != nil {
+			im.issue(path, "invalid type ignored")
+		}
+	}
+
+	var nonNull []string
+	for _, t := range types {
+		if t == "null" {
+			im.issue(path, "null values are not supported; the field is optional instead")
+			continue
+		}
+		nonNull = append(nonNull, t)
+	}
+	if len(nonNull) > 1 {
+		im.issue(path, "several types (%s) are not supported; using %s", strings.Join(nonNull, ", "), nonNull[0])
+	}
+
+	t := ""
+	if len(nonNull) > 0 {
+		t = nonNull[0]
+	}
+	switch {
+	case t != "":
+	case node["properties"] != nil:
+		t = "object"
+	case node["items"] != nil:
+		t = "array"
+	case node["enum"] != nil || node["const"] != nil || node["pattern"] != nil || node["format"] != nil:
+		t = "string"
+	case node["minimum"] != nil || node["maximum"] != nil:
+		t = "number"
+	default:
+		im.issue(path, "no type; treated as string")
+		t = "string"
+	}
+
+	if t == "string" {
+		var format string
+		if im.decode(path, node, "format", &format) {
+			if mapped, ok := importFormats[format]; ok {
+				return mapped
+			}
+			im.issue(path, "format %s is not checked", format)
+		}
+	}
+	if !fieldTypes[t] {
+		im.issue(path, "type %s is not supported; treated as string", t)
+		t = "string"
+	}
+	return t
+}
+
+// properties converts an object node's properties to fields.
+func (im *jsonSchemaImporter) properties(path string, node map[string]json.RawMessage) map[string]FieldSchema {
+	var properties map[string]map[string]json.RawMessage
+	im.decode(path, node, "properties", &properties)
+
+	var additional interface{}
+	if im.decode(path, node, "additionalProperties", &additional) && additional != true {
+		im.issue(path, "additionalProperties is not enforced; undefined fields get warnings")
+	}
+
+	if len(properties) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make(map[string]FieldSchema, len(properties))
+	for _, name := range names {
+		child := name
+		if path != "" {
+			child = path + "." + name
+		}
+		fields[name] = im.field(child, properties[name])
+	}
+	return fields
+}
+
+// field converts one property node.
+func (im *jsonSchemaImporter) field(path string, node map[string]json.RawMessage) FieldSchema {
+	depth := len(im.resolving)
+	defer func() { im.resolving = im.resolving[:depth] }()
+	node, err := im.resolve(path, node)
+	if err != nil {
+		im.issue(path, "%v; treated as an object without fields", err)
+		return FieldSchema{Type: "object"}
+	}
+
+	field := FieldSchema{Type: im.typeOf(path, node)}
+	im.decode(path, node, "description", &field.Description)
+	if field.Description == "" {
+		im.decode(path, node, "title", &field.Description)
+	}
+	if im.decode(path, node, "pattern", &field.Pattern) {
+		if _, err := regexp.Compile(field.Pattern); err != nil {
+			// ECMA-262 features such as lookahead have no RE2 equivalent
+			im.issue(path, "pattern %s cannot be checked and was ignored: %v", field.Pattern, err)
+			field.Pattern = ""
+		}
+	}
+	im.decode(path, node, "default", &field.Default)
+	im.decode(path, node, "examples", &field.Examples)
+	im.decode(path, node, "minItems", &field.MinItems)
+	im.decode(path, node, "maxItems", &field.MaxItems)
+
+	var ext fieldExtension
+	im.decode(path, node, jsonSchemaExtension, &ext)
+	field.RequiredIf = ext.RequiredIf
+	field.Vocabulary = ext.Vocabulary
+	field.Synonyms = ext.Synonyms
+	field.Units = ext.Units
+	field.Range = ext.Range
+	field.Auto = ext.Auto
+	field.Ontology = ext.Ontology
+
+	im.importEnum(path, node, &field)
+	im.importBounds(path, node, &field)
+
+	if field.Type == "object" {
+		field.Fields = im.properties(path, node)
+		var required []string
+		im.decode(path, node, "required", &required)
+		for _, name := range required {
+			child, ok := field.Fields[name]
+			if !ok {
+				im.issue(path, "required field %s is not defined and was ignored", name)
+				continue
+			}
+			child.Required = true
+			field.Fields[name] = child
+		}
+	}
+	var items map[string]json.RawMessage
+	if im.decode(path, node, "items", &items) {
+		if field.Type == "array" {
+			itemField := im.field(path+"[]", items)
+			field.Items = &itemField
+		} else {
+			im.issue(path, "items on a %s field was ignored", field.Type)
+		}
+	}
+
+	im.unsupported(path, node, fieldKeywords)
+	return field
+}
+
+// importEnum converts enum or const to a vocabulary. Only string values
+// can be vocabulary terms.
+func (im *jsonSchemaImporter) importEnum(path string, node map[string]json.RawMessage, field *FieldSchema) {
+	var values []interface{}
+	if !im.decode(path, node, "enum", &values) {
+		var value interface{}
+		if !im.decode(path, node, "const", &value) {
+			return
+		}
+		values = []interface{}{value}
+	}
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			value, _ := json.Marshal(v)
+			im.issue(path, "enum value %s is not a string and was ignored", value)
+			continue
+		}
+		if !contains(field.Vocabulary, s) {
+			field.Vocabulary = append(field.Vocabulary, s)
+		}
+	}
+	if len(field.Vocabulary) > 0 && field.Type != "string" {
+		im.issue(path, "enum on a %s field is not enforced", field.Type)
+	}
+}
+
+// importBounds converts minimum and maximum to a range. Exclusive bounds
+// become inclusive ones.
+func (im *jsonSchemaImporter) importBounds(path string, node map[string]json.RawMessage, field *FieldSchema) {
+	var min, max *float64
+	for _, bound := range []struct {
+		keyword   string
+		exclusive bool
+		dest      **float64
+	}{
+		{"minimum", false, &min},
+		{"exclusiveMinimum", true, &min},
+		{"maximum", false, &max},
+		{"exclusiveMaximum", true, &max},
+	} {
+		var v float64
+		if !im.decode(path, node, bound.keyword, &v) {
+			continue
+		}
+		if bound.exclusive {
+			im.issue(path, "%s %v is imported as an inclusive bound", bound.keyword, v)
+		}
+		*bound.dest = &v
+	}
+	if min == nil && max == nil {
+		return
+	}
+	if field.Type != "number" && field.Type != "integer" {
+		im.issue(path, "minimum and maximum on a %s field were ignored", field.Type)
+		return
+	}
+
+	if field.Range == nil {
+		field.Range = &Range{}
+	}
+	if min != nil {
+		field.Range.Min = jsonNumber(*min)
+	}
+	if max != nil {
+		field.Range.Max = jsonNumber(*max)
+	}
+}
+
+// jsonNumber returns whole numbers as int so they print without a
+// fraction.
+func jsonNumber(f float64) interface{} {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int(f)
+	}
+	return f
+}
+
+// isJSONSchema reports whether a JSON document is a JSON Schema rather
+// than a Cicada schema: it names a JSON Schema dialect, or has properties
+// and no fields.
+func isJSONSchema(data []byte) bool {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	var dialect string
+	if raw, ok := doc["$schema"]; ok && json.Unmarshal(raw, &dialect) == nil && strings.Contains(dialect, "json-schema.org") {
+		return true
+	}
+	_, hasProperties := doc["properties"]
+	_, hasFields := doc["fields"]
+	return hasProperties && !hasFields
+}
@@ -0,0 +1,326 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testJSONSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "LIMS sample",
+  "type": "object",
+  "required": ["sample_id"],
+  "additionalProperties": false,
+  "$defs": {
+    "concentration": {"type": "number", "minimum": 0, "exclusiveMaximum": 1000}
+  },
+  "properties": {
+    "sample_id": {"type": "string", "pattern": "^S[0-9]{4}$", "minLength": 5},
+    "organism": {"enum": ["Mus musculus", "Homo sapiens", null]},
+    "concentration": {"$ref": "#/$defs/concentration", "description": "ng/ul"},
+    "collected": {"type": ["string", "null"], "format": "date"},
+    "code": {"type": "string", "pattern": "^(?!X)"},
+    "tags": {"type": "array", "items": {"type": "string", "const": "qc"}, "maxItems": 4},
+    "storage": {
+      "type": "object",
+      "required": ["freezer"],
+      "properties": {
+        "freezer": {"type": "string"},
+        "temperature": {"type": "integer", "maximum": -20}
+      }
+    }
+  }
+}`
+
+func TestImportJSONSchema(t *testing.T) {
+	schema, issues, err := ImportJSONSchema([]byte(testJSONSchema))
+	if err != nil {
+		t.Fatalf("ImportJSONSchema() error = %v", err)
+	}
+
+	if schema.Name != "" || schema.Description != "LIMS sample" {
+		t.Errorf("Name, Description = %q, %q, want unnamed with the title as description", schema.Name, schema.Description)
+	}
+	if !reflect.DeepEqual(schema.RequiredFields, []string{"sample_id"}) {
+		t.Errorf("RequiredFields = %v, want [sample_id]", schema.RequiredFields)
+	}
+
+	fields := schema.Fields
+	if f := fields["sample_id"]; f.Type != "string" || f.Pattern != "^S[0-9]{4}$" {
+		t.Errorf("sample_id = %+v", f)
+	}
+	if f := fields["organism"]; f.Type != "string" || !reflect.DeepEqual(f.Vocabulary, []string{"Mus musculus", "Homo sapiens"}) {
+		t.Errorf("organism = %+v, want the string enum values as vocabulary", f)
+	}
+	if f := fields["concentration"]; f.Type != "number" || f.Description != "ng/ul" || f.Range == nil || f.Range.Min != 0 || f.Range.Max != 1000 {
+		t.Errorf("concentration = %+v, want the referenced number with range 0..1000", f)
+	}
+	if f := fields["collected"]; f.Type != "date" {
+		t.Errorf("collected type = %s, want date", f.Type)
+	}
+	if f := fields["code"]; f.Pattern != "" {
+		t.Errorf("code pattern = %q, want the lookahead dropped", f.Pattern)
+	}
+	tags := fields["tags"]
+	if tags.Type != "array" || tags.MaxItems != 4 || tags.Items == nil || !reflect.DeepEqual(tags.Items.Vocabulary, []string{"qc"}) {
+		t.Errorf("tags = %+v, want string items limited to qc", tags)
+	}
+	storage := fields["storage"]
+	if !storage.Fields["freezer"].Required || storage.Fields["temperature"].Required {
+		t.Errorf("storage fields = %+v, want freezer required", storage.Fields)
+	}
+	if r := storage.Fields["temperature"].Range; r == nil || r.Min != nil || r.Max != -20 {
+		t.Errorf("storage.temperature range = %+v, want max -20", r)
+	}
+
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+	for _, want := range []string{
+		"additionalProperties is not enforced",
+		"code: pattern ^(?!X) cannot be checked",
+		"collected: null values are not supported",
+		"concentration: exclusiveMaximum 1000 is imported as an inclusive bound",
+		"organism: enum value null is not a string",
+		"sample_id: keyword minLength is not supported",
+	} {
+		found := false
+		for _, message := range messages {
+			found = found || strings.Contains(message, want)
+		}
+		if !found {
+			t.Errorf("issues %q lack %q", messages, want)
+		}
+	}
+	if len(messages) != 6 {
+		t.Errorf("got %d issues, want 6: %q", len(messages), messages)
+	}
+}
+
+func TestImportJSONSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"not JSON", `{`, "parse JSON Schema"},
+		{"not an object", `{"type": "array", "items": {"type": "string"}}`, "describes array values"},
+		{"recursive reference", `{"$ref": "#/$defs/node", "$defs": {"node": {"$ref": "#/$defs/node"}}}`, "recursive reference"},
+		{"inverted range", `{"properties": {"n": {"type": "number", "minimum": 5, "maximum": 1}}}`, "exceeds max"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ImportJSONSchema([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ImportJSONSchema() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImportJSONSchema_RecursiveDefs(t *testing.T) {
+	doc := `{
+	  "type": "object",
+	  "properties": {
+	    "tree": {"$ref": "#/$defs/node"},
+	    "label": {"$ref": "#/$defs/name"},
+	    "alias": {"$ref": "#/$defs/name"}
+	  },
+	  "$defs": {
+	    "name": {"type": "string"},
+	    "node": {
+	      "type": "object",
+	      "properties": {
+	        "value": {"type": "number"},
+	        "child": {"$ref": "#/$defs/node"},
+	        "children": {"type": "array", "items": {"$ref": "#/$defs/node"}}
+	      }
+	    }
+	  }
+	}`
+	schema, issues, err := ImportJSONSchema([]byte(doc))
+	if err != nil {
+		t.Fatalf("ImportJSONSchema() error = %v", err)
+	}
+
+	tree := schema.Fields["tree"]
+	if tree.Fields["value"].Type != "number" || tree.Fields["child"].Type != "object" || len(tree.Fields["child"].Fields) != 0 {
+		t.Errorf("tree = %+v, want value and a child without fields", tree)
+	}
+	var recursive int
+	for _, issue := range issues {
+		if strings.Contains(issue.Message, "recursive reference #/$defs/node") {
+			recursive++
+		}
+	}
+	if recursive != 2 {
+		t.Errorf("issues = %v, want the child and children references reported", issues)
+	}
+	// A definition used by sibling fields is not recursive
+	if schema.Fields["label"].Type != "string" || schema.Fields["alias"].Type != "string" {
+		t.Errorf("label, alias = %+v, %+v, want strings", schema.Fields["label"], schema.Fields["alias"])
+	}
+}
+
+func TestExportJSONSchema(t *testing.T) {
+	schema := &Schema{
+		Version:        "2.0",
+		Name:           "lab",
+		Description:    "Lab schema",
+		RequiredFields: []string{"sample_id"},
+		Fields: map[string]FieldSchema{
+			"sample_id": {Type: "string", Pattern: "^S[0-9]+$"},
+			"modality": {Type: "string", Vocabulary: []string{"confocal", "widefield"},
+				Synonyms: map[string][]string{"confocal": {"LSM"}}},
+			"exposure": {Type: "number", Units: "ms", Range: &Range{Min: "1 ms", Max: 2}},
+			"pinhole":  {Type: "number", RequiredIf: "modality == 'confocal'"},
+			"acquired": {Type: "datetime", Range: &Range{Min: "2000-01-01"}},
+			"channels": {Type: "array", MinItems: 1, Items: &FieldSchema{
+				Type:   "object",
+				Fields: map[string]FieldSchema{"name": {Type: "string", Required: true}},
+			}},
+		},
+		ValidationRules: []ValidationRule{{Rule: "exposure > 0", Message: "positive"}},
+	}
+
+	doc, issues := ExportJSONSchema(schema)
+	if doc.Schema != JSONSchemaDialect || doc.Type != "object" || doc.Title != "lab" {
+		t.Errorf("document header = %s %s %s", doc.Schema, doc.Type, doc.Title)
+	}
+	if !reflect.DeepEqual(doc.Required, []string{"sample_id"}) {
+		t.Errorf("Required = %v, want [sample_id]", doc.Required)
+	}
+	if p := doc.Properties["modality"]; !reflect.DeepEqual(p.Enum, []string{"confocal", "widefield"}) {
+		t.Errorf("modality enum = %v", p.Enum)
+	}
+	if p := doc.Properties["exposure"]; p.Minimum == nil || *p.Minimum != 1 || p.Maximum == nil || *p.Maximum != 2 {
+		t.Errorf("exposure bounds = %v..%v, want 1..2", p.Minimum, p.Maximum)
+	}
+	if p := doc.Properties["acquired"]; p.Type != "string" || p.Format != "date-time" || p.Minimum != nil {
+		t.Errorf("acquired = %+v, want a date-time string without minimum", p)
+	}
+	items := doc.Properties["channels"].Items
+	if items == nil || items.Type != "object" || !reflect.DeepEqual(items.Required, []string{"name"}) {
+		t.Errorf("channels items = %+v, want objects requiring name", items)
+	}
+
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+	want := []string{
+		`acquired: range on a datetime field is not enforced by JSON Schema`,
+		`pinhole: required_if "modality == 'confocal'" is not enforced by JSON Schema`,
+		`validation rule "exposure > 0" is not enforced by JSON Schema`,
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("issues = %q, want %q", messages, want)
+	}
+
+	// The Cicada-only properties survive a round trip
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, _, err := ImportJSONSchema(data)
+	if err != nil {
+		t.Fatalf("ImportJSONSchema(exported) error = %v", err)
+	}
+	if imported.Name != "lab" || imported.Version != "2.0" || imported.Description != "Lab schema" {
+		t.Errorf("imported header = %s %s %q", imported.Name, imported.Version, imported.Description)
+	}
+	if !reflect.DeepEqual(imported.ValidationRules, schema.ValidationRules) {
+		t.Errorf("ValidationRules = %v", imported.ValidationRules)
+	}
+	if f := imported.Fields["modality"]; !reflect.DeepEqual(f.Synonyms, schema.Fields["modality"].Synonyms) {
+		t.Errorf("modality synonyms = %v", f.Synonyms)
+	}
+	if f := imported.Fields["exposure"]; f.Units != "ms" || f.Range.Min != 1 || f.Range.Max != 2 {
+		t.Errorf("exposure = %+v", f)
+	}
+	if f := imported.Fields["pinhole"]; f.RequiredIf != "modality == 'confocal'" {
+		t.Errorf("pinhole required_if = %q", f.RequiredIf)
+	}
+	if f := imported.Fields["acquired"]; f.Type != "datetime" || f.Range == nil || f.Range.Min != "2000-01-01" {
+		t.Errorf("acquired = %+v", f)
+	}
+	if f := imported.Fields["channels"]; f.MinItems != 1 || !f.Items.Fields["name"].Required {
+		t.Errorf("channels = %+v", f)
+	}
+}
+
+func TestExportJSONSchema_BuiltinRoundTrip(t *testing.T) {
+	sm := NewSchemaManager(NewFileSchemaLoader())
+	for _, name := range []string{"sequencing", "fluorescence-microscopy", "mass-spectrometry", "flow-cytometry", "cryo-em"} {
+		schema, err := sm.LoadSchema(name)
+		if err != nil {
+			t.Fatalf("LoadSchema(%s) error = %v", name, err)
+		}
+		doc, _ := ExportJSONSchema(schema)
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported, issues, err := ImportJSONSchema(data)
+		if err != nil {
+			t.Fatalf("%s: ImportJSONSchema(exported) error = %v", name, err)
+		}
+		if len(issues) > 0 {
+			t.Errorf("%s: round trip issues = %v, want none", name, issues)
+		}
+		if len(imported.Fields) != len(schema.Fields) {
+			t.Errorf("%s: %d fields after round trip, want %d", name, len(imported.Fields), len(schema.Fields))
+		}
+		for field, original := range schema.Fields {
+			got := imported.Fields[field]
+			if got.Type != original.Type || got.Units != original.Units || got.Ontology != original.Ontology ||
+				!reflect.DeepEqual(got.Vocabulary, original.Vocabulary) || got.RequiredIf != original.RequiredIf {
+				t.Errorf("%s: field %s = %+v after round trip, want %+v", name, field, got, original)
+			}
+		}
+	}
+}
+
+func TestFileSchemaLoader_JSONSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeSchemaFile(t, dir, "lims-sample.json", testJSONSchema)
+
+	loader := NewFileSchemaLoader(dir)
+	schema, err := loader.Load("lims-sample")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if schema.Name != "lims-sample" || len(schema.Fields) != 7 {
+		t.Errorf("schema = %s with %d fields, want lims-sample with 7", schema.Name, len(schema.Fields))
+	}
+	if len(schema.ConversionIssues) != 6 {
+		t.Errorf("ConversionIssues = %v, want 6", schema.ConversionIssues)
+	}
+
+	// A Cicada schema in JSON is not mistaken for JSON Schema
+	writeSchemaFile(t, dir, "plain.json", `{"name": "plain", "fields": {"x": {"type": "string"}}}`)
+	plain, err := loader.Load("plain")
+	if err != nil {
+		t.Fatalf("Load(plain) error = %v", err)
+	}
+	if len(plain.ConversionIssues) != 0 || plain.Fields["x"].Type != "string" {
+		t.Errorf("plain = %+v", plain)
+	}
+}
@@ -0,0 +1,544 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # Leica LIF Format
+//
+// This file implements metadata extraction for Leica Image File (LIF) files,
+// the project container written by Leica LAS X / LAS AF for SP5, SP8,
+// Stellaris, THUNDER and other Leica systems.
+//
+// ## File Format Overview
+//
+// A LIF file is a sequence of blocks, each starting with the test value 0x70:
+//   - Header block: 0x70 (uint32), block length (uint32), 0x2A (byte),
+//     XML character count (uint32), then the XML header as UTF-16LE
+//   - Memory blocks: one per image, holding raw pixel data
+//
+// The XML header (LMSDataContainerHeader) describes the whole project as a
+// tree of Element nodes. Folders and series are both Elements; a series is an
+// Element whose Data/Image node carries dimension and channel descriptions:
+//   - DimensionDescription: DimID (1=X, 2=Y, 3=Z, 4=T, 5=λ, 10=tiles),
+//     NumberOfElements, Length and Unit
+//   - ChannelDescription: bit resolution and LUT name
+//   - ATLConfocalSettingDefinition / ATLCameraSettingDefinition attachments:
+//     objective, NA, magnification, pinhole, zoom and laser lines
+//   - TimeStampList: Windows FILETIME acquisition timestamps
+//
+// ## Per-Series Output
+//
+// One LIF usually holds many experiments, so each series is reported as its
+// own map under "series". When the file holds exactly one series its fields
+// are also promoted to the top level so single-image files look like every
+// other microscopy extractor's output.
+//
+// ## References and Sources
+//
+// Bio-Formats LIFReader:
+// https://bio-formats.readthedocs.io/en/latest/formats/leica-lif.html
+//
+// readlif - Python LIF reader:
+// https://github.com/Arcadia-Science/readlif
+//
+// ## Limitations
+//
+//   - Reads the XML header only; memory blocks are never read
+//   - Dye names are matched to channels by order when counts agree
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LIF file format constants
+const (
+	lifTestValue    = 0x70
+	lifMemoryMarker = 0x2A
+	lifMaxXMLChars  = 128 << 20
+	// lifFileTimeEpoch is the number of 100ns intervals between 1601-01-01 and 1970-01-01
+	lifFileTimeEpoch = 116444736000000000
+)
+
+// lifDimensionNames maps LIF DimID values to dimension names.
+var lifDimensionNames = map[int]string{
+	1:  "X",
+	2:  "Y",
+	3:  "Z",
+	4:  "T",
+	5:  "Lambda",
+	6:  "Rotation",
+	7:  "XT",
+	8:  "TSlice",
+	10: "Tiles",
+}
+
+// LeicaLIFExtractor extracts metadata from Leica LIF microscopy files.
+type LeicaLIFExtractor struct{}
+
+// lifSeries accumulates the settings of one Element while walking the XML.
+type lifSeries struct {
+	name       string
+	path       string
+	hasImage   bool
+	dimensions []lifDimension
+	channels   []lifChannel
+	settings   map[string]string
+	laserLines map[float64]bool
+	dyeNames   []string
+	timestamps []string
+	modality   string
+}
+
+type lifDimension struct {
+	id       int
+	elements int
+	length   float64
+	unit     string
+}
+
+type lifChannel struct {
+	resolution int
+	lutName    string
+}
+
+// Name returns the extractor name.
+func (e *LeicaLIFExtractor) Name() string {
+	return "Leica LIF"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *LeicaLIFExtractor) SupportedFormats() []string {
+	return []string{".lif"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *LeicaLIFExtractor) CanHandle(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".lif")
+}
+
+// Sniff recognises the LIF test value and memory block marker.
+func (e *LeicaLIFExtractor) Sniff(in *SniffInput) (int, string) {
+	if len(in.Header) >= 9 && binary.LittleEndian.Uint32(in.Header[0:4]) == lifTestValue && in.Header[8] == lifMemoryMarker {
+		return 100, "LIF header block"
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from a LIF file.
+func (e *LeicaLIFExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	metadata, err := e.extractFromReader(f, filepath, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	metadata["file_size"] = info.Size()
+	return metadata, nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *LeicaLIFExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	return e.extractFromReader(r, filename, 0)
+}
+
+// extractFromReader reads the LIF XML header and builds per-series metadata.
+// size is the total file size, or zero when unknown.
+func (e *LeicaLIFExtractor) extractFromReader(r io.Reader, filename string, size int64) (map[string]interface{}, error) {
+	xmlData, err := readLIFHeader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	series, version, project, err := parseLIFXML(xmlData)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "LIF",
+		"manufacturer":    "Leica",
+		"instrument_type": "microscopy",
+		"file_name":       filename,
+		"extractor_name":  "leica_lif",
+		"schema_name":     "leica_lif_v1",
+		"series_count":    len(series),
+	}
+	if version != "" {
+		metadata["lif_version"] = version
+	}
+	if project != "" {
+		metadata["project_name"] = project
+	}
+
+	seriesMaps := make([]map[string]interface{}, 0, len(series))
+	for _, s := range series {
+		seriesMaps = append(seriesMaps, s.fields())
+	}
+	metadata["series"] = seriesMaps
+
+	switch len(seriesMaps) {
+	case 0:
+		metadata["extraction_note"] = "No image series found in LIF header"
+	case 1:
+		for key, value := range seriesMaps[0] {
+			metadata[key] = value
+		}
+	default:
+		// Promote instrument fields only when every series agrees on them
+		for _, key := range []string{"instrument_model", "system_type", "serial_number"} {
+			if value, ok := commonSeriesValue(seriesMaps, key); ok {
+				metadata[key] = value
+			}
+		}
+	}
+
+	return metadata, nil
+}
+
+// readLIFHeader reads and decodes the UTF-16 XML header block. A length
+// beyond the file size (when known) is rejected before anything is
+// allocated, and otherwise the buffer only grows as data arrives.
+func readLIFHeader(r io.Reader, size int64) ([]byte, error) {
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("not a valid LIF file: %w", err)
+	}
+	if binary.LittleEndian.Uint32(header[0:4]) != lifTestValue || header[8] != lifMemoryMarker {
+		return nil, fmt.Errorf("not a valid LIF file: invalid magic bytes")
+	}
+
+	chars := binary.LittleEndian.Uint32(header[9:13])
+	length := int64(chars) * 2
+	if chars == 0 || chars > lifMaxXMLChars || (size > 0 && length > size-int64(len(header))) {
+		return nil, fmt.Errorf("not a valid LIF file: invalid XML header length %d", chars)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r, length))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LIF XML header: %w", err)
+	}
+	if int64(len(raw)) < length {
+		return nil, fmt.Errorf("failed to read LIF XML header: %w", io.ErrUnexpectedEOF)
+	}
+
+	return []byte(decodeUTF16(raw)), nil
+}
+
+// parseLIFXML walks the LIF XML header and returns every image series along
+// with the container version and project name.
+func parseLIFXML(data []byte) ([]*lifSeries, string, string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	var (
+		version string
+		project string
+		stack   []*lifSeries
+		series  []*lifSeries
+		inTimes bool
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to parse LIF XML header: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := make(map[string]string, len(t.Attr))
+			for _, a := range t.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+
+			if t.Name.Local == "LMSDataContainerHeader" {
+				version = attrs["Version"]
+				continue
+			}
+			if t.Name.Local == "Element" {
+				s := &lifSeries{
+					name:       attrs["Name"],
+					settings:   map[string]string{},
+					laserLines: map[float64]bool{},
+				}
+				if len(stack) == 0 {
+					project = s.name
+				} else {
+					parent := stack[len(stack)-1]
+					s.path = strings.TrimPrefix(parent.path+"/"+s.name, "/")
+				}
+				stack = append(stack, s)
+				continue
+			}
+			if len(stack) == 0 {
+				continue
+			}
+
+			current := stack[len(stack)-1]
+			switch t.Name.Local {
+			case "Image":
+				current.hasImage = true
+			case "DimensionDescription":
+				id, _ := strconv.Atoi(attrs["DimID"])
+				n, _ := strconv.Atoi(attrs["NumberOfElements"])
+				length, _ := strconv.ParseFloat(attrs["Length"], 64)
+				current.dimensions = append(current.dimensions, lifDimension{
+					id: id, elements: n, length: length, unit: attrs["Unit"],
+				})
+			case "ChannelDescription":
+				res, _ := strconv.Atoi(attrs["Resolution"])
+				current.channels = append(current.channels, lifChannel{resolution: res, lutName: attrs["LUTName"]})
+			case "ATLConfocalSettingDefinition", "ATLCameraSettingDefinition":
+				if current.modality == "" {
+					if t.Name.Local == "ATLConfocalSettingDefinition" {
+						current.modality = "confocal"
+					} else {
+						current.modality = "widefield"
+					}
+				}
+				for _, key := range []string{
+					"ObjectiveName", "NumericalAperture", "Magnification", "Immersion",
+					"Pinhole", "Zoom", "SystemTypeName", "MicroscopeModel", "SystemSerialNumber",
+				} {
+					if v := strings.TrimSpace(attrs[key]); v != "" && current.settings[key] == "" {
+						current.settings[key] = v
+					}
+				}
+			case "LaserLineSetting":
+				line, _ := strconv.ParseFloat(attrs["LaserLine"], 64)
+				intensity, _ := strconv.ParseFloat(attrs["IntensityDev"], 64)
+				if line > 0 && intensity > 0 {
+					current.laserLines[line] = true
+				}
+			case "MultiBand":
+				if dye := strings.TrimSpace(attrs["DyeName"]); dye != "" {
+					current.dyeNames = append(current.dyeNames, dye)
+				}
+			case "TimeStampList":
+				inTimes = true
+			}
+
+		case xml.CharData:
+			if inTimes && len(stack) > 0 {
+				current := stack[len(stack)-1]
+				current.timestamps = append(current.timestamps, strings.Fields(string(t))...)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "TimeStampList":
+				inTimes = false
+			case "Element":
+				if len(stack) == 0 {
+					continue
+				}
+				s := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if s.hasImage && len(s.dimensions) > 0 {
+					series = append(series, s)
+				}
+			}
+		}
+	}
+
+	return series, version, project, nil
+}
+
+// fields converts a series into its metadata map.
+func (s *lifSeries) fields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"series_name": s.name,
+	}
+	if s.path != "" && s.path != s.name {
+		fields["series_path"] = s.path
+	}
+
+	dims := make([]map[string]interface{}, 0, len(s.dimensions))
+	for _, d := range s.dimensions {
+		name := lifDimensionNames[d.id]
+		if name == "" {
+			name = fmt.Sprintf("Dim%d", d.id)
+		}
+		dims = append(dims, map[string]interface{}{"name": name, "size": d.elements})
+
+		switch d.id {
+		case 1:
+			fields["image_width"] = d.elements
+		case 2:
+			fields["image_height"] = d.elements
+		case 3:
+			fields["image_depth"] = d.elements
+		case 4:
+			fields["num_timepoints"] = d.elements
+		case 10:
+			fields["num_tiles"] = d.elements
+		}
+
+		if d.elements < 2 || d.length == 0 {
+			continue
+		}
+		step := d.length / float64(d.elements-1)
+		switch d.id {
+		case 1, 2, 3:
+			if um, ok := omeLengthIn(step, lifUnit(d.unit), "µm"); ok {
+				axis := map[int]string{1: "x", 2: "y", 3: "z"}[d.id]
+				if um < 0 {
+					um = -um
+				}
+				fields["pixel_size_"+axis+"_um"] = um
+			}
+		case 4:
+			if secs, ok := omeTimeInSeconds(step, d.unit); ok {
+				fields["time_increment_s"] = secs
+			}
+		}
+	}
+	fields["dimensions"] = dims
+
+	if len(s.channels) > 0 {
+		fields["num_channels"] = len(s.channels)
+		fields["bit_depth"] = s.channels[0].resolution
+
+		channels := make([]map[string]interface{}, 0, len(s.channels))
+		for i, ch := range s.channels {
+			channel := map[string]interface{}{
+				"id":    fmt.Sprintf("Channel:%d", i),
+				"index": i,
+			}
+			if ch.lutName != "" {
+				channel["lut"] = ch.lutName
+				channel["color"] = ch.lutName
+			}
+			if len(s.dyeNames) == len(s.channels) {
+				channel["name"] = s.dyeNames[i]
+				channel["dye_name"] = s.dyeNames[i]
+			} else if ch.lutName != "" {
+				channel["name"] = ch.lutName
+			}
+			channels = append(channels, channel)
+		}
+		fields["channels"] = channels
+	}
+	if len(s.dyeNames) > 0 {
+		fields["dye_names"] = s.dyeNames
+	}
+
+	if s.modality != "" {
+		fields["modality"] = s.modality
+	}
+	if v := s.settings["ObjectiveName"]; v != "" {
+		fields["objective_name"] = v
+	}
+	if v, err := strconv.ParseFloat(s.settings["NumericalAperture"], 64); err == nil && v > 0 {
+		fields["objective_na"] = v
+	}
+	if v, err := strconv.ParseFloat(s.settings["Magnification"], 64); err == nil && v > 0 {
+		fields["objective_magnification"] = v
+	}
+	if v := s.settings["Immersion"]; v != "" {
+		fields["objective_immersion"] = v
+	}
+	if v, err := strconv.ParseFloat(s.settings["Pinhole"], 64); err == nil && v > 0 {
+		// Pinhole diameter is stored in meters
+		fields["pinhole_size_um"] = v * 1e6
+	}
+	if v, err := strconv.ParseFloat(s.settings["Zoom"], 64); err == nil && v > 0 {
+		fields["zoom_factor"] = v
+	}
+	if v := s.settings["MicroscopeModel"]; v != "" {
+		fields["microscope_name"] = v
+	}
+	if v := s.settings["SystemTypeName"]; v != "" {
+		fields["system_type"] = v
+		fields["instrument_model"] = v
+	} else if v := s.settings["MicroscopeModel"]; v != "" {
+		fields["instrument_model"] = v
+	}
+	if v := s.settings["SystemSerialNumber"]; v != "" {
+		fields["serial_number"] = v
+	}
+
+	if len(s.laserLines) > 0 {
+		lines := make([]float64, 0, len(s.laserLines))
+		for line := range s.laserLines {
+			lines = append(lines, line)
+		}
+		sort.Float64s(lines)
+		fields["laser_lines_nm"] = lines
+	}
+
+	if len(s.timestamps) > 0 {
+		if t, ok := lifFileTime(s.timestamps[0]); ok {
+			fields["acquisition_date"] = t.Format(time.RFC3339)
+		}
+	}
+
+	return fields
+}
+
+// lifUnit normalizes LIF length units to OME unit symbols.
+func lifUnit(unit string) string {
+	switch strings.TrimSpace(unit) {
+	case "", "m":
+		return "m"
+	case "um", "µm", "μm":
+		return "µm"
+	default:
+		return unit
+	}
+}
+
+// lifFileTime parses a hexadecimal Windows FILETIME timestamp.
+func lifFileTime(hex string) (time.Time, bool) {
+	v, err := strconv.ParseUint(hex, 16, 64)
+	if err != nil || v < lifFileTimeEpoch {
+		return time.Time{}, false
+	}
+	ticks := v - lifFileTimeEpoch
+	return time.Unix(int64(ticks/1e7), int64(ticks%1e7)*100).UTC(), true
+}
+
+// commonSeriesValue returns the value of key when every series has the same one.
+func commonSeriesValue(series []map[string]interface{}, key string) (interface{}, bool) {
+	var common interface{}
+	for _, s := range series {
+		v, ok := s[key]
+		if !ok || (common != nil && v != common) {
+			return nil, false
+		}
+		common = v
+	}
+	return common, common != nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const testLIFSeries = `<Element Name="%s">
+  <Data>
+    <Image TextDescription="">
+      <ImageDescription>
+        <Channels>
+          <ChannelDescription DataType="0" Resolution="12" LUTName="Blue"/>
+          <ChannelDescription DataType="0" Resolution="12" LUTName="Green"/>
+        </Channels>
+        <Dimensions>
+          <DimensionDescription DimID="1" NumberOfElements="1024" Length="1.023e-04" Unit="m"/>
+          <DimensionDescription DimID="2" NumberOfElements="1024" Length="1.023e-04" Unit="m"/>
+          <DimensionDescription DimID="3" NumberOfElements="11" Length="-5e-06" Unit="m"/>
+        </Dimensions>
+      </ImageDescription>
+      <Attachment Name="HardwareSetting">
+        <ATLConfocalSettingDefinition ObjectiveName="HC PL APO CS2 63x/1.40 OIL" NumericalAperture="1.4"
+            Magnification="63" Immersion="OIL" Pinhole="9.55e-05" Zoom="2.5"
+            SystemTypeName="TCS SP8" MicroscopeModel="DMI8" SystemSerialNumber="8100001234">
+          <AotfList>
+            <Aotf>
+              <LaserLineSetting LaserLine="405" IntensityDev="3.5"/>
+              <LaserLineSetting LaserLine="488" IntensityDev="10"/>
+              <LaserLineSetting LaserLine="561" IntensityDev="0"/>
+            </Aotf>
+          </AotfList>
+          <Spectro>
+            <MultiBand Channel="1" DyeName="DAPI" LeftWorld="415" RightWorld="480"/>
+            <MultiBand Channel="2" DyeName="Alexa 488" LeftWorld="500" RightWorld="550"/>
+          </Spectro>
+        </ATLConfocalSettingDefinition>
+      </Attachment>
+      <TimeStampList NumberOfTimeStamps="1">01DB3E2A8C4F5000</TimeStampList>
+    </Image>
+  </Data>
+  <Memory Size="23068672" MemoryBlockID="MemBlock_1"/>
+  <Children/>
+</Element>`
+
+// buildTestLIF wraps an XML header in the LIF binary container.
+func buildTestLIF(t *testing.T, xmlHeader string) []byte {
+	t.Helper()
+	units := utf16.Encode([]rune(xmlHeader))
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(lifTestValue))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(units)*2+5))
+	buf.WriteByte(lifMemoryMarker)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(units)))
+	_ = binary.Write(&buf, binary.LittleEndian, units)
+	return buf.Bytes()
+}
+
+func testLIFHeader(series ...string) string {
+	children := ""
+	for _, s := range series {
+		children += sprintfLIF(testLIFSeries, s)
+	}
+	return `<LMSDataContainerHeader Version="2">
+<Element Name="project.lif">
+  <Data><Experiment Path="C:\data\project.lif"/></Data>
+  <Memory Size="0" MemoryBlockID="MemBlock_0"/>
+  <Children>
+    <Element Name="Folder">
+      <Data/>
+      <Memory Size="0"/>
+      <Children>` + children + `</Children>
+    </Element>
+  </Children>
+</Element>
+</LMSDataContainerHeader>`
+}
+
+func sprintfLIF(format, name string) string {
+	return string(bytes.Replace([]byte(format), []byte("%s"), []byte(name), 1))
+}
+
+func TestLeicaLIFExtractor_CanHandle(t *testing.T) {
+	extractor := &LeicaExtractor{}
+	if !extractor.CanHandle("project.lif") || !extractor.CanHandle("PROJECT.LIF") {
+		t.Error("CanHandle() should accept .lif files")
+	}
+	if extractor.CanHandle("project.lof") {
+		t.Error("CanHandle() should reject non-LIF files")
+	}
+}
+
+func TestLeicaLIFExtractor_SingleSeries(t *testing.T) {
+	extractor := &LeicaLIFExtractor{}
+	data := buildTestLIF(t, testLIFHeader("Series001"))
+
+	metadata, err := extractor.ExtractFromReader(bytes.NewReader(data), "project.lif")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	checks := map[string]interface{}{
+		"format":                  "LIF",
+		"manufacturer":            "Leica",
+		"lif_version":             "2",
+		"project_name":            "project.lif",
+		"series_count":            1,
+		"series_name":             "Series001",
+		"series_path":             "Folder/Series001",
+		"image_width":             1024,
+		"image_height":            1024,
+		"image_depth":             11,
+		"num_channels":            2,
+		"bit_depth":               12,
+		"objective_na":            1.4,
+		"objective_magnification": 63.0,
+		"objective_immersion":     "OIL",
+		"instrument_model":        "TCS SP8",
+		"microscope_name":         "DMI8",
+		"serial_number":           "8100001234",
+		"modality":                "confocal",
+		"zoom_factor":             2.5,
+	}
+	for key, want := range checks {
+		if metadata[key] != want {
+			t.Errorf("%s = %v (%T), want %v", key, metadata[key], metadata[key], want)
+		}
+	}
+
+	if got := metadata["pixel_size_x_um"].(float64); math.Abs(got-0.1) > 1e-9 {
+		t.Errorf("pixel_size_x_um = %v, want 0.1", got)
+	}
+	if got := metadata["pixel_size_z_um"].(float64); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("pixel_size_z_um = %v, want 0.5", got)
+	}
+	if got := metadata["pinhole_size_um"].(float64); math.Abs(got-95.5) > 1e-9 {
+		t.Errorf("pinhole_size_um = %v, want 95.5", got)
+	}
+
+	lines := metadata["laser_lines_nm"].([]float64)
+	if len(lines) != 2 || lines[0] != 405 || lines[1] != 488 {
+		t.Errorf("laser_lines_nm = %v, want [405 488]", lines)
+	}
+
+	channels := metadata["channels"].([]map[string]interface{})
+	if channels[1]["dye_name"] != "Alexa 488" || channels[1]["lut"] != "Green" {
+		t.Errorf("channel 1 = %v", channels[1])
+	}
+
+	if date, ok := metadata["acquisition_date"].(string); !ok || date[:4] != "2024" {
+		t.Errorf("acquisition_date = %v", metadata["acquisition_date"])
+	}
+}
+
+func TestLeicaLIFExtractor_MultipleSeries(t *testing.T) {
+	extractor := &LeicaLIFExtractor{}
+	data := buildTestLIF(t, testLIFHeader("Position 1", "Position 2", "Position 3"))
+
+	metadata, err := extractor.ExtractFromReader(bytes.NewReader(data), "plate.lif")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	if metadata["series_count"] != 3 {
+		t.Fatalf("series_count = %v, want 3", metadata["series_count"])
+	}
+	series := metadata["series"].([]map[string]interface{})
+	for i, want := range []string{"Position 1", "Position 2", "Position 3"} {
+		if series[i]["series_name"] != want {
+			t.Errorf("series[%d] = %v, want %v", i, series[i]["series_name"], want)
+		}
+	}
+
+	// Per-series fields stay in the series list; shared instrument fields are promoted
+	if _, ok := metadata["image_width"]; ok {
+		t.Error("image_width should not be flattened for multi-series files")
+	}
+	if metadata["instrument_model"] != "TCS SP8" {
+		t.Errorf("instrument_model = %v, want TCS SP8", metadata["instrument_model"])
+	}
+}
+
+func TestLeicaLIFExtractor_Invalid(t *testing.T) {
+	extractor := &LeicaLIFExtractor{}
+
+	if _, err := extractor.ExtractFromReader(bytes.NewReader([]byte("not a lif file")), "bad.lif"); err == nil {
+		t.Error("ExtractFromReader() should return error for invalid magic")
+	}
+
+	data := buildTestLIF(t, testLIFHeader("Series001"))
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(data[:100]), "bad.lif"); err == nil {
+		t.Error("ExtractFromReader() should return error for truncated header")
+	}
+
+	// An XML length far beyond the file is rejected before it is read
+	oversized := append([]byte(nil), data[:13]...)
+	binary.LittleEndian.PutUint32(oversized[9:13], lifMaxXMLChars)
+	path := filepath.Join(t.TempDir(), "oversized.lif")
+	if err := os.WriteFile(path, oversized, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractor.Extract(path); err == nil || !strings.Contains(err.Error(), "invalid XML header length") {
+		t.Errorf("Extract() error = %v, want invalid XML header length", err)
+	}
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(oversized), "oversized.lif"); err == nil {
+		t.Error("ExtractFromReader() should return error for an oversized XML length")
+	}
+}
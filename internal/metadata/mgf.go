@@ -0,0 +1,222 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # MGF Format
+//
+// This file implements metadata extraction for Mascot Generic Format (MGF)
+// peak lists, the plain-text MS/MS format accepted by most search engines.
+//
+// Global KEY=value parameters may precede the first spectrum. Each spectrum
+// is a BEGIN IONS / END IONS block with its own parameters followed by
+// "m/z intensity [charge]" peak lines:
+//
+//	BEGIN IONS
+//	TITLE=run.1234.1234.2 File:"run.raw", NativeID:"scan=1234"
+//	PEPMASS=500.2504 12345.6
+//	CHARGE=2+
+//	RTINSECONDS=1234.5
+//	SCANS=1234
+//	110.0712 5032.1
+//	END IONS
+//
+// ## References and Sources
+//
+// Matrix Science MGF reference:
+// https://www.matrixscience.com/help/data_file_help.html
+package metadata
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mgfTitleFilePattern = regexp.MustCompile(`File:"([^"]+)"`)
+	mgfTitleScanPattern = regexp.MustCompile(`scan=(\d+)`)
+)
+
+// MGFExtractor extracts metadata from MGF peak list files.
+type MGFExtractor struct{}
+
+// Name returns the extractor name.
+func (e *MGFExtractor) Name() string {
+	return "MGF"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *MGFExtractor) SupportedFormats() []string {
+	return []string{".mgf"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *MGFExtractor) CanHandle(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".mgf"
+}
+
+// Sniff recognises a BEGIN IONS block.
+func (e *MGFExtractor) Sniff(in *SniffInput) (int, string) {
+	if in.contains("BEGIN IONS") {
+		return 85, "BEGIN IONS block"
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from an MGF file.
+func (e *MGFExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	metadata, err := e.extractFromReader(f, filepath)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil {
+		metadata["file_size"] = info.Size()
+	}
+	return metadata, nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *MGFExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	return e.extractFromReader(r, filename)
+}
+
+// extractFromReader streams the peak list, summarizing each spectrum.
+func (e *MGFExtractor) extractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	summary := newMSRunSummary()
+	params := map[string]string{}
+	charges := map[string]int{}
+	var sourceFiles []string
+	var spectrum *msSpectrum
+	var peaks int64
+	var precursorMin, precursorMax float64
+	hasPrecursor := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '!' {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(line, "BEGIN IONS"):
+			spectrum = &msSpectrum{level: 2}
+			continue
+		case strings.EqualFold(line, "END IONS"):
+			if spectrum != nil {
+				summary.add(spectrum)
+				spectrum = nil
+			}
+			continue
+		}
+
+		key, value, isParam := strings.Cut(line, "=")
+		if !isParam {
+			if spectrum != nil {
+				peaks++
+			}
+			continue
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if spectrum == nil {
+			params[key] = value
+			continue
+		}
+
+		switch key {
+		case "TITLE":
+			if m := mgfTitleFilePattern.FindStringSubmatch(value); m != nil {
+				sourceFiles = appendUnique(sourceFiles, m[1])
+			}
+			if m := mgfTitleScanPattern.FindStringSubmatch(value); m != nil && !spectrum.hasScan {
+				spectrum.scan, _ = strconv.Atoi(m[1])
+				spectrum.hasScan = true
+			}
+		case "PEPMASS":
+			if mz, err := strconv.ParseFloat(strings.Fields(value + " ")[0], 64); err == nil {
+				if !hasPrecursor || mz < precursorMin {
+					precursorMin = mz
+				}
+				if !hasPrecursor || mz > precursorMax {
+					precursorMax = mz
+				}
+				hasPrecursor = true
+			}
+		case "CHARGE":
+			charges[value]++
+		case "RTINSECONDS":
+			// Ranges such as "12.1-12.4" keep their start
+			start, _, _ := strings.Cut(value, "-")
+			if seconds, err := strconv.ParseFloat(start, 64); err == nil {
+				spectrum.rtMinutes, spectrum.hasRT = seconds/60, true
+			}
+		case "SCANS":
+			first, _, _ := strings.Cut(value, "-")
+			if scan, err := strconv.Atoi(first); err == nil {
+				spectrum.scan, spectrum.hasScan = scan, true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read MGF: %w", err)
+	}
+	if summary.spectra == 0 && len(params) == 0 {
+		return nil, fmt.Errorf("not a valid MGF file: no spectra or parameters found")
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "MGF",
+		"file_name":       filename,
+		"extractor_name":  "mgf",
+		"schema_name":     "mgf_v1",
+		"instrument_type": "mass_spec",
+		"data_type":       "mass_spectrum",
+	}
+	if len(params) > 0 {
+		metadata["mgf_parameters"] = params
+	}
+	if len(sourceFiles) > 0 {
+		metadata["source_file"] = sourceFiles[0]
+		metadata["source_files"] = sourceFiles
+	}
+	summary.addTo(metadata)
+	if len(charges) > 0 {
+		metadata["charge_states"] = charges
+	}
+	if hasPrecursor {
+		metadata["precursor_mz_min"] = precursorMin
+		metadata["precursor_mz_max"] = precursorMax
+	}
+	if summary.spectra > 0 {
+		metadata["total_peaks"] = peaks
+		metadata["mean_peaks_per_spectrum"] = float64(peaks) / float64(summary.spectra)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMGF = `# exported by msconvert
+COM=Test peak list
+MASS=Monoisotopic
+
+BEGIN IONS
+TITLE=sample.100.100.2 File:"sample.raw", NativeID:"controllerType=0 controllerNumber=1 scan=100"
+PEPMASS=445.1200 120034.5
+CHARGE=2+
+RTINSECONDS=600.5
+110.0712 5032.1
+175.1190 8830.0
+END IONS
+
+BEGIN IONS
+TITLE=sample.250.250.3 File:"sample.raw", NativeID:"controllerType=0 controllerNumber=1 scan=250"
+PEPMASS=712.8800
+CHARGE=3+
+RTINSECONDS=1200
+SCANS=250
+129.1022 1200.0
+END IONS
+`
+
+func TestMGFExtractor_ExtractFromReader(t *testing.T) {
+	extractor := &MGFExtractor{}
+	metadata, err := extractor.ExtractFromReader(strings.NewReader(testMGF), "sample.mgf")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	checks := map[string]interface{}{
+		"format":           "MGF",
+		"total_spectra":    2,
+		"ms2_spectra":      2,
+		"source_file":      "sample.raw",
+		"first_scan":       100,
+		"last_scan":        250,
+		"precursor_mz_min": 445.12,
+		"precursor_mz_max": 712.88,
+		"total_peaks":      int64(3),
+		"rt_end_min":       20.0,
+	}
+	for key, want := range checks {
+		if metadata[key] != want {
+			t.Errorf("%s = %v (%T), want %v", key, metadata[key], metadata[key], want)
+		}
+	}
+
+	charges := metadata["charge_states"].(map[string]int)
+	if charges["2+"] != 1 || charges["3+"] != 1 {
+		t.Errorf("charge_states = %v", charges)
+	}
+	params := metadata["mgf_parameters"].(map[string]string)
+	if params["MASS"] != "Monoisotopic" {
+		t.Errorf("mgf_parameters = %v", params)
+	}
+}
+
+func TestMGFExtractor_Invalid(t *testing.T) {
+	extractor := &MGFExtractor{}
+	if _, err := extractor.ExtractFromReader(strings.NewReader("just some text\n"), "bad.mgf"); err == nil {
+		t.Error("ExtractFromReader() should return error when no spectra or parameters are found")
+	}
+}
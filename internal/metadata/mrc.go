@@ -0,0 +1,462 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # MRC Format
+//
+// This file implements metadata extraction for MRC2014 files: cryo-EM
+// micrographs, movie stacks (.mrcs), tilt series (.st), tomograms (.rec)
+// and density maps (.map).
+//
+// ## File Format Overview
+//
+// An MRC file starts with a 1024-byte header of 4-byte words:
+//   - NX, NY, NZ: columns, rows and sections
+//   - MODE: 0 int8, 1 int16, 2 float32, 3/4 complex, 6 uint16, 12 float16
+//   - MX, MY, MZ and CELLA: sampling and cell size in Å, so the pixel size
+//     is CELLA/MX
+//   - ISPG: space group (0 image stack, 1 volume, 401 volume stack)
+//   - NSYMBT: size of the extended header that follows the main header
+//   - EXTTYP (byte 104): extended header type, e.g. "FEI1", "FEI2", "CCP4"
+//   - MACHST (byte 212): byte order stamp, 0x44 0x44 little endian
+//   - NLABL and ten 80-character text labels
+//
+// ## FEI Extended Header
+//
+// Thermo Fisher software writes one FEI1 or FEI2 metadata block per section.
+// Each block starts with its own size, so FEI2's additional fields are
+// skipped. Fields read from each block:
+//   - Timestamp (OLE date), microscope type, D-number, application
+//   - HT (V), dose (e⁻/m²), alpha tilt, pixel size (m), defocus (m)
+//   - Magnification, integration time (s), camera name, counting flag
+//
+// Voltage, pixel size, defocus and detector come from the first block;
+// dose is summed over sections and varying tilts are reported as
+// "tilt_angles". An EPU XML sidecar fills any fields the header lacks.
+//
+// ## References and Sources
+//
+// MRC2014 file format (CCP-EM):
+// https://www.ccpem.ac.uk/mrc_format/mrc2014.php
+//
+// Thermo Fisher "MRC2014 extended header" (FEI1/FEI2) specification, as
+// implemented by the mrcfile Python library.
+package metadata
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MRC layout constants
+const (
+	mrcHeaderSize        = 1024
+	mrcMaxExtendedHeader = 64 << 20
+	mrcLabelSize         = 80
+	mrcMaxLabels         = 10
+)
+
+// mrcModes names MRC data modes.
+var mrcModes = map[int32]string{
+	0:   "int8",
+	1:   "int16",
+	2:   "float32",
+	3:   "complex_int16",
+	4:   "complex_float32",
+	6:   "uint16",
+	12:  "float16",
+	101: "uint4",
+}
+
+// FEI1 extended header field offsets within a metadata block.
+const (
+	feiMetadataSize    = 0
+	feiTimestamp       = 12
+	feiMicroscopeType  = 20
+	feiDNumber         = 36
+	feiApplication     = 52
+	feiAppVersion      = 68
+	feiHT              = 84
+	feiDose            = 92
+	feiAlphaTilt       = 100
+	feiPixelSizeX      = 156
+	feiDefocus         = 220
+	feiMagnification   = 289
+	feiIntegrationTime = 419
+	feiCameraName      = 435
+	feiCounting        = 467
+	feiMinBlockSize    = 469
+)
+
+// MRCExtractor extracts metadata from MRC/MRCS files.
+type MRCExtractor struct{}
+
+// Name returns the extractor name.
+func (e *MRCExtractor) Name() string {
+	return "MRC"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *MRCExtractor) SupportedFormats() []string {
+	return []string{".mrc", ".mrcs", ".map", ".st", ".rec"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *MRCExtractor) CanHandle(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, format := range e.SupportedFormats() {
+		if ext == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Sniff recognises the "MAP " stamp of MRC2014 headers. Older files without
+// it are matched by extension.
+func (e *MRCExtractor) Sniff(in *SniffInput) (int, string) {
+	if len(in.Header) >= mrcHeaderSize && in.at(208, "MAP ") {
+		return 90, `"MAP " stamp at offset 208`
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from an MRC file and its EPU sidecar.
+func (e *MRCExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	metadata, _, err := e.extractFile(filepath)
+	return metadata, err
+}
+
+// ExtractTyped extracts an MRC file and its EPU sidecar as CryoEMMetadata.
+func (e *MRCExtractor) ExtractTyped(filepath string) (*InstrumentMetadata, error) {
+	metadata, typed, err := e.extractFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return typedMetadata(metadata, typed), nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *MRCExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	ra, err := readerAtFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	metadata, _, err := e.extract(ra, filename, false)
+	return metadata, err
+}
+
+// extractFile opens filepath and extracts it, adding the file size.
+func (e *MRCExtractor) extractFile(filepath string) (map[string]interface{}, *CryoEMMetadata, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	metadata, typed, err := e.extract(f, filepath, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata["file_size"] = info.Size()
+	return metadata, typed, nil
+}
+
+// mrcHeader holds the fields of the main header.
+type mrcHeader struct {
+	order             binary.ByteOrder
+	nx, ny, nz        int32
+	mode              int32
+	mx, my, mz        int32
+	cella             [3]float32
+	ispg              int32
+	nsymbt            int32
+	dmin, dmax, dmean float32
+	extType           string
+	version           int32
+	labels            []string
+	rms               float32
+}
+
+// readMRCHeader parses and validates the 1024-byte header.
+func readMRCHeader(r io.ReaderAt) (*mrcHeader, error) {
+	buf := make([]byte, mrcHeaderSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("not a valid MRC file: header too short")
+	}
+
+	h := &mrcHeader{order: binary.LittleEndian}
+	switch {
+	case buf[212] == 0x11 && buf[213] == 0x11:
+		h.order = binary.BigEndian
+	case buf[212] == 0x44:
+	default:
+		// No stamp: pick the byte order that gives a known mode
+		if _, ok := mrcModes[int32(binary.LittleEndian.Uint32(buf[12:16]))]; !ok {
+			h.order = binary.BigEndian
+		}
+	}
+
+	i32 := func(off int) int32 { return int32(h.order.Uint32(buf[off:])) }
+	f32 := func(off int) float32 { return math.Float32frombits(h.order.Uint32(buf[off:])) }
+
+	h.nx, h.ny, h.nz = i32(0), i32(4), i32(8)
+	h.mode = i32(12)
+	h.mx, h.my, h.mz = i32(28), i32(32), i32(36)
+	h.cella = [3]float32{f32(40), f32(44), f32(48)}
+	h.dmin, h.dmax, h.dmean = f32(76), f32(80), f32(84)
+	h.ispg = i32(88)
+	h.nsymbt = i32(92)
+	h.extType = strings.TrimRight(string(buf[104:108]), "\x00 ")
+	h.version = i32(108)
+	h.rms = f32(216)
+
+	if h.nx <= 0 || h.ny <= 0 || h.nz <= 0 {
+		return nil, fmt.Errorf("not a valid MRC file: invalid dimensions %dx%dx%d", h.nx, h.ny, h.nz)
+	}
+	if _, ok := mrcModes[h.mode]; !ok {
+		return nil, fmt.Errorf("not a valid MRC file: unknown mode %d", h.mode)
+	}
+	if h.nsymbt < 0 || h.nsymbt > mrcMaxExtendedHeader {
+		return nil, fmt.Errorf("not a valid MRC file: invalid extended header size %d", h.nsymbt)
+	}
+
+	nlabl := int(i32(220))
+	if nlabl > mrcMaxLabels {
+		nlabl = mrcMaxLabels
+	}
+	for i := 0; i < nlabl; i++ {
+		label := strings.TrimRight(string(buf[224+i*mrcLabelSize:224+(i+1)*mrcLabelSize]), "\x00 ")
+		if label != "" {
+			h.labels = append(h.labels, label)
+		}
+	}
+	return h, nil
+}
+
+// pixelSize returns the sampling in Å along an axis, or 0.
+func (h *mrcHeader) pixelSize(axis int) float64 {
+	samples := [3]int32{h.mx, h.my, h.mz}[axis]
+	if samples <= 0 || h.cella[axis] <= 0 {
+		return 0
+	}
+	return float64(h.cella[axis]) / float64(samples)
+}
+
+// extract reads the header, the FEI extended header and, when sidecar is
+// set, the EPU XML next to the file.
+func (e *MRCExtractor) extract(r io.ReaderAt, filename string, sidecar bool) (map[string]interface{}, *CryoEMMetadata, error) {
+	h, err := readMRCHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "MRC",
+		"file_name":       filepath.Base(filename),
+		"extractor_name":  "mrc",
+		"schema_name":     "cryoem_v1",
+		"instrument_type": "cryo_em",
+		"image_width":     int(h.nx),
+		"image_height":    int(h.ny),
+		"num_sections":    int(h.nz),
+		"mode":            int(h.mode),
+		"dtype":           mrcModes[h.mode],
+		"space_group":     int(h.ispg),
+		"density_min":     float64(h.dmin),
+		"density_max":     float64(h.dmax),
+		"density_mean":    float64(h.dmean),
+		"density_rms":     float64(h.rms),
+	}
+	if h.order == binary.BigEndian {
+		metadata["byte_order"] = "big_endian"
+	} else {
+		metadata["byte_order"] = "little_endian"
+	}
+
+	isStack := h.ispg == 0 || strings.EqualFold(filepath.Ext(filename), ".mrcs")
+	switch {
+	case h.ispg == 0 && h.nz > 1, strings.EqualFold(filepath.Ext(filename), ".mrcs"):
+		metadata["data_kind"] = "image_stack"
+	case h.ispg == 0:
+		metadata["data_kind"] = "image"
+	case h.ispg >= 401:
+		metadata["data_kind"] = "volume_stack"
+	default:
+		metadata["data_kind"] = "volume"
+		metadata["image_depth"] = int(h.nz)
+	}
+	if h.extType != "" {
+		metadata["extended_header_type"] = h.extType
+	}
+	if h.nsymbt > 0 {
+		metadata["extended_header_size"] = int(h.nsymbt)
+	}
+	if h.version > 0 {
+		metadata["mrc_version"] = int(h.version)
+	}
+	if len(h.labels) > 0 {
+		metadata["labels"] = h.labels
+	}
+	for axis, key := range []string{"pixel_size_x_A", "pixel_size_y_A", "pixel_size_z_A"} {
+		if ps := h.pixelSize(axis); ps > 0 && (axis < 2 || !isStack) {
+			metadata[key] = ps
+		}
+	}
+
+	cm := &CryoEMMetadata{PixelSize: h.pixelSize(0)}
+	if isStack && h.nz > 1 {
+		cm.FramesPerMovie = int(h.nz)
+	}
+
+	if strings.HasPrefix(h.extType, "FEI") && h.nsymbt > 0 {
+		ext := make([]byte, h.nsymbt)
+		if _, err := r.ReadAt(ext, mrcHeaderSize); err != nil && err != io.EOF {
+			return nil, nil, fmt.Errorf("failed to read extended header: %w", err)
+		}
+		fei := parseFEIExtendedHeader(ext, int(h.nz))
+		fei.apply(cm, metadata)
+	}
+
+	if sidecar {
+		if path := mergeEPUSidecar(filename, cm); path != "" {
+			metadata["sidecar_file"] = filepath.Base(path)
+		}
+	}
+	cryoEMFields(cm, metadata)
+	return metadata, cm, nil
+}
+
+// feiBlock holds the FEI1 fields read from one section's metadata block.
+type feiBlock struct {
+	timestamp       float64
+	microscopeType  string
+	dNumber         string
+	application     string
+	appVersion      string
+	ht              float64
+	dose            float64
+	alphaTilt       float64
+	pixelSize       float64
+	defocus         float64
+	magnification   float64
+	integrationTime float64
+	cameraName      string
+	counting        bool
+}
+
+// feiExtendedHeader is the list of per-section blocks.
+type feiExtendedHeader []feiBlock
+
+// parseFEIExtendedHeader reads up to sections FEI1/FEI2 blocks. The FEI
+// extended header is always little endian.
+func parseFEIExtendedHeader(ext []byte, sections int) feiExtendedHeader {
+	var blocks feiExtendedHeader
+	for offset := 0; len(blocks) < sections && offset+feiMinBlockSize <= len(ext); {
+		b := ext[offset:]
+		size := int(binary.LittleEndian.Uint32(b[feiMetadataSize:]))
+		if size < feiMinBlockSize || offset+size > len(ext) {
+			break
+		}
+
+		f64 := func(off int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[off:])) }
+		str := func(off int) string { return strings.TrimRight(string(b[off:off+16]), "\x00 ") }
+		blocks = append(blocks, feiBlock{
+			timestamp:       f64(feiTimestamp),
+			microscopeType:  str(feiMicroscopeType),
+			dNumber:         str(feiDNumber),
+			application:     str(feiApplication),
+			appVersion:      str(feiAppVersion),
+			ht:              f64(feiHT),
+			dose:            f64(feiDose),
+			alphaTilt:       f64(feiAlphaTilt),
+			pixelSize:       f64(feiPixelSizeX),
+			defocus:         f64(feiDefocus),
+			magnification:   f64(feiMagnification),
+			integrationTime: f64(feiIntegrationTime),
+			cameraName:      str(feiCameraName),
+			counting:        b[feiCounting] != 0,
+		})
+		offset += size
+	}
+	return blocks
+}
+
+// apply maps the blocks into cm and adds FEI-specific fields to metadata.
+func (fei feiExtendedHeader) apply(cm *CryoEMMetadata, metadata map[string]interface{}) {
+	if len(fei) == 0 {
+		return
+	}
+	first := fei[0]
+
+	if first.microscopeType != "" {
+		cm.Manufacturer = "Thermo Fisher Scientific"
+		cm.Model = first.microscopeType
+	}
+	cm.SerialNumber = first.dNumber
+	cm.DetectorModel = first.cameraName
+	if first.counting {
+		cm.DetectorMode = "counting"
+	}
+	if first.ht > 0 {
+		cm.Voltage = int(first.ht/1000 + 0.5)
+	}
+	if first.pixelSize > 0 {
+		cm.PixelSize = first.pixelSize * 1e10
+	}
+	cm.Defocus = first.defocus * 1e6
+	cm.Magnification = first.magnification
+	cm.ExposureTime = first.integrationTime
+	if first.timestamp > 0 {
+		// OLE automation date: days since 1899-12-30
+		epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		cm.AcquisitionDate = epoch.Add(time.Duration(first.timestamp * 24 * float64(time.Hour))).Truncate(time.Second)
+	}
+
+	var dose float64
+	var tilts []float64
+	tiltVaries := false
+	for _, b := range fei {
+		dose += b.dose
+		tilts = append(tilts, b.alphaTilt)
+		if b.alphaTilt != first.alphaTilt {
+			tiltVaries = true
+		}
+	}
+	if dose > 0 {
+		cm.TotalDose = dose * 1e-20
+	}
+	if tiltVaries {
+		metadata["tilt_angles"] = tilts
+	}
+
+	if first.application != "" {
+		metadata["software_name"] = first.application
+	}
+	if first.appVersion != "" {
+		metadata["software_version"] = first.appVersion
+	}
+}
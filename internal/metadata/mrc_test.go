@@ -0,0 +1,180 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// buildTestMRC writes an MRC2014 header with an optional FEI1 extended
+// header holding one block per section.
+func buildTestMRC(nx, ny, nz, mode, ispg int32, pixelA float32, fei []feiBlock) []byte {
+	const blockSize = 768
+	h := make([]byte, mrcHeaderSize)
+	le := binary.LittleEndian
+	put32 := func(off int, v int32) { le.PutUint32(h[off:], uint32(v)) }
+	putF := func(off int, v float32) { le.PutUint32(h[off:], math.Float32bits(v)) }
+
+	put32(0, nx)
+	put32(4, ny)
+	put32(8, nz)
+	put32(12, mode)
+	put32(28, nx)
+	put32(32, ny)
+	put32(36, nz)
+	putF(40, pixelA*float32(nx))
+	putF(44, pixelA*float32(ny))
+	putF(48, pixelA*float32(nz))
+	put32(64, 1)
+	put32(68, 2)
+	put32(72, 3)
+	putF(84, 12.5)
+	put32(88, ispg)
+	copy(h[208:], "MAP ")
+	h[212], h[213] = 0x44, 0x44
+	put32(220, 1)
+	copy(h[224:], "Created by test")
+
+	var ext []byte
+	if len(fei) > 0 {
+		copy(h[104:], "FEI1")
+		put32(108, 20140)
+		for _, b := range fei {
+			block := make([]byte, blockSize)
+			le.PutUint32(block[feiMetadataSize:], blockSize)
+			putF64 := func(off int, v float64) { le.PutUint64(block[off:], math.Float64bits(v)) }
+			putF64(feiTimestamp, b.timestamp)
+			copy(block[feiMicroscopeType:], b.microscopeType)
+			copy(block[feiDNumber:], b.dNumber)
+			copy(block[feiApplication:], b.application)
+			copy(block[feiAppVersion:], b.appVersion)
+			putF64(feiHT, b.ht)
+			putF64(feiDose, b.dose)
+			putF64(feiAlphaTilt, b.alphaTilt)
+			putF64(feiPixelSizeX, b.pixelSize)
+			putF64(feiDefocus, b.defocus)
+			putF64(feiMagnification, b.magnification)
+			putF64(feiIntegrationTime, b.integrationTime)
+			copy(block[feiCameraName:], b.cameraName)
+			if b.counting {
+				block[feiCounting] = 1
+			}
+			ext = append(ext, block...)
+		}
+		put32(92, int32(len(ext)))
+	}
+
+	out := append(h, ext...)
+	return append(out, make([]byte, 16)...)
+}
+
+func TestMRCExtractorFEITiltSeries(t *testing.T) {
+	var blocks []feiBlock
+	for _, tilt := range []float64{-3, 0, 3} {
+		blocks = append(blocks, feiBlock{
+			timestamp:       45414.5, // 2024-05-02 12:00 UTC
+			microscopeType:  "TITAN52336320",
+			dNumber:         "D3593",
+			application:     "Tomography",
+			appVersion:      "5.15",
+			ht:              300000,
+			dose:            1e20,
+			alphaTilt:       tilt,
+			pixelSize:       1.5e-10,
+			defocus:         -4e-6,
+			magnification:   64000,
+			integrationTime: 0.8,
+			cameraName:      "BM-Falcon",
+			counting:        true,
+		})
+	}
+	data := buildTestMRC(4096, 4096, 3, 2, 0, 1.5, blocks)
+
+	metadata, err := (&MRCExtractor{}).ExtractFromReader(bytes.NewReader(data), "tilt.st")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":               "MRC",
+		"image_width":          4096,
+		"num_sections":         3,
+		"dtype":                "float32",
+		"data_kind":            "image_stack",
+		"extended_header_type": "FEI1",
+		"labels":               []string{"Created by test"},
+		"manufacturer":         "Thermo Fisher Scientific",
+		"model":                "TITAN52336320",
+		"serial_number":        "D3593",
+		"detector_model":       "BM-Falcon",
+		"detector_mode":        "counting",
+		"voltage":              300,
+		"frames_per_movie":     3,
+		"software_name":        "Tomography",
+		"acquisition_date":     "2024-05-02T12:00:00Z",
+		"tilt_angles":          []float64{-3, 0, 3},
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(metadata[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+	assertFloat(t, metadata, "pixel_size", 1.5)
+	assertFloat(t, metadata, "defocus", -4)
+	assertFloat(t, metadata, "magnification", 64000)
+	assertFloat(t, metadata, "exposure_time", 0.8)
+	assertFloat(t, metadata, "total_dose", 3)
+}
+
+func TestMRCExtractorVolumeWithSidecar(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "FoilHole_1_Data_2_3_20240502_101112_fractions.mrc")
+	if err := os.WriteFile(path, buildTestMRC(64, 64, 64, 2, 1, 0.73, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := filepath.Join(dir, "FoilHole_1_Data_2_3_20240502_101112.xml")
+	if err := os.WriteFile(sidecar, []byte(testEPUXML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := (&MRCExtractor{}).Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if metadata["data_kind"] != "volume" || metadata["image_depth"] != 64 {
+		t.Errorf("data_kind/image_depth = %v/%v", metadata["data_kind"], metadata["image_depth"])
+	}
+	if metadata["sidecar_file"] != filepath.Base(sidecar) || metadata["voltage"] != 300 || metadata["detector_model"] != "Falcon 4i" {
+		t.Errorf("sidecar fields = %v/%v/%v", metadata["sidecar_file"], metadata["voltage"], metadata["detector_model"])
+	}
+	assertFloat(t, metadata, "pixel_size_z_A", 0.73)
+	assertFloat(t, metadata, "spherical_aberration", 2.7)
+}
+
+func TestMRCExtractorInvalid(t *testing.T) {
+	extractor := &MRCExtractor{}
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(make([]byte, 100)), "short.mrc"); err == nil {
+		t.Error("expected error for short header")
+	}
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(buildTestMRC(10, 10, 1, 77, 0, 1, nil)), "mode.mrc"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
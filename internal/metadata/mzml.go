@@ -0,0 +1,1107 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # mzML and mzXML Formats
+//
+// This file implements metadata extraction for the two open XML formats used
+// for mass spectrometry data: HUPO-PSI mzML (1.0/1.1) and the older ISB
+// mzXML (2.x/3.x).
+//
+// ## mzML Structure
+//
+// mzML describes everything with PSI-MS controlled vocabulary (CV) terms:
+//   - referenceableParamGroupList: shared cvParam groups referenced by ID
+//   - softwareList: acquisition and processing software with versions
+//   - instrumentConfigurationList: model and serial number, followed by
+//     source (ionization), analyzer and detector components
+//   - run: start timestamp, then spectrumList with one spectrum per scan
+//
+// Each spectrum carries its MS level, polarity and representation as CV
+// terms, a scan with its start time and scan window, and base64 binary data
+// arrays that this extractor never decodes.
+//
+// ## Indexed Files
+//
+// indexedmzML and indexed mzXML end with an offset index pointing at every
+// spectrum. When extracting from a file with a valid index, the header is
+// read up to the spectrum list and then only the first few kilobytes at each
+// indexed offset are parsed, so the binary peak data making up most of a
+// multi-gigabyte file is never read.
+//
+// ## mzXML Structure
+//
+// mzXML stores instrument settings as msInstrument child elements with
+// free-text values, and every scan's level, polarity, retention time
+// (xs:duration) and m/z window as attributes on nested scan elements.
+//
+// ## References and Sources
+//
+// mzML 1.1.0 specification:
+// https://www.psidev.info/mzML
+//
+// PSI-MS controlled vocabulary:
+// https://github.com/HUPO-PSI/psi-ms-CV
+//
+// mzXML 3.2 schema:
+// http://sashimi.sourceforge.net/schema_revision/mzXML_3.2/
+//
+// ## Limitations
+//
+//   - Binary peak arrays are never decoded
+//   - Only the first instrument configuration supplies model and serial
+//   - CV names come from a bundled subset of PSI-MS; other accessions use the
+//     name written in the file
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// mzML parsing constants
+const (
+	// mzMLIndexTail is how much of the file end is searched for the index offset
+	mzMLIndexTail = 4096
+	// mzMLSpectrumChunk is how much is read at each indexed spectrum offset;
+	// spectrum metadata always precedes the binary data arrays
+	mzMLSpectrumChunk = 16 << 10
+)
+
+var (
+	mzMLIndexOffsetPattern  = regexp.MustCompile(`<indexListOffset>\s*(\d+)\s*</indexListOffset>`)
+	mzXMLIndexOffsetPattern = regexp.MustCompile(`<indexOffset>\s*(\d+)\s*</indexOffset>`)
+	mzMLScanNumberPattern   = regexp.MustCompile(`(?:scan|index|spectrum)=(\d+)`)
+	xsDurationPattern       = regexp.MustCompile(`^-?P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+)
+
+// MzMLExtractor extracts metadata from mzML and mzXML mass spectrometry files.
+type MzMLExtractor struct{}
+
+// cvParam is a PSI-MS controlled vocabulary parameter.
+type cvParam struct {
+	accession     string
+	name          string
+	value         string
+	unitAccession string
+}
+
+// msInstrumentConfig is one mzML instrumentConfiguration.
+type msInstrumentConfig struct {
+	model          string
+	modelAccession string
+	serial         string
+	sources        []string
+	analyzers      []string
+	detectors      []string
+}
+
+// msSpectrum holds the summary fields of one spectrum.
+type msSpectrum struct {
+	level          int
+	polarity       string
+	representation string
+	rtMinutes      float64
+	hasRT          bool
+	mzLow, mzHigh  float64
+	hasMZ          bool
+	scan           int
+	hasScan        bool
+	activations    []string
+}
+
+// msRunSummary aggregates spectra into run-level counts and ranges.
+type msRunSummary struct {
+	spectra         int
+	byLevel         map[int]int
+	polarities      map[string]bool
+	representations map[string]bool
+	activations     []string
+	rtMin, rtMax    float64
+	hasRT           bool
+	mzMin, mzMax    float64
+	hasMZ           bool
+	scanFirst       int
+	scanLast        int
+	hasScan         bool
+}
+
+// mzML parse modes
+const (
+	mzMLParseAll      = iota // the whole document
+	mzMLParseHeader          // up to the start of the spectrum list
+	mzMLParseSpectrum        // one indexed spectrum, up to its binary data
+)
+
+// mzMLParser walks mzML tokens, collecting header fields and spectra.
+type mzMLParser struct {
+	stack       []string
+	sawRoot     bool
+	paramGroups map[string][]cvParam
+	groupID     string
+	configs     []*msInstrumentConfig
+	software    []map[string]interface{}
+	sourceFiles []string
+	version     string
+	runID       string
+	startTime   string
+	listCount   int
+	spectrum    *msSpectrum
+	summary     *msRunSummary
+}
+
+// Name returns the extractor name.
+func (e *MzMLExtractor) Name() string {
+	return "mzML"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *MzMLExtractor) SupportedFormats() []string {
+	return []string{".mzml", ".mzxml"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *MzMLExtractor) CanHandle(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".mzml" || ext == ".mzxml"
+}
+
+// Sniff recognises mzML and mzXML root elements.
+func (e *MzMLExtractor) Sniff(in *SniffInput) (int, string) {
+	switch msXMLRoot(in.Header) {
+	case "mzML", "indexedmzML":
+		return 95, "mzML root element"
+	case "mzXML":
+		return 95, "mzXML root element"
+	}
+	return 0, ""
+}
+
+// msXMLRoot returns the name of the root element at the start of an XML
+// document, or "" if header does not reach one.
+func msXMLRoot(header []byte) string {
+	dec := newMSXMLDecoder(bytes.NewReader(header))
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+// isMzXML reports whether a document is mzXML rather than mzML, going by
+// its root element and, if header does not reach it, the file name.
+func isMzXML(header []byte, filename string) bool {
+	if root := msXMLRoot(header); root != "" {
+		return root == "mzXML"
+	}
+	return strings.HasSuffix(strings.ToLower(filename), ".mzxml")
+}
+
+// Extract extracts metadata from an mzML or mzXML file, using the offset
+// index when the file has one.
+func (e *MzMLExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	header := make([]byte, sniffHeaderSize)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	mzXML := isMzXML(header[:n], filepath)
+
+	var metadata map[string]interface{}
+	if offsets := readSpectrumIndex(f, info.Size(), mzXML); len(offsets) > 0 {
+		metadata, err = e.extractIndexed(f, offsets, filepath, mzXML)
+	} else {
+		metadata, err = e.extractFromReader(f, filepath, mzXML)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata["file_size"] = info.Size()
+	return metadata, nil
+}
+
+// ExtractFromReader extracts metadata from a reader by streaming the whole
+// document; the offset index needs random access and is only used by Extract.
+func (e *MzMLExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	br := bufio.NewReaderSize(r, sniffHeaderSize)
+	header, _ := br.Peek(sniffHeaderSize)
+	return e.extractFromReader(br, filename, isMzXML(header, filename))
+}
+
+// extractFromReader streams the full document.
+func (e *MzMLExtractor) extractFromReader(r io.Reader, filename string, isMzXML bool) (map[string]interface{}, error) {
+	dec := newMSXMLDecoder(r)
+	if isMzXML {
+		p := newMzXMLParser()
+		if err := p.parse(dec, false); err != nil {
+			return nil, fmt.Errorf("failed to parse mzXML: %w", err)
+		}
+		if !p.sawRoot {
+			return nil, fmt.Errorf("not a valid mzXML file: missing msRun element")
+		}
+		return p.metadata(filename, false), nil
+	}
+
+	p := newMzMLParser()
+	if err := p.parse(dec, mzMLParseAll); err != nil {
+		return nil, fmt.Errorf("failed to parse mzML: %w", err)
+	}
+	if !p.sawRoot {
+		return nil, fmt.Errorf("not a valid mzML file: missing mzML element")
+	}
+	return p.metadata(filename, false), nil
+}
+
+// extractIndexed parses the header and then each indexed spectrum.
+func (e *MzMLExtractor) extractIndexed(f io.ReaderAt, offsets []int64, filename string, isMzXML bool) (map[string]interface{}, error) {
+	header := newMSXMLDecoder(io.NewSectionReader(f, 0, offsets[0]))
+	chunk := make([]byte, mzMLSpectrumChunk)
+
+	readChunk := func(offset int64) (*xml.Decoder, error) {
+		n, err := f.ReadAt(chunk, offset)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read spectrum at offset %d: %w", offset, err)
+		}
+		return newMSXMLDecoder(bytes.NewReader(chunk[:n])), nil
+	}
+
+	if isMzXML {
+		p := newMzXMLParser()
+		// The header section ends mid-document, so only a missing run is fatal
+		_ = p.parse(header, true)
+		if !p.sawRoot {
+			return nil, fmt.Errorf("not a valid mzXML file: missing msRun element")
+		}
+		for _, offset := range offsets {
+			dec, err := readChunk(offset)
+			if err != nil {
+				return nil, err
+			}
+			p.parseIndexedScan(dec)
+		}
+		return p.metadata(filename, true), nil
+	}
+
+	p := newMzMLParser()
+	if err := p.parse(header, mzMLParseHeader); err != nil {
+		return nil, fmt.Errorf("failed to parse mzML header: %w", err)
+	}
+	if !p.sawRoot {
+		return nil, fmt.Errorf("not a valid mzML file: missing mzML element")
+	}
+	for _, offset := range offsets {
+		dec, err := readChunk(offset)
+		if err != nil {
+			return nil, err
+		}
+		p.stack = p.stack[:0]
+		// A spectrum larger than the chunk ends mid-element; what was read is kept
+		_ = p.parse(dec, mzMLParseSpectrum)
+		p.flushSpectrum()
+	}
+	return p.metadata(filename, true), nil
+}
+
+// readSpectrumIndex reads the spectrum offsets from an indexed mzML or mzXML
+// file. It returns nil when the file has no usable index.
+func readSpectrumIndex(f io.ReaderAt, size int64, isMzXML bool) []int64 {
+	tailSize := int64(mzMLIndexTail)
+	if size < tailSize {
+		tailSize = size
+	}
+	tail := make([]byte, tailSize)
+	if _, err := f.ReadAt(tail, size-tailSize); err != nil && err != io.EOF {
+		return nil
+	}
+
+	pattern, indexName := mzMLIndexOffsetPattern, "spectrum"
+	if isMzXML {
+		pattern, indexName = mzXMLIndexOffsetPattern, "scan"
+	}
+	match := pattern.FindSubmatch(tail)
+	if match == nil {
+		return nil
+	}
+	indexOffset, err := strconv.ParseInt(string(match[1]), 10, 64)
+	if err != nil || indexOffset <= 0 || indexOffset >= size {
+		return nil
+	}
+
+	dec := newMSXMLDecoder(io.NewSectionReader(f, indexOffset, size-indexOffset))
+	var offsets []int64
+	inIndex := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "index" {
+				inIndex = xmlAttr(t, "name") == indexName
+			} else if t.Name.Local == "offset" && inIndex {
+				var text string
+				if err := dec.DecodeElement(&text, &t); err != nil {
+					return nil
+				}
+				offset, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+				if err != nil || offset <= 0 || offset >= indexOffset {
+					return nil
+				}
+				offsets = append(offsets, offset)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "index" {
+				inIndex = false
+			}
+		}
+	}
+
+	// Offsets must be increasing for the header section to end at the first
+	if !sort.SliceIsSorted(offsets, func(i, j int) bool { return offsets[i] < offsets[j] }) {
+		return nil
+	}
+	return offsets
+}
+
+func newMzMLParser() *mzMLParser {
+	return &mzMLParser{
+		paramGroups: map[string][]cvParam{},
+		summary:     newMSRunSummary(),
+	}
+}
+
+// parse walks tokens until EOF or until the section selected by mode ends.
+func (p *mzMLParser) parse(dec *xml.Decoder, mode int) error {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			switch name {
+			case "spectrumList":
+				p.listCount, _ = strconv.Atoi(xmlAttr(t, "count"))
+				if mode == mzMLParseHeader {
+					return nil
+				}
+			case "binaryDataArrayList", "chromatogramList":
+				if mode == mzMLParseSpectrum {
+					return nil
+				}
+				// Binary peak data and chromatograms carry nothing we report
+				if err := dec.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			p.start(t)
+			p.stack = append(p.stack, name)
+		case xml.EndElement:
+			if len(p.stack) > 0 {
+				p.stack = p.stack[:len(p.stack)-1]
+			}
+			p.end(t.Name.Local)
+			if mode == mzMLParseSpectrum && t.Name.Local == "spectrum" {
+				return nil
+			}
+		}
+	}
+}
+
+// parent returns the enclosing element name.
+func (p *mzMLParser) parent() string {
+	if len(p.stack) == 0 {
+		return ""
+	}
+	return p.stack[len(p.stack)-1]
+}
+
+// start handles an opening element.
+func (p *mzMLParser) start(t xml.StartElement) {
+	switch t.Name.Local {
+	case "mzML":
+		p.sawRoot = true
+		p.version = xmlAttr(t, "version")
+	case "referenceableParamGroup":
+		p.groupID = xmlAttr(t, "id")
+	case "sourceFile":
+		p.sourceFiles = append(p.sourceFiles, xmlAttr(t, "name"))
+	case "software":
+		entry := map[string]interface{}{"id": xmlAttr(t, "id")}
+		if version := xmlAttr(t, "version"); version != "" {
+			entry["version"] = version
+		}
+		p.software = append(p.software, entry)
+	case "softwareParam":
+		// mzML 1.0 names software with a softwareParam element
+		if len(p.software) > 0 {
+			p.software[len(p.software)-1]["name"] = psiMSName(xmlAttr(t, "accession"), xmlAttr(t, "name"))
+			if version := xmlAttr(t, "version"); version != "" {
+				p.software[len(p.software)-1]["version"] = version
+			}
+		}
+	case "instrumentConfiguration":
+		p.configs = append(p.configs, &msInstrumentConfig{})
+	case "run":
+		p.runID = xmlAttr(t, "id")
+		p.startTime = xmlAttr(t, "startTimeStamp")
+	case "spectrum":
+		p.flushSpectrum()
+		p.spectrum = &msSpectrum{}
+		if m := mzMLScanNumberPattern.FindStringSubmatch(xmlAttr(t, "id")); m != nil {
+			p.spectrum.scan, _ = strconv.Atoi(m[1])
+			p.spectrum.hasScan = true
+		}
+	case "cvParam":
+		p.param(cvParam{
+			accession:     xmlAttr(t, "accession"),
+			name:          xmlAttr(t, "name"),
+			value:         xmlAttr(t, "value"),
+			unitAccession: xmlAttr(t, "unitAccession"),
+		})
+	case "referenceableParamGroupRef":
+		for _, param := range p.paramGroups[xmlAttr(t, "ref")] {
+			p.param(param)
+		}
+	}
+}
+
+// end handles a closing element.
+func (p *mzMLParser) end(name string) {
+	switch name {
+	case "referenceableParamGroup":
+		p.groupID = ""
+	case "spectrum":
+		p.flushSpectrum()
+	}
+}
+
+// flushSpectrum adds the current spectrum to the run summary.
+func (p *mzMLParser) flushSpectrum() {
+	if p.spectrum != nil {
+		p.summary.add(p.spectrum)
+		p.spectrum = nil
+	}
+}
+
+// param dispatches a cvParam by the element it appears in.
+func (p *mzMLParser) param(param cvParam) {
+	if p.groupID != "" {
+		p.paramGroups[p.groupID] = append(p.paramGroups[p.groupID], param)
+		return
+	}
+
+	if p.spectrum != nil {
+		p.spectrumParam(param)
+		return
+	}
+
+	name := psiMSName(param.accession, param.name)
+	if p.parent() == "software" {
+		if len(p.software) > 0 {
+			p.software[len(p.software)-1]["name"] = name
+		}
+		return
+	}
+
+	if len(p.configs) == 0 || !p.inElement("instrumentConfiguration") {
+		return
+	}
+	config := p.configs[len(p.configs)-1]
+
+	// Component type terms have no value; properties such as resolution do
+	switch p.parent() {
+	case "source":
+		if param.value == "" {
+			config.sources = appendUnique(config.sources, name)
+		}
+	case "analyzer":
+		if param.value == "" {
+			config.analyzers = appendUnique(config.analyzers, name)
+		}
+	case "detector":
+		if param.value == "" {
+			config.detectors = appendUnique(config.detectors, name)
+		}
+	case "instrumentConfiguration":
+		switch {
+		case param.accession == psiInstrumentSerial:
+			config.serial = param.value
+		case param.value == "" && config.model == "":
+			config.model = name
+			config.modelAccession = param.accession
+		}
+	}
+}
+
+// inElement reports whether name is anywhere on the element stack.
+func (p *mzMLParser) inElement(name string) bool {
+	for _, n := range p.stack {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// spectrumParam records a cvParam inside a spectrum.
+func (p *mzMLParser) spectrumParam(param cvParam) {
+	s := p.spectrum
+	switch param.accession {
+	case psiMSLevel:
+		s.level, _ = strconv.Atoi(param.value)
+	case psiMS1Spectrum:
+		if s.level == 0 {
+			s.level = 1
+		}
+	case psiPositiveScan:
+		s.polarity = "positive"
+	case psiNegativeScan:
+		s.polarity = "negative"
+	case psiCentroidSpectrum:
+		s.representation = "centroid"
+	case psiProfileSpectrum:
+		s.representation = "profile"
+	case psiScanStartTime:
+		if value, err := strconv.ParseFloat(param.value, 64); err == nil && !s.hasRT {
+			switch param.unitAccession {
+			case psiUnitSecond:
+				value /= 60
+			case psiUnitMillisecond:
+				value /= 60000
+			}
+			s.rtMinutes, s.hasRT = value, true
+		}
+	case psiScanWindowLower:
+		if value, err := strconv.ParseFloat(param.value, 64); err == nil {
+			s.mzLow, s.hasMZ = value, true
+		}
+	case psiScanWindowUpper:
+		if value, err := strconv.ParseFloat(param.value, 64); err == nil {
+			s.mzHigh, s.hasMZ = value, true
+		}
+	default:
+		if method, ok := psiActivationTerms[param.accession]; ok {
+			s.activations = appendUnique(s.activations, method)
+		}
+	}
+}
+
+// metadata builds the output map.
+func (p *mzMLParser) metadata(filename string, indexed bool) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"format":          "mzML",
+		"file_name":       filename,
+		"extractor_name":  "mzml",
+		"schema_name":     "mzml_v1",
+		"instrument_type": "mass_spec",
+		"data_type":       "mass_spectrum",
+		"spectrum_index":  indexed,
+	}
+	if p.version != "" {
+		metadata["mzml_version"] = p.version
+	}
+	if p.runID != "" {
+		metadata["run_id"] = p.runID
+	}
+	if p.startTime != "" {
+		metadata["acquisition_date"] = p.startTime
+	}
+	if len(p.sourceFiles) > 0 {
+		metadata["source_file"] = p.sourceFiles[0]
+		metadata["source_files"] = p.sourceFiles
+	}
+
+	if len(p.configs) > 0 {
+		config := p.configs[0]
+		addMSInstrument(metadata, config.model, psiMSVendor(config.modelAccession, config.model), config.serial)
+		if config.modelAccession != "" {
+			metadata["instrument_model_accession"] = config.modelAccession
+		}
+
+		var sources, analyzers, detectors []string
+		for _, c := range p.configs {
+			for _, s := range c.sources {
+				sources = appendUnique(sources, s)
+			}
+			for _, a := range c.analyzers {
+				analyzers = appendUnique(analyzers, a)
+			}
+			for _, d := range c.detectors {
+				detectors = appendUnique(detectors, d)
+			}
+		}
+		addMSComponents(metadata, sources, analyzers, detectors)
+		if len(p.configs) > 1 {
+			metadata["instrument_configurations"] = len(p.configs)
+		}
+	}
+
+	addMSSoftware(metadata, p.software)
+
+	if p.summary.spectra == 0 && p.listCount > 0 {
+		metadata["total_spectra"] = p.listCount
+	}
+	p.summary.addTo(metadata)
+	return metadata
+}
+
+// mzXMLParser walks mzXML tokens.
+type mzXMLParser struct {
+	sawRoot     bool
+	instrument  map[string]string
+	software    []map[string]interface{}
+	sourceFiles []string
+	startTime   string
+	scanCount   int
+	summary     *msRunSummary
+}
+
+func newMzXMLParser() *mzXMLParser {
+	return &mzXMLParser{instrument: map[string]string{}, summary: newMSRunSummary()}
+}
+
+// parse walks tokens until EOF. When headerOnly is set it stops at the
+// first scan.
+func (p *mzXMLParser) parse(dec *xml.Decoder, headerOnly bool) error {
+	inInstrument := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "msRun":
+				p.sawRoot = true
+				p.scanCount, _ = strconv.Atoi(xmlAttr(t, "scanCount"))
+				p.startTime = xmlAttr(t, "startTime")
+			case "parentFile":
+				p.sourceFiles = append(p.sourceFiles, xmlAttr(t, "fileName"))
+			case "msInstrument":
+				inInstrument = true
+			case "msManufacturer", "msModel", "msIonisation", "msMassAnalyzer", "msDetector", "msResolution":
+				if inInstrument {
+					p.instrument[t.Name.Local] = xmlAttr(t, "value")
+				}
+			case "software":
+				entry := map[string]interface{}{"name": xmlAttr(t, "name")}
+				if version := xmlAttr(t, "version"); version != "" {
+					entry["version"] = version
+				}
+				if kind := xmlAttr(t, "type"); kind != "" {
+					entry["type"] = kind
+				}
+				p.software = append(p.software, entry)
+			case "scan":
+				if headerOnly {
+					return nil
+				}
+				p.summary.add(mzXMLScan(t))
+			case "peaks":
+				if err := dec.Skip(); err != nil {
+					return err
+				}
+			case "precursorMz":
+				p.addActivation(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "msInstrument" {
+				inInstrument = false
+			}
+		}
+	}
+}
+
+// parseIndexedScan reads the scan element at the start of an indexed chunk.
+func (p *mzXMLParser) parseIndexedScan(dec *xml.Decoder) {
+	seen := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch t.Name.Local {
+		case "scan":
+			// A nested child scan has its own index entry
+			if seen {
+				return
+			}
+			p.summary.add(mzXMLScan(t))
+			seen = true
+		case "precursorMz":
+			p.addActivation(t)
+		case "peaks":
+			return
+		}
+	}
+}
+
+// addActivation records the fragmentation method of a precursor.
+func (p *mzXMLParser) addActivation(t xml.StartElement) {
+	if method := xmlAttr(t, "activationMethod"); method != "" {
+		p.summary.activations = appendUnique(p.summary.activations, strings.ToUpper(method))
+	}
+}
+
+// mzXMLScan converts scan attributes to a spectrum summary.
+func mzXMLScan(t xml.StartElement) *msSpectrum {
+	s := &msSpectrum{}
+	s.level, _ = strconv.Atoi(xmlAttr(t, "msLevel"))
+	if num, err := strconv.Atoi(xmlAttr(t, "num")); err == nil {
+		s.scan, s.hasScan = num, true
+	}
+	switch xmlAttr(t, "polarity") {
+	case "+":
+		s.polarity = "positive"
+	case "-":
+		s.polarity = "negative"
+	}
+	switch xmlAttr(t, "centroided") {
+	case "1":
+		s.representation = "centroid"
+	case "0":
+		s.representation = "profile"
+	}
+	if seconds, ok := parseXSDuration(xmlAttr(t, "retentionTime")); ok {
+		s.rtMinutes, s.hasRT = seconds/60, true
+	}
+
+	// Prefer the acquisition window over the observed peak range
+	low, high := xmlAttr(t, "startMz"), xmlAttr(t, "endMz")
+	if low == "" || high == "" {
+		low, high = xmlAttr(t, "lowMz"), xmlAttr(t, "highMz")
+	}
+	lo, errLo := strconv.ParseFloat(low, 64)
+	hi, errHi := strconv.ParseFloat(high, 64)
+	if errLo == nil && errHi == nil {
+		s.mzLow, s.mzHigh, s.hasMZ = lo, hi, true
+	}
+	return s
+}
+
+// metadata builds the output map.
+func (p *mzXMLParser) metadata(filename string, indexed bool) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"format":          "mzXML",
+		"file_name":       filename,
+		"extractor_name":  "mzxml",
+		"schema_name":     "mzxml_v1",
+		"instrument_type": "mass_spec",
+		"data_type":       "mass_spectrum",
+		"spectrum_index":  indexed,
+	}
+	if len(p.sourceFiles) > 0 {
+		metadata["source_file"] = p.sourceFiles[0]
+		metadata["source_files"] = p.sourceFiles
+	}
+
+	model := p.instrument["msModel"]
+	vendor := p.instrument["msManufacturer"]
+	if vendor == "" {
+		vendor = psiMSVendor("", model)
+	}
+	addMSInstrument(metadata, model, vendor, "")
+
+	var sources, analyzers, detectors []string
+	sources = appendUnique(sources, p.instrument["msIonisation"])
+	analyzers = appendUnique(analyzers, p.instrument["msMassAnalyzer"])
+	detectors = appendUnique(detectors, p.instrument["msDetector"])
+	addMSComponents(metadata, sources, analyzers, detectors)
+	if resolution := p.instrument["msResolution"]; resolution != "" {
+		metadata["resolution"] = resolution
+	}
+
+	addMSSoftware(metadata, p.software)
+
+	if p.summary.spectra == 0 && p.scanCount > 0 {
+		metadata["total_spectra"] = p.scanCount
+	}
+	p.summary.addTo(metadata)
+	return metadata
+}
+
+// addMSInstrument adds instrument identification fields.
+func addMSInstrument(metadata map[string]interface{}, model, vendor, serial string) {
+	if model != "" {
+		metadata["instrument_model"] = model
+	}
+	if vendor != "" {
+		metadata["manufacturer"] = vendor
+	}
+	if serial != "" {
+		metadata["serial_number"] = serial
+	}
+}
+
+// addMSComponents adds ionization, analyzer and detector fields.
+func addMSComponents(metadata map[string]interface{}, sources, analyzers, detectors []string) {
+	if len(sources) > 0 {
+		metadata["ionization_mode"] = strings.Join(sources, ", ")
+	}
+	if len(analyzers) > 0 {
+		metadata["mass_analyzer"] = strings.Join(analyzers, ", ")
+		metadata["mass_analyzers"] = analyzers
+	}
+	if len(detectors) > 0 {
+		metadata["detector_types"] = detectors
+	}
+}
+
+// addMSSoftware adds the software list; the first entry is the acquisition
+// software in files written by vendor converters.
+func addMSSoftware(metadata map[string]interface{}, software []map[string]interface{}) {
+	if len(software) == 0 {
+		return
+	}
+	for _, entry := range software {
+		if entry["name"] == nil || entry["name"] == "" {
+			entry["name"] = entry["id"]
+		}
+	}
+	metadata["software"] = software
+	metadata["software_name"] = software[0]["name"]
+	if version, ok := software[0]["version"]; ok {
+		metadata["software_version"] = version
+	}
+}
+
+func newMSRunSummary() *msRunSummary {
+	return &msRunSummary{
+		byLevel:         map[int]int{},
+		polarities:      map[string]bool{},
+		representations: map[string]bool{},
+	}
+}
+
+// add folds one spectrum into the summary.
+func (s *msRunSummary) add(sp *msSpectrum) {
+	s.spectra++
+	s.byLevel[sp.level]++
+	if sp.polarity != "" {
+		s.polarities[sp.polarity] = true
+	}
+	if sp.representation != "" {
+		s.representations[sp.representation] = true
+	}
+	for _, method := range sp.activations {
+		s.activations = appendUnique(s.activations, method)
+	}
+	if sp.hasRT {
+		if !s.hasRT || sp.rtMinutes < s.rtMin {
+			s.rtMin = sp.rtMinutes
+		}
+		if !s.hasRT || sp.rtMinutes > s.rtMax {
+			s.rtMax = sp.rtMinutes
+		}
+		s.hasRT = true
+	}
+	if sp.hasMZ {
+		if !s.hasMZ || sp.mzLow < s.mzMin {
+			s.mzMin = sp.mzLow
+		}
+		if !s.hasMZ || sp.mzHigh > s.mzMax {
+			s.mzMax = sp.mzHigh
+		}
+		s.hasMZ = true
+	}
+	if sp.hasScan {
+		if !s.hasScan || sp.scan < s.scanFirst {
+			s.scanFirst = sp.scan
+		}
+		if !s.hasScan || sp.scan > s.scanLast {
+			s.scanLast = sp.scan
+		}
+		s.hasScan = true
+	}
+}
+
+// addTo adds the run summary to metadata.
+func (s *msRunSummary) addTo(metadata map[string]interface{}) {
+	if s.spectra == 0 {
+		return
+	}
+
+	metadata["total_spectra"] = s.spectra
+	metadata["ms1_spectra"] = s.byLevel[1]
+	metadata["ms2_spectra"] = s.byLevel[2]
+	byLevel := make(map[string]int, len(s.byLevel))
+	for level, count := range s.byLevel {
+		key := strconv.Itoa(level)
+		if level == 0 {
+			key = "unknown"
+		}
+		byLevel[key] = count
+	}
+	metadata["spectra_by_ms_level"] = byLevel
+
+	if value := summarizeModes(s.polarities); value != "" {
+		metadata["polarity"] = value
+	}
+	if value := summarizeModes(s.representations); value != "" {
+		metadata["spectrum_representation"] = value
+	}
+	if len(s.activations) > 0 {
+		metadata["fragmentation_methods"] = s.activations
+	}
+	if s.hasRT {
+		metadata["rt_start_min"] = s.rtMin
+		metadata["rt_end_min"] = s.rtMax
+		metadata["run_time"] = s.rtMax
+	}
+	if s.hasMZ {
+		metadata["mz_range_min"] = s.mzMin
+		metadata["mz_range_max"] = s.mzMax
+		metadata["scan_range"] = fmt.Sprintf("%g-%g m/z", s.mzMin, s.mzMax)
+	}
+	if s.hasScan {
+		metadata["first_scan"] = s.scanFirst
+		metadata["last_scan"] = s.scanLast
+	}
+}
+
+// summarizeModes returns the single mode seen, or "mixed".
+func summarizeModes(modes map[string]bool) string {
+	switch len(modes) {
+	case 0:
+		return ""
+	case 1:
+		for mode := range modes {
+			return mode
+		}
+	}
+	return "mixed"
+}
+
+// parseXSDuration parses an xs:duration such as "PT12.5S" into seconds.
+func parseXSDuration(value string) (float64, bool) {
+	m := xsDurationPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil || value == "" {
+		return 0, false
+	}
+	var seconds float64
+	for i, scale := range []float64{86400, 3600, 60, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, false
+		}
+		seconds += v * scale
+	}
+	if strings.HasPrefix(value, "-") {
+		seconds = -seconds
+	}
+	return seconds, true
+}
+
+// newMSXMLDecoder returns an XML decoder that also accepts the Latin-1
+// encoding declared by many mzXML writers.
+func newMSXMLDecoder(r io.Reader) *xml.Decoder {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "iso-8859-1", "latin1", "latin-1", "us-ascii", "ascii":
+			return &latin1Reader{r: bufio.NewReader(input)}, nil
+		}
+		return nil, fmt.Errorf("unsupported XML encoding %q", charset)
+	}
+	return dec
+}
+
+// latin1Reader converts ISO-8859-1 bytes to UTF-8.
+type latin1Reader struct {
+	r       *bufio.Reader
+	pending []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(l.pending) > 0 {
+			c := copy(p[n:], l.pending)
+			l.pending = l.pending[c:]
+			n += c
+			continue
+		}
+		b, err := l.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b < 0x80 {
+			p[n] = b
+			n++
+			continue
+		}
+		l.pending = utf8.AppendRune(nil, rune(b))
+	}
+	return n, nil
+}
+
+// xmlAttr returns the value of the named attribute.
+func xmlAttr(t xml.StartElement, name string) string {
+	for _, attr := range t.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,333 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMzMLHeader = `<?xml version="1.0" encoding="utf-8"?>
+<indexedmzML xmlns="http://psi.hupo.org/ms/mzml">
+<mzML xmlns="http://psi.hupo.org/ms/mzml" version="1.1.0" id="sample">
+  <fileDescription>
+    <fileContent><cvParam cvRef="MS" accession="MS:1000579" name="MS1 spectrum" value=""/></fileContent>
+    <sourceFileList count="1">
+      <sourceFile id="RAW1" name="sample.raw" location="file:///data"/>
+    </sourceFileList>
+  </fileDescription>
+  <referenceableParamGroupList count="1">
+    <referenceableParamGroup id="CommonInstrumentParams">
+      <cvParam cvRef="MS" accession="MS:1002523" name="Q Exactive HF" value=""/>
+      <cvParam cvRef="MS" accession="MS:1000529" name="instrument serial number" value="SN03215"/>
+    </referenceableParamGroup>
+  </referenceableParamGroupList>
+  <softwareList count="2">
+    <software id="Xcalibur" version="4.1.31.9"><cvParam cvRef="MS" accession="MS:1000532" name="Xcalibur" value=""/></software>
+    <software id="pwiz" version="3.0.21"><cvParam cvRef="MS" accession="MS:1000615" name="ProteoWizard software" value=""/></software>
+  </softwareList>
+  <instrumentConfigurationList count="1">
+    <instrumentConfiguration id="IC1">
+      <referenceableParamGroupRef ref="CommonInstrumentParams"/>
+      <componentList count="3">
+        <source order="1"><cvParam cvRef="MS" accession="MS:1000073" name="ESI" value=""/></source>
+        <analyzer order="2">
+          <cvParam cvRef="MS" accession="MS:1000081" name="quadrupole" value=""/>
+          <cvParam cvRef="MS" accession="MS:1000028" name="detector resolution" value="120000"/>
+        </analyzer>
+        <analyzer order="3"><cvParam cvRef="MS" accession="MS:1000484" name="orbitrap" value=""/></analyzer>
+        <detector order="4"><cvParam cvRef="MS" accession="MS:1000624" name="inductive detector" value=""/></detector>
+      </componentList>
+    </instrumentConfiguration>
+  </instrumentConfigurationList>
+  <run id="sample_run" defaultInstrumentConfigurationRef="IC1" startTimeStamp="2025-03-14T09:26:53Z">
+    <spectrumList count="%d" defaultDataProcessingRef="pwiz_processing">
+`
+
+// testMzMLSpectrum formats one spectrum; level 2 spectra get an HCD precursor.
+func testMzMLSpectrum(index, level int, rtSeconds float64) string {
+	spectrumType, precursor := "MS:1000579\" name=\"MS1 spectrum", ""
+	if level == 2 {
+		spectrumType = "MS:1000580\" name=\"MSn spectrum"
+		precursor = `<precursorList count="1"><precursor><activation>
+            <cvParam cvRef="MS" accession="MS:1000422" name="beam-type collision-induced dissociation" value=""/>
+          </activation></precursor></precursorList>`
+	}
+	return fmt.Sprintf(`      <spectrum index="%d" id="controllerType=0 controllerNumber=1 scan=%d" defaultArrayLength="2">
+        <cvParam cvRef="MS" accession="MS:1000511" name="ms level" value="%d"/>
+        <cvParam cvRef="MS" accession="%s" value=""/>
+        <cvParam cvRef="MS" accession="MS:1000130" name="positive scan" value=""/>
+        <cvParam cvRef="MS" accession="MS:1000127" name="centroid spectrum" value=""/>
+        <scanList count="1"><scan>
+          <cvParam cvRef="MS" accession="MS:1000016" name="scan start time" value="%g" unitCvRef="UO" unitAccession="UO:0000010" unitName="second"/>
+          <scanWindowList count="1"><scanWindow>
+            <cvParam cvRef="MS" accession="MS:1000501" name="scan window lower limit" value="%d"/>
+            <cvParam cvRef="MS" accession="MS:1000500" name="scan window upper limit" value="2000"/>
+          </scanWindow></scanWindowList>
+        </scan></scanList>
+        %s
+        <binaryDataArrayList count="1"><binaryDataArray encodedLength="24"><binary>AAAAAAAAAAAAAAAAAAAAAAAAAAAA</binary></binaryDataArray></binaryDataArrayList>
+      </spectrum>
+`, index, index+1, level, spectrumType, rtSeconds, 350-50*(level-1), precursor)
+}
+
+// buildTestMzML returns an indexedmzML document with one MS1 and two MS2 spectra.
+func buildTestMzML(withIndex bool) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(testMzMLHeader, 3))
+	var offsets []int
+	for i, level := range []int{1, 2, 2} {
+		offsets = append(offsets, b.Len()+strings.Index(testMzMLSpectrum(i, level, 0), "<spectrum"))
+		b.WriteString(testMzMLSpectrum(i, level, 60+float64(i)*30))
+	}
+	b.WriteString("    </spectrumList>\n  </run>\n</mzML>\n")
+	if withIndex {
+		indexOffset := b.Len()
+		b.WriteString(`<indexList count="1"><index name="spectrum">`)
+		for i, offset := range offsets {
+			b.WriteString(fmt.Sprintf(`<offset idRef="scan=%d">%d</offset>`, i+1, offset))
+		}
+		b.WriteString(fmt.Sprintf("</index></indexList>\n<indexListOffset>%d</indexListOffset>\n", indexOffset))
+	}
+	b.WriteString("</indexedmzML>\n")
+	return b.String()
+}
+
+func checkMzMLFields(t *testing.T, metadata map[string]interface{}) {
+	t.Helper()
+	checks := map[string]interface{}{
+		"format":                  "mzML",
+		"mzml_version":            "1.1.0",
+		"instrument_model":        "Q Exactive HF",
+		"manufacturer":            "Thermo Fisher Scientific",
+		"serial_number":           "SN03215",
+		"ionization_mode":         "electrospray ionization",
+		"mass_analyzer":           "quadrupole, orbitrap",
+		"software_name":           "Xcalibur",
+		"software_version":        "4.1.31.9",
+		"acquisition_date":        "2025-03-14T09:26:53Z",
+		"source_file":             "sample.raw",
+		"total_spectra":           3,
+		"ms1_spectra":             1,
+		"ms2_spectra":             2,
+		"polarity":                "positive",
+		"spectrum_representation": "centroid",
+		"first_scan":              1,
+		"last_scan":               3,
+		"scan_range":              "300-2000 m/z",
+	}
+	for key, want := range checks {
+		if metadata[key] != want {
+			t.Errorf("%s = %v, want %v", key, metadata[key], want)
+		}
+	}
+	if got := metadata["rt_start_min"].(float64); math.Abs(got-1) > 1e-9 {
+		t.Errorf("rt_start_min = %v, want 1", got)
+	}
+	if got := metadata["rt_end_min"].(float64); math.Abs(got-2) > 1e-9 {
+		t.Errorf("rt_end_min = %v, want 2", got)
+	}
+	methods := metadata["fragmentation_methods"].([]string)
+	if len(methods) != 1 || methods[0] != "HCD" {
+		t.Errorf("fragmentation_methods = %v, want [HCD]", methods)
+	}
+}
+
+func TestMzMLExtractor_CanHandle(t *testing.T) {
+	extractor := &MzMLExtractor{}
+	for _, name := range []string{"run.mzML", "run.mzml", "run.mzXML"} {
+		if !extractor.CanHandle(name) {
+			t.Errorf("CanHandle(%q) = false, want true", name)
+		}
+	}
+	if extractor.CanHandle("run.raw") {
+		t.Error("CanHandle() should reject vendor raw files")
+	}
+}
+
+func TestMzMLExtractor_Stream(t *testing.T) {
+	extractor := &MzMLExtractor{}
+	metadata, err := extractor.ExtractFromReader(strings.NewReader(buildTestMzML(false)), "sample.mzML")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	checkMzMLFields(t, metadata)
+	if metadata["spectrum_index"] != false {
+		t.Error("spectrum_index should be false when streaming")
+	}
+}
+
+func TestMzMLExtractor_Indexed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample.mzML")
+	if err := os.WriteFile(path, []byte(buildTestMzML(true)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extractor := &MzMLExtractor{}
+	metadata, err := extractor.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if metadata["spectrum_index"] != true {
+		t.Fatal("spectrum_index should be true for indexed mzML")
+	}
+	checkMzMLFields(t, metadata)
+}
+
+func TestMzMLExtractor_BadIndexFallsBack(t *testing.T) {
+	doc := buildTestMzML(true)
+	doc = strings.Replace(doc, "<indexListOffset>", "<indexListOffset>9", 1)
+	path := filepath.Join(t.TempDir(), "sample.mzML")
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := (&MzMLExtractor{}).Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if metadata["spectrum_index"] != false || metadata["total_spectra"] != 3 {
+		t.Errorf("spectrum_index = %v, total_spectra = %v", metadata["spectrum_index"], metadata["total_spectra"])
+	}
+}
+
+func TestMzMLExtractor_MzXML(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<mzXML xmlns="http://sashimi.sourceforge.net/schema_revision/mzXML_3.2">
+  <msRun scanCount="3" startTime="PT0.5S" endTime="PT2M">
+    <parentFile fileName="file://C:/data/sample.raw" fileType="RAWData" fileSha1="0"/>
+    <msInstrument>
+      <msManufacturer category="msManufacturer" value="Thermo Scientific"/>
+      <msModel category="msModel" value="Orbitrap Fusion Lumos"/>
+      <msIonisation category="msIonisation" value="nanoelectrospray"/>
+      <msMassAnalyzer category="msMassAnalyzer" value="orbitrap"/>
+      <msDetector category="msDetector" value="inductive detector"/>
+      <software type="acquisition" name="Xcalibur" version="4.3"/>
+    </msInstrument>
+    <scan num="1" msLevel="1" polarity="+" retentionTime="PT30S" startMz="375" endMz="1500" centroided="0" peaksCount="1">
+      <peaks precision="32" byteOrder="network" compressionType="none">AAAAAAAAAAA=</peaks>
+      <scan num="2" msLevel="2" polarity="+" retentionTime="PT31.5S" lowMz="110" highMz="900" centroided="1" peaksCount="1">
+        <precursorMz precursorCharge="2" activationMethod="HCD">445.12</precursorMz>
+        <peaks precision="32" byteOrder="network" compressionType="none">AAAAAAAAAAA=</peaks>
+      </scan>
+    </scan>
+    <scan num="3" msLevel="2" polarity="+" retentionTime="PT1M2S" lowMz="120" highMz="950" centroided="1" peaksCount="1">
+      <precursorMz precursorCharge="3" activationMethod="CID">512.3</precursorMz>
+      <peaks precision="32" byteOrder="network" compressionType="none">AAAAAAAAAAA=</peaks>
+    </scan>
+  </msRun>
+</mzXML>`
+
+	extractor := &MzMLExtractor{}
+	metadata, err := extractor.ExtractFromReader(strings.NewReader(doc), "sample.mzXML")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	checks := map[string]interface{}{
+		"format":                  "mzXML",
+		"manufacturer":            "Thermo Scientific",
+		"instrument_model":        "Orbitrap Fusion Lumos",
+		"ionization_mode":         "nanoelectrospray",
+		"mass_analyzer":           "orbitrap",
+		"software_name":           "Xcalibur",
+		"total_spectra":           3,
+		"ms1_spectra":             1,
+		"ms2_spectra":             2,
+		"spectrum_representation": "mixed",
+		"scan_range":              "110-1500 m/z",
+		"last_scan":               3,
+	}
+	for key, want := range checks {
+		if metadata[key] != want {
+			t.Errorf("%s = %v, want %v", key, metadata[key], want)
+		}
+	}
+	if got := metadata["rt_end_min"].(float64); math.Abs(got-62.0/60) > 1e-9 {
+		t.Errorf("rt_end_min = %v, want %v", got, 62.0/60)
+	}
+	if methods := metadata["fragmentation_methods"].([]string); len(methods) != 2 {
+		t.Errorf("fragmentation_methods = %v, want [HCD CID]", methods)
+	}
+
+	// The root element, not the name, selects the parser
+	path := filepath.Join(t.TempDir(), "run_0042")
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, extract := range map[string]func() (map[string]interface{}, error){
+		"Extract": func() (map[string]interface{}, error) { return extractor.Extract(path) },
+		"ExtractFromReader": func() (map[string]interface{}, error) {
+			return extractor.ExtractFromReader(strings.NewReader(doc), "run.mzML")
+		},
+	} {
+		metadata, err := extract()
+		if err != nil || metadata["format"] != "mzXML" {
+			t.Errorf("%s() of mzXML without .mzXML name = %v, %v", name, metadata["format"], err)
+		}
+	}
+}
+
+func TestMzMLExtractor_Sniff(t *testing.T) {
+	extractor := &MzMLExtractor{}
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{`<?xml version="1.0"?><!-- run 42 --><indexedmzML xmlns="http://psi.hupo.org/ms/mzml"><mzML>`, 95},
+		{`<?xml version="1.0" encoding="ISO-8859-1"?><mzXML xmlns="http://sashimi.sourceforge.net/schema_revision/mzXML_3.2">`, 95},
+		{`<?xml version="1.0"?><workflow><input><mzML/></input></workflow>`, 0},
+	}
+	for _, tt := range tests {
+		if got, _ := extractor.Sniff(&SniffInput{Header: []byte(tt.header)}); got != tt.want {
+			t.Errorf("Sniff(%q) = %d, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMzMLExtractor_Invalid(t *testing.T) {
+	extractor := &MzMLExtractor{}
+	if _, err := extractor.ExtractFromReader(strings.NewReader("<html><body/></html>"), "page.mzML"); err == nil {
+		t.Error("ExtractFromReader() should return error for non-mzML XML")
+	}
+	if _, err := extractor.ExtractFromReader(strings.NewReader("<mzML><run"), "broken.mzML"); err == nil {
+		t.Error("ExtractFromReader() should return error for truncated XML")
+	}
+}
+
+func TestParseXSDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"PT12.5S", 12.5, true},
+		{"PT2M3S", 123, true},
+		{"PT1H", 3600, true},
+		{"P1DT1S", 86401, true},
+		{"12.5", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseXSDuration(tt.in)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseXSDuration(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
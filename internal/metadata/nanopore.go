@@ -0,0 +1,550 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # Oxford Nanopore Formats
+//
+// This file implements run metadata extraction for Oxford Nanopore
+// Technologies (ONT) raw signal files written by MinKNOW on MinION, GridION,
+// PromethION and P2 devices. The low-level POD5 reader lives in
+// pod5_format.go; FAST5 files are read with the HDF5 reader.
+//
+// ## File Format Overview
+//
+// POD5 (.pod5) is a container of Apache Arrow IPC files between a signature
+// and a FlatBuffers footer that lists the embedded tables:
+//   - Run info table: one row per acquisition with flow cell, kit, device,
+//     sample, protocol and software columns, plus the MinKNOW tracking_id
+//     and context_tags maps
+//   - Reads table: one row per read (only the row count is used)
+//   - Signal table: compressed raw signal (never read)
+//
+// FAST5 (.fast5) is HDF5. Multi-read files hold one read_<uuid> group per
+// read; single-read files hold a UniqueGlobalKey group. Either way the run
+// metadata is stored as attributes of the tracking_id, context_tags and
+// channel_id subgroups, and basecalled files add Analyses/Basecall_*
+// groups naming the basecaller.
+//
+// ## Field Mapping
+//
+// Both formats are mapped into SequencingMetadata with platform "ONT":
+//   - device_type → model (MinION, GridION, PromethION, P2 Solo)
+//   - host_product_serial_number → serial number
+//   - run_id (acquisition ID), flow_cell_id, sample_id, exp_start_time
+//   - sequencing_kit → library kit
+//
+// Device position, flow cell product code, protocol run ID, experiment
+// name, basecaller, basecall model and sample rate are added alongside.
+//
+// ## References and Sources
+//
+// POD5 file format specification:
+// https://github.com/nanoporetech/pod5-file-format/blob/master/docs/SPECIFICATION.md
+//
+// Apache Arrow IPC format:
+// https://arrow.apache.org/docs/format/Columnar.html#serialization-and-interprocess-communication-ipc
+//
+// ONT FAST5 API schema:
+// https://github.com/nanoporetech/ont_fast5_api
+//
+// ## Limitations
+//
+//   - Only the first acquisition's run info is mapped; the others are
+//     listed by acquisition ID
+//   - Compressed Arrow record batches are not supported
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pod5MaxRunInfos limits the run info rows read from one POD5 file.
+const pod5MaxRunInfos = 1000
+
+// nanoporeDeviceModels maps MinKNOW device types to product names.
+var nanoporeDeviceModels = map[string]string{
+	"minion":      "MinION",
+	"minion_mk1b": "MinION Mk1B",
+	"minion_mk1c": "MinION Mk1C",
+	"mk1c":        "MinION Mk1C",
+	"minion_mk1d": "MinION Mk1D",
+	"gridion":     "GridION",
+	"promethion":  "PromethION",
+	"p2_solo":     "P2 Solo",
+	"p2_integ":    "P2i",
+}
+
+// POD5Extractor extracts run metadata from ONT POD5 files.
+type POD5Extractor struct{}
+
+// Name returns the extractor name.
+func (e *POD5Extractor) Name() string {
+	return "POD5"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *POD5Extractor) SupportedFormats() []string {
+	return []string{".pod5"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *POD5Extractor) CanHandle(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".pod5"
+}
+
+// Sniff recognises the POD5 file signature.
+func (e *POD5Extractor) Sniff(in *SniffInput) (int, string) {
+	if in.hasPrefix(pod5Signature) {
+		return 100, "POD5 signature"
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from a POD5 file.
+func (e *POD5Extractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	metadata, err := e.extract(f, info.Size(), filepath)
+	if err != nil {
+		return nil, err
+	}
+	metadata["file_size"] = info.Size()
+	return metadata, nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *POD5Extractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return e.extract(bytes.NewReader(data), int64(len(data)), filename)
+}
+
+// extract reads the footer, counts reads and maps the run info table.
+func (e *POD5Extractor) extract(r io.ReaderAt, size int64, filename string) (map[string]interface{}, error) {
+	footer, err := readPOD5Footer(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid POD5 file: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "POD5",
+		"file_name":       filepath.Base(filename),
+		"extractor_name":  "pod5",
+		"schema_name":     "pod5_v1",
+		"instrument_type": "sequencing",
+		"data_type":       "nanopore_signal",
+	}
+	for key, value := range map[string]string{
+		"file_identifier":  footer.fileIdentifier,
+		"writing_software": footer.software,
+		"pod5_version":     footer.version,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+
+	if reads, ok := footer.find(pod5ContentReads); ok {
+		if table, err := openArrowFile(r, reads.offset, reads.length); err == nil {
+			if n, err := table.rows(); err == nil {
+				metadata["read_count"] = n
+			}
+		}
+	}
+
+	runInfo, ok := footer.find(pod5ContentRunInfo)
+	if !ok {
+		return metadata, nil
+	}
+	table, err := openArrowFile(r, runInfo.offset, runInfo.length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read POD5 run info: %w", err)
+	}
+	records, err := table.readRecords(pod5MaxRunInfos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read POD5 run info: %w", err)
+	}
+	if len(records) == 0 {
+		return metadata, nil
+	}
+
+	var acquisitions []string
+	for _, record := range records {
+		if id, ok := record["acquisition_id"].(string); ok {
+			acquisitions = append(acquisitions, id)
+		}
+	}
+	if len(acquisitions) > 1 {
+		metadata["acquisition_ids"] = acquisitions
+	}
+	metadata["run_info_count"] = len(records)
+	applyNanoporeRun(pod5RunInfo(records[0]), metadata)
+	return metadata, nil
+}
+
+// pod5RunInfo converts a run info row into MinKNOW tracking_id and
+// context_tags keys. Dedicated columns take precedence over the maps.
+func pod5RunInfo(record map[string]interface{}) map[string]string {
+	info := map[string]string{}
+	for _, column := range []string{"tracking_id", "context_tags"} {
+		if tags, ok := record[column].(map[string]interface{}); ok {
+			for key, value := range tags {
+				if s, ok := value.(string); ok {
+					info[key] = s
+				}
+			}
+		}
+	}
+
+	columns := map[string]string{
+		"acquisition_id":          "run_id",
+		"experiment_name":         "protocol_group_id",
+		"flow_cell_id":            "flow_cell_id",
+		"flow_cell_product_code":  "flow_cell_product_code",
+		"protocol_name":           "exp_script_name",
+		"protocol_run_id":         "protocol_run_id",
+		"sample_id":               "sample_id",
+		"sequencer_position":      "device_id",
+		"sequencer_position_type": "device_type",
+		"sequencing_kit":          "sequencing_kit",
+		"system_name":             "hostname",
+		"system_type":             "host_product_code",
+	}
+	for column, key := range columns {
+		if s, ok := record[column].(string); ok && s != "" {
+			info[key] = s
+		}
+	}
+	if software, ok := record["software"].(string); ok && software != "" {
+		info["acquisition_software"] = software
+	}
+	if rate, ok := record["sample_rate"].(int64); ok && rate > 0 {
+		info["sample_frequency"] = strconv.FormatInt(rate, 10)
+	}
+	if start, ok := record["acquisition_start_time"].(time.Time); ok && start.Unix() > 0 {
+		info["exp_start_time"] = start.Format(time.RFC3339Nano)
+	}
+	return info
+}
+
+// FAST5Extractor extracts run metadata from ONT FAST5 files.
+type FAST5Extractor struct{}
+
+// Name returns the extractor name.
+func (e *FAST5Extractor) Name() string {
+	return "FAST5"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *FAST5Extractor) SupportedFormats() []string {
+	return []string{".fast5"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *FAST5Extractor) CanHandle(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".fast5"
+}
+
+// Sniff recognises HDF5 files whose root holds nanopore read groups.
+func (e *FAST5Extractor) Sniff(in *SniffInput) (int, string) {
+	if !in.hasPrefix(hdf5Signature) {
+		return 0, ""
+	}
+	f, err := openHDF5(in.Reader, in.Size)
+	if err != nil {
+		return 0, ""
+	}
+	root, err := f.readObjectHeader(f.rootAddr)
+	if err != nil {
+		return 0, ""
+	}
+	links, err := f.groupLinks(root)
+	if err != nil {
+		return 0, ""
+	}
+	for _, link := range links {
+		if link.name == "UniqueGlobalKey" || strings.HasPrefix(link.name, "read_") {
+			return 95, fmt.Sprintf("HDF5 signature with nanopore group %s", link.name)
+		}
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from a FAST5 file.
+func (e *FAST5Extractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	metadata, err := e.extract(f, info.Size(), filepath)
+	if err != nil {
+		return nil, err
+	}
+	metadata["file_size"] = info.Size()
+	return metadata, nil
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *FAST5Extractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return e.extract(bytes.NewReader(data), int64(len(data)), filename)
+}
+
+// extract reads the run attributes of the first read group (multi-read
+// files) or of UniqueGlobalKey (single-read files). Only the root group's
+// links are listed, so files with many reads are not walked.
+func (e *FAST5Extractor) extract(r io.ReaderAt, size int64, filename string) (map[string]interface{}, error) {
+	f, err := openHDF5(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid FAST5 file: %w", err)
+	}
+	root, err := f.readObjectHeader(f.rootAddr)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid FAST5 file: %w", err)
+	}
+	rootLinks, err := f.groupLinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid FAST5 file: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "FAST5",
+		"file_name":       filepath.Base(filename),
+		"extractor_name":  "fast5",
+		"schema_name":     "fast5_v1",
+		"instrument_type": "sequencing",
+		"data_type":       "nanopore_signal",
+	}
+	rootAttrs := f.attributes(root)
+	if version := h5String(rootAttrs["file_version"]); version != "" {
+		metadata["fast5_version"] = version
+	}
+
+	var readGroup *h5Link
+	reads := 0
+	for i, link := range rootLinks {
+		if strings.HasPrefix(link.name, "read_") {
+			if readGroup == nil {
+				readGroup = &rootLinks[i]
+			}
+			reads++
+		}
+	}
+
+	baseLinks := rootLinks
+	info := map[string]string{}
+	switch {
+	case readGroup != nil:
+		metadata["file_type"] = "multi-read"
+		metadata["read_count"] = reads
+		msgs, err := f.readObjectHeader(readGroup.addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", readGroup.name, err)
+		}
+		if baseLinks, err = f.groupLinks(msgs); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", readGroup.name, err)
+		}
+		if runID := h5String(f.attributes(msgs)["run_id"]); runID != "" {
+			info["run_id"] = runID
+		}
+	default:
+		msgs, ok := fast5Group(f, rootLinks, "UniqueGlobalKey")
+		if !ok {
+			return nil, fmt.Errorf("not a valid FAST5 file: no read groups or UniqueGlobalKey")
+		}
+		metadata["file_type"] = "single-read"
+		metadata["read_count"] = 1
+		if baseLinks, err = f.groupLinks(msgs); err != nil {
+			return nil, fmt.Errorf("failed to read UniqueGlobalKey: %w", err)
+		}
+	}
+	if fileType := h5String(rootAttrs["file_type"]); fileType != "" {
+		metadata["file_type"] = fileType
+	}
+
+	for _, name := range []string{"channel_id", "context_tags", "tracking_id"} {
+		msgs, ok := fast5Group(f, baseLinks, name)
+		if !ok {
+			continue
+		}
+		for key, value := range f.attributes(msgs) {
+			if s := strings.TrimSpace(h5String(value)); s != "" {
+				info[key] = s
+			}
+		}
+	}
+	if info["sample_frequency"] == "" && info["sampling_rate"] != "" {
+		info["sample_frequency"] = info["sampling_rate"]
+	}
+
+	// Basecalled files: Analyses/Basecall_1D_000 (or Basecall_2D_*)
+	analysesLinks := baseLinks
+	if _, ok := fast5Group(f, baseLinks, "Analyses"); !ok {
+		analysesLinks = rootLinks
+	}
+	if msgs, ok := fast5Group(f, analysesLinks, "Analyses"); ok {
+		if links, err := f.groupLinks(msgs); err == nil {
+			sort.Slice(links, func(i, j int) bool { return links[i].name < links[j].name })
+			for _, link := range links {
+				if !strings.HasPrefix(link.name, "Basecall_") {
+					continue
+				}
+				if msgs, err := f.readObjectHeader(link.addr); err == nil {
+					attrs := f.attributes(msgs)
+					info["basecaller"] = strings.TrimSuffix(strings.TrimSpace(h5String(attrs["name"])), ".")
+					info["basecaller_version"] = strings.TrimSpace(h5String(attrs["version"]))
+				}
+				break
+			}
+		}
+	}
+
+	applyNanoporeRun(info, metadata)
+	return metadata, nil
+}
+
+// fast5Group reads the object header of the group linked as name.
+func fast5Group(f *hdf5File, links []h5Link, name string) ([]h5Message, bool) {
+	for _, link := range links {
+		if link.name == name && link.soft == "" && !link.external {
+			msgs, err := f.readObjectHeader(link.addr)
+			return msgs, err == nil && isGroup(msgs)
+		}
+	}
+	return nil, false
+}
+
+// applyNanoporeRun maps MinKNOW tracking_id and context_tags keys into
+// SequencingMetadata and adds the ONT-specific fields to metadata.
+func applyNanoporeRun(info map[string]string, metadata map[string]interface{}) {
+	deviceType := strings.ToLower(strings.ReplaceAll(info["device_type"], "-", "_"))
+	model := nanoporeDeviceModels[deviceType]
+	if model == "" {
+		model = info["device_type"]
+	}
+
+	sm := &SequencingMetadata{
+		Platform:        "ONT",
+		Model:           model,
+		SerialNumber:    info["host_product_serial_number"],
+		SoftwareVersion: info["version"],
+		RunID:           info["run_id"],
+		FlowcellID:      info["flow_cell_id"],
+		LibraryKit:      strings.ToUpper(info["sequencing_kit"]),
+		SampleID:        info["sample_id"],
+	}
+	if t, err := time.Parse(time.RFC3339Nano, info["exp_start_time"]); err == nil {
+		sm.RunDate = t.UTC()
+	}
+	sequencingFields(sm, metadata)
+	metadata["manufacturer"] = "Oxford Nanopore Technologies"
+
+	for key, source := range map[string]string{
+		"device_id":              "device_id",
+		"device_type":            "device_type",
+		"hostname":               "hostname",
+		"flow_cell_product_code": "flow_cell_product_code",
+		"sequencing_kit":         "sequencing_kit",
+		"protocol_run_id":        "protocol_run_id",
+		"experiment_name":        "protocol_group_id",
+		"protocol_name":          "exp_script_name",
+		"experiment_type":        "experiment_type",
+		"acquisition_software":   "acquisition_software",
+		"basecaller":             "basecaller",
+		"basecaller_version":     "basecaller_version",
+	} {
+		if value := info[source]; value != "" {
+			metadata[key] = value
+		}
+	}
+	if metadata["basecaller"] == nil && info["guppy_version"] != "" {
+		metadata["basecaller"] = "Guppy"
+		metadata["basecaller_version"] = info["guppy_version"]
+	}
+	if config := info["basecall_config_filename"]; config != "" {
+		metadata["basecall_model"] = strings.TrimSuffix(config, ".cfg")
+	}
+	if rate, err := strconv.ParseFloat(info["sample_frequency"], 64); err == nil && rate > 0 {
+		metadata["sample_rate_hz"] = int(rate)
+	}
+}
+
+// sequencingFields writes the non-empty fields of sm into metadata under
+// their JSON names.
+func sequencingFields(sm *SequencingMetadata, metadata map[string]interface{}) {
+	for key, value := range map[string]string{
+		"platform":         sm.Platform,
+		"model":            sm.Model,
+		"serial_number":    sm.SerialNumber,
+		"software_version": sm.SoftwareVersion,
+		"run_id":           sm.RunID,
+		"flowcell_id":      sm.FlowcellID,
+		"operator":         sm.Operator,
+		"library_id":       sm.LibraryID,
+		"library_kit":      sm.LibraryKit,
+		"read_type":        sm.ReadType,
+		"sample_id":        sm.SampleID,
+		"sample_name":      sm.SampleName,
+		"reference_genome": sm.ReferenceGenome,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	if sm.Model != "" {
+		metadata["instrument_model"] = sm.Model
+	}
+	for key, value := range map[string]int{
+		"lane":        sm.Lane,
+		"insert_size": sm.InsertSize,
+		"read_length": sm.ReadLength,
+	} {
+		if value != 0 {
+			metadata[key] = value
+		}
+	}
+	if !sm.RunDate.IsZero() {
+		metadata["run_date"] = sm.RunDate.Format(time.RFC3339)
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// FlatBuffers test objects. A testFBTable lists fields by id: nil for an
+// absent field, []byte for an inline scalar, or a string, table, vector or
+// struct vector for a referenced object. Objects are written after the
+// objects that reference them, since FlatBuffers offsets are unsigned.
+type testFBTable []interface{}
+
+type testFBVector []interface{}
+
+type testFBStructs struct {
+	n    int
+	data []byte
+}
+
+func testFBBuild(root testFBTable) []byte {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, uint32(testFBWrite(&buf, root)))
+	return buf
+}
+
+func testFBWrite(buf *[]byte, obj interface{}) int {
+	patch := func(slot int, child interface{}) {
+		pos := testFBWrite(buf, child)
+		binary.LittleEndian.PutUint32((*buf)[slot:], uint32(pos-slot))
+	}
+
+	switch o := obj.(type) {
+	case string:
+		pos := len(*buf)
+		*buf = append(join(*buf, le32(uint32(len(o))), []byte(o)), 0)
+		return pos
+	case testFBStructs:
+		pos := len(*buf)
+		*buf = join(*buf, le32(uint32(o.n)), o.data)
+		return pos
+	case testFBVector:
+		pos := len(*buf)
+		*buf = join(*buf, le32(uint32(len(o))), make([]byte, 4*len(o)))
+		for i, child := range o {
+			patch(pos+4+4*i, child)
+		}
+		return pos
+	case testFBTable:
+		offsets := make([]byte, 0, 2*len(o))
+		size := 4
+		for _, field := range o {
+			switch v := field.(type) {
+			case nil:
+				offsets = append(offsets, le16(0)...)
+			case []byte:
+				offsets = append(offsets, le16(uint16(size))...)
+				size += len(v)
+			default:
+				offsets = append(offsets, le16(uint16(size))...)
+				size += 4
+			}
+		}
+		vtable := len(*buf)
+		*buf = join(*buf, le16(uint16(4+len(offsets))), le16(uint16(size)), offsets)
+		pos := len(*buf)
+		*buf = join(*buf, le32(uint32(pos-vtable)))
+		slots := map[int]interface{}{}
+		for _, field := range o {
+			switch v := field.(type) {
+			case nil:
+			case []byte:
+				*buf = append(*buf, v...)
+			default:
+				slots[len(*buf)] = v
+				*buf = append(*buf, make([]byte, 4)...)
+			}
+		}
+		order := make([]int, 0, len(slots))
+		for slot := range slots {
+			order = append(order, slot)
+		}
+		sort.Ints(order)
+		for _, slot := range order {
+			patch(slot, slots[slot])
+		}
+		return pos
+	}
+	panic("unsupported flatbuffers test object")
+}
+
+// testArrowColumn is one Arrow field with its nodes and buffers in
+// pre-order.
+type testArrowColumn struct {
+	field   testFBTable
+	nodes   [][2]int64
+	buffers [][]byte
+}
+
+func testArrowField(name string, typeID byte, typ testFBTable, children ...interface{}) testFBTable {
+	field := testFBTable{name, []byte{1}, []byte{typeID}, typ, nil}
+	if len(children) > 0 {
+		field = append(field, testFBVector(children))
+	}
+	return field
+}
+
+func testArrowStrings(values []string) [][]byte {
+	offsets, data := le32(0), []byte{}
+	for _, v := range values {
+		data = append(data, v...)
+		offsets = append(offsets, le32(uint32(len(data)))...)
+	}
+	return [][]byte{nil, offsets, data}
+}
+
+func testArrowUtf8(name string, values ...string) testArrowColumn {
+	return testArrowColumn{
+		field:   testArrowField(name, arrowUtf8, testFBTable{}),
+		nodes:   [][2]int64{{int64(len(values)), 0}},
+		buffers: testArrowStrings(values),
+	}
+}
+
+func testArrowUint16(name string, value uint16) testArrowColumn {
+	return testArrowColumn{
+		field:   testArrowField(name, arrowInt, testFBTable{le32(16), []byte{0}}),
+		nodes:   [][2]int64{{1, 0}},
+		buffers: [][]byte{nil, le16(value)},
+	}
+}
+
+func testArrowTimestamp(name string, t time.Time) testArrowColumn {
+	return testArrowColumn{
+		field:   testArrowField(name, arrowTimestamp, testFBTable{le16(1), "UTC"}),
+		nodes:   [][2]int64{{1, 0}},
+		buffers: [][]byte{nil, le64(uint64(t.UnixMilli()))},
+	}
+}
+
+// testArrowStringMap is a one-row map<utf8, utf8> column.
+func testArrowStringMap(name string, values map[string]string) testArrowColumn {
+	var keys, vals []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vals = append(vals, values[k])
+	}
+	n := int64(len(keys))
+
+	entries := testArrowField("entries", arrowStruct, testFBTable{},
+		testArrowField("key", arrowUtf8, testFBTable{}),
+		testArrowField("value", arrowUtf8, testFBTable{}))
+	var buffers [][]byte
+	buffers = append(buffers, nil, join(le32(0), le32(uint32(n))), nil)
+	buffers = append(buffers, testArrowStrings(keys)...)
+	buffers = append(buffers, testArrowStrings(vals)...)
+	return testArrowColumn{
+		field:   testArrowField(name, arrowMap, testFBTable{[]byte{0}}, entries),
+		nodes:   [][2]int64{{1, 0}, {n, 0}, {n, 0}, {n, 0}},
+		buffers: buffers,
+	}
+}
+
+// buildTestArrowFile writes an Arrow IPC file with one record batch per
+// entry of batchRows; every batch repeats the same columns.
+func buildTestArrowFile(columns []testArrowColumn, batchRows ...int64) []byte {
+	buf := []byte("ARROW1\x00\x00")
+	var blocks []byte
+	for _, rows := range batchRows {
+		var body, nodes, buffers []byte
+		nodeCount, bufferCount := 0, 0
+		for _, c := range columns {
+			for _, node := range c.nodes {
+				nodes = join(nodes, le64(uint64(node[0])), le64(uint64(node[1])))
+				nodeCount++
+			}
+			for _, b := range c.buffers {
+				buffers = join(buffers, le64(uint64(len(body))), le64(uint64(len(b))))
+				body = pad8(append(body, b...))
+				bufferCount++
+			}
+		}
+		batch := testFBTable{le64(uint64(rows)), testFBStructs{nodeCount, nodes}, testFBStructs{bufferCount, buffers}}
+		message := pad8(testFBBuild(testFBTable{le16(4), []byte{arrowMsgRecordBatch}, batch, le64(uint64(len(body)))}))
+
+		offset := len(buf)
+		buf = join(buf, le32(0xFFFFFFFF), le32(uint32(len(message))), message, body)
+		blocks = join(blocks, le64(uint64(offset)), le32(uint32(8+len(message))), le32(0), le64(uint64(len(body))))
+	}
+
+	var fields testFBVector
+	for _, c := range columns {
+		fields = append(fields, c.field)
+	}
+	footer := testFBBuild(testFBTable{le16(4), testFBTable{le16(0), fields}, nil,
+		testFBStructs{len(batchRows), blocks}})
+	return join(buf, footer, le32(uint32(len(footer))), []byte(arrowMagic))
+}
+
+var testPOD5Marker = bytes.Repeat([]byte{0xA5}, 16)
+
+// buildTestPOD5 writes a POD5 file with a run info table and a reads table.
+func buildTestPOD5() []byte {
+	runInfo := buildTestArrowFile([]testArrowColumn{
+		testArrowUtf8("acquisition_id", "a1b2c3d4e5f6"),
+		testArrowTimestamp("acquisition_start_time", time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)),
+		testArrowStringMap("context_tags", map[string]string{
+			"basecall_config_filename": "dna_r10.4.1_e8.2_400bps_hac.cfg",
+			"experiment_type":          "genomic_dna",
+			"sample_frequency":         "5000",
+			"sequencing_kit":           "sqk-lsk114",
+		}),
+		testArrowUtf8("experiment_name", "exp_2024_03"),
+		testArrowUtf8("flow_cell_id", "PAO12345"),
+		testArrowUtf8("flow_cell_product_code", "FLO-PRO114M"),
+		testArrowUtf8("protocol_run_id", "5f0c9d0e-1234-4abc-8def-001122334455"),
+		testArrowUtf8("sample_id", "HG002"),
+		testArrowUint16("sample_rate", 5000),
+		testArrowUtf8("sequencer_position", "1A"),
+		testArrowUtf8("sequencer_position_type", "promethion"),
+		testArrowUtf8("sequencing_kit", "sqk-lsk114"),
+		testArrowUtf8("software", "MinKNOW 24.02.6 (Bream 7.9.8, Core 5.9.12, Dorado 7.3.11)"),
+		testArrowStringMap("tracking_id", map[string]string{
+			"host_product_serial_number": "PC24B123",
+			"version":                    "5.9.12",
+		}),
+	}, 1)
+	reads := buildTestArrowFile([]testArrowColumn{testArrowUtf8("read_id", "r1", "r2", "r3")}, 3, 2)
+
+	buf := join([]byte(pod5Signature), testPOD5Marker)
+	var contents testFBVector
+	for _, file := range []struct {
+		data        []byte
+		contentType uint16
+	}{{runInfo, pod5ContentRunInfo}, {reads, pod5ContentReads}} {
+		offset := len(buf)
+		buf = join(pad8(append(buf, file.data...)), testPOD5Marker)
+		contents = append(contents, testFBTable{le64(uint64(offset)), le64(uint64(len(file.data))), le16(0), le16(file.contentType)})
+	}
+	footer := testFBBuild(testFBTable{"0f8e7d6c-test", "Python pod5 0.3.10", "0.3.10", contents})
+	return join(buf, []byte("FOOTER\x00\x00"), footer, le64(uint64(len(footer))), testPOD5Marker, []byte(pod5Signature))
+}
+
+func TestPOD5Extractor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "PAO12345_pass_a1b2c3d4_0.pod5")
+	if err := os.WriteFile(path, buildTestPOD5(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+	metadata, err := registry.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":                 "POD5",
+		"pod5_version":           "0.3.10",
+		"read_count":             int64(5),
+		"run_info_count":         1,
+		"platform":               "ONT",
+		"manufacturer":           "Oxford Nanopore Technologies",
+		"instrument_model":       "PromethION",
+		"serial_number":          "PC24B123",
+		"software_version":       "5.9.12",
+		"run_id":                 "a1b2c3d4e5f6",
+		"flowcell_id":            "PAO12345",
+		"flow_cell_product_code": "FLO-PRO114M",
+		"library_kit":            "SQK-LSK114",
+		"sample_id":              "HG002",
+		"device_id":              "1A",
+		"experiment_name":        "exp_2024_03",
+		"experiment_type":        "genomic_dna",
+		"basecall_model":         "dna_r10.4.1_e8.2_400bps_hac",
+		"sample_rate_hz":         5000,
+		"run_date":               "2024-03-04T05:06:07Z",
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+
+	if result := nanoporePromethIONPreset().Validate(metadata); !result.IsValid || len(result.Warnings) > 0 {
+		t.Errorf("nanopore-promethion validation: errors %v, warnings %v", result.Errors, result.Warnings)
+	}
+}
+
+func TestPOD5ExtractorInvalid(t *testing.T) {
+	extractor := &POD5Extractor{}
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(make([]byte, 64)), "bad.pod5"); err == nil {
+		t.Error("expected error for missing signature")
+	}
+
+	data := buildTestPOD5()
+	binary.LittleEndian.PutUint64(data[len(data)-32:], math.MaxUint32)
+	if _, err := extractor.ExtractFromReader(bytes.NewReader(data), "bad.pod5"); err == nil {
+		t.Error("expected error for corrupt footer length")
+	}
+}
+
+// buildTestFAST5 writes a multi-read FAST5 file with two reads.
+func buildTestFAST5() []byte {
+	w := &testH5Writer{}
+	w.superblock0()
+	attr := func(name, value string) testH5Msg { return testH5StrAttr(name, value, false) }
+
+	readGroup := func() uint64 {
+		tracking := w.symbolTableGroup(nil,
+			attr("device_id", "MN12345"), attr("device_type", "minion"),
+			attr("exp_start_time", "2023-11-20T09:15:00Z"), attr("flow_cell_id", "FAT98765"),
+			attr("flow_cell_product_code", "FLO-MIN114"), attr("guppy_version", "6.5.7+ca6d6af"),
+			attr("protocol_group_id", "plasmid_run"), attr("run_id", "f00dcafe"),
+			attr("sample_id", "pUC19"), attr("version", "23.07.12"))
+		context := w.symbolTableGroup(nil,
+			attr("sample_frequency", "4000"), attr("sequencing_kit", "sqk-rbk114-24"))
+		channel := w.symbolTableGroup(nil,
+			testH5Attr("sampling_rate", testH5Float64(), testH5Scalar(), le64(math.Float64bits(4000)), false))
+		basecall := w.symbolTableGroup(nil,
+			attr("name", "ONT Guppy basecalling software."), attr("version", "6.5.7+ca6d6af"))
+		analyses := w.symbolTableGroup([]testH5Child{{"Basecall_1D_000", basecall}})
+		return w.symbolTableGroup([]testH5Child{
+			{"Analyses", analyses}, {"channel_id", channel}, {"context_tags", context}, {"tracking_id", tracking},
+		}, attr("run_id", "f00dcafe"))
+	}
+
+	root := w.symbolTableGroup([]testH5Child{
+		{"read_0001", readGroup()},
+		{"read_0002", readGroup()},
+	}, attr("file_type", "multi-read"), attr("file_version", "3.0"))
+	return w.finish(root)
+}
+
+func TestFAST5Extractor(t *testing.T) {
+	metadata, err := (&FAST5Extractor{}).ExtractFromReader(bytes.NewReader(buildTestFAST5()), "FAT98765_pass_0.fast5")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":             "FAST5",
+		"file_type":          "multi-read",
+		"fast5_version":      "3.0",
+		"read_count":         2,
+		"platform":           "ONT",
+		"instrument_model":   "MinION",
+		"device_id":          "MN12345",
+		"run_id":             "f00dcafe",
+		"flowcell_id":        "FAT98765",
+		"library_kit":        "SQK-RBK114-24",
+		"sample_id":          "pUC19",
+		"experiment_name":    "plasmid_run",
+		"software_version":   "23.07.12",
+		"basecaller":         "ONT Guppy basecalling software",
+		"basecaller_version": "6.5.7+ca6d6af",
+		"sample_rate_hz":     4000,
+		"run_date":           "2023-11-20T09:15:00Z",
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+}
+
+func TestFAST5ExtractorInvalid(t *testing.T) {
+	w := &testH5Writer{}
+	w.superblock0()
+	empty := w.finish(w.symbolTableGroup(nil))
+	if _, err := (&FAST5Extractor{}).ExtractFromReader(bytes.NewReader(empty), "empty.fast5"); err == nil {
+		t.Error("expected error for HDF5 file without reads")
+	}
+}
@@ -0,0 +1,999 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # Nikon ND2 Format
+//
+// This file implements metadata extraction for Nikon ND2 files, the native
+// format of NIS-Elements software used on Nikon Ti/Ti2, A1 and AX systems.
+//
+// ## File Format Overview
+//
+// Two generations of ND2 exist:
+//
+// Legacy (NIS-Elements < 3.x, "JPEG2000-era"):
+//   - JPEG 2000 box container starting with the "jP  " signature box
+//   - Image planes stored as JPEG 2000 codestreams
+//   - Metadata stored as XML "variant" documents embedded between boxes
+//
+// Modern (ND2 2.x/3.x):
+//   - Sequence of chunks, each with a 16-byte header:
+//     magic 0x0ABECEDA (uint32), name length (uint32), data length (uint64)
+//   - First chunk "ND2 FILE SIGNATURE CHUNK NAME01!" carries the version ("Ver3.0")
+//   - Last 40 bytes: "ND2 CHUNK MAP SIGNATURE 0000001!" and the chunk map offset
+//   - Chunk map lists every chunk name ("ImageAttributesLV!", ...) with its
+//     position and length, so metadata can be read without scanning image data
+//   - Metadata chunks are encoded as CLxLiteVariant ("LV") binary trees in
+//     version 3 files and as XML variants in version 2 files
+//
+// ## Metadata Chunks
+//
+//   - ImageAttributes: width, height, components, bit depth, frame count
+//   - ImageMetadata: experiment loops (time, XY positions, Z stack)
+//   - ImageMetadataSeq|0: per-frame metadata for the first frame, including
+//     picture planes (channels), objective, calibration and absolute time
+//   - ImageCalibration|0: pixel calibration and objective settings
+//   - ImageTextInfo: free-text author, description, capturing and optics info
+//
+// ## References and Sources
+//
+// nd2 - Python ND2 reader (Talley Lambert):
+// https://github.com/tlambert03/nd2
+//
+// Bio-Formats NativeND2Reader:
+// https://bio-formats.readthedocs.io/en/latest/formats/nikon-nis-elements-nd2.html
+//
+// ## Limitations
+//
+//   - Reads metadata only; image planes are never decoded
+//   - Per-frame metadata is read for the first frame only
+//   - Legacy files are scanned for XML blocks rather than walked box by box
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ND2 file format constants
+const (
+	nd2ChunkMagic         = 0x0ABECEDA
+	nd2ChunkHeaderSize    = 16
+	nd2FileSignature      = "ND2 FILE SIGNATURE CHUNK NAME01!"
+	nd2ChunkMapSignature  = "ND2 CHUNK MAP SIGNATURE 0000001!"
+	nd2MaxChunkSize       = 64 << 20
+	nd2LegacyScanBlock    = 4 << 20
+	jp2SignatureBox       = "\x00\x00\x00\x0cjP  \r\n\x87\n"
+	nd2LegacyXMLEnd       = "</variant>"
+	nd2JulianDayUnixEpoch = 2440587.5
+)
+
+// CLxLiteVariant value types
+const (
+	lvBool     = 1
+	lvInt32    = 2
+	lvUint32   = 3
+	lvInt64    = 4
+	lvUint64   = 5
+	lvDouble   = 6
+	lvPointer  = 7
+	lvString   = 8
+	lvBytes    = 9
+	lvLevel    = 11
+	lvCompress = 76
+)
+
+// nd2LoopTypes maps ND2 experiment loop types to dimension names.
+var nd2LoopTypes = map[int64]string{
+	1:  "T",  // TimeLoop
+	2:  "XY", // XYPosLoop
+	3:  "XY", // XYDiscrLoop
+	4:  "Z",  // ZStackLoop
+	5:  "Polarization",
+	6:  "Spectral",
+	7:  "Custom",
+	8:  "T", // NETimeLoop
+	9:  "T", // ManTimeLoop
+	10: "Z", // ZStackLoopAccurate
+}
+
+// nd2TextInfoItems maps ImageTextInfo item keys to field names.
+var nd2TextInfoItems = map[string]string{
+	"TextInfoItem_3":  "sample_id",
+	"TextInfoItem_4":  "operator",
+	"TextInfoItem_5":  "description",
+	"TextInfoItem_6":  "capturing",
+	"TextInfoItem_7":  "sampling",
+	"TextInfoItem_8":  "location",
+	"TextInfoItem_9":  "date",
+	"TextInfoItem_13": "optics",
+}
+
+// NikonND2Extractor extracts metadata from Nikon ND2 microscopy files.
+type NikonND2Extractor struct{}
+
+// nd2Chunks holds the decoded metadata chunks of an ND2 file.
+type nd2Chunks struct {
+	version     string
+	attributes  map[string]interface{}
+	experiment  map[string]interface{}
+	frame       map[string]interface{}
+	calibration map[string]interface{}
+	textInfo    map[string]interface{}
+	appInfo     map[string]interface{}
+}
+
+// Name returns the extractor name.
+func (e *NikonND2Extractor) Name() string {
+	return "Nikon ND2"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *NikonND2Extractor) SupportedFormats() []string {
+	return []string{".nd2"}
+}
+
+// CanHandle returns true if this extractor can handle the given filename.
+func (e *NikonND2Extractor) CanHandle(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".nd2")
+}
+
+// Sniff recognises the ND2 chunk magic and, with less confidence, the
+// JPEG 2000 container used by legacy ND2 files. Plain JPEG 2000 images share
+// the container, so it is only claimed for files named .nd2.
+func (e *NikonND2Extractor) Sniff(in *SniffInput) (int, string) {
+	switch {
+	case len(in.Header) >= 4 && binary.LittleEndian.Uint32(in.Header[0:4]) == nd2ChunkMagic:
+		return 100, "ND2 chunk magic 0x0ABECEDA"
+	case in.hasPrefix(jp2SignatureBox) && e.CanHandle(in.Name):
+		return 60, "JPEG 2000 signature box (legacy ND2)"
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from an ND2 file.
+func (e *NikonND2Extractor) Extract(filepath string) (map[string]interface{}, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return e.extract(f, info.Size(), filepath)
+}
+
+// ExtractFromReader extracts metadata from a reader.
+func (e *NikonND2Extractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return e.extract(bytes.NewReader(data), int64(len(data)), filename)
+}
+
+// extract detects the ND2 generation and extracts metadata.
+func (e *NikonND2Extractor) extract(r io.ReaderAt, size int64, filename string) (map[string]interface{}, error) {
+	header := make([]byte, 12)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("not a valid ND2 file: %w", err)
+	}
+
+	var chunks *nd2Chunks
+	var err error
+	switch {
+	case binary.LittleEndian.Uint32(header[0:4]) == nd2ChunkMagic:
+		chunks, err = e.readModern(r, size)
+	case string(header) == jp2SignatureBox:
+		chunks, err = e.readLegacy(r, size)
+	default:
+		return nil, fmt.Errorf("not a valid ND2 file: invalid magic bytes")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"format":          "ND2",
+		"manufacturer":    "Nikon",
+		"instrument_type": "microscopy",
+		"file_name":       filename,
+		"file_size":       size,
+		"extractor_name":  "nikon_nd2",
+		"schema_name":     "nikon_nd2_v1",
+	}
+	if chunks.version != "" {
+		metadata["nd2_version"] = chunks.version
+	}
+
+	e.mapAttributes(chunks, metadata)
+	e.mapExperiment(chunks, metadata)
+	e.mapFrame(chunks, metadata)
+	e.mapTextInfo(chunks, metadata)
+
+	return metadata, nil
+}
+
+// readModern reads the chunk map of a version 2/3 ND2 file and decodes the metadata chunks.
+func (e *NikonND2Extractor) readModern(r io.ReaderAt, size int64) (*nd2Chunks, error) {
+	chunks := &nd2Chunks{}
+
+	// Signature chunk carries the file version
+	name, data, err := readND2Chunk(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	if name == nd2FileSignature {
+		chunks.version = strings.TrimRight(string(data), "\x00")
+	}
+
+	// Chunk map location is stored in the last 40 bytes
+	if size < 40 {
+		return nil, fmt.Errorf("not a valid ND2 file: too short")
+	}
+	tail := make([]byte, 40)
+	if _, err := r.ReadAt(tail, size-40); err != nil {
+		return nil, fmt.Errorf("failed to read chunk map location: %w", err)
+	}
+	if string(tail[:32]) != nd2ChunkMapSignature {
+		return nil, fmt.Errorf("not a valid ND2 file: chunk map signature not found")
+	}
+	mapOffset := int64(binary.LittleEndian.Uint64(tail[32:40]))
+
+	_, mapData, err := readND2Chunk(r, mapOffset, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk map: %w", err)
+	}
+	chunkMap := parseND2ChunkMap(mapData)
+
+	decode := func(names ...string) map[string]interface{} {
+		for _, name := range names {
+			offset, ok := chunkMap[name]
+			if !ok {
+				continue
+			}
+			_, data, err := readND2Chunk(r, offset, size)
+			if err != nil {
+				continue
+			}
+			if tree, err := decodeND2Metadata(data); err == nil {
+				return tree
+			}
+		}
+		return nil
+	}
+
+	chunks.attributes = decode("ImageAttributesLV!", "ImageAttributes!")
+	chunks.experiment = decode("ImageMetadataLV!", "ImageMetadata!")
+	chunks.frame = decode("ImageMetadataSeqLV|0!", "ImageMetadataSeq|0!")
+	chunks.calibration = decode("ImageCalibrationLV|0!", "ImageCalibration|0!")
+	chunks.textInfo = decode("ImageTextInfoLV!", "ImageTextInfo!")
+	chunks.appInfo = decode("CustomDataVar|AppInfo_V1_0!")
+
+	if chunks.attributes == nil {
+		return nil, fmt.Errorf("not a valid ND2 file: image attributes chunk not found")
+	}
+
+	return chunks, nil
+}
+
+// readLegacy scans a JPEG2000-era ND2 file for embedded XML variant documents.
+func (e *NikonND2Extractor) readLegacy(r io.ReaderAt, size int64) (*nd2Chunks, error) {
+	chunks := &nd2Chunks{version: "JPEG2000"}
+	merged := map[string]interface{}{}
+
+	var offset int64
+	for offset < size {
+		block := make([]byte, nd2LegacyScanBlock)
+		n, err := r.ReadAt(block, offset)
+		if n == 0 {
+			break
+		}
+		block = block[:n]
+
+		start := bytes.Index(block, []byte("<?xml"))
+		if start < 0 {
+			if err != nil {
+				break
+			}
+			// Keep a small overlap so a split "<?xml" marker is not missed
+			offset += int64(n) - 4
+			continue
+		}
+
+		doc, docLen, ok := readLegacyXML(r, offset+int64(start), size)
+		if ok {
+			if tree, err := decodeND2XML(doc); err == nil {
+				for k, v := range tree {
+					merged[k] = v
+				}
+			}
+			offset += int64(start) + docLen
+		} else {
+			offset += int64(start) + 5
+		}
+	}
+
+	if len(merged) == 0 {
+		return nil, fmt.Errorf("no metadata found in legacy ND2 file")
+	}
+
+	// Legacy files keep every section in one tree; each mapper searches it by key.
+	chunks.attributes = merged
+	chunks.experiment = merged
+	chunks.frame = merged
+	chunks.calibration = merged
+	chunks.textInfo = merged
+
+	return chunks, nil
+}
+
+// readLegacyXML reads one XML document starting at offset up to its closing </variant>.
+func readLegacyXML(r io.ReaderAt, offset, size int64) ([]byte, int64, bool) {
+	limit := size - offset
+	if limit > nd2MaxChunkSize {
+		limit = nd2MaxChunkSize
+	}
+	buf := make([]byte, limit)
+	n, _ := r.ReadAt(buf, offset)
+	buf = buf[:n]
+
+	end := bytes.Index(buf, []byte(nd2LegacyXMLEnd))
+	if end < 0 {
+		return nil, 0, false
+	}
+	end += len(nd2LegacyXMLEnd)
+	return buf[:end], int64(end), true
+}
+
+// readND2Chunk reads the chunk at offset of a file of size bytes and returns
+// its name and data.
+func readND2Chunk(r io.ReaderAt, offset, size int64) (string, []byte, error) {
+	if offset < 0 || offset > size-nd2ChunkHeaderSize {
+		return "", nil, fmt.Errorf("chunk offset %d out of range", offset)
+	}
+	header := make([]byte, nd2ChunkHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return "", nil, fmt.Errorf("failed to read chunk header at offset %d: %w", offset, err)
+	}
+	if binary.LittleEndian.Uint32(header[0:4]) != nd2ChunkMagic {
+		return "", nil, fmt.Errorf("invalid chunk magic at offset %d", offset)
+	}
+
+	nameLen := int64(binary.LittleEndian.Uint32(header[4:8]))
+	dataLen := int64(binary.LittleEndian.Uint64(header[8:16]))
+	if nameLen > 4096 || dataLen < 0 || dataLen > nd2MaxChunkSize {
+		return "", nil, fmt.Errorf("chunk at offset %d is too large", offset)
+	}
+	if nameLen+dataLen > size-offset-nd2ChunkHeaderSize {
+		return "", nil, fmt.Errorf("chunk at offset %d extends past end of file", offset)
+	}
+
+	buf := make([]byte, nameLen+dataLen)
+	if _, err := r.ReadAt(buf, offset+nd2ChunkHeaderSize); err != nil && err != io.EOF {
+		return "", nil, fmt.Errorf("failed to read chunk at offset %d: %w", offset, err)
+	}
+
+	name := string(bytes.TrimRight(buf[:nameLen], "\x00"))
+	return name, buf[nameLen:], nil
+}
+
+// parseND2ChunkMap parses chunk map entries of the form "<name>!" followed by
+// a uint64 position and a uint64 length.
+func parseND2ChunkMap(data []byte) map[string]int64 {
+	entries := make(map[string]int64)
+	pos := 0
+	for pos < len(data) {
+		end := bytes.IndexByte(data[pos:], '!')
+		if end < 0 {
+			break
+		}
+		name := string(data[pos : pos+end+1])
+		pos += end + 1
+		if name == nd2ChunkMapSignature || pos+16 > len(data) {
+			break
+		}
+		entries[name] = int64(binary.LittleEndian.Uint64(data[pos : pos+8]))
+		pos += 16
+	}
+	return entries
+}
+
+// decodeND2Metadata decodes a metadata chunk, which is either an XML variant
+// document (ND2 version 2) or a CLxLiteVariant binary tree (version 3).
+func decodeND2Metadata(data []byte) (map[string]interface{}, error) {
+	trimmed := bytes.TrimLeft(data, "\xef\xbb\xbf \r\n\t")
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return decodeND2XML(trimmed)
+	}
+	return decodeLiteVariant(data, -1)
+}
+
+// decodeLiteVariant decodes count items of a CLxLiteVariant buffer (all items if count < 0).
+//
+// Each item is a type byte, a name length byte (UTF-16 code units including
+// the terminator), the UTF-16LE name and a type-specific value. Nested levels
+// carry an item count and byte length and are followed by an offset table.
+func decodeLiteVariant(data []byte, count int) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	pos := 0
+
+	for i := 0; count < 0 || i < count; i++ {
+		if pos+2 > len(data) {
+			break
+		}
+		start := pos
+		typ := data[pos]
+		nameLen := int(data[pos+1]) * 2
+		pos += 2
+		if typ == 0 || typ == 0xFF {
+			break
+		}
+		if pos+nameLen > len(data) {
+			return nil, fmt.Errorf("truncated lite variant name at offset %d", start)
+		}
+		name := nd2FieldName(decodeUTF16(data[pos : pos+nameLen]))
+		pos += nameLen
+
+		need := func(n int) error {
+			if pos+n > len(data) {
+				return fmt.Errorf("truncated lite variant value %q", name)
+			}
+			return nil
+		}
+
+		var value interface{}
+		switch typ {
+		case lvBool:
+			if err := need(1); err != nil {
+				return nil, err
+			}
+			value = data[pos] != 0
+			pos++
+		case lvInt32:
+			if err := need(4); err != nil {
+				return nil, err
+			}
+			value = int64(int32(binary.LittleEndian.Uint32(data[pos:])))
+			pos += 4
+		case lvUint32:
+			if err := need(4); err != nil {
+				return nil, err
+			}
+			value = int64(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+		case lvInt64, lvUint64, lvPointer:
+			if err := need(8); err != nil {
+				return nil, err
+			}
+			value = int64(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+		case lvDouble:
+			if err := need(8); err != nil {
+				return nil, err
+			}
+			value = math.Float64frombits(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+		case lvString:
+			end := pos
+			for end+1 < len(data) && (data[end] != 0 || data[end+1] != 0) {
+				end += 2
+			}
+			value = decodeUTF16(data[pos:end])
+			pos = end + 2
+		case lvBytes:
+			if err := need(8); err != nil {
+				return nil, err
+			}
+			n := int(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+			if n < 0 || n > len(data)-pos {
+				return nil, fmt.Errorf("truncated lite variant byte array %q", name)
+			}
+			// Byte arrays hold binary blobs (thumbnails, LUTs); nested variants are tried
+			if nested, err := decodeLiteVariant(data[pos:pos+n], -1); err == nil && len(nested) > 0 {
+				value = nested
+			}
+			pos += n
+		case lvLevel:
+			if err := need(12); err != nil {
+				return nil, err
+			}
+			itemCount := int(binary.LittleEndian.Uint32(data[pos:]))
+			length := int(binary.LittleEndian.Uint64(data[pos+4:]))
+			pos += 12
+			if length < pos-start || length > len(data)-start {
+				return nil, fmt.Errorf("invalid lite variant level length for %q", name)
+			}
+			end := start + length
+			nested, err := decodeLiteVariant(data[pos:end], itemCount)
+			if err != nil {
+				return nil, err
+			}
+			value = nested
+			pos = end + itemCount*8
+		case lvCompress:
+			if pos+10 > len(data) {
+				return nil, fmt.Errorf("truncated compressed lite variant %q", name)
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(data[pos+10:]))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress lite variant %q: %w", name, err)
+			}
+			inflated, err := io.ReadAll(io.LimitReader(zr, nd2MaxChunkSize))
+			_ = zr.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress lite variant %q: %w", name, err)
+			}
+			nested, err := decodeLiteVariant(inflated, -1)
+			if err != nil {
+				return nil, err
+			}
+			value = nested
+			pos = len(data)
+		default:
+			return nil, fmt.Errorf("unknown lite variant type %d for %q", typ, name)
+		}
+
+		addND2Value(out, name, value)
+	}
+
+	return out, nil
+}
+
+// decodeND2XML decodes an ND2 XML variant document into a tree. Elements carry
+// the value in a "value" attribute and the type in a "runtype" attribute;
+// elements without a value are containers.
+func decodeND2XML(data []byte) (map[string]interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	root := make(map[string]interface{})
+	stack := []map[string]interface{}{root}
+	names := []string{""}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ND2 XML: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var value, runtype string
+			hasValue := false
+			for _, attr := range t.Attr {
+				switch attr.Name.Local {
+				case "value":
+					value, hasValue = attr.Value, true
+				case "runtype":
+					runtype = attr.Value
+				}
+			}
+			name := nd2FieldName(t.Name.Local)
+			if hasValue {
+				addND2Value(stack[len(stack)-1], name, nd2XMLValue(value, runtype))
+			}
+			child := make(map[string]interface{})
+			stack = append(stack, child)
+			names = append(names, name)
+		case xml.EndElement:
+			if len(stack) <= 1 {
+				continue
+			}
+			child := stack[len(stack)-1]
+			name := names[len(names)-1]
+			stack = stack[:len(stack)-1]
+			names = names[:len(names)-1]
+			if len(child) > 0 {
+				addND2Value(stack[len(stack)-1], name, child)
+			}
+		}
+	}
+
+	// Unwrap the <variant> root element
+	if v, ok := root["variant"].(map[string]interface{}); ok {
+		return v, nil
+	}
+	return root, nil
+}
+
+// nd2XMLValue converts an XML variant value according to its runtype.
+func nd2XMLValue(value, runtype string) interface{} {
+	switch {
+	case strings.Contains(runtype, "int"):
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return v
+		}
+	case strings.Contains(runtype, "double") || strings.Contains(runtype, "float"):
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	case strings.Contains(runtype, "bool"):
+		return value == "true" || value == "1"
+	}
+	return value
+}
+
+// nd2FieldName strips the Hungarian-notation prefix from ND2 field names
+// ("uiWidth" -> "Width", "m_pFilter" -> "Filter") so XML and lite variant
+// trees share the same keys.
+func nd2FieldName(name string) string {
+	stripped := strings.TrimLeft(name, "abcdefghijklmnopqrstuvwxyz_")
+	if stripped == "" {
+		return name
+	}
+	return stripped
+}
+
+// addND2Value adds a value to a tree, turning repeated names into lists.
+func addND2Value(tree map[string]interface{}, name string, value interface{}) {
+	existing, ok := tree[name]
+	if !ok {
+		tree[name] = value
+		return
+	}
+	if list, ok := existing.([]interface{}); ok {
+		tree[name] = append(list, value)
+		return
+	}
+	tree[name] = []interface{}{existing, value}
+}
+
+// decodeUTF16 decodes little-endian UTF-16 bytes, dropping a trailing NUL.
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, binary.LittleEndian.Uint16(b[i:]))
+	}
+	for len(units) > 0 && units[len(units)-1] == 0 {
+		units = units[:len(units)-1]
+	}
+	return string(utf16.Decode(units))
+}
+
+// nd2Find searches a tree depth-first for the first value with the given key.
+func nd2Find(tree map[string]interface{}, key string) (interface{}, bool) {
+	if tree == nil {
+		return nil, false
+	}
+	if v, ok := tree[key]; ok {
+		return v, true
+	}
+	for _, k := range sortedKeys(tree) {
+		switch child := tree[k].(type) {
+		case map[string]interface{}:
+			if v, ok := nd2Find(child, key); ok {
+				return v, true
+			}
+		case []interface{}:
+			for _, item := range child {
+				if m, ok := item.(map[string]interface{}); ok {
+					if v, ok := nd2Find(m, key); ok {
+						return v, true
+					}
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+func nd2FindMap(tree map[string]interface{}, key string) map[string]interface{} {
+	v, _ := nd2Find(tree, key)
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func nd2FindString(tree map[string]interface{}, key string) string {
+	v, _ := nd2Find(tree, key)
+	s, _ := v.(string)
+	return strings.TrimSpace(s)
+}
+
+func nd2FindNumber(tree map[string]interface{}, key string) (float64, bool) {
+	v, ok := nd2Find(tree, key)
+	if !ok {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// nd2Children returns the child trees of a container in key order.
+func nd2Children(tree map[string]interface{}) []map[string]interface{} {
+	var children []map[string]interface{}
+	for _, k := range sortedKeys(tree) {
+		switch child := tree[k].(type) {
+		case map[string]interface{}:
+			children = append(children, child)
+		case []interface{}:
+			for _, item := range child {
+				if m, ok := item.(map[string]interface{}); ok {
+					children = append(children, m)
+				}
+			}
+		}
+	}
+	return children
+}
+
+func sortedKeys(tree map[string]interface{}) []string {
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mapAttributes maps ImageAttributes fields.
+func (e *NikonND2Extractor) mapAttributes(chunks *nd2Chunks, metadata map[string]interface{}) {
+	attrs := chunks.attributes
+	if v, ok := nd2FindNumber(attrs, "Width"); ok && v > 0 {
+		metadata["image_width"] = int(v)
+	}
+	if v, ok := nd2FindNumber(attrs, "Height"); ok && v > 0 {
+		metadata["image_height"] = int(v)
+	}
+	if v, ok := nd2FindNumber(attrs, "BpcSignificant"); ok && v > 0 {
+		metadata["bit_depth"] = int(v)
+	} else if v, ok := nd2FindNumber(attrs, "BpcInMemory"); ok && v > 0 {
+		metadata["bit_depth"] = int(v)
+	}
+	if v, ok := nd2FindNumber(attrs, "SequenceCount"); ok && v > 0 {
+		metadata["num_frames"] = int(v)
+	}
+	if v, ok := nd2FindNumber(attrs, "Comp"); ok && v > 0 {
+		metadata["num_channels"] = int(v)
+	}
+
+	if app := chunks.appInfo; app != nil {
+		if name := nd2FindString(app, "SWNameString"); name != "" {
+			metadata["software_name"] = name
+		}
+		if version := nd2FindString(app, "VersionString"); version != "" {
+			metadata["software_version"] = version
+		}
+	}
+}
+
+// mapExperiment walks the experiment loop tree (T, XY, Z, ...).
+func (e *NikonND2Extractor) mapExperiment(chunks *nd2Chunks, metadata map[string]interface{}) {
+	exp := nd2FindMap(chunks.experiment, "SLxExperiment")
+	if exp == nil {
+		exp = chunks.experiment
+	}
+
+	var loops []map[string]interface{}
+	for level := exp; level != nil; {
+		loopType, ok := nd2FindNumber(level, "LoopType")
+		if !ok {
+			break
+		}
+		name := nd2LoopTypes[int64(loopType)]
+		if name == "" {
+			name = fmt.Sprintf("Loop%d", int64(loopType))
+		}
+
+		pars, _ := level["LoopPars"].(map[string]interface{})
+		loop := map[string]interface{}{"type": name}
+		count := 0
+		if v, ok := nd2FindNumber(pars, "Count"); ok {
+			count = int(v)
+		}
+
+		switch name {
+		case "T":
+			if v, ok := nd2FindNumber(pars, "Period"); ok && v > 0 {
+				loop["period_ms"] = v
+			}
+			if periods := nd2FindMap(pars, "Period"); periods != nil && count == 0 {
+				for _, p := range nd2Children(periods) {
+					if v, ok := nd2FindNumber(p, "Count"); ok {
+						count += int(v)
+					}
+				}
+			}
+		case "Z":
+			if v, ok := nd2FindNumber(pars, "ZStep"); ok && v != 0 {
+				loop["step_um"] = math.Abs(v)
+			}
+		case "XY":
+			if points := nd2FindMap(pars, "Points"); points != nil && count == 0 {
+				count = len(nd2Children(points))
+			}
+		}
+
+		loop["count"] = count
+		loops = append(loops, loop)
+
+		switch name {
+		case "T":
+			metadata["num_timepoints"] = count
+		case "Z":
+			metadata["image_depth"] = count
+			if step, ok := loop["step_um"]; ok {
+				metadata["pixel_size_z_um"] = step
+			}
+		case "XY":
+			metadata["num_positions"] = count
+		}
+
+		next, _ := level["NextLevelEx"].(map[string]interface{})
+		children := nd2Children(next)
+		if len(children) == 0 {
+			break
+		}
+		level = children[0]
+	}
+
+	if len(loops) > 0 {
+		metadata["experiment_loops"] = loops
+	}
+}
+
+// mapFrame maps the first-frame metadata: channels, objective, calibration and time.
+func (e *NikonND2Extractor) mapFrame(chunks *nd2Chunks, metadata map[string]interface{}) {
+	frame := chunks.frame
+
+	// Pixel calibration (µm/pixel), preferring the calibration chunk
+	for _, tree := range []map[string]interface{}{chunks.calibration, frame} {
+		if v, ok := nd2FindNumber(tree, "Calibration"); ok && v > 0 {
+			metadata["pixel_size_x_um"] = v
+			metadata["pixel_size_y_um"] = v
+			if aspect, ok := nd2FindNumber(tree, "Aspect"); ok && aspect > 0 {
+				metadata["pixel_size_y_um"] = v * aspect
+			}
+			break
+		}
+	}
+
+	// Objective
+	for _, tree := range []map[string]interface{}{frame, chunks.calibration} {
+		if name := nd2FindString(tree, "ObjectiveName"); name != "" {
+			metadata["objective_name"] = name
+			if immersion := nd2ObjectiveImmersion(name); immersion != "" {
+				metadata["objective_immersion"] = immersion
+			}
+		}
+		if v, ok := nd2FindNumber(tree, "ObjectiveMag"); ok && v > 0 {
+			metadata["objective_magnification"] = v
+		}
+		if v, ok := nd2FindNumber(tree, "ObjectiveNA"); ok && v > 0 {
+			metadata["objective_na"] = v
+		}
+		if _, ok := metadata["objective_name"]; ok {
+			break
+		}
+	}
+
+	if name := nd2FindString(frame, "MicroscopeName"); name != "" {
+		metadata["instrument_model"] = name
+		metadata["microscope_name"] = name
+	}
+	if name := nd2FindString(frame, "CameraName"); name != "" {
+		metadata["detector_model"] = name
+	}
+
+	// Acquisition time is stored as a Julian day number
+	if jdn, ok := nd2FindNumber(frame, "TimeAbsolute"); ok && jdn > nd2JulianDayUnixEpoch {
+		secs := (jdn - nd2JulianDayUnixEpoch) * 86400
+		t := time.Unix(int64(secs), int64((secs-math.Floor(secs))*1e9)).UTC()
+		metadata["acquisition_date"] = t.Format(time.RFC3339)
+	}
+
+	// Channels from picture planes
+	planes := nd2FindMap(frame, "PicturePlanes")
+	planeList := nd2FindMap(planes, "PlaneNew")
+	if planeList == nil {
+		planeList = nd2FindMap(planes, "Plane")
+	}
+	if planeList == nil {
+		return
+	}
+
+	var channels []map[string]interface{}
+	for i, plane := range nd2Children(planeList) {
+		channel := map[string]interface{}{
+			"id":    fmt.Sprintf("Channel:%d", i),
+			"index": i,
+		}
+		if name := nd2FindString(plane, "Description"); name != "" {
+			channel["name"] = name
+			channel["dye_name"] = name
+		}
+		if spectrum := nd2FindMap(plane, "EmissionSpectrum"); spectrum != nil {
+			if v, ok := nd2FindNumber(spectrum, "Wavelength"); ok && v > 0 {
+				channel["emission_wavelength_nm"] = v
+			}
+		}
+		if spectrum := nd2FindMap(plane, "ExcitationSpectrum"); spectrum != nil {
+			if v, ok := nd2FindNumber(spectrum, "Wavelength"); ok && v > 0 {
+				channel["excitation_wavelength_nm"] = v
+			}
+		}
+		if v, ok := nd2FindNumber(plane, "Color"); ok {
+			// ND2 stores colors as 0x00BBGGRR
+			c := uint32(v)
+			channel["color"] = fmt.Sprintf("#%02X%02X%02X", c&0xFF, (c>>8)&0xFF, (c>>16)&0xFF)
+		}
+		channels = append(channels, channel)
+	}
+	if len(channels) > 0 {
+		metadata["channels"] = channels
+		metadata["num_channels"] = len(channels)
+	}
+}
+
+// mapTextInfo maps the free-text ImageTextInfo items.
+func (e *NikonND2Extractor) mapTextInfo(chunks *nd2Chunks, metadata map[string]interface{}) {
+	for key, field := range nd2TextInfoItems {
+		value := nd2FindString(chunks.textInfo, key)
+		if value == "" {
+			continue
+		}
+		switch field {
+		case "date":
+			if _, ok := metadata["acquisition_date"]; !ok {
+				metadata["acquisition_date"] = value
+			}
+		case "description":
+			metadata["document_name"] = value
+		default:
+			metadata[field] = value
+		}
+	}
+}
+
+// nd2ObjectiveImmersion guesses the immersion medium from a Nikon objective name.
+func nd2ObjectiveImmersion(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "oil"):
+		return "Oil"
+	case strings.Contains(lower, "sil"):
+		return "Silicone"
+	case strings.Contains(lower, "water") || strings.Contains(lower, " wi") || strings.HasSuffix(lower, "wi"):
+		return "Water"
+	case strings.Contains(lower, "glyc"):
+		return "Glycerol"
+	}
+	return ""
+}
@@ -0,0 +1,388 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// lvItem is a named value for building CLxLiteVariant test data.
+type lvItem struct {
+	name  string
+	value interface{}
+}
+
+// encodeLiteVariant encodes items as a CLxLiteVariant buffer.
+// Supported values: uint32, float64, string and nested []lvItem levels.
+func encodeLiteVariant(items []lvItem) []byte {
+	var buf bytes.Buffer
+	for _, item := range items {
+		start := buf.Len()
+		name := utf16.Encode([]rune(item.name + "\x00"))
+
+		writeName := func(typ byte) {
+			buf.WriteByte(typ)
+			buf.WriteByte(byte(len(name)))
+			_ = binary.Write(&buf, binary.LittleEndian, name)
+		}
+
+		switch v := item.value.(type) {
+		case uint32:
+			writeName(lvUint32)
+			_ = binary.Write(&buf, binary.LittleEndian, v)
+		case float64:
+			writeName(lvDouble)
+			_ = binary.Write(&buf, binary.LittleEndian, v)
+		case string:
+			writeName(lvString)
+			_ = binary.Write(&buf, binary.LittleEndian, utf16.Encode([]rune(v+"\x00")))
+		case []lvItem:
+			writeName(lvLevel)
+			nested := encodeLiteVariant(v)
+			headerLen := buf.Len() - start + 12
+			_ = binary.Write(&buf, binary.LittleEndian, uint32(len(v)))
+			_ = binary.Write(&buf, binary.LittleEndian, uint64(headerLen+len(nested)))
+			buf.Write(nested)
+			buf.Write(make([]byte, len(v)*8))
+		}
+	}
+	return buf.Bytes()
+}
+
+// buildTestND2 builds a modern ND2 file from named chunk payloads.
+func buildTestND2(t *testing.T, chunks map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+
+	writeChunk := func(name string, data []byte) int64 {
+		offset := int64(buf.Len())
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(nd2ChunkMagic))
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(name)))
+		_ = binary.Write(&buf, binary.LittleEndian, uint64(len(data)))
+		buf.WriteString(name)
+		buf.Write(data)
+		return offset
+	}
+
+	writeChunk(nd2FileSignature, []byte("Ver3.0"))
+
+	var chunkMap bytes.Buffer
+	for name, data := range chunks {
+		offset := writeChunk(name, data)
+		chunkMap.WriteString(name)
+		_ = binary.Write(&chunkMap, binary.LittleEndian, uint64(offset))
+		_ = binary.Write(&chunkMap, binary.LittleEndian, uint64(len(data)))
+	}
+	chunkMap.WriteString(nd2ChunkMapSignature)
+
+	mapOffset := writeChunk("ND2 FILEMAP SIGNATURE NAME 0001!", chunkMap.Bytes())
+	buf.WriteString(nd2ChunkMapSignature)
+	_ = binary.Write(&buf, binary.LittleEndian, uint64(mapOffset))
+
+	return buf.Bytes()
+}
+
+func testND2Chunks() map[string][]byte {
+	channel := func(name string, color uint32, emission float64) []lvItem {
+		return []lvItem{
+			{"sDescription", name},
+			{"uiColor", color},
+			{"pFilterPath", []lvItem{
+				{"m_pFilter", []lvItem{
+					{"i0000000000", []lvItem{
+						{"m_EmissionSpectrum", []lvItem{
+							{"pPoint", []lvItem{
+								{"Point0", []lvItem{{"dWavelength", emission}}},
+							}},
+						}},
+					}},
+				}},
+			}},
+		}
+	}
+
+	return map[string][]byte{
+		"ImageAttributesLV!": encodeLiteVariant([]lvItem{
+			{"SLxImageAttributes", []lvItem{
+				{"uiWidth", uint32(512)},
+				{"uiHeight", uint32(256)},
+				{"uiComp", uint32(2)},
+				{"uiBpcSignificant", uint32(12)},
+				{"uiSequenceCount", uint32(60)},
+			}},
+		}),
+		"ImageMetadataLV!": encodeLiteVariant([]lvItem{
+			{"SLxExperiment", []lvItem{
+				{"uiLoopType", uint32(1)},
+				{"uLoopPars", []lvItem{
+					{"uiCount", uint32(10)},
+					{"dPeriod", 500.0},
+				}},
+				{"ppNextLevelEx", []lvItem{
+					{"i0000000000", []lvItem{
+						{"uiLoopType", uint32(4)},
+						{"uLoopPars", []lvItem{
+							{"uiCount", uint32(6)},
+							{"dZStep", -0.5},
+						}},
+					}},
+				}},
+			}},
+		}),
+		"ImageMetadataSeqLV|0!": encodeLiteVariant([]lvItem{
+			{"SLxPictureMetadata", []lvItem{
+				{"dCalibration", 0.325},
+				{"dObjectiveMag", 60.0},
+				{"dObjectiveNA", 1.4},
+				{"wsObjectiveName", "Plan Apo λ 60x Oil"},
+				{"dTimeAbsolute", 2460000.5},
+				{"sPicturePlanes", []lvItem{
+					{"sPlaneNew", []lvItem{
+						{"a0", channel("DAPI", 0xFF0000, 461)},
+						{"a1", channel("GFP", 0x00FF00, 510)},
+					}},
+				}},
+			}},
+		}),
+		"ImageTextInfoLV!": encodeLiteVariant([]lvItem{
+			{"SLxImageTextInfo", []lvItem{
+				{"TextInfoItem_4", "Jane Doe"},
+				{"TextInfoItem_13", "Plan Apo 60x Oil"},
+			}},
+		}),
+		"CustomDataVar|AppInfo_V1_0!": encodeLiteVariant([]lvItem{
+			{"SWNameString", "NIS-Elements AR"},
+			{"VersionString", "5.42.02"},
+		}),
+	}
+}
+
+func TestNikonND2Extractor_CanHandle(t *testing.T) {
+	extractor := &NikonExtractor{}
+	tests := []struct {
+		filename string
+		want     bool
+	}{
+		{"sample.nd2", true},
+		{"SAMPLE.ND2", true},
+		{"sample.czi", false},
+		{"sample", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := extractor.CanHandle(tt.filename); got != tt.want {
+				t.Errorf("CanHandle(%v) = %v, want %v", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNikonND2Extractor_ExtractModern(t *testing.T) {
+	extractor := &NikonND2Extractor{}
+	data := buildTestND2(t, testND2Chunks())
+
+	metadata, err := extractor.ExtractFromReader(bytes.NewReader(data), "timelapse.nd2")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+
+	checks := map[string]interface{}{
+		"format":                  "ND2",
+		"manufacturer":            "Nikon",
+		"instrument_type":         "microscopy",
+		"nd2_version":             "Ver3.0",
+		"image_width":             512,
+		"image_height":            256,
+		"bit_depth":               12,
+		"num_frames":              60,
+		"num_timepoints":          10,
+		"image_depth":             6,
+		"pixel_size_x_um":         0.325,
+		"pixel_size_z_um":         0.5,
+		"objective_magnification": 60.0,
+		"objective_na":            1.4,
+		"objective_immersion":     "Oil",
+		"operator":                "Jane Doe",
+		"optics":                  "Plan Apo 60x Oil",
+		"software_name":           "NIS-Elements AR",
+		"software_version":        "5.42.02",
+		"num_channels":            2,
+	}
+	for key, want := range checks {
+		if metadata[key] != want {
+			t.Errorf("%s = %v (%T), want %v", key, metadata[key], metadata[key], want)
+		}
+	}
+
+	wantDate := time.Unix(int64((2460000.5-nd2JulianDayUnixEpoch)*86400), 0).UTC().Format(time.RFC3339)
+	if metadata["acquisition_date"] != wantDate {
+		t.Errorf("acquisition_date = %v, want %v", metadata["acquisition_date"], wantDate)
+	}
+
+	channels, ok := metadata["channels"].([]map[string]interface{})
+	if !ok || len(channels) != 2 {
+		t.Fatalf("channels = %v", metadata["channels"])
+	}
+	if channels[0]["name"] != "DAPI" || channels[0]["emission_wavelength_nm"] != 461.0 || channels[0]["color"] != "#0000FF" {
+		t.Errorf("channel 0 = %v", channels[0])
+	}
+	if channels[1]["name"] != "GFP" || channels[1]["emission_wavelength_nm"] != 510.0 {
+		t.Errorf("channel 1 = %v", channels[1])
+	}
+
+	loops, ok := metadata["experiment_loops"].([]map[string]interface{})
+	if !ok || len(loops) != 2 || loops[0]["type"] != "T" || loops[1]["type"] != "Z" {
+		t.Errorf("experiment_loops = %v", metadata["experiment_loops"])
+	}
+}
+
+func TestNikonND2Extractor_ExtractFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample.nd2")
+	if err := os.WriteFile(path, buildTestND2(t, testND2Chunks()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+	metadata, err := registry.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if metadata["image_width"] != 512 {
+		t.Errorf("image_width = %v, want 512", metadata["image_width"])
+	}
+}
+
+func TestNikonND2Extractor_ExtractLegacy(t *testing.T) {
+	extractor := &NikonND2Extractor{}
+
+	var buf bytes.Buffer
+	buf.WriteString(jp2SignatureBox)
+	buf.Write(make([]byte, 256)) // stand-in for JPEG 2000 boxes
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<variant version="1.0"><no_name runtype="CLxListVariant">
+<uiWidth runtype="lx_uint32" value="1024"/>
+<uiHeight runtype="lx_uint32" value="768"/>
+<dCalibration runtype="double" value="0.1"/>
+<wsObjectiveName runtype="CLxStringW" value="Plan Fluor 10x"/>
+</no_name></variant>`)
+	buf.Write(make([]byte, 64))
+
+	metadata, err := extractor.ExtractFromReader(&buf, "legacy.nd2")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	if metadata["nd2_version"] != "JPEG2000" {
+		t.Errorf("nd2_version = %v", metadata["nd2_version"])
+	}
+	if metadata["image_width"] != 1024 || metadata["image_height"] != 768 {
+		t.Errorf("dimensions = %v x %v", metadata["image_width"], metadata["image_height"])
+	}
+	if metadata["pixel_size_x_um"] != 0.1 {
+		t.Errorf("pixel_size_x_um = %v", metadata["pixel_size_x_um"])
+	}
+	if metadata["objective_name"] != "Plan Fluor 10x" {
+		t.Errorf("objective_name = %v", metadata["objective_name"])
+	}
+}
+
+func TestNikonND2Extractor_Invalid(t *testing.T) {
+	extractor := &NikonND2Extractor{}
+
+	tests := map[string][]byte{
+		"wrong magic": []byte("this is not an nd2 file at all"),
+		"too short":   {0xDA, 0xCE},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := extractor.ExtractFromReader(bytes.NewReader(data), "bad.nd2"); err == nil {
+				t.Error("ExtractFromReader() should return error")
+			}
+		})
+	}
+
+	t.Run("missing chunk map", func(t *testing.T) {
+		data := buildTestND2(t, testND2Chunks())
+		data = data[:len(data)-40]
+		if _, err := extractor.ExtractFromReader(bytes.NewReader(data), "bad.nd2"); err == nil {
+			t.Error("ExtractFromReader() should return error without chunk map")
+		}
+	})
+
+	for name, dataLen := range map[string]uint64{
+		"negative chunk length": math.MaxUint64,
+		"chunk past end":        1 << 20,
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := buildTestND2(t, testND2Chunks())
+			binary.LittleEndian.PutUint64(data[8:16], dataLen)
+			if _, err := extractor.ExtractFromReader(bytes.NewReader(data), "bad.nd2"); err == nil {
+				t.Error("ExtractFromReader() should return error for corrupt chunk length")
+			}
+		})
+	}
+
+	t.Run("mutated", func(t *testing.T) {
+		// Lengths read from a corrupt file must never panic
+		file := buildTestND2(t, testND2Chunks())
+		for _, fill := range []byte{0xFF, 0x7F} {
+			for off := 0; off < len(file); off += 3 {
+				data := bytes.Clone(file)
+				for i := off; i < off+8 && i < len(data); i++ {
+					data[i] = fill
+				}
+				_, _ = extractor.ExtractFromReader(bytes.NewReader(data), "bad.nd2")
+			}
+		}
+	})
+}
+
+func TestDecodeLiteVariant_Corrupt(t *testing.T) {
+	name := utf16.Encode([]rune("x\x00"))
+	for _, length := range []uint64{math.MaxUint64, 1 << 62, 64} {
+		var buf bytes.Buffer
+		buf.WriteByte(lvBytes)
+		buf.WriteByte(byte(len(name)))
+		_ = binary.Write(&buf, binary.LittleEndian, name)
+		_ = binary.Write(&buf, binary.LittleEndian, length)
+		if _, err := decodeLiteVariant(buf.Bytes(), -1); err == nil {
+			t.Errorf("decodeLiteVariant() with byte array length %d should return error", length)
+		}
+	}
+}
+
+func TestDecodeLiteVariant_RepeatedNames(t *testing.T) {
+	data := encodeLiteVariant([]lvItem{
+		{"dValue", 1.0},
+		{"dValue", 2.0},
+	})
+
+	tree, err := decodeLiteVariant(data, -1)
+	if err != nil {
+		t.Fatalf("decodeLiteVariant() error = %v", err)
+	}
+	list, ok := tree["Value"].([]interface{})
+	if !ok || len(list) != 2 || math.Abs(list[1].(float64)-2.0) > 1e-12 {
+		t.Errorf("Value = %v, want [1 2]", tree["Value"])
+	}
+}
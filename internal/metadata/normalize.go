@@ -0,0 +1,501 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file converts between the flat field maps returned by extractors and
+// the instrument structs in types.go. Each struct field has one canonical
+// flat key (the name presets and S3 tags use, e.g. "image_width" or
+// "pixel_size_x_um") plus the aliases individual extractors emit, with a
+// scale factor when the alias is in a different unit.
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InstrumentMetadata is the typed form of an extractor's flat field map.
+// At most one of the domain structs is set, chosen by InstrumentType;
+// Extra holds every flat field the struct has no place for.
+type InstrumentMetadata struct {
+	InstrumentType string                 `json:"instrument_type" yaml:"instrument_type"`
+	Microscopy     *MicroscopyMetadata    `json:"microscopy,omitempty" yaml:"microscopy,omitempty"`
+	Sequencing     *SequencingMetadata    `json:"sequencing,omitempty" yaml:"sequencing,omitempty"`
+	MassSpec       *MassSpecMetadata      `json:"mass_spec,omitempty" yaml:"mass_spec,omitempty"`
+	FlowCytometry  *FlowCytometryMetadata `json:"flow_cytometry,omitempty" yaml:"flow_cytometry,omitempty"`
+	CryoEM         *CryoEMMetadata        `json:"cryo_em,omitempty" yaml:"cryo_em,omitempty"`
+	XRay           *XRayMetadata          `json:"xray,omitempty" yaml:"xray,omitempty"`
+	Extra          map[string]interface{} `json:"extra,omitempty" yaml:"extra,omitempty"`
+}
+
+// Typed returns the domain struct, or nil if the instrument type has none.
+func (m *InstrumentMetadata) Typed() interface{} {
+	switch {
+	case m.Microscopy != nil:
+		return m.Microscopy
+	case m.Sequencing != nil:
+		return m.Sequencing
+	case m.MassSpec != nil:
+		return m.MassSpec
+	case m.FlowCytometry != nil:
+		return m.FlowCytometry
+	case m.CryoEM != nil:
+		return m.CryoEM
+	case m.XRay != nil:
+		return m.XRay
+	}
+	return nil
+}
+
+// setTyped stores typed in the matching domain field. Unknown types are
+// ignored.
+func (m *InstrumentMetadata) setTyped(typed interface{}) {
+	switch v := typed.(type) {
+	case *MicroscopyMetadata:
+		m.Microscopy = v
+	case *SequencingMetadata:
+		m.Sequencing = v
+	case *MassSpecMetadata:
+		m.MassSpec = v
+	case *FlowCytometryMetadata:
+		m.FlowCytometry = v
+	case *CryoEMMetadata:
+		m.CryoEM = v
+	case *XRayMetadata:
+		m.XRay = v
+	}
+}
+
+// Fields converts the typed metadata back to a flat map using the
+// canonical keys. Extra fields are copied first so typed values win.
+func (m *InstrumentMetadata) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, len(m.Extra)+16)
+	for key, value := range m.Extra {
+		fields[key] = value
+	}
+	if m.InstrumentType != "" {
+		fields["instrument_type"] = m.InstrumentType
+	}
+	if typed := m.Typed(); typed != nil {
+		for key, value := range typedToFields(reflect.ValueOf(typed).Elem()) {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// newTypedMetadata returns an empty domain struct for an instrument type,
+// or nil if there is none.
+func newTypedMetadata(instrumentType string) interface{} {
+	switch instrumentType {
+	case "microscopy":
+		return &MicroscopyMetadata{}
+	case "sequencing":
+		return &SequencingMetadata{}
+	case "mass_spec":
+		return &MassSpecMetadata{}
+	case "flow_cytometry":
+		return &FlowCytometryMetadata{}
+	case "cryo_em":
+		return &CryoEMMetadata{}
+	case "xray":
+		return &XRayMetadata{}
+	}
+	return nil
+}
+
+// formatInstrumentTypes infers the instrument type from the format field
+// for maps that do not carry instrument_type.
+var formatInstrumentTypes = map[string]string{
+	"CZI":      "microscopy",
+	"ND2":      "microscopy",
+	"LIF":      "microscopy",
+	"OME-TIFF": "microscopy",
+	"FASTQ":    "sequencing",
+	"BAM":      "sequencing",
+	"SAM":      "sequencing",
+	"CRAM":     "sequencing",
+	"POD5":     "sequencing",
+	"FAST5":    "sequencing",
+	"MZML":     "mass_spec",
+	"MZXML":    "mass_spec",
+	"MGF":      "mass_spec",
+	"FCS":      "flow_cytometry",
+	"MRC":      "cryo_em",
+	"EER":      "cryo_em",
+	"EPU XML":  "cryo_em",
+}
+
+// NormalizeMetadata converts a flat extractor map into its typed form.
+// Values under aliases are converted to the struct's units; values that
+// cannot be converted are kept in Extra unchanged.
+func NormalizeMetadata(fields map[string]interface{}) *InstrumentMetadata {
+	m := &InstrumentMetadata{Extra: map[string]interface{}{}}
+	m.InstrumentType, _ = fields["instrument_type"].(string)
+	if m.InstrumentType == "" {
+		if format, ok := fields["format"].(string); ok {
+			m.InstrumentType = formatInstrumentTypes[strings.ToUpper(format)]
+		}
+	}
+
+	used := map[string]bool{"instrument_type": true}
+	if typed := newTypedMetadata(m.InstrumentType); typed != nil {
+		fieldsToTyped(reflect.ValueOf(typed).Elem(), fields, used)
+		m.setTyped(typed)
+	}
+	for key, value := range fields {
+		if !used[key] {
+			m.Extra[key] = value
+		}
+	}
+	return m
+}
+
+// NormalizeFields returns a copy of fields with the canonical key added for
+// every value found under an alias. Existing keys are never overwritten, so
+// the result is a superset of what the extractor returned.
+func NormalizeFields(fields map[string]interface{}) map[string]interface{} {
+	normalized := NormalizeMetadata(fields).Fields()
+	for key, value := range fields {
+		normalized[key] = value
+	}
+	return normalized
+}
+
+// typedMetadata wraps a struct an extractor built itself, keeping the flat
+// fields the struct does not cover as extras.
+func typedMetadata(fields map[string]interface{}, typed interface{}) *InstrumentMetadata {
+	m := NormalizeMetadata(fields)
+	if v := reflect.ValueOf(typed); v.Kind() == reflect.Ptr && !v.IsNil() {
+		m.setTyped(typed)
+	}
+	return m
+}
+
+// fieldAlias is another flat key for a struct field. Scale converts the
+// alias's unit to the struct's; zero means the units already agree. An
+// alias with a length unit is skipped when a <key>_unit field names a
+// different one, as extractors report values they cannot convert.
+type fieldAlias struct {
+	key   string
+	scale float64
+	unit  string
+}
+
+// unitMismatch reports whether fields name a unit for the alias's value
+// other than the alias's own.
+func (a fieldAlias) unitMismatch(fields map[string]interface{}) bool {
+	if a.unit == "" {
+		return false
+	}
+	unit, ok := fields[a.key+"_unit"].(string)
+	if !ok || unit == "" {
+		return false
+	}
+	meters, ok := omeLengthMeters[unit]
+	return !ok || meters != omeLengthMeters[a.unit]
+}
+
+// fieldMapping relates a struct field (by JSON name) to its canonical flat
+// key and aliases. Fields without a mapping use their JSON name.
+type fieldMapping struct {
+	key     string
+	aliases []fieldAlias
+}
+
+func alias(keys ...string) []fieldAlias {
+	aliases := make([]fieldAlias, len(keys))
+	for i, key := range keys {
+		aliases[i] = fieldAlias{key: key}
+	}
+	return aliases
+}
+
+// modelMapping stores the instrument model under instrument_model, which
+// every extractor sets, rather than the ambiguous "model".
+var modelMapping = fieldMapping{key: "instrument_model", aliases: alias("model")}
+
+var typedFieldMappings = map[reflect.Type]map[string]fieldMapping{
+	reflect.TypeOf(MicroscopyMetadata{}): {
+		"model":              modelMapping,
+		"magnification":      {key: "objective_magnification", aliases: alias("magnification")},
+		"numerical_aperture": {key: "objective_na", aliases: alias("numerical_aperture")},
+		"objective":          {key: "objective_name", aliases: alias("objective")},
+		"width":              {key: "image_width", aliases: alias("width", "size_x")},
+		"height":             {key: "image_height", aliases: alias("height", "size_y")},
+		"depth":              {key: "image_depth", aliases: alias("depth", "size_z", "z_planes")},
+		"channels":           {key: "num_channels", aliases: alias("channel_count", "size_c")},
+		"timepoints":         {key: "num_timepoints", aliases: alias("timepoints", "size_t")},
+		"pixel_size_x":       {key: "pixel_size_x_um", aliases: []fieldAlias{{key: "pixel_size_x", unit: "µm"}, {key: "pixel_size_x_nm", scale: 1e-3}}},
+		"pixel_size_y":       {key: "pixel_size_y_um", aliases: []fieldAlias{{key: "pixel_size_y", unit: "µm"}, {key: "pixel_size_y_nm", scale: 1e-3}}},
+		"pixel_size_z":       {key: "pixel_size_z_um", aliases: []fieldAlias{{key: "pixel_size_z", unit: "µm"}, {key: "pixel_size_z_nm", scale: 1e-3}}},
+		"channel_info":       {key: "channels", aliases: alias("channel_info")},
+		"exposure_time":      {key: "exposure_time_ms", aliases: []fieldAlias{{key: "exposure_time"}, {key: "exposure_time_s", scale: 1e3}}},
+	},
+	reflect.TypeOf(MicroscopyChannel{}): {
+		"fluorophore":           {key: "dye_name", aliases: alias("fluorophore")},
+		"excitation_wavelength": {key: "excitation_wavelength_nm", aliases: alias("excitation_wavelength")},
+		"emission_wavelength":   {key: "emission_wavelength_nm", aliases: alias("emission_wavelength")},
+	},
+	reflect.TypeOf(SequencingMetadata{}): {
+		"model":            modelMapping,
+		"platform":         {key: "platform", aliases: alias("sequencing_platform")},
+		"flowcell_id":      {key: "flowcell_id", aliases: alias("flow_cell_id")},
+		"total_reads":      {key: "total_reads", aliases: alias("read_count")},
+		"quality_score":    {key: "mean_quality_score", aliases: alias("quality_score")},
+		"duplication_rate": {key: "duplication_rate", aliases: alias("duplicate_rate_percent")},
+	},
+	reflect.TypeOf(MassSpecMetadata{}): {
+		"model": modelMapping,
+		// instrument_type is the domain ("mass_spec") in flat maps.
+		"instrument_type": {key: "mass_spec_type"},
+		"run_time":        {key: "run_time", aliases: []fieldAlias{{key: "rt_end_min"}, {key: "run_time_s", scale: 1.0 / 60}}},
+	},
+	reflect.TypeOf(FlowCytometryMetadata{}): {
+		"model": modelMapping,
+	},
+	reflect.TypeOf(CryoEMMetadata{}): {
+		"model":         modelMapping,
+		"pixel_size":    {key: "pixel_size", aliases: alias("pixel_size_A", "pixel_size_x_A")},
+		"exposure_time": {key: "exposure_time", aliases: []fieldAlias{{key: "exposure_time_ms", scale: 1e-3}}},
+	},
+}
+
+// jsonName returns the JSON name of a struct field, or "" if it has none.
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// fieldsToTyped fills the struct v from fields, recording the flat keys it
+// consumed in used (which may be nil).
+func fieldsToTyped(v reflect.Value, fields map[string]interface{}, used map[string]bool) {
+	mappings := typedFieldMappings[v.Type()]
+	for i := 0; i < v.NumField(); i++ {
+		name := jsonName(v.Type().Field(i))
+		if name == "" {
+			continue
+		}
+		mapping, ok := mappings[name]
+		if !ok {
+			mapping = fieldMapping{key: name}
+		}
+		for _, candidate := range append([]fieldAlias{{key: mapping.key}}, mapping.aliases...) {
+			value, ok := fields[candidate.key]
+			if !ok || value == nil || candidate.unitMismatch(fields) {
+				continue
+			}
+			if assignField(v.Field(i), value, candidate.scale) {
+				if used != nil {
+					used[candidate.key] = true
+				}
+				break
+			}
+		}
+	}
+}
+
+// assignField converts value into dst, returning false if it cannot.
+func assignField(dst reflect.Value, value interface{}, scale float64) bool {
+	if dst.Type() == reflect.TypeOf(time.Time{}) {
+		t, ok := toTime(value)
+		if ok {
+			dst.Set(reflect.ValueOf(t))
+		}
+		return ok
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			dst.SetString(v)
+		case int, int64, float64:
+			dst.SetString(fmtSimple(v))
+		default:
+			return false
+		}
+	case reflect.Int, reflect.Int64:
+		f, ok := toFloat(value)
+		if !ok {
+			return false
+		}
+		if scale != 0 {
+			f *= scale
+		}
+		dst.SetInt(int64(math.Round(f)))
+	case reflect.Float64:
+		f, ok := toFloat(value)
+		if !ok {
+			return false
+		}
+		if scale != 0 {
+			f *= scale
+		}
+		dst.SetFloat(f)
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Struct {
+			return assignStructSlice(dst, value)
+		}
+		// Plain slices ([]string, [][]float64) go through JSON so that
+		// []interface{} values decoded from JSON or YAML are accepted.
+		data, err := json.Marshal(value)
+		if err != nil {
+			return false
+		}
+		ptr := reflect.New(dst.Type())
+		if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+			return false
+		}
+		dst.Set(ptr.Elem())
+	default:
+		return false
+	}
+	return true
+}
+
+// assignStructSlice fills a slice of structs from a list of flat maps.
+func assignStructSlice(dst reflect.Value, value interface{}) bool {
+	var items []map[string]interface{}
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		items = v
+	case []interface{}:
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			items = append(items, m)
+		}
+	default:
+		return false
+	}
+
+	slice := reflect.MakeSlice(dst.Type(), len(items), len(items))
+	for i, item := range items {
+		fieldsToTyped(slice.Index(i), item, nil)
+	}
+	dst.Set(slice)
+	return true
+}
+
+// typedToFields flattens the struct v to canonical keys, skipping zero
+// values.
+func typedToFields(v reflect.Value) map[string]interface{} {
+	mappings := typedFieldMappings[v.Type()]
+	fields := map[string]interface{}{}
+	for i := 0; i < v.NumField(); i++ {
+		name := jsonName(v.Type().Field(i))
+		field := v.Field(i)
+		if name == "" || field.IsZero() {
+			continue
+		}
+		key := name
+		if mapping, ok := mappings[name]; ok {
+			key = mapping.key
+		}
+
+		switch value := field.Interface().(type) {
+		case time.Time:
+			fields[key] = value.Format(time.RFC3339)
+		default:
+			if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+				items := make([]map[string]interface{}, field.Len())
+				for j := range items {
+					items[j] = typedToFields(field.Index(j))
+				}
+				fields[key] = items
+			} else {
+				fields[key] = value
+			}
+		}
+	}
+	return fields
+}
+
+// toFloat converts numeric values and numeric strings to float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// timeLayouts are the date formats extractors emit, most specific first.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// toTime parses time values and the date strings in timeLayouts. Strings
+// without a zone are read as UTC.
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// fmtSimple formats a number for a string field such as a serial number.
+func fmtSimple(value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
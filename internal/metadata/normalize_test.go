@@ -0,0 +1,291 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeMetadata_MicroscopyAliases(t *testing.T) {
+	fields := map[string]interface{}{
+		"format":           "ND2",
+		"instrument_type":  "microscopy",
+		"model":            "Ti2-E",
+		"manufacturer":     "Nikon",
+		"image_width":      2048,
+		"image_height":     2048.0,
+		"channel_count":    2,
+		"objective_na":     "1.45",
+		"pixel_size_x_nm":  65.0,
+		"exposure_time_s":  0.1,
+		"acquisition_date": "2025-01-15 10:30:00",
+		"channels": []interface{}{
+			map[string]interface{}{"name": "DAPI", "index": 0, "dye_name": "DAPI", "emission_wavelength_nm": 461.0},
+			map[string]interface{}{"name": "GFP", "index": 1, "excitation_wavelength_nm": 488},
+		},
+		"nd2_version": "3.0",
+	}
+
+	m := NormalizeMetadata(fields)
+	if m.InstrumentType != "microscopy" || m.Microscopy == nil {
+		t.Fatalf("NormalizeMetadata() = %+v, want microscopy", m)
+	}
+	mm := m.Microscopy
+	if mm.Model != "Ti2-E" || mm.Manufacturer != "Nikon" {
+		t.Errorf("Model, Manufacturer = %q, %q", mm.Model, mm.Manufacturer)
+	}
+	if mm.Width != 2048 || mm.Height != 2048 || mm.Channels != 2 {
+		t.Errorf("Width, Height, Channels = %d, %d, %d", mm.Width, mm.Height, mm.Channels)
+	}
+	if mm.NumericalAperture != 1.45 {
+		t.Errorf("NumericalAperture = %v, want 1.45", mm.NumericalAperture)
+	}
+	if math.Abs(mm.PixelSizeX-0.065) > 1e-9 {
+		t.Errorf("PixelSizeX = %v µm, want 0.065", mm.PixelSizeX)
+	}
+	if math.Abs(mm.ExposureTime-100) > 1e-9 {
+		t.Errorf("ExposureTime = %v ms, want 100", mm.ExposureTime)
+	}
+	if want := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC); !mm.AcquisitionDate.Equal(want) {
+		t.Errorf("AcquisitionDate = %v, want %v", mm.AcquisitionDate, want)
+	}
+	wantChannels := []MicroscopyChannel{
+		{Name: "DAPI", Index: 0, Fluorophore: "DAPI", EmissionWavelength: 461},
+		{Name: "GFP", Index: 1, ExcitationWavelength: 488},
+	}
+	if !reflect.DeepEqual(mm.ChannelInfo, wantChannels) {
+		t.Errorf("ChannelInfo = %+v, want %+v", mm.ChannelInfo, wantChannels)
+	}
+
+	wantExtra := map[string]interface{}{"format": "ND2", "nd2_version": "3.0"}
+	if !reflect.DeepEqual(m.Extra, wantExtra) {
+		t.Errorf("Extra = %v, want %v", m.Extra, wantExtra)
+	}
+}
+
+func TestNormalizeMetadata_InferTypeFromFormat(t *testing.T) {
+	m := NormalizeMetadata(map[string]interface{}{
+		"format":     "FASTQ",
+		"read_count": int64(1500),
+	})
+	if m.InstrumentType != "sequencing" || m.Sequencing == nil {
+		t.Fatalf("NormalizeMetadata() = %+v, want sequencing", m)
+	}
+	if m.Sequencing.TotalReads != 1500 {
+		t.Errorf("TotalReads = %d, want 1500", m.Sequencing.TotalReads)
+	}
+}
+
+func TestNormalizeMetadata_UnknownType(t *testing.T) {
+	fields := map[string]interface{}{
+		"format":          "DICOM",
+		"instrument_type": "medical_imaging",
+		"modality":        "CT",
+	}
+	m := NormalizeMetadata(fields)
+	if m.Typed() != nil {
+		t.Errorf("Typed() = %v, want nil", m.Typed())
+	}
+	if !reflect.DeepEqual(m.Fields(), fields) {
+		t.Errorf("Fields() = %v, want %v", m.Fields(), fields)
+	}
+}
+
+func TestNormalizeMetadata_KeepsUnconvertibleValues(t *testing.T) {
+	m := NormalizeMetadata(map[string]interface{}{
+		"instrument_type": "mass_spec",
+		"resolution":      "high",
+		"ms1_spectra":     "1200",
+	})
+	if m.MassSpec.Resolution != 0 {
+		t.Errorf("Resolution = %d, want 0", m.MassSpec.Resolution)
+	}
+	if m.Extra["resolution"] != "high" {
+		t.Errorf("Extra[resolution] = %v, want high", m.Extra["resolution"])
+	}
+	if m.MassSpec.MS1Spectra != 1200 {
+		t.Errorf("MS1Spectra = %d, want 1200", m.MassSpec.MS1Spectra)
+	}
+}
+
+func TestInstrumentMetadata_FieldsRoundTrip(t *testing.T) {
+	original := &InstrumentMetadata{
+		InstrumentType: "flow_cytometry",
+		FlowCytometry: &FlowCytometryMetadata{
+			Manufacturer:    "BD",
+			Model:           "FACSAria III",
+			TotalEvents:     50000,
+			AcquisitionTime: 42.5,
+			AcquisitionDate: time.Date(2024, 3, 5, 14, 2, 10, 0, time.UTC),
+			Parameters: []FlowCytometryParameter{
+				{Name: "FSC-A", Range: 262144, Voltage: 350},
+				{Name: "FITC-A", Fluorochrome: "CD3", Filter: "530/30"},
+			},
+			CompensationMatrix:     [][]float64{{1, 0.12}, {0.003, 1}},
+			CompensationParameters: []string{"FITC-A", "PE-A"},
+		},
+		Extra: map[string]interface{}{"fcs_version": "3.1"},
+	}
+
+	fields := original.Fields()
+	if fields["instrument_model"] != "FACSAria III" {
+		t.Errorf("Fields()[instrument_model] = %v, want FACSAria III", fields["instrument_model"])
+	}
+	if fields["acquisition_date"] != "2024-03-05T14:02:10Z" {
+		t.Errorf("Fields()[acquisition_date] = %v", fields["acquisition_date"])
+	}
+
+	roundTrip := NormalizeMetadata(fields)
+	if !reflect.DeepEqual(roundTrip, original) {
+		t.Errorf("NormalizeMetadata(Fields()) = %+v\nwant %+v", roundTrip.FlowCytometry, original.FlowCytometry)
+	}
+}
+
+func TestNormalizeFields(t *testing.T) {
+	fields := map[string]interface{}{
+		"format":          "BAM",
+		"instrument_type": "sequencing",
+		"read_count":      int64(42),
+		"model":           "Revio",
+		"platform":        "PacBio",
+	}
+	got := NormalizeFields(fields)
+
+	for key, value := range fields {
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("NormalizeFields()[%s] = %v, want original %v", key, got[key], value)
+		}
+	}
+	if got["total_reads"] != int64(42) {
+		t.Errorf("NormalizeFields()[total_reads] = %v, want 42", got["total_reads"])
+	}
+	if got["instrument_model"] != "Revio" {
+		t.Errorf("NormalizeFields()[instrument_model] = %v, want Revio", got["instrument_model"])
+	}
+}
+
+func TestInstrumentPreset_ValidateNormalizesAliases(t *testing.T) {
+	preset := &InstrumentPreset{
+		ID: "test",
+		RequiredFields: []FieldRequirement{
+			{Name: "instrument_model", Type: "string"},
+			{Name: "pixel_size_x_um", Type: "number", MinValue: ptr(0.0)},
+		},
+	}
+	result := preset.Validate(map[string]interface{}{
+		"instrument_type": "microscopy",
+		"model":           "LSM 980",
+		"pixel_size_x_nm": 100.0,
+	})
+	if !result.IsValid {
+		t.Errorf("Validate() errors = %v, want aliases accepted", result.Errors)
+	}
+}
+
+func TestExtractorRegistry_ExtractTyped(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+	dir := t.TempDir()
+
+	// FCS implements TypedExtractor
+	fcs := buildTestFCS("3.1", '/', [][2]string{
+		{"$BEGINANALYSIS", "0"}, {"$ENDANALYSIS", "0"},
+		{"$BEGINSTEXT", "0"}, {"$ENDSTEXT", "0"},
+		{"$BYTEORD", "1,2,3,4"}, {"$DATATYPE", "F"}, {"$MODE", "L"},
+		{"$NEXTDATA", "0"}, {"$TOT", "10000"}, {"$PAR", "1"},
+		{"$CYT", "FACSCanto II"}, {"$P1N", "FSC-A"}, {"$P1R", "262144"}, {"$P1B", "32"}, {"$P1E", "0,0"},
+		{"$SPILLOVER", "2,FITC-A,PE-A,1,0.12,0.003,1"},
+	}, make([]byte, 64))
+	fcsPath := filepath.Join(dir, "tube.fcs")
+	if err := os.WriteFile(fcsPath, fcs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.FindExtractor(fcsPath).(TypedExtractor); !ok {
+		t.Fatal("FCSExtractor does not implement TypedExtractor")
+	}
+	m, err := registry.ExtractTyped(fcsPath)
+	if err != nil {
+		t.Fatalf("ExtractTyped() error = %v", err)
+	}
+	if m.FlowCytometry == nil || m.FlowCytometry.TotalEvents != 10000 || m.FlowCytometry.Model != "FACSCanto II" {
+		t.Fatalf("ExtractTyped() FlowCytometry = %+v", m.FlowCytometry)
+	}
+	if want := [][]float64{{1, 0.12}, {0.003, 1}}; !reflect.DeepEqual(m.FlowCytometry.CompensationMatrix, want) {
+		t.Errorf("CompensationMatrix = %v, want %v", m.FlowCytometry.CompensationMatrix, want)
+	}
+	if m.Extra["fcs_version"] != "3.1" {
+		t.Errorf("Extra[fcs_version] = %v", m.Extra["fcs_version"])
+	}
+
+	// FASTQ only returns a map and goes through the normaliser
+	fastqPath := filepath.Join(dir, "reads.fastq")
+	if err := os.WriteFile(fastqPath, []byte("@r1\nACGT\n+\nIIII\n@r2\nGGCC\n+\nIIII\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err = registry.ExtractTyped(fastqPath)
+	if err != nil {
+		t.Fatalf("ExtractTyped() error = %v", err)
+	}
+	if m.Sequencing == nil || m.Sequencing.TotalReads != 2 {
+		t.Errorf("ExtractTyped() Sequencing = %+v, want 2 reads", m.Sequencing)
+	}
+	if m.Extra["format"] != "FASTQ" {
+		t.Errorf("Extra[format] = %v, want FASTQ", m.Extra["format"])
+	}
+}
+
+func TestNormalizeFields_OMETIFFUnits(t *testing.T) {
+	const omeXML = `<?xml version="1.0" encoding="UTF-8"?>
+<OME xmlns="http://www.openmicroscopy.org/Schemas/OME/2016-06">
+  <Image ID="Image:0">
+    <Pixels ID="Pixels:0" DimensionOrder="XYCZT" Type="uint16" SizeX="512" SizeY="512" SizeZ="4" SizeC="1" SizeT="1"
+            PhysicalSizeX="2500" PhysicalSizeXUnit="Å" PhysicalSizeY="250" PhysicalSizeYUnit="nm"
+            PhysicalSizeZ="3" PhysicalSizeZUnit="reference frame"/>
+  </Image>
+</OME>`
+	fields, err := (&OMETIFFExtractor{}).ExtractFromReader(bytes.NewReader(buildTestTIFF(t, omeXML, false)), "image.ome.tif")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	got := NormalizeFields(fields)
+
+	for key, want := range map[string]float64{"pixel_size_x_um": 0.25, "pixel_size_y_um": 0.25} {
+		if v, ok := got[key].(float64); !ok || math.Abs(v-want) > 1e-12 {
+			t.Errorf("NormalizeFields()[%s] = %v, want %v", key, got[key], want)
+		}
+	}
+	// A size in a unit that is not a length stays as extracted
+	if v, ok := got["pixel_size_z_um"]; ok {
+		t.Errorf("NormalizeFields()[pixel_size_z_um] = %v, want unset for reference frame unit", v)
+	}
+	if got["pixel_size_z"] != 3.0 || got["pixel_size_z_unit"] != "reference frame" {
+		t.Errorf("pixel_size_z = %v %v, want 3 reference frame", got["pixel_size_z"], got["pixel_size_z_unit"])
+	}
+
+	// Flat values in micrometers still normalize
+	got = NormalizeFields(map[string]interface{}{"instrument_type": "microscopy", "pixel_size_x": 0.1, "pixel_size_y": 2.0, "pixel_size_y_unit": "nm"})
+	if got["pixel_size_x_um"] != 0.1 {
+		t.Errorf("NormalizeFields()[pixel_size_x_um] = %v, want 0.1", got["pixel_size_x_um"])
+	}
+	if v, ok := got["pixel_size_y_um"]; ok {
+		t.Errorf("NormalizeFields()[pixel_size_y_um] = %v, want unset for nm value", v)
+	}
+}
@@ -0,0 +1,372 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file reads ontologies from OBO 1.2/1.4 flat files and from OWL
+// ontologies in RDF/XML, the two forms OBO Foundry ontologies are released
+// in.
+//
+// Only what term resolution needs is kept: each term's CURIE, label, exact
+// synonyms, is_a parents and obsolescence. Other synonym scopes, relations,
+// axioms and typedefs are skipped.
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// oboPURL is the prefix of OBO Foundry term IRIs.
+const oboPURL = "http://purl.obolibrary.org/obo/"
+
+// maxOBOLine caps the length of a line in an OBO file.
+const maxOBOLine = 1 << 20
+
+// ReadOntologyFile reads an OBO (.obo) or OWL RDF/XML (.owl, .rdf) file.
+func ReadOntologyFile(path string) (*Ontology, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open ontology: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var o *Ontology
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".obo":
+		o, err = ParseOBO(f)
+	case ".owl", ".rdf":
+		o, err = ParseOWL(f)
+	default:
+		return nil, fmt.Errorf("unsupported ontology file %s (use .obo, .owl or .rdf)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse ontology %s: %w", path, err)
+	}
+	o.Source = path
+	return o, nil
+}
+
+// isOntologyFile reports whether name has an ontology file extension.
+func isOntologyFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".obo", ".owl", ".rdf":
+		return true
+	}
+	return false
+}
+
+// ParseOBO parses an OBO flat file.
+func ParseOBO(r io.Reader) (*Ontology, error) {
+	o := &Ontology{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxOBOLine)
+
+	var term *OntologyTerm
+	inHeader := true
+	finish := func() {
+		if term != nil && term.ID != "" {
+			o.Terms = append(o.Terms, term)
+		}
+		term = nil
+	}
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "!") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			finish()
+			inHeader = false
+			if text == "[Term]" {
+				term = &OntologyTerm{}
+			}
+			continue
+		}
+
+		tag, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected tag: value", line)
+		}
+		value = strings.TrimSpace(value)
+
+		if inHeader {
+			switch tag {
+			case "ontology":
+				if o.Title == "" {
+					o.Title = value
+				}
+			case "data-version":
+				o.Version = value
+			}
+			continue
+		}
+		if term == nil {
+			// A stanza other than [Term]
+			continue
+		}
+
+		switch tag {
+		case "id":
+			term.ID = oboValue(value)
+		case "name":
+			term.Label = oboValue(value)
+		case "synonym":
+			text, scope, ok := oboSynonym(value)
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid synonym", line)
+			}
+			if scope == "EXACT" {
+				term.Synonyms = append(term.Synonyms, text)
+			}
+		case "is_a":
+			term.Parents = append(term.Parents, oboValue(value))
+		case "is_obsolete":
+			term.Obsolete = oboValue(value) == "true"
+		case "replaced_by":
+			term.ReplacedBy = oboValue(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+
+	o.Prefix = ontologyPrefix(o.Terms)
+	if o.Prefix == "" {
+		return nil, fmt.Errorf("no terms")
+	}
+	return o, nil
+}
+
+// oboValue strips the trailing modifiers ({...}) and comment (! ...) from
+// an unquoted tag value.
+func oboValue(value string) string {
+	if i := strings.Index(value, " !"); i >= 0 {
+		value = value[:i]
+	}
+	if i := strings.Index(value, " {"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// oboSynonym splits a synonym value such as
+// "house mouse" EXACT genbank_common_name [] into its text and scope.
+func oboSynonym(value string) (text, scope string, ok bool) {
+	if !strings.HasPrefix(value, `"`) {
+		return "", "", false
+	}
+	var b strings.Builder
+	i := 1
+	for ; i < len(value); i++ {
+		c := value[i]
+		if c == '\\' && i+1 < len(value) {
+			i++
+			b.WriteByte(value[i])
+			continue
+		}
+		if c == '"' {
+			break
+		}
+		b.WriteByte(c)
+	}
+	if i >= len(value) {
+		return "", "", false
+	}
+	rest := strings.Fields(value[i+1:])
+	scope = "RELATED"
+	if len(rest) > 0 {
+		scope = rest[0]
+	}
+	return b.String(), scope, true
+}
+
+// owlClass is an owl:Class element in RDF/XML. Elements are matched by
+// local name, so rdfs:label, oboInOwl:hasExactSynonym and the rest need no
+// namespace handling.
+type owlClass struct {
+	About      string        `xml:"about,attr"`
+	Labels     []owlLiteral  `xml:"label"`
+	IDs        []string      `xml:"id"`
+	Synonyms   []string      `xml:"hasExactSynonym"`
+	SubClassOf []owlResource `xml:"subClassOf"`
+	Deprecated string        `xml:"deprecated"`
+	ReplacedBy []owlResource `xml:"IAO_0100001"`
+}
+
+type owlLiteral struct {
+	Lang  string `xml:"lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+type owlResource struct {
+	Resource string `xml:"resource,attr"`
+}
+
+// ParseOWL parses an OWL ontology in RDF/XML.
+func ParseOWL(r io.Reader) (*Ontology, error) {
+	o := &Ontology{}
+	decoder := xml.NewDecoder(r)
+	depth := 0
+	inHeader := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 2 && t.Name.Local == "Class":
+				// Classes are children of rdf:RDF
+				var class owlClass
+				if err := decoder.DecodeElement(&class, &t); err != nil {
+					return nil, err
+				}
+				depth--
+				if term := class.term(); term != nil {
+					o.Terms = append(o.Terms, term)
+				}
+			case depth == 2 && t.Name.Local == "Ontology":
+				o.Title = strings.TrimSuffix(iriBase(attr(t, "about")), ".owl")
+				inHeader = true
+			case depth == 3 && inHeader && (t.Name.Local == "versionIRI" || t.Name.Local == "versionInfo"):
+				var v struct {
+					Resource string `xml:"resource,attr"`
+					Value    string `xml:",chardata"`
+				}
+				if err := decoder.DecodeElement(&v, &t); err != nil {
+					return nil, err
+				}
+				depth--
+				if o.Version == "" {
+					o.Version = strings.TrimSpace(v.Value)
+				}
+				if o.Version == "" {
+					o.Version = v.Resource
+				}
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				inHeader = false
+			}
+			depth--
+		}
+	}
+
+	o.Prefix = ontologyPrefix(o.Terms)
+	if o.Prefix == "" {
+		return nil, fmt.Errorf("no terms")
+	}
+	return o, nil
+}
+
+// term converts the class to a term, or returns nil for anonymous classes
+// and IRIs that are not OBO terms.
+func (c owlClass) term() *OntologyTerm {
+	id := ""
+	if len(c.IDs) > 0 {
+		id = strings.TrimSpace(c.IDs[0])
+	}
+	if id == "" {
+		id = curieFromIRI(c.About)
+	}
+	if id == "" {
+		return nil
+	}
+
+	term := &OntologyTerm{
+		ID:       id,
+		Obsolete: strings.TrimSpace(c.Deprecated) == "true",
+	}
+	for _, label := range c.Labels {
+		if term.Label == "" || label.Lang == "en" {
+			term.Label = strings.TrimSpace(label.Value)
+		}
+	}
+	for _, synonym := range c.Synonyms {
+		term.Synonyms = append(term.Synonyms, strings.TrimSpace(synonym))
+	}
+	for _, parent := range c.SubClassOf {
+		if p := curieFromIRI(parent.Resource); p != "" {
+			term.Parents = append(term.Parents, p)
+		}
+	}
+	if len(c.ReplacedBy) > 0 {
+		term.ReplacedBy = curieFromIRI(c.ReplacedBy[0].Resource)
+	}
+	return term
+}
+
+// attr returns the value of an attribute by local name.
+func attr(e xml.StartElement, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// iriBase returns the last path segment of an IRI.
+func iriBase(iri string) string {
+	return iri[strings.LastIndex(iri, "/")+1:]
+}
+
+// curieFromIRI converts an OBO PURL such as
+// http://purl.obolibrary.org/obo/NCBITaxon_10090 to NCBITaxon:10090.
+func curieFromIRI(iri string) string {
+	local, ok := strings.CutPrefix(iri, oboPURL)
+	if !ok {
+		local, ok = strings.CutPrefix(iri, "https://purl.obolibrary.org/obo/")
+	}
+	if !ok {
+		return ""
+	}
+	prefix, id, ok := strings.Cut(local, "_")
+	if !ok || prefix == "" || id == "" {
+		return ""
+	}
+	return prefix + ":" + id
+}
+
+// ontologyPrefix returns the most common CURIE prefix among terms, so an
+// ontology that imports a few terms from others is named by its own.
+func ontologyPrefix(terms []*OntologyTerm) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, term := range terms {
+		prefix, _, ok := strings.Cut(term.ID, ":")
+		if !ok {
+			continue
+		}
+		counts[prefix]++
+		if counts[prefix] > counts[best] || (counts[prefix] == counts[best] && prefix < best) {
+			best = prefix
+		}
+	}
+	return best
+}
@@ -78,25 +78,28 @@
 // ### Implementation Notes
 //
 // This extractor:
-//   - Reads TIFF ImageDescription tag from first IFD
-//   - Parses embedded OME-XML metadata
-//   - Extracts key fields for FAIR compliance
-//   - Uses Go standard library (image/tiff, encoding/xml)
+//   - Reads the TIFF ImageDescription tag from the first IFD (classic TIFF and BigTIFF)
+//   - Parses embedded OME-XML metadata, or a standalone .companion.ome file
+//   - Follows BinaryOnly references to the companion metadata file when needed
+//   - Resolves Instrument, Objective and Experimenter references per image
+//   - Normalizes physical sizes to micrometers and wavelengths to nanometers
+//   - Uses only the Go standard library (see tiff.go for the IFD reader)
 //
 // ## Limitations
 //
 //   - Does not extract full OME-XML tree (focuses on common fields)
 //   - Does not parse TiffData elements (metadata only)
-//   - Does not handle multi-file OME-TIFF datasets
+//   - Does not follow UUID references to other files in multi-file datasets
 //   - Tested with OME-XML schema 2016-06 and later
-//
 package metadata
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -105,87 +108,116 @@ type OMETIFFExtractor struct{}
 
 // omeXML represents the root OME-XML structure.
 // This is a simplified representation focusing on commonly-used metadata fields.
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omeXML struct {
-	XMLName      xml.Name      `xml:"OME"`
-	Images       []omeImage    `xml:"Image"`
-	Instruments  []omeInstrument `xml:"Instrument"`
+	XMLName       xml.Name          `xml:"OME"`
+	Creator       string            `xml:"Creator,attr"`
+	Images        []omeImage        `xml:"Image"`
+	Instruments   []omeInstrument   `xml:"Instrument"`
 	Experimenters []omeExperimenter `xml:"Experimenter"`
+	BinaryOnly    *omeBinaryOnly    `xml:"BinaryOnly"`
 }
 
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omeImage struct {
-	ID               string        `xml:"ID,attr"`
-	Name             string        `xml:"Name,attr"`
-	AcquisitionDate  string        `xml:"AcquisitionDate"`
-	Pixels           omePixels     `xml:"Pixels"`
-	InstrumentRef    *omeRef       `xml:"InstrumentRef"`
-	ExperimenterRef  *omeRef       `xml:"ExperimenterRef"`
+	ID                string                `xml:"ID,attr"`
+	Name              string                `xml:"Name,attr"`
+	AcquisitionDate   string                `xml:"AcquisitionDate"`
+	Description       string                `xml:"Description"`
+	Pixels            omePixels             `xml:"Pixels"`
+	InstrumentRef     *omeRef               `xml:"InstrumentRef"`
+	ExperimenterRef   *omeRef               `xml:"ExperimenterRef"`
+	ObjectiveSettings *omeObjectiveSettings `xml:"ObjectiveSettings"`
 }
 
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omePixels struct {
-	ID               string        `xml:"ID,attr"`
-	Type             string        `xml:"Type,attr"`
-	SizeX            int           `xml:"SizeX,attr"`
-	SizeY            int           `xml:"SizeY,attr"`
-	SizeZ            int           `xml:"SizeZ,attr"`
-	SizeC            int           `xml:"SizeC,attr"`
-	SizeT            int           `xml:"SizeT,attr"`
-	DimensionOrder   string        `xml:"DimensionOrder,attr"`
-	PhysicalSizeX    float64       `xml:"PhysicalSizeX,attr"`
-	PhysicalSizeY    float64       `xml:"PhysicalSizeY,attr"`
-	PhysicalSizeZ    float64       `xml:"PhysicalSizeZ,attr"`
-	Channels         []omeChannel  `xml:"Channel"`
+	ID                string       `xml:"ID,attr"`
+	Type              string       `xml:"Type,attr"`
+	SignificantBits   int          `xml:"SignificantBits,attr"`
+	SizeX             int          `xml:"SizeX,attr"`
+	SizeY             int          `xml:"SizeY,attr"`
+	SizeZ             int          `xml:"SizeZ,attr"`
+	SizeC             int          `xml:"SizeC,attr"`
+	SizeT             int          `xml:"SizeT,attr"`
+	DimensionOrder    string       `xml:"DimensionOrder,attr"`
+	PhysicalSizeX     float64      `xml:"PhysicalSizeX,attr"`
+	PhysicalSizeXUnit string       `xml:"PhysicalSizeXUnit,attr"`
+	PhysicalSizeY     float64      `xml:"PhysicalSizeY,attr"`
+	PhysicalSizeYUnit string       `xml:"PhysicalSizeYUnit,attr"`
+	PhysicalSizeZ     float64      `xml:"PhysicalSizeZ,attr"`
+	PhysicalSizeZUnit string       `xml:"PhysicalSizeZUnit,attr"`
+	TimeIncrement     float64      `xml:"TimeIncrement,attr"`
+	TimeIncrementUnit string       `xml:"TimeIncrementUnit,attr"`
+	Channels          []omeChannel `xml:"Channel"`
 }
 
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omeChannel struct {
-	ID                   string  `xml:"ID,attr"`
-	Name                 string  `xml:"Name,attr"`
-	SamplesPerPixel      int     `xml:"SamplesPerPixel,attr"`
-	EmissionWavelength   float64 `xml:"EmissionWavelength,attr"`
-	ExcitationWavelength float64 `xml:"ExcitationWavelength,attr"`
-	Fluor                string  `xml:"Fluor,attr"`
+	ID                       string  `xml:"ID,attr"`
+	Name                     string  `xml:"Name,attr"`
+	SamplesPerPixel          int     `xml:"SamplesPerPixel,attr"`
+	EmissionWavelength       float64 `xml:"EmissionWavelength,attr"`
+	EmissionWavelengthUnit   string  `xml:"EmissionWavelengthUnit,attr"`
+	ExcitationWavelength     float64 `xml:"ExcitationWavelength,attr"`
+	ExcitationWavelengthUnit string  `xml:"ExcitationWavelengthUnit,attr"`
+	Fluor                    string  `xml:"Fluor,attr"`
+	Color                    *int32  `xml:"Color,attr"`
+	AcquisitionMode          string  `xml:"AcquisitionMode,attr"`
+	IlluminationType         string  `xml:"IlluminationType,attr"`
 }
 
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omeInstrument struct {
-	ID           string         `xml:"ID,attr"`
-	Microscopes  []omeMicroscope `xml:"Microscope"`
-	Objectives   []omeObjective  `xml:"Objective"`
+	ID          string          `xml:"ID,attr"`
+	Microscopes []omeMicroscope `xml:"Microscope"`
+	Objectives  []omeObjective  `xml:"Objective"`
+	Detectors   []omeDetector   `xml:"Detector"`
 }
 
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omeMicroscope struct {
-	Type         string  `xml:"Type,attr"`
-	Manufacturer string  `xml:"Manufacturer,attr"`
-	Model        string  `xml:"Model,attr"`
+	Type         string `xml:"Type,attr"`
+	Manufacturer string `xml:"Manufacturer,attr"`
+	Model        string `xml:"Model,attr"`
+	SerialNumber string `xml:"SerialNumber,attr"`
 }
 
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omeObjective struct {
-	ID               string  `xml:"ID,attr"`
-	Manufacturer     string  `xml:"Manufacturer,attr"`
-	Model            string  `xml:"Model,attr"`
+	ID                   string  `xml:"ID,attr"`
+	Manufacturer         string  `xml:"Manufacturer,attr"`
+	Model                string  `xml:"Model,attr"`
 	NominalMagnification float64 `xml:"NominalMagnification,attr"`
-	LensNA           float64 `xml:"LensNA,attr"`
-	Immersion        string  `xml:"Immersion,attr"`
+	LensNA               float64 `xml:"LensNA,attr"`
+	Immersion            string  `xml:"Immersion,attr"`
+	Correction           string  `xml:"Correction,attr"`
+}
+
+type omeDetector struct {
+	ID           string `xml:"ID,attr"`
+	Manufacturer string `xml:"Manufacturer,attr"`
+	Model        string `xml:"Model,attr"`
+	Type         string `xml:"Type,attr"`
+}
+
+type omeObjectiveSettings struct {
+	ID string `xml:"ID,attr"`
 }
 
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omeExperimenter struct {
-	ID        string `xml:"ID,attr"`
-	FirstName string `xml:"FirstName"`
-	LastName  string `xml:"LastName"`
-	Email     string `xml:"Email"`
+	ID          string `xml:"ID,attr"`
+	FirstName   string `xml:"FirstName,attr"`
+	LastName    string `xml:"LastName,attr"`
+	Email       string `xml:"Email,attr"`
+	Institution string `xml:"Institution,attr"`
+	UserName    string `xml:"UserName,attr"`
 }
 
-//nolint:unused // Reserved for future OME-XML parsing functionality
 type omeRef struct {
 	ID string `xml:"ID,attr"`
 }
 
+// omeBinaryOnly points a TIFF file at the companion file that holds the
+// full OME-XML for a multi-file dataset.
+type omeBinaryOnly struct {
+	MetadataFile string `xml:"MetadataFile,attr"`
+	UUID         string `xml:"UUID,attr"`
+}
+
 // Name returns the extractor name.
 func (e *OMETIFFExtractor) Name() string {
 	return "OME-TIFF"
@@ -193,16 +225,36 @@ func (e *OMETIFFExtractor) Name() string {
 
 // SupportedFormats returns the file extensions this extractor handles.
 func (e *OMETIFFExtractor) SupportedFormats() []string {
-	return []string{".ome.tif", ".ome.tiff"}
+	return []string{".ome.tif", ".ome.tiff", ".companion.ome"}
 }
 
 // CanHandle returns true if this extractor can handle the given filename.
 func (e *OMETIFFExtractor) CanHandle(filename string) bool {
 	lower := strings.ToLower(filename)
-	return strings.HasSuffix(lower, ".ome.tif") || strings.HasSuffix(lower, ".ome.tiff")
+	for _, format := range e.SupportedFormats() {
+		if strings.HasSuffix(lower, format) {
+			return true
+		}
+	}
+	return false
 }
 
-// Extract extracts metadata from an OME-TIFF file.
+// Sniff recognises TIFF files with OME-XML in the first ImageDescription
+// and companion OME-XML documents.
+func (e *OMETIFFExtractor) Sniff(in *SniffInput) (int, string) {
+	if isTIFFHeader(in.Header) {
+		if strings.Contains(in.imageDescription(), "<OME") {
+			return 95, "TIFF header with OME-XML ImageDescription"
+		}
+		return 0, ""
+	}
+	if in.hasPrefix("<?xml") && in.contains("<OME") {
+		return 90, "OME-XML document"
+	}
+	return 0, ""
+}
+
+// Extract extracts metadata from an OME-TIFF or companion OME-XML file.
 func (e *OMETIFFExtractor) Extract(filepath string) (map[string]interface{}, error) {
 	f, err := os.Open(filepath)
 	if err != nil {
@@ -210,7 +262,18 @@ func (e *OMETIFFExtractor) Extract(filepath string) (map[string]interface{}, err
 	}
 	defer func() { _ = f.Close() }()
 
-	return e.extractFromReader(f, filepath)
+	metadata, err := e.extractFromReader(f, filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	// A BinaryOnly block means the real OME-XML lives in a companion file
+	// next to this TIFF; load it when it is present on disk.
+	if companion, ok := metadata["companion_file"].(string); ok {
+		e.mergeCompanion(filepath, companion, metadata)
+	}
+
+	return metadata, nil
 }
 
 // ExtractFromReader extracts metadata from a reader.
@@ -218,16 +281,8 @@ func (e *OMETIFFExtractor) ExtractFromReader(r io.Reader, filename string) (map[
 	return e.extractFromReader(r, filename)
 }
 
-// extractFromReader extracts metadata from an OME-TIFF file.
+// extractFromReader extracts metadata from an OME-TIFF file or companion OME-XML document.
 func (e *OMETIFFExtractor) extractFromReader(r io.Reader, filepath string) (map[string]interface{}, error) {
-	// Note: Full OME-TIFF metadata extraction requires reading the ImageDescription
-	// TIFF tag which contains the OME-XML. Go's standard library doesn't include
-	// TIFF support, and golang.org/x/image/tiff doesn't expose all TIFF tags.
-	//
-	// For v0.2.0, we provide a framework implementation that can be enhanced later
-	// with a complete TIFF tag reader (e.g., using github.com/google/tiff or
-	// implementing custom IFD parsing).
-
 	metadata := map[string]interface{}{
 		"format":         "OME-TIFF",
 		"file_name":      filepath,
@@ -242,137 +297,413 @@ func (e *OMETIFFExtractor) extractFromReader(r io.Reader, filepath string) (map[
 		}
 	}
 
-	// Add implementation status
-	metadata["extraction_note"] = "OME-TIFF format recognized. Full OME-XML extraction requires TIFF tag reader library."
-	metadata["implementation_status"] = "framework - requires golang.org/x/image/tiff or github.com/google/tiff"
-	metadata["enhancement_needed"] = "Add TIFF ImageDescription tag parsing to extract embedded OME-XML"
+	ra, err := readerAtFrom(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 8)
+	n, _ := ra.ReadAt(header, 0)
+	header = header[:n]
+
+	// Companion files are plain OME-XML documents
+	if !isTIFFHeader(header) {
+		data, err := io.ReadAll(io.NewSectionReader(ra, 0, tiffMaxValueSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		if !bytes.Contains(data, []byte("<OME")) {
+			return nil, fmt.Errorf("not a valid OME-TIFF file: no TIFF header or OME-XML found")
+		}
+		metadata["companion"] = true
+		if err := e.parseOMEXML(data, metadata); err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	}
+
+	tf, err := openTIFF(ra)
+	if err != nil {
+		return nil, err
+	}
+	entries, _, err := tf.readIFD(tf.firstIFD)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, ok := findTIFFEntry(entries, tiffTagImageDescription)
+	if !ok || !strings.Contains(desc.String(), "<OME") {
+		// Fall back to the plain TIFF tags so the file still carries something useful
+		e.addTIFFTags(tf, entries, metadata)
+		metadata["extraction_note"] = "No OME-XML found in the first IFD ImageDescription"
+		return metadata, nil
+	}
+
+	if err := e.parseOMEXML([]byte(desc.String()), metadata); err != nil {
+		return nil, err
+	}
 
 	return metadata, nil
 }
 
+// addTIFFTags records basic TIFF tag values from the first IFD.
+func (e *OMETIFFExtractor) addTIFFTags(tf *tiffFile, entries []tiffEntry, metadata map[string]interface{}) {
+	if entry, ok := findTIFFEntry(entries, tiffTagImageWidth); ok {
+		if v, ok := entry.Uint(tf.order); ok {
+			metadata["image_width"] = int(v)
+		}
+	}
+	if entry, ok := findTIFFEntry(entries, tiffTagImageLength); ok {
+		if v, ok := entry.Uint(tf.order); ok {
+			metadata["image_height"] = int(v)
+		}
+	}
+	if entry, ok := findTIFFEntry(entries, tiffTagBitsPerSample); ok {
+		if v, ok := entry.Uint(tf.order); ok {
+			metadata["bit_depth"] = int(v)
+		}
+	}
+	if entry, ok := findTIFFEntry(entries, tiffTagSoftware); ok && entry.String() != "" {
+		metadata["software_name"] = entry.String()
+	}
+	if entry, ok := findTIFFEntry(entries, tiffTagDateTime); ok && entry.String() != "" {
+		metadata["acquisition_date"] = entry.String()
+	}
+}
+
+// mergeCompanion loads the companion OME-XML file referenced by a BinaryOnly
+// block and merges its fields into metadata.
+func (e *OMETIFFExtractor) mergeCompanion(tiffPath, companion string, metadata map[string]interface{}) {
+	companionPath := filepath.Join(filepath.Dir(tiffPath), filepath.Base(companion))
+	data, err := os.ReadFile(companionPath)
+	if err != nil {
+		metadata["extraction_note"] = fmt.Sprintf("Companion metadata file not found: %s", companion)
+		return
+	}
+	if err := e.parseOMEXML(data, metadata); err != nil {
+		metadata["extraction_note"] = fmt.Sprintf("Failed to parse companion metadata file: %v", err)
+	}
+}
+
 // parseOMEXML parses OME-XML and extracts metadata fields.
-// This will be used once we have full TIFF tag access.
-//nolint:unused // Reserved for future OME-XML parsing functionality
+//
+// Fields from the first image are written at the top level using the same
+// keys as the other microscopy extractors. When the document holds more than
+// one image (series), every image is also listed under "images".
 func (e *OMETIFFExtractor) parseOMEXML(xmlData []byte, metadata map[string]interface{}) error {
 	var ome omeXML
 	if err := xml.Unmarshal(xmlData, &ome); err != nil {
 		return fmt.Errorf("failed to unmarshal OME-XML: %w", err)
 	}
 
-	// Extract from first image (most common case)
-	if len(ome.Images) > 0 {
-		img := ome.Images[0]
+	if ome.XMLName.Space != "" {
+		metadata["ome_schema"] = ome.XMLName.Space
+	}
+	if ome.Creator != "" {
+		metadata["software_name"] = ome.Creator
+	}
+	if ome.BinaryOnly != nil && ome.BinaryOnly.MetadataFile != "" {
+		metadata["companion_file"] = ome.BinaryOnly.MetadataFile
+	}
 
-		if img.Name != "" {
-			metadata["image_name"] = img.Name
-		}
-		if img.AcquisitionDate != "" {
-			metadata["acquisition_date"] = img.AcquisitionDate
-		}
+	if len(ome.Images) == 0 {
+		return nil
+	}
 
-		// Pixel information
-		pixels := img.Pixels
-		if pixels.SizeX > 0 {
-			metadata["image_width"] = pixels.SizeX
-		}
-		if pixels.SizeY > 0 {
-			metadata["image_height"] = pixels.SizeY
-		}
-		if pixels.SizeZ > 0 {
-			metadata["image_depth"] = pixels.SizeZ
-		}
-		if pixels.SizeC > 0 {
-			metadata["num_channels"] = pixels.SizeC
-		}
-		if pixels.SizeT > 0 {
-			metadata["num_timepoints"] = pixels.SizeT
-		}
-		if pixels.Type != "" {
-			metadata["pixel_type"] = pixels.Type
-		}
-		if pixels.DimensionOrder != "" {
-			metadata["dimension_order"] = pixels.DimensionOrder
-		}
+	images := make([]map[string]interface{}, 0, len(ome.Images))
+	for _, img := range ome.Images {
+		images = append(images, e.imageFields(&ome, img))
+	}
 
-		// Physical dimensions (in micrometers)
-		if pixels.PhysicalSizeX > 0 {
-			metadata["pixel_size_x_um"] = pixels.PhysicalSizeX
+	// First image populates the top-level fields (most common case)
+	for key, value := range images[0] {
+		metadata[key] = value
+	}
+	metadata["image_count"] = len(images)
+	if len(images) > 1 {
+		metadata["images"] = images
+	}
+
+	return nil
+}
+
+// imageFields extracts the fields of a single OME Image, resolving its
+// instrument, objective and experimenter references.
+func (e *OMETIFFExtractor) imageFields(ome *omeXML, img omeImage) map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	if img.ID != "" {
+		fields["image_id"] = img.ID
+	}
+	if img.Name != "" {
+		fields["image_name"] = img.Name
+	}
+	if img.Description != "" {
+		fields["image_description"] = img.Description
+	}
+	if img.AcquisitionDate != "" {
+		fields["acquisition_date"] = img.AcquisitionDate
+	}
+
+	// Pixel information
+	pixels := img.Pixels
+	if pixels.SizeX > 0 {
+		fields["image_width"] = pixels.SizeX
+	}
+	if pixels.SizeY > 0 {
+		fields["image_height"] = pixels.SizeY
+	}
+	if pixels.SizeZ > 0 {
+		fields["image_depth"] = pixels.SizeZ
+	}
+	if pixels.SizeC > 0 {
+		fields["num_channels"] = pixels.SizeC
+	}
+	if pixels.SizeT > 0 {
+		fields["num_timepoints"] = pixels.SizeT
+	}
+	if pixels.Type != "" {
+		fields["pixel_type"] = pixels.Type
+	}
+	if pixels.SignificantBits > 0 {
+		fields["bit_depth"] = pixels.SignificantBits
+	}
+	if pixels.DimensionOrder != "" {
+		fields["dimension_order"] = pixels.DimensionOrder
+	}
+
+	// Physical dimensions, normalized to micrometers
+	for axis, size := range map[string]struct {
+		value float64
+		unit  string
+	}{
+		"x": {pixels.PhysicalSizeX, pixels.PhysicalSizeXUnit},
+		"y": {pixels.PhysicalSizeY, pixels.PhysicalSizeYUnit},
+		"z": {pixels.PhysicalSizeZ, pixels.PhysicalSizeZUnit},
+	} {
+		if size.value <= 0 {
+			continue
 		}
-		if pixels.PhysicalSizeY > 0 {
-			metadata["pixel_size_y_um"] = pixels.PhysicalSizeY
+		if um, ok := omeLengthIn(size.value, size.unit, "µm"); ok {
+			fields["pixel_size_"+axis+"_um"] = um
+			fields["voxel_size_unit"] = "micrometers"
+		} else {
+			fields["pixel_size_"+axis] = size.value
+			fields["pixel_size_"+axis+"_unit"] = size.unit
 		}
-		if pixels.PhysicalSizeZ > 0 {
-			metadata["pixel_size_z_um"] = pixels.PhysicalSizeZ
+	}
+	if pixels.TimeIncrement > 0 {
+		if s, ok := omeTimeInSeconds(pixels.TimeIncrement, pixels.TimeIncrementUnit); ok {
+			fields["time_increment_s"] = s
 		}
+	}
 
-		// Channel information
-		if len(pixels.Channels) > 0 {
-			channels := make([]map[string]interface{}, 0)
-			for _, ch := range pixels.Channels {
-				channelInfo := map[string]interface{}{
-					"id":   ch.ID,
-					"name": ch.Name,
-				}
-				if ch.EmissionWavelength > 0 {
-					channelInfo["emission_wavelength_nm"] = ch.EmissionWavelength
-				}
-				if ch.ExcitationWavelength > 0 {
-					channelInfo["excitation_wavelength_nm"] = ch.ExcitationWavelength
+	// Channel information
+	if len(pixels.Channels) > 0 {
+		channels := make([]map[string]interface{}, 0, len(pixels.Channels))
+		for i, ch := range pixels.Channels {
+			channelInfo := map[string]interface{}{
+				"id":    ch.ID,
+				"name":  ch.Name,
+				"index": i,
+			}
+			if ch.EmissionWavelength > 0 {
+				if nm, ok := omeLengthIn(ch.EmissionWavelength, defaultUnit(ch.EmissionWavelengthUnit, "nm"), "nm"); ok {
+					channelInfo["emission_wavelength_nm"] = nm
 				}
-				if ch.Fluor != "" {
-					channelInfo["fluorophore"] = ch.Fluor
+			}
+			if ch.ExcitationWavelength > 0 {
+				if nm, ok := omeLengthIn(ch.ExcitationWavelength, defaultUnit(ch.ExcitationWavelengthUnit, "nm"), "nm"); ok {
+					channelInfo["excitation_wavelength_nm"] = nm
 				}
-				channels = append(channels, channelInfo)
 			}
-			metadata["channels"] = channels
+			if ch.Fluor != "" {
+				channelInfo["fluorophore"] = ch.Fluor
+			}
+			if ch.Color != nil {
+				channelInfo["color"] = omeColorHex(*ch.Color)
+			}
+			if ch.AcquisitionMode != "" {
+				channelInfo["acquisition_mode"] = ch.AcquisitionMode
+			}
+			channels = append(channels, channelInfo)
 		}
+		fields["channels"] = channels
 	}
 
-	// Extract instrument information
-	if len(ome.Instruments) > 0 {
-		inst := ome.Instruments[0]
-		metadata["instrument_type"] = "microscopy"
+	// Instrument information
+	inst := ome.findInstrument(img.InstrumentRef)
+	if inst != nil {
+		fields["instrument_type"] = "microscopy"
 
 		if len(inst.Microscopes) > 0 {
 			micro := inst.Microscopes[0]
 			if micro.Manufacturer != "" {
-				metadata["manufacturer"] = micro.Manufacturer
+				fields["manufacturer"] = micro.Manufacturer
 			}
 			if micro.Model != "" {
-				metadata["instrument_model"] = micro.Model
+				fields["instrument_model"] = micro.Model
 			}
 			if micro.Type != "" {
-				metadata["microscope_type"] = micro.Type
+				fields["microscope_type"] = micro.Type
+			}
+			if micro.SerialNumber != "" {
+				fields["serial_number"] = micro.SerialNumber
 			}
 		}
 
-		if len(inst.Objectives) > 0 {
-			obj := inst.Objectives[0]
+		if obj := inst.findObjective(img.ObjectiveSettings); obj != nil {
 			if obj.NominalMagnification > 0 {
-				metadata["objective_magnification"] = obj.NominalMagnification
+				fields["objective_magnification"] = obj.NominalMagnification
 			}
 			if obj.LensNA > 0 {
-				metadata["objective_na"] = obj.LensNA
+				fields["objective_na"] = obj.LensNA
 			}
 			if obj.Immersion != "" {
-				metadata["objective_immersion"] = obj.Immersion
+				fields["objective_immersion"] = obj.Immersion
 			}
 			if obj.Model != "" {
-				metadata["objective_model"] = obj.Model
+				fields["objective_model"] = obj.Model
+			}
+			if obj.Correction != "" {
+				fields["objective_correction"] = obj.Correction
+			}
+		}
+
+		if len(inst.Detectors) > 0 {
+			det := inst.Detectors[0]
+			if model := strings.TrimSpace(det.Manufacturer + " " + det.Model); model != "" {
+				fields["detector_model"] = model
+			}
+			if det.Type != "" {
+				fields["detector_type"] = det.Type
 			}
 		}
 	}
 
-	// Extract experimenter information
-	if len(ome.Experimenters) > 0 {
-		exp := ome.Experimenters[0]
-		if exp.FirstName != "" || exp.LastName != "" {
-			metadata["operator"] = strings.TrimSpace(exp.FirstName + " " + exp.LastName)
+	// Experimenter information
+	if exp := ome.findExperimenter(img.ExperimenterRef); exp != nil {
+		name := strings.TrimSpace(exp.FirstName + " " + exp.LastName)
+		if name == "" {
+			name = exp.UserName
+		}
+		if name != "" {
+			fields["operator"] = name
+			fields["experimenter"] = name
 		}
 		if exp.Email != "" {
-			metadata["operator_email"] = exp.Email
+			fields["operator_email"] = exp.Email
+		}
+		if exp.Institution != "" {
+			fields["institution"] = exp.Institution
 		}
 	}
 
+	return fields
+}
+
+// findInstrument resolves an InstrumentRef, falling back to the only
+// instrument in the document when the image has no explicit reference.
+func (o *omeXML) findInstrument(ref *omeRef) *omeInstrument {
+	if ref != nil {
+		for i := range o.Instruments {
+			if o.Instruments[i].ID == ref.ID {
+				return &o.Instruments[i]
+			}
+		}
+	}
+	if len(o.Instruments) > 0 {
+		return &o.Instruments[0]
+	}
+	return nil
+}
+
+// findExperimenter resolves an ExperimenterRef, falling back to the first
+// experimenter in the document.
+func (o *omeXML) findExperimenter(ref *omeRef) *omeExperimenter {
+	if ref != nil {
+		for i := range o.Experimenters {
+			if o.Experimenters[i].ID == ref.ID {
+				return &o.Experimenters[i]
+			}
+		}
+	}
+	if len(o.Experimenters) > 0 {
+		return &o.Experimenters[0]
+	}
 	return nil
 }
+
+// findObjective resolves ObjectiveSettings, falling back to the first objective.
+func (inst *omeInstrument) findObjective(settings *omeObjectiveSettings) *omeObjective {
+	if settings != nil {
+		for i := range inst.Objectives {
+			if inst.Objectives[i].ID == settings.ID {
+				return &inst.Objectives[i]
+			}
+		}
+	}
+	if len(inst.Objectives) > 0 {
+		return &inst.Objectives[0]
+	}
+	return nil
+}
+
+// omeLengthMeters maps OME UnitsLength symbols to their size in meters.
+var omeLengthMeters = map[string]float64{
+	"m":  1,
+	"dm": 1e-1,
+	"cm": 1e-2,
+	"mm": 1e-3,
+	"µm": 1e-6,
+	"μm": 1e-6, // Greek mu, as written by some tools
+	"um": 1e-6,
+	"nm": 1e-9,
+	"pm": 1e-12,
+	"Å":  1e-10,
+}
+
+// omeLengthIn converts a length from an OME unit to the target unit.
+// An empty unit means the OME default of micrometers.
+func omeLengthIn(value float64, unit, target string) (float64, bool) {
+	from, ok := omeLengthMeters[defaultUnit(unit, "µm")]
+	if !ok {
+		return 0, false
+	}
+	to, ok := omeLengthMeters[target]
+	if !ok {
+		return 0, false
+	}
+	return value * from / to, true
+}
+
+// omeTimeInSeconds converts an OME UnitsTime value to seconds.
+// An empty unit means the OME default of seconds.
+func omeTimeInSeconds(value float64, unit string) (float64, bool) {
+	switch defaultUnit(unit, "s") {
+	case "s":
+		return value, true
+	case "ms":
+		return value / 1e3, true
+	case "µs", "μs", "us":
+		return value / 1e6, true
+	case "min":
+		return value * 60, true
+	case "h":
+		return value * 3600, true
+	}
+	return 0, false
+}
+
+func defaultUnit(unit, fallback string) string {
+	if unit == "" {
+		return fallback
+	}
+	return unit
+}
+
+// omeColorHex converts an OME signed RGBA integer to a #RRGGBB string.
+func omeColorHex(color int32) string {
+	c := uint32(color)
+	return fmt.Sprintf("#%02X%02X%02X", (c>>24)&0xFF, (c>>16)&0xFF, (c>>8)&0xFF)
+}
@@ -0,0 +1,280 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOMEXML = `<?xml version="1.0" encoding="UTF-8"?>
+<OME xmlns="http://www.openmicroscopy.org/Schemas/OME/2016-06" Creator="OME Bio-Formats 6.11.1">
+  <Experimenter ID="Experimenter:0" FirstName="Jane" LastName="Doe" Email="jane@example.org" Institution="Example University"/>
+  <Instrument ID="Instrument:0">
+    <Microscope Manufacturer="Nikon" Model="Ti2-E" Type="Inverted"/>
+    <Objective ID="Objective:0:0" Model="Plan Apo 20x" NominalMagnification="20" LensNA="0.75" Immersion="Air"/>
+    <Objective ID="Objective:0:1" Model="Plan Apo 60x Oil" NominalMagnification="60" LensNA="1.4" Immersion="Oil"/>
+    <Detector ID="Detector:0:0" Manufacturer="Hamamatsu" Model="ORCA-Fusion" Type="CMOS"/>
+  </Instrument>
+  <Image ID="Image:0" Name="Well A1">
+    <AcquisitionDate>2025-11-23T10:30:00</AcquisitionDate>
+    <ExperimenterRef ID="Experimenter:0"/>
+    <InstrumentRef ID="Instrument:0"/>
+    <ObjectiveSettings ID="Objective:0:1"/>
+    <Pixels ID="Pixels:0" DimensionOrder="XYCZT" Type="uint16" SignificantBits="12"
+            SizeX="2048" SizeY="2048" SizeZ="15" SizeC="2" SizeT="1"
+            PhysicalSizeX="108.3" PhysicalSizeXUnit="nm" PhysicalSizeY="0.1083" PhysicalSizeZ="0.5" PhysicalSizeZUnit="µm">
+      <Channel ID="Channel:0:0" Name="DAPI" EmissionWavelength="461" ExcitationWavelength="405" Fluor="DAPI" Color="65535"/>
+      <Channel ID="Channel:0:1" Name="GFP" EmissionWavelength="0.509" EmissionWavelengthUnit="µm" Fluor="EGFP" Color="16711935"/>
+    </Pixels>
+  </Image>
+  <Image ID="Image:1" Name="Well A2">
+    <ObjectiveSettings ID="Objective:0:0"/>
+    <Pixels ID="Pixels:1" DimensionOrder="XYCZT" Type="uint16" SizeX="1024" SizeY="1024" SizeZ="1" SizeC="1" SizeT="10">
+      <Channel ID="Channel:1:0" Name="Brightfield"/>
+    </Pixels>
+  </Image>
+</OME>`
+
+// buildTestTIFF creates a single-IFD TIFF containing the given ImageDescription.
+func buildTestTIFF(t *testing.T, description string, bigTIFF bool) []byte {
+	t.Helper()
+	order := binary.LittleEndian
+	var buf bytes.Buffer
+	desc := append([]byte(description), 0)
+
+	if bigTIFF {
+		// Header (16) + IFD count (8) + 2 entries (40) + next offset (8) = 72
+		buf.WriteString("II")
+		_ = binary.Write(&buf, order, uint16(43))
+		_ = binary.Write(&buf, order, uint16(8))
+		_ = binary.Write(&buf, order, uint16(0))
+		_ = binary.Write(&buf, order, uint64(16))
+		_ = binary.Write(&buf, order, uint64(2))
+		_ = binary.Write(&buf, order, uint16(tiffTagImageWidth))
+		_ = binary.Write(&buf, order, uint16(3))
+		_ = binary.Write(&buf, order, uint64(1))
+		_ = binary.Write(&buf, order, uint64(2048))
+		_ = binary.Write(&buf, order, uint16(tiffTagImageDescription))
+		_ = binary.Write(&buf, order, uint16(2))
+		_ = binary.Write(&buf, order, uint64(len(desc)))
+		_ = binary.Write(&buf, order, uint64(72))
+		_ = binary.Write(&buf, order, uint64(0))
+	} else {
+		// Header (8) + IFD count (2) + 2 entries (24) + next offset (4) = 38
+		buf.WriteString("II")
+		_ = binary.Write(&buf, order, uint16(42))
+		_ = binary.Write(&buf, order, uint32(8))
+		_ = binary.Write(&buf, order, uint16(2))
+		_ = binary.Write(&buf, order, uint16(tiffTagImageWidth))
+		_ = binary.Write(&buf, order, uint16(3))
+		_ = binary.Write(&buf, order, uint32(1))
+		_ = binary.Write(&buf, order, uint32(2048))
+		_ = binary.Write(&buf, order, uint16(tiffTagImageDescription))
+		_ = binary.Write(&buf, order, uint16(2))
+		_ = binary.Write(&buf, order, uint32(len(desc)))
+		_ = binary.Write(&buf, order, uint32(38))
+		_ = binary.Write(&buf, order, uint32(0))
+	}
+
+	buf.Write(desc)
+	return buf.Bytes()
+}
+
+func TestOMETIFFExtractor_CanHandle(t *testing.T) {
+	extractor := &OMETIFFExtractor{}
+	tests := []struct {
+		filename string
+		want     bool
+	}{
+		{"image.ome.tif", true},
+		{"image.OME.TIFF", true},
+		{"plate.companion.ome", true},
+		{"image.tif", false},
+		{"image.czi", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := extractor.CanHandle(tt.filename); got != tt.want {
+				t.Errorf("CanHandle(%v) = %v, want %v", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOMETIFFExtractor_ExtractFromReader(t *testing.T) {
+	extractor := &OMETIFFExtractor{}
+
+	for _, bigTIFF := range []bool{false, true} {
+		name := "classic"
+		if bigTIFF {
+			name = "bigtiff"
+		}
+		t.Run(name, func(t *testing.T) {
+			data := buildTestTIFF(t, testOMEXML, bigTIFF)
+			metadata, err := extractor.ExtractFromReader(bytes.NewReader(data), "plate.ome.tif")
+			if err != nil {
+				t.Fatalf("ExtractFromReader() error = %v", err)
+			}
+
+			checks := map[string]interface{}{
+				"format":                  "OME-TIFF",
+				"image_name":              "Well A1",
+				"image_width":             2048,
+				"image_depth":             15,
+				"num_channels":            2,
+				"bit_depth":               12,
+				"manufacturer":            "Nikon",
+				"instrument_model":        "Ti2-E",
+				"objective_magnification": 60.0,
+				"objective_na":            1.4,
+				"objective_immersion":     "Oil",
+				"operator":                "Jane Doe",
+				"operator_email":          "jane@example.org",
+				"detector_model":          "Hamamatsu ORCA-Fusion",
+				"software_name":           "OME Bio-Formats 6.11.1",
+				"image_count":             2,
+			}
+			for key, want := range checks {
+				if metadata[key] != want {
+					t.Errorf("%s = %v, want %v", key, metadata[key], want)
+				}
+			}
+
+			// 108.3 nm should be normalized to micrometers
+			if got := metadata["pixel_size_x_um"].(float64); math.Abs(got-0.1083) > 1e-9 {
+				t.Errorf("pixel_size_x_um = %v, want 0.1083", got)
+			}
+			if got := metadata["pixel_size_z_um"].(float64); got != 0.5 {
+				t.Errorf("pixel_size_z_um = %v, want 0.5", got)
+			}
+
+			channels := metadata["channels"].([]map[string]interface{})
+			if len(channels) != 2 {
+				t.Fatalf("channels = %d, want 2", len(channels))
+			}
+			if channels[0]["emission_wavelength_nm"] != 461.0 || channels[0]["color"] != "#0000FF" {
+				t.Errorf("channel 0 = %v", channels[0])
+			}
+			if got := channels[1]["emission_wavelength_nm"].(float64); math.Abs(got-509) > 1e-9 {
+				t.Errorf("channel 1 emission = %v, want 509", got)
+			}
+
+			images := metadata["images"].([]map[string]interface{})
+			if len(images) != 2 {
+				t.Fatalf("images = %d, want 2", len(images))
+			}
+			if images[1]["objective_magnification"] != 20.0 || images[1]["num_timepoints"] != 10 {
+				t.Errorf("second image = %v", images[1])
+			}
+		})
+	}
+}
+
+func TestOMETIFFExtractor_PlainTIFF(t *testing.T) {
+	extractor := &OMETIFFExtractor{}
+
+	data := buildTestTIFF(t, "ImageJ=1.54f", false)
+	metadata, err := extractor.ExtractFromReader(bytes.NewReader(data), "image.ome.tif")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	if metadata["extraction_note"] == nil {
+		t.Error("expected extraction_note when no OME-XML is present")
+	}
+	if metadata["image_width"] != 2048 {
+		t.Errorf("image_width = %v, want 2048", metadata["image_width"])
+	}
+}
+
+func TestOMETIFFExtractor_InvalidFile(t *testing.T) {
+	extractor := &OMETIFFExtractor{}
+
+	_, err := extractor.ExtractFromReader(strings.NewReader("not a tiff"), "image.ome.tif")
+	if err == nil {
+		t.Error("ExtractFromReader() should return error for non-TIFF data")
+	}
+}
+
+func TestOMETIFFExtractor_Companion(t *testing.T) {
+	extractor := &OMETIFFExtractor{}
+	dir := t.TempDir()
+
+	companion := filepath.Join(dir, "plate.companion.ome")
+	if err := os.WriteFile(companion, []byte(testOMEXML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("companion file", func(t *testing.T) {
+		metadata, err := extractor.Extract(companion)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if metadata["companion"] != true || metadata["image_count"] != 2 {
+			t.Errorf("companion = %v, image_count = %v", metadata["companion"], metadata["image_count"])
+		}
+	})
+
+	t.Run("binary only TIFF", func(t *testing.T) {
+		binaryOnly := `<OME xmlns="http://www.openmicroscopy.org/Schemas/OME/2016-06">
+  <BinaryOnly MetadataFile="plate.companion.ome" UUID="urn:uuid:1234"/>
+</OME>`
+		path := filepath.Join(dir, "plate_1.ome.tif")
+		if err := os.WriteFile(path, buildTestTIFF(t, binaryOnly, false), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		metadata, err := extractor.Extract(path)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if metadata["companion_file"] != "plate.companion.ome" {
+			t.Errorf("companion_file = %v", metadata["companion_file"])
+		}
+		if metadata["instrument_model"] != "Ti2-E" {
+			t.Errorf("instrument_model = %v, want Ti2-E from companion", metadata["instrument_model"])
+		}
+	})
+}
+
+func TestOMELengthIn(t *testing.T) {
+	tests := []struct {
+		value  float64
+		unit   string
+		target string
+		want   float64
+		ok     bool
+	}{
+		{0.5, "", "µm", 0.5, true},
+		{500, "nm", "µm", 0.5, true},
+		{1, "mm", "µm", 1000, true},
+		{5000, "Å", "µm", 0.5, true},
+		{0.488, "µm", "nm", 488, true},
+		{1, "parsec", "µm", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := omeLengthIn(tt.value, tt.unit, tt.target)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("omeLengthIn(%v, %q, %q) = %v, %v; want %v, %v", tt.value, tt.unit, tt.target, got, ok, tt.want, tt.ok)
+		}
+	}
+}
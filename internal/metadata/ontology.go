@@ -0,0 +1,607 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file resolves field values to ontology terms without network access.
+//
+// Small subsets of NCBITaxon, UBERON, CL, EFO and FBbi are built in, and
+// PSI-MS (prefix MS) is built from the accessions the mzML parser knows.
+// OBO and OWL files in the ontology directories (.cicada/ontologies in the
+// working directory, then ~/.cicada/ontologies) add to the built-in terms
+// and override them, so a larger or newer subset can be imported once and
+// used offline.
+//
+// A field names its ontology with FieldSchema.Ontology or the schema's
+// ontology_mappings, such as "NCBITaxon", or several prefixes separated by
+// commas. A value resolves to a term when it is the term's CURIE or OBO
+// PURL, or matches its label or an exact synonym, ignoring case and
+// separators. The CURIE is stored next to the label in
+// <field>_ontology_term_id, so "mouse" becomes organism "Mus musculus" with
+// organism_ontology_term_id "NCBITaxon:10090".
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed ontologies/*.obo
+var builtinOntologies embed.FS
+
+// OntologySourceBuiltin is the Ontology.Source of embedded ontologies.
+const OntologySourceBuiltin = "builtin"
+
+// OntologyTermIDSuffix is appended to a field name to name the field that
+// holds the CURIE of its value's term.
+const OntologyTermIDSuffix = "_ontology_term_id"
+
+// maxOntologySuggestions caps the terms suggested for an unresolved value.
+const maxOntologySuggestions = 3
+
+// DefaultOntologyFields maps canonical field names to the ontologies of
+// their values, for metadata that is not validated against a schema.
+var DefaultOntologyFields = map[string]string{
+	"organism":         "NCBITaxon",
+	"tissue":           "UBERON",
+	"cell_type":        "CL",
+	"assay":            "EFO",
+	"assay_type":       "EFO",
+	"imaging_method":   "FBbi",
+	"instrument_model": "MS, EFO",
+}
+
+// OntologyTerm is a term of an ontology.
+type OntologyTerm struct {
+	ID         string   `json:"id" yaml:"id"` // CURIE, e.g. NCBITaxon:10090
+	Label      string   `json:"label" yaml:"label"`
+	Synonyms   []string `json:"synonyms,omitempty" yaml:"synonyms,omitempty"` // Exact synonyms
+	Parents    []string `json:"parents,omitempty" yaml:"parents,omitempty"`   // is_a parents
+	Obsolete   bool     `json:"obsolete,omitempty" yaml:"obsolete,omitempty"`
+	ReplacedBy string   `json:"replaced_by,omitempty" yaml:"replaced_by,omitempty"`
+}
+
+// Prefix returns the ontology prefix of the term's CURIE.
+func (t *OntologyTerm) Prefix() string {
+	prefix, _, _ := strings.Cut(t.ID, ":")
+	return prefix
+}
+
+// URI returns the term's OBO PURL.
+func (t *OntologyTerm) URI() string {
+	return OntologyTermURI(t.ID)
+}
+
+// Ontology is a parsed ontology file.
+type Ontology struct {
+	Prefix  string // CURIE prefix of most terms, e.g. NCBITaxon
+	Title   string // ontology ID from the header, e.g. ncbitaxon
+	Version string
+	Source  string // file, or "builtin"
+	Terms   []*OntologyTerm
+}
+
+// OntologyInfo summarises an ontology in an index.
+type OntologyInfo struct {
+	Prefix  string   `json:"prefix" yaml:"prefix"`
+	Title   string   `json:"title,omitempty" yaml:"title,omitempty"`
+	Version string   `json:"version,omitempty" yaml:"version,omitempty"`
+	Terms   int      `json:"terms" yaml:"terms"`
+	Sources []string `json:"sources" yaml:"sources"`
+}
+
+// OntologyIndex looks up terms by CURIE, label and synonym. Terms added
+// later replace earlier terms with the same CURIE.
+type OntologyIndex struct {
+	infos map[string]*OntologyInfo   // by lower-cased prefix
+	terms map[string]*OntologyTerm   // by lower-cased CURIE
+	names map[string][]*OntologyTerm // by lower-cased prefix and normalised name
+}
+
+// NewOntologyIndex creates an empty index.
+func NewOntologyIndex() *OntologyIndex {
+	return &OntologyIndex{
+		infos: make(map[string]*OntologyInfo),
+		terms: make(map[string]*OntologyTerm),
+		names: make(map[string][]*OntologyTerm),
+	}
+}
+
+// Add adds an ontology's terms to the index.
+func (idx *OntologyIndex) Add(o *Ontology) {
+	key := strings.ToLower(o.Prefix)
+	info := idx.infos[key]
+	if info == nil {
+		info = &OntologyInfo{Prefix: o.Prefix}
+		idx.infos[key] = info
+	}
+	if o.Title != "" {
+		info.Title = o.Title
+	}
+	if o.Version != "" {
+		info.Version = o.Version
+	}
+	info.Sources = append(info.Sources, o.Source)
+
+	for _, term := range o.Terms {
+		prefix := strings.ToLower(term.Prefix())
+		if idx.infos[prefix] == nil {
+			idx.infos[prefix] = &OntologyInfo{Prefix: term.Prefix(), Sources: []string{o.Source}}
+		}
+		idx.terms[strings.ToLower(term.ID)] = term
+		for _, name := range append([]string{term.Label}, term.Synonyms...) {
+			if name == "" {
+				continue
+			}
+			nameKey := prefix + "\x00" + normalizeTerm(name)
+			idx.names[nameKey] = append(idx.names[nameKey], term)
+		}
+	}
+}
+
+// Has reports whether the index holds terms with the given prefix.
+func (idx *OntologyIndex) Has(prefix string) bool {
+	return idx.infos[strings.ToLower(prefix)] != nil
+}
+
+// Ontologies summarises the ontologies in the index, sorted by prefix.
+func (idx *OntologyIndex) Ontologies() []OntologyInfo {
+	counts := make(map[string]int)
+	for _, term := range idx.terms {
+		counts[strings.ToLower(term.Prefix())]++
+	}
+	infos := make([]OntologyInfo, 0, len(idx.infos))
+	for key, info := range idx.infos {
+		i := *info
+		i.Terms = counts[key]
+		infos = append(infos, i)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return strings.ToLower(infos[i].Prefix) < strings.ToLower(infos[j].Prefix)
+	})
+	return infos
+}
+
+// Term returns the term with the given CURIE or OBO PURL.
+func (idx *OntologyIndex) Term(id string) (*OntologyTerm, bool) {
+	curie, ok := parseCURIE(id)
+	if !ok {
+		return nil, false
+	}
+	term, ok := idx.terms[strings.ToLower(curie)]
+	return term, ok
+}
+
+// Resolve returns the term that value names in the first of the given
+// ontologies that has one. value is a CURIE, an OBO PURL, a label or an
+// exact synonym. With no ontologies, every ontology is searched.
+func (idx *OntologyIndex) Resolve(value string, ontologies ...string) (*OntologyTerm, error) {
+	value = strings.TrimSpace(value)
+	names := strings.Join(ontologies, ", ")
+	if len(ontologies) == 0 {
+		names = "any ontology"
+		for _, info := range idx.Ontologies() {
+			ontologies = append(ontologies, info.Prefix)
+		}
+	}
+
+	if curie, ok := parseCURIE(value); ok {
+		prefix, _, _ := strings.Cut(curie, ":")
+		if !containsFold(ontologies, prefix) {
+			return nil, fmt.Errorf("%s is not a term of %s", curie, names)
+		}
+		if term, ok := idx.terms[strings.ToLower(curie)]; ok {
+			return term, nil
+		}
+		return nil, fmt.Errorf("%s is not in the %s terms available offline", curie, prefix)
+	}
+
+	norm := normalizeTerm(value)
+	for _, prefix := range ontologies {
+		var found []*OntologyTerm
+		for _, term := range idx.names[strings.ToLower(prefix)+"\x00"+norm] {
+			// Skip terms replaced by a later ontology with the same CURIE
+			if idx.terms[strings.ToLower(term.ID)] == term && !containsTerm(found, term) {
+				found = append(found, term)
+			}
+		}
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], nil
+		default:
+			ids := make([]string, len(found))
+			for i, term := range found {
+				ids[i] = term.ID
+			}
+			return nil, fmt.Errorf("'%s' names several %s terms: %s", value, prefix, strings.Join(ids, ", "))
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not a %s term", value, names)
+}
+
+// Suggest returns the terms of the given ontologies whose labels or
+// synonyms are spelled like value, closest first.
+func (idx *OntologyIndex) Suggest(value string, ontologies ...string) []*OntologyTerm {
+	norm := normalizeTerm(value)
+	if norm == "" {
+		return nil
+	}
+	limit := len([]rune(norm)) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 3 {
+		limit = 3
+	}
+
+	type candidate struct {
+		term     *OntologyTerm
+		distance int
+	}
+	var candidates []candidate
+	for _, term := range idx.terms {
+		if term.Obsolete || (len(ontologies) > 0 && !containsFold(ontologies, term.Prefix())) {
+			continue
+		}
+		best := -1
+		for _, name := range append([]string{term.Label}, term.Synonyms...) {
+			if d := editDistance(norm, normalizeTerm(name)); d <= limit && (best < 0 || d < best) {
+				best = d
+			}
+		}
+		if best >= 0 {
+			candidates = append(candidates, candidate{term, best})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].term.ID < candidates[j].term.ID
+	})
+
+	var terms []*OntologyTerm
+	for _, c := range candidates {
+		if len(terms) == maxOntologySuggestions {
+			break
+		}
+		terms = append(terms, c.term)
+	}
+	return terms
+}
+
+// BuiltinOntologies returns an index of the built-in ontology subsets.
+func BuiltinOntologies() *OntologyIndex {
+	idx := NewOntologyIndex()
+	files, _ := fs.Glob(builtinOntologies, "ontologies/*.obo")
+	for _, file := range files {
+		f, err := builtinOntologies.Open(file)
+		if err != nil {
+			continue
+		}
+		o, err := ParseOBO(f)
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+		o.Source = OntologySourceBuiltin
+		idx.Add(o)
+	}
+	idx.Add(psiMSOntology())
+	return idx
+}
+
+// psiMSOntology returns the PSI-MS accessions known to the mzML parser.
+func psiMSOntology() *Ontology {
+	o := &Ontology{Prefix: "MS", Title: "ms", Version: "cicada-builtin", Source: OntologySourceBuiltin}
+	ids := make([]string, 0, len(psiMSTerms))
+	for id := range psiMSTerms {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		o.Terms = append(o.Terms, &OntologyTerm{ID: id, Label: psiMSTerms[id].Name})
+	}
+	return o
+}
+
+// DefaultOntologyDirs returns the project (.cicada/ontologies in the working
+// directory) and user (~/.cicada/ontologies) ontology directories.
+func DefaultOntologyDirs() []string {
+	dirs := []string{filepath.Join(".cicada", "ontologies")}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".cicada", "ontologies"))
+	}
+	return dirs
+}
+
+// LoadOntologies returns the built-in ontologies with the files in dirs
+// added. Earlier directories take precedence over later ones. Files that
+// fail to parse are left out and reported in the error.
+func LoadOntologies(dirs ...string) (*OntologyIndex, error) {
+	idx := BuiltinOntologies()
+	var errs []error
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("read ontology directory: %w", err))
+			}
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isOntologyFile(entry.Name()) {
+				continue
+			}
+			o, err := ReadOntologyFile(filepath.Join(dirs[i], entry.Name()))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			idx.Add(o)
+		}
+	}
+	return idx, errors.Join(errs...)
+}
+
+// ImportOntology checks an ontology file and copies it into dir as
+// <prefix>.obo (or .owl), replacing an earlier import of the same
+// ontology. It returns the parsed ontology and the installed path.
+func ImportOntology(file, dir string) (*Ontology, string, error) {
+	o, err := ReadOntologyFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("read ontology: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, "", fmt.Errorf("create ontology directory: %w", err)
+	}
+
+	stem := strings.ToLower(o.Prefix)
+	ext := strings.ToLower(filepath.Ext(file))
+	dest := filepath.Join(dir, stem+ext)
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return nil, "", fmt.Errorf("write ontology: %w", err)
+	}
+	// An import in another format would shadow this one
+	for _, other := range []string{".obo", ".owl", ".rdf"} {
+		if other != ext {
+			if err := os.Remove(filepath.Join(dir, stem+other)); err != nil && !os.IsNotExist(err) {
+				return nil, "", fmt.Errorf("remove previous import: %w", err)
+			}
+		}
+	}
+	o.Source = dest
+	return o, dest, nil
+}
+
+// OntologyTermURI returns the OBO PURL of a CURIE, such as
+// http://purl.obolibrary.org/obo/NCBITaxon_10090 for NCBITaxon:10090.
+func OntologyTermURI(curie string) string {
+	return oboPURL + strings.Replace(curie, ":", "_", 1)
+}
+
+// OntologySchemeURI returns the OBO PURL of the ontology with the given
+// prefix, such as http://purl.obolibrary.org/obo/ncbitaxon.owl.
+func OntologySchemeURI(prefix string) string {
+	return oboPURL + strings.ToLower(prefix) + ".owl"
+}
+
+// curiePattern matches a CURIE with an OBO-style prefix.
+var curiePattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9.]*):([A-Za-z0-9_.-]+)$`)
+
+// parseCURIE returns the CURIE a value or OBO PURL stands for.
+func parseCURIE(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if curie := curieFromIRI(value); curie != "" {
+		return curie, true
+	}
+	if !curiePattern.MatchString(value) {
+		return "", false
+	}
+	return value, true
+}
+
+// OntologyResolution records a field value resolved to an ontology term.
+type OntologyResolution struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// String describes the resolution.
+func (r OntologyResolution) String() string {
+	return fmt.Sprintf("%s: %q -> %s (%s)", r.Field, r.From, r.ID, r.Label)
+}
+
+// ResolveOntologyFields resolves the string fields named in mappings (field
+// name to ontology prefixes) and stores each term's label in the field and
+// its CURIE in <field>_ontology_term_id. Values that do not resolve, and
+// obsolete terms, are left alone. It returns the fields that changed.
+func ResolveOntologyFields(fields map[string]interface{}, mappings map[string]string, idx *OntologyIndex) []OntologyResolution {
+	names := make([]string, 0, len(mappings))
+	for name := range mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var resolved []OntologyResolution
+	for _, name := range names {
+		value, ok := fields[name].(string)
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+		term, err := idx.Resolve(value, ontologyPrefixes(mappings[name])...)
+		if err != nil || term.Obsolete {
+			continue
+		}
+		idField := name + OntologyTermIDSuffix
+		if value == term.Label && fields[idField] == term.ID {
+			continue
+		}
+		fields[name] = term.Label
+		fields[idField] = term.ID
+		resolved = append(resolved, OntologyResolution{Field: name, From: value, ID: term.ID, Label: term.Label})
+	}
+	return resolved
+}
+
+// SetOntologies sets the ontologies used to check and resolve fields that
+// name an ontology. Without them, such fields are not checked.
+func (sm *SchemaManager) SetOntologies(idx *OntologyIndex) {
+	sm.ontologies = idx
+}
+
+// ResolveOntologyTerms resolves the schema's ontology fields, storing each
+// CURIE next to its label, and records the changes in the metadata's
+// provenance. It returns the changes made.
+func (sm *SchemaManager) ResolveOntologyTerms(metadata *Metadata) ([]OntologyResolution, error) {
+	schema, err := sm.LoadSchema(metadata.SchemaName)
+	if err != nil {
+		return nil, err
+	}
+	if sm.ontologies == nil {
+		return nil, fmt.Errorf("no ontologies loaded")
+	}
+
+	resolved := ResolveOntologyFields(metadata.Fields, schemaOntologies(schema), sm.ontologies)
+	if len(resolved) == 0 {
+		return nil, nil
+	}
+	changes := make([]interface{}, len(resolved))
+	for i, r := range resolved {
+		changes[i] = map[string]interface{}{
+			"field": r.Field,
+			"from":  r.From,
+			"id":    r.ID,
+			"label": r.Label,
+		}
+	}
+	recordWorkflowStep(metadata, "ontology-resolve", map[string]interface{}{
+		"schema":  schema.Name,
+		"changes": changes,
+	})
+	return resolved, nil
+}
+
+// checkOntologyTerm returns a warning if a field's value, or the CURIE
+// stored next to it, is not a current term of the field's ontologies.
+// Ontologies that are not loaded are not checked.
+func (sm *SchemaManager) checkOntologyTerm(name string, value interface{}, ontologies string, fields map[string]interface{}) *ValidationWarning {
+	s, ok := value.(string)
+	if !ok || sm.ontologies == nil {
+		return nil
+	}
+	var loaded []string
+	for _, prefix := range ontologyPrefixes(ontologies) {
+		if sm.ontologies.Has(prefix) {
+			loaded = append(loaded, prefix)
+		}
+	}
+	if len(loaded) == 0 {
+		return nil
+	}
+
+	term, err := sm.ontologies.Resolve(s, loaded...)
+	if err != nil {
+		warning := &ValidationWarning{Field: name, Message: err.Error()}
+		for _, t := range sm.ontologies.Suggest(s, loaded...) {
+			warning.Suggestions = append(warning.Suggestions, fmt.Sprintf("%s (%s)", t.Label, t.ID))
+		}
+		return warning
+	}
+	if term.Obsolete {
+		warning := &ValidationWarning{Field: name, Message: fmt.Sprintf("%s (%s) is obsolete", term.ID, term.Label)}
+		if replacement, ok := sm.ontologies.Term(term.ReplacedBy); ok {
+			warning.Suggestions = []string{fmt.Sprintf("%s (%s)", replacement.Label, replacement.ID)}
+		}
+		return warning
+	}
+	if id, ok := fields[name+OntologyTermIDSuffix].(string); ok && !strings.EqualFold(id, term.ID) {
+		return &ValidationWarning{
+			Field:       name,
+			Message:     fmt.Sprintf("'%s' is %s, but %s%s is %s", s, term.ID, name, OntologyTermIDSuffix, id),
+			Suggestions: []string{term.ID},
+		}
+	}
+	return nil
+}
+
+// schemaOntologies maps each of the schema's ontology fields to its
+// ontology prefixes.
+func schemaOntologies(schema *Schema) map[string]string {
+	mappings := make(map[string]string)
+	for name, ontology := range schema.OntologyMappings {
+		mappings[name] = ontology
+	}
+	for name, field := range schema.Fields {
+		if field.Ontology != "" {
+			mappings[name] = field.Ontology
+		}
+	}
+	return mappings
+}
+
+// fieldOntology returns the ontology prefixes of a schema field, if any.
+func fieldOntology(schema *Schema, name string) string {
+	if field, ok := schema.Fields[name]; ok && field.Ontology != "" {
+		return field.Ontology
+	}
+	return schema.OntologyMappings[name]
+}
+
+// isOntologyTermIDField reports whether name holds the CURIE of one of the
+// schema's ontology fields.
+func isOntologyTermIDField(schema *Schema, name string) bool {
+	base, ok := strings.CutSuffix(name, OntologyTermIDSuffix)
+	return ok && fieldOntology(schema, base) != ""
+}
+
+// ontologyPrefixes splits a list of ontology prefixes such as "MS, EFO".
+func ontologyPrefixes(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// containsFold reports whether list holds s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTerm(terms []*OntologyTerm, term *OntologyTerm) bool {
+	for _, t := range terms {
+		if t == term {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,401 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOBO = `format-version: 1.2
+data-version: releases/2024-06-01
+ontology: uberon
+
+[Term]
+id: UBERON:0002107
+name: liver
+synonym: "hepar" EXACT []
+synonym: "jecur" RELATED []
+synonym: "the \"liver\" organ" EXACT []
+is_a: UBERON:0002075 ! viscus {source="test"}
+
+[Term]
+id: UBERON:0000001
+name: obsolete organ
+is_obsolete: true
+replaced_by: UBERON:0002107
+
+[Typedef]
+id: part_of
+name: part of
+
+[Term]
+id: CL:0000182
+name: hepatocyte
+`
+
+const testOWL = `<?xml version="1.0"?>
+<rdf:RDF xmlns:owl="http://www.w3.org/2002/07/owl#"
+     xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+     xmlns:rdfs="http://www.w3.org/2000/01/rdf-schema#"
+     xmlns:obo="http://purl.obolibrary.org/obo/"
+     xmlns:oboInOwl="http://www.geneontology.org/formats/oboInOwl#">
+    <owl:Ontology rdf:about="http://purl.obolibrary.org/obo/cl.owl">
+        <owl:versionIRI rdf:resource="http://purl.obolibrary.org/obo/cl/releases/2024-01-04/cl.owl"/>
+    </owl:Ontology>
+    <owl:Class rdf:about="http://purl.obolibrary.org/obo/CL_0000084">
+        <rdfs:subClassOf rdf:resource="http://purl.obolibrary.org/obo/CL_0000542"/>
+        <rdfs:subClassOf>
+            <owl:Restriction>
+                <owl:onProperty rdf:resource="http://purl.obolibrary.org/obo/RO_0002215"/>
+            </owl:Restriction>
+        </rdfs:subClassOf>
+        <oboInOwl:hasExactSynonym>T-lymphocyte</oboInOwl:hasExactSynonym>
+        <oboInOwl:hasRelatedSynonym>T-cell</oboInOwl:hasRelatedSynonym>
+        <rdfs:label xml:lang="en">T cell</rdfs:label>
+    </owl:Class>
+    <owl:Class rdf:about="http://purl.obolibrary.org/obo/CL_0000001">
+        <owl:deprecated rdf:datatype="http://www.w3.org/2001/XMLSchema#boolean">true</owl:deprecated>
+        <obo:IAO_0100001 rdf:resource="http://purl.obolibrary.org/obo/CL_0000084"/>
+        <rdfs:label>obsolete cell</rdfs:label>
+    </owl:Class>
+    <owl:Class>
+        <owl:unionOf rdf:parseType="Collection"/>
+    </owl:Class>
+</rdf:RDF>
+`
+
+func TestParseOBO(t *testing.T) {
+	o, err := ParseOBO(strings.NewReader(testOBO))
+	if err != nil {
+		t.Fatalf("ParseOBO() error = %v", err)
+	}
+	if o.Prefix != "UBERON" || o.Title != "uberon" || o.Version != "releases/2024-06-01" {
+		t.Errorf("header = %s/%s/%s, want UBERON/uberon/releases/2024-06-01", o.Prefix, o.Title, o.Version)
+	}
+	if len(o.Terms) != 3 {
+		t.Fatalf("got %d terms, want 3 (typedefs skipped)", len(o.Terms))
+	}
+
+	liver := o.Terms[0]
+	if liver.ID != "UBERON:0002107" || liver.Label != "liver" {
+		t.Errorf("term = %s %q, want UBERON:0002107 liver", liver.ID, liver.Label)
+	}
+	if want := []string{"hepar", `the "liver" organ`}; strings.Join(liver.Synonyms, "|") != strings.Join(want, "|") {
+		t.Errorf("Synonyms = %q, want exact synonyms %q", liver.Synonyms, want)
+	}
+	if len(liver.Parents) != 1 || liver.Parents[0] != "UBERON:0002075" {
+		t.Errorf("Parents = %v, want [UBERON:0002075]", liver.Parents)
+	}
+	if obsolete := o.Terms[1]; !obsolete.Obsolete || obsolete.ReplacedBy != "UBERON:0002107" {
+		t.Errorf("obsolete term = %+v, want obsolete, replaced by UBERON:0002107", obsolete)
+	}
+
+	if _, err := ParseOBO(strings.NewReader("format-version: 1.2\n")); err == nil {
+		t.Error("ParseOBO(no terms) succeeded, want error")
+	}
+	if _, err := ParseOBO(strings.NewReader("[Term]\nid: X:1\nsynonym: unquoted EXACT []\n")); err == nil {
+		t.Error("ParseOBO(bad synonym) succeeded, want error")
+	}
+}
+
+func TestParseOWL(t *testing.T) {
+	o, err := ParseOWL(strings.NewReader(testOWL))
+	if err != nil {
+		t.Fatalf("ParseOWL() error = %v", err)
+	}
+	if o.Prefix != "CL" || o.Title != "cl" || !strings.Contains(o.Version, "2024-01-04") {
+		t.Errorf("header = %s/%s/%s, want CL/cl and the version IRI", o.Prefix, o.Title, o.Version)
+	}
+	if len(o.Terms) != 2 {
+		t.Fatalf("got %d terms, want 2 (anonymous classes skipped)", len(o.Terms))
+	}
+
+	tcell := o.Terms[0]
+	if tcell.ID != "CL:0000084" || tcell.Label != "T cell" {
+		t.Errorf("term = %s %q, want CL:0000084 T cell", tcell.ID, tcell.Label)
+	}
+	if len(tcell.Synonyms) != 1 || tcell.Synonyms[0] != "T-lymphocyte" {
+		t.Errorf("Synonyms = %q, want [T-lymphocyte]", tcell.Synonyms)
+	}
+	if len(tcell.Parents) != 1 || tcell.Parents[0] != "CL:0000542" {
+		t.Errorf("Parents = %v, want [CL:0000542]", tcell.Parents)
+	}
+	if obsolete := o.Terms[1]; !obsolete.Obsolete || obsolete.ReplacedBy != "CL:0000084" {
+		t.Errorf("obsolete term = %+v, want obsolete, replaced by CL:0000084", obsolete)
+	}
+}
+
+func TestBuiltinOntologies(t *testing.T) {
+	idx := BuiltinOntologies()
+	for _, prefix := range []string{"NCBITaxon", "UBERON", "CL", "EFO", "FBbi", "MS"} {
+		if !idx.Has(prefix) {
+			t.Errorf("built-in ontologies lack %s", prefix)
+		}
+	}
+	for _, info := range idx.Ontologies() {
+		if info.Terms == 0 {
+			t.Errorf("%s has no terms", info.Prefix)
+		}
+	}
+}
+
+func TestOntologyIndex_Resolve(t *testing.T) {
+	idx := BuiltinOntologies()
+
+	tests := []struct {
+		value      string
+		ontologies []string
+		want       string
+		wantErr    string
+	}{
+		{"Mus musculus", []string{"NCBITaxon"}, "NCBITaxon:10090", ""},
+		{"mouse", []string{"NCBITaxon"}, "NCBITaxon:10090", ""},
+		{"  HOUSE   mouse ", []string{"NCBITaxon"}, "NCBITaxon:10090", ""},
+		{"NCBITaxon:9606", []string{"NCBITaxon"}, "NCBITaxon:9606", ""},
+		{"ncbitaxon:9606", nil, "NCBITaxon:9606", ""},
+		{"http://purl.obolibrary.org/obo/UBERON_0002107", []string{"UBERON"}, "UBERON:0002107", ""},
+		{"T lymphocyte", []string{"CL"}, "CL:0000084", ""},
+		{"Q Exactive", []string{"MS", "EFO"}, "MS:1001911", ""},
+		{"Illumina NovaSeq 6000", []string{"MS", "EFO"}, "EFO:0008637", ""},
+		{"UBERON:0002107", []string{"NCBITaxon"}, "", "not a term of NCBITaxon"},
+		{"NCBITaxon:123456789", []string{"NCBITaxon"}, "", "not in the NCBITaxon terms available offline"},
+		{"unicorn", []string{"NCBITaxon"}, "", "not a NCBITaxon term"},
+	}
+	for _, tt := range tests {
+		term, err := idx.Resolve(tt.value, tt.ontologies...)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Resolve(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || term.ID != tt.want {
+			t.Errorf("Resolve(%q) = %v, %v, want %s", tt.value, term, err, tt.want)
+		}
+	}
+
+	// A name shared by two terms is ambiguous
+	ambiguous := NewOntologyIndex()
+	ambiguous.Add(&Ontology{Prefix: "X", Terms: []*OntologyTerm{
+		{ID: "X:1", Label: "cold", Synonyms: []string{"chill"}},
+		{ID: "X:2", Label: "chill"},
+	}})
+	if _, err := ambiguous.Resolve("chill", "X"); err == nil || !strings.Contains(err.Error(), "several") {
+		t.Errorf("Resolve(ambiguous) error = %v, want several terms", err)
+	}
+}
+
+func TestOntologyIndex_Suggest(t *testing.T) {
+	idx := BuiltinOntologies()
+	suggestions := idx.Suggest("hepatocite", "CL")
+	if len(suggestions) == 0 || suggestions[0].ID != "CL:0000182" {
+		t.Errorf("Suggest(hepatocite) = %v, want hepatocyte first", suggestions)
+	}
+	if suggestions := idx.Suggest("hepatocite", "NCBITaxon"); len(suggestions) != 0 {
+		t.Errorf("Suggest(hepatocite, NCBITaxon) = %v, want none", suggestions)
+	}
+}
+
+func TestLoadOntologies(t *testing.T) {
+	project := t.TempDir()
+	user := t.TempDir()
+	if err := os.WriteFile(filepath.Join(user, "cl.owl"), []byte(testOWL), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The project relabels a term the user file also defines
+	project1 := "format-version: 1.2\n\n[Term]\nid: CL:0000084\nname: T-cell (project)\n"
+	if err := os.WriteFile(filepath.Join(project, "cl.obo"), []byte(project1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "broken.obo"), []byte("format-version: 1.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := LoadOntologies(project, user)
+	if err == nil || !strings.Contains(err.Error(), "broken.obo") {
+		t.Errorf("LoadOntologies() error = %v, want an error for broken.obo", err)
+	}
+	if term, ok := idx.Term("CL:0000084"); !ok || term.Label != "T-cell (project)" {
+		t.Errorf("Term(CL:0000084) = %v, want the project's label", term)
+	}
+	// The replaced term's old names no longer resolve
+	if _, err := idx.Resolve("T-lymphocyte", "CL"); err == nil {
+		t.Error("Resolve(T-lymphocyte) succeeded, want the replaced term's synonym dropped")
+	}
+	// Built-in terms the files do not define remain
+	if _, ok := idx.Term("CL:0000540"); !ok {
+		t.Error("built-in term CL:0000540 missing")
+	}
+}
+
+func TestImportOntology(t *testing.T) {
+	src := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "ontologies")
+
+	obo := filepath.Join(src, "uberon-subset.obo")
+	if err := os.WriteFile(obo, []byte(testOBO), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o, dest, err := ImportOntology(obo, dir)
+	if err != nil {
+		t.Fatalf("ImportOntology() error = %v", err)
+	}
+	if o.Prefix != "UBERON" || dest != filepath.Join(dir, "uberon.obo") {
+		t.Errorf("ImportOntology() = %s at %s, want UBERON at uberon.obo", o.Prefix, dest)
+	}
+
+	// Importing the same ontology in another format replaces it
+	owl := filepath.Join(src, "uberon.owl")
+	updated := strings.ReplaceAll(strings.ReplaceAll(testOWL, "CL_", "UBERON_"), "cl.owl", "uberon.owl")
+	if err := os.WriteFile(owl, []byte(updated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ImportOntology(owl, dir); err != nil {
+		t.Fatalf("ImportOntology(owl) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "uberon.obo")); !os.IsNotExist(err) {
+		t.Errorf("previous uberon.obo still present (stat error %v)", err)
+	}
+
+	bad := filepath.Join(src, "notes.txt")
+	if err := os.WriteFile(bad, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ImportOntology(bad, dir); err == nil {
+		t.Error("ImportOntology(.txt) succeeded, want error")
+	}
+}
+
+func TestResolveOntologyFields(t *testing.T) {
+	fields := map[string]interface{}{
+		"organism":  "mouse",
+		"tissue":    "UBERON:0002107",
+		"cell_type": "no such cell",
+		"format":    "FASTQ",
+	}
+	resolved := ResolveOntologyFields(fields, DefaultOntologyFields, BuiltinOntologies())
+	if len(resolved) != 2 {
+		t.Fatalf("ResolveOntologyFields() = %v, want 2 resolutions", resolved)
+	}
+	want := map[string]interface{}{
+		"organism":                   "Mus musculus",
+		"organism_ontology_term_id":  "NCBITaxon:10090",
+		"tissue":                     "liver",
+		"tissue_ontology_term_id":    "UBERON:0002107",
+		"cell_type":                  "no such cell",
+		"cell_type_ontology_term_id": nil,
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("fields[%s] = %v, want %v", key, fields[key], value)
+		}
+	}
+
+	// Resolving again changes nothing
+	if again := ResolveOntologyFields(fields, DefaultOntologyFields, BuiltinOntologies()); len(again) != 0 {
+		t.Errorf("second ResolveOntologyFields() = %v, want none", again)
+	}
+}
+
+func TestSchemaManager_OntologyFields(t *testing.T) {
+	idx := BuiltinOntologies()
+	idx.Add(&Ontology{Prefix: "UBERON", Source: "test", Terms: []*OntologyTerm{
+		{ID: "UBERON:0000001", Label: "old organ", Obsolete: true, ReplacedBy: "UBERON:0002107"},
+	}})
+
+	sm := NewSchemaManager(nil)
+	sm.SetOntologies(idx)
+	if err := sm.AddSchema(&Schema{
+		Name: "lab",
+		Fields: map[string]FieldSchema{
+			"organism": {Type: "string", Ontology: "NCBITaxon"},
+			"tissue":   {Type: "string"},
+			"strain":   {Type: "string", Ontology: "MGI"}, // not loaded: not checked
+		},
+		OntologyMappings: map[string]string{"tissue": "UBERON"},
+	}); err != nil {
+		t.Fatalf("AddSchema() error = %v", err)
+	}
+
+	warnings := func(fields map[string]interface{}) map[string]ValidationWarning {
+		result := sm.ValidateMetadata(&Metadata{SchemaName: "lab", Fields: fields})
+		byField := make(map[string]ValidationWarning)
+		for _, w := range result.Warnings {
+			byField[w.Field] = w
+		}
+		return byField
+	}
+
+	got := warnings(map[string]interface{}{
+		"organism":                  "Mus musculus",
+		"organism_ontology_term_id": "NCBITaxon:10090",
+		"tissue":                    "livr",
+		"strain":                    "C57BL/6J",
+	})
+	if _, ok := got["organism"]; ok {
+		t.Errorf("organism warning = %v, want none", got["organism"])
+	}
+	if _, ok := got["organism_ontology_term_id"]; ok {
+		t.Error("organism_ontology_term_id reported as undefined")
+	}
+	if _, ok := got["strain"]; ok {
+		t.Errorf("strain warning = %v, want none for an ontology that is not loaded", got["strain"])
+	}
+	if w, ok := got["tissue"]; !ok || len(w.Suggestions) == 0 || !strings.Contains(w.Suggestions[0], "UBERON:0002107") {
+		t.Errorf("tissue warning = %+v, want a suggestion of liver", w)
+	}
+
+	got = warnings(map[string]interface{}{
+		"organism":                  "Mus musculus",
+		"organism_ontology_term_id": "NCBITaxon:9606",
+		"tissue":                    "UBERON:0000001",
+	})
+	if w := got["organism"]; !strings.Contains(w.Message, "NCBITaxon:9606") {
+		t.Errorf("organism warning = %q, want a mismatch with the stored CURIE", w.Message)
+	}
+	if w := got["tissue"]; !strings.Contains(w.Message, "obsolete") || len(w.Suggestions) != 1 {
+		t.Errorf("tissue warning = %+v, want obsolete with a replacement", w)
+	}
+}
+
+func TestSchemaManager_ResolveOntologyTerms(t *testing.T) {
+	sm := NewSchemaManager(nil)
+	if err := sm.AddSchema(&Schema{
+		Name:   "lab",
+		Fields: map[string]FieldSchema{"organism": {Type: "string", Ontology: "NCBITaxon"}},
+	}); err != nil {
+		t.Fatalf("AddSchema() error = %v", err)
+	}
+
+	metadata := &Metadata{SchemaName: "lab", Fields: map[string]interface{}{"organism": "human"}}
+	if _, err := sm.ResolveOntologyTerms(metadata); err == nil {
+		t.Error("ResolveOntologyTerms() without ontologies succeeded, want error")
+	}
+
+	sm.SetOntologies(BuiltinOntologies())
+	resolved, err := sm.ResolveOntologyTerms(metadata)
+	if err != nil {
+		t.Fatalf("ResolveOntologyTerms() error = %v", err)
+	}
+	if len(resolved) != 1 || metadata.Fields["organism_ontology_term_id"] != "NCBITaxon:9606" || metadata.Fields["organism"] != "Homo sapiens" {
+		t.Errorf("ResolveOntologyTerms() = %v with fields %v, want human resolved", resolved, metadata.Fields)
+	}
+	if len(metadata.Provenance.Workflow) != 1 || metadata.Provenance.Workflow[0].Name != "ontology-resolve" {
+		t.Errorf("Workflow = %+v, want one ontology-resolve step", metadata.Provenance.Workflow)
+	}
+}
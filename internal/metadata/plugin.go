@@ -0,0 +1,469 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # Plugin Extractors
+//
+// This file lets extractors live outside the module as executables written in
+// any language. Plugins are discovered from ~/.cicada/extractors/ and
+// registered ahead of the built-in extractors. A plugin that declares no
+// magic signatures takes over every file with one of its extensions, even
+// one a built-in extractor recognises by content. A plugin with signatures
+// claims only the files that match them, at the confidence it declares.
+//
+// ## Protocol (version 1)
+//
+// Every executable file in the plugin directory is a candidate; hidden files
+// and subdirectories are skipped. Cicada runs plugins with these commands
+// and sets CICADA_PLUGIN_PROTOCOL=1 in their environment:
+//
+//	<plugin> describe
+//	<plugin> extract <path>
+//	<plugin> extract - <name>    (only if the manifest sets "stdin")
+//
+// describe prints a JSON manifest on stdout:
+//
+//	{
+//	  "protocol":   1,
+//	  "name":       "Acme XYZ",
+//	  "version":    "1.2.0",
+//	  "formats":    ["XYZ"],
+//	  "extensions": [".xyz", ".xyz.gz"],
+//	  "magic":      [{"offset": 0, "text": "XYZ1", "confidence": 95}],
+//	  "stdin":      true
+//	}
+//
+// name, formats and extensions are required. magic entries (text, or hex for
+// binary signatures) feed content sniffing (see Sniffer). Without "stdin",
+// streams are written to a temporary file and passed by path.
+//
+// extract prints one JSON object of metadata on stdout and exits 0. A
+// non-zero exit status is a failure, and stderr is reported as the reason.
+// Cicada fills in format, file_name and file_size when the plugin leaves them
+// out, and records the plugin name and version.
+//
+// ## Limits
+//
+// Every call runs under PluginLimits: a wall-clock timeout, a cap on stdout,
+// and on Unix an address-space limit. A plugin that exceeds them is killed
+// along with any processes it started. If the address-space limit cannot be
+// set, the plugin still loads and discovery reports the failure.
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PluginProtocolVersion is the plugin protocol version this build speaks.
+const PluginProtocolVersion = 1
+
+// Default plugin limits.
+const (
+	DefaultPluginTimeout   = 60 * time.Second
+	DefaultPluginMaxOutput = 16 << 20 // 16 MiB of JSON
+	DefaultPluginMaxMemory = 4 << 30  // 4 GiB address space
+)
+
+// pluginDescribeTimeout bounds the describe call made during discovery.
+const pluginDescribeTimeout = 10 * time.Second
+
+// pluginMaxStderr is how much plugin stderr is kept for error messages.
+const pluginMaxStderr = 4096
+
+// pluginNoMemoryLimit is written to stderr by the plugin wrapper when the
+// address-space limit could not be set.
+const pluginNoMemoryLimit = "cicada: plugin memory limit not set"
+
+// errPluginOutputLimit stops reading a plugin whose output is too large.
+var errPluginOutputLimit = errors.New("output limit exceeded")
+
+// PluginLimits bounds the resources a plugin may use per call. Zero values
+// disable the corresponding limit.
+type PluginLimits struct {
+	// Timeout is the wall-clock limit for one extract call.
+	Timeout time.Duration
+
+	// MaxOutput is the largest metadata document accepted, in bytes.
+	MaxOutput int64
+
+	// MaxMemory is the plugin's address-space limit in bytes. It is
+	// enforced on Unix only.
+	MaxMemory int64
+}
+
+// DefaultPluginLimits returns the limits used for discovered plugins.
+func DefaultPluginLimits() PluginLimits {
+	return PluginLimits{
+		Timeout:   DefaultPluginTimeout,
+		MaxOutput: DefaultPluginMaxOutput,
+		MaxMemory: DefaultPluginMaxMemory,
+	}
+}
+
+// PluginManifest is the JSON a plugin prints for "describe".
+type PluginManifest struct {
+	Protocol   int           `json:"protocol"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	Formats    []string      `json:"formats"`
+	Extensions []string      `json:"extensions"`
+	Magic      []PluginMagic `json:"magic,omitempty"`
+	Stdin      bool          `json:"stdin,omitempty"`
+}
+
+// PluginMagic is a content signature declared by a plugin. Exactly one of
+// Text and Hex is set. Confidence defaults to 90.
+type PluginMagic struct {
+	Offset     int    `json:"offset"`
+	Text       string `json:"text,omitempty"`
+	Hex        string `json:"hex,omitempty"`
+	Confidence int    `json:"confidence,omitempty"`
+
+	bytes []byte
+}
+
+// PluginExtractor runs an external executable as an Extractor.
+type PluginExtractor struct {
+	Path     string
+	Manifest PluginManifest
+	Limits   PluginLimits
+
+	// limitErr records that describe ran without the memory limit.
+	limitErr error
+}
+
+// DefaultPluginDir returns ~/.cicada/extractors.
+func DefaultPluginDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home directory: %w", err)
+	}
+	return filepath.Join(home, ".cicada", "extractors"), nil
+}
+
+// NewPluginExtractor runs the plugin's describe command and validates the
+// manifest.
+func NewPluginExtractor(path string, limits PluginLimits) (*PluginExtractor, error) {
+	e := &PluginExtractor{Path: path, Limits: limits}
+
+	describeLimits := limits
+	if describeLimits.Timeout <= 0 || describeLimits.Timeout > pluginDescribeTimeout {
+		describeLimits.Timeout = pluginDescribeTimeout
+	}
+	out, stderr, err := runPlugin(path, describeLimits, []string{"describe"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(stderr, pluginNoMemoryLimit) {
+		e.limitErr = fmt.Errorf("plugin %s: could not set the %d byte memory limit, running without it",
+			filepath.Base(path), limits.MaxMemory)
+	}
+	if err := json.Unmarshal(out, &e.Manifest); err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid manifest: %w", filepath.Base(path), err)
+	}
+	if err := e.Manifest.validate(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", filepath.Base(path), err)
+	}
+	return e, nil
+}
+
+// validate checks required manifest fields and decodes magic signatures.
+func (m *PluginManifest) validate() error {
+	if m.Protocol != PluginProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d (want %d)", m.Protocol, PluginProtocolVersion)
+	}
+	if m.Name == "" {
+		return errors.New("manifest has no name")
+	}
+	if len(m.Formats) == 0 || len(m.Extensions) == 0 {
+		return errors.New("manifest must list formats and extensions")
+	}
+	for i, ext := range m.Extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		m.Extensions[i] = ext
+	}
+	for i := range m.Magic {
+		magic := &m.Magic[i]
+		switch {
+		case magic.Text != "" && magic.Hex == "":
+			magic.bytes = []byte(magic.Text)
+		case magic.Hex != "" && magic.Text == "":
+			b, err := hex.DecodeString(magic.Hex)
+			if err != nil {
+				return fmt.Errorf("magic %d: invalid hex: %w", i, err)
+			}
+			magic.bytes = b
+		default:
+			return fmt.Errorf("magic %d: set exactly one of text and hex", i)
+		}
+		if magic.Offset < 0 || magic.Offset+len(magic.bytes) > sniffHeaderSize {
+			return fmt.Errorf("magic %d: must lie within the first %d bytes", i, sniffHeaderSize)
+		}
+		if magic.Confidence <= 0 {
+			magic.Confidence = 90
+		}
+	}
+	return nil
+}
+
+// DiscoverPlugins loads every plugin in dir. A missing directory yields no
+// plugins. Plugins that fail to describe themselves are skipped and their
+// errors joined into the returned error, as are memory limits that could not
+// be set for plugins that did load.
+func DiscoverPlugins(dir string, limits PluginLimits) ([]*PluginExtractor, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read plugin directory: %w", err)
+	}
+
+	var plugins []*PluginExtractor
+	var errs []error
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path) // follow symlinks
+		if err != nil || !isPluginExecutable(info) {
+			continue
+		}
+		plugin, err := NewPluginExtractor(path, limits)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if plugin.limitErr != nil {
+			errs = append(errs, plugin.limitErr)
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, errors.Join(errs...)
+}
+
+// RegisterPlugins discovers the plugins in dir and registers them ahead of
+// the extractors already in the registry. Plugins that load are registered
+// even if others fail; the failures are returned.
+func (r *ExtractorRegistry) RegisterPlugins(dir string, limits PluginLimits) error {
+	plugins, err := DiscoverPlugins(dir, limits)
+	extractors := make([]Extractor, 0, len(plugins)+len(r.extractors))
+	for _, plugin := range plugins {
+		extractors = append(extractors, plugin)
+	}
+	r.extractors = append(extractors, r.extractors...)
+	return err
+}
+
+// Name returns the plugin's declared name.
+func (e *PluginExtractor) Name() string {
+	return e.Manifest.Name
+}
+
+// SupportedFormats returns the plugin's declared formats.
+func (e *PluginExtractor) SupportedFormats() []string {
+	return e.Manifest.Formats
+}
+
+// CanHandle checks the plugin's declared extensions.
+func (e *PluginExtractor) CanHandle(filename string) bool {
+	name := strings.ToLower(filename)
+	for _, ext := range e.Manifest.Extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sniff matches the plugin's declared magic signatures. Plugins without
+// signatures are scored on their extensions alone, high enough to take the
+// file from the built-in extractors.
+func (e *PluginExtractor) Sniff(in *SniffInput) (int, string) {
+	if len(e.Manifest.Magic) == 0 {
+		if e.CanHandle(in.Name) {
+			return confidencePluginExtension, "extension matches (plugin declares no signature)"
+		}
+		return 0, ""
+	}
+	best, reason := 0, ""
+	for _, magic := range e.Manifest.Magic {
+		if magic.Confidence > best && bytes.HasPrefix(in.Header[min(magic.Offset, len(in.Header)):], magic.bytes) {
+			best = magic.Confidence
+			reason = fmt.Sprintf("plugin signature at offset %d", magic.Offset)
+		}
+	}
+	return best, reason
+}
+
+// Extract runs the plugin on a file.
+func (e *PluginExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	out, _, err := runPlugin(e.Path, e.Limits, []string{"extract", filepath}, nil)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := e.decode(out, filepath)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := metadata["file_size"]; !ok {
+		metadata["file_size"] = info.Size()
+	}
+	return metadata, nil
+}
+
+// ExtractFromReader streams to the plugin's stdin if it accepts streams, and
+// otherwise through a temporary file with the same base name.
+func (e *PluginExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	if e.Manifest.Stdin {
+		out, _, err := runPlugin(e.Path, e.Limits, []string{"extract", "-", filepath.Base(filename)}, r)
+		if err != nil {
+			return nil, err
+		}
+		return e.decode(out, filename)
+	}
+
+	dir, err := os.MkdirTemp("", "cicada-plugin-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, filepath.Base(filename))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("write temp file: %w", err)
+	}
+	return e.Extract(path)
+}
+
+// decode parses the plugin's JSON object and fills in standard fields.
+func (e *PluginExtractor) decode(out []byte, filename string) (map[string]interface{}, error) {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(out, &metadata); err != nil || metadata == nil {
+		if err == nil {
+			err = errors.New("not a JSON object")
+		}
+		return nil, fmt.Errorf("plugin %s returned invalid metadata: %w", e.Manifest.Name, err)
+	}
+
+	if _, ok := metadata["format"]; !ok {
+		metadata["format"] = e.Manifest.Formats[0]
+	}
+	if _, ok := metadata["file_name"]; !ok {
+		metadata["file_name"] = filepath.Base(filename)
+	}
+	metadata["extractor_plugin"] = e.Manifest.Name
+	if e.Manifest.Version != "" {
+		metadata["extractor_plugin_version"] = e.Manifest.Version
+	}
+	return metadata, nil
+}
+
+// runPlugin runs the plugin with args under limits and returns its stdout
+// and the start of its stderr.
+func runPlugin(path string, limits PluginLimits, args []string, stdin io.Reader) ([]byte, string, error) {
+	name := filepath.Base(path)
+	ctx := context.Background()
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	cmd := pluginCommand(ctx, path, args, limits)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("CICADA_PLUGIN_PROTOCOL=%d", PluginProtocolVersion))
+	cmd.Stdin = stdin
+	stdout := &limitedBuffer{max: limits.MaxOutput}
+	stderr := &limitedBuffer{max: pluginMaxStderr, truncate: true}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, "", fmt.Errorf("plugin %s timed out after %s", name, limits.Timeout)
+	case stdout.exceeded:
+		return nil, "", fmt.Errorf("plugin %s: output exceeds %d bytes", name, limits.MaxOutput)
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, "", fmt.Errorf("plugin %s failed: %w: %s", name, err, msg)
+		}
+		return nil, "", fmt.Errorf("plugin %s failed: %w", name, err)
+	}
+	return stdout.Bytes(), stderr.String(), nil
+}
+
+// limitedBuffer collects output up to max bytes (0 means no limit). Past the
+// limit it either drops the rest (truncate) or fails the write, which closes
+// the pipe and stops the plugin. It wraps rather than embeds bytes.Buffer so
+// io.Copy cannot bypass Write through ReadFrom.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int64
+	truncate bool
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 && int64(b.buf.Len()+len(p)) > b.max {
+		if !b.truncate {
+			b.exceeded = true
+			return 0, errPluginOutputLimit
+		}
+		if room := int(b.max) - b.buf.Len(); room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Bytes returns the collected output.
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// String returns the collected output as a string.
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,44 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !unix
+
+package metadata
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pluginCommand builds the command for a plugin call. Only the timeout and
+// output limits apply on this platform.
+func pluginCommand(ctx context.Context, path string, args []string, _ PluginLimits) *exec.Cmd {
+	return exec.CommandContext(ctx, path, args...)
+}
+
+// isPluginExecutable reports whether info is a regular file with an
+// executable extension.
+func isPluginExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(info.Name())) {
+	case ".exe", ".bat", ".cmd", ".com":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPluginManifest is the describe output of the test plugin.
+const testPluginManifest = `{"protocol": 1, "name": "Acme XYZ", "version": "0.1.0", "formats": ["XYZ"], "extensions": [".xyz"], "magic": [{"offset": 0, "text": "XYZ1"}], "stdin": true}`
+
+// writeTestPlugin writes an executable shell script plugin. extract is the
+// body of the extract branch; $2 is the path (or "-") and $3 the stream name.
+func writeTestPlugin(t *testing.T, dir, name, manifest, extract string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins need a Unix shell")
+	}
+	script := "#!/bin/sh\n" +
+		"case \"$1\" in\n" +
+		"describe) cat <<'EOF'\n" + manifest + "\nEOF\n;;\n" +
+		"extract)\n" + extract + "\n;;\n" +
+		"*) echo \"unknown command $1\" >&2; exit 2;;\n" +
+		"esac\n"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testPluginExtract echoes the path or stream name and the input size.
+const testPluginExtract = `if [ "$2" = "-" ]; then
+  size=$(wc -c | tr -d ' ')
+  echo "{\"source\": \"stdin\", \"name\": \"$3\", \"bytes\": $size, \"protocol\": \"$CICADA_PLUGIN_PROTOCOL\"}"
+else
+  echo "{\"source\": \"path\", \"path\": \"$2\", \"instrument_type\": \"acme\"}"
+fi`
+
+func TestDiscoverPlugins(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPlugin(t, dir, "acme-xyz", testPluginManifest, testPluginExtract)
+	writeTestPlugin(t, dir, "broken", `{"protocol": 1, "name": "Broken"}`, "exit 1")
+	writeTestPlugin(t, dir, ".hidden", testPluginManifest, testPluginExtract)
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, err := DiscoverPlugins(dir, DefaultPluginLimits())
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("DiscoverPlugins() error = %v, want error for broken plugin", err)
+	}
+	if len(plugins) != 1 {
+		t.Fatalf("DiscoverPlugins() returned %d plugins, want 1", len(plugins))
+	}
+	p := plugins[0]
+	if p.Name() != "Acme XYZ" || p.SupportedFormats()[0] != "XYZ" {
+		t.Errorf("plugin = %s %v", p.Name(), p.SupportedFormats())
+	}
+	if !p.CanHandle("run1.XYZ") || p.CanHandle("run1.tif") {
+		t.Error("CanHandle() does not follow the declared extensions")
+	}
+
+	if plugins, err := DiscoverPlugins(filepath.Join(dir, "missing"), DefaultPluginLimits()); err != nil || plugins != nil {
+		t.Errorf("DiscoverPlugins(missing) = %v, %v, want nothing", plugins, err)
+	}
+}
+
+func TestPluginExtractor_Extract(t *testing.T) {
+	dir := t.TempDir()
+	plugin, err := NewPluginExtractor(writeTestPlugin(t, dir, "acme", testPluginManifest, testPluginExtract), DefaultPluginLimits())
+	if err != nil {
+		t.Fatalf("NewPluginExtractor() error = %v", err)
+	}
+
+	path := filepath.Join(dir, "run1.xyz")
+	if err := os.WriteFile(path, []byte("XYZ1 data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := plugin.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	want := map[string]interface{}{
+		"source":                   "path",
+		"path":                     path,
+		"instrument_type":          "acme",
+		"format":                   "XYZ",
+		"file_name":                "run1.xyz",
+		"file_size":                int64(9),
+		"extractor_plugin":         "Acme XYZ",
+		"extractor_plugin_version": "0.1.0",
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("Extract()[%s] = %v, want %v", key, metadata[key], value)
+		}
+	}
+
+	metadata, err = plugin.ExtractFromReader(strings.NewReader("XYZ1 streamed"), "dir/run2.xyz")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	if metadata["source"] != "stdin" || metadata["name"] != "run2.xyz" || metadata["bytes"] != 13.0 {
+		t.Errorf("ExtractFromReader() = %v", metadata)
+	}
+	if metadata["protocol"] != "1" {
+		t.Errorf("CICADA_PLUGIN_PROTOCOL = %v, want 1", metadata["protocol"])
+	}
+}
+
+func TestPluginExtractor_ExtractFromReaderTempFile(t *testing.T) {
+	manifest := strings.Replace(testPluginManifest, `"stdin": true`, `"stdin": false`, 1)
+	plugin, err := NewPluginExtractor(writeTestPlugin(t, t.TempDir(), "acme", manifest, testPluginExtract), DefaultPluginLimits())
+	if err != nil {
+		t.Fatalf("NewPluginExtractor() error = %v", err)
+	}
+	metadata, err := plugin.ExtractFromReader(strings.NewReader("XYZ1"), "run3.xyz")
+	if err != nil {
+		t.Fatalf("ExtractFromReader() error = %v", err)
+	}
+	if metadata["source"] != "path" || filepath.Base(metadata["path"].(string)) != "run3.xyz" {
+		t.Errorf("ExtractFromReader() = %v, want temp file named run3.xyz", metadata)
+	}
+	if _, err := os.Stat(metadata["path"].(string)); !os.IsNotExist(err) {
+		t.Errorf("temp file %v was not removed", metadata["path"])
+	}
+}
+
+func TestPluginExtractor_Failures(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "run.xyz")
+	if err := os.WriteFile(input, []byte("XYZ1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		extract string
+		limits  PluginLimits
+		wantErr string
+	}{
+		{"exit status", `echo "unsupported revision" >&2; exit 3`, DefaultPluginLimits(), "unsupported revision"},
+		{"not an object", `echo '[1, 2]'`, DefaultPluginLimits(), "invalid metadata"},
+		{"timeout", `sleep 5; echo '{}'`, PluginLimits{Timeout: 200 * time.Millisecond}, "timed out"},
+		{"output limit", `yes '{"x": 1}'`, PluginLimits{Timeout: 5 * time.Second, MaxOutput: 1024}, "output exceeds 1024 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin, err := NewPluginExtractor(writeTestPlugin(t, t.TempDir(), "acme", testPluginManifest, tt.extract), tt.limits)
+			if err != nil {
+				t.Fatalf("NewPluginExtractor() error = %v", err)
+			}
+			start := time.Now()
+			_, err = plugin.Extract(input)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Extract() error = %v, want %q", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 4*time.Second {
+				t.Errorf("Extract() took %v, plugin was not stopped", elapsed)
+			}
+		})
+	}
+}
+
+func TestPluginManifest_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest PluginManifest
+		wantErr  bool
+	}{
+		{"valid", PluginManifest{Protocol: 1, Name: "A", Formats: []string{"A"}, Extensions: []string{"a"}}, false},
+		{"wrong protocol", PluginManifest{Protocol: 2, Name: "A", Formats: []string{"A"}, Extensions: []string{".a"}}, true},
+		{"no extensions", PluginManifest{Protocol: 1, Name: "A", Formats: []string{"A"}}, true},
+		{"bad hex", PluginManifest{Protocol: 1, Name: "A", Formats: []string{"A"}, Extensions: []string{".a"},
+			Magic: []PluginMagic{{Hex: "zz"}}}, true},
+		{"text and hex", PluginManifest{Protocol: 1, Name: "A", Formats: []string{"A"}, Extensions: []string{".a"},
+			Magic: []PluginMagic{{Text: "A", Hex: "41"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.manifest.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractorRegistry_RegisterPlugins(t *testing.T) {
+	pluginDir := t.TempDir()
+	// Claims .tif files that start with the XYZ1 signature
+	manifest := `{"protocol": 1, "name": "Acme TIFF", "formats": ["XYZ"], "extensions": [".xyz", ".tif"], "magic": [{"offset": 0, "hex": "58595a31", "confidence": 99}]}`
+	writeTestPlugin(t, pluginDir, "acme", manifest, `echo '{"vendor": "acme"}'`)
+
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+	if err := registry.RegisterPlugins(pluginDir, DefaultPluginLimits()); err != nil {
+		t.Fatalf("RegisterPlugins() error = %v", err)
+	}
+	if got := registry.ListExtractors()[0].Name; got != "Acme TIFF" {
+		t.Errorf("first extractor = %s, want the plugin", got)
+	}
+
+	dataDir := t.TempDir()
+	acme := filepath.Join(dataDir, "acme.tif")
+	if err := os.WriteFile(acme, []byte("XYZ1 payload"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := registry.Extract(acme)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if metadata["vendor"] != "acme" {
+		t.Errorf("Extract() = %v, want plugin output", metadata)
+	}
+
+	// A real TIFF still goes to the built-in extractor
+	tiff := filepath.Join(dataDir, "real.tif")
+	if err := os.WriteFile(tiff, buildTestTIFF(t, "plain", false), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.DetectExtractor(tiff); got == nil || got.Name() != "TIFF" {
+		t.Errorf("DetectExtractor(real.tif) = %v, want TIFF", got)
+	}
+}
+
+func TestExtractorRegistry_RegisterPluginsWithoutMagic(t *testing.T) {
+	pluginDir := t.TempDir()
+	// Takes over every .tif file
+	manifest := `{"protocol": 1, "name": "Acme TIFF", "formats": ["TIFF"], "extensions": [".tif"]}`
+	writeTestPlugin(t, pluginDir, "acme", manifest, `echo '{"vendor": "acme"}'`)
+
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+	if err := registry.RegisterPlugins(pluginDir, DefaultPluginLimits()); err != nil {
+		t.Fatalf("RegisterPlugins() error = %v", err)
+	}
+
+	tiff := filepath.Join(t.TempDir(), "real.tif")
+	if err := os.WriteFile(tiff, buildTestTIFF(t, "ImageJ=1.54f", false), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.DetectExtractor(tiff); got == nil || got.Name() != "Acme TIFF" {
+		t.Errorf("DetectExtractor(real.tif) = %v, want the plugin", got)
+	}
+}
+
+func TestDiscoverPlugins_MemoryLimitNotSet(t *testing.T) {
+	dir := t.TempDir()
+	plugin := writeTestPlugin(t, dir, "acme", testPluginManifest, testPluginExtract)
+	// Stand in for the wrapper's report of a failed ulimit
+	script, err := os.ReadFile(plugin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script = []byte(strings.Replace(string(script), "describe) ", "describe) echo '"+pluginNoMemoryLimit+"' >&2; ", 1))
+	if err := os.WriteFile(plugin, script, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, err := DiscoverPlugins(dir, DefaultPluginLimits())
+	if err == nil || !strings.Contains(err.Error(), "memory limit") {
+		t.Errorf("DiscoverPlugins() error = %v, want memory limit warning", err)
+	}
+	if len(plugins) != 1 {
+		t.Errorf("DiscoverPlugins() returned %d plugins, want the plugin loaded anyway", len(plugins))
+	}
+}
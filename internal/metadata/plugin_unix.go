@@ -0,0 +1,51 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// pluginCommand builds the command for a plugin call. The memory limit is
+// applied with the shell's ulimit before exec'ing the plugin; if ulimit fails
+// the plugin still runs and pluginNoMemoryLimit is written to stderr. The plugin
+// runs in its own process group so a timeout kills its children too.
+func pluginCommand(ctx context.Context, path string, args []string, limits PluginLimits) *exec.Cmd {
+	var cmd *exec.Cmd
+	if limits.MaxMemory > 0 {
+		script := fmt.Sprintf(`ulimit -v %d 2>/dev/null || echo '%s' >&2; exec "$0" "$@"`,
+			limits.MaxMemory/1024, pluginNoMemoryLimit)
+		cmd = exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", script, path}, args...)...)
+	} else {
+		cmd = exec.CommandContext(ctx, path, args...)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd
+}
+
+// isPluginExecutable reports whether info is a regular file with an execute
+// bit set.
+func isPluginExecutable(info os.FileInfo) bool {
+	return info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
@@ -0,0 +1,878 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file holds the low-level POD5 reader used by nanopore.go: a minimal
+// FlatBuffers table reader and an Arrow IPC file reader that decodes the
+// column types used by POD5 run info tables. Signal data is never read.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// POD5 container constants
+const (
+	pod5Signature      = "\x8BPOD\r\n\x1A\n"
+	pod5MaxFooterSize  = 16 << 20
+	pod5ContentReads   = 0
+	pod5ContentRunInfo = 4
+)
+
+// Arrow IPC constants
+const (
+	arrowMagic           = "ARROW1"
+	arrowMaxFooterSize   = 16 << 20
+	arrowMaxMetadataSize = 16 << 20
+	arrowMaxBodySize     = 64 << 20
+	arrowMaxDepth        = 16
+)
+
+// Arrow message header types (Message.fbs MessageHeader union)
+const (
+	arrowMsgDictionaryBatch = 2
+	arrowMsgRecordBatch     = 3
+)
+
+// Arrow logical types (Schema.fbs Type union)
+const (
+	arrowNull            = 1
+	arrowInt             = 2
+	arrowFloatingPoint   = 3
+	arrowBinary          = 4
+	arrowUtf8            = 5
+	arrowBool            = 6
+	arrowDecimal         = 7
+	arrowDate            = 8
+	arrowTime            = 9
+	arrowTimestamp       = 10
+	arrowInterval        = 11
+	arrowList            = 12
+	arrowStruct          = 13
+	arrowUnion           = 14
+	arrowFixedSizeBinary = 15
+	arrowFixedSizeList   = 16
+	arrowMap             = 17
+	arrowDuration        = 18
+	arrowLargeBinary     = 19
+	arrowLargeUtf8       = 20
+	arrowLargeList       = 21
+)
+
+// fbTable is a FlatBuffers table. Accessors return zero values for absent
+// fields and for offsets that fall outside the buffer.
+type fbTable struct {
+	buf []byte
+	pos int
+}
+
+func fbU16(b []byte, off int) uint16 {
+	if off < 0 || off+2 > len(b) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b[off:])
+}
+
+func fbU32(b []byte, off int) uint32 {
+	if off < 0 || off+4 > len(b) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b[off:])
+}
+
+func fbU64(b []byte, off int) uint64 {
+	if off < 0 || off+8 > len(b) {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b[off:])
+}
+
+// fbRoot returns the root table of a FlatBuffers buffer.
+func fbRoot(buf []byte) (fbTable, bool) {
+	t := fbTable{buf: buf, pos: int(fbU32(buf, 0))}
+	return t, len(buf) >= 4 && t.vtable() >= 0
+}
+
+// vtable returns the position of the table's vtable, or -1.
+func (t fbTable) vtable() int {
+	if t.pos <= 0 || t.pos+4 > len(t.buf) {
+		return -1
+	}
+	vt := t.pos - int(int32(fbU32(t.buf, t.pos)))
+	if vt < 0 || vt+4 > len(t.buf) {
+		return -1
+	}
+	return vt
+}
+
+// field returns the absolute position of field i, or 0 if it is absent.
+func (t fbTable) field(i int) int {
+	vt := t.vtable()
+	if vt < 0 {
+		return 0
+	}
+	slot := 4 + 2*i
+	if slot+2 > int(fbU16(t.buf, vt)) {
+		return 0
+	}
+	off := int(fbU16(t.buf, vt+slot))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+func (t fbTable) uint8(i int) uint8 {
+	if p := t.field(i); p > 0 && p < len(t.buf) {
+		return t.buf[p]
+	}
+	return 0
+}
+
+func (t fbTable) bool(i int) bool   { return t.uint8(i) != 0 }
+func (t fbTable) int16(i int) int16 { return int16(fbU16(t.buf, t.field(i))) }
+func (t fbTable) int32(i int) int32 { return int32(fbU32(t.buf, t.field(i))) }
+func (t fbTable) int64(i int) int64 { return int64(fbU64(t.buf, t.field(i))) }
+
+// ref follows the offset stored in field i, returning -1 if it is absent.
+func (t fbTable) ref(i int) int {
+	p := t.field(i)
+	if p == 0 || p+4 > len(t.buf) {
+		return -1
+	}
+	target := p + int(fbU32(t.buf, p))
+	if target >= len(t.buf) {
+		return -1
+	}
+	return target
+}
+
+// table returns the sub-table in field i.
+func (t fbTable) table(i int) (fbTable, bool) {
+	p := t.ref(i)
+	if p < 0 {
+		return fbTable{}, false
+	}
+	sub := fbTable{buf: t.buf, pos: p}
+	return sub, sub.vtable() >= 0
+}
+
+// string returns the string in field i.
+func (t fbTable) string(i int) string {
+	p := t.ref(i)
+	if p < 0 {
+		return ""
+	}
+	n := int(fbU32(t.buf, p))
+	if p+4+n > len(t.buf) {
+		return ""
+	}
+	return string(t.buf[p+4 : p+4+n])
+}
+
+// vector returns the element start and length of the vector in field i,
+// checking that elemSize-byte elements fit in the buffer.
+func (t fbTable) vector(i, elemSize int) (int, int) {
+	p := t.ref(i)
+	if p < 0 {
+		return 0, 0
+	}
+	n := int(fbU32(t.buf, p))
+	if n < 0 || p+4+n*elemSize > len(t.buf) {
+		return 0, 0
+	}
+	return p + 4, n
+}
+
+// tables returns the vector of tables in field i.
+func (t fbTable) tables(i int) []fbTable {
+	start, n := t.vector(i, 4)
+	tables := make([]fbTable, 0, n)
+	for k := 0; k < n; k++ {
+		p := start + 4*k
+		sub := fbTable{buf: t.buf, pos: p + int(fbU32(t.buf, p))}
+		if sub.vtable() >= 0 {
+			tables = append(tables, sub)
+		}
+	}
+	return tables
+}
+
+// pod5EmbeddedFile is one embedded Arrow file listed in the POD5 footer.
+type pod5EmbeddedFile struct {
+	offset      int64
+	length      int64
+	contentType int16
+}
+
+// pod5Footer is the decoded POD5 footer.
+type pod5Footer struct {
+	fileIdentifier string
+	software       string
+	version        string
+	contents       []pod5EmbeddedFile
+}
+
+// readPOD5Footer checks both signatures and decodes the footer, which is
+// followed by its 8-byte length, a 16-byte section marker and the signature.
+func readPOD5Footer(r io.ReaderAt, size int64) (*pod5Footer, error) {
+	if size < int64(2*len(pod5Signature)+16+8) {
+		return nil, fmt.Errorf("file too small")
+	}
+	head := make([]byte, len(pod5Signature))
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+	tail := make([]byte, 32)
+	if _, err := r.ReadAt(tail, size-32); err != nil {
+		return nil, fmt.Errorf("failed to read footer: %w", err)
+	}
+	if string(head) != pod5Signature || string(tail[24:]) != pod5Signature {
+		return nil, fmt.Errorf("missing POD5 signature")
+	}
+
+	length := int64(binary.LittleEndian.Uint64(tail))
+	if length <= 0 || length > pod5MaxFooterSize || length > size-32 {
+		return nil, fmt.Errorf("invalid footer length %d", length)
+	}
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, size-32-length); err != nil {
+		return nil, fmt.Errorf("failed to read footer: %w", err)
+	}
+	root, ok := fbRoot(buf)
+	if !ok {
+		return nil, fmt.Errorf("invalid footer")
+	}
+
+	footer := &pod5Footer{
+		fileIdentifier: root.string(0),
+		software:       root.string(1),
+		version:        root.string(2),
+	}
+	for _, file := range root.tables(3) {
+		footer.contents = append(footer.contents, pod5EmbeddedFile{
+			offset:      file.int64(0),
+			length:      file.int64(1),
+			contentType: file.int16(3),
+		})
+	}
+	return footer, nil
+}
+
+// find returns the first embedded file of the given content type.
+func (f *pod5Footer) find(contentType int16) (pod5EmbeddedFile, bool) {
+	for _, file := range f.contents {
+		if file.contentType == contentType {
+			return file, true
+		}
+	}
+	return pod5EmbeddedFile{}, false
+}
+
+// arrowField is one field of an Arrow schema.
+type arrowField struct {
+	name     string
+	typeID   uint8
+	typ      fbTable
+	dictID   int64
+	dict     bool
+	index    fbTable // dictionary index type
+	children []arrowField
+}
+
+// arrowBlock locates a message in an Arrow file.
+type arrowBlock struct {
+	offset  int64
+	metaLen int32
+	bodyLen int64
+}
+
+// arrowFile is an Arrow IPC file embedded at base in r.
+type arrowFile struct {
+	r        io.ReaderAt
+	base     int64
+	size     int64
+	fields   []arrowField
+	batches  []arrowBlock
+	dicts    []arrowBlock
+	dictVals map[int64][]interface{}
+}
+
+// openArrowFile reads the footer of an Arrow IPC file occupying
+// [base, base+size) in r.
+func openArrowFile(r io.ReaderAt, base, size int64) (*arrowFile, error) {
+	if size < 2*8+4 {
+		return nil, fmt.Errorf("arrow file too small")
+	}
+	head := make([]byte, len(arrowMagic))
+	if _, err := r.ReadAt(head, base); err != nil {
+		return nil, fmt.Errorf("failed to read arrow header: %w", err)
+	}
+	tail := make([]byte, 4+len(arrowMagic))
+	if _, err := r.ReadAt(tail, base+size-int64(len(tail))); err != nil {
+		return nil, fmt.Errorf("failed to read arrow footer: %w", err)
+	}
+	if string(head) != arrowMagic || string(tail[4:]) != arrowMagic {
+		return nil, fmt.Errorf("missing arrow magic")
+	}
+
+	length := int64(int32(binary.LittleEndian.Uint32(tail)))
+	if length <= 0 || length > arrowMaxFooterSize || length > size-int64(len(tail))-8 {
+		return nil, fmt.Errorf("invalid arrow footer length %d", length)
+	}
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, base+size-int64(len(tail))-length); err != nil {
+		return nil, fmt.Errorf("failed to read arrow footer: %w", err)
+	}
+	footer, ok := fbRoot(buf)
+	if !ok {
+		return nil, fmt.Errorf("invalid arrow footer")
+	}
+
+	a := &arrowFile{r: r, base: base, size: size, dictVals: map[int64][]interface{}{}}
+	schema, ok := footer.table(1)
+	if !ok {
+		return nil, fmt.Errorf("arrow footer has no schema")
+	}
+	for _, field := range schema.tables(1) {
+		a.fields = append(a.fields, parseArrowField(field, 0))
+	}
+	a.dicts = arrowBlocks(footer, 2)
+	a.batches = arrowBlocks(footer, 3)
+	return a, nil
+}
+
+// arrowBlocks decodes a vector of 24-byte Block structs.
+func arrowBlocks(t fbTable, i int) []arrowBlock {
+	start, n := t.vector(i, 24)
+	blocks := make([]arrowBlock, n)
+	for k := range blocks {
+		p := start + 24*k
+		blocks[k] = arrowBlock{
+			offset:  int64(fbU64(t.buf, p)),
+			metaLen: int32(fbU32(t.buf, p+8)),
+			bodyLen: int64(fbU64(t.buf, p+16)),
+		}
+	}
+	return blocks
+}
+
+// parseArrowField decodes a Field table and its children.
+func parseArrowField(t fbTable, depth int) arrowField {
+	f := arrowField{name: t.string(0), typeID: t.uint8(2)}
+	f.typ, _ = t.table(3)
+	if enc, ok := t.table(4); ok {
+		f.dict = true
+		f.dictID = enc.int64(0)
+		f.index, _ = enc.table(1)
+	}
+	if depth < arrowMaxDepth {
+		for _, child := range t.tables(5) {
+			f.children = append(f.children, parseArrowField(child, depth+1))
+		}
+	}
+	return f
+}
+
+// rows returns the total row count from the record batch headers.
+func (a *arrowFile) rows() (int64, error) {
+	var total int64
+	for _, block := range a.batches {
+		header, err := a.message(block, arrowMsgRecordBatch)
+		if err != nil {
+			return total, err
+		}
+		total += header.int64(0)
+	}
+	return total, nil
+}
+
+// message reads the metadata of the message at block and returns its
+// header table when it has the wanted type.
+func (a *arrowFile) message(block arrowBlock, want uint8) (fbTable, error) {
+	if block.metaLen < 8 || block.metaLen > arrowMaxMetadataSize ||
+		block.offset < 0 || block.offset+int64(block.metaLen) > a.size {
+		return fbTable{}, fmt.Errorf("invalid arrow block")
+	}
+	buf := make([]byte, block.metaLen)
+	if _, err := a.r.ReadAt(buf, a.base+block.offset); err != nil {
+		return fbTable{}, fmt.Errorf("failed to read arrow message: %w", err)
+	}
+	// Messages start with an optional 0xFFFFFFFF continuation marker and
+	// the metadata length.
+	if binary.LittleEndian.Uint32(buf) == 0xFFFFFFFF {
+		buf = buf[8:]
+	} else {
+		buf = buf[4:]
+	}
+	msg, ok := fbRoot(buf)
+	if !ok {
+		return fbTable{}, fmt.Errorf("invalid arrow message")
+	}
+	if msg.uint8(1) != want {
+		return fbTable{}, fmt.Errorf("unexpected arrow message type %d", msg.uint8(1))
+	}
+	header, ok := msg.table(2)
+	if !ok {
+		return fbTable{}, fmt.Errorf("arrow message has no header")
+	}
+	return header, nil
+}
+
+// body reads the body of the message at block.
+func (a *arrowFile) body(block arrowBlock) ([]byte, error) {
+	start := block.offset + int64(block.metaLen)
+	if block.bodyLen < 0 || block.bodyLen > arrowMaxBodySize || start+block.bodyLen > a.size {
+		return nil, fmt.Errorf("invalid arrow body length %d", block.bodyLen)
+	}
+	body := make([]byte, block.bodyLen)
+	if _, err := a.r.ReadAt(body, a.base+start); err != nil {
+		return nil, fmt.Errorf("failed to read arrow body: %w", err)
+	}
+	return body, nil
+}
+
+// readRecords decodes every record batch into one map per row, keyed by
+// column name. Columns of unsupported types are nil.
+func (a *arrowFile) readRecords(limit int) ([]map[string]interface{}, error) {
+	if err := a.readDictionaries(); err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for _, block := range a.batches {
+		header, err := a.message(block, arrowMsgRecordBatch)
+		if err != nil {
+			return records, err
+		}
+		body, err := a.body(block)
+		if err != nil {
+			return records, err
+		}
+		batch, err := newArrowBatch(header, body, a.dictVals)
+		if err != nil {
+			return records, err
+		}
+
+		rows := int(header.int64(0))
+		columns := make(map[string][]interface{}, len(a.fields))
+		for _, field := range a.fields {
+			values, err := batch.decode(field, 0)
+			if err != nil {
+				return records, fmt.Errorf("column %s: %w", field.name, err)
+			}
+			columns[field.name] = values
+		}
+		for row := 0; row < rows && len(records) < limit; row++ {
+			record := make(map[string]interface{}, len(columns))
+			for name, values := range columns {
+				if row < len(values) {
+					record[name] = values[row]
+				}
+			}
+			records = append(records, record)
+		}
+		if len(records) >= limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+// readDictionaries decodes the dictionary batches referenced by the schema.
+func (a *arrowFile) readDictionaries() error {
+	for _, block := range a.dicts {
+		header, err := a.message(block, arrowMsgDictionaryBatch)
+		if err != nil {
+			return err
+		}
+		id := header.int64(0)
+		field, ok := findArrowDictField(a.fields, id)
+		if !ok {
+			continue
+		}
+		data, ok := header.table(1)
+		if !ok {
+			return fmt.Errorf("dictionary %d has no data", id)
+		}
+		body, err := a.body(block)
+		if err != nil {
+			return err
+		}
+		batch, err := newArrowBatch(data, body, a.dictVals)
+		if err != nil {
+			return err
+		}
+		field.dict = false
+		values, err := batch.decode(field, 0)
+		if err != nil {
+			return fmt.Errorf("dictionary %d: %w", id, err)
+		}
+		if header.bool(2) {
+			a.dictVals[id] = append(a.dictVals[id], values...)
+		} else {
+			a.dictVals[id] = values
+		}
+	}
+	return nil
+}
+
+// findArrowDictField finds the dictionary-encoded field with the given id.
+func findArrowDictField(fields []arrowField, id int64) (arrowField, bool) {
+	for _, f := range fields {
+		if f.dict && f.dictID == id {
+			return f, true
+		}
+		if child, ok := findArrowDictField(f.children, id); ok {
+			return child, true
+		}
+	}
+	return arrowField{}, false
+}
+
+// arrowBatch walks the field nodes and buffers of one record batch in
+// schema pre-order.
+type arrowBatch struct {
+	body    []byte
+	nodes   [][2]int64 // length, null count
+	buffers [][2]int64 // offset, length
+	node    int
+	buffer  int
+	dicts   map[int64][]interface{}
+}
+
+var errArrowExhausted = errors.New("arrow batch has too few nodes or buffers")
+
+func newArrowBatch(header fbTable, body []byte, dicts map[int64][]interface{}) (*arrowBatch, error) {
+	if _, ok := header.table(3); ok {
+		return nil, fmt.Errorf("compressed arrow record batches are not supported")
+	}
+	b := &arrowBatch{body: body, dicts: dicts}
+	start, n := header.vector(1, 16)
+	for k := 0; k < n; k++ {
+		p := start + 16*k
+		b.nodes = append(b.nodes, [2]int64{int64(fbU64(header.buf, p)), int64(fbU64(header.buf, p+8))})
+	}
+	start, n = header.vector(2, 16)
+	for k := 0; k < n; k++ {
+		p := start + 16*k
+		b.buffers = append(b.buffers, [2]int64{int64(fbU64(header.buf, p)), int64(fbU64(header.buf, p+8))})
+	}
+	return b, nil
+}
+
+// nextNode returns the length and null count of the next field node.
+func (b *arrowBatch) nextNode() (int, int64, error) {
+	if b.node >= len(b.nodes) {
+		return 0, 0, errArrowExhausted
+	}
+	node := b.nodes[b.node]
+	b.node++
+	if node[0] < 0 || node[0] > arrowMaxBodySize {
+		return 0, 0, fmt.Errorf("invalid arrow node length %d", node[0])
+	}
+	return int(node[0]), node[1], nil
+}
+
+// nextBuffer returns the next buffer's bytes.
+func (b *arrowBatch) nextBuffer() ([]byte, error) {
+	if b.buffer >= len(b.buffers) {
+		return nil, errArrowExhausted
+	}
+	buf := b.buffers[b.buffer]
+	b.buffer++
+	if buf[0] < 0 || buf[1] < 0 || buf[0]+buf[1] > int64(len(b.body)) {
+		return nil, fmt.Errorf("arrow buffer out of range")
+	}
+	return b.body[buf[0] : buf[0]+buf[1]], nil
+}
+
+// skipBuffers advances past n buffers.
+func (b *arrowBatch) skipBuffers(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := b.nextBuffer(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode decodes one field and its children into one value per row.
+func (b *arrowBatch) decode(f arrowField, depth int) ([]interface{}, error) {
+	if depth > arrowMaxDepth {
+		return nil, fmt.Errorf("arrow field nesting too deep")
+	}
+	if f.typeID == arrowNull {
+		n, _, err := b.nextNode()
+		return make([]interface{}, n), err
+	}
+	if f.typeID == arrowUnion {
+		return nil, fmt.Errorf("arrow union columns are not supported")
+	}
+
+	n, nulls, err := b.nextNode()
+	if err != nil {
+		return nil, err
+	}
+	validity, err := b.nextBuffer()
+	if err != nil {
+		return nil, err
+	}
+	valid := func(i int) bool {
+		return nulls == 0 || len(validity) == 0 || (i/8 < len(validity) && validity[i/8]&(1<<(i%8)) != 0)
+	}
+
+	values := make([]interface{}, n)
+	if f.dict {
+		data, err := b.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		dict := b.dicts[f.dictID]
+		for i := range values {
+			index, ok := arrowInteger(data, i, int(f.index.int32(0)), f.index.bool(1))
+			if valid(i) && ok && index >= 0 && index < int64(len(dict)) {
+				values[i] = dict[index]
+			}
+		}
+		return values, nil
+	}
+
+	switch f.typeID {
+	case arrowInt:
+		data, err := b.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			if v, ok := arrowInteger(data, i, int(f.typ.int32(0)), f.typ.bool(1)); ok && valid(i) {
+				values[i] = v
+			}
+		}
+
+	case arrowFloatingPoint:
+		data, err := b.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			if !valid(i) {
+				continue
+			}
+			switch f.typ.int16(0) {
+			case 1:
+				if (i+1)*4 <= len(data) {
+					values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:])))
+				}
+			case 2:
+				if (i+1)*8 <= len(data) {
+					values[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[i*8:]))
+				}
+			}
+		}
+
+	case arrowBool:
+		data, err := b.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			if valid(i) && i/8 < len(data) {
+				values[i] = data[i/8]&(1<<(i%8)) != 0
+			}
+		}
+
+	case arrowTimestamp:
+		data, err := b.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			v, ok := arrowInteger(data, i, 64, true)
+			if !ok || !valid(i) {
+				continue
+			}
+			switch f.typ.int16(0) {
+			case 0:
+				values[i] = time.Unix(v, 0).UTC()
+			case 1:
+				values[i] = time.UnixMilli(v).UTC()
+			case 2:
+				values[i] = time.UnixMicro(v).UTC()
+			case 3:
+				values[i] = time.Unix(0, v).UTC()
+			}
+		}
+
+	case arrowUtf8, arrowBinary, arrowLargeUtf8, arrowLargeBinary:
+		offsets, err := b.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		data, err := b.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		width := 32
+		if f.typeID == arrowLargeUtf8 || f.typeID == arrowLargeBinary {
+			width = 64
+		}
+		for i := range values {
+			start, ok1 := arrowInteger(offsets, i, width, true)
+			end, ok2 := arrowInteger(offsets, i+1, width, true)
+			if !valid(i) || !ok1 || !ok2 || start < 0 || end < start || end > int64(len(data)) {
+				continue
+			}
+			if f.typeID == arrowUtf8 || f.typeID == arrowLargeUtf8 {
+				values[i] = string(data[start:end])
+			} else {
+				values[i] = bytes.Clone(data[start:end])
+			}
+		}
+
+	case arrowList, arrowLargeList, arrowMap:
+		offsets, err := b.nextBuffer()
+		if err != nil {
+			return nil, err
+		}
+		if len(f.children) != 1 {
+			return nil, fmt.Errorf("arrow list field %s has %d children", f.name, len(f.children))
+		}
+		child, err := b.decode(f.children[0], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		width := 32
+		if f.typeID == arrowLargeList {
+			width = 64
+		}
+		for i := range values {
+			start, ok1 := arrowInteger(offsets, i, width, true)
+			end, ok2 := arrowInteger(offsets, i+1, width, true)
+			if !valid(i) || !ok1 || !ok2 || start < 0 || end < start || end > int64(len(child)) {
+				continue
+			}
+			items := child[start:end]
+			if f.typeID == arrowMap {
+				values[i] = arrowMapEntries(items)
+			} else {
+				values[i] = items
+			}
+		}
+
+	case arrowStruct:
+		columns := make([][]interface{}, len(f.children))
+		for k, c := range f.children {
+			if columns[k], err = b.decode(c, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		for i := range values {
+			if !valid(i) {
+				continue
+			}
+			row := make(map[string]interface{}, len(f.children))
+			for k, c := range f.children {
+				if i < len(columns[k]) {
+					row[c.name] = columns[k][i]
+				}
+			}
+			values[i] = row
+		}
+
+	case arrowFixedSizeList:
+		if len(f.children) != 1 {
+			return nil, fmt.Errorf("arrow list field %s has %d children", f.name, len(f.children))
+		}
+		child, err := b.decode(f.children[0], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		size := int(f.typ.int32(0))
+		for i := range values {
+			if valid(i) && size > 0 && (i+1)*size <= len(child) {
+				values[i] = child[i*size : (i+1)*size]
+			}
+		}
+
+	case arrowDecimal, arrowDate, arrowTime, arrowInterval, arrowFixedSizeBinary, arrowDuration:
+		// Values are left nil; only the buffer layout matters.
+		if err := b.skipBuffers(1); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported arrow type %d", f.typeID)
+	}
+	return values, nil
+}
+
+// arrowInteger reads element i of a packed little-endian integer buffer.
+func arrowInteger(data []byte, i, bits int, signed bool) (int64, bool) {
+	size := bits / 8
+	if size <= 0 || i < 0 || (i+1)*size > len(data) {
+		return 0, false
+	}
+	p := data[i*size:]
+	switch bits {
+	case 8:
+		if signed {
+			return int64(int8(p[0])), true
+		}
+		return int64(p[0]), true
+	case 16:
+		if signed {
+			return int64(int16(binary.LittleEndian.Uint16(p))), true
+		}
+		return int64(binary.LittleEndian.Uint16(p)), true
+	case 32:
+		if signed {
+			return int64(int32(binary.LittleEndian.Uint32(p))), true
+		}
+		return int64(binary.LittleEndian.Uint32(p)), true
+	case 64:
+		return int64(binary.LittleEndian.Uint64(p)), true
+	}
+	return 0, false
+}
+
+// arrowMapEntries converts decoded map entries (structs with key and value
+// children) into a map keyed by the string form of each key.
+func arrowMapEntries(entries []interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(entries))
+	for _, entry := range entries {
+		row, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var key, value interface{}
+		for name, v := range row {
+			if name == "key" || name == "keys" {
+				key = v
+			} else {
+				value = v
+			}
+		}
+		if k, ok := key.(string); ok {
+			m[k] = value
+		}
+	}
+	return m
+}
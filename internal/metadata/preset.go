@@ -90,6 +90,7 @@ package metadata
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -192,6 +193,12 @@ func (r *PresetRegistry) RegisterDefaults() {
 	r.Register(illuminaMiSeqPreset())
 	r.Register(illuminaNextSeqPreset())
 
+	// Register long-read sequencing presets
+	r.Register(nanoporeMinIONPreset())
+	r.Register(nanoporePromethIONPreset())
+	r.Register(pacbioSequelPreset())
+	r.Register(pacbioRevioPreset())
+
 	// Register generic presets
 	r.Register(genericMicroscopyPreset())
 	r.Register(genericSequencingPreset())
@@ -216,6 +223,9 @@ func (p *InstrumentPreset) Validate(metadata map[string]interface{}) *PresetVali
 		Present:  []string{},
 	}
 
+	// Match fields extractors emit under aliases ("model", "read_count", ...)
+	metadata = NormalizeFields(metadata)
+
 	// Check required fields
 	for _, req := range p.RequiredFields {
 		value, exists := metadata[req.Name]
@@ -336,6 +346,63 @@ func validateFieldValue(req FieldRequirement, value interface{}) error {
 	return nil
 }
 
+// ParseValue converts text entered by a user, or read from a spreadsheet,
+// to a value of the field's type and checks it against the requirement.
+// Enum values match ignoring case and are returned as declared; arrays are
+// comma-separated.
+func (r FieldRequirement) ParseValue(text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	var value interface{}
+	switch r.Type {
+	case "number":
+		num, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected number, got %q", text)
+		}
+		value = num
+
+	case "boolean":
+		switch strings.ToLower(text) {
+		case "yes", "y":
+			value = true
+		case "no", "n":
+			value = false
+		default:
+			b, err := strconv.ParseBool(text)
+			if err != nil {
+				return nil, fmt.Errorf("expected boolean, got %q", text)
+			}
+			value = b
+		}
+
+	case "array":
+		items := []interface{}{}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value = items
+
+	case "object":
+		return nil, fmt.Errorf("object values cannot be entered as text")
+
+	default:
+		value = text
+		for _, allowed := range r.Enum {
+			if strings.EqualFold(text, allowed) {
+				value = allowed
+				break
+			}
+		}
+	}
+
+	if err := validateFieldValue(r, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
 // QualityScore calculates a quality score (0-100) based on validation results.
 func (v *PresetValidationResult) QualityScore() float64 {
 	if len(v.Present) == 0 && len(v.Missing) == 0 {
@@ -492,6 +559,101 @@ func illuminaNextSeqPreset() *InstrumentPreset {
 	return preset
 }
 
+func nanoporeMinIONPreset() *InstrumentPreset {
+	return &InstrumentPreset{
+		ID:             "nanopore-minion",
+		Name:           "Oxford Nanopore MinION",
+		Manufacturer:   "Oxford Nanopore Technologies",
+		Models:         []string{"MinION", "MinION Mk1B", "MinION Mk1C", "MinION Mk1D", "GridION"},
+		Description:    "Oxford Nanopore MinION and GridION long-read sequencers",
+		InstrumentType: "sequencing",
+		DataTypes:      []string{"nanopore_signal", "nucleotide_sequence"},
+		FileFormats:    []string{".pod5", ".fast5"},
+		RequiredFields: []FieldRequirement{
+			{Name: "format", Type: "string", Description: "File format", Enum: []string{"POD5", "FAST5"}, Example: "POD5"},
+			{Name: "instrument_type", Type: "string", Description: "Instrument type", Enum: []string{"sequencing"}, Example: "sequencing"},
+			{Name: "platform", Type: "string", Description: "Sequencing platform", Enum: []string{"ONT"}, Example: "ONT"},
+			{Name: "run_id", Type: "string", Description: "MinKNOW acquisition (run) ID"},
+			{Name: "flowcell_id", Type: "string", Description: "Flow cell ID", Pattern: `^[A-Z]{3}\d{5}$`, Example: "FAT98765"},
+		},
+		OptionalFields: []FieldRequirement{
+			{Name: "flow_cell_product_code", Type: "string", Description: "Flow cell product code", Pattern: `^FLO-[A-Z0-9]+$`, Example: "FLO-MIN114"},
+			{Name: "library_kit", Type: "string", Description: "Sequencing kit", Pattern: `^SQK-[A-Z0-9-]+$`, Example: "SQK-LSK114"},
+			{Name: "device_id", Type: "string", Description: "Device ID or position", Example: "MN12345"},
+			{Name: "sample_id", Type: "string", Description: "Sample ID entered in MinKNOW"},
+			{Name: "basecall_model", Type: "string", Description: "Basecall model or configuration", Example: "dna_r10.4.1_e8.2_400bps_hac"},
+			{Name: "sample_rate_hz", Type: "number", Description: "Signal sample rate in Hz", MinValue: ptr(1.0)},
+			{Name: "read_count", Type: "number", Description: "Number of reads in the file", MinValue: ptr(0.0)},
+			{Name: "run_date", Type: "string", Format: "date-time", Description: "Run start time"},
+		},
+		Documentation: "Oxford Nanopore MinION and GridION devices write POD5 (or legacy FAST5) raw signal files with MinKNOW run information",
+		References: []string{
+			"https://nanoporetech.com/products/sequence/minion",
+			"https://github.com/nanoporetech/pod5-file-format",
+		},
+	}
+}
+
+func nanoporePromethIONPreset() *InstrumentPreset {
+	preset := nanoporeMinIONPreset()
+	preset.ID = "nanopore-promethion"
+	preset.Name = "Oxford Nanopore PromethION"
+	preset.Models = []string{"PromethION", "P2 Solo", "P2i"}
+	preset.Description = "Oxford Nanopore PromethION and P2 high-throughput long-read sequencers"
+	preset.RequiredFields[4].Example = "PAO12345"
+	preset.OptionalFields[0].Example = "FLO-PRO114M"
+	preset.OptionalFields[2].Example = "1A"
+	preset.References = []string{
+		"https://nanoporetech.com/products/sequence/promethion",
+		"https://github.com/nanoporetech/pod5-file-format",
+	}
+	return preset
+}
+
+func pacbioSequelPreset() *InstrumentPreset {
+	return &InstrumentPreset{
+		ID:             "pacbio-sequel",
+		Name:           "PacBio Sequel",
+		Manufacturer:   "Pacific Biosciences",
+		Models:         []string{"Sequel", "Sequel II", "Sequel IIe"},
+		Description:    "PacBio Sequel series long-read sequencers",
+		InstrumentType: "sequencing",
+		DataTypes:      []string{"nucleotide_sequence"},
+		FileFormats:    []string{".bam", ".subreads.bam", ".hifi_reads.bam"},
+		RequiredFields: []FieldRequirement{
+			{Name: "format", Type: "string", Description: "File format", Enum: []string{"BAM", "SAM"}, Example: "BAM"},
+			{Name: "instrument_type", Type: "string", Description: "Instrument type", Enum: []string{"sequencing"}, Example: "sequencing"},
+			{Name: "platform", Type: "string", Description: "Sequencing platform", Enum: []string{"PacBio"}, Example: "PacBio"},
+			{Name: "movie_name", Type: "string", Description: "SMRT Cell movie name", Pattern: `^m\d+[a-zA-Z]?_\d{6}_\d{6}`, Example: "m64011_190830_220126"},
+		},
+		OptionalFields: []FieldRequirement{
+			{Name: "pacbio_read_type", Type: "string", Description: "Read type", Enum: []string{"SUBREAD", "CCS"}, Example: "CCS"},
+			{Name: "binding_kit", Type: "string", Description: "Binding kit part number", Example: "101-894-200"},
+			{Name: "sequencing_chemistry", Type: "string", Description: "Sequencing kit part number", Example: "101-826-100"},
+			{Name: "basecaller_version", Type: "string", Description: "Basecaller version"},
+			{Name: "instrument_model", Type: "string", Description: "Instrument model", Example: "Sequel II"},
+			{Name: "serial_number", Type: "string", Description: "Instrument serial number", Example: "64011"},
+			{Name: "sample_name", Type: "string", Description: "Biosample name"},
+		},
+		Documentation: "PacBio Sequel instruments write subread and HiFi (CCS) reads as unaligned BAM with run details in the @RG DS tag",
+		References: []string{
+			"https://pacbiofileformats.readthedocs.io/en/latest/BAM.html",
+		},
+	}
+}
+
+func pacbioRevioPreset() *InstrumentPreset {
+	preset := pacbioSequelPreset()
+	preset.ID = "pacbio-revio"
+	preset.Name = "PacBio Revio"
+	preset.Models = []string{"Revio", "Vega"}
+	preset.Description = "PacBio Revio and Vega HiFi long-read sequencers"
+	preset.RequiredFields[3].Example = "m84011_220902_175841_s1"
+	preset.OptionalFields[4].Example = "Revio"
+	preset.OptionalFields[5].Example = "84011"
+	return preset
+}
+
 func genericMicroscopyPreset() *InstrumentPreset {
 	return &InstrumentPreset{
 		ID:             "generic-microscopy",
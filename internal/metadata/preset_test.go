@@ -15,6 +15,7 @@
 package metadata
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -121,6 +122,10 @@ func TestPresetRegistry_RegisterDefaults(t *testing.T) {
 		"illumina-novaseq",
 		"illumina-miseq",
 		"illumina-nextseq",
+		"nanopore-minion",
+		"nanopore-promethion",
+		"pacbio-sequel",
+		"pacbio-revio",
 		"generic-microscopy",
 		"generic-sequencing",
 	}
@@ -594,3 +599,38 @@ func TestGenericSequencingPreset(t *testing.T) {
 		t.Errorf("Valid sequencing metadata failed validation: %v", result.Errors)
 	}
 }
+
+func TestFieldRequirement_ParseValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     FieldRequirement
+		text    string
+		want    interface{}
+		wantErr bool
+	}{
+		{"String", FieldRequirement{Type: "string"}, " jdoe ", "jdoe", false},
+		{"Enum ignores case", FieldRequirement{Type: "string", Enum: []string{"R1", "R2"}}, "r1", "R1", false},
+		{"Enum rejects other values", FieldRequirement{Type: "string", Enum: []string{"R1", "R2"}}, "R3", nil, true},
+		{"Pattern", FieldRequirement{Type: "string", Pattern: `^S\d+$`}, "S12", "S12", false},
+		{"Pattern mismatch", FieldRequirement{Type: "string", Pattern: `^S\d+$`}, "sample", nil, true},
+		{"Number", FieldRequirement{Type: "number", MinValue: ptr(0)}, "42.5", 42.5, false},
+		{"Number out of range", FieldRequirement{Type: "number", MinValue: ptr(0)}, "-1", nil, true},
+		{"Not a number", FieldRequirement{Type: "number"}, "many", nil, true},
+		{"Boolean", FieldRequirement{Type: "boolean"}, "true", true, false},
+		{"Boolean yes", FieldRequirement{Type: "boolean"}, "Yes", true, false},
+		{"Not a boolean", FieldRequirement{Type: "boolean"}, "maybe", nil, true},
+		{"Array", FieldRequirement{Type: "array"}, "a, b,,c", []interface{}{"a", "b", "c"}, false},
+		{"Object", FieldRequirement{Type: "object"}, "{}", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.req.ParseValue(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
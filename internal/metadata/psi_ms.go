@@ -0,0 +1,186 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import "strings"
+
+// PSI-MS controlled vocabulary accessions used by the mzML parser.
+// https://github.com/HUPO-PSI/psi-ms-CV
+const (
+	psiMSLevel             = "MS:1000511"
+	psiMS1Spectrum         = "MS:1000579"
+	psiMSnSpectrum         = "MS:1000580"
+	psiPositiveScan        = "MS:1000130"
+	psiNegativeScan        = "MS:1000129"
+	psiCentroidSpectrum    = "MS:1000127"
+	psiProfileSpectrum     = "MS:1000128"
+	psiScanStartTime       = "MS:1000016"
+	psiScanWindowLower     = "MS:1000501"
+	psiScanWindowUpper     = "MS:1000500"
+	psiInstrumentSerial    = "MS:1000529"
+	psiInstrumentModel     = "MS:1000031"
+	psiUnitMinute          = "UO:0000031"
+	psiUnitSecond          = "UO:0000010"
+	psiUnitMillisecond     = "UO:0000028"
+	psiActivationCID       = "MS:1000133"
+	psiActivationHCD       = "MS:1000422"
+	psiActivationETD       = "MS:1000598"
+	psiActivationECD       = "MS:1000250"
+	psiActivationEThcD     = "MS:1002631"
+	psiActivationPhotoDiss = "MS:1000435"
+)
+
+// psiMSTerm is an offline PSI-MS CV entry.
+type psiMSTerm struct {
+	Name   string
+	Vendor string // for instrument models, the manufacturer
+}
+
+// psiMSTerms is the subset of the PSI-MS CV needed to name instruments,
+// sources, analyzers, detectors, software and activation methods. Terms not
+// listed here fall back to the name attribute written in the file.
+var psiMSTerms = map[string]psiMSTerm{
+	// Vendor instrument model branches
+	"MS:1000031": {Name: "instrument model"},
+	"MS:1000483": {Name: "Thermo Fisher Scientific instrument model", Vendor: "Thermo Fisher Scientific"},
+	"MS:1000492": {Name: "Thermo Electron instrument model", Vendor: "Thermo Fisher Scientific"},
+	"MS:1000494": {Name: "Thermo Scientific instrument model", Vendor: "Thermo Fisher Scientific"},
+	"MS:1000126": {Name: "Waters instrument model", Vendor: "Waters"},
+	"MS:1000122": {Name: "Bruker Daltonics instrument model", Vendor: "Bruker"},
+	"MS:1000121": {Name: "SCIEX instrument model", Vendor: "SCIEX"},
+	"MS:1000490": {Name: "Agilent instrument model", Vendor: "Agilent"},
+	"MS:1000124": {Name: "Shimadzu instrument model", Vendor: "Shimadzu"},
+
+	// Instrument models
+	"MS:1000447": {Name: "LTQ", Vendor: "Thermo Fisher Scientific"},
+	"MS:1000449": {Name: "LTQ Orbitrap", Vendor: "Thermo Fisher Scientific"},
+	"MS:1000556": {Name: "LTQ Orbitrap XL", Vendor: "Thermo Fisher Scientific"},
+	"MS:1001742": {Name: "LTQ Orbitrap Velos", Vendor: "Thermo Fisher Scientific"},
+	"MS:1001910": {Name: "LTQ Orbitrap Elite", Vendor: "Thermo Fisher Scientific"},
+	"MS:1001911": {Name: "Q Exactive", Vendor: "Thermo Fisher Scientific"},
+	"MS:1002523": {Name: "Q Exactive HF", Vendor: "Thermo Fisher Scientific"},
+	"MS:1002634": {Name: "Q Exactive Plus", Vendor: "Thermo Fisher Scientific"},
+	"MS:1002877": {Name: "Q Exactive HF-X", Vendor: "Thermo Fisher Scientific"},
+	"MS:1002416": {Name: "Orbitrap Fusion", Vendor: "Thermo Fisher Scientific"},
+	"MS:1002732": {Name: "Orbitrap Fusion Lumos", Vendor: "Thermo Fisher Scientific"},
+	"MS:1003028": {Name: "Orbitrap Exploris 480", Vendor: "Thermo Fisher Scientific"},
+	"MS:1003029": {Name: "Orbitrap Eclipse", Vendor: "Thermo Fisher Scientific"},
+	"MS:1003005": {Name: "timsTOF Pro", Vendor: "Bruker"},
+	"MS:1000932": {Name: "TripleTOF 5600", Vendor: "SCIEX"},
+	"MS:1002533": {Name: "TripleTOF 6600", Vendor: "SCIEX"},
+
+	// Ionization types
+	"MS:1000008": {Name: "ionization type"},
+	"MS:1000073": {Name: "electrospray ionization"},
+	"MS:1000398": {Name: "nanoelectrospray"},
+	"MS:1000070": {Name: "atmospheric pressure chemical ionization"},
+	"MS:1000382": {Name: "atmospheric pressure photoionization"},
+	"MS:1000075": {Name: "matrix-assisted laser desorption ionization"},
+
+	// Mass analyzer types
+	"MS:1000443": {Name: "mass analyzer type"},
+	"MS:1000484": {Name: "orbitrap"},
+	"MS:1000081": {Name: "quadrupole"},
+	"MS:1000084": {Name: "time-of-flight"},
+	"MS:1000264": {Name: "ion trap"},
+	"MS:1000082": {Name: "quadrupole ion trap"},
+	"MS:1000291": {Name: "linear ion trap"},
+	"MS:1000079": {Name: "fourier transform ion cyclotron resonance mass spectrometer"},
+	"MS:1000080": {Name: "magnetic sector"},
+
+	// Detector types
+	"MS:1000026": {Name: "detector type"},
+	"MS:1000624": {Name: "inductive detector"},
+	"MS:1000253": {Name: "electron multiplier"},
+	"MS:1000114": {Name: "microchannel plate detector"},
+
+	// Software
+	"MS:1000531": {Name: "software"},
+	"MS:1000532": {Name: "Xcalibur"},
+	"MS:1000615": {Name: "ProteoWizard software"},
+	"MS:1000551": {Name: "Analyst"},
+	"MS:1000534": {Name: "MassLynx"},
+	"MS:1000799": {Name: "custom unreleased software tool"},
+
+	// Activation methods
+	psiActivationCID:       {Name: "collision-induced dissociation"},
+	psiActivationHCD:       {Name: "beam-type collision-induced dissociation"},
+	psiActivationETD:       {Name: "electron transfer dissociation"},
+	psiActivationECD:       {Name: "electron capture dissociation"},
+	psiActivationEThcD:     {Name: "electron transfer/higher-energy collision dissociation"},
+	psiActivationPhotoDiss: {Name: "photodissociation"},
+
+	// Spectrum and scan terms
+	psiMSLevel:          {Name: "ms level"},
+	psiMS1Spectrum:      {Name: "MS1 spectrum"},
+	psiMSnSpectrum:      {Name: "MSn spectrum"},
+	psiPositiveScan:     {Name: "positive scan"},
+	psiNegativeScan:     {Name: "negative scan"},
+	psiCentroidSpectrum: {Name: "centroid spectrum"},
+	psiProfileSpectrum:  {Name: "profile spectrum"},
+	psiScanStartTime:    {Name: "scan start time"},
+	psiScanWindowLower:  {Name: "scan window lower limit"},
+	psiScanWindowUpper:  {Name: "scan window upper limit"},
+	psiInstrumentSerial: {Name: "instrument serial number"},
+}
+
+// psiActivationTerms lists the accessions that name fragmentation methods.
+var psiActivationTerms = map[string]string{
+	psiActivationCID:       "CID",
+	psiActivationHCD:       "HCD",
+	psiActivationETD:       "ETD",
+	psiActivationECD:       "ECD",
+	psiActivationEThcD:     "EThcD",
+	psiActivationPhotoDiss: "PD",
+}
+
+// psiMSName resolves a CV accession to its term name, falling back to the
+// name written in the file for accessions not in the offline table.
+func psiMSName(accession, fileName string) string {
+	if term, ok := psiMSTerms[accession]; ok {
+		return term.Name
+	}
+	return fileName
+}
+
+// psiMSVendor returns the manufacturer of an instrument model, using the
+// offline table first and well-known model name prefixes second.
+func psiMSVendor(accession, modelName string) string {
+	if term, ok := psiMSTerms[accession]; ok && term.Vendor != "" {
+		return term.Vendor
+	}
+
+	lower := strings.ToLower(modelName)
+	for _, hint := range []struct{ prefix, vendor string }{
+		{"orbitrap", "Thermo Fisher Scientific"},
+		{"q exactive", "Thermo Fisher Scientific"},
+		{"ltq", "Thermo Fisher Scientific"},
+		{"tsq", "Thermo Fisher Scientific"},
+		{"exploris", "Thermo Fisher Scientific"},
+		{"timstof", "Bruker"},
+		{"maxis", "Bruker"},
+		{"impact", "Bruker"},
+		{"tripletof", "SCIEX"},
+		{"qtrap", "SCIEX"},
+		{"zenotof", "SCIEX"},
+		{"synapt", "Waters"},
+		{"xevo", "Waters"},
+	} {
+		if strings.HasPrefix(lower, hint.prefix) {
+			return hint.vendor
+		}
+	}
+	return ""
+}
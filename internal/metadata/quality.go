@@ -0,0 +1,312 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file computes QualityScore from the metadata and its schema.
+//
+// Each dimension is the share of its signals that are met:
+//
+//   - Completeness: schema fields that are filled, with required fields
+//     (including required_if fields whose condition holds) counting three
+//     times as much as optional ones.
+//   - Consistency: checks on the filled fields that pass: type, controlled
+//     vocabulary, pattern and range, plus every validation rule that applies.
+//   - Richness: optional schema fields that are filled.
+//   - Interoperability: ontology-mapped fields that are filled, unit-bearing
+//     fields whose values use known units, and whether the file format is
+//     one the schema lists.
+//
+// Fields the schema does not define are not scored, except that names in
+// required_fields always count towards completeness. A dimension with no
+// signals scores 100 and is left out of the overall score, which weighs
+// the others by the schema's QualityWeights.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// requiredWeight is how much more a required field counts than an optional
+// one towards completeness.
+const requiredWeight = 3
+
+// maxQualityExamples caps the examples listed in a detail line.
+const maxQualityExamples = 3
+
+// QualityWeights weigh the quality dimensions in the overall score. Weights
+// are relative: they need not add up to one.
+type QualityWeights struct {
+	Completeness     float64 `yaml:"completeness" json:"completeness"`
+	Consistency      float64 `yaml:"consistency" json:"consistency"`
+	Richness         float64 `yaml:"richness" json:"richness"`
+	Interoperability float64 `yaml:"interoperability" json:"interoperability"`
+}
+
+// DefaultQualityWeights returns the weights used by schemas that set none.
+func DefaultQualityWeights() QualityWeights {
+	return QualityWeights{
+		Completeness:     0.4,
+		Consistency:      0.3,
+		Richness:         0.15,
+		Interoperability: 0.15,
+	}
+}
+
+// validate reports negative weights or weights that are all zero.
+func (w QualityWeights) validate() error {
+	if w.Completeness < 0 || w.Consistency < 0 || w.Richness < 0 || w.Interoperability < 0 {
+		return fmt.Errorf("quality weights must not be negative")
+	}
+	if w.Completeness+w.Consistency+w.Richness+w.Interoperability == 0 {
+		return fmt.Errorf("quality weights must not all be zero")
+	}
+	return nil
+}
+
+// qualityTally counts the signals met for one dimension.
+type qualityTally struct {
+	met, total float64
+	misses     []string
+}
+
+func (t *qualityTally) add(ok bool, weight float64, miss string) {
+	t.total += weight
+	if ok {
+		t.met += weight
+	} else if miss != "" {
+		t.misses = append(t.misses, miss)
+	}
+}
+
+// score returns the share met, 0-100, and whether there were any signals.
+func (t *qualityTally) score() (int, bool) {
+	if t.total == 0 {
+		return 100, false
+	}
+	return int(math.Round(t.met / t.total * 100)), true
+}
+
+// examples lists the first few misses for a detail line.
+func (t *qualityTally) examples() string {
+	if len(t.misses) == 0 {
+		return ""
+	}
+	shown := t.misses
+	if len(shown) > maxQualityExamples {
+		shown = shown[:maxQualityExamples]
+	}
+	more := ""
+	if n := len(t.misses) - len(shown); n > 0 {
+		more = fmt.Sprintf(" and %d more", n)
+	}
+	return fmt.Sprintf(" (%s%s)", strings.Join(shown, ", "), more)
+}
+
+func calculateQualityScore(schema *Schema, metadata *Metadata) *QualityScore {
+	score := &QualityScore{}
+	names := sortedFieldNames(schema.Fields)
+
+	required := make(map[string]bool)
+	for _, name := range schema.RequiredFields {
+		required[name] = true
+	}
+	for _, name := range names {
+		field := schema.Fields[name]
+		if field.Required {
+			required[name] = true
+		}
+		if field.RequiredIf != "" {
+			if ok, err := EvalRule(field.RequiredIf, metadata.Fields); err == nil && ok {
+				required[name] = true
+			}
+		}
+	}
+	// Required fields need not be defined in Fields; they still count
+	for _, name := range schema.RequiredFields {
+		if _, ok := schema.Fields[name]; !ok && !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var completeness, consistency, richness, interop qualityTally
+	var requiredFilled, requiredTotal, optionalFilled, optionalTotal int
+	for _, name := range names {
+		field, defined := schema.Fields[name]
+		value, filled := metadata.Fields[name]
+		filled = filled && isFilled(value)
+
+		// Completeness and richness
+		if required[name] {
+			requiredTotal++
+			if filled {
+				requiredFilled++
+			}
+			completeness.add(filled, requiredWeight, name)
+		} else {
+			optionalTotal++
+			if filled {
+				optionalFilled++
+			}
+			completeness.add(filled, 1, "")
+			richness.add(filled, 1, name)
+		}
+
+		// Interoperability: ontology-mapped fields
+		if field.Ontology != "" || schema.OntologyMappings[name] != "" {
+			interop.add(filled, 1, name+" (ontology)")
+		}
+
+		if !filled || !defined {
+			continue
+		}
+
+		// Interoperability: values in known units
+		if field.Units != "" {
+			converted, _, err := normalizeQuantity(value, field.Units)
+			_, numeric := numericValue(converted)
+			interop.add(err == nil && numeric, 1, name+" (units)")
+			if err == nil {
+				value = converted
+			}
+		}
+
+		// Consistency
+		consistency.add(isValidType(value, field.Type), 1, name+" (type)")
+		if len(field.Vocabulary) > 0 {
+			s, ok := value.(string)
+			consistency.add(ok && contains(field.Vocabulary, s), 1, fmt.Sprintf("%s %v not in vocabulary", name, value))
+		}
+		if field.Pattern != "" {
+			if s, ok := value.(string); ok {
+				matched, _ := regexp.MatchString(field.Pattern, s)
+				consistency.add(matched, 1, name+" (pattern)")
+			}
+		}
+		if field.Range != nil {
+			consistency.add(checkRange(value, field), 1, name+" (range)")
+		}
+	}
+
+	// Consistency: cross-field rules that apply
+	for _, rule := range schema.ValidationRules {
+		ok, err := EvalRule(rule.Rule, metadata.Fields)
+		var exprErr *ExprError
+		if errors.As(err, &exprErr) && exprErr.Missing {
+			continue
+		}
+		consistency.add(err == nil && ok, 1, "rule "+rule.Rule)
+	}
+
+	// Interoperability: standard file formats
+	format := metadata.FileInfo.Format
+	if s, ok := metadata.Fields["format"].(string); ok && s != "" {
+		format = s
+	}
+	formats := schemaFormats(schema.FileFormats)
+	if format != "" && len(formats) > 0 {
+		listed := false
+		for _, f := range formats {
+			if strings.EqualFold(f, format) {
+				listed = true
+			}
+		}
+		interop.add(listed, 1, "format "+format+" not listed")
+	}
+
+	weights := DefaultQualityWeights()
+	if schema.QualityWeights != nil {
+		weights = *schema.QualityWeights
+	}
+
+	var details []string
+	var weighted, weightSum float64
+	dimension := func(label string, tally *qualityTally, weight float64, explain, unscored string) int {
+		value, applicable := tally.score()
+		if !applicable {
+			details = append(details, fmt.Sprintf("%s: not scored, %s", label, unscored))
+			return value
+		}
+		weighted += float64(value) * weight
+		weightSum += weight
+		details = append(details, fmt.Sprintf("%s %d/100: %s%s", label, value, explain, tally.examples()))
+		return value
+	}
+	score.Completeness = dimension("Completeness", &completeness, weights.Completeness,
+		fmt.Sprintf("%d of %d required and %d of %d optional fields filled", requiredFilled, requiredTotal, optionalFilled, optionalTotal),
+		"the schema defines no fields")
+	score.Consistency = dimension("Consistency", &consistency, weights.Consistency,
+		fmt.Sprintf("%g of %g checks passed", consistency.met, consistency.total),
+		"no filled fields to check")
+	score.Richness = dimension("Richness", &richness, weights.Richness,
+		fmt.Sprintf("%d of %d optional fields filled", optionalFilled, optionalTotal),
+		"the schema has no optional fields")
+	score.Interoperability = dimension("Interoperability", &interop, weights.Interoperability,
+		fmt.Sprintf("%g of %g ontology, unit and format signals met", interop.met, interop.total),
+		"no ontology mappings, units or file formats apply")
+
+	score.Overall = 100
+	if weightSum > 0 {
+		score.Overall = int(math.Round(weighted / weightSum))
+	}
+	details = append(details, fmt.Sprintf("Overall %d/100: weights completeness %g, consistency %g, richness %g, interoperability %g",
+		score.Overall, weights.Completeness, weights.Consistency, weights.Richness, weights.Interoperability))
+
+	undefined := 0
+	for name := range metadata.Fields {
+		if _, ok := schema.Fields[name]; !ok && !required[name] && !isOntologyTermIDField(schema, name) {
+			undefined++
+		}
+	}
+	if undefined > 0 {
+		details = append(details, fmt.Sprintf("%d fields not defined in the schema were not scored", undefined))
+	}
+
+	score.Details = details
+	return score
+}
+
+// isFilled reports whether a value is present: not nil, a blank string or
+// an empty list or object.
+func isFilled(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s) != ""
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() > 0
+	case reflect.Pointer, reflect.Interface:
+		return !rv.IsNil()
+	}
+	return true
+}
+
+// schemaFormats returns every format the schema lists.
+func schemaFormats(f FileFormats) []string {
+	var formats []string
+	for _, list := range [][]string{f.Primary, f.Processed, f.Raw, f.Metadata} {
+		formats = append(formats, list...)
+	}
+	return formats
+}
@@ -0,0 +1,175 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// testQualitySchema has two required fields, three optional ones, a
+// vocabulary, an ontology mapping, units, a rule and file formats.
+func testQualitySchema() *Schema {
+	return &Schema{
+		Name:           "quality",
+		RequiredFields: []string{"format", "image_width"},
+		Fields: map[string]FieldSchema{
+			"format":           {Type: "string"},
+			"image_width":      {Type: "integer", Range: &Range{Min: 1}},
+			"modality":         {Type: "string", Vocabulary: []string{"confocal", "widefield"}},
+			"organism":         {Type: "string", Ontology: "NCBITaxon"},
+			"exposure_time_ms": {Type: "number", Units: "ms"},
+		},
+		ValidationRules: []ValidationRule{{Rule: "image_width <= 100000"}},
+		FileFormats:     FileFormats{Primary: []string{"CZI", "LIF"}},
+	}
+}
+
+func TestCalculateQualityScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		want   QualityScore
+	}{
+		{
+			name: "complete",
+			fields: map[string]interface{}{
+				"format":           "CZI",
+				"image_width":      2048,
+				"modality":         "confocal",
+				"organism":         "Homo sapiens",
+				"exposure_time_ms": "0.5 s",
+			},
+			want: QualityScore{Overall: 100, Completeness: 100, Consistency: 100, Richness: 100, Interoperability: 100},
+		},
+		{
+			// Completeness (3+3+1)/9; consistency 5 of 6 checks with the rule;
+			// interoperability: ontology missing, format not listed
+			name: "sparse",
+			fields: map[string]interface{}{
+				"format":      "TIFF",
+				"image_width": 2048,
+				"modality":    "Confocal",
+				"extra":       "not scored",
+				"organism":    "",
+			},
+			want: QualityScore{Overall: 61, Completeness: 78, Consistency: 83, Richness: 33, Interoperability: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateQualityScore(testQualitySchema(), &Metadata{Fields: tt.fields})
+			if got.Overall != tt.want.Overall || got.Completeness != tt.want.Completeness ||
+				got.Consistency != tt.want.Consistency || got.Richness != tt.want.Richness ||
+				got.Interoperability != tt.want.Interoperability {
+				t.Errorf("calculateQualityScore() = %+v, want %+v", *got, tt.want)
+			}
+			if len(got.Details) < 5 {
+				t.Errorf("Details = %v, want one line per dimension and overall", got.Details)
+			}
+		})
+	}
+}
+
+func TestCalculateQualityScore_Details(t *testing.T) {
+	score := calculateQualityScore(testQualitySchema(), &Metadata{Fields: map[string]interface{}{
+		"format":      "TIFF",
+		"image_width": 2048,
+		"modality":    "Confocal",
+		"extra":       1,
+	}})
+	details := strings.Join(score.Details, "\n")
+	for _, want := range []string{
+		"Completeness 78/100: 2 of 2 required and 1 of 3 optional fields filled",
+		"modality Confocal not in vocabulary",
+		"Richness 33/100: 1 of 3 optional fields filled (exposure_time_ms, organism)",
+		"organism (ontology)",
+		"format TIFF not listed",
+		"1 fields not defined in the schema were not scored",
+	} {
+		if !strings.Contains(details, want) {
+			t.Errorf("Details missing %q:\n%s", want, details)
+		}
+	}
+}
+
+func TestCalculateQualityScore_Weights(t *testing.T) {
+	fields := map[string]interface{}{"format": "TIFF", "image_width": 2048, "modality": "Confocal"}
+
+	schema := testQualitySchema()
+	schema.QualityWeights = &QualityWeights{Completeness: 1}
+	if got := calculateQualityScore(schema, &Metadata{Fields: fields}); got.Overall != got.Completeness {
+		t.Errorf("Overall = %d, want completeness %d alone", got.Overall, got.Completeness)
+	}
+
+	schema.QualityWeights = &QualityWeights{Consistency: 2, Interoperability: 2}
+	if got := calculateQualityScore(schema, &Metadata{Fields: fields}); got.Overall != int(math.Round(float64(got.Consistency+got.Interoperability)/2)) {
+		t.Errorf("Overall = %d, want mean of consistency %d and interoperability %d", got.Overall, got.Consistency, got.Interoperability)
+	}
+}
+
+func TestCalculateQualityScore_UndefinedRequiredField(t *testing.T) {
+	// sample_id is required but has no field definition
+	schema := testQualitySchema()
+	schema.RequiredFields = append(schema.RequiredFields, "sample_id")
+	fields := map[string]interface{}{"format": "CZI", "image_width": 2048}
+
+	missing := calculateQualityScore(schema, &Metadata{Fields: fields})
+	details := strings.Join(missing.Details, "\n")
+	if !strings.Contains(details, "2 of 3 required") || !strings.Contains(details, "sample_id") {
+		t.Errorf("Details = %s, want sample_id scored as missing", details)
+	}
+
+	fields["sample_id"] = "S1"
+	present := calculateQualityScore(schema, &Metadata{Fields: fields})
+	details = strings.Join(present.Details, "\n")
+	if !strings.Contains(details, "3 of 3 required") || strings.Contains(details, "not defined in the schema") {
+		t.Errorf("Details = %s, want sample_id scored as present", details)
+	}
+	if present.Completeness <= missing.Completeness {
+		t.Errorf("Completeness = %d with sample_id, %d without", present.Completeness, missing.Completeness)
+	}
+}
+
+func TestCalculateQualityScore_EmptySchema(t *testing.T) {
+	// An empty schema used to divide by zero
+	score := calculateQualityScore(&Schema{Name: "empty"}, &Metadata{Fields: map[string]interface{}{"a": 1}})
+	if score.Overall != 100 {
+		t.Errorf("Overall = %d, want 100 with nothing to score", score.Overall)
+	}
+	if !strings.Contains(strings.Join(score.Details, "\n"), "Completeness: not scored") {
+		t.Errorf("Details = %v", score.Details)
+	}
+}
+
+func TestQualityWeights_Inherited(t *testing.T) {
+	dir := t.TempDir()
+	writeSchemaFile(t, dir, "parent.yaml", "name: parent\nquality_weights:\n  completeness: 1\n  consistency: 1\na:\n  type: string\n")
+	writeSchemaFile(t, dir, "child.yaml", "name: child\nextends: [parent]\n")
+	writeSchemaFile(t, dir, "bad.yaml", "name: bad\nquality_weights:\n  richness: -1\n")
+
+	loader := NewFileSchemaLoader(dir)
+	schema, err := NewSchemaManager(loader).LoadSchema("child")
+	if err != nil {
+		t.Fatalf("LoadSchema() error = %v", err)
+	}
+	if schema.QualityWeights == nil || schema.QualityWeights.Completeness != 1 {
+		t.Errorf("QualityWeights = %+v, want inherited from parent", schema.QualityWeights)
+	}
+	if _, err := loader.Load("bad"); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("Load(bad) error = %v, want negative weight error", err)
+	}
+}
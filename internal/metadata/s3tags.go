@@ -36,6 +36,8 @@ var PriorityFields = []string{
 	"operator",          // Low priority: who ran the instrument
 	"extractor_name",    // Low priority: which extractor was used
 	"schema_name",       // Low priority: metadata schema used
+	"organism",          // Low priority: source organism
+	"organism_ontology_term_id", // Low priority: organism CURIE, e.g. NCBITaxon:10090
 }
 
 // MetadataToS3Tags converts metadata to S3 tags.
@@ -50,6 +52,9 @@ var PriorityFields = []string{
 //  6. operator - Who ran the instrument
 //  7. extractor_name - Which extractor was used
 //  8. schema_name - Metadata schema used
+//  9. organism - Source organism
+//  10. organism_ontology_term_id - Organism CURIE (NCBITaxon:10090), set when
+//     the organism was resolved against NCBITaxon
 //
 // Returns up to 10 tags with sanitized keys and values.
 func MetadataToS3Tags(metadata *Metadata) []types.Tag {
@@ -63,8 +68,9 @@ func MetadataToS3Tags(metadata *Metadata) []types.Tag {
 		fields["schema_name"] = metadata.SchemaName
 	}
 
-	// Add fields from metadata.Fields map
-	for key, value := range metadata.Fields {
+	// Add fields from metadata.Fields map, with aliases resolved to their
+	// canonical names
+	for key, value := range NormalizeFields(metadata.Fields) {
 		if strValue, ok := value.(string); ok && strValue != "" {
 			fields[key] = strValue
 		} else if value != nil {
@@ -0,0 +1,528 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file reads sample sheets, spreadsheets with one row per sample, and
+// joins their rows to data files to enrich the files' metadata.
+//
+// Plain CSV and TSV files have a header row naming the columns. Illumina
+// SampleSheets are read natively: v1 sheets take their samples from the
+// [Data] section, v2 sheets from [BCLConvert_Data], with the columns of
+// other *_Data sections (such as [Cloud_Data]) joined by Sample_ID. The run
+// settings in [Header] add run-level fields to every sample.
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Sample sheet formats.
+const (
+	SampleSheetCSV        = "csv"
+	SampleSheetTSV        = "tsv"
+	SampleSheetIlluminaV1 = "illumina-v1"
+	SampleSheetIlluminaV2 = "illumina-v2"
+)
+
+// illuminaHeaderFields maps Illumina [Header] settings to the fields they
+// add to every sample.
+var illuminaHeaderFields = map[string]string{
+	"Investigator Name": "operator",
+	"Experiment Name":   "experiment_name",
+	"RunName":           "experiment_name",
+	"Date":              "run_date",
+}
+
+// illuminaFASTQName matches the FASTQ file names bcl2fastq and BCL Convert
+// write, <sample>_S<n>[_L<lane>]_<read>_001.fastq.gz, capturing the sample.
+var illuminaFASTQName = regexp.MustCompile(`^(.+?)_S\d+(?:_L\d{3})?_[RI]\d_\d{3}\.f(?:ast)?q(?:\.gz)?$`)
+
+// laneInName finds the lane in an Illumina FASTQ file name.
+var laneInName = regexp.MustCompile(`_L(\d{3})_`)
+
+// SampleSheet is a parsed sample sheet.
+type SampleSheet struct {
+	Format  string            // csv, tsv, illumina-v1 or illumina-v2
+	Header  map[string]string // Illumina [Header] settings
+	Columns []string
+	Rows    []SampleRow
+}
+
+// SampleRow is one sample of a sheet.
+type SampleRow struct {
+	Line   int               // Line in the sheet, from 1
+	Values map[string]string // Cell values by column
+}
+
+// ReadSampleSheet reads and parses a sample sheet file.
+func ReadSampleSheet(path string) (*SampleSheet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sample sheet: %w", err)
+	}
+	sheet, err := ParseSampleSheet(data, path)
+	if err != nil {
+		return nil, fmt.Errorf("parse sample sheet %s: %w", path, err)
+	}
+	return sheet, nil
+}
+
+// ParseSampleSheet parses a CSV, TSV or Illumina sample sheet. name
+// supplies the file extension, which selects tab-separated values for .tsv
+// and .tab files; otherwise the delimiter is detected.
+func ParseSampleSheet(data []byte, name string) (*SampleSheet, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	delimiter := ','
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".tsv", ".tab":
+		delimiter = '\t'
+	default:
+		firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+		if bytes.Count(firstLine, []byte("\t")) > bytes.Count(firstLine, []byte(",")) {
+			delimiter = '\t'
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var records []sheetRecord
+	for {
+		cells, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, sheetRecord{line: line, cells: trimCells(cells)})
+	}
+
+	for _, record := range records {
+		if len(record.cells) == 0 {
+			continue
+		}
+		if isSectionName(record.cells[0]) {
+			return parseIlluminaSheet(records)
+		}
+		break
+	}
+
+	sheet := &SampleSheet{Format: SampleSheetCSV}
+	if delimiter == '\t' {
+		sheet.Format = SampleSheetTSV
+	}
+	if err := sheet.readTable(records); err != nil {
+		return nil, err
+	}
+	return sheet, nil
+}
+
+// sheetRecord is a row of cells and the line it starts on.
+type sheetRecord struct {
+	line  int
+	cells []string
+}
+
+// trimCells trims spaces from cells and drops empty trailing cells, which
+// spreadsheet programs add to pad rows.
+func trimCells(cells []string) []string {
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	for len(cells) > 0 && cells[len(cells)-1] == "" {
+		cells = cells[:len(cells)-1]
+	}
+	return cells
+}
+
+// isSectionName reports whether a cell opens an Illumina section.
+func isSectionName(cell string) bool {
+	return len(cell) > 2 && strings.HasPrefix(cell, "[") && strings.HasSuffix(cell, "]")
+}
+
+// isIllumina reports whether the sheet is an Illumina SampleSheet.
+func (s *SampleSheet) isIllumina() bool {
+	return s.Format == SampleSheetIlluminaV1 || s.Format == SampleSheetIlluminaV2
+}
+
+// readTable reads a header row and the rows after it, skipping empty ones.
+func (s *SampleSheet) readTable(records []sheetRecord) error {
+	i := 0
+	for i < len(records) && len(records[i].cells) == 0 {
+		i++
+	}
+	if i == len(records) {
+		return fmt.Errorf("no header row")
+	}
+	s.Columns = records[i].cells
+	for _, column := range s.Columns {
+		if column == "" {
+			return fmt.Errorf("line %d: empty column name", records[i].line)
+		}
+	}
+
+	for _, record := range records[i+1:] {
+		if len(record.cells) == 0 {
+			continue
+		}
+		if len(record.cells) > len(s.Columns) {
+			return fmt.Errorf("line %d: %d cells, but only %d columns", record.line, len(record.cells), len(s.Columns))
+		}
+		row := SampleRow{Line: record.line, Values: make(map[string]string, len(record.cells))}
+		for j, cell := range record.cells {
+			if cell != "" {
+				row.Values[s.Columns[j]] = cell
+			}
+		}
+		s.Rows = append(s.Rows, row)
+	}
+	return nil
+}
+
+// parseIlluminaSheet parses the sections of an Illumina SampleSheet.
+func parseIlluminaSheet(records []sheetRecord) (*SampleSheet, error) {
+	sections := make(map[string][]sheetRecord)
+	var order []string
+	section := ""
+	for _, record := range records {
+		if len(record.cells) > 0 && isSectionName(record.cells[0]) {
+			section = strings.Trim(record.cells[0], "[]")
+			order = append(order, section)
+			continue
+		}
+		sections[section] = append(sections[section], record)
+	}
+
+	sheet := &SampleSheet{Format: SampleSheetIlluminaV1, Header: make(map[string]string)}
+	for _, record := range sections["Header"] {
+		if len(record.cells) >= 2 {
+			sheet.Header[record.cells[0]] = record.cells[1]
+		}
+	}
+
+	data := "Data"
+	if sheet.Header["FileFormatVersion"] == "2" || sections["BCLConvert_Data"] != nil {
+		sheet.Format = SampleSheetIlluminaV2
+		data = "BCLConvert_Data"
+	}
+	if sections[data] == nil {
+		return nil, fmt.Errorf("no [%s] section", data)
+	}
+	if err := sheet.readTable(sections[data]); err != nil {
+		return nil, fmt.Errorf("[%s]: %w", data, err)
+	}
+
+	// Join the columns of other data sections by sample
+	for _, name := range order {
+		if name == data || !strings.HasSuffix(name, "_Data") {
+			continue
+		}
+		extra := &SampleSheet{}
+		if err := extra.readTable(sections[name]); err != nil {
+			return nil, fmt.Errorf("[%s]: %w", name, err)
+		}
+		sheet.join(extra, "Sample_ID")
+	}
+	return sheet, nil
+}
+
+// join adds the columns of other to the rows with the same value in key.
+func (s *SampleSheet) join(other *SampleSheet, key string) {
+	for _, column := range other.Columns {
+		if !contains(s.Columns, column) {
+			s.Columns = append(s.Columns, column)
+		}
+	}
+	for _, extra := range other.Rows {
+		for _, row := range s.Rows {
+			if extra.Values[key] == "" || row.Values[key] != extra.Values[key] {
+				continue
+			}
+			for column, value := range extra.Values {
+				if _, ok := row.Values[column]; !ok {
+					row.Values[column] = value
+				}
+			}
+		}
+	}
+}
+
+// Column returns the sheet column called name, matching exactly, then
+// ignoring case, then by field name, so "sample_id" finds "Sample_ID".
+func (s *SampleSheet) Column(name string) (string, bool) {
+	for _, column := range s.Columns {
+		if column == name {
+			return column, true
+		}
+	}
+	for _, column := range s.Columns {
+		if strings.EqualFold(column, name) {
+			return column, true
+		}
+	}
+	for _, column := range s.Columns {
+		if SampleFieldName(column) == SampleFieldName(name) {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// SampleFieldName converts a column name to a snake_case field name:
+// "Sample_ID" becomes sample_id, "ProjectName" project_name and "Investigator
+// Name" investigator_name.
+func SampleFieldName(column string) string {
+	var b strings.Builder
+	runes := []rune(column)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	for strings.Contains(name, "__") {
+		name = strings.ReplaceAll(name, "__", "_")
+	}
+	return strings.Trim(name, "_")
+}
+
+// Enrichment converts a row to enrichment fields. Columns become field
+// names (see SampleFieldName), and Illumina run settings are added. Values
+// of fields the preset defines are parsed to the field's type and checked
+// against its requirements; the errors are returned along with the fields
+// that are valid. Empty cells are left out.
+func (s *SampleSheet) Enrichment(row SampleRow, preset *InstrumentPreset) (map[string]interface{}, []error) {
+	fields := make(map[string]interface{})
+	for setting, field := range illuminaHeaderFields {
+		if value := s.Header[setting]; value != "" {
+			fields[field] = value
+		}
+	}
+
+	var errs []error
+	for _, column := range s.Columns {
+		value, ok := row.Values[column]
+		if !ok {
+			continue
+		}
+		name := SampleFieldName(column)
+		if name == "description" && s.isIllumina() {
+			// An Illumina sample's Description is not the dataset's
+			name = "sample_description"
+		}
+		req, ok := preset.requirement(name)
+		if !ok {
+			fields[name] = value
+			continue
+		}
+		parsed, err := req.ParseValue(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: invalid value for %s: %w", row.Line, name, err))
+			continue
+		}
+		fields[name] = parsed
+	}
+	return fields, errs
+}
+
+// requirement returns the preset's requirement for a field. A nil preset
+// has none.
+func (p *InstrumentPreset) requirement(name string) (FieldRequirement, bool) {
+	if p == nil {
+		return FieldRequirement{}, false
+	}
+	for _, reqs := range [][]FieldRequirement{p.RequiredFields, p.OptionalFields} {
+		for _, req := range reqs {
+			if req.Name == name {
+				return req, true
+			}
+		}
+	}
+	return FieldRequirement{}, false
+}
+
+// SampleMatch joins files to sample sheet rows by comparing a value taken
+// from the file's name with the value of a column.
+type SampleMatch struct {
+	// Columns are the columns compared, in order; the first one the sheet
+	// has is used.
+	Columns []string
+
+	// Pattern extracts the value from the file name: its first group, or
+	// the whole match without groups. Without a pattern the column holds
+	// file names or paths.
+	Pattern *regexp.Regexp
+}
+
+// ParseSampleMatch parses a match specification: "column={regex}" (the
+// braces are optional) compares the column with the part of each file name
+// the regular expression extracts, and "column" alone compares it with the
+// file's name or path.
+func ParseSampleMatch(spec string) (*SampleMatch, error) {
+	column, expr, hasExpr := strings.Cut(spec, "=")
+	column = strings.TrimSpace(column)
+	if column == "" {
+		return nil, fmt.Errorf("invalid match %q: missing column", spec)
+	}
+	match := &SampleMatch{Columns: []string{column}}
+	if !hasExpr {
+		return match, nil
+	}
+
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "{") && strings.HasSuffix(expr, "}") {
+		expr = expr[1 : len(expr)-1]
+	}
+	if expr == "" {
+		return nil, fmt.Errorf("invalid match %q: missing regular expression", spec)
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid match %q: %w", spec, err)
+	}
+	match.Pattern = pattern
+	return match, nil
+}
+
+// DefaultSampleMatch returns how the rows of a sheet are matched without a
+// specification: Illumina FASTQ file names against Sample_ID, or
+// Sample_Name for bcl2fastq output. Other sheets have no default.
+func (s *SampleSheet) DefaultSampleMatch() (*SampleMatch, bool) {
+	if !s.isIllumina() {
+		return nil, false
+	}
+	return &SampleMatch{Columns: []string{"Sample_ID", "Sample_Name"}, Pattern: illuminaFASTQName}, true
+}
+
+// Match returns the row for a file. Rows that differ only in their lane
+// are one sample; for an Illumina file name with a lane, the row for that
+// lane is used. It returns false if no row matches and an error if several
+// rows for different samples do.
+func (s *SampleSheet) Match(path string, match *SampleMatch) (SampleRow, bool, error) {
+	var rows []SampleRow
+	for _, name := range match.Columns {
+		column, ok := s.Column(name)
+		if !ok {
+			continue
+		}
+		for _, row := range s.Rows {
+			if value := row.Values[column]; value != "" && match.matches(path, value) {
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) > 0 {
+			break
+		}
+	}
+	if len(rows) == 0 {
+		return SampleRow{}, false, nil
+	}
+
+	if lane, ok := s.Column("Lane"); ok && len(rows) > 1 {
+		if m := laneInName.FindStringSubmatch(filepath.Base(path)); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			var laneRows []SampleRow
+			for _, row := range rows {
+				if row.Values[lane] == strconv.Itoa(n) {
+					laneRows = append(laneRows, row)
+				}
+			}
+			if len(laneRows) > 0 {
+				rows = laneRows
+			}
+		}
+	}
+	for _, row := range rows[1:] {
+		if !sameSample(rows[0], row) {
+			lines := make([]string, len(rows))
+			for i, row := range rows {
+				lines[i] = strconv.Itoa(row.Line)
+			}
+			return SampleRow{}, false, fmt.Errorf("%s matches several samples, on lines %s", path, strings.Join(lines, ", "))
+		}
+	}
+	return rows[0], true, nil
+}
+
+// column returns the first of the match's columns the sheet has.
+func (m *SampleMatch) column(s *SampleSheet) (string, bool) {
+	for _, name := range m.Columns {
+		if column, ok := s.Column(name); ok {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// Check reports an error if the sheet has none of the match's columns.
+func (s *SampleSheet) Check(match *SampleMatch) error {
+	if _, ok := match.column(s); !ok {
+		return fmt.Errorf("sample sheet has no %s column (columns: %s)", strings.Join(match.Columns, " or "), strings.Join(s.Columns, ", "))
+	}
+	return nil
+}
+
+// matches reports whether a file matches a column value.
+func (m *SampleMatch) matches(path, value string) bool {
+	name := filepath.Base(path)
+	if m.Pattern == nil {
+		value = filepath.ToSlash(filepath.Clean(value))
+		slashed := filepath.ToSlash(path)
+		return value == name || value == slashed || strings.HasSuffix(slashed, "/"+value)
+	}
+	found := m.Pattern.FindStringSubmatch(name)
+	if found == nil {
+		return false
+	}
+	if len(found) > 1 {
+		return found[1] == value
+	}
+	return found[0] == value
+}
+
+// sameSample reports whether two rows differ only in their lane.
+func sameSample(a, b SampleRow) bool {
+	strip := func(values map[string]string) map[string]string {
+		stripped := make(map[string]string, len(values))
+		for column, value := range values {
+			if !strings.EqualFold(column, "Lane") {
+				stripped[column] = value
+			}
+		}
+		return stripped
+	}
+	return reflect.DeepEqual(strip(a.Values), strip(b.Values))
+}
@@ -0,0 +1,345 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const illuminaV1Sheet = `[Header],,,,
+IEMFileVersion,4,,,
+Investigator Name,Jane Doe,,,
+Experiment Name,Run42,,,
+Date,2026-10-18,,,
+,,,,
+[Reads],,,,
+151,,,,
+,,,,
+[Data],,,,
+Lane,Sample_ID,Sample_Name,index,Description
+1,S1,Liver_1,ACGTACGT,liver
+2,S1,Liver_1,ACGTACGT,liver
+1,S2,Kidney_1,TTTTAAAA,kidney
+`
+
+const illuminaV2Sheet = `[Header]
+FileFormatVersion,2
+RunName,Run43
+InstrumentPlatform,NovaSeqXSeries
+
+[Reads]
+Read1Cycles,151
+
+[BCLConvert_Settings]
+SoftwareVersion,4.1.7
+
+[BCLConvert_Data]
+Lane,Sample_ID,Index,Index2
+1,S1,ACGTACGT,TTTTAAAA
+1,S2,GGGGCCCC,AAAATTTT
+
+[Cloud_Data]
+Sample_ID,ProjectName,LibraryName
+S1,Atlas,Lib1
+`
+
+func TestParseSampleSheet(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		file        string
+		wantFormat  string
+		wantColumns []string
+		wantRows    []map[string]string
+		wantErr     bool
+	}{
+		{
+			name:        "CSV",
+			data:        "sample_id,organism\nA1,mouse\n\nB2, human \n",
+			file:        "samples.csv",
+			wantFormat:  SampleSheetCSV,
+			wantColumns: []string{"sample_id", "organism"},
+			wantRows:    []map[string]string{{"sample_id": "A1", "organism": "mouse"}, {"sample_id": "B2", "organism": "human"}},
+		},
+		{
+			name:        "TSV by extension",
+			data:        "sample_id\torganism\nA1\tmouse, lab strain\n",
+			file:        "samples.tsv",
+			wantFormat:  SampleSheetTSV,
+			wantColumns: []string{"sample_id", "organism"},
+			wantRows:    []map[string]string{{"sample_id": "A1", "organism": "mouse, lab strain"}},
+		},
+		{
+			name:        "TSV detected",
+			data:        "\xef\xbb\xbfsample_id\torganism\nA1\tmouse\n",
+			file:        "samples.txt",
+			wantFormat:  SampleSheetTSV,
+			wantColumns: []string{"sample_id", "organism"},
+			wantRows:    []map[string]string{{"sample_id": "A1", "organism": "mouse"}},
+		},
+		{
+			name:        "Quoted cells and empty values",
+			data:        "file,notes,operator\n\"a,b.czi\",\"said \"\"hi\"\"\",\n",
+			file:        "samples.csv",
+			wantFormat:  SampleSheetCSV,
+			wantColumns: []string{"file", "notes", "operator"},
+			wantRows:    []map[string]string{{"file": "a,b.czi", "notes": `said "hi"`}},
+		},
+		{
+			name:        "Illumina v1",
+			data:        illuminaV1Sheet,
+			file:        "SampleSheet.csv",
+			wantFormat:  SampleSheetIlluminaV1,
+			wantColumns: []string{"Lane", "Sample_ID", "Sample_Name", "index", "Description"},
+			wantRows: []map[string]string{
+				{"Lane": "1", "Sample_ID": "S1", "Sample_Name": "Liver_1", "index": "ACGTACGT", "Description": "liver"},
+				{"Lane": "2", "Sample_ID": "S1", "Sample_Name": "Liver_1", "index": "ACGTACGT", "Description": "liver"},
+				{"Lane": "1", "Sample_ID": "S2", "Sample_Name": "Kidney_1", "index": "TTTTAAAA", "Description": "kidney"},
+			},
+		},
+		{
+			name:        "Illumina v2 joins data sections",
+			data:        illuminaV2Sheet,
+			file:        "SampleSheet.csv",
+			wantFormat:  SampleSheetIlluminaV2,
+			wantColumns: []string{"Lane", "Sample_ID", "Index", "Index2", "ProjectName", "LibraryName"},
+			wantRows: []map[string]string{
+				{"Lane": "1", "Sample_ID": "S1", "Index": "ACGTACGT", "Index2": "TTTTAAAA", "ProjectName": "Atlas", "LibraryName": "Lib1"},
+				{"Lane": "1", "Sample_ID": "S2", "Index": "GGGGCCCC", "Index2": "AAAATTTT"},
+			},
+		},
+		{name: "Empty", data: "\n\n", file: "samples.csv", wantErr: true},
+		{name: "Row longer than header", data: "a,b\n1,2,3\n", file: "samples.csv", wantErr: true},
+		{name: "Illumina without data", data: "[Header]\nIEMFileVersion,4\n", file: "SampleSheet.csv", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet, err := ParseSampleSheet([]byte(tt.data), tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSampleSheet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if sheet.Format != tt.wantFormat {
+				t.Errorf("Format = %q, want %q", sheet.Format, tt.wantFormat)
+			}
+			if !reflect.DeepEqual(sheet.Columns, tt.wantColumns) {
+				t.Errorf("Columns = %q, want %q", sheet.Columns, tt.wantColumns)
+			}
+			var rows []map[string]string
+			for _, row := range sheet.Rows {
+				rows = append(rows, row.Values)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("Rows = %v, want %v", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestReadSampleSheet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "SampleSheet.csv")
+	if err := os.WriteFile(path, []byte(illuminaV1Sheet), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := ReadSampleSheet(path)
+	if err != nil {
+		t.Fatalf("ReadSampleSheet() error = %v", err)
+	}
+	if sheet.Header["Investigator Name"] != "Jane Doe" || sheet.Rows[0].Line != 12 {
+		t.Errorf("Header = %v, first row on line %d, want Jane Doe and line 12", sheet.Header, sheet.Rows[0].Line)
+	}
+
+	if _, err := ReadSampleSheet(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("ReadSampleSheet() of missing file succeeded")
+	}
+}
+
+func TestSampleFieldName(t *testing.T) {
+	tests := map[string]string{
+		"Sample_ID":         "sample_id",
+		"sample_id":         "sample_id",
+		"ProjectName":       "project_name",
+		"Investigator Name": "investigator_name",
+		"I7_Index_ID":       "i7_index_id",
+		"index2":            "index2",
+		"GC %":              "gc",
+		"Treatment (dose)":  "treatment_dose",
+	}
+	for column, want := range tests {
+		if got := SampleFieldName(column); got != want {
+			t.Errorf("SampleFieldName(%q) = %q, want %q", column, got, want)
+		}
+	}
+}
+
+func TestSampleSheet_Enrichment(t *testing.T) {
+	sheet, err := ParseSampleSheet([]byte(illuminaV1Sheet), "SampleSheet.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, errs := sheet.Enrichment(sheet.Rows[0], nil)
+	if len(errs) > 0 {
+		t.Fatalf("Enrichment() errors = %v", errs)
+	}
+	want := map[string]interface{}{
+		"operator":           "Jane Doe",
+		"experiment_name":    "Run42",
+		"run_date":           "2026-10-18",
+		"lane":               "1",
+		"sample_id":          "S1",
+		"sample_name":        "Liver_1",
+		"index":              "ACGTACGT",
+		"sample_description": "liver",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Enrichment() = %v, want %v", fields, want)
+	}
+
+	// Preset fields are typed and checked
+	registry := NewPresetRegistry()
+	registry.RegisterDefaults()
+	preset, err := registry.GetPreset("illumina-novaseq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv, err := ParseSampleSheet([]byte("sample_id,read_pair,mean_read_length,description\nA1,r1,150,liver RNA\nB2,R9,lots,\n"), "samples.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, errs = csv.Enrichment(csv.Rows[0], preset)
+	if len(errs) > 0 {
+		t.Fatalf("Enrichment() errors = %v", errs)
+	}
+	want = map[string]interface{}{"sample_id": "A1", "read_pair": "R1", "mean_read_length": 150.0, "description": "liver RNA"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Enrichment() = %v, want %v", fields, want)
+	}
+	fields, errs = csv.Enrichment(csv.Rows[1], preset)
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "line 3: invalid value for read_pair") {
+		t.Errorf("Enrichment() errors = %v, want read_pair and mean_read_length on line 3", errs)
+	}
+	if fields["sample_id"] != "B2" || fields["read_pair"] != nil {
+		t.Errorf("Enrichment() = %v, want only valid fields", fields)
+	}
+}
+
+func TestParseSampleMatch(t *testing.T) {
+	tests := []struct {
+		spec        string
+		wantColumn  string
+		wantPattern string
+		wantErr     bool
+	}{
+		{"sample_id={^([A-Z]\\d+)_}", "sample_id", `^([A-Z]\d+)_`, false},
+		{"sample_id=^([A-Z]\\d+)_", "sample_id", `^([A-Z]\d+)_`, false},
+		{" file ", "file", "", false},
+		{"=^x", "", "", true},
+		{"sample_id={}", "", "", true},
+		{"sample_id={(}", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			match, err := ParseSampleMatch(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSampleMatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if match.Columns[0] != tt.wantColumn {
+				t.Errorf("Columns = %v, want %s", match.Columns, tt.wantColumn)
+			}
+			pattern := ""
+			if match.Pattern != nil {
+				pattern = match.Pattern.String()
+			}
+			if pattern != tt.wantPattern {
+				t.Errorf("Pattern = %q, want %q", pattern, tt.wantPattern)
+			}
+		})
+	}
+}
+
+func TestSampleSheet_Match(t *testing.T) {
+	illumina, err := ParseSampleSheet([]byte(illuminaV1Sheet), "SampleSheet.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	illuminaMatch, ok := illumina.DefaultSampleMatch()
+	if !ok {
+		t.Fatal("DefaultSampleMatch() found no default for an Illumina sheet")
+	}
+
+	sheet, err := ParseSampleSheet([]byte("file,sample_id,organism\nrun1/a.czi,A1,mouse\nb.czi,B2,human\nc.czi,C3,rat\nc.czi,C3,mouse\n"), "samples.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sheet.DefaultSampleMatch(); ok {
+		t.Error("DefaultSampleMatch() found a default for a CSV sheet")
+	}
+	byID, err := ParseSampleMatch(`sample_id={^([A-Z]\d)_}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byFile, err := ParseSampleMatch("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		sheet    *SampleSheet
+		match    *SampleMatch
+		path     string
+		wantLine int // 0 for no match
+		wantErr  bool
+	}{
+		{"Illumina by Sample_ID and lane", illumina, illuminaMatch, "fastq/S1_S1_L002_R1_001.fastq.gz", 13, false},
+		{"Illumina lanes of one sample", illumina, illuminaMatch, "fastq/S1_S1_R1_001.fastq.gz", 12, false},
+		{"Illumina by Sample_Name", illumina, illuminaMatch, "Kidney_1_S2_L001_R2_001.fastq.gz", 14, false},
+		{"Illumina other file", illumina, illuminaMatch, "Undetermined.fastq.gz", 0, false},
+		{"Regex", sheet, byID, "data/B2_image.czi", 3, false},
+		{"Regex without match", sheet, byID, "data/image.czi", 0, false},
+		{"File name", sheet, byFile, "data/b.czi", 3, false},
+		{"Relative path", sheet, byFile, "/lab/run1/a.czi", 2, false},
+		{"Path in another directory", sheet, byFile, "/lab/run2/a.czi", 0, false},
+		{"Several samples", sheet, byFile, "c.czi", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, ok, err := tt.sheet.Match(tt.path, tt.match)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != (tt.wantLine > 0) || row.Line != tt.wantLine {
+				t.Errorf("Match() = line %d, %v, want line %d", row.Line, ok, tt.wantLine)
+			}
+		})
+	}
+
+	if err := sheet.Check(byID); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	missing, _ := ParseSampleMatch("barcode")
+	if err := sheet.Check(missing); err == nil {
+		t.Error("Check() of missing column succeeded")
+	}
+}
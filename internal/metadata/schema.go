@@ -2,8 +2,13 @@
 package metadata
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -21,17 +26,23 @@ type Schema struct {
 	OntologyMappings map[string]string      `yaml:"ontology_mappings,omitempty" json:"ontology_mappings,omitempty"`
 	FileFormats      FileFormats            `yaml:"file_formats,omitempty" json:"file_formats,omitempty"`
 	Facets           []FacetConfig          `yaml:"facets,omitempty" json:"facets,omitempty"`
+	QualityWeights   *QualityWeights        `yaml:"quality_weights,omitempty" json:"quality_weights,omitempty"`
+
+	// ConversionIssues lists what was lost converting the schema from JSON
+	// Schema
+	ConversionIssues []SchemaIssue `yaml:"-" json:"-"`
 }
 
 // FieldSchema defines the schema for a single field
 type FieldSchema struct {
-	Type        string                 `yaml:"type" json:"type"` // string, number, integer, boolean, array, object, date, datetime
+	Type        string                 `yaml:"type" json:"type"` // string, number, integer, boolean, array, object, date, datetime, duration
 	Required    bool                   `yaml:"required,omitempty" json:"required,omitempty"`
 	RequiredIf  string                 `yaml:"required_if,omitempty" json:"required_if,omitempty"`
 	Default     interface{}            `yaml:"default,omitempty" json:"default,omitempty"`
 	Description string                 `yaml:"description,omitempty" json:"description,omitempty"`
 	Examples    []interface{}          `yaml:"examples,omitempty" json:"examples,omitempty"`
 	Vocabulary  []string               `yaml:"vocabulary,omitempty" json:"vocabulary,omitempty"`
+	Synonyms    map[string][]string    `yaml:"synonyms,omitempty" json:"synonyms,omitempty"` // Vocabulary term to alternative names
 	Pattern     string                 `yaml:"pattern,omitempty" json:"pattern,omitempty"`
 	Units       string                 `yaml:"units,omitempty" json:"units,omitempty"`
 	Range       *Range                 `yaml:"range,omitempty" json:"range,omitempty"`
@@ -146,8 +157,10 @@ type QualityScore struct {
 
 // SchemaManager manages metadata schemas
 type SchemaManager struct {
-	schemas map[string]*Schema
-	loader  SchemaLoader
+	schemas    map[string]*Schema
+	loader     SchemaLoader
+	resolving  []string       // extends chain being resolved, for cycle detection
+	ontologies *OntologyIndex // checks ontology fields, if set
 }
 
 // SchemaLoader interface for loading schemas
@@ -168,6 +181,7 @@ type SchemaInfo struct {
 	CreatedAt   time.Time `json:"created_at"`
 	Downloads   int       `json:"downloads"`
 	Rating      float64   `json:"rating"`
+	Source      string    `json:"source,omitempty"` // directory, or "builtin"
 }
 
 // NewSchemaManager creates a new schema manager
@@ -192,10 +206,8 @@ func (sm *SchemaManager) LoadSchema(name string) (*Schema, error) {
 	}
 
 	// Resolve extends
-	if len(schema.Extends) > 0 {
-		if err := sm.resolveExtends(schema); err != nil {
-			return nil, fmt.Errorf("failed to resolve extends: %w", err)
-		}
+	if err := sm.resolve(schema); err != nil {
+		return nil, err
 	}
 
 	// Cache it
@@ -203,8 +215,41 @@ func (sm *SchemaManager) LoadSchema(name string) (*Schema, error) {
 	return schema, nil
 }
 
+// AddSchema resolves a schema loaded outside the loader, such as one read
+// with LoadFromFile, and makes it available by name.
+func (sm *SchemaManager) AddSchema(schema *Schema) error {
+	if err := sm.resolve(schema); err != nil {
+		return err
+	}
+	sm.schemas[schema.Name] = schema
+	return nil
+}
+
+// resolve merges a schema's parents, failing on an extends cycle.
+func (sm *SchemaManager) resolve(schema *Schema) error {
+	if len(schema.Extends) == 0 {
+		return nil
+	}
+	for i, name := range sm.resolving {
+		if name == schema.Name {
+			chain := append(append([]string{}, sm.resolving[i:]...), schema.Name)
+			return fmt.Errorf("extends cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	sm.resolving = append(sm.resolving, schema.Name)
+	defer func() { sm.resolving = sm.resolving[:len(sm.resolving)-1] }()
+
+	if err := sm.resolveExtends(schema); err != nil {
+		return fmt.Errorf("failed to resolve extends: %w", err)
+	}
+	return nil
+}
+
 // resolveExtends merges parent schemas
 func (sm *SchemaManager) resolveExtends(schema *Schema) error {
+	if schema.Fields == nil {
+		schema.Fields = make(map[string]FieldSchema)
+	}
 	for _, parent := range schema.Extends {
 		parentSchema, err := sm.LoadSchema(parent)
 		if err != nil {
@@ -219,7 +264,25 @@ func (sm *SchemaManager) resolveExtends(schema *Schema) error {
 		}
 
 		// Merge required fields
-		schema.RequiredFields = append(schema.RequiredFields, parentSchema.RequiredFields...)
+		for _, required := range parentSchema.RequiredFields {
+			if !contains(schema.RequiredFields, required) {
+				schema.RequiredFields = append(schema.RequiredFields, required)
+			}
+		}
+
+		// Merge rules, quality weights and ontology mappings
+		schema.ValidationRules = append(schema.ValidationRules, parentSchema.ValidationRules...)
+		if schema.QualityWeights == nil {
+			schema.QualityWeights = parentSchema.QualityWeights
+		}
+		for field, term := range parentSchema.OntologyMappings {
+			if _, exists := schema.OntologyMappings[field]; !exists {
+				if schema.OntologyMappings == nil {
+					schema.OntologyMappings = make(map[string]string)
+				}
+				schema.OntologyMappings[field] = term
+			}
+		}
 	}
 
 	return nil
@@ -257,10 +320,37 @@ func (sm *SchemaManager) ValidateMetadata(metadata *Metadata) ValidationResult {
 		}
 	}
 
+	// Validate conditionally required fields
+	for _, fieldName := range sortedFieldNames(schema.Fields) {
+		condition := schema.Fields[fieldName].RequiredIf
+		if condition == "" {
+			continue
+		}
+		if _, ok := metadata.Fields[fieldName]; ok {
+			continue
+		}
+		required, err := EvalRule(condition, metadata.Fields)
+		if ruleErr := ruleError(err, fieldName); ruleErr != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, *ruleErr)
+		} else if required {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   fieldName,
+				Message: fmt.Sprintf("Required field '%s' is missing (required if %s)", fieldName, condition),
+				Type:    "missing_field",
+			})
+		}
+	}
+
 	// Validate field types and constraints
 	for fieldName, value := range metadata.Fields {
 		fieldSchema, ok := schema.Fields[fieldName]
 		if !ok {
+			if isOntologyTermIDField(schema, fieldName) {
+				// Checked with the field it identifies
+				continue
+			}
 			result.Warnings = append(result.Warnings, ValidationWarning{
 				Field:   fieldName,
 				Message: fmt.Sprintf("Field '%s' not defined in schema", fieldName),
@@ -268,6 +358,27 @@ func (sm *SchemaManager) ValidateMetadata(metadata *Metadata) ValidationResult {
 			continue
 		}
 
+		// Values given with units are compared in the field's units
+		if fieldSchema.Units != "" {
+			converted, note, err := normalizeQuantity(value, fieldSchema.Units)
+			if err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, ValidationError{
+					Field:   fieldName,
+					Message: fmt.Sprintf("Invalid units for '%s': %v", fieldName, err),
+					Type:    "invalid_unit",
+				})
+				continue
+			}
+			if note != "" {
+				result.Warnings = append(result.Warnings, ValidationWarning{
+					Field:   fieldName,
+					Message: note,
+				})
+			}
+			value = converted
+		}
+
 		// Type checking
 		if !isValidType(value, fieldSchema.Type) {
 			result.Valid = false
@@ -285,11 +396,18 @@ func (sm *SchemaManager) ValidateMetadata(metadata *Metadata) ValidationResult {
 				result.Warnings = append(result.Warnings, ValidationWarning{
 					Field:       fieldName,
 					Message:     fmt.Sprintf("Value '%s' not in controlled vocabulary", strValue),
-					Suggestions: findSimilar(strValue, fieldSchema.Vocabulary),
+					Suggestions: findSimilar(strValue, fieldSchema),
 				})
 			}
 		}
 
+		// Ontology terms
+		if ontology := fieldOntology(schema, fieldName); ontology != "" {
+			if warning := sm.checkOntologyTerm(fieldName, value, ontology, metadata.Fields); warning != nil {
+				result.Warnings = append(result.Warnings, *warning)
+			}
+		}
+
 		// Pattern matching
 		if fieldSchema.Pattern != "" {
 			strValue, ok := value.(string)
@@ -307,22 +425,39 @@ func (sm *SchemaManager) ValidateMetadata(metadata *Metadata) ValidationResult {
 		}
 
 		// Range checking
-		if fieldSchema.Range != nil {
-			if !checkRange(value, fieldSchema.Range) {
+		if fieldSchema.Range != nil && !checkRange(value, fieldSchema) {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   fieldName,
+				Message: fmt.Sprintf("Value %v out of range: must be %s", value, describeRange(fieldSchema)),
+				Type:    "out_of_range",
+			})
+		}
+	}
+
+	// Custom validation rules
+	for _, rule := range schema.ValidationRules {
+		expr, err := CompileExpr(rule.Rule)
+		if err == nil {
+			var ok bool
+			ok, err = expr.EvalBool(metadata.Fields)
+			if err == nil && !ok {
+				message := rule.Message
+				if message == "" {
+					message = "Rule failed"
+				}
 				result.Valid = false
 				result.Errors = append(result.Errors, ValidationError{
-					Field:   fieldName,
-					Message: fmt.Sprintf("Value out of range: %v", fieldSchema.Range),
-					Type:    "out_of_range",
+					Field:   expr.Subject(),
+					Message: fmt.Sprintf("%s (rule: %s)", message, rule.Rule),
+					Type:    "rule_failed",
 				})
 			}
 		}
-	}
-
-	// Custom validation rules
-	for range schema.ValidationRules {
-		// TODO: Implement rule evaluation
-		// This would involve parsing and evaluating the rule expression
+		if ruleErr := ruleError(err, "rule"); ruleErr != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, *ruleErr)
+		}
 	}
 
 	// Calculate quality score if valid
@@ -335,41 +470,66 @@ func (sm *SchemaManager) ValidateMetadata(metadata *Metadata) ValidationResult {
 
 // Helper functions
 
+// ruleError converts an expression error to a validation error. Rules that
+// stop on an absent field do not apply, so they yield nil.
+func ruleError(err error, field string) *ValidationError {
+	if err == nil {
+		return nil
+	}
+	var exprErr *ExprError
+	if errors.As(err, &exprErr) {
+		if exprErr.Missing {
+			return nil
+		}
+		if exprErr.Field != "" {
+			field = exprErr.Field
+		}
+	}
+	return &ValidationError{
+		Field:   field,
+		Message: err.Error(),
+		Type:    "invalid_rule",
+	}
+}
+
+// sortedFieldNames returns the field names in order.
+func sortedFieldNames(fields map[string]FieldSchema) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func isValidType(value interface{}, expectedType string) bool {
+	// Extractors return sized integers, float32 and typed slices and maps
+	kind := reflect.ValueOf(value).Kind()
 	switch expectedType {
 	case "string":
 		_, ok := value.(string)
 		return ok
 	case "number":
-		_, ok := value.(float64)
-		if ok {
-			return true
-		}
-		_, ok = value.(int)
-		return ok
+		return kind >= reflect.Int && kind <= reflect.Float64
 	case "integer":
-		_, ok := value.(int)
-		if ok {
+		if kind >= reflect.Int && kind <= reflect.Uint64 {
 			return true
 		}
-		f, ok := value.(float64)
-		return ok && f == float64(int(f))
+		f, ok := toFloat(value)
+		return ok && (kind == reflect.Float32 || kind == reflect.Float64) && f == math.Trunc(f)
 	case "boolean":
 		_, ok := value.(bool)
 		return ok
 	case "array":
-		_, ok := value.([]interface{})
-		return ok
+		return kind == reflect.Slice || kind == reflect.Array
 	case "object":
-		_, ok := value.(map[string]interface{})
-		return ok
+		return kind == reflect.Map
 	case "date", "datetime":
-		_, ok := value.(time.Time)
-		if ok {
-			return true
-		}
-		_, ok = value.(string)
-		return ok // TODO: Validate date format
+		_, ok := toDateTime(value)
+		return ok
+	case "duration":
+		_, ok := toDuration(value, "")
+		return ok
 	default:
 		return true
 	}
@@ -384,38 +544,80 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-func findSimilar(value string, vocabulary []string) []string {
-	// TODO: Implement fuzzy matching (Levenshtein distance)
-	return []string{}
+// numericValue converts a value of any numeric kind to float64. Unlike
+// toFloat it does not parse strings.
+func numericValue(value interface{}) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
 }
 
-func checkRange(value interface{}, r *Range) bool {
-	// TODO: Implement range checking for different types
+// checkRange reports whether value lies within the field's range. Dates and
+// datetimes compare as times, durations as lengths of time, and everything
+// else as numbers in the field's units. Values that cannot be compared are
+// left to the type check and pass.
+func checkRange(value interface{}, field FieldSchema) bool {
+	x, ok := rangeKey(value, field)
+	if !ok {
+		return true
+	}
+	if min, ok := rangeKey(field.Range.Min, field); ok && x < min {
+		return false
+	}
+	if max, ok := rangeKey(field.Range.Max, field); ok && x > max {
+		return false
+	}
 	return true
 }
 
-func calculateQualityScore(schema *Schema, metadata *Metadata) *QualityScore {
-	score := &QualityScore{}
-
-	// Completeness: percentage of fields filled
-	totalFields := len(schema.Fields)
-	filledFields := len(metadata.Fields)
-	score.Completeness = (filledFields * 100) / totalFields
-
-	// Consistency: use of controlled vocabularies
-	// TODO: Implement
-	score.Consistency = 85
-
-	// Richness: optional fields filled, notes, keywords
-	// TODO: Implement
-	score.Richness = 70
-
-	// Interoperability: ontology terms, standard formats
-	// TODO: Implement
-	score.Interoperability = 80
-
-	// Overall: weighted average
-	score.Overall = (score.Completeness + score.Consistency + score.Richness + score.Interoperability) / 4
+// rangeKey converts a value or range bound to a comparable number: Unix
+// seconds for dates, seconds for durations, and the field's units for
+// numbers. A date bound of "now" is the current time.
+func rangeKey(v interface{}, field FieldSchema) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	switch field.Type {
+	case "date", "datetime":
+		if s, ok := v.(string); ok && strings.EqualFold(s, "now") {
+			v = time.Now()
+		}
+		t, ok := toDateTime(v)
+		return float64(t.UnixNano()) / 1e9, ok
+	case "duration":
+		d, ok := toDuration(v, field.Units)
+		return d.Seconds(), ok
+	}
+	if f, ok := numericValue(v); ok {
+		return f, true
+	}
+	// Bounds may be written with units, such as "10 ms"
+	converted, _, err := normalizeQuantity(v, field.Units)
+	if err != nil {
+		return 0, false
+	}
+	return numericValue(converted)
+}
 
-	return score
+// describeRange describes a field's range for error messages.
+func describeRange(field FieldSchema) string {
+	r := field.Range
+	units := ""
+	if field.Units != "" && field.Type != "date" && field.Type != "datetime" {
+		units = " " + field.Units
+	}
+	switch {
+	case r.Min != nil && r.Max != nil:
+		return fmt.Sprintf("between %v and %v%s", r.Min, r.Max, units)
+	case r.Min != nil:
+		return fmt.Sprintf("at least %v%s", r.Min, units)
+	}
+	return fmt.Sprintf("at most %v%s", r.Max, units)
 }
@@ -0,0 +1,355 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file implements SchemaLoader over schema files on disk and the
+// built-in schemas embedded in the binary.
+//
+// Schemas are YAML or JSON documents. In YAML, field definitions may sit at
+// the top level next to name and extends, or under a "fields" key; JSON
+// always uses "fields". JSON files may also hold JSON Schema, which is
+// converted on load (see jsonschema.go). A schema is found by its name, or
+// by its file name without the extension.
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed schemas/*.yaml
+var builtinSchemas embed.FS
+
+// SchemaSourceBuiltin is the SchemaInfo.Source of embedded schemas.
+const SchemaSourceBuiltin = "builtin"
+
+// fieldTypes are the types a FieldSchema may declare.
+var fieldTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true,
+	"array": true, "object": true, "date": true, "datetime": true,
+	"duration": true,
+}
+
+// FileSchemaLoader loads schemas from directories, searched in order, and
+// then from the built-in schemas. A schema in an earlier directory hides a
+// later one with the same name.
+type FileSchemaLoader struct {
+	dirs []string
+}
+
+// NewFileSchemaLoader creates a loader that searches dirs before the
+// built-in schemas. Missing directories are ignored.
+func NewFileSchemaLoader(dirs ...string) *FileSchemaLoader {
+	return &FileSchemaLoader{dirs: dirs}
+}
+
+// DefaultSchemaDirs returns the project (.cicada/schemas in the working
+// directory) and user (~/.cicada/schemas) schema directories.
+func DefaultSchemaDirs() []string {
+	dirs := []string{filepath.Join(".cicada", "schemas")}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".cicada", "schemas"))
+	}
+	return dirs
+}
+
+// schemaEntry is a parsed schema and where it came from.
+type schemaEntry struct {
+	schema *Schema
+	source string
+	stem   string
+}
+
+// Load returns the schema with the given name or file name.
+func (l *FileSchemaLoader) Load(name string) (*Schema, error) {
+	entries, err := l.scan()
+	for _, entry := range entries {
+		if entry.schema.Name == name || entry.stem == name {
+			return entry.schema, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("schema not found: %s (%w)", name, err)
+	}
+	return nil, fmt.Errorf("schema not found: %s", name)
+}
+
+// LoadFromFile parses and checks a schema file.
+func (l *FileSchemaLoader) LoadFromFile(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+	return parseSchema(data, path)
+}
+
+// List returns every schema the loader can see, sorted by name. Files that
+// fail to parse are left out and reported in the error.
+func (l *FileSchemaLoader) List() ([]SchemaInfo, error) {
+	entries, err := l.scan()
+	infos := make([]SchemaInfo, 0, len(entries))
+	for _, entry := range entries {
+		infos = append(infos, entry.info())
+	}
+	return infos, err
+}
+
+// Search returns the schemas whose name, domain, description or field
+// names contain query, ignoring case.
+func (l *FileSchemaLoader) Search(query string) ([]SchemaInfo, error) {
+	query = strings.ToLower(query)
+	entries, err := l.scan()
+	var infos []SchemaInfo
+	for _, entry := range entries {
+		if entry.matches(query) {
+			infos = append(infos, entry.info())
+		}
+	}
+	return infos, err
+}
+
+// scan parses every schema in the search path. Entries are sorted by name
+// and a name appears once, from the first location that defines it.
+func (l *FileSchemaLoader) scan() ([]schemaEntry, error) {
+	var entries []schemaEntry
+	var errs []error
+	seen := make(map[string]bool)
+	add := func(schema *Schema, source, file string) {
+		if seen[schema.Name] {
+			return
+		}
+		seen[schema.Name] = true
+		stem := strings.TrimSuffix(path.Base(filepath.ToSlash(file)), path.Ext(file))
+		entries = append(entries, schemaEntry{schema: schema, source: source, stem: stem})
+	}
+
+	for _, dir := range l.dirs {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("read schema directory: %w", err))
+			}
+			continue
+		}
+		for _, file := range files {
+			if file.IsDir() || !isSchemaFile(file.Name()) {
+				continue
+			}
+			file := filepath.Join(dir, file.Name())
+			schema, err := l.LoadFromFile(file)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			add(schema, dir, file)
+		}
+	}
+
+	files, _ := fs.Glob(builtinSchemas, "schemas/*.yaml")
+	for _, file := range files {
+		data, err := builtinSchemas.ReadFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		schema, err := parseSchema(data, file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		add(schema, SchemaSourceBuiltin, file)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].schema.Name < entries[j].schema.Name
+	})
+	return entries, errors.Join(errs...)
+}
+
+// info summarises the entry.
+func (e schemaEntry) info() SchemaInfo {
+	return SchemaInfo{
+		Name:        e.schema.Name,
+		Version:     e.schema.Version,
+		Domain:      e.schema.Domain,
+		Description: e.schema.Description,
+		Source:      e.source,
+	}
+}
+
+// matches reports whether a lower-cased query occurs in the entry.
+func (e schemaEntry) matches(query string) bool {
+	s := e.schema
+	for _, text := range []string{s.Name, s.Domain, s.Description} {
+		if strings.Contains(strings.ToLower(text), query) {
+			return true
+		}
+	}
+	for name, field := range s.Fields {
+		if strings.Contains(strings.ToLower(name), query) || strings.Contains(strings.ToLower(field.Description), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSchemaFile reports whether name has a schema file extension.
+func isSchemaFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// parseSchema decodes a YAML or JSON schema and checks it. file names the
+// source in errors and supplies the name of an unnamed schema.
+func parseSchema(data []byte, file string) (*Schema, error) {
+	schema := &Schema{}
+	if strings.EqualFold(filepath.Ext(file), ".json") && isJSONSchema(data) {
+		imported, issues, err := ImportJSONSchema(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse schema %s: %w", file, err)
+		}
+		schema = imported
+		schema.ConversionIssues = issues
+	} else if strings.EqualFold(filepath.Ext(file), ".json") {
+		if err := json.Unmarshal(data, schema); err != nil {
+			return nil, fmt.Errorf("parse schema %s: %w", file, err)
+		}
+	} else {
+		if err := yaml.Unmarshal(data, schema); err != nil {
+			return nil, fmt.Errorf("parse schema %s: %w", file, err)
+		}
+		// A "fields" key holds the fields instead of the top level
+		var nested struct {
+			Fields map[string]FieldSchema `yaml:"fields"`
+		}
+		if err := yaml.Unmarshal(data, &nested); err == nil && len(nested.Fields) > 0 {
+			delete(schema.Fields, "fields")
+			for name, field := range nested.Fields {
+				schema.Fields[name] = field
+			}
+		}
+	}
+
+	if schema.Name == "" {
+		schema.Name = strings.TrimSuffix(path.Base(filepath.ToSlash(file)), path.Ext(file))
+	}
+	if schema.Fields == nil {
+		schema.Fields = make(map[string]FieldSchema)
+	}
+	if err := checkSchema(schema); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", file, err)
+	}
+	return schema, nil
+}
+
+// checkSchema reports definition errors, such as an unknown field type or
+// an invalid pattern, that would otherwise surface during validation.
+func checkSchema(schema *Schema) error {
+	var errs []error
+	for _, name := range sortedFieldNames(schema.Fields) {
+		errs = append(errs, checkFieldSchema(name, schema.Fields[name])...)
+	}
+	if schema.QualityWeights != nil {
+		if err := schema.QualityWeights.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i, rule := range schema.ValidationRules {
+		if _, err := CompileExpr(rule.Rule); err != nil {
+			errs = append(errs, fmt.Errorf("validation rule %d: %w", i+1, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkRangeBounds checks that a field's range bounds suit its type and
+// that min does not exceed max.
+func checkRangeBounds(name string, field FieldSchema) []error {
+	var errs []error
+	var keys []float64
+	for _, bound := range []interface{}{field.Range.Min, field.Range.Max} {
+		if bound == nil {
+			continue
+		}
+		key, ok := rangeKey(bound, field)
+		if !ok {
+			errs = append(errs, fmt.Errorf("field %s: invalid range bound %v for type %s", name, bound, field.Type))
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(errs) == 0 && len(keys) == 2 && keys[0] > keys[1] {
+		errs = append(errs, fmt.Errorf("field %s: range min %v exceeds max %v", name, field.Range.Min, field.Range.Max))
+	}
+	return errs
+}
+
+// checkFieldSchema checks one field and its nested fields and items.
+func checkFieldSchema(name string, field FieldSchema) []error {
+	var errs []error
+	if !fieldTypes[field.Type] {
+		if field.Type == "" {
+			errs = append(errs, fmt.Errorf("field %s: missing type (or unknown top-level key)", name))
+		} else {
+			errs = append(errs, fmt.Errorf("field %s: unknown type %q", name, field.Type))
+		}
+	}
+	if field.Pattern != "" {
+		if _, err := regexp.Compile(field.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("field %s: invalid pattern: %w", name, err))
+		}
+	}
+	if field.RequiredIf != "" {
+		if _, err := CompileExpr(field.RequiredIf); err != nil {
+			errs = append(errs, fmt.Errorf("field %s: required_if: %w", name, err))
+		}
+	}
+	if field.Range != nil {
+		errs = append(errs, checkRangeBounds(name, field)...)
+	}
+	var unknown []string
+	for term := range field.Synonyms {
+		if !contains(field.Vocabulary, term) {
+			unknown = append(unknown, term)
+		}
+	}
+	sort.Strings(unknown)
+	for _, term := range unknown {
+		errs = append(errs, fmt.Errorf("field %s: synonyms for %q, which is not in the vocabulary", name, term))
+	}
+	if field.MaxItems > 0 && field.MinItems > field.MaxItems {
+		errs = append(errs, fmt.Errorf("field %s: min_items %d exceeds max_items %d", name, field.MinItems, field.MaxItems))
+	}
+	for child, childField := range field.Fields {
+		errs = append(errs, checkFieldSchema(name+"."+child, childField)...)
+	}
+	if field.Items != nil {
+		errs = append(errs, checkFieldSchema(name+"[]", *field.Items)...)
+	}
+	return errs
+}
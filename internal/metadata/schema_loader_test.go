@@ -0,0 +1,196 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSchemaFile writes a schema file into dir.
+func writeSchemaFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileSchemaLoader_Builtin(t *testing.T) {
+	loader := NewFileSchemaLoader()
+
+	infos, err := loader.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []string{"base", "cryo-em", "flow-cytometry", "fluorescence-microscopy", "mass-spectrometry", "microscopy", "sequencing"}
+	if len(infos) != len(want) {
+		t.Fatalf("List() returned %d schemas, want %d", len(infos), len(want))
+	}
+	for i, info := range infos {
+		if info.Name != want[i] || info.Source != SchemaSourceBuiltin {
+			t.Errorf("List()[%d] = %s (%s), want %s (builtin)", i, info.Name, info.Source, want[i])
+		}
+	}
+
+	manager := NewSchemaManager(loader)
+	schema, err := manager.LoadSchema("fluorescence-microscopy")
+	if err != nil {
+		t.Fatalf("LoadSchema() error = %v", err)
+	}
+	// Fields come from microscopy and, through it, base
+	for _, field := range []string{"channels", "objective_na", "file_size"} {
+		if _, ok := schema.Fields[field]; !ok {
+			t.Errorf("resolved schema missing inherited field %s", field)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, field := range schema.RequiredFields {
+		if seen[field] {
+			t.Errorf("required field %s listed twice", field)
+		}
+		seen[field] = true
+	}
+}
+
+func TestFileSchemaLoader_Dirs(t *testing.T) {
+	project := t.TempDir()
+	user := t.TempDir()
+	writeSchemaFile(t, project, "micro.yaml", `
+name: microscopy
+schema_version: "9.0"
+description: Project microscopy
+fields:
+  stage:
+    type: string
+`)
+	writeSchemaFile(t, user, "microscopy.yaml", "name: microscopy\nschema_version: \"8.0\"\n")
+	writeSchemaFile(t, user, "assay.json", `{"schema_version": "1.0", "fields": {"plate": {"type": "string"}}}`)
+	writeSchemaFile(t, user, "notes.txt", "not a schema")
+
+	loader := NewFileSchemaLoader(project, user, filepath.Join(project, "missing"))
+
+	schema, err := loader.Load("microscopy")
+	if err != nil {
+		t.Fatalf("Load(microscopy) error = %v", err)
+	}
+	if schema.Version != "9.0" || schema.Fields["stage"].Type != "string" {
+		t.Errorf("Load(microscopy) = %+v, want the project schema", schema)
+	}
+	if _, err := loader.Load("micro"); err != nil {
+		t.Errorf("Load(micro) by file name error = %v", err)
+	}
+
+	// An unnamed JSON schema takes its name from the file
+	schema, err = loader.Load("assay")
+	if err != nil {
+		t.Fatalf("Load(assay) error = %v", err)
+	}
+	if schema.Fields["plate"].Type != "string" {
+		t.Errorf("Load(assay) fields = %v", schema.Fields)
+	}
+
+	infos, err := loader.Search("plate")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != "assay" || infos[0].Source != user {
+		t.Errorf("Search(plate) = %+v, want assay from %s", infos, user)
+	}
+
+	if _, err := loader.Load("nope"); err == nil {
+		t.Error("Load(nope) error = nil, want error")
+	}
+}
+
+func TestFileSchemaLoader_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown type", "size:\n  type: size\n", `unknown type "size"`},
+		{"missing type", "lab_id:\n  description: Lab ID\n", "missing type"},
+		{"bad pattern", "id:\n  type: string\n  pattern: \"[a-\"\n", "invalid pattern"},
+		{"items", "tags:\n  type: array\n  min_items: 3\n  max_items: 1\n", "exceeds max_items"},
+		{"nested", "stage:\n  type: object\n  fields:\n    x:\n      type: float\n", "stage.x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeSchemaFile(t, dir, "s.yaml", tt.content)
+			_, err := NewFileSchemaLoader().LoadFromFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadFromFile() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A broken file does not hide the others from List
+	writeSchemaFile(t, dir, "s.yaml", "size:\n  type: size\n")
+	infos, err := NewFileSchemaLoader(dir).List()
+	if err == nil {
+		t.Error("List() error = nil, want the broken file reported")
+	}
+	if len(infos) == 0 {
+		t.Error("List() returned no schemas")
+	}
+}
+
+func TestSchemaManager_ExtendsCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeSchemaFile(t, dir, "a.yaml", "name: a\nextends: [b]\n")
+	writeSchemaFile(t, dir, "b.yaml", "name: b\nextends: [a]\n")
+
+	manager := NewSchemaManager(NewFileSchemaLoader(dir))
+	_, err := manager.LoadSchema("a")
+	if err == nil || !strings.Contains(err.Error(), "extends cycle: a -> b -> a") {
+		t.Fatalf("LoadSchema(a) error = %v, want extends cycle", err)
+	}
+
+	// A failed load leaves the manager usable
+	if _, err := manager.LoadSchema("base"); err != nil {
+		t.Errorf("LoadSchema(base) after cycle error = %v", err)
+	}
+}
+
+func TestSchemaManager_ValidateTypes(t *testing.T) {
+	manager := NewSchemaManager(NewFileSchemaLoader())
+	schema, err := manager.LoadSchema("microscopy")
+	if err != nil {
+		t.Fatalf("LoadSchema() error = %v", err)
+	}
+
+	result := manager.ValidateMetadata(&Metadata{
+		SchemaName: schema.Name,
+		Fields: map[string]interface{}{
+			"format":          "CZI",
+			"instrument_type": "microscopy",
+			"image_width":     int64(2048),
+			"image_height":    float64(2048),
+			"image_depth":     1.5,
+		},
+	})
+	var typeErrors []string
+	for _, e := range result.Errors {
+		typeErrors = append(typeErrors, e.Field)
+	}
+	if len(typeErrors) != 1 || typeErrors[0] != "image_depth" {
+		t.Errorf("type errors on %v, want only image_depth", typeErrors)
+	}
+}
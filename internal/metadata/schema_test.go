@@ -0,0 +1,141 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		field FieldSchema
+		value interface{}
+		want  bool
+	}{
+		{"integer in range", FieldSchema{Type: "integer", Range: &Range{Min: 1, Max: 10}}, int32(5), true},
+		{"integer below", FieldSchema{Type: "integer", Range: &Range{Min: 1}}, uint8(0), false},
+		{"float above", FieldSchema{Type: "number", Range: &Range{Max: 1.4}}, float32(1.45), false},
+		{"bound with units", FieldSchema{Type: "number", Units: "ms", Range: &Range{Max: "2 s"}}, 1500.0, true},
+		{"bound with units exceeded", FieldSchema{Type: "number", Units: "ms", Range: &Range{Max: "2 s"}}, 2500.0, false},
+		{"date in range", FieldSchema{Type: "date", Range: &Range{Min: "2000-01-01", Max: "now"}}, "2025-03-01", true},
+		{"date in future", FieldSchema{Type: "date", Range: &Range{Max: "now"}}, time.Now().Add(48 * time.Hour), false},
+		{"date too early", FieldSchema{Type: "datetime", Range: &Range{Min: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}}, "1999-12-31T23:59:59Z", false},
+		{"duration in range", FieldSchema{Type: "duration", Range: &Range{Min: "PT1M", Max: "PT1H"}}, "PT30M", true},
+		{"duration too long", FieldSchema{Type: "duration", Range: &Range{Max: "PT1H"}}, 2 * time.Hour, false},
+		{"duration number in units", FieldSchema{Type: "duration", Units: "min", Range: &Range{Max: "PT1H"}}, 90, false},
+		{"wrong type passes", FieldSchema{Type: "number", Range: &Range{Max: 1}}, "many", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkRange(tt.value, tt.field); got != tt.want {
+				t.Errorf("checkRange(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidType_Dates(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		typ   string
+		want  bool
+	}{
+		{"2025-03-01", "date", true},
+		{"2025-03-01T10:00:00Z", "datetime", true},
+		{time.Now(), "datetime", true},
+		{"05-MAY-2023", "date", false},
+		{"last tuesday", "datetime", false},
+		{"PT15M", "duration", true},
+		{"15m", "duration", true},
+		{"fortnight", "duration", false},
+	}
+	for _, tt := range tests {
+		if got := isValidType(tt.value, tt.typ); got != tt.want {
+			t.Errorf("isValidType(%v, %s) = %v, want %v", tt.value, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestSchemaManager_ValidateUnits(t *testing.T) {
+	manager := NewSchemaManager(NewFileSchemaLoader())
+	if err := manager.AddSchema(&Schema{
+		Name: "units",
+		Fields: map[string]FieldSchema{
+			"exposure_time_ms": {Type: "number", Units: "ms", Range: &Range{Min: 1, Max: 1000}},
+			"temperature":      {Type: "number", Units: "°C", Range: &Range{Min: 4, Max: 42}},
+			"wavelength":       {Type: "number", Units: "nm"},
+			"acquired":         {Type: "datetime", Range: &Range{Max: "now"}},
+		},
+	}); err != nil {
+		t.Fatalf("AddSchema() error = %v", err)
+	}
+
+	// 500 µs is 0.5 ms, below the 1 ms minimum; 310 K is 36.85 °C
+	result := manager.ValidateMetadata(&Metadata{
+		SchemaName: "units",
+		Fields: map[string]interface{}{
+			"exposure_time_ms": "500 µs",
+			"temperature":      "310 K",
+			"wavelength":       "3 ms",
+			"acquired":         "2024-13-01",
+		},
+	})
+	errs := make(map[string]ValidationError)
+	for _, e := range result.Errors {
+		errs[e.Field] = e
+	}
+	if e := errs["exposure_time_ms"]; e.Type != "out_of_range" || !strings.Contains(e.Message, "between 1 and 1000 ms") {
+		t.Errorf("exposure_time_ms error = %+v, want out_of_range", e)
+	}
+	if e := errs["wavelength"]; e.Type != "invalid_unit" {
+		t.Errorf("wavelength error = %+v, want invalid_unit", e)
+	}
+	if e := errs["acquired"]; e.Type != "invalid_type" {
+		t.Errorf("acquired error = %+v, want invalid_type", e)
+	}
+	if _, ok := errs["temperature"]; ok {
+		t.Errorf("temperature error = %+v, want none", errs["temperature"])
+	}
+
+	var notes []string
+	for _, w := range result.Warnings {
+		notes = append(notes, w.Message)
+	}
+	if got := strings.Join(notes, "; "); !strings.Contains(got, "Converted 500 µs to 0.5 ms") || !strings.Contains(got, "Converted 310 K to 36.85 °C") {
+		t.Errorf("warnings = %q, want both conversions reported", got)
+	}
+}
+
+func TestFileSchemaLoader_InvalidRange(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"when:\n  type: date\n  range:\n    min: someday\n", "invalid range bound someday for type date"},
+		{"size:\n  type: number\n  range:\n    min: 10\n    max: 1\n", "range min 10 exceeds max 1"},
+		{"span:\n  type: duration\n  range:\n    max: forever\n", "invalid range bound forever"},
+	}
+	for _, tt := range tests {
+		path := writeSchemaFile(t, dir, "r.yaml", tt.content)
+		_, err := NewFileSchemaLoader().LoadFromFile(path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadFromFile() error = %v, want %q", err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file implements content-based extractor selection. Extractors that
+// implement Sniffer look at the first bytes of a file (and, through the
+// reader, at structures such as the first TIFF IFD) and return a
+// confidence. Extensions still count, but a signature always outranks a
+// bare extension match, so renamed or extensionless files reach the right
+// extractor and OME-TIFF, ImageJ TIFF and EER are told apart.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Confidence levels used when scoring extractors. Sniffers return their
+// own value between confidenceExtension and 100 for a signature match.
+const (
+	// confidenceExtension is the score for an extension match alone.
+	confidenceExtension = 50
+
+	// confidencePluginExtension is the score for an extension match by a
+	// plugin without signatures. It ties the strongest built-in signature,
+	// and plugins are registered first, so such a plugin takes over its
+	// extensions.
+	confidencePluginExtension = 100
+
+	// confidenceMismatch is the score for an extension match whose content
+	// the extractor's sniffer did not recognise.
+	confidenceMismatch = 10
+
+	// confidenceFallback is the score of the generic extractor.
+	confidenceFallback = 1
+)
+
+// sniffHeaderSize is how much of a file is read up front for sniffing.
+const sniffHeaderSize = 8192
+
+// Sniffer is an optional interface for extractors that can recognise a file
+// by its content. Sniff returns a confidence from 0 to 100 with a short
+// reason; 0 means the content was not recognised.
+type Sniffer interface {
+	Sniff(in *SniffInput) (confidence int, reason string)
+}
+
+// SniffInput is what a Sniffer sees: the file name, its first bytes and
+// random access to the rest. Reader is nil for directories.
+type SniffInput struct {
+	Name   string
+	Header []byte
+	Reader io.ReaderAt
+	Size   int64
+
+	tiffRead    bool
+	tiff        *tiffFile
+	tiffEntries []tiffEntry
+}
+
+// Detection is one extractor's claim on a file.
+type Detection struct {
+	Extractor  Extractor `json:"-"`
+	Name       string    `json:"extractor"`
+	Confidence int       `json:"confidence"`
+	Reason     string    `json:"reason"`
+}
+
+// String formats the detection for --explain output.
+func (d Detection) String() string {
+	return fmt.Sprintf("%3d  %-10s %s", d.Confidence, d.Name, d.Reason)
+}
+
+// hasPrefix reports whether the header starts with prefix.
+func (in *SniffInput) hasPrefix(prefix string) bool {
+	return bytes.HasPrefix(in.Header, []byte(prefix))
+}
+
+// at reports whether the header holds s at offset.
+func (in *SniffInput) at(offset int, s string) bool {
+	return len(in.Header) >= offset+len(s) && string(in.Header[offset:offset+len(s)]) == s
+}
+
+// contains reports whether the header contains s.
+func (in *SniffInput) contains(s string) bool {
+	return bytes.Contains(in.Header, []byte(s))
+}
+
+// firstIFD returns the first TIFF directory, reading it once for all
+// TIFF-based sniffers.
+func (in *SniffInput) firstIFD() (*tiffFile, []tiffEntry, bool) {
+	if !in.tiffRead {
+		in.tiffRead = true
+		if in.Reader != nil && isTIFFHeader(in.Header) {
+			if t, err := openTIFF(in.Reader); err == nil {
+				if entries, _, err := t.readIFD(t.firstIFD); err == nil {
+					in.tiff, in.tiffEntries = t, entries
+				}
+			}
+		}
+	}
+	return in.tiff, in.tiffEntries, in.tiff != nil
+}
+
+// imageDescription returns the first IFD's ImageDescription tag.
+func (in *SniffInput) imageDescription() string {
+	_, entries, ok := in.firstIFD()
+	if !ok {
+		return ""
+	}
+	if entry, ok := findTIFFEntry(entries, tiffTagImageDescription); ok {
+		return entry.String()
+	}
+	return ""
+}
+
+// gunzipHeader decompresses the start of a gzip (or BGZF) file.
+func (in *SniffInput) gunzipHeader(n int) []byte {
+	if len(in.Header) < 2 || in.Header[0] != 0x1f || in.Header[1] != 0x8b || in.Reader == nil {
+		return nil
+	}
+	zr, err := gzip.NewReader(io.NewSectionReader(in.Reader, 0, in.Size))
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = zr.Close() }()
+	buf := make([]byte, n)
+	read, _ := io.ReadFull(zr, buf)
+	return buf[:read]
+}
+
+// openSniffInput opens path for sniffing. Directories get an input with no
+// content; the returned closer is always safe to call.
+func openSniffInput(path string) (*SniffInput, func(), error) {
+	in := &SniffInput{Name: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	if info.IsDir() {
+		return in, func() {}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	header := make([]byte, sniffHeaderSize)
+	n, _ := io.ReadFull(f, header)
+	in.Header = header[:n]
+	in.Reader = f
+	in.Size = info.Size()
+	return in, func() { _ = f.Close() }, nil
+}
+
+// Detect scores every registered extractor against the file at path and
+// returns the ones with a non-zero confidence, best first. Ties keep
+// registration order. Files that cannot be read are scored by name only.
+func (r *ExtractorRegistry) Detect(path string) []Detection {
+	in, closeInput, err := openSniffInput(path)
+	defer closeInput()
+	if err != nil {
+		in = &SniffInput{Name: path}
+	}
+
+	var detections []Detection
+	for _, extractor := range r.extractors {
+		confidence, reason := scoreExtractor(extractor, in)
+		if confidence > 0 {
+			detections = append(detections, Detection{
+				Extractor:  extractor,
+				Name:       extractor.Name(),
+				Confidence: confidence,
+				Reason:     reason,
+			})
+		}
+	}
+	sort.SliceStable(detections, func(i, j int) bool {
+		return detections[i].Confidence > detections[j].Confidence
+	})
+	return detections
+}
+
+// DetectExtractor returns the best-scoring extractor for path, or nil.
+func (r *ExtractorRegistry) DetectExtractor(path string) Extractor {
+	if detections := r.Detect(path); len(detections) > 0 {
+		return detections[0].Extractor
+	}
+	return nil
+}
+
+// scoreExtractor combines an extractor's sniffer result with its
+// extension match.
+func scoreExtractor(extractor Extractor, in *SniffInput) (int, string) {
+	if sniffer, ok := extractor.(Sniffer); ok {
+		if confidence, reason := sniffer.Sniff(in); confidence > 0 {
+			if confidence > 100 {
+				confidence = 100
+			}
+			return confidence, reason
+		}
+	}
+	if !extractor.CanHandle(in.Name) {
+		return 0, ""
+	}
+	ext := filepath.Ext(in.Name)
+	if _, ok := extractor.(Sniffer); ok && in.Reader != nil {
+		return confidenceMismatch, fmt.Sprintf("extension %s matches, but content signature not found", ext)
+	}
+	if ext == "" {
+		return confidenceExtension, "file name matches"
+	}
+	return confidenceExtension, fmt.Sprintf("extension %s matches", ext)
+}
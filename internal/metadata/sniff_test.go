@@ -0,0 +1,186 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSniffTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractorRegistry_Detect(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	dicom := &testDICOMWriter{order: binary.LittleEndian, explicit: true}
+	writeTestMRDataset(dicom)
+
+	tests := []struct {
+		name           string
+		file           string
+		data           []byte
+		wantExtractor  string
+		wantConfidence int
+	}{
+		{
+			name:           "FCS without extension",
+			file:           "tube_001",
+			data:           buildTestFCS("3.1", '/', [][2]string{{"$TOT", "0"}, {"$PAR", "0"}}, nil),
+			wantExtractor:  "FCS",
+			wantConfidence: 100,
+		},
+		{
+			name:           "DICOM without extension",
+			file:           "IM000001",
+			data:           buildTestDICOM(dicomExplicitLittle, dicom.buf.Bytes()),
+			wantExtractor:  "DICOM",
+			wantConfidence: 100,
+		},
+		{
+			name:           "OME-TIFF named .tif",
+			file:           "cells.tif",
+			data:           buildTestTIFF(t, testOMEXML, false),
+			wantExtractor:  "OME-TIFF",
+			wantConfidence: 95,
+		},
+		{
+			name:           "ImageJ TIFF",
+			file:           "stack.tif",
+			data:           buildTestTIFF(t, "ImageJ=1.54f", false),
+			wantExtractor:  "TIFF",
+			wantConfidence: 80,
+		},
+		{
+			name:           "TIFF misnamed .czi",
+			file:           "export.czi",
+			data:           buildTestTIFF(t, "plain", false),
+			wantExtractor:  "TIFF",
+			wantConfidence: 60,
+		},
+		{
+			name:           "FASTQ misnamed .txt",
+			file:           "reads.txt",
+			data:           []byte("@r1\nACGT\n+\nIIII\n"),
+			wantExtractor:  "FASTQ",
+			wantConfidence: 80,
+		},
+		{
+			name:           "legacy ND2",
+			file:           "legacy.nd2",
+			data:           append([]byte(jp2SignatureBox), make([]byte, 64)...),
+			wantExtractor:  "Nikon ND2",
+			wantConfidence: 60,
+		},
+		{
+			name:           "JPEG 2000 image",
+			file:           "photo.jp2",
+			data:           append([]byte(jp2SignatureBox), make([]byte, 64)...),
+			wantExtractor:  "Generic",
+			wantConfidence: confidenceFallback,
+		},
+		{
+			name:           "unknown content",
+			file:           "notes.txt",
+			data:           []byte("nothing to see here"),
+			wantExtractor:  "Generic",
+			wantConfidence: confidenceFallback,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeSniffTestFile(t, tt.file, tt.data)
+			detections := registry.Detect(path)
+			if len(detections) == 0 {
+				t.Fatal("Detect() returned no detections")
+			}
+			best := detections[0]
+			if best.Name != tt.wantExtractor || best.Confidence != tt.wantConfidence {
+				t.Errorf("Detect()[0] = %s (%d, %q), want %s (%d)",
+					best.Name, best.Confidence, best.Reason, tt.wantExtractor, tt.wantConfidence)
+			}
+			for i := 1; i < len(detections); i++ {
+				if detections[i].Confidence > detections[i-1].Confidence {
+					t.Errorf("Detect() not sorted: %v", detections)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractorRegistry_DetectExtensionMismatch(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	path := writeSniffTestFile(t, "export.czi", buildTestTIFF(t, "plain", false))
+	for _, d := range registry.Detect(path) {
+		if d.Name == "Zeiss CZI" {
+			if d.Confidence != confidenceMismatch {
+				t.Errorf("Zeiss CZI confidence = %d, want %d", d.Confidence, confidenceMismatch)
+			}
+			return
+		}
+	}
+	t.Error("Detect() did not score Zeiss CZI for a .czi file")
+}
+
+func TestExtractorRegistry_ExtractSniffsRenamedFile(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	fcs := buildTestFCS("3.0", '/', [][2]string{
+		{"$BEGINANALYSIS", "0"}, {"$ENDANALYSIS", "0"},
+		{"$BEGINSTEXT", "0"}, {"$ENDSTEXT", "0"},
+		{"$BYTEORD", "1,2,3,4"}, {"$DATATYPE", "F"}, {"$MODE", "L"},
+		{"$NEXTDATA", "0"}, {"$TOT", "500"}, {"$PAR", "1"},
+		{"$P1N", "FSC-A"}, {"$P1R", "1024"}, {"$P1B", "32"}, {"$P1E", "0,0"},
+	}, make([]byte, 2000))
+	path := writeSniffTestFile(t, "tube.dat", fcs)
+
+	if got := registry.FindExtractor(path); got == nil || got.Name() != "Generic" {
+		t.Fatalf("FindExtractor() = %v, want Generic by extension", got)
+	}
+	if got := registry.DetectExtractor(path); got == nil || got.Name() != "FCS" {
+		t.Fatalf("DetectExtractor() = %v, want FCS", got)
+	}
+	fields, err := registry.Extract(path)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if fields["format"] != "FCS" {
+		t.Errorf("Extract()[format] = %v, want FCS", fields["format"])
+	}
+}
+
+func TestExtractorRegistry_DetectDirectory(t *testing.T) {
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+
+	root := filepath.Join(t.TempDir(), "plate.zarr")
+	writeZarrFiles(t, root, map[string]string{".zgroup": `{"zarr_format": 2}`})
+	if got := registry.DetectExtractor(root); got == nil || got.Name() != "Zarr" {
+		t.Errorf("DetectExtractor() = %v, want Zarr", got)
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file implements the metadata store: a record kept next to each data
+// file holding its current metadata and the history of how it got there.
+//
+// Locally a file's record is .cicada/<name>.metadata.json in the file's
+// directory; in S3 it is the object <key>.metadata.json. A record keeps the
+// extracted fields and the enrichment fields (manual edits, sample sheets,
+// vocabulary fixes) apart, so re-extracting a file never loses enrichment.
+// Enrichment takes precedence in the merged Metadata.Fields. Every change is
+// a numbered version listing the fields it added, changed or removed.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// StoreDir is the directory, next to the data files, that holds their
+// local metadata records.
+const StoreDir = ".cicada"
+
+// RecordSuffix is appended to a file's name to name its metadata record.
+const RecordSuffix = ".metadata.json"
+
+// Kinds of change recorded in a metadata history.
+const (
+	ChangeExtraction = "extraction"
+	ChangeEnrichment = "enrichment"
+	ChangeValidation = "validation"
+)
+
+// StoreBackend is an object store holding metadata records. Keys are
+// "/"-separated. sync.Backend satisfies this interface.
+type StoreBackend interface {
+	Read(ctx context.Context, key string) (io.ReadCloser, error)
+	Write(ctx context.Context, key string, r io.Reader, size int64) error
+}
+
+// MetadataStore loads and saves metadata records.
+type MetadataStore struct {
+	backend StoreBackend // nil for local files
+}
+
+// NewLocalMetadataStore creates a store that keeps each file's record in
+// the .cicada directory next to it.
+func NewLocalMetadataStore() *MetadataStore {
+	return &MetadataStore{}
+}
+
+// NewMetadataStore creates a store that keeps each object's record in
+// backend as <key>.metadata.json.
+func NewMetadataStore(backend StoreBackend) *MetadataStore {
+	return &MetadataStore{backend: backend}
+}
+
+// MetadataRecord is the stored metadata of one file.
+type MetadataRecord struct {
+	// Metadata is the current metadata, with enrichment applied over the
+	// extracted fields.
+	Metadata Metadata `json:"metadata"`
+
+	Extracted  map[string]interface{} `json:"extracted,omitempty"`
+	Enrichment map[string]interface{} `json:"enrichment,omitempty"`
+
+	// Validation is the most recent validation result.
+	Validation *ValidationSummary `json:"validation,omitempty"`
+
+	History []MetadataVersion `json:"history"`
+}
+
+// MetadataVersion is one change to a record.
+type MetadataVersion struct {
+	Version    int                `json:"version"`
+	Kind       string             `json:"kind"` // extraction, enrichment or validation
+	Source     string             `json:"source,omitempty"`
+	Timestamp  time.Time          `json:"timestamp"`
+	Changes    []FieldChange      `json:"changes,omitempty"`
+	Validation *ValidationSummary `json:"validation,omitempty"`
+}
+
+// FieldChange is a field added (Old nil), removed (New nil) or changed by
+// a version.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// String formats the change as a diff line.
+func (c FieldChange) String() string {
+	switch {
+	case c.Old == nil:
+		return fmt.Sprintf("+ %s: %v", c.Field, c.New)
+	case c.New == nil:
+		return fmt.Sprintf("- %s: %v", c.Field, c.Old)
+	}
+	return fmt.Sprintf("~ %s: %v -> %v", c.Field, c.Old, c.New)
+}
+
+// ValidationSummary is the outcome of validating a file against a schema
+// or preset.
+type ValidationSummary struct {
+	Against  string   `json:"against"`
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Score    *float64 `json:"score,omitempty"` // Quality score, 0-100
+}
+
+// String summarises the outcome on one line.
+func (v ValidationSummary) String() string {
+	status := "valid"
+	if !v.Valid {
+		status = "invalid"
+	}
+	s := fmt.Sprintf("%s against %s, %d errors, %d warnings", status, v.Against, len(v.Errors), len(v.Warnings))
+	if v.Score != nil {
+		s += fmt.Sprintf(", quality %.0f/100", *v.Score)
+	}
+	return s
+}
+
+// SummarizeValidation summarises a schema validation result.
+func SummarizeValidation(schema string, result ValidationResult) ValidationSummary {
+	summary := ValidationSummary{Against: schema, Valid: result.Valid}
+	for _, e := range result.Errors {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %s", e.Field, e.Message))
+	}
+	for _, w := range result.Warnings {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("%s: %s", w.Field, w.Message))
+	}
+	if result.Score != nil {
+		score := float64(result.Score.Overall)
+		summary.Score = &score
+	}
+	return summary
+}
+
+// SummarizePresetValidation summarises a preset validation result.
+func SummarizePresetValidation(preset string, result *PresetValidationResult) ValidationSummary {
+	score := result.QualityScore()
+	return ValidationSummary{
+		Against:  preset,
+		Valid:    result.IsValid,
+		Errors:   result.Errors,
+		Warnings: result.Warnings,
+		Score:    &score,
+	}
+}
+
+// RecordPath returns where the record of path is kept.
+func (s *MetadataStore) RecordPath(path string) string {
+	path = strings.TrimRight(path, "/")
+	if s.backend != nil {
+		return path + RecordSuffix
+	}
+	return filepath.Join(filepath.Dir(path), StoreDir, filepath.Base(path)+RecordSuffix)
+}
+
+// RecordLayoutPath maps the "/"-separated relative path of a record between
+// the local layout, with records in StoreDir next to the data files, and the
+// object store layout, with records next to the objects. toLocal gives the
+// direction. Other paths are returned unchanged.
+func RecordLayoutPath(relPath string, toLocal bool) string {
+	if !strings.HasSuffix(relPath, RecordSuffix) {
+		return relPath
+	}
+	dir, file := path.Split(relPath)
+	dir = strings.TrimSuffix(dir, "/")
+	inStore := path.Base(dir) == StoreDir
+	switch {
+	case !toLocal && inStore:
+		return path.Join(path.Dir(dir), file)
+	case toLocal && !inStore:
+		return path.Join(dir, StoreDir, file)
+	}
+	return relPath
+}
+
+// Load returns the record of path. A file with no record gets an empty
+// one, without history.
+func (s *MetadataStore) Load(ctx context.Context, path string) (*MetadataRecord, error) {
+	data, err := s.read(ctx, s.RecordPath(path))
+	if isNotStored(err) {
+		return newMetadataRecord(path), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read metadata record: %w", err)
+	}
+
+	record := &MetadataRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("parse metadata record %s: %w", s.RecordPath(path), err)
+	}
+	if record.Metadata.Fields == nil {
+		record.Metadata.Fields = make(map[string]interface{})
+	}
+	return record, nil
+}
+
+// Save writes the record of path.
+func (s *MetadataStore) Save(ctx context.Context, path string, record *MetadataRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal metadata record: %w", err)
+	}
+	data = append(data, '\n')
+
+	key := s.RecordPath(path)
+	if s.backend != nil {
+		if err := s.backend.Write(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+			return fmt.Errorf("write metadata record: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(key), 0755); err != nil {
+		return fmt.Errorf("create metadata store: %w", err)
+	}
+	if err := os.WriteFile(key, data, 0644); err != nil {
+		return fmt.Errorf("write metadata record: %w", err)
+	}
+	return nil
+}
+
+// read reads a record from the backend or the local file system.
+func (s *MetadataStore) read(ctx context.Context, key string) ([]byte, error) {
+	if s.backend == nil {
+		return os.ReadFile(key)
+	}
+	r, err := s.backend.Read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return io.ReadAll(r)
+}
+
+// isNotStored reports whether err means the record does not exist.
+func isNotStored(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	return errors.Is(err, fs.ErrNotExist) || errors.As(err, &noSuchKey)
+}
+
+// newMetadataRecord creates the empty record of path.
+func newMetadataRecord(path string) *MetadataRecord {
+	path = strings.TrimRight(path, "/")
+	return &MetadataRecord{
+		Metadata: Metadata{
+			Fields: make(map[string]interface{}),
+			FileInfo: FileInfo{
+				Filename: filepath.Base(path),
+				Path:     path,
+			},
+		},
+	}
+}
+
+// Version returns the current version number, zero for a record with no
+// history.
+func (r *MetadataRecord) Version() int {
+	return len(r.History)
+}
+
+// SetExtracted replaces the extracted fields. It returns the new version,
+// or nil if the fields did not change.
+func (r *MetadataRecord) SetExtracted(source string, fields map[string]interface{}) *MetadataVersion {
+	r.Extracted = storedFields(fields)
+	if format, ok := r.Extracted["format"].(string); ok {
+		r.Metadata.FileInfo.Format = format
+	}
+	if size, ok := numericValue(r.Extracted["file_size"]); ok {
+		r.Metadata.FileInfo.Size = int64(size)
+	}
+	return r.update(ChangeExtraction, source)
+}
+
+// Enrich sets enrichment fields; a nil value removes the field's
+// enrichment. It returns the new version, or nil if nothing changed.
+func (r *MetadataRecord) Enrich(source string, fields map[string]interface{}) *MetadataVersion {
+	if r.Enrichment == nil {
+		r.Enrichment = make(map[string]interface{})
+	}
+	for key, value := range storedFields(fields) {
+		if value == nil {
+			delete(r.Enrichment, key)
+		} else {
+			r.Enrichment[key] = value
+		}
+	}
+	return r.update(ChangeEnrichment, source)
+}
+
+// AddValidation records a validation result. A result identical to the
+// previous one is not recorded again, and nil is returned.
+func (r *MetadataRecord) AddValidation(summary ValidationSummary) *MetadataVersion {
+	if r.Validation != nil && reflect.DeepEqual(*r.Validation, summary) {
+		return nil
+	}
+	r.Validation = &summary
+	r.Metadata.SchemaName = summary.Against
+	version := r.addVersion(ChangeValidation, summary.Against, nil)
+	version.Validation = &summary
+	return version
+}
+
+// update recomputes the merged fields and records a version if they
+// changed.
+func (r *MetadataRecord) update(kind, source string) *MetadataVersion {
+	merged := make(map[string]interface{}, len(r.Extracted)+len(r.Enrichment))
+	for key, value := range r.Extracted {
+		merged[key] = value
+	}
+	for key, value := range r.Enrichment {
+		merged[key] = value
+	}
+
+	changes := diffFields(r.Metadata.Fields, merged)
+	r.Metadata.Fields = merged
+	if len(changes) == 0 {
+		return nil
+	}
+	return r.addVersion(kind, source, changes)
+}
+
+// addVersion appends a version and a matching provenance step.
+func (r *MetadataRecord) addVersion(kind, source string, changes []FieldChange) *MetadataVersion {
+	parameters := map[string]interface{}{"version": len(r.History) + 1}
+	if source != "" {
+		parameters["source"] = source
+	}
+	if len(changes) > 0 {
+		parameters["changes"] = len(changes)
+	}
+	recordWorkflowStep(&r.Metadata, kind, parameters)
+	if r.Metadata.CreatedAt.IsZero() {
+		r.Metadata.CreatedAt = r.Metadata.UpdatedAt
+	}
+
+	r.History = append(r.History, MetadataVersion{
+		Version:   len(r.History) + 1,
+		Kind:      kind,
+		Source:    source,
+		Timestamp: r.Metadata.UpdatedAt,
+		Changes:   changes,
+	})
+	return &r.History[len(r.History)-1]
+}
+
+// diffFields lists the changes from old to new, sorted by field.
+func diffFields(old, new map[string]interface{}) []FieldChange {
+	var changes []FieldChange
+	for key, value := range new {
+		if previous, ok := old[key]; !ok || !reflect.DeepEqual(previous, value) {
+			changes = append(changes, FieldChange{Field: key, Old: previous, New: value})
+		}
+	}
+	for key, value := range old {
+		if _, ok := new[key]; !ok {
+			changes = append(changes, FieldChange{Field: key, Old: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// storedFields returns fields as they read back from JSON, so values that
+// only differ in Go type (int and float64, time.Time and string) compare
+// equal to stored ones.
+func storedFields(fields map[string]interface{}) map[string]interface{} {
+	stored := make(map[string]interface{}, len(fields))
+	data, err := json.Marshal(fields)
+	if err == nil && json.Unmarshal(data, &stored) == nil {
+		return stored
+	}
+	// Values JSON cannot hold are kept one by one where possible
+	stored = make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		var v interface{}
+		if data, err := json.Marshal(value); err == nil && json.Unmarshal(data, &v) == nil {
+			stored[key] = v
+		}
+	}
+	return stored
+}
@@ -0,0 +1,246 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// memoryStoreBackend is a StoreBackend over a map, reporting missing keys
+// the way S3 does.
+type memoryStoreBackend struct {
+	objects map[string][]byte
+}
+
+func (b *memoryStoreBackend) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := b.objects[key]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *memoryStoreBackend) Write(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	b.objects[key] = data
+	return nil
+}
+
+func TestMetadataStore_RecordPath(t *testing.T) {
+	local := NewLocalMetadataStore()
+	if got, want := local.RecordPath(filepath.Join("data", "a.czi")), filepath.Join("data", StoreDir, "a.czi.metadata.json"); got != want {
+		t.Errorf("local RecordPath() = %q, want %q", got, want)
+	}
+	if got, want := local.RecordPath(filepath.Join("data", "image.zarr")+"/"), filepath.Join("data", StoreDir, "image.zarr.metadata.json"); got != want {
+		t.Errorf("local RecordPath() of directory = %q, want %q", got, want)
+	}
+
+	remote := NewMetadataStore(&memoryStoreBackend{})
+	if got, want := remote.RecordPath("run42/a.czi"), "run42/a.czi.metadata.json"; got != want {
+		t.Errorf("backend RecordPath() = %q, want %q", got, want)
+	}
+}
+
+func TestMetadataStore_LoadSave(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "reads.fastq")
+
+	tests := []struct {
+		name  string
+		store *MetadataStore
+	}{
+		{"Local", NewLocalMetadataStore()},
+		{"Backend", NewMetadataStore(&memoryStoreBackend{objects: make(map[string][]byte)})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := tt.store.Load(ctx, path)
+			if err != nil {
+				t.Fatalf("Load() of missing record error = %v", err)
+			}
+			if record.Version() != 0 || record.Metadata.FileInfo.Filename != "reads.fastq" {
+				t.Fatalf("Load() of missing record = %+v, want empty record for reads.fastq", record)
+			}
+
+			record.SetExtracted("FASTQ", map[string]interface{}{"format": "FASTQ", "file_size": 1024, "total_reads": 10})
+			if err := tt.store.Save(ctx, path, record); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			loaded, err := tt.store.Load(ctx, path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if loaded.Version() != 1 || loaded.Metadata.Fields["total_reads"] != float64(10) {
+				t.Errorf("Load() = version %d, fields %v", loaded.Version(), loaded.Metadata.Fields)
+			}
+			if loaded.Metadata.FileInfo.Format != "FASTQ" || loaded.Metadata.FileInfo.Size != 1024 {
+				t.Errorf("FileInfo = %+v, want FASTQ format and size 1024", loaded.Metadata.FileInfo)
+			}
+			if len(loaded.Metadata.Provenance.Workflow) != 1 || loaded.Metadata.CreatedAt.IsZero() {
+				t.Errorf("Provenance = %+v, CreatedAt = %v", loaded.Metadata.Provenance, loaded.Metadata.CreatedAt)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, StoreDir, "reads.fastq.metadata.json")); err != nil {
+		t.Errorf("local record not written: %v", err)
+	}
+}
+
+func TestMetadataStore_LoadErrors(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.czi")
+	store := NewLocalMetadataStore()
+	if err := os.MkdirAll(filepath.Join(dir, StoreDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store.RecordPath(path), []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(ctx, path); err == nil {
+		t.Error("Load() of corrupt record succeeded, want error")
+	}
+
+	failing := NewMetadataStore(failingStoreBackend{})
+	if _, err := failing.Load(ctx, "a.czi"); err == nil {
+		t.Error("Load() with failing backend succeeded, want error")
+	}
+}
+
+// failingStoreBackend fails every read and write.
+type failingStoreBackend struct{}
+
+func (failingStoreBackend) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, errors.New("access denied")
+}
+
+func (failingStoreBackend) Write(ctx context.Context, key string, r io.Reader, size int64) error {
+	return errors.New("access denied")
+}
+
+func TestMetadataRecord_History(t *testing.T) {
+	record := newMetadataRecord("data/a.czi")
+
+	if v := record.SetExtracted("CZI", map[string]interface{}{"format": "CZI", "channels": 3, "objective": "10x"}); v == nil || v.Kind != ChangeExtraction {
+		t.Fatalf("SetExtracted() = %+v, want extraction version", v)
+	}
+
+	// Re-extracting the same values, even with other Go types, is no change
+	if v := record.SetExtracted("CZI", map[string]interface{}{"format": "CZI", "channels": float64(3), "objective": "10x"}); v != nil {
+		t.Errorf("SetExtracted() of same fields = %+v, want nil", v)
+	}
+
+	v := record.Enrich("manual", map[string]interface{}{"objective": "20x", "operator": "jdoe"})
+	if v == nil || v.Kind != ChangeEnrichment || v.Source != "manual" {
+		t.Fatalf("Enrich() = %+v, want enrichment version from manual", v)
+	}
+	wantChanges := []FieldChange{
+		{Field: "objective", Old: "10x", New: "20x"},
+		{Field: "operator", New: "jdoe"},
+	}
+	if !reflect.DeepEqual(v.Changes, wantChanges) {
+		t.Errorf("Enrich() changes = %+v, want %+v", v.Changes, wantChanges)
+	}
+
+	// Enrichment survives re-extraction
+	v = record.SetExtracted("CZI", map[string]interface{}{"format": "CZI", "channels": 4, "objective": "10x"})
+	if v == nil || !reflect.DeepEqual(v.Changes, []FieldChange{{Field: "channels", Old: float64(3), New: float64(4)}}) {
+		t.Errorf("SetExtracted() = %+v, want only channels changed", v)
+	}
+	if record.Metadata.Fields["objective"] != "20x" {
+		t.Errorf("objective = %v, want enrichment 20x", record.Metadata.Fields["objective"])
+	}
+
+	// A nil value drops the enrichment, restoring the extracted value
+	v = record.Enrich("manual", map[string]interface{}{"objective": nil})
+	if v == nil || record.Metadata.Fields["objective"] != "10x" {
+		t.Errorf("Enrich(nil) = %+v, objective = %v, want extracted 10x", v, record.Metadata.Fields["objective"])
+	}
+
+	summary := ValidationSummary{Against: "zeiss-lsm-880", Valid: false, Errors: []string{"missing required field: sample_id"}}
+	if v := record.AddValidation(summary); v == nil || v.Validation == nil || v.Source != "zeiss-lsm-880" {
+		t.Errorf("AddValidation() = %+v, want validation version", v)
+	}
+	if v := record.AddValidation(summary); v != nil {
+		t.Errorf("AddValidation() of same result = %+v, want nil", v)
+	}
+	if record.Metadata.SchemaName != "zeiss-lsm-880" {
+		t.Errorf("SchemaName = %q, want zeiss-lsm-880", record.Metadata.SchemaName)
+	}
+
+	var kinds []string
+	for i, version := range record.History {
+		if version.Version != i+1 {
+			t.Errorf("History[%d].Version = %d, want %d", i, version.Version, i+1)
+		}
+		kinds = append(kinds, version.Kind)
+	}
+	wantKinds := []string{ChangeExtraction, ChangeEnrichment, ChangeExtraction, ChangeEnrichment, ChangeValidation}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Errorf("History kinds = %v, want %v", kinds, wantKinds)
+	}
+	if len(record.Metadata.Provenance.Workflow) != len(wantKinds) {
+		t.Errorf("Workflow has %d steps, want %d", len(record.Metadata.Provenance.Workflow), len(wantKinds))
+	}
+}
+
+func TestFieldChange_String(t *testing.T) {
+	tests := []struct {
+		change FieldChange
+		want   string
+	}{
+		{FieldChange{Field: "a", New: 1}, "+ a: 1"},
+		{FieldChange{Field: "a", Old: 1}, "- a: 1"},
+		{FieldChange{Field: "a", Old: 1, New: 2}, "~ a: 1 -> 2"},
+	}
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSummarizePresetValidation(t *testing.T) {
+	result := &PresetValidationResult{
+		IsValid:  false,
+		Errors:   []string{"missing required field: sample_id"},
+		Warnings: []string{"missing recommended field: operator"},
+		Missing:  []string{"sample_id"},
+		Present:  []string{"objective"},
+	}
+	summary := SummarizePresetValidation("zeiss-lsm-880", result)
+	if summary.Valid || summary.Against != "zeiss-lsm-880" || summary.Score == nil {
+		t.Fatalf("SummarizePresetValidation() = %+v", summary)
+	}
+	want := "invalid against zeiss-lsm-880, 1 errors, 1 warnings"
+	if got := summary.String(); len(got) < len(want) || got[:len(want)] != want {
+		t.Errorf("String() = %q, want prefix %q", got, want)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file implements a minimal TIFF/BigTIFF directory reader.
+//
+// Go's standard library has no TIFF support, and golang.org/x/image/tiff
+// only decodes pixels without exposing the raw tag table. Several instrument
+// formats (OME-TIFF, ImageJ TIFF, EER) keep their metadata in TIFF tags, so
+// this reader walks Image File Directories (IFDs) and returns raw tag values
+// without touching pixel data.
+//
+// References:
+//   - TIFF 6.0 Specification (Adobe, 1992)
+//   - BigTIFF: https://www.awaresystems.be/imaging/tiff/bigtiff.html
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TIFF tag numbers used by the extractors.
+const (
+	tiffTagImageWidth       = 256
+	tiffTagImageLength      = 257
+	tiffTagBitsPerSample    = 258
+	tiffTagCompression      = 259
+	tiffTagImageDescription = 270
+	tiffTagSoftware         = 305
+	tiffTagDateTime         = 306
+)
+
+// tiffMaxValueSize bounds the size of a single tag value we are willing to
+// read. OME-XML headers for large plates can reach a few megabytes; anything
+// larger than this is treated as a corrupt file rather than allocated.
+const tiffMaxValueSize = 64 << 20
+
+// tiffMaxIFDEntries bounds the number of entries in a single IFD.
+const tiffMaxIFDEntries = 4096
+
+// tiffFile provides random access to the directories of a TIFF or BigTIFF file.
+type tiffFile struct {
+	r        io.ReaderAt
+	order    binary.ByteOrder
+	bigTIFF  bool
+	firstIFD uint64
+}
+
+// tiffEntry is a single IFD entry with its value bytes resolved.
+type tiffEntry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint64
+	Value []byte
+}
+
+// isTIFFHeader reports whether header starts with a classic TIFF or BigTIFF signature.
+func isTIFFHeader(header []byte) bool {
+	if len(header) < 4 {
+		return false
+	}
+	switch string(header[0:4]) {
+	case "II*\x00", "MM\x00*", "II+\x00", "MM\x00+":
+		return true
+	}
+	return false
+}
+
+// openTIFF reads the TIFF header and returns a reader positioned at the first IFD.
+func openTIFF(r io.ReaderAt) (*tiffFile, error) {
+	header := make([]byte, 16)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read TIFF header: %w", err)
+	}
+	if n < 8 || !isTIFFHeader(header) {
+		return nil, fmt.Errorf("not a valid TIFF file: invalid header")
+	}
+
+	t := &tiffFile{r: r}
+	if header[0] == 'I' {
+		t.order = binary.LittleEndian
+	} else {
+		t.order = binary.BigEndian
+	}
+
+	switch t.order.Uint16(header[2:4]) {
+	case 42:
+		t.firstIFD = uint64(t.order.Uint32(header[4:8]))
+	case 43:
+		if n < 16 {
+			return nil, fmt.Errorf("not a valid BigTIFF file: truncated header")
+		}
+		if t.order.Uint16(header[4:6]) != 8 {
+			return nil, fmt.Errorf("unsupported BigTIFF offset size %d", t.order.Uint16(header[4:6]))
+		}
+		t.bigTIFF = true
+		t.firstIFD = t.order.Uint64(header[8:16])
+	default:
+		return nil, fmt.Errorf("not a valid TIFF file: unknown version")
+	}
+
+	return t, nil
+}
+
+// readIFD reads the directory at offset and returns its entries along with
+// the offset of the next IFD (zero if this is the last one).
+func (t *tiffFile) readIFD(offset uint64) ([]tiffEntry, uint64, error) {
+	countSize, entrySize, offsetSize := 2, 12, 4
+	if t.bigTIFF {
+		countSize, entrySize, offsetSize = 8, 20, 8
+	}
+
+	buf := make([]byte, countSize)
+	if _, err := t.r.ReadAt(buf, int64(offset)); err != nil {
+		return nil, 0, fmt.Errorf("failed to read IFD at offset %d: %w", offset, err)
+	}
+
+	var count uint64
+	if t.bigTIFF {
+		count = t.order.Uint64(buf)
+	} else {
+		count = uint64(t.order.Uint16(buf))
+	}
+	if count > tiffMaxIFDEntries {
+		return nil, 0, fmt.Errorf("IFD at offset %d has too many entries (%d)", offset, count)
+	}
+
+	table := make([]byte, int(count)*entrySize+offsetSize)
+	if _, err := t.r.ReadAt(table, int64(offset)+int64(countSize)); err != nil && err != io.EOF {
+		return nil, 0, fmt.Errorf("failed to read IFD entries: %w", err)
+	}
+
+	entries := make([]tiffEntry, 0, count)
+	for i := 0; i < int(count); i++ {
+		raw := table[i*entrySize : (i+1)*entrySize]
+		entry := tiffEntry{
+			Tag:  t.order.Uint16(raw[0:2]),
+			Type: t.order.Uint16(raw[2:4]),
+		}
+
+		var inline []byte
+		if t.bigTIFF {
+			entry.Count = t.order.Uint64(raw[4:12])
+			inline = raw[12:20]
+		} else {
+			entry.Count = uint64(t.order.Uint32(raw[4:8]))
+			inline = raw[8:12]
+		}
+
+		size := entry.Count * uint64(tiffTypeSize(entry.Type))
+		switch {
+		case size > tiffMaxValueSize:
+			return nil, 0, fmt.Errorf("tag %d value too large (%d bytes)", entry.Tag, size)
+		case size <= uint64(len(inline)):
+			entry.Value = append([]byte(nil), inline[:size]...)
+		default:
+			var valueOffset uint64
+			if t.bigTIFF {
+				valueOffset = t.order.Uint64(inline)
+			} else {
+				valueOffset = uint64(t.order.Uint32(inline))
+			}
+			entry.Value = make([]byte, size)
+			if _, err := t.r.ReadAt(entry.Value, int64(valueOffset)); err != nil && err != io.EOF {
+				return nil, 0, fmt.Errorf("failed to read tag %d value: %w", entry.Tag, err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	tail := table[int(count)*entrySize:]
+	var next uint64
+	if t.bigTIFF {
+		next = t.order.Uint64(tail)
+	} else {
+		next = uint64(t.order.Uint32(tail))
+	}
+
+	return entries, next, nil
+}
+
+// countIFDs follows the IFD chain without reading tag values, stopping at
+// limit directories or a loop.
+func (t *tiffFile) countIFDs(limit int) (int, error) {
+	countSize, entrySize, offsetSize := 2, 12, 4
+	if t.bigTIFF {
+		countSize, entrySize, offsetSize = 8, 20, 8
+	}
+
+	seen := map[uint64]bool{}
+	n := 0
+	for offset := t.firstIFD; offset != 0 && n < limit && !seen[offset]; n++ {
+		seen[offset] = true
+		buf := make([]byte, countSize)
+		if _, err := t.r.ReadAt(buf, int64(offset)); err != nil {
+			return n, fmt.Errorf("failed to read IFD at offset %d: %w", offset, err)
+		}
+		var count uint64
+		if t.bigTIFF {
+			count = t.order.Uint64(buf)
+		} else {
+			count = uint64(t.order.Uint16(buf))
+		}
+		if count > tiffMaxIFDEntries {
+			return n, fmt.Errorf("IFD at offset %d has too many entries (%d)", offset, count)
+		}
+
+		next := make([]byte, offsetSize)
+		if _, err := t.r.ReadAt(next, int64(offset)+int64(countSize)+int64(count)*int64(entrySize)); err != nil {
+			return n + 1, nil
+		}
+		if t.bigTIFF {
+			offset = t.order.Uint64(next)
+		} else {
+			offset = uint64(t.order.Uint32(next))
+		}
+	}
+	return n, nil
+}
+
+// tiffTypeSize returns the size in bytes of a single value of the given TIFF field type.
+func tiffTypeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 6, 7: // BYTE, ASCII, SBYTE, UNDEFINED
+		return 1
+	case 3, 8: // SHORT, SSHORT
+		return 2
+	case 4, 9, 11, 13: // LONG, SLONG, FLOAT, IFD
+		return 4
+	case 5, 10, 12, 16, 17, 18: // RATIONAL, SRATIONAL, DOUBLE, LONG8, SLONG8, IFD8
+		return 8
+	default:
+		return 1
+	}
+}
+
+// findTIFFEntry returns the entry with the given tag, if present.
+func findTIFFEntry(entries []tiffEntry, tag uint16) (tiffEntry, bool) {
+	for _, e := range entries {
+		if e.Tag == tag {
+			return e, true
+		}
+	}
+	return tiffEntry{}, false
+}
+
+// String returns an ASCII tag value with trailing NULs removed.
+func (e tiffEntry) String() string {
+	return strings.TrimRight(string(bytes.TrimRight(e.Value, "\x00")), " ")
+}
+
+// Uint returns the first value of an unsigned integer tag.
+func (e tiffEntry) Uint(order binary.ByteOrder) (uint64, bool) {
+	switch e.Type {
+	case 1:
+		if len(e.Value) >= 1 {
+			return uint64(e.Value[0]), true
+		}
+	case 3:
+		if len(e.Value) >= 2 {
+			return uint64(order.Uint16(e.Value)), true
+		}
+	case 4, 13:
+		if len(e.Value) >= 4 {
+			return uint64(order.Uint32(e.Value)), true
+		}
+	case 16, 18:
+		if len(e.Value) >= 8 {
+			return order.Uint64(e.Value), true
+		}
+	}
+	return 0, false
+}
+
+// readerAtFrom returns r as an io.ReaderAt, buffering it in memory when the
+// reader does not support random access.
+func readerAtFrom(r io.Reader) (io.ReaderAt, error) {
+	if ra, ok := r.(io.ReaderAt); ok {
+		return ra, nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return bytes.NewReader(data), nil
+}
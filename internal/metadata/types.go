@@ -197,6 +197,7 @@ type FlowCytometryMetadata struct {
 	// Channels/Parameters
 	Parameters      []FlowCytometryParameter `json:"parameters,omitempty" yaml:"parameters,omitempty"` // Measured parameters
 	CompensationMatrix [][]float64 `json:"compensation_matrix,omitempty" yaml:"compensation_matrix,omitempty"` // Compensation matrix
+	CompensationParameters []string `json:"compensation_parameters,omitempty" yaml:"compensation_parameters,omitempty"` // Parameter names for matrix rows/columns
 
 	// Sample information
 	SampleID        string `json:"sample_id,omitempty" yaml:"sample_id,omitempty"`                 // Sample identifier
@@ -205,6 +206,7 @@ type FlowCytometryMetadata struct {
 	CellType        string `json:"cell_type,omitempty" yaml:"cell_type,omitempty"`                 // Cell type analyzed
 	Treatment       string `json:"treatment,omitempty" yaml:"treatment,omitempty"`                 // Experimental treatment
 	Operator        string `json:"operator,omitempty" yaml:"operator,omitempty"`                   // Person who ran instrument
+	ExperimentName  string `json:"experiment_name,omitempty" yaml:"experiment_name,omitempty"`     // Experiment or project name
 
 	// Gating/Analysis
 	Populations     []string `json:"populations,omitempty" yaml:"populations,omitempty"`             // Identified populations
@@ -221,6 +223,7 @@ type FlowCytometryParameter struct {
 	Voltage     float64 `json:"voltage,omitempty" yaml:"voltage,omitempty"`             // PMT voltage
 	Filter      string  `json:"filter,omitempty" yaml:"filter,omitempty"`               // Optical filter (e.g., "530/30")
 	Fluorochrome string `json:"fluorochrome,omitempty" yaml:"fluorochrome,omitempty"`   // Fluorochrome (e.g., "FITC", "PE")
+	Amplification string `json:"amplification,omitempty" yaml:"amplification,omitempty"` // $PnE (e.g., "0,0" linear, "4,1" log)
 }
 
 // CryoEMMetadata contains metadata specific to cryo-electron microscopy
@@ -229,6 +232,7 @@ type CryoEMMetadata struct {
 	Manufacturer    string `json:"manufacturer" yaml:"manufacturer"`                               // e.g., "Thermo Fisher", "JEOL"
 	Model           string `json:"model" yaml:"model"`                                             // e.g., "Titan Krios"
 	Voltage         int    `json:"voltage,omitempty" yaml:"voltage,omitempty"`                     // kV (e.g., 300)
+	SphericalAberration float64 `json:"spherical_aberration,omitempty" yaml:"spherical_aberration,omitempty"` // Cs in mm (e.g., 2.7)
 	SerialNumber    string `json:"serial_number,omitempty" yaml:"serial_number,omitempty"`         // Instrument serial number
 
 	// Detector
@@ -0,0 +1,225 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file implements the unit registry used to compare values that carry
+// units with a field's declared FieldSchema.Units.
+//
+// A quantity is a string such as "500 µs" or "37 °C", or an object with
+// "value" and "unit" (or "units") keys. Units convert within a dimension
+// through a base unit, so 500 µs against a field in ms reads as 0.5 ms.
+// Units outside the registry, such as pixels or bytes, only match
+// themselves.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Unit dimensions.
+const (
+	DimensionLength              = "length"
+	DimensionTime                = "time"
+	DimensionTemperature         = "temperature"
+	DimensionConcentration       = "concentration"
+	DimensionMassConcentration   = "mass_concentration"
+	DimensionPower               = "power"
+	DimensionElectricalPotential = "electrical_potential"
+)
+
+// Unit is a unit of measure. A value v in the unit is v*Factor + Offset in
+// the base unit of its dimension.
+type Unit struct {
+	Symbol    string
+	Dimension string
+	Factor    float64
+	Offset    float64
+}
+
+// units lists the registry: each unit and its alternative spellings.
+// Spellings of more than two letters also match regardless of case.
+var units = []struct {
+	unit    Unit
+	aliases []string
+}{
+	// Length, base metre. Wavelengths are lengths.
+	{Unit{"m", DimensionLength, 1, 0}, []string{"metre", "meter", "metres", "meters"}},
+	{Unit{"cm", DimensionLength, 1e-2, 0}, []string{"centimetre", "centimeter", "centimetres", "centimeters"}},
+	{Unit{"mm", DimensionLength, 1e-3, 0}, []string{"millimetre", "millimeter", "millimetres", "millimeters"}},
+	{Unit{"µm", DimensionLength, 1e-6, 0}, []string{"um", "μm", "micron", "microns", "micrometre", "micrometer", "micrometres", "micrometers"}},
+	{Unit{"nm", DimensionLength, 1e-9, 0}, []string{"nanometre", "nanometer", "nanometres", "nanometers"}},
+	{Unit{"pm", DimensionLength, 1e-12, 0}, []string{"picometre", "picometer", "picometres", "picometers"}},
+	{Unit{"Å", DimensionLength, 1e-10, 0}, []string{"\u212b", "angstrom", "angstroms", "ångström", "ang"}},
+
+	// Time, base second
+	{Unit{"ns", DimensionTime, 1e-9, 0}, []string{"nanosecond", "nanoseconds"}},
+	{Unit{"µs", DimensionTime, 1e-6, 0}, []string{"us", "μs", "usec", "microsecond", "microseconds"}},
+	{Unit{"ms", DimensionTime, 1e-3, 0}, []string{"msec", "millisecond", "milliseconds"}},
+	{Unit{"s", DimensionTime, 1, 0}, []string{"sec", "secs", "second", "seconds"}},
+	{Unit{"min", DimensionTime, 60, 0}, []string{"mins", "minute", "minutes"}},
+	{Unit{"h", DimensionTime, 3600, 0}, []string{"hr", "hrs", "hour", "hours"}},
+	{Unit{"d", DimensionTime, 86400, 0}, []string{"day", "days"}},
+
+	// Temperature, base kelvin
+	{Unit{"K", DimensionTemperature, 1, 0}, []string{"kelvin"}},
+	{Unit{"°C", DimensionTemperature, 1, 273.15}, []string{"C", "degC", "celsius", "℃"}},
+	{Unit{"°F", DimensionTemperature, 5.0 / 9, 459.67 * 5 / 9}, []string{"F", "degF", "fahrenheit", "℉"}},
+
+	// Amount concentration, base mol/L
+	{Unit{"M", DimensionConcentration, 1, 0}, []string{"mol/L", "molar"}},
+	{Unit{"mM", DimensionConcentration, 1e-3, 0}, []string{"mmol/L", "millimolar"}},
+	{Unit{"µM", DimensionConcentration, 1e-6, 0}, []string{"uM", "μM", "µmol/L", "umol/L", "micromolar"}},
+	{Unit{"nM", DimensionConcentration, 1e-9, 0}, []string{"nmol/L", "nanomolar"}},
+	{Unit{"pM", DimensionConcentration, 1e-12, 0}, []string{"pmol/L", "picomolar"}},
+
+	// Mass concentration, base g/L
+	{Unit{"g/L", DimensionMassConcentration, 1, 0}, []string{"mg/mL", "mg/ml", "g/l"}},
+	{Unit{"mg/L", DimensionMassConcentration, 1e-3, 0}, []string{"µg/mL", "ug/mL", "µg/ml", "ug/ml", "ng/µL", "ng/uL", "ng/ul", "mg/l"}},
+	{Unit{"µg/L", DimensionMassConcentration, 1e-6, 0}, []string{"ug/L", "ng/mL", "ng/ml", "pg/µL", "pg/uL"}},
+
+	// Power, base watt
+	{Unit{"W", DimensionPower, 1, 0}, []string{"watt", "watts"}},
+	{Unit{"mW", DimensionPower, 1e-3, 0}, []string{"milliwatt", "milliwatts"}},
+	{Unit{"µW", DimensionPower, 1e-6, 0}, []string{"uW", "μW", "microwatt", "microwatts"}},
+
+	// Electrical potential, base volt
+	{Unit{"V", DimensionElectricalPotential, 1, 0}, []string{"volt", "volts"}},
+	{Unit{"kV", DimensionElectricalPotential, 1e3, 0}, []string{"kilovolt", "kilovolts"}},
+	{Unit{"mV", DimensionElectricalPotential, 1e-3, 0}, []string{"millivolt", "millivolts"}},
+}
+
+// unitIndex maps symbols and aliases to units; unitNames maps lower-cased
+// names to units.
+var unitIndex, unitNames = buildUnitIndex()
+
+func buildUnitIndex() (map[string]Unit, map[string]Unit) {
+	index := make(map[string]Unit)
+	names := make(map[string]Unit)
+	for _, entry := range units {
+		index[entry.unit.Symbol] = entry.unit
+		for _, alias := range entry.aliases {
+			index[alias] = entry.unit
+			if len([]rune(alias)) > 2 && !strings.Contains(alias, "/") {
+				names[strings.ToLower(alias)] = entry.unit
+			}
+		}
+	}
+	return index, names
+}
+
+// LookupUnit returns the unit with the given symbol or alias.
+func LookupUnit(symbol string) (Unit, bool) {
+	symbol = strings.TrimSpace(symbol)
+	if u, ok := unitIndex[symbol]; ok {
+		return u, true
+	}
+	u, ok := unitNames[strings.ToLower(symbol)]
+	return u, ok
+}
+
+// ConvertUnit converts value from one unit to another of the same
+// dimension. Units outside the registry convert only to themselves.
+func ConvertUnit(value float64, from, to string) (float64, error) {
+	fromUnit, fromOK := LookupUnit(from)
+	toUnit, toOK := LookupUnit(to)
+	switch {
+	case !fromOK || !toOK:
+		if strings.TrimSpace(from) == strings.TrimSpace(to) {
+			return value, nil
+		}
+		if !fromOK {
+			return 0, fmt.Errorf("unknown unit %q", from)
+		}
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	case fromUnit.Dimension != toUnit.Dimension:
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from, fromUnit.Dimension, to, toUnit.Dimension)
+	}
+	base := value*fromUnit.Factor + fromUnit.Offset
+	return (base - toUnit.Offset) / toUnit.Factor, nil
+}
+
+// quantityPattern matches a number followed by an optional unit.
+var quantityPattern = regexp.MustCompile(`^\s*([-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?)\s*([^\d\s.+\-].*?)?\s*$`)
+
+// ParseQuantity splits a string such as "500 µs" into its value and unit.
+// The unit is empty for a bare number.
+func ParseQuantity(s string) (float64, string, bool) {
+	m := quantityPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, "", false
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return value, m[2], true
+}
+
+// quantity returns the value and unit of a quantity string or object. ok is
+// false if v is not a quantity with a unit.
+func quantity(v interface{}) (value float64, unit string, ok bool) {
+	switch q := v.(type) {
+	case string:
+		value, unit, ok = ParseQuantity(q)
+		return value, unit, ok && unit != ""
+	case map[string]interface{}:
+		unit, _ = q["unit"].(string)
+		if unit == "" {
+			unit, _ = q["units"].(string)
+		}
+		if unit == "" {
+			return 0, "", false
+		}
+		value, ok = toFloat(q["value"])
+		if _, isString := q["value"].(string); isString {
+			ok = false
+		}
+		return value, unit, ok
+	}
+	return 0, "", false
+}
+
+// normalizeQuantity converts a quantity to a number in the given units.
+// Values that are not quantities are returned unchanged. note describes a
+// conversion between different units.
+func normalizeQuantity(v interface{}, units string) (result interface{}, note string, err error) {
+	value, unit, ok := quantity(v)
+	if !ok {
+		return v, "", nil
+	}
+	converted, err := ConvertUnit(value, unit, units)
+	if err != nil {
+		return v, "", err
+	}
+	if canonicalUnit(unit) != canonicalUnit(units) {
+		note = fmt.Sprintf("Converted %s %s to %s %s", formatNumber(value), unit, formatNumber(converted), units)
+	}
+	return converted, note, nil
+}
+
+// canonicalUnit returns the registry symbol for a unit, or the unit itself.
+func canonicalUnit(unit string) string {
+	if u, ok := LookupUnit(unit); ok {
+		return u.Symbol
+	}
+	return strings.TrimSpace(unit)
+}
+
+// formatNumber formats a float without trailing zeros.
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', 10, 64)
+}
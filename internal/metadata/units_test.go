@@ -0,0 +1,123 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{500, "µs", "ms", 0.5},
+		{500, "us", "ms", 0.5},
+		{2, "min", "s", 120},
+		{1.5, "hours", "min", 90},
+		{0.5, "μm", "nm", 500},
+		{488, "nm", "µm", 0.488},
+		{2.5, "Å", "nm", 0.25},
+		{1, "microns", "µm", 1},
+		{37, "°C", "K", 310.15},
+		{98.6, "°F", "°C", 37},
+		{-40, "C", "F", -40},
+		{10, "mM", "µM", 10000},
+		{1, "mg/mL", "µg/mL", 1000},
+		{50, "ng/µL", "mg/L", 50},
+		{0.2, "W", "mW", 200},
+		{300, "kV", "V", 300000},
+		{512, "pixels", "pixels", 512},
+		{3, "Seconds", "ms", 3000},
+	}
+	for _, tt := range tests {
+		got, err := ConvertUnit(tt.value, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("ConvertUnit(%v, %s, %s) error = %v", tt.value, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("ConvertUnit(%v, %s, %s) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ from, to string }{
+		{"ms", "nm"},
+		{"furlongs", "m"},
+		{"pixels", "µm"},
+		{"m", "M"}, // metres are not molar
+	} {
+		if _, err := ConvertUnit(1, tt.from, tt.to); err == nil {
+			t.Errorf("ConvertUnit(1, %s, %s) error = nil, want error", tt.from, tt.to)
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		in    string
+		value float64
+		unit  string
+		ok    bool
+	}{
+		{"500 µs", 500, "µs", true},
+		{"0.5um", 0.5, "um", true},
+		{" -4 °C ", -4, "°C", true},
+		{"1.2e3 nm", 1200, "nm", true},
+		{"42", 42, "", true},
+		{"e/Å²", 0, "", false},
+		{"2025-03-01", 0, "", false},
+		{"fast", 0, "", false},
+	}
+	for _, tt := range tests {
+		value, unit, ok := ParseQuantity(tt.in)
+		if ok != tt.ok || ok && (value != tt.value || unit != tt.unit) {
+			t.Errorf("ParseQuantity(%q) = %v, %q, %v, want %v, %q, %v", tt.in, value, unit, ok, tt.value, tt.unit, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeQuantity(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		units   string
+		want    interface{}
+		note    string
+		wantErr bool
+	}{
+		{"500 µs", "ms", 0.5, "Converted 500 µs to 0.5 ms", false},
+		{"20 ms", "ms", 20.0, "", false},
+		{"20 msec", "ms", 20.0, "", false},
+		{map[string]interface{}{"value": 2, "unit": "min"}, "s", 120.0, "Converted 2 min to 120 s", false},
+		{12.5, "ms", 12.5, "", false},
+		{"unlabelled", "ms", "unlabelled", "", false},
+		{"5 nm", "ms", nil, "", true},
+	}
+	for _, tt := range tests {
+		got, note, err := normalizeQuantity(tt.value, tt.units)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeQuantity(%v, %s) error = %v", tt.value, tt.units, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got != tt.want || note != tt.note {
+			t.Errorf("normalizeQuantity(%v, %s) = %v, %q, want %v, %q", tt.value, tt.units, got, note, tt.want, tt.note)
+		}
+	}
+}
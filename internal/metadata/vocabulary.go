@@ -0,0 +1,304 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+// This file matches free-text values against controlled vocabularies.
+//
+// A value matches a term, best first:
+//
+//  1. After normalisation: case, surrounding and repeated whitespace, and
+//     "_", "-" and "." are ignored, so "Mus Musculus", "GFP " and
+//     "spinning disk" match "Mus musculus", "GFP" and "spinning_disk".
+//  2. Through a synonym: FieldSchema.Synonyms, or the common names of model
+//     organisms, so "mouse" matches "Mus musculus".
+//  3. By spelling: the Damerau-Levenshtein distance between the normalised
+//     forms is small for the length of the value.
+//
+// Normalised and synonym matches are safe to apply automatically. A spelling
+// match is applied only when it is the single closest term and at most two
+// edits away.
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Reasons a value matched a vocabulary term.
+const (
+	MatchNormalized = "normalized"
+	MatchSynonym    = "synonym"
+	MatchSpelling   = "spelling"
+)
+
+// maxSuggestions caps the suggestions offered for a value.
+const maxSuggestions = 3
+
+// maxFixDistance is the largest spelling distance fixed automatically.
+const maxFixDistance = 2
+
+// commonSynonyms are alternative names for widely used terms. They apply to
+// any field whose vocabulary contains the term.
+var commonSynonyms = map[string][]string{
+	"Homo sapiens":              {"human", "humans", "H. sapiens", "H sapiens"},
+	"Mus musculus":              {"mouse", "mice", "M. musculus", "house mouse"},
+	"Rattus norvegicus":         {"rat", "rats", "R. norvegicus", "Norway rat"},
+	"Danio rerio":               {"zebrafish", "zebra fish", "D. rerio"},
+	"Drosophila melanogaster":   {"fruit fly", "fruitfly", "D. melanogaster", "drosophila"},
+	"Caenorhabditis elegans":    {"C. elegans", "worm", "nematode"},
+	"Saccharomyces cerevisiae":  {"yeast", "budding yeast", "baker's yeast", "S. cerevisiae"},
+	"Schizosaccharomyces pombe": {"fission yeast", "S. pombe"},
+	"Escherichia coli":          {"E. coli", "ecoli"},
+	"Arabidopsis thaliana":      {"arabidopsis", "thale cress", "A. thaliana"},
+	"Xenopus laevis":            {"xenopus", "African clawed frog", "X. laevis"},
+	"Gallus gallus":             {"chicken", "G. gallus"},
+	"Sus scrofa":                {"pig", "swine", "S. scrofa"},
+	"Macaca mulatta":            {"rhesus macaque", "rhesus monkey", "M. mulatta"},
+}
+
+// VocabularyMatch is a vocabulary term that a value may have meant.
+type VocabularyMatch struct {
+	Term     string `json:"term"`
+	Reason   string `json:"reason"`             // normalized, synonym or spelling
+	Distance int    `json:"distance,omitempty"` // edits, for spelling matches
+}
+
+// VocabularyFix records a field value replaced by its canonical term.
+type VocabularyFix struct {
+	Field  string `json:"field"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// MatchVocabulary returns the terms of the field's vocabulary that value
+// may have meant, best first. It returns nil if value is in the vocabulary.
+func MatchVocabulary(value string, field FieldSchema) []VocabularyMatch {
+	if len(field.Vocabulary) == 0 || contains(field.Vocabulary, value) {
+		return nil
+	}
+	norm := normalizeTerm(value)
+	if norm == "" {
+		return nil
+	}
+
+	var matches []VocabularyMatch
+	seen := make(map[string]bool)
+	add := func(m VocabularyMatch) {
+		if !seen[m.Term] {
+			seen[m.Term] = true
+			matches = append(matches, m)
+		}
+	}
+
+	for _, term := range field.Vocabulary {
+		if normalizeTerm(term) == norm {
+			add(VocabularyMatch{Term: term, Reason: MatchNormalized})
+		}
+	}
+	for _, term := range field.Vocabulary {
+		synonyms := append(append([]string(nil), field.Synonyms[term]...), commonSynonyms[term]...)
+		for _, synonym := range synonyms {
+			if normalizeTerm(synonym) == norm {
+				add(VocabularyMatch{Term: term, Reason: MatchSynonym})
+			}
+		}
+	}
+
+	// Spelling: allow roughly one edit per three characters, up to three
+	limit := len([]rune(norm)) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 3 {
+		limit = 3
+	}
+	var spelling []VocabularyMatch
+	for _, term := range field.Vocabulary {
+		if seen[term] {
+			continue
+		}
+		if d := editDistance(norm, normalizeTerm(term)); d <= limit {
+			spelling = append(spelling, VocabularyMatch{Term: term, Reason: MatchSpelling, Distance: d})
+		}
+	}
+	sort.SliceStable(spelling, func(i, j int) bool {
+		return spelling[i].Distance < spelling[j].Distance
+	})
+	for _, m := range spelling {
+		add(m)
+	}
+
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+	return matches
+}
+
+// findSimilar returns the vocabulary terms value may have meant.
+func findSimilar(value string, field FieldSchema) []string {
+	matches := MatchVocabulary(value, field)
+	terms := make([]string, len(matches))
+	for i, m := range matches {
+		terms[i] = m.Term
+	}
+	return terms
+}
+
+// canonicalTerm returns the term a value can safely be replaced with.
+func canonicalTerm(value string, field FieldSchema) (VocabularyMatch, bool) {
+	matches := MatchVocabulary(value, field)
+	if len(matches) == 0 {
+		return VocabularyMatch{}, false
+	}
+	best := matches[0]
+	if best.Reason != MatchSpelling {
+		// Several normalised or synonym matches are ambiguous
+		if len(matches) > 1 && matches[1].Reason == best.Reason {
+			return VocabularyMatch{}, false
+		}
+		return best, true
+	}
+	if best.Distance > maxFixDistance || len([]rune(value)) < 4 {
+		return VocabularyMatch{}, false
+	}
+	if len(matches) > 1 && matches[1].Distance == best.Distance {
+		return VocabularyMatch{}, false
+	}
+	return best, true
+}
+
+// FixVocabulary replaces values that are variants of a controlled term with
+// the term itself and records the changes in the metadata's provenance. It
+// returns the changes made.
+func (sm *SchemaManager) FixVocabulary(metadata *Metadata) ([]VocabularyFix, error) {
+	schema, err := sm.LoadSchema(metadata.SchemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixes []VocabularyFix
+	for _, name := range sortedFieldNames(schema.Fields) {
+		field := schema.Fields[name]
+		value, ok := metadata.Fields[name].(string)
+		if !ok || len(field.Vocabulary) == 0 {
+			continue
+		}
+		match, ok := canonicalTerm(value, field)
+		if !ok {
+			continue
+		}
+		metadata.Fields[name] = match.Term
+		fixes = append(fixes, VocabularyFix{Field: name, From: value, To: match.Term, Reason: match.Reason})
+	}
+	if len(fixes) == 0 {
+		return nil, nil
+	}
+
+	changes := make([]interface{}, len(fixes))
+	for i, fix := range fixes {
+		changes[i] = map[string]interface{}{
+			"field":  fix.Field,
+			"from":   fix.From,
+			"to":     fix.To,
+			"reason": fix.Reason,
+		}
+	}
+	recordWorkflowStep(metadata, "vocabulary-fix", map[string]interface{}{
+		"schema":  schema.Name,
+		"changes": changes,
+	})
+	return fixes, nil
+}
+
+// String describes the fix.
+func (f VocabularyFix) String() string {
+	return fmt.Sprintf("%s: %q -> %q (%s)", f.Field, f.From, f.To, f.Reason)
+}
+
+// recordWorkflowStep appends a step run by cicada on the metadata's file to
+// its provenance.
+func recordWorkflowStep(metadata *Metadata, name string, parameters map[string]interface{}) {
+	now := time.Now()
+	step := WorkflowStep{
+		Name:       name,
+		Tool:       "cicada",
+		Version:    toolVersion(),
+		Timestamp:  now,
+		Parameters: parameters,
+	}
+	if metadata.FileInfo.Path != "" {
+		step.Inputs = []string{metadata.FileInfo.Path}
+	}
+	metadata.Provenance.Workflow = append(metadata.Provenance.Workflow, step)
+	metadata.UpdatedAt = now
+}
+
+// toolVersion returns the version of the running binary, if known.
+func toolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return ""
+}
+
+// normalizeTerm folds case, separators and whitespace.
+func normalizeTerm(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.TrimSpace(s) {
+		if unicode.IsSpace(r) || r == '_' || r == '-' || r == '.' {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// editDistance returns the Damerau-Levenshtein distance (optimal string
+// alignment) between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// Three rows: two back, previous and current
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
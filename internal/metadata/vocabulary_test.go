@@ -0,0 +1,205 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchVocabulary(t *testing.T) {
+	organism := FieldSchema{Type: "string", Vocabulary: []string{"Homo sapiens", "Mus musculus", "Danio rerio"}}
+	fluorophore := FieldSchema{Type: "string", Vocabulary: []string{"GFP", "mCherry", "DAPI"}}
+	modality := FieldSchema{
+		Type:       "string",
+		Vocabulary: []string{"widefield", "confocal", "spinning_disk", "two_photon"},
+		Synonyms:   map[string][]string{"two_photon": {"2-photon", "multiphoton"}},
+	}
+
+	tests := []struct {
+		name       string
+		value      string
+		field      FieldSchema
+		wantTerm   string
+		wantReason string
+	}{
+		{"Case", "Mus Musculus", organism, "Mus musculus", MatchNormalized},
+		{"Whitespace", "GFP ", fluorophore, "GFP", MatchNormalized},
+		{"Separator", "spinning disk", modality, "spinning_disk", MatchNormalized},
+		{"Common synonym", "mouse", organism, "Mus musculus", MatchSynonym},
+		{"Schema synonym", "Multiphoton", modality, "two_photon", MatchSynonym},
+		{"Misspelling", "confocl", modality, "confocal", MatchSpelling},
+		{"Transposition", "mCehrry", fluorophore, "mCherry", MatchSpelling},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := MatchVocabulary(tt.value, tt.field)
+			if len(matches) == 0 {
+				t.Fatalf("MatchVocabulary(%q) = none, want %s", tt.value, tt.wantTerm)
+			}
+			if matches[0].Term != tt.wantTerm || matches[0].Reason != tt.wantReason {
+				t.Errorf("MatchVocabulary(%q)[0] = %+v, want %s (%s)", tt.value, matches[0], tt.wantTerm, tt.wantReason)
+			}
+		})
+	}
+
+	if matches := MatchVocabulary("Mus musculus", organism); matches != nil {
+		t.Errorf("MatchVocabulary(term) = %v, want nil", matches)
+	}
+	if matches := MatchVocabulary("xenon arc", organism); len(matches) != 0 {
+		t.Errorf("MatchVocabulary(unrelated) = %v, want none", matches)
+	}
+	if matches := MatchVocabulary("  ", organism); len(matches) != 0 {
+		t.Errorf("MatchVocabulary(blank) = %v, want none", matches)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"confocal", "confocal", 0},
+		{"confocl", "confocal", 1},
+		{"kitten", "sitting", 3},
+		{"ab", "ba", 1},
+		{"mcehrry", "mcherry", 1},
+		{"µm", "um", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalTerm(t *testing.T) {
+	field := FieldSchema{Type: "string", Vocabulary: []string{"RNA-seq", "ChIP-seq", "ATAC-seq", "WGS", "WES"}}
+
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{"rna seq", "RNA-seq", true},
+		{"ChIP-sq", "ChIP-seq", true},
+		{"WXS", "", false}, // too short to fix by spelling, and as close to WGS as to WES
+		{"methylation", "", false},
+	}
+	for _, tt := range tests {
+		match, ok := canonicalTerm(tt.value, field)
+		if ok != tt.wantOK || match.Term != tt.want {
+			t.Errorf("canonicalTerm(%q) = %q, %v, want %q, %v", tt.value, match.Term, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	// Two terms that normalise alike are ambiguous
+	ambiguous := FieldSchema{Type: "string", Vocabulary: []string{"single-end", "single_end"}}
+	if match, ok := canonicalTerm("Single End", ambiguous); ok {
+		t.Errorf("canonicalTerm(ambiguous) = %q, want no fix", match.Term)
+	}
+}
+
+func TestSchemaManager_FixVocabulary(t *testing.T) {
+	sm := NewSchemaManager(nil)
+	if err := sm.AddSchema(&Schema{
+		Name: "lab",
+		Fields: map[string]FieldSchema{
+			"organism":  {Type: "string", Vocabulary: []string{"Homo sapiens", "Mus musculus"}},
+			"read_type": {Type: "string", Vocabulary: []string{"single-end", "paired-end"}, Synonyms: map[string][]string{"paired-end": {"PE"}}},
+			"platform":  {Type: "string", Vocabulary: []string{"Illumina", "ONT"}},
+		},
+	}); err != nil {
+		t.Fatalf("AddSchema() error = %v", err)
+	}
+
+	metadata := &Metadata{
+		SchemaName: "lab",
+		Fields: map[string]interface{}{
+			"organism":  "mouse",
+			"read_type": "pe",
+			"platform":  "Illumina",
+		},
+		FileInfo: FileInfo{Path: "data/reads.fastq"},
+	}
+	fixes, err := sm.FixVocabulary(metadata)
+	if err != nil {
+		t.Fatalf("FixVocabulary() error = %v", err)
+	}
+	if len(fixes) != 2 {
+		t.Fatalf("FixVocabulary() = %v, want 2 fixes", fixes)
+	}
+	if metadata.Fields["organism"] != "Mus musculus" || metadata.Fields["read_type"] != "paired-end" {
+		t.Errorf("Fields = %v, want fixed organism and read_type", metadata.Fields)
+	}
+	if metadata.UpdatedAt.IsZero() {
+		t.Error("UpdatedAt not set")
+	}
+
+	if len(metadata.Provenance.Workflow) != 1 {
+		t.Fatalf("Workflow = %v, want one step", metadata.Provenance.Workflow)
+	}
+	step := metadata.Provenance.Workflow[0]
+	if step.Name != "vocabulary-fix" || step.Tool != "cicada" {
+		t.Errorf("step = %s/%s, want vocabulary-fix/cicada", step.Name, step.Tool)
+	}
+	if len(step.Inputs) != 1 || step.Inputs[0] != "data/reads.fastq" {
+		t.Errorf("step.Inputs = %v, want the file path", step.Inputs)
+	}
+	if changes, _ := step.Parameters["changes"].([]interface{}); len(changes) != 2 {
+		t.Errorf("step.Parameters[changes] = %v, want 2 changes", step.Parameters["changes"])
+	}
+
+	// A second pass has nothing to fix and records nothing
+	fixes, err = sm.FixVocabulary(metadata)
+	if err != nil || len(fixes) != 0 || len(metadata.Provenance.Workflow) != 1 {
+		t.Errorf("second FixVocabulary() = %v, %v with %d steps, want no fixes", fixes, err, len(metadata.Provenance.Workflow))
+	}
+}
+
+func TestSchemaManager_ValidateSuggestions(t *testing.T) {
+	sm := NewSchemaManager(nil)
+	if err := sm.AddSchema(&Schema{
+		Name: "lab",
+		Fields: map[string]FieldSchema{
+			"organism": {Type: "string", Vocabulary: []string{"Homo sapiens", "Mus musculus"}},
+		},
+	}); err != nil {
+		t.Fatalf("AddSchema() error = %v", err)
+	}
+
+	result := sm.ValidateMetadata(&Metadata{SchemaName: "lab", Fields: map[string]interface{}{"organism": "Mus Musculus"}})
+	for _, w := range result.Warnings {
+		if w.Field == "organism" {
+			if len(w.Suggestions) == 0 || w.Suggestions[0] != "Mus musculus" {
+				t.Errorf("Suggestions = %v, want Mus musculus first", w.Suggestions)
+			}
+			return
+		}
+	}
+	t.Errorf("Warnings = %v, want a vocabulary warning for organism", result.Warnings)
+}
+
+func TestFileSchemaLoader_UnknownSynonymTerm(t *testing.T) {
+	dir := t.TempDir()
+	writeSchemaFile(t, dir, "lab.yaml", "name: lab\nread_type:\n  type: string\n  vocabulary: [single-end]\n  synonyms:\n    paired-end: [PE]\n")
+
+	_, err := NewFileSchemaLoader(dir).Load("lab")
+	if err == nil || !strings.Contains(err.Error(), `synonyms for "paired-end"`) {
+		t.Errorf("Load() error = %v, want error for synonyms of a term not in the vocabulary", err)
+	}
+}
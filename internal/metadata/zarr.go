@@ -0,0 +1,876 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata provides metadata extraction for scientific instrument files.
+//
+// # Zarr Format
+//
+// This file implements metadata extraction for Zarr stores, including
+// OME-NGFF (OME-Zarr) images, plates and labels.
+//
+// ## Store Layout
+//
+// A Zarr store is a directory (or object prefix) rather than a single file.
+// Only the JSON metadata documents are read; chunks are never touched:
+//   - Zarr v2: .zgroup and .zarray mark groups and arrays, .zattrs holds
+//     user attributes, and .zmetadata optionally consolidates all of them
+//   - Zarr v3: zarr.json holds node_type, array metadata and attributes,
+//     optionally with inline consolidated_metadata for the hierarchy
+//
+// Child nodes are found from consolidated metadata when present, from the
+// paths declared by OME-NGFF metadata, and, for local stores, by listing
+// group directories. Remote stores are read through ZarrStore, which the
+// sync Backend implementations satisfy, so s3:// stores are read in place.
+//
+// ## OME-NGFF
+//
+// Groups with "multiscales" attributes (nested under "ome" from NGFF 0.5)
+// are images. Axes with units, scale transformations of the full-resolution
+// level, "omero" channel rendering settings, labels, and plate and well
+// layouts are mapped to MicroscopyMetadata field names.
+//
+// ## References and Sources
+//
+// Zarr v2 specification:
+// https://zarr-specs.readthedocs.io/en/latest/v2/v2.0.html
+//
+// Zarr v3 specification:
+// https://zarr-specs.readthedocs.io/en/latest/v3/core/v3.0.html
+//
+// OME-NGFF specification:
+// https://ngff.openmicroscopy.org/latest/
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Zarr traversal limits
+const (
+	zarrMaxNodes     = 10000
+	zarrMaxDepth     = 32
+	zarrMaxListed    = 200
+	zarrMaxJSONBytes = 16 << 20
+)
+
+// ZarrStore is the read-only view of a key/value store holding a Zarr
+// hierarchy. Keys are "/"-separated. sync.Backend satisfies this interface.
+type ZarrStore interface {
+	Read(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// zarrDirLister is implemented by stores that can cheaply list the child
+// directories of a group.
+type zarrDirLister interface {
+	ListDirs(ctx context.Context, prefix string) ([]string, error)
+}
+
+// zarrDirStore is a ZarrStore backed by a local directory.
+type zarrDirStore struct {
+	dir string
+}
+
+// Read opens a key below the store directory.
+func (s zarrDirStore) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
+
+// ListDirs lists the subdirectories of a group, skipping hidden entries.
+func (s zarrDirStore) ListDirs(ctx context.Context, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, filepath.FromSlash(prefix)))
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	return dirs, nil
+}
+
+// ZarrExtractor extracts metadata from Zarr v2/v3 and OME-NGFF stores.
+type ZarrExtractor struct{}
+
+// Name returns the extractor name.
+func (e *ZarrExtractor) Name() string {
+	return "Zarr"
+}
+
+// SupportedFormats returns the file extensions this extractor handles.
+func (e *ZarrExtractor) SupportedFormats() []string {
+	return []string{".zarr"}
+}
+
+// CanHandle returns true for paths ending in .zarr and for local
+// directories holding Zarr metadata.
+func (e *ZarrExtractor) CanHandle(filename string) bool {
+	if strings.HasSuffix(strings.ToLower(strings.TrimRight(filename, "/")), ".zarr") {
+		return true
+	}
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		for _, name := range []string{"zarr.json", ".zgroup", ".zarray"} {
+			if _, err := os.Stat(filepath.Join(filename, name)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Extract extracts metadata from a local Zarr store directory.
+func (e *ZarrExtractor) Extract(filepath string) (map[string]interface{}, error) {
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a valid Zarr store: %s is not a directory", filepath)
+	}
+	return e.ExtractFromStore(context.Background(), zarrDirStore{dir: filepath}, "", filepath)
+}
+
+// ExtractFromReader is not supported: a Zarr store is a directory, not a
+// byte stream. Use ExtractFromStore instead.
+func (e *ZarrExtractor) ExtractFromReader(r io.Reader, filename string) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("zarr stores cannot be read from a stream; use ExtractFromStore")
+}
+
+// ExtractFromStore extracts metadata from the Zarr hierarchy rooted at
+// prefix in store. name is used for file_name in the output.
+func (e *ZarrExtractor) ExtractFromStore(ctx context.Context, store ZarrStore, prefix, name string) (map[string]interface{}, error) {
+	z := &zarrReader{ctx: ctx, store: store, prefix: strings.Trim(prefix, "/"), nodes: map[string]*zarrNode{}}
+	if lister, ok := store.(zarrDirLister); ok {
+		z.lister = lister
+	}
+
+	root, err := z.readNode("")
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("not a valid Zarr store: no zarr.json, .zgroup or .zarray found")
+	}
+	z.add(root)
+	if !z.loadConsolidated(root) {
+		z.walk(root, 0)
+	}
+
+	metadata := map[string]interface{}{
+		"format":         "Zarr",
+		"file_name":      filepath.Base(strings.TrimRight(name, "/")),
+		"extractor_name": "zarr",
+		"schema_name":    "zarr_v1",
+		"zarr_format":    root.format,
+		"node_type":      root.kind(),
+	}
+	if z.consolidated {
+		metadata["consolidated_metadata"] = true
+	}
+	if len(root.attrs) > 0 {
+		metadata["attributes"] = root.attrs
+	}
+	z.addStructure(metadata)
+	z.mapNGFF(metadata)
+	return metadata, nil
+}
+
+// zarrNode is one group or array in the hierarchy.
+type zarrNode struct {
+	path   string
+	format int
+	array  bool
+	meta   map[string]interface{}
+	attrs  map[string]interface{}
+}
+
+func (n *zarrNode) kind() string {
+	if n.array {
+		return "array"
+	}
+	return "group"
+}
+
+// zarrReader reads metadata documents from a store.
+type zarrReader struct {
+	ctx          context.Context
+	store        ZarrStore
+	lister       zarrDirLister
+	prefix       string
+	nodes        map[string]*zarrNode
+	paths        []string
+	consolidated bool
+	truncated    bool
+}
+
+// key joins the store prefix with a node path and document name.
+func (z *zarrReader) key(parts ...string) string {
+	var keep []string
+	for _, p := range append([]string{z.prefix}, parts...) {
+		if p = strings.Trim(p, "/"); p != "" {
+			keep = append(keep, p)
+		}
+	}
+	return strings.Join(keep, "/")
+}
+
+// readJSON decodes a JSON document. A missing or unreadable key returns nil.
+func (z *zarrReader) readJSON(key string) map[string]interface{} {
+	rc, err := z.store.Read(z.ctx, key)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = rc.Close() }()
+
+	var doc map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(rc, zarrMaxJSONBytes)).Decode(&doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// readNode reads the metadata of the node at path, or returns nil when
+// the path holds no Zarr node.
+func (z *zarrReader) readNode(path string) (*zarrNode, error) {
+	if doc := z.readJSON(z.key(path, "zarr.json")); doc != nil {
+		return newZarrV3Node(path, doc)
+	}
+
+	node := &zarrNode{path: path, format: 2}
+	if doc := z.readJSON(z.key(path, ".zarray")); doc != nil {
+		node.array, node.meta = true, doc
+	} else if doc := z.readJSON(z.key(path, ".zgroup")); doc != nil {
+		node.meta = doc
+	} else {
+		return nil, nil
+	}
+	node.attrs = z.readJSON(z.key(path, ".zattrs"))
+	return node, nil
+}
+
+// newZarrV3Node builds a node from a zarr.json document.
+func newZarrV3Node(path string, doc map[string]interface{}) (*zarrNode, error) {
+	if format, _ := doc["zarr_format"].(float64); format != 3 {
+		return nil, fmt.Errorf("not a valid Zarr store: unsupported zarr_format %v in %s/zarr.json", doc["zarr_format"], path)
+	}
+	node := &zarrNode{path: path, format: 3, meta: doc, array: doc["node_type"] == "array"}
+	node.attrs, _ = doc["attributes"].(map[string]interface{})
+	return node, nil
+}
+
+// join joins node paths relative to the store root.
+func (z *zarrReader) join(parent, child string) string {
+	return strings.Trim(strings.Trim(parent, "/")+"/"+strings.Trim(child, "/"), "/")
+}
+
+func (z *zarrReader) add(node *zarrNode) {
+	if _, ok := z.nodes[node.path]; ok {
+		return
+	}
+	z.nodes[node.path] = node
+	z.paths = append(z.paths, node.path)
+}
+
+// loadConsolidated adds every node from v2 .zmetadata or v3 inline
+// consolidated metadata and reports whether any was found.
+func (z *zarrReader) loadConsolidated(root *zarrNode) bool {
+	if root.format == 3 {
+		consolidated, _ := root.meta["consolidated_metadata"].(map[string]interface{})
+		docs, _ := consolidated["metadata"].(map[string]interface{})
+		if len(docs) == 0 {
+			return false
+		}
+		for path, raw := range docs {
+			if doc, ok := raw.(map[string]interface{}); ok {
+				if node, err := newZarrV3Node(path, doc); err == nil {
+					z.add(node)
+				}
+			}
+		}
+		z.consolidated = true
+		z.sortPaths()
+		return true
+	}
+
+	doc := z.readJSON(z.key(".zmetadata"))
+	docs, _ := doc["metadata"].(map[string]interface{})
+	if len(docs) == 0 {
+		return false
+	}
+	for key, raw := range docs {
+		meta, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		dir, name := "", key
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			dir, name = key[:i], key[i+1:]
+		}
+		if name != ".zarray" && name != ".zgroup" {
+			continue
+		}
+		node := &zarrNode{path: dir, format: 2, array: name == ".zarray", meta: meta}
+		node.attrs, _ = docs[strings.TrimPrefix(dir+"/.zattrs", "/")].(map[string]interface{})
+		z.add(node)
+	}
+	z.consolidated = true
+	z.sortPaths()
+	return true
+}
+
+func (z *zarrReader) sortPaths() {
+	sort.Strings(z.paths)
+}
+
+// walk visits the children of a group: paths declared by OME-NGFF
+// metadata and, when the store can list, every subdirectory.
+func (z *zarrReader) walk(group *zarrNode, depth int) {
+	if group.array || depth > zarrMaxDepth {
+		return
+	}
+	children := ngffChildPaths(ngffAttrs(group.attrs))
+	if _, ok := group.attrs["bioformats2raw.layout"]; ok {
+		if ome, _ := z.readNode(z.join(group.path, "OME")); ome != nil {
+			series, _ := ome.attrs["series"].([]interface{})
+			for _, s := range series {
+				if name, ok := s.(string); ok {
+					children = append(children, name)
+				}
+			}
+		}
+	}
+	if z.lister != nil {
+		if dirs, err := z.lister.ListDirs(z.ctx, z.key(group.path)); err == nil {
+			children = append(children, dirs...)
+		}
+	}
+	sort.Strings(children)
+
+	for _, child := range children {
+		path := z.join(group.path, child)
+		if _, seen := z.nodes[path]; seen || path == "" {
+			continue
+		}
+		if len(z.nodes) >= zarrMaxNodes {
+			z.truncated = true
+			return
+		}
+		node, err := z.readNode(path)
+		if err != nil || node == nil {
+			continue
+		}
+		z.add(node)
+		z.walk(node, depth+1)
+	}
+}
+
+// addStructure reports group and array counts and describes each array.
+func (z *zarrReader) addStructure(metadata map[string]interface{}) {
+	var arrays []map[string]interface{}
+	groups, arrayCount := 0, 0
+	for _, path := range z.paths {
+		node := z.nodes[path]
+		if !node.array {
+			if path != "" {
+				groups++
+			}
+			continue
+		}
+		arrayCount++
+		if len(arrays) < zarrMaxListed {
+			arrays = append(arrays, describeZarrArray(node))
+		}
+	}
+
+	metadata["group_count"] = groups
+	metadata["array_count"] = arrayCount
+	if len(arrays) > 0 {
+		metadata["arrays"] = arrays
+	}
+	if arrayCount > len(arrays) {
+		metadata["arrays_truncated"] = true
+	}
+	if z.truncated {
+		metadata["extraction_note"] = fmt.Sprintf("walk stopped after %d nodes", zarrMaxNodes)
+	}
+}
+
+// describeZarrArray returns the shape, chunking, dtype and codecs of an array.
+func describeZarrArray(node *zarrNode) map[string]interface{} {
+	path := node.path
+	if path == "" {
+		path = "/"
+	}
+	info := map[string]interface{}{"path": path}
+	if shape := zarrInts(node.meta["shape"]); shape != nil {
+		info["shape"] = shape
+	}
+	if fill, ok := node.meta["fill_value"]; ok && fill != nil {
+		info["fill_value"] = fill
+	}
+
+	if node.format == 2 {
+		if chunks := zarrInts(node.meta["chunks"]); chunks != nil {
+			info["chunks"] = chunks
+		}
+		info["dtype"] = zarrV2DType(node.meta["dtype"])
+		if order, ok := node.meta["order"].(string); ok {
+			info["order"] = order
+		}
+		if compressor, ok := node.meta["compressor"].(map[string]interface{}); ok {
+			info["compressor"] = compressor["id"]
+		}
+		var filters []string
+		list, _ := node.meta["filters"].([]interface{})
+		for _, f := range list {
+			if m, ok := f.(map[string]interface{}); ok {
+				if id, ok := m["id"].(string); ok {
+					filters = append(filters, id)
+				}
+			}
+		}
+		if len(filters) > 0 {
+			info["filters"] = filters
+		}
+		return info
+	}
+
+	if dtype, ok := node.meta["data_type"].(string); ok {
+		info["dtype"] = dtype
+	}
+	if grid, ok := node.meta["chunk_grid"].(map[string]interface{}); ok {
+		config, _ := grid["configuration"].(map[string]interface{})
+		if chunks := zarrInts(config["chunk_shape"]); chunks != nil {
+			info["chunks"] = chunks
+		}
+	}
+	if names, ok := node.meta["dimension_names"].([]interface{}); ok {
+		info["dimension_names"] = names
+	}
+
+	codecs, _ := node.meta["codecs"].([]interface{})
+	var names []string
+	for _, c := range codecs {
+		codec, _ := c.(map[string]interface{})
+		name, _ := codec["name"].(string)
+		names = append(names, name)
+		if name != "sharding_indexed" {
+			continue
+		}
+		// Sharded arrays store inner chunks within each shard
+		config, _ := codec["configuration"].(map[string]interface{})
+		if inner := zarrInts(config["chunk_shape"]); inner != nil {
+			info["shard_shape"] = info["chunks"]
+			info["chunks"] = inner
+		}
+		innerCodecs, _ := config["codecs"].([]interface{})
+		for _, ic := range innerCodecs {
+			if m, ok := ic.(map[string]interface{}); ok {
+				if n, ok := m["name"].(string); ok {
+					names = append(names, n)
+				}
+			}
+		}
+	}
+	if len(names) > 0 {
+		info["codecs"] = names
+	}
+	return info
+}
+
+// zarrInts converts a JSON number array to []int64.
+func zarrInts(v interface{}) []int64 {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]int64, 0, len(list))
+	for _, item := range list {
+		f, ok := item.(float64)
+		if !ok {
+			return nil
+		}
+		out = append(out, int64(f))
+	}
+	return out
+}
+
+// zarrV2DType converts a NumPy type string such as "<u2" to a name such as
+// "uint16". Structured dtypes are reported as "structured".
+func zarrV2DType(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		return "structured"
+	}
+	if len(s) < 3 {
+		return s
+	}
+	kind, size := s[1], s[2:]
+	bits := func() string {
+		var n int
+		if _, err := fmt.Sscanf(size, "%d", &n); err != nil {
+			return size
+		}
+		return fmt.Sprint(n * 8)
+	}
+	switch kind {
+	case 'b':
+		return "bool"
+	case 'i':
+		return "int" + bits()
+	case 'u':
+		return "uint" + bits()
+	case 'f':
+		return "float" + bits()
+	case 'c':
+		return "complex" + bits()
+	case 'S':
+		return "bytes" + size
+	case 'U':
+		return "str" + size
+	case 'M':
+		return "datetime64"
+	case 'm':
+		return "timedelta64"
+	}
+	return s
+}
+
+// ngffAttrs returns the OME-NGFF attributes of a group; from NGFF 0.5 they
+// are nested under "ome".
+func ngffAttrs(attrs map[string]interface{}) map[string]interface{} {
+	if ome, ok := attrs["ome"].(map[string]interface{}); ok {
+		return ome
+	}
+	return attrs
+}
+
+// ngffChildPaths returns the child paths declared by OME-NGFF metadata:
+// multiscale datasets, labels, plate wells, well fields of view and the
+// first bioformats2raw series.
+func ngffChildPaths(attrs map[string]interface{}) []string {
+	var paths []string
+	addPath := func(v interface{}) {
+		if m, ok := v.(map[string]interface{}); ok {
+			if p, ok := m["path"].(string); ok && p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	// Images may hold a "labels" group, which lists the label images
+	multiscales, _ := attrs["multiscales"].([]interface{})
+	if len(multiscales) > 0 {
+		paths = append(paths, "labels")
+	}
+	for _, ms := range multiscales {
+		m, _ := ms.(map[string]interface{})
+		datasets, _ := m["datasets"].([]interface{})
+		for _, ds := range datasets {
+			addPath(ds)
+		}
+	}
+	if labels, ok := attrs["labels"].([]interface{}); ok {
+		for _, l := range labels {
+			if name, ok := l.(string); ok {
+				paths = append(paths, name)
+			}
+		}
+	}
+	if plate, ok := attrs["plate"].(map[string]interface{}); ok {
+		wells, _ := plate["wells"].([]interface{})
+		for _, w := range wells {
+			addPath(w)
+		}
+	}
+	if well, ok := attrs["well"].(map[string]interface{}); ok {
+		images, _ := well["images"].([]interface{})
+		for _, img := range images {
+			addPath(img)
+		}
+	}
+	if _, ok := attrs["bioformats2raw.layout"]; ok {
+		// Series are numbered groups listed by the OME group
+		paths = append(paths, "OME", "0")
+	}
+	return paths
+}
+
+// ngffLengthUnits maps OME-NGFF (UDUNITS-2) length units to OME symbols.
+var ngffLengthUnits = map[string]string{
+	"angstrom":   "Å",
+	"picometer":  "pm",
+	"nanometer":  "nm",
+	"micrometer": "µm",
+	"millimeter": "mm",
+	"centimeter": "cm",
+	"meter":      "m",
+}
+
+// ngffTimeUnits maps OME-NGFF time units to symbols for omeTimeInSeconds.
+var ngffTimeUnits = map[string]string{
+	"microsecond": "us",
+	"millisecond": "ms",
+	"second":      "s",
+	"minute":      "min",
+	"hour":        "h",
+}
+
+// mapNGFF maps OME-NGFF images, labels and plates to MicroscopyMetadata
+// field names. Each image is reported under "images"; a single image is
+// also promoted to the top level.
+func (z *zarrReader) mapNGFF(metadata map[string]interface{}) {
+	var images []map[string]interface{}
+	var labels []string
+	for _, path := range z.paths {
+		node := z.nodes[path]
+		attrs := ngffAttrs(node.attrs)
+		if node.array || attrs == nil {
+			continue
+		}
+		if _, ok := attrs["image-label"]; ok {
+			labels = append(labels, path)
+			continue
+		}
+		if _, ok := attrs["multiscales"]; ok {
+			images = append(images, z.ngffImage(node, attrs))
+		}
+	}
+
+	root := ngffAttrs(z.nodes[""].attrs)
+	plate, isPlate := root["plate"].(map[string]interface{})
+	if len(images) == 0 && !isPlate {
+		return
+	}
+
+	metadata["format"] = "OME-Zarr"
+	metadata["schema_name"] = "ome_zarr_v1"
+	metadata["instrument_type"] = "microscopy"
+	if version, ok := root["version"].(string); ok {
+		metadata["ngff_version"] = version
+	}
+
+	if isPlate {
+		if name, ok := plate["name"].(string); ok {
+			metadata["plate_name"] = name
+		}
+		for _, field := range []struct{ key, out string }{
+			{"rows", "plate_rows"}, {"columns", "plate_columns"}, {"wells", "well_count"}, {"acquisitions", "acquisition_count"},
+		} {
+			if list, ok := plate[field.key].([]interface{}); ok {
+				metadata[field.out] = len(list)
+			}
+		}
+		if n, ok := plate["field_count"].(float64); ok {
+			metadata["field_count"] = int(n)
+		}
+	}
+	if len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if len(images) == 0 {
+		return
+	}
+
+	metadata["image_count"] = len(images)
+	if len(images) > zarrMaxListed {
+		images = images[:zarrMaxListed]
+	}
+	metadata["images"] = images
+	if len(images) == 1 || isPlate {
+		for k, v := range images[0] {
+			if _, exists := metadata[k]; !exists && k != "path" {
+				metadata[k] = v
+			}
+		}
+	}
+}
+
+// ngffImage maps one multiscales image group.
+func (z *zarrReader) ngffImage(node *zarrNode, attrs map[string]interface{}) map[string]interface{} {
+	path := node.path
+	if path == "" {
+		path = "/"
+	}
+	image := map[string]interface{}{"path": path}
+
+	multiscales, _ := attrs["multiscales"].([]interface{})
+	if len(multiscales) == 0 {
+		return image
+	}
+	ms, _ := multiscales[0].(map[string]interface{})
+	if version, ok := ms["version"].(string); ok {
+		image["ngff_version"] = version
+	}
+	if name, ok := ms["name"].(string); ok && name != "" {
+		image["image_name"] = name
+	}
+	datasets, _ := ms["datasets"].([]interface{})
+	image["resolution_levels"] = len(datasets)
+
+	// Axes: objects from 0.4, plain names in 0.3, implicit tczyx before
+	type axis struct{ name, kind, unit string }
+	var axes []axis
+	switch list := ms["axes"].(type) {
+	case []interface{}:
+		for _, a := range list {
+			switch v := a.(type) {
+			case string:
+				axes = append(axes, axis{name: v})
+			case map[string]interface{}:
+				ax := axis{}
+				ax.name, _ = v["name"].(string)
+				ax.kind, _ = v["type"].(string)
+				ax.unit, _ = v["unit"].(string)
+				axes = append(axes, ax)
+			}
+		}
+	default:
+		for _, name := range []string{"t", "c", "z", "y", "x"} {
+			axes = append(axes, axis{name: name})
+		}
+	}
+	var axisInfo []map[string]interface{}
+	var order strings.Builder
+	for _, ax := range axes {
+		info := map[string]interface{}{"name": ax.name}
+		if ax.kind != "" {
+			info["type"] = ax.kind
+		}
+		if ax.unit != "" {
+			info["unit"] = ax.unit
+		}
+		axisInfo = append(axisInfo, info)
+		order.WriteString(ax.name)
+	}
+	image["axes"] = axisInfo
+	image["dimension_order"] = order.String()
+
+	// Full-resolution shape and scale
+	var level0 *zarrNode
+	scale := ngffScale(ms["coordinateTransformations"])
+	if len(datasets) > 0 {
+		ds, _ := datasets[0].(map[string]interface{})
+		p, _ := ds["path"].(string)
+		level0 = z.nodes[z.join(node.path, p)]
+		if s := ngffScale(ds["coordinateTransformations"]); s != nil {
+			if scale == nil {
+				scale = s
+			} else if len(scale) == len(s) {
+				for i := range scale {
+					scale[i] *= s[i]
+				}
+			}
+		}
+	}
+
+	dimFields := map[string]string{
+		"x": "image_width", "y": "image_height", "z": "image_depth",
+		"c": "num_channels", "t": "num_timepoints",
+	}
+	if level0 != nil {
+		shape := zarrInts(level0.meta["shape"])
+		for i, ax := range axes {
+			if field, ok := dimFields[strings.ToLower(ax.name)]; ok && i < len(shape) {
+				image[field] = int(shape[i])
+			}
+		}
+		image["dtype"] = describeZarrArray(level0)["dtype"]
+	}
+
+	if len(scale) == len(axes) {
+		for i, ax := range axes {
+			name := strings.ToLower(ax.name)
+			switch {
+			case name == "x" || name == "y" || name == "z":
+				if symbol, ok := ngffLengthUnits[ax.unit]; ok {
+					if um, ok := omeLengthIn(scale[i], symbol, "µm"); ok {
+						image["pixel_size_"+name+"_um"] = um
+					}
+				}
+			case name == "t" || ax.kind == "time":
+				if symbol, ok := ngffTimeUnits[ax.unit]; ok {
+					if seconds, ok := omeTimeInSeconds(scale[i], symbol); ok {
+						image["time_increment_s"] = seconds
+					}
+				}
+			}
+		}
+	}
+
+	if omero, ok := attrs["omero"].(map[string]interface{}); ok {
+		if name, ok := omero["name"].(string); ok && name != "" {
+			if _, exists := image["image_name"]; !exists {
+				image["image_name"] = name
+			}
+		}
+		var channels []map[string]interface{}
+		list, _ := omero["channels"].([]interface{})
+		for i, c := range list {
+			ch, _ := c.(map[string]interface{})
+			channel := map[string]interface{}{"index": i}
+			if label, ok := ch["label"].(string); ok && label != "" {
+				channel["name"] = label
+			}
+			if color, ok := ch["color"].(string); ok && len(color) == 6 {
+				channel["color"] = "#" + strings.ToUpper(color)
+			}
+			if active, ok := ch["active"].(bool); ok {
+				channel["active"] = active
+			}
+			if window, ok := ch["window"].(map[string]interface{}); ok {
+				channel["window_start"] = window["start"]
+				channel["window_end"] = window["end"]
+			}
+			channels = append(channels, channel)
+		}
+		if len(channels) > 0 {
+			image["channels"] = channels
+			if _, ok := image["num_channels"]; !ok {
+				image["num_channels"] = len(channels)
+			}
+		}
+	}
+	return image
+}
+
+// ngffScale returns the scale vector of a coordinateTransformations list.
+func ngffScale(v interface{}) []float64 {
+	list, _ := v.([]interface{})
+	for _, t := range list {
+		m, _ := t.(map[string]interface{})
+		if m["type"] != "scale" {
+			continue
+		}
+		values, _ := m["scale"].([]interface{})
+		scale := make([]float64, 0, len(values))
+		for _, s := range values {
+			f, ok := s.(float64)
+			if !ok {
+				return nil
+			}
+			scale = append(scale, f)
+		}
+		return scale
+	}
+	return nil
+}
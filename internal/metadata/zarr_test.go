@@ -0,0 +1,261 @@
+// Copyright 2025 Scott Friedman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testZarrStore is an in-memory ZarrStore that records every key read,
+// standing in for an S3 backend.
+type testZarrStore struct {
+	objects map[string]string
+	reads   []string
+}
+
+func (s *testZarrStore) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.reads = append(s.reads, key)
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func writeZarrFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const testNGFFAxes = `[
+  {"name": "c", "type": "channel"},
+  {"name": "z", "type": "space", "unit": "micrometer"},
+  {"name": "y", "type": "space", "unit": "micrometer"},
+  {"name": "x", "type": "space", "unit": "micrometer"}
+]`
+
+func TestZarrExtractorLocalNGFF(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "cells.ome.zarr")
+	writeZarrFiles(t, root, map[string]string{
+		".zgroup": `{"zarr_format": 2}`,
+		".zattrs": `{
+  "multiscales": [{
+    "version": "0.4",
+    "name": "cells",
+    "axes": ` + testNGFFAxes + `,
+    "datasets": [
+      {"path": "0", "coordinateTransformations": [{"type": "scale", "scale": [1, 2.0, 0.325, 0.325]}]},
+      {"path": "1", "coordinateTransformations": [{"type": "scale", "scale": [1, 2.0, 0.65, 0.65]}]}
+    ]
+  }],
+  "omero": {"channels": [
+    {"label": "DAPI", "color": "0000ff", "active": true, "window": {"start": 0, "end": 1500}},
+    {"label": "GFP", "color": "00FF00", "active": false}
+  ]}
+}`,
+		"0/.zarray": `{"zarr_format": 2, "shape": [2, 10, 512, 512], "chunks": [1, 1, 256, 256], "dtype": "<u2",
+  "compressor": {"id": "blosc", "cname": "zstd"}, "fill_value": 0, "order": "C", "filters": null}`,
+		"0/0/0/0/0":               "chunk data is never read",
+		"1/.zarray":               `{"zarr_format": 2, "shape": [2, 10, 256, 256], "chunks": [1, 1, 256, 256], "dtype": "<u2", "compressor": null, "fill_value": 0, "order": "C", "filters": null}`,
+		"labels/.zgroup":          `{"zarr_format": 2}`,
+		"labels/.zattrs":          `{"labels": ["nuclei"]}`,
+		"labels/nuclei/.zgroup":   `{"zarr_format": 2}`,
+		"labels/nuclei/.zattrs":   `{"image-label": {"version": "0.4"}, "multiscales": [{"version": "0.4", "axes": ` + testNGFFAxes + `, "datasets": [{"path": "0"}]}]}`,
+		"labels/nuclei/0/.zarray": `{"zarr_format": 2, "shape": [1, 10, 512, 512], "chunks": [1, 1, 512, 512], "dtype": "|u1", "compressor": null, "fill_value": 0, "order": "C", "filters": null}`,
+		"extra/.zgroup":           `{"zarr_format": 2}`,
+	})
+
+	registry := NewExtractorRegistry()
+	registry.RegisterDefaults()
+	if extractor := registry.FindExtractor(root); extractor == nil || extractor.Name() != "Zarr" {
+		t.Fatalf("FindExtractor(%s) = %v, want Zarr", root, extractor)
+	}
+
+	metadata, err := registry.Extract(root)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":            "OME-Zarr",
+		"file_name":         "cells.ome.zarr",
+		"zarr_format":       2,
+		"node_type":         "group",
+		"instrument_type":   "microscopy",
+		"ngff_version":      "0.4",
+		"image_name":        "cells",
+		"image_width":       512,
+		"image_height":      512,
+		"image_depth":       10,
+		"num_channels":      2,
+		"resolution_levels": 2,
+		"dimension_order":   "czyx",
+		"dtype":             "uint16",
+		"image_count":       1,
+		"array_count":       3,
+		"group_count":       3,
+		"labels":            []string{"labels/nuclei"},
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(metadata[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+	for key, value := range map[string]float64{"pixel_size_x_um": 0.325, "pixel_size_y_um": 0.325, "pixel_size_z_um": 2} {
+		if got, ok := metadata[key].(float64); !ok || math.Abs(got-value) > 1e-9 {
+			t.Errorf("%s = %v, want %v", key, metadata[key], value)
+		}
+	}
+
+	channels := metadata["channels"].([]map[string]interface{})
+	if channels[0]["name"] != "DAPI" || channels[0]["color"] != "#0000FF" || channels[0]["window_end"] != 1500.0 {
+		t.Errorf("channel 0 = %v", channels[0])
+	}
+
+	arrays := metadata["arrays"].([]map[string]interface{})
+	if arrays[0]["path"] != "0" || !reflect.DeepEqual(arrays[0]["chunks"], []int64{1, 1, 256, 256}) || arrays[0]["compressor"] != "blosc" {
+		t.Errorf("array 0 = %v", arrays[0])
+	}
+}
+
+func TestZarrExtractorRemoteV3(t *testing.T) {
+	store := &testZarrStore{objects: map[string]string{
+		"lab/run1/image.zarr/zarr.json": `{"zarr_format": 3, "node_type": "group", "attributes": {"ome": {
+  "version": "0.5",
+  "multiscales": [{
+    "axes": [
+      {"name": "t", "type": "time", "unit": "second"},
+      {"name": "c", "type": "channel"},
+      {"name": "y", "type": "space", "unit": "nanometer"},
+      {"name": "x", "type": "space", "unit": "nanometer"}
+    ],
+    "datasets": [{"path": "s0", "coordinateTransformations": [{"type": "scale", "scale": [30, 1, 100, 100]}]}]
+  }]
+}}}`,
+		"lab/run1/image.zarr/s0/zarr.json": `{"zarr_format": 3, "node_type": "array", "shape": [5, 3, 1024, 2048],
+  "data_type": "uint8",
+  "chunk_grid": {"name": "regular", "configuration": {"chunk_shape": [1, 1, 1024, 1024]}},
+  "chunk_key_encoding": {"name": "default"},
+  "fill_value": 0,
+  "codecs": [{"name": "sharding_indexed", "configuration": {
+    "chunk_shape": [1, 1, 256, 256],
+    "codecs": [{"name": "bytes"}, {"name": "zstd", "configuration": {"level": 3}}]
+  }}],
+  "dimension_names": ["t", "c", "y", "x"]}`,
+		"lab/run1/image.zarr/s0/c/0/0/0/0": "chunk data is never read",
+	}}
+
+	metadata, err := (&ZarrExtractor{}).ExtractFromStore(context.Background(), store, "lab/run1/image.zarr/", "s3://bucket/lab/run1/image.zarr")
+	if err != nil {
+		t.Fatalf("ExtractFromStore() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"format":         "OME-Zarr",
+		"file_name":      "image.zarr",
+		"zarr_format":    3,
+		"ngff_version":   "0.5",
+		"image_width":    2048,
+		"image_height":   1024,
+		"num_channels":   3,
+		"num_timepoints": 5,
+		"dtype":          "uint8",
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(metadata[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, metadata[key], value)
+		}
+	}
+	if metadata["time_increment_s"] != 30.0 {
+		t.Errorf("time_increment_s = %v, want 30", metadata["time_increment_s"])
+	}
+	if got, _ := metadata["pixel_size_x_um"].(float64); math.Abs(got-0.1) > 1e-9 {
+		t.Errorf("pixel_size_x_um = %v, want 0.1", metadata["pixel_size_x_um"])
+	}
+
+	array := metadata["arrays"].([]map[string]interface{})[0]
+	if !reflect.DeepEqual(array["chunks"], []int64{1, 1, 256, 256}) || !reflect.DeepEqual(array["shard_shape"], []int64{1, 1, 1024, 1024}) {
+		t.Errorf("chunks/shard_shape = %v/%v", array["chunks"], array["shard_shape"])
+	}
+	if !reflect.DeepEqual(array["codecs"], []string{"sharding_indexed", "bytes", "zstd"}) {
+		t.Errorf("codecs = %v", array["codecs"])
+	}
+
+	for _, key := range store.reads {
+		if !strings.HasSuffix(key, "zarr.json") && !strings.Contains(key, "/.z") {
+			t.Errorf("read non-metadata key %q", key)
+		}
+	}
+}
+
+func TestZarrExtractorConsolidated(t *testing.T) {
+	store := &testZarrStore{objects: map[string]string{
+		".zgroup": `{"zarr_format": 2}`,
+		".zmetadata": `{"zarr_consolidated_format": 1, "metadata": {
+  ".zgroup": {"zarr_format": 2},
+  ".zattrs": {"experiment": "run 7"},
+  "raw/.zgroup": {"zarr_format": 2},
+  "raw/counts/.zarray": {"zarr_format": 2, "shape": [1000, 20], "chunks": [100, 20], "dtype": "<f8", "compressor": {"id": "zstd"}, "fill_value": "NaN", "order": "C", "filters": [{"id": "delta"}]},
+  "raw/counts/.zattrs": {"units": "counts"}
+}}`,
+	}}
+
+	metadata, err := (&ZarrExtractor{}).ExtractFromStore(context.Background(), store, "", "counts.zarr")
+	if err != nil {
+		t.Fatalf("ExtractFromStore() error = %v", err)
+	}
+	if metadata["format"] != "Zarr" || metadata["consolidated_metadata"] != true {
+		t.Errorf("format/consolidated = %v/%v", metadata["format"], metadata["consolidated_metadata"])
+	}
+	if metadata["array_count"] != 1 || metadata["group_count"] != 1 {
+		t.Errorf("array_count/group_count = %v/%v, want 1/1", metadata["array_count"], metadata["group_count"])
+	}
+	array := metadata["arrays"].([]map[string]interface{})[0]
+	if array["path"] != "raw/counts" || array["dtype"] != "float64" || array["compressor"] != "zstd" {
+		t.Errorf("array = %v", array)
+	}
+	if !reflect.DeepEqual(array["filters"], []string{"delta"}) {
+		t.Errorf("filters = %v", array["filters"])
+	}
+}
+
+func TestZarrExtractorInvalid(t *testing.T) {
+	extractor := &ZarrExtractor{}
+	if _, err := extractor.Extract(t.TempDir()); err == nil {
+		t.Error("expected error for directory without Zarr metadata")
+	}
+	if _, err := extractor.ExtractFromReader(strings.NewReader("{}"), "a.zarr"); err == nil {
+		t.Error("expected error from ExtractFromReader")
+	}
+	if !extractor.CanHandle("s3://bucket/plate.ome.zarr/") {
+		t.Error("CanHandle should accept .zarr paths with a trailing slash")
+	}
+}
@@ -296,6 +296,14 @@ func (e *ZeissCZIExtractor) CanHandle(filename string) bool {
 	return strings.HasSuffix(strings.ToLower(filename), ".czi")
 }
 
+// Sniff recognises the ZISRAWFILE segment at the start of a CZI file.
+func (e *ZeissCZIExtractor) Sniff(in *SniffInput) (int, string) {
+	if in.hasPrefix("ZISRAWFILE") {
+		return 100, "ZISRAWFILE segment header"
+	}
+	return 0, ""
+}
+
 // Extract extracts metadata from a CZI file.
 func (e *ZeissCZIExtractor) Extract(filepath string) (map[string]interface{}, error) {
 	f, err := os.Open(filepath)
@@ -17,7 +17,10 @@ package sync
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"sync"
+
+	"github.com/scttfrdmn/cicada/internal/metadata"
 )
 
 // SyncOptions configures sync behavior.
@@ -100,8 +103,9 @@ func (e *Engine) Sync(ctx context.Context, sourcePath, destPath string) error {
 			continue // Skip directories
 		}
 
-		// Strip source prefix to get relative path
-		relPath := stripPrefix(srcFile.Path, sourcePath)
+		// Strip source prefix to get relative path, moving metadata
+		// records between the local and object store layouts
+		relPath := e.recordPath(stripPrefix(srcFile.Path, sourcePath))
 
 		dstFile, exists := dstMap[relPath]
 		if !exists || needsSync(srcFile, *dstFile) {
@@ -255,6 +259,19 @@ func needsSync(src, dst FileInfo) bool {
 	return src.ModTime.After(dst.ModTime)
 }
 
+// recordPath maps the relative path of a metadata record to where the
+// destination keeps it. Local directories keep records in .cicada
+// directories next to the data files; object stores keep them next to the
+// objects. Other paths are returned unchanged.
+func (e *Engine) recordPath(relPath string) string {
+	_, srcLocal := e.source.(*LocalBackend)
+	_, dstLocal := e.destination.(*LocalBackend)
+	if srcLocal == dstLocal {
+		return relPath
+	}
+	return metadata.RecordLayoutPath(filepath.ToSlash(relPath), dstLocal)
+}
+
 // stripPrefix removes the prefix from a path.
 // For example: stripPrefix("prefix/file.txt", "prefix/") returns "file.txt"
 func stripPrefix(path, prefix string) string {
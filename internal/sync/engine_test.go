@@ -16,6 +16,8 @@ package sync
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -344,3 +346,61 @@ func TestEngine_Sync(t *testing.T) {
 		}
 	})
 }
+
+func TestEngine_SyncMetadataRecords(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("upload moves records next to objects", func(t *testing.T) {
+		root := t.TempDir()
+		// A batch sidecar sits next to the record's object store key
+		for _, file := range []string{"run/a.czi", "run/a.czi.extract.json", "run/.cicada/a.czi.metadata.json", ".cicada/b.metadata.json"} {
+			path := filepath.Join(root, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		src, err := NewLocalBackend(root)
+		if err != nil {
+			t.Fatalf("NewLocalBackend() error = %v", err)
+		}
+		dst := newMockBackend()
+
+		if err := NewEngine(src, dst, SyncOptions{}).Sync(ctx, "", ""); err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+		for _, key := range []string{"run/a.czi", "run/a.czi.extract.json", "run/a.czi.metadata.json", "b.metadata.json"} {
+			if _, ok := dst.files[key]; !ok {
+				t.Errorf("object %s not uploaded, have %v", key, dst.files)
+			}
+		}
+		if len(dst.files) != 4 {
+			t.Errorf("uploaded %d objects, want 4", len(dst.files))
+		}
+		if record := dst.files["run/a.czi.metadata.json"]; record.content != "run/.cicada/a.czi.metadata.json" {
+			t.Errorf("record object holds %q, want the store record", record.content)
+		}
+	})
+
+	t.Run("download moves records into .cicada", func(t *testing.T) {
+		src := newMockBackend()
+		src.addFile("run/a.czi", "data", "etag1", time.Now())
+		src.addFile("run/a.czi.metadata.json", "{}", "etag2", time.Now())
+		root := t.TempDir()
+		dst, err := NewLocalBackend(root)
+		if err != nil {
+			t.Fatalf("NewLocalBackend() error = %v", err)
+		}
+
+		if err := NewEngine(src, dst, SyncOptions{}).Sync(ctx, "", ""); err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+		for _, file := range []string{"run/a.czi", "run/.cicada/a.czi.metadata.json"} {
+			if _, err := os.Stat(filepath.Join(root, file)); err != nil {
+				t.Errorf("file %s not downloaded: %v", file, err)
+			}
+		}
+	})
+}
@@ -28,6 +28,10 @@ import (
 	"github.com/scttfrdmn/cicada/internal/metadata"
 )
 
+// S3Backend can serve as a metadata.ZarrStore, so Zarr stores are read in
+// place without downloading chunks.
+var _ metadata.ZarrStore = (*S3Backend)(nil)
+
 // S3Backend implements Backend for AWS S3.
 type S3Backend struct {
 	client *s3.Client